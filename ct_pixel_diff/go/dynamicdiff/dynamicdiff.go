@@ -45,8 +45,13 @@ func NewPixelDiffStoreMapper(diffInstance interface{}) diffstore.DiffStoreMapper
 }
 
 // DiffFn implements the diffstore.DiffStoreMapper interface.
-func (g PixelDiffStoreMapper) DiffFn(leftImg *image.NRGBA, rightImg *image.NRGBA) (interface{}, *image.NRGBA) {
-	return DynamicContentDiff(leftImg, rightImg)
+func (g PixelDiffStoreMapper) DiffFn(leftImg image.Image, rightImg image.Image) (interface{}, *image.NRGBA) {
+	return DynamicContentDiff(diff.GetNRGBA(leftImg), diff.GetNRGBA(rightImg))
+}
+
+// MetricsVersion implements the diffstore.DiffStoreMapper interface.
+func (g PixelDiffStoreMapper) MetricsVersion() string {
+	return ""
 }
 
 // DiffID implements the diffstore.DiffStoreMapper interface.
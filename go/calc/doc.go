@@ -2,9 +2,16 @@
 //
 //   f(g(h("foo"), i(3, "bar")))
 //
-// Note that while it does understand strings and numbers, it doesn't
-// do binary operators. We can do those via functions if needed, ala
-// add(x, y), sub(x, y), etc.
+// Expressions can also use the arithmetic operators +, -, * and /, with the
+// usual precedence, and parentheses:
+//
+//   (f("a") - f("b")) / f("b") * 100
+//
+// and can be preceded by let bindings, which are only evaluated once:
+//
+//   let x = f("a");
+//   let y = f("b");
+//   (x - y) / y * 100
 //
 // Caveats:
 // * Only handles ASCII.
@@ -3,6 +3,7 @@ package calc
 import (
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 
 	"go.skia.org/infra/go/vec32"
@@ -556,3 +557,350 @@ func (TraceStepFunc) Describe() string {
 }
 
 var traceStepFunc = TraceStepFunc{}
+
+// numArg returns the value of the i'th argument of node, which must be a
+// number. The name of the function is used in error messages.
+func numArg(node *Node, i int, name string) (float64, error) {
+	if node.Args[i].Typ != NodeNum {
+		return 0, fmt.Errorf("%s() takes a number as argument %d.", name, i+1)
+	}
+	v, err := strconv.ParseFloat(node.Args[i].Val, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s() argument %d not a valid number %s : %s", name, i+1, node.Args[i].Val, err)
+	}
+	return v, nil
+}
+
+// foldRows folds the values at each index across all the rows into a single
+// value using 'f', which is passed all the non-missing values at that index.
+// If all the values at an index are vec32.MISSING_DATA_SENTINEL then the
+// result at that index is vec32.MISSING_DATA_SENTINEL.
+func foldRows(rows Rows, f func(values []float32) float32) []float32 {
+	ret := newRow(rows)
+	values := make([]float32, 0, len(rows))
+	for i := range ret {
+		values = values[:0]
+		for _, r := range rows {
+			if v := r[i]; v != vec32.MISSING_DATA_SENTINEL {
+				values = append(values, v)
+			}
+		}
+		if len(values) > 0 {
+			ret[i] = f(values)
+		}
+	}
+	return ret
+}
+
+type MinFunc struct{}
+
+// minFunc implements Func and merges the values of all argument rows into a
+// single trace of the minimum value at each point.
+//
+// vec32.MISSING_DATA_SENTINEL values are ignored.
+func (MinFunc) Eval(ctx *Context, node *Node) (Rows, error) {
+	if len(node.Args) != 1 {
+		return nil, fmt.Errorf("min() takes a single argument.")
+	}
+	if node.Args[0].Typ != NodeFunc {
+		return nil, fmt.Errorf("min() takes a function argument.")
+	}
+	rows, err := node.Args[0].Eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("min() argument failed to evaluate: %s", err)
+	}
+	if len(rows) == 0 {
+		return rows, nil
+	}
+	ret := foldRows(rows, func(values []float32) float32 {
+		min := values[0]
+		for _, v := range values[1:] {
+			if v < min {
+				min = v
+			}
+		}
+		return min
+	})
+	return Rows{ctx.formula: ret}, nil
+}
+
+func (MinFunc) Describe() string {
+	return `min() folds the values of all argument rows into a single trace of the minimum at each point.`
+}
+
+var minFunc = MinFunc{}
+
+type MaxFunc struct{}
+
+// maxFunc implements Func and merges the values of all argument rows into a
+// single trace of the maximum value at each point.
+//
+// vec32.MISSING_DATA_SENTINEL values are ignored.
+func (MaxFunc) Eval(ctx *Context, node *Node) (Rows, error) {
+	if len(node.Args) != 1 {
+		return nil, fmt.Errorf("max() takes a single argument.")
+	}
+	if node.Args[0].Typ != NodeFunc {
+		return nil, fmt.Errorf("max() takes a function argument.")
+	}
+	rows, err := node.Args[0].Eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("max() argument failed to evaluate: %s", err)
+	}
+	if len(rows) == 0 {
+		return rows, nil
+	}
+	ret := foldRows(rows, func(values []float32) float32 {
+		max := values[0]
+		for _, v := range values[1:] {
+			if v > max {
+				max = v
+			}
+		}
+		return max
+	})
+	return Rows{ctx.formula: ret}, nil
+}
+
+func (MaxFunc) Describe() string {
+	return `max() folds the values of all argument rows into a single trace of the maximum at each point.`
+}
+
+var maxFunc = MaxFunc{}
+
+type PercentileFunc struct{}
+
+// percentileFunc implements Func and merges the values of all argument rows
+// into a single trace of the given percentile at each point, linearly
+// interpolating between the closest values.
+//
+// vec32.MISSING_DATA_SENTINEL values are ignored.
+func (PercentileFunc) Eval(ctx *Context, node *Node) (Rows, error) {
+	if len(node.Args) != 2 {
+		return nil, fmt.Errorf("percentile() takes two arguments.")
+	}
+	if node.Args[0].Typ != NodeFunc {
+		return nil, fmt.Errorf("percentile() takes a function as its first argument.")
+	}
+	p, err := numArg(node, 1, "percentile")
+	if err != nil {
+		return nil, err
+	}
+	if p < 0 || p > 100 {
+		return nil, fmt.Errorf("percentile() must be between 0 and 100, got %g.", p)
+	}
+	rows, err := node.Args[0].Eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("percentile() argument failed to evaluate: %s", err)
+	}
+	if len(rows) == 0 {
+		return rows, nil
+	}
+	ret := foldRows(rows, func(values []float32) float32 {
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		rank := p / 100 * float64(len(values)-1)
+		lower := int(math.Floor(rank))
+		upper := int(math.Ceil(rank))
+		frac := float32(rank - float64(lower))
+		return values[lower] + frac*(values[upper]-values[lower])
+	})
+	return Rows{ctx.formula: ret}, nil
+}
+
+func (PercentileFunc) Describe() string {
+	return `percentile(a, p) folds the values of all the rows in a into a single trace of the p'th percentile at each point.
+
+  The percentile p is a number between 0 and 100.`
+}
+
+var percentileFunc = PercentileFunc{}
+
+type MovingAverageFunc struct{}
+
+// movingAverageFunc implements Func and replaces each point in each row with
+// the average of that point and the points before it in a window of the given
+// size.
+//
+// vec32.MISSING_DATA_SENTINEL values are not included in the average, and
+// stay vec32.MISSING_DATA_SENTINEL.
+func (MovingAverageFunc) Eval(ctx *Context, node *Node) (Rows, error) {
+	if len(node.Args) != 2 {
+		return nil, fmt.Errorf("moving_average() takes two arguments.")
+	}
+	if node.Args[0].Typ != NodeFunc {
+		return nil, fmt.Errorf("moving_average() takes a function as its first argument.")
+	}
+	w, err := numArg(node, 1, "moving_average")
+	if err != nil {
+		return nil, err
+	}
+	window := int(w)
+	if window < 1 {
+		return nil, fmt.Errorf("moving_average() window must be at least 1, got %g.", w)
+	}
+	rows, err := node.Args[0].Eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("moving_average() failed evaluating argument: %s", err)
+	}
+
+	ret := Rows{}
+	for key, r := range rows {
+		row := vec32.Dup(r)
+		for i, v := range r {
+			if v == vec32.MISSING_DATA_SENTINEL {
+				continue
+			}
+			sum := float32(0.0)
+			count := 0
+			for j := i; j >= 0 && j > i-window; j-- {
+				if r[j] != vec32.MISSING_DATA_SENTINEL {
+					sum += r[j]
+					count += 1
+				}
+			}
+			row[i] = sum / float32(count)
+		}
+		ret["moving_average("+key+")"] = row
+	}
+	return ret, nil
+}
+
+func (MovingAverageFunc) Describe() string {
+	return `moving_average(a, n) replaces each point with the average of that point and the n-1 points before it.`
+}
+
+var movingAverageFunc = MovingAverageFunc{}
+
+type DiffFunc struct{}
+
+// diffFunc implements Func and replaces each point in each row with the
+// difference between it and the previous point that has data.
+//
+// The first point with data in each row becomes vec32.MISSING_DATA_SENTINEL.
+func (DiffFunc) Eval(ctx *Context, node *Node) (Rows, error) {
+	if len(node.Args) != 1 {
+		return nil, fmt.Errorf("diff() takes a single argument.")
+	}
+	if node.Args[0].Typ != NodeFunc {
+		return nil, fmt.Errorf("diff() takes a function argument.")
+	}
+	rows, err := node.Args[0].Eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("diff() failed evaluating argument: %s", err)
+	}
+
+	ret := Rows{}
+	for key, r := range rows {
+		row := vec32.Dup(r)
+		prev := vec32.MISSING_DATA_SENTINEL
+		for i, v := range r {
+			if v == vec32.MISSING_DATA_SENTINEL {
+				continue
+			}
+			if prev == vec32.MISSING_DATA_SENTINEL {
+				row[i] = vec32.MISSING_DATA_SENTINEL
+			} else {
+				row[i] = v - prev
+			}
+			prev = v
+		}
+		ret["diff("+key+")"] = row
+	}
+	return ret, nil
+}
+
+func (DiffFunc) Describe() string {
+	return `diff() replaces each point with the difference between it and the previous point.`
+}
+
+var diffFunc = DiffFunc{}
+
+type AbsFunc struct{}
+
+// absFunc implements Func and replaces each point in each row with its
+// absolute value.
+//
+// vec32.MISSING_DATA_SENTINEL values are left untouched.
+func (AbsFunc) Eval(ctx *Context, node *Node) (Rows, error) {
+	if len(node.Args) != 1 {
+		return nil, fmt.Errorf("abs() takes a single argument.")
+	}
+	if node.Args[0].Typ != NodeFunc {
+		return nil, fmt.Errorf("abs() takes a function argument.")
+	}
+	rows, err := node.Args[0].Eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("abs() failed evaluating argument: %s", err)
+	}
+
+	ret := Rows{}
+	for key, r := range rows {
+		row := vec32.Dup(r)
+		for i, v := range row {
+			if v != vec32.MISSING_DATA_SENTINEL && v < 0 {
+				row[i] = -v
+			}
+		}
+		ret["abs("+key+")"] = row
+	}
+	return ret, nil
+}
+
+func (AbsFunc) Describe() string {
+	return `abs() replaces each point with its absolute value.`
+}
+
+var absFunc = AbsFunc{}
+
+type ClipFunc struct{}
+
+// clipFunc implements Func and limits each point in each row to lie between
+// the given minimum and maximum.
+//
+// vec32.MISSING_DATA_SENTINEL values are left untouched.
+func (ClipFunc) Eval(ctx *Context, node *Node) (Rows, error) {
+	if len(node.Args) != 3 {
+		return nil, fmt.Errorf("clip() takes three arguments.")
+	}
+	if node.Args[0].Typ != NodeFunc {
+		return nil, fmt.Errorf("clip() takes a function as its first argument.")
+	}
+	lo, err := numArg(node, 1, "clip")
+	if err != nil {
+		return nil, err
+	}
+	hi, err := numArg(node, 2, "clip")
+	if err != nil {
+		return nil, err
+	}
+	if lo > hi {
+		return nil, fmt.Errorf("clip() minimum %g is larger than the maximum %g.", lo, hi)
+	}
+	rows, err := node.Args[0].Eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("clip() failed evaluating argument: %s", err)
+	}
+
+	ret := Rows{}
+	for key, r := range rows {
+		row := vec32.Dup(r)
+		for i, v := range row {
+			if v == vec32.MISSING_DATA_SENTINEL {
+				continue
+			}
+			if v < float32(lo) {
+				row[i] = float32(lo)
+			} else if v > float32(hi) {
+				row[i] = float32(hi)
+			}
+		}
+		ret["clip("+key+")"] = row
+	}
+	return ret, nil
+}
+
+func (ClipFunc) Describe() string {
+	return `clip(a, min, max) limits each point to lie between min and max.`
+}
+
+var clipFunc = ClipFunc{}
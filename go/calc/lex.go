@@ -16,6 +16,9 @@ const (
 	itemLParen
 	itemRParen
 	itemComma
+	itemOperator
+	itemAssign
+	itemSemicolon
 	itemEOF
 )
 
@@ -35,6 +38,7 @@ type lexer struct {
 	input      string    // The string being parsed.
 	start      int       // The offset of the current lexical item.
 	pos        int       // Current position in input.
+	width      int       // Width of the last char read by next(), 0 at eof.
 	items      chan item // Channel by which items are delivered.
 	state      stateFn   // The next lexing function.
 	peekBuffer []item    // A peekBuffer for peek'd items.
@@ -54,6 +58,9 @@ func (l *lexer) nextItem() item {
 // peekItem allows the caller to look ahead and see the next item that
 // nextItem() will return.
 func (l *lexer) peekItem() item {
+	if len(l.peekBuffer) > 0 {
+		return l.peekBuffer[0]
+	}
 	item := <-l.items
 	l.peekBuffer = append(l.peekBuffer, item)
 	return item
@@ -104,16 +111,18 @@ func newLexer(input string) *lexer {
 // next returns the next char in the input.
 func (l *lexer) next() byte {
 	if int(l.pos) >= len(l.input) {
+		l.width = 0
 		return eof
 	}
 	ch := l.input[l.pos]
+	l.width = 1
 	l.pos += 1
 	return ch
 }
 
 // backUp steps back one rune. Can only be called once per call of next.
 func (l *lexer) backUp() {
-	l.pos -= 1
+	l.pos -= l.width
 }
 
 // run runs the state machine for the lexer.
@@ -150,10 +159,19 @@ func lexExp(l *lexer) stateFn {
 	case r == ',':
 		l.emit(itemComma)
 		return lexExp
+	case r == '+' || r == '-' || r == '*' || r == '/':
+		l.emit(itemOperator)
+		return lexExp
+	case r == '=':
+		l.emit(itemAssign)
+		return lexExp
+	case r == ';':
+		l.emit(itemSemicolon)
+		return lexExp
 	case unicode.IsSpace(rune(r)):
 		l.ignore()
 		return lexExp
-	case r == '+' || r == '-' || ('0' <= r && r <= '9'):
+	case ('0' <= r && r <= '9') || r == '.':
 		l.backUp()
 		return lexNumber
 	default:
@@ -181,9 +199,9 @@ func lexString(l *lexer) stateFn {
 }
 
 // lexNumber parses numbers, things that looks like ints and floats.
+//
+// A leading sign is lexed as an itemOperator, see parseUnary.
 func lexNumber(l *lexer) stateFn {
-	// Optional leading sign.
-	l.accept("+-")
 	// Is it hex?
 	digits := "0123456789"
 	if l.accept("0") && l.accept("xX") {
@@ -205,7 +223,7 @@ func lexNumber(l *lexer) stateFn {
 func lexIdentifier(l *lexer) stateFn {
 	for {
 		r := l.next()
-		if !unicode.IsLetter(rune(r)) && !unicode.IsDigit(rune(r)) && r != '_' {
+		if r == eof || (!unicode.IsLetter(rune(r)) && !unicode.IsDigit(rune(r)) && r != '_') {
 			l.backUp()
 			break
 		}
@@ -62,6 +62,26 @@ func TestLex(t *testing.T) {
 				{itemEOF, ""},
 			},
 		},
+		{
+			input: "let x = (a - 1.5) * -2 / b;",
+			items: []item{
+				{itemIdentifier, "let"},
+				{itemIdentifier, "x"},
+				{itemAssign, "="},
+				{itemLParen, "("},
+				{itemIdentifier, "a"},
+				{itemOperator, "-"},
+				{itemNum, "1.5"},
+				{itemRParen, ")"},
+				{itemOperator, "*"},
+				{itemOperator, "-"},
+				{itemNum, "2"},
+				{itemOperator, "/"},
+				{itemIdentifier, "b"},
+				{itemSemicolon, ";"},
+				{itemEOF, ""},
+			},
+		},
 	}
 	for _, tc := range testCases {
 		l := newLexer(tc.input)
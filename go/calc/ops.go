@@ -0,0 +1,197 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"go.skia.org/infra/go/vec32"
+)
+
+// applyOp applies the arithmetic operator 'op' to a and b.
+func applyOp(op string, a, b float64) (float64, error) {
+	switch op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return 0, fmt.Errorf("Division by zero.")
+		}
+		return a / b, nil
+	default:
+		return 0, fmt.Errorf("Unknown operator: %q", op)
+	}
+}
+
+// operand is one side of an arithmetic operator, either a number or Rows.
+type operand struct {
+	num    float32
+	rows   Rows
+	isNum  bool
+	numStr string
+}
+
+// evalOperand evaluates one side of an arithmetic operator.
+func evalOperand(ctx *Context, op string, node *Node) (*operand, error) {
+	switch node.Typ {
+	case NodeNum:
+		v, err := strconv.ParseFloat(node.Val, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: not a valid number %s: %s", op, node.Val, err)
+		}
+		return &operand{num: float32(v), isNum: true, numStr: node.Val}, nil
+	case NodeFunc:
+		rows, err := node.Eval(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed evaluating argument: %s", op, err)
+		}
+		return &operand{rows: rows}, nil
+	default:
+		return nil, fmt.Errorf("%s: takes numbers or functions as arguments.", op)
+	}
+}
+
+// applyRow applies the operator point by point to two rows, either of which
+// can be nil, in which case 'num' is used in its place.
+//
+// Points that are vec32.MISSING_DATA_SENTINEL in either row, or that aren't
+// finite after the operation, such as a division by zero, are
+// vec32.MISSING_DATA_SENTINEL in the result.
+func applyRow(op string, a []float32, aNum float32, b []float32, bNum float32) []float32 {
+	n := len(a)
+	if a == nil {
+		n = len(b)
+	}
+	ret := make([]float32, n)
+	for i := range ret {
+		x, y := aNum, bNum
+		if a != nil {
+			x = a[i]
+		}
+		if b != nil {
+			y = b[i]
+		}
+		if x == vec32.MISSING_DATA_SENTINEL || y == vec32.MISSING_DATA_SENTINEL {
+			ret[i] = vec32.MISSING_DATA_SENTINEL
+			continue
+		}
+		v, err := applyOp(op, float64(x), float64(y))
+		if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+			ret[i] = vec32.MISSING_DATA_SENTINEL
+			continue
+		}
+		ret[i] = float32(v)
+	}
+	return ret
+}
+
+// single returns the key and row if there is exactly one row.
+func single(rows Rows) (string, []float32, bool) {
+	if len(rows) != 1 {
+		return "", nil, false
+	}
+	for k, v := range rows {
+		return k, v, true
+	}
+	return "", nil, false
+}
+
+// BinaryFunc implements Func for the arithmetic operators +, -, * and /.
+//
+// Each side can be a number or Rows. If one side is a single row, or a
+// number, then it is applied to every row on the other side. Otherwise both
+// sides must have the same keys and the rows are paired up by key.
+//
+// The result of an operation on the rows 'a' and 'b' has a key of "(a op b)",
+// unless the result is a single row, in which case, like ave(), the key is
+// the formula.
+type BinaryFunc struct {
+	op string
+}
+
+func (b BinaryFunc) Eval(ctx *Context, node *Node) (Rows, error) {
+	if len(node.Args) != 2 {
+		return nil, fmt.Errorf("%s takes two arguments.", b.op)
+	}
+	lhs, err := evalOperand(ctx, b.op, node.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := evalOperand(ctx, b.op, node.Args[1])
+	if err != nil {
+		return nil, err
+	}
+	if lhs.isNum && rhs.isNum {
+		return nil, fmt.Errorf("%s: at least one side must be a function.", b.op)
+	}
+
+	ret, err := b.apply(lhs, rhs)
+	if err != nil {
+		return nil, err
+	}
+	if _, row, ok := single(ret); ok {
+		return Rows{ctx.formula: row}, nil
+	}
+	return ret, nil
+}
+
+// apply the operator to the evaluated operands.
+func (b BinaryFunc) apply(lhs, rhs *operand) (Rows, error) {
+	ret := Rows{}
+	key := func(lkey, rkey string) string {
+		return fmt.Sprintf("(%s %s %s)", lkey, b.op, rkey)
+	}
+	if lhs.isNum {
+		for k, r := range rhs.rows {
+			ret[key(lhs.numStr, k)] = applyRow(b.op, nil, lhs.num, r, 0)
+		}
+		return ret, nil
+	}
+	if rhs.isNum {
+		for k, l := range lhs.rows {
+			ret[key(k, rhs.numStr)] = applyRow(b.op, l, 0, nil, rhs.num)
+		}
+		return ret, nil
+	}
+	if rk, r, ok := single(rhs.rows); ok {
+		for k, l := range lhs.rows {
+			ret[key(k, rk)] = applyRow(b.op, l, 0, r, 0)
+		}
+		return ret, nil
+	}
+	if lk, l, ok := single(lhs.rows); ok {
+		for k, r := range rhs.rows {
+			ret[key(lk, k)] = applyRow(b.op, l, 0, r, 0)
+		}
+		return ret, nil
+	}
+	if len(lhs.rows) != len(rhs.rows) {
+		return nil, fmt.Errorf("%s: one side must be a single row, or both sides must have the same rows.", b.op)
+	}
+	for k, l := range lhs.rows {
+		r, ok := rhs.rows[k]
+		if !ok {
+			return nil, fmt.Errorf("%s: one side must be a single row, or both sides must have the same rows.", b.op)
+		}
+		ret[key(k, k)] = applyRow(b.op, l, 0, r, 0)
+	}
+	return ret, nil
+}
+
+func (b BinaryFunc) Describe() string {
+	return fmt.Sprintf(`a %s b applies the operator point by point to the rows of a and b.
+
+  Either side can be a number. If one side is a single row then it is applied to
+  every row on the other side, otherwise both sides must contain the same rows.`, b.op)
+}
+
+var (
+	addFunc = BinaryFunc{op: "+"}
+	subFunc = BinaryFunc{op: "-"}
+	mulFunc = BinaryFunc{op: "*"}
+	divFunc = BinaryFunc{op: "/"}
+)
@@ -2,6 +2,8 @@ package calc
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"go.skia.org/infra/go/vec32"
 )
@@ -42,6 +44,8 @@ type Node struct {
 	Typ  NodeType
 	Val  string
 	Args []*Node
+
+	shared bool // True if the node is bound to a variable via 'let', and so may be evaluated more than once.
 }
 
 // newNode creates a new Node of the given type and value.
@@ -58,11 +62,34 @@ func (n *Node) Eval(ctx *Context) (Rows, error) {
 	if n.Typ != NodeFunc {
 		return nil, fmt.Errorf("Tried to call eval on a non-Func node: %s", n.Val)
 	}
-	if f, ok := ctx.Funcs[n.Val]; ok {
-		return f.Eval(ctx, n)
-	} else {
+	if n.shared {
+		if rows, ok := ctx.cache[n]; ok {
+			return copyRows(rows), nil
+		}
+	}
+	f, ok := ctx.Funcs[n.Val]
+	if !ok {
 		return nil, fmt.Errorf("Unknown function name: %s", n.Val)
 	}
+	rows, err := f.Eval(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	if n.shared {
+		ctx.cache[n] = copyRows(rows)
+	}
+	return rows, nil
+}
+
+// copyRows returns a shallow copy of the rows, so that Funcs that modify
+// the Rows they get from evaluating their arguments, such as log(), don't
+// modify the cached value of a let binding.
+func copyRows(rows Rows) Rows {
+	ret := make(Rows, len(rows))
+	for k, v := range rows {
+		ret[k] = v
+	}
+	return ret
 }
 
 // Func defines a type for functions that can be used in the parser.
@@ -81,7 +108,8 @@ type Context struct {
 	RowsFromQuery    RowsFromQuery
 	RowsFromShortcut RowsFromShortcut
 	Funcs            map[string]Func
-	formula          string // The current formula being evaluated.
+	formula          string         // The current formula being evaluated.
+	cache            map[*Node]Rows // The values of the let bindings in the current formula.
 }
 
 // NewContext create a new parsing context that includes the basic functions.
@@ -90,22 +118,33 @@ func NewContext(rowsFromQuery RowsFromQuery, rowsFromShortcut RowsFromShortcut)
 		RowsFromQuery:    rowsFromQuery,
 		RowsFromShortcut: rowsFromShortcut,
 		Funcs: map[string]Func{
-			"filter":       filterFunc,
-			"shortcut":     shortcutFunc,
-			"norm":         normFunc,
-			"fill":         fillFunc,
-			"ave":          aveFunc,
-			"avg":          aveFunc,
-			"count":        countFunc,
-			"ratio":        ratioFunc,
-			"sum":          sumFunc,
-			"geo":          geoFunc,
-			"log":          logFunc,
-			"trace_ave":    traceAveFunc,
-			"trace_avg":    traceAveFunc,
-			"trace_stddev": traceStdDevFunc,
-			"trace_cov":    traceCovFunc,
-			"step":         traceStepFunc,
+			"filter":         filterFunc,
+			"shortcut":       shortcutFunc,
+			"norm":           normFunc,
+			"fill":           fillFunc,
+			"ave":            aveFunc,
+			"avg":            aveFunc,
+			"count":          countFunc,
+			"ratio":          ratioFunc,
+			"sum":            sumFunc,
+			"geo":            geoFunc,
+			"log":            logFunc,
+			"trace_ave":      traceAveFunc,
+			"trace_avg":      traceAveFunc,
+			"trace_stddev":   traceStdDevFunc,
+			"trace_cov":      traceCovFunc,
+			"step":           traceStepFunc,
+			"min":            minFunc,
+			"max":            maxFunc,
+			"percentile":     percentileFunc,
+			"moving_average": movingAverageFunc,
+			"diff":           diffFunc,
+			"abs":            absFunc,
+			"clip":           clipFunc,
+			"+":              addFunc,
+			"-":              subFunc,
+			"*":              mulFunc,
+			"/":              divFunc,
 		},
 	}
 }
@@ -114,6 +153,7 @@ func NewContext(rowsFromQuery RowsFromQuery, rowsFromShortcut RowsFromShortcut)
 // an error.
 func (ctx *Context) Eval(exp string) (Rows, error) {
 	ctx.formula = exp
+	ctx.cache = map[*Node]Rows{}
 	n, err := parse(exp)
 	if err != nil {
 		return nil, fmt.Errorf("Eval: failed to parse the expression: %s", err)
@@ -121,72 +161,235 @@ func (ctx *Context) Eval(exp string) (Rows, error) {
 	return n.Eval(ctx)
 }
 
+// parser holds the state of parsing a single formula.
+type parser struct {
+	l    *lexer
+	vars map[string]*Node // The let bindings seen so far.
+}
+
 // parse starts the parsing.
+//
+// A formula is zero or more let bindings followed by an expression:
+//
+//    let x = filter("config=8888");
+//    let y = filter("config=565");
+//    (x - y) / y * 100
+//
 func parse(input string) (*Node, error) {
-	l := newLexer(input)
-	return parseExp(l)
+	p := &parser{
+		l:    newLexer(input),
+		vars: map[string]*Node{},
+	}
+	for {
+		it := p.l.peekItem()
+		if it.typ != itemIdentifier || it.val != "let" {
+			break
+		}
+		if err := p.parseLet(); err != nil {
+			return nil, err
+		}
+	}
+	n, err := p.parseExp()
+	if err != nil {
+		return nil, err
+	}
+	if it := p.l.nextItem(); it.typ != itemEOF {
+		return nil, fmt.Errorf("Expression: unexpected %q after the end of the expression.", it.val)
+	}
+	return n, nil
 }
 
-// parseExp parses an expression.
+// parseLet parses a variable binding.
 //
 // Something of the form:
 //
-//    fn(arg1, args2)
+//    let x = expression;
 //
-func parseExp(l *lexer) (*Node, error) {
-	it := l.nextItem()
+func (p *parser) parseLet() error {
+	p.l.nextItem()
+	it := p.l.nextItem()
 	if it.typ != itemIdentifier {
-		return nil, fmt.Errorf("Expression: must begin with an identifier")
+		return fmt.Errorf("Let: must be followed by a variable name.")
+	}
+	name := it.val
+	if _, ok := p.vars[name]; ok {
+		return fmt.Errorf("Let: variable %q is already defined.", name)
 	}
-	n := newNode(it.val, NodeFunc)
-	it = l.nextItem()
-	if it.typ != itemLParen {
-		return nil, fmt.Errorf("Expression: didn't find '(' after an identifier.")
+	if it = p.l.nextItem(); it.typ != itemAssign {
+		return fmt.Errorf("Let: didn't find '=' after %q.", name)
 	}
-	if err := parseArgs(l, n); err != nil {
-		return nil, fmt.Errorf("Expression: failed parsing arguments: %s", err)
+	n, err := p.parseExp()
+	if err != nil {
+		return fmt.Errorf("Let: failed parsing the value of %q: %s", name, err)
 	}
-	it = l.nextItem()
-	if it.typ != itemRParen {
-		return nil, fmt.Errorf("Expression: didn't find ')' after arguments.")
+	if it = p.l.nextItem(); it.typ != itemSemicolon {
+		return fmt.Errorf("Let: didn't find ';' after the value of %q.", name)
 	}
-	return n, nil
+	n.shared = true
+	p.vars[name] = n
+	return nil
+}
+
+// parseExp parses an expression, which is a sum of products.
+//
+// Something of the form:
+//
+//    term1 + term2 - term3
+//
+func (p *parser) parseExp() (*Node, error) {
+	return p.parseBinary("+-", p.parseTerm)
 }
 
-// parseArgs parses the arguments to a function.
+// parseTerm parses a product of unary expressions.
 //
 // Something of the form:
 //
-//    arg1, arg2, arg3
+//    factor1 * factor2 / factor3
 //
-// It terminates when it sees a closing paren, or an invalid token.
-func parseArgs(l *lexer, p *Node) error {
-Loop:
+func (p *parser) parseTerm() (*Node, error) {
+	return p.parseBinary("*/", p.parseUnary)
+}
+
+// parseBinary parses a left associative chain of the operators in 'ops',
+// where each operand is parsed by 'operand'.
+func (p *parser) parseBinary(ops string, operand func() (*Node, error)) (*Node, error) {
+	lhs, err := operand()
+	if err != nil {
+		return nil, err
+	}
 	for {
-		it := l.peekItem()
-		switch it.typ {
-		case itemIdentifier:
-			next, err := parseExp(l)
-			if err != nil {
-				return fmt.Errorf("Failed parsing args: %s", err)
+		it := p.l.peekItem()
+		if it.typ != itemOperator || !strings.Contains(ops, it.val) {
+			return lhs, nil
+		}
+		p.l.nextItem()
+		rhs, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		lhs, err = newBinaryNode(it.val, lhs, rhs)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// newBinaryNode returns a Node that applies the operator to lhs and rhs.
+//
+// If both sides are numbers then the result is computed here and a NodeNum
+// is returned.
+func newBinaryNode(op string, lhs, rhs *Node) (*Node, error) {
+	if lhs.Typ == NodeNum && rhs.Typ == NodeNum {
+		a, err := strconv.ParseFloat(lhs.Val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number %q: %s", lhs.Val, err)
+		}
+		b, err := strconv.ParseFloat(rhs.Val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number %q: %s", rhs.Val, err)
+		}
+		v, err := applyOp(op, a, b)
+		if err != nil {
+			return nil, err
+		}
+		return newNode(strconv.FormatFloat(v, 'g', -1, 64), NodeNum), nil
+	}
+	n := newNode(op, NodeFunc)
+	n.Args = []*Node{lhs, rhs}
+	return n, nil
+}
+
+// parseUnary parses an optionally negated primary expression.
+//
+// Something of the form:
+//
+//    -factor
+//
+func (p *parser) parseUnary() (*Node, error) {
+	it := p.l.peekItem()
+	if it.typ != itemOperator || (it.val != "-" && it.val != "+") {
+		return p.parsePrimary()
+	}
+	p.l.nextItem()
+	n, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if it.val == "+" {
+		return n, nil
+	}
+	if n.Typ == NodeNum {
+		if strings.HasPrefix(n.Val, "-") {
+			return newNode(n.Val[1:], NodeNum), nil
+		}
+		return newNode("-"+n.Val, NodeNum), nil
+	}
+	return newBinaryNode("*", newNode("-1", NodeNum), n)
+}
+
+// parsePrimary parses a number, a string, a variable, a function call, or a
+// parenthesized expression.
+func (p *parser) parsePrimary() (*Node, error) {
+	it := p.l.nextItem()
+	switch it.typ {
+	case itemNum:
+		return newNode(it.val, NodeNum), nil
+	case itemString:
+		return newNode(it.val, NodeString), nil
+	case itemLParen:
+		n, err := p.parseExp()
+		if err != nil {
+			return nil, err
+		}
+		if it = p.l.nextItem(); it.typ != itemRParen {
+			return nil, fmt.Errorf("Expression: didn't find closing ')'.")
+		}
+		return n, nil
+	case itemIdentifier:
+		if p.l.peekItem().typ != itemLParen {
+			n, ok := p.vars[it.val]
+			if !ok {
+				return nil, fmt.Errorf("Expression: unknown variable %q.", it.val)
 			}
-			p.Args = append(p.Args, next)
-		case itemString:
-			l.nextItem()
-			node := newNode(it.val, NodeString)
-			p.Args = append(p.Args, node)
-		case itemNum:
-			l.nextItem()
-			node := newNode(it.val, NodeNum)
-			p.Args = append(p.Args, node)
+			return n, nil
+		}
+		p.l.nextItem()
+		n := newNode(it.val, NodeFunc)
+		if err := p.parseArgs(n); err != nil {
+			return nil, fmt.Errorf("Expression: failed parsing arguments: %s", err)
+		}
+		return n, nil
+	case itemError:
+		return nil, fmt.Errorf("Expression: %s", it.val)
+	default:
+		return nil, fmt.Errorf("Expression: unexpected %q.", it.val)
+	}
+}
+
+// parseArgs parses the arguments to a function, and the closing paren.
+//
+// Something of the form:
+//
+//    arg1, arg2, arg3)
+//
+func (p *parser) parseArgs(n *Node) error {
+	if p.l.peekItem().typ == itemRParen {
+		p.l.nextItem()
+		return nil
+	}
+	for {
+		arg, err := p.parseExp()
+		if err != nil {
+			return fmt.Errorf("Failed parsing args: %s", err)
+		}
+		n.Args = append(n.Args, arg)
+		switch it := p.l.nextItem(); it.typ {
 		case itemComma:
-			l.nextItem()
 			continue
 		case itemRParen:
-			break Loop
+			return nil
 		default:
-			return fmt.Errorf("Invalid token in args: %d", it.typ)
+			return fmt.Errorf("Invalid token in args: %q", it.val)
 		}
 	}
-	return nil
 }
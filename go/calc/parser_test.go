@@ -141,6 +141,20 @@ func TestEvalErrors(t *testing.T) {
 		`ave()`,
 		`avg()`,
 		`fill()`,
+		// Arithmetic and let bindings.
+		`1 + 2`,
+		`filter("") +`,
+		`1 / (1 - 1) * filter("")`,
+		`filter("") + "foo"`,
+		`(filter("")`,
+		`filter("") filter("")`,
+		`x`,
+		`let x = filter("") filter("")`,
+		`let x = filter(""); let x = filter(""); x`,
+		`let = filter(""); x`,
+		`percentile(filter(""), 101)`,
+		`moving_average(filter(""), 0)`,
+		`clip(filter(""), 2, 1)`,
 	}
 	for _, tc := range testCases {
 		_, err := ctx.Eval(tc)
@@ -343,3 +357,116 @@ func TestLog(t *testing.T) {
 		}
 	}
 }
+
+func TestArithmetic(t *testing.T) {
+	testutils.SmallTest(t)
+	ctx := newTestContext(Rows{
+		",name=t1,": []float32{10, 4, e, 50, 9999},
+		",name=t2,": []float32{5, 2, 4, 5, 0},
+	}, nil)
+
+	testCases := []struct {
+		formula string
+		want    []float32
+	}{
+		{`filter("name=t1") + filter("name=t2")`, []float32{15, 6, e, 55, 9999}},
+		{`filter("name=t1") - 2 * 3`, []float32{4, -2, e, 44, 9993}},
+		{`(filter("name=t1") - filter("name=t2")) / filter("name=t2") * 100`, []float32{100, 100, e, 900, e}},
+		{`-filter("name=t2")`, []float32{-5, -2, -4, -5, 0}},
+		{`10 / filter("name=t2")`, []float32{2, 5, 2.5, 2, e}},
+		{`let x = filter("name=t2"); let k = -(1 + 1); x * k + x`, []float32{-5, -2, -4, -5, 0}},
+	}
+	for _, tc := range testCases {
+		rows, err := ctx.Eval(tc.formula)
+		assert.NoError(t, err, tc.formula)
+		assert.Len(t, rows, 1, tc.formula)
+		assert.Equal(t, tc.want, rows[tc.formula], tc.formula)
+	}
+
+	// A single row is applied to each of many.
+	rows, err := ctx.Eval(`filter("") - filter("name=t2")`)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, []float32{0, 0, 0, 0, 0}, rows["(,name=t2, - ,name=t2,)"])
+	assert.Equal(t, []float32{5, 2, e, 45, 9999}, rows["(,name=t1, - ,name=t2,)"])
+
+	// As is a number.
+	rows, err = ctx.Eval(`filter("") * 2`)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, []float32{10, 4, 8, 10, 0}, rows["(,name=t2, * 2)"])
+
+	// Many rows are paired by key.
+	rows, err = ctx.Eval(`let x = filter(""); x + x`)
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, []float32{10, 4, 8, 10, 0}, rows["(,name=t2, + ,name=t2,)"])
+	_, err = ctx.Eval(`filter("") * fill(filter(""))`)
+	assert.Error(t, err)
+}
+
+func TestLetEvaluatesOnce(t *testing.T) {
+	testutils.SmallTest(t)
+	calls := 0
+	ctx := NewContext(func(s string) (Rows, error) {
+		calls += 1
+		return Rows{",name=t1,": []float32{1, -2, e}}, nil
+	}, nil)
+
+	formula := `let x = filter(""); log(x) + abs(x) + x`
+	rows, err := ctx.Eval(formula)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, []float32{2, e, e}, rows[formula])
+}
+
+func TestMinMaxPercentile(t *testing.T) {
+	testutils.SmallTest(t)
+	ctx := newTestContext(Rows{
+		",name=t1,": []float32{1, 5, e, e},
+		",name=t2,": []float32{2, 4, 3, e},
+		",name=t3,": []float32{3, 6, e, e},
+	}, nil)
+
+	testCases := []struct {
+		formula string
+		want    []float32
+	}{
+		{`min(filter(""))`, []float32{1, 4, 3, e}},
+		{`max(filter(""))`, []float32{3, 6, 3, e}},
+		{`percentile(filter(""), 50)`, []float32{2, 5, 3, e}},
+		{`percentile(filter(""), 25)`, []float32{1.5, 4.5, 3, e}},
+		{`percentile(filter(""), 100)`, []float32{3, 6, 3, e}},
+	}
+	for _, tc := range testCases {
+		rows, err := ctx.Eval(tc.formula)
+		assert.NoError(t, err, tc.formula)
+		assert.Equal(t, tc.want, rows[tc.formula], tc.formula)
+	}
+}
+
+func TestTraceTransforms(t *testing.T) {
+	testutils.SmallTest(t)
+	ctx := newTestContext(Rows{
+		",name=t1,": []float32{1, -3, e, 5, -7},
+	}, nil)
+
+	testCases := []struct {
+		formula string
+		key     string
+		want    []float32
+	}{
+		{`moving_average(filter(""), 2)`, "moving_average(,name=t1,)", []float32{1, -1, e, 5, -1}},
+		{`moving_average(filter(""), 1)`, "moving_average(,name=t1,)", []float32{1, -3, e, 5, -7}},
+		{`diff(filter(""))`, "diff(,name=t1,)", []float32{e, -4, e, 8, -12}},
+		{`abs(filter(""))`, "abs(,name=t1,)", []float32{1, 3, e, 5, 7}},
+		{`clip(filter(""), -4, 2)`, "clip(,name=t1,)", []float32{1, -3, e, 2, -4}},
+	}
+	for _, tc := range testCases {
+		rows, err := ctx.Eval(tc.formula)
+		assert.NoError(t, err, tc.formula)
+		assert.Len(t, rows, 1, tc.formula)
+		assert.Equal(t, tc.want, rows[tc.key], tc.formula)
+	}
+}
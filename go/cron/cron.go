@@ -0,0 +1,219 @@
+// Package cron parses cron expressions and computes the times at which they
+// fire.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxSearchYears is how far into the future Next looks for a matching
+	// time before giving up, eg. for "0 0 30 2 *".
+	maxSearchYears = 5
+)
+
+var (
+	// macros are the supported shorthands for common expressions.
+	macros = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+
+	monthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+
+	dayNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+// field describes one of the five fields of a cron expression.
+type field struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: monthNames},
+	// 7 is accepted as an alias for Sunday.
+	{name: "day of week", min: 0, max: 7, names: dayNames},
+}
+
+// Schedule is a parsed cron expression. Times are matched in the location of
+// the time.Time passed to Match or Next.
+type Schedule struct {
+	expr string
+
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	// Per cron convention, if both the day of month and day of week are
+	// restricted, a day matches if either of them matches.
+	domRestricted bool
+	dowRestricted bool
+}
+
+// Parse parses a standard five-field cron expression ("minute hour
+// day-of-month month day-of-week") or one of the macros "@yearly",
+// "@annually", "@monthly", "@weekly", "@daily", "@midnight" and "@hourly".
+// Each field may be "*", a value, a range "a-b" or a comma-separated list of
+// those, optionally followed by a step "/n". Months and days of the week may
+// also be given by their three-letter English names.
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if m, ok := macros[strings.ToLower(spec)]; ok {
+		spec = m
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("Cron expression %q must have %d fields; found %d", expr, len(fields), len(parts))
+	}
+	bits := make([]uint64, len(fields))
+	for i, f := range fields {
+		b, err := parseField(parts[i], f)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s in cron expression %q: %s", f.name, expr, err)
+		}
+		bits[i] = b
+	}
+	// Treat 7 as Sunday.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = (bits[4] | 1) &^ (1 << 7)
+	}
+	return &Schedule{
+		expr:          expr,
+		minute:        bits[0],
+		hour:          bits[1],
+		dom:           bits[2],
+		month:         bits[3],
+		dow:           bits[4],
+		domRestricted: !strings.HasPrefix(parts[2], "*"),
+		dowRestricted: !strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// parseValue parses a single value of the given field.
+func parseValue(s string, f field) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid value", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%d is outside of the range %d-%d", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// parseField returns a bitset of the values matched by the given field.
+func parseField(s string, f field) (uint64, error) {
+	var rv uint64
+	for _, part := range strings.Split(s, ",") {
+		rangeStr := part
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("%q is not a valid step", part[idx+1:])
+			}
+			rangeStr = part[:idx]
+		}
+		start, end := f.min, f.max
+		if rangeStr != "*" {
+			bounds := strings.SplitN(rangeStr, "-", 2)
+			var err error
+			start, err = parseValue(bounds[0], f)
+			if err != nil {
+				return 0, err
+			}
+			if len(bounds) == 2 {
+				end, err = parseValue(bounds[1], f)
+				if err != nil {
+					return 0, err
+				}
+				if end < start {
+					return 0, fmt.Errorf("Range %q ends before it starts", rangeStr)
+				}
+			} else if step == 1 {
+				end = start
+			}
+		}
+		for v := start; v <= end; v += step {
+			rv |= 1 << uint(v)
+		}
+	}
+	return rv, nil
+}
+
+// String returns the expression from which the Schedule was parsed.
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// matchDay returns true iff the Schedule matches the day of the given time.
+func (s *Schedule) matchDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Match returns true iff the Schedule fires during the minute of the given
+// time.
+func (s *Schedule) Match(t time.Time) bool {
+	return s.month&(1<<uint(t.Month())) != 0 &&
+		s.matchDay(t) &&
+		s.hour&(1<<uint(t.Hour())) != 0 &&
+		s.minute&(1<<uint(t.Minute())) != 0
+}
+
+// Next returns the first time strictly after the given time at which the
+// Schedule fires. Returns the zero time if the Schedule does not fire within
+// the next few years, eg. because it specifies a nonexistent date.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
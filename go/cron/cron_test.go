@@ -0,0 +1,94 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+)
+
+func TestParseErrors(t *testing.T) {
+	testutils.SmallTest(t)
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@sometimes",
+	} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	testutils.SmallTest(t)
+	// Monday, 2018-06-04 03:30 UTC.
+	ts := time.Date(2018, time.June, 4, 3, 30, 0, 0, time.UTC)
+	test := func(expr string, expect bool) {
+		s, err := Parse(expr)
+		assert.NoError(t, err)
+		assert.Equal(t, expect, s.Match(ts), expr)
+	}
+	test("* * * * *", true)
+	test("30 3 * * *", true)
+	test("31 3 * * *", false)
+	test("*/15 * * * *", true)
+	test("*/20 * * * *", false)
+	test("0-30/10 1-5 * * *", true)
+	test("0,15,45 * * * *", false)
+	test("30 3 * * mon", true)
+	test("30 3 * * MON-FRI", true)
+	test("30 3 * * 0,7", false)
+	test("30 3 * jun *", true)
+	test("30 3 4 * *", true)
+	test("30 3 5 * *", false)
+	// Both day fields are restricted, so either of them may match.
+	test("30 3 5 * mon", true)
+	test("30 3 4 * tue", true)
+	test("30 3 5 * tue", false)
+	// Only one day field is restricted.
+	test("30 3 */2 * *", false)
+	test("30 3 * * */2", false)
+	test("@daily", false)
+	test("@hourly", false)
+}
+
+func TestNext(t *testing.T) {
+	testutils.SmallTest(t)
+	ts := time.Date(2018, time.June, 4, 3, 30, 15, 0, time.UTC)
+	test := func(expr string, expect time.Time) {
+		s, err := Parse(expr)
+		assert.NoError(t, err)
+		assert.Equal(t, expect, s.Next(ts), expr)
+	}
+	test("* * * * *", time.Date(2018, time.June, 4, 3, 31, 0, 0, time.UTC))
+	test("30 3 * * *", time.Date(2018, time.June, 5, 3, 30, 0, 0, time.UTC))
+	test("0 */6 * * *", time.Date(2018, time.June, 4, 6, 0, 0, 0, time.UTC))
+	test("@daily", time.Date(2018, time.June, 5, 0, 0, 0, 0, time.UTC))
+	test("@weekly", time.Date(2018, time.June, 10, 0, 0, 0, 0, time.UTC))
+	test("@monthly", time.Date(2018, time.July, 1, 0, 0, 0, 0, time.UTC))
+	test("@yearly", time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC))
+	test("15 10 * * 7", time.Date(2018, time.June, 10, 10, 15, 0, 0, time.UTC))
+	test("0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC))
+	// February 30th never happens.
+	test("0 0 30 2 *", time.Time{})
+
+	// Next is strictly after the given time.
+	s, err := Parse("30 3 * * *")
+	assert.NoError(t, err)
+	next := s.Next(time.Date(2018, time.June, 4, 3, 30, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2018, time.June, 5, 3, 30, 0, 0, time.UTC), next)
+	assert.True(t, s.Match(next))
+	assert.Equal(t, "30 3 * * *", s.String())
+}
@@ -34,10 +34,12 @@ const (
 	FLAKY_RANGES Kind = "FlakyRanges"
 
 	// Perf
-	SHORTCUT   Kind = "Shortcut"
-	ACTIVITY   Kind = "Activity"
-	REGRESSION Kind = "Regression"
-	ALERT      Kind = "Alert"
+	SHORTCUT           Kind = "Shortcut"
+	ACTIVITY           Kind = "Activity"
+	REGRESSION         Kind = "Regression"
+	REGRESSION_HISTORY Kind = "RegressionHistory"
+	ALERT              Kind = "Alert"
+	ALERT_REVISION     Kind = "AlertRevision"
 
 	// Gold
 	ISSUE                  Kind = "Issue"
@@ -47,7 +49,9 @@ const (
 	TEST_DIGEST_EXP        Kind = "TryjobTestDigestExp" // TODO(stephana): Remove after migration to consolidated expectations store
 	TRYJOB_TEST_DIGEST_EXP Kind = "TryjobTestDigestExp"
 	MASTER_EXP_CHANGE      Kind = "MasterExpChange"
+	BRANCH_EXP_CHANGE      Kind = "BranchExpChange"
 	IGNORE_RULE            Kind = "IgnoreRule"
+	AUTO_TRIAGE_RULE       Kind = "AutoTriageRule"
 	HELPER_RECENT_KEYS     Kind = "HelperRecentKeys"
 	EXPECTATIONS_BLOB      Kind = "ExpectationsBlob"
 	EXPECTATIONS_BLOB_ROOT Kind = "ExpectationsBlobRoot"
@@ -122,7 +126,7 @@ const (
 
 var (
 	// goldKinds are the DS kinds used by Gold.
-	goldKinds = []Kind{ISSUE, TRYJOB, TRYJOB_RESULT, TRYJOB_EXP_CHANGE, TRYJOB_TEST_DIGEST_EXP, MASTER_EXP_CHANGE, IGNORE_RULE, HELPER_RECENT_KEYS, EXPECTATIONS_BLOB, EXPECTATIONS_BLOB_ROOT}
+	goldKinds = []Kind{ISSUE, TRYJOB, TRYJOB_RESULT, TRYJOB_EXP_CHANGE, TRYJOB_TEST_DIGEST_EXP, MASTER_EXP_CHANGE, BRANCH_EXP_CHANGE, IGNORE_RULE, AUTO_TRIAGE_RULE, HELPER_RECENT_KEYS, EXPECTATIONS_BLOB, EXPECTATIONS_BLOB_ROOT}
 
 	// KindsToBackup is a map from namespace to the list of Kinds to backup.
 	// If this value is changed then remember to push a new version of /ds/go/datastore_backup.
@@ -133,10 +137,10 @@ var (
 	KindsToBackup = map[string][]Kind{
 		AUTOROLL_NS:            []Kind{KIND_AUTOROLL_MODE, KIND_AUTOROLL_MODE_ANCESTOR, KIND_AUTOROLL_ROLL, KIND_AUTOROLL_ROLL_ANCESTOR, KIND_AUTOROLL_STATUS, KIND_AUTOROLL_STATUS_ANCESTOR, KIND_AUTOROLL_STRATEGY, KIND_AUTOROLL_STRATEGY_ANCESTOR, KIND_AUTOROLL_UNTHROTTLE, KIND_AUTOROLL_UNTHROTTLE_ANCESTOR},
 		AUTOROLL_INTERNAL_NS:   []Kind{KIND_AUTOROLL_MODE, KIND_AUTOROLL_MODE_ANCESTOR, KIND_AUTOROLL_ROLL, KIND_AUTOROLL_ROLL_ANCESTOR, KIND_AUTOROLL_STATUS, KIND_AUTOROLL_STATUS_ANCESTOR, KIND_AUTOROLL_STRATEGY, KIND_AUTOROLL_STRATEGY_ANCESTOR, KIND_AUTOROLL_UNTHROTTLE, KIND_AUTOROLL_UNTHROTTLE_ANCESTOR},
-		PERF_NS:                []Kind{ACTIVITY, ALERT, REGRESSION, SHORTCUT},
-		PERF_ANDROID_NS:        []Kind{ACTIVITY, ALERT, REGRESSION, SHORTCUT},
-		PERF_ANDROID_MASTER_NS: []Kind{ACTIVITY, ALERT, REGRESSION, SHORTCUT},
-		PERF_CT_NS:             []Kind{ACTIVITY, ALERT, REGRESSION, SHORTCUT},
+		PERF_NS:                []Kind{ACTIVITY, ALERT, ALERT_REVISION, REGRESSION, REGRESSION_HISTORY, SHORTCUT},
+		PERF_ANDROID_NS:        []Kind{ACTIVITY, ALERT, ALERT_REVISION, REGRESSION, REGRESSION_HISTORY, SHORTCUT},
+		PERF_ANDROID_MASTER_NS: []Kind{ACTIVITY, ALERT, ALERT_REVISION, REGRESSION, REGRESSION_HISTORY, SHORTCUT},
+		PERF_CT_NS:             []Kind{ACTIVITY, ALERT, ALERT_REVISION, REGRESSION, REGRESSION_HISTORY, SHORTCUT},
 		GOLD_CHROMEVR_NS:       goldKinds,
 		GOLD_LOTTIE_NS:         goldKinds,
 		GOLD_PDFIUM_NS:         goldKinds,
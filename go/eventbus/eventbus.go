@@ -56,7 +56,9 @@ type EventBus interface {
 	//  objectRegEx - only include objects where the name matches this regular
 	//                expression (can be nil). Client side filtering.
 	//  client - Google storage client that has permission to create a
-	//           pubsub based event subscription for the given bucket.
+	//           pubsub based event subscription for the given bucket. Can be
+	//           nil, in which case only events published via
+	//           PublishStorageEvent are received, e.g. for local files.
 	//
 	// Returns: channel ID to use in the SubscribeAsync call to receive events
 	//          for this combination of (bucketName, objectPrefix, objectRegEx), e.g.
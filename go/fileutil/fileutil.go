@@ -14,6 +14,19 @@ import (
 	"go.skia.org/infra/go/util"
 )
 
+// LOCAL_PATH_PREFIX is the prefix of locations that refer to the local file
+// system instead of a GCS bucket, e.g. "file:///path/to/dir".
+const LOCAL_PATH_PREFIX = "file://"
+
+// LocalPath returns the path on the local file system and true if the given
+// location starts with LOCAL_PATH_PREFIX.
+func LocalPath(location string) (string, bool) {
+	if strings.HasPrefix(location, LOCAL_PATH_PREFIX) {
+		return strings.TrimPrefix(location, LOCAL_PATH_PREFIX), true
+	}
+	return "", false
+}
+
 // EnsureDirExists checks whether the given path to a directory exits and creates it
 // if necessary. Returns the absolute path that corresponds to the input path
 // and an error indicating a problem.
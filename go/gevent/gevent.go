@@ -140,10 +140,12 @@ func (d *distEventBus) SubscribeAsync(channelID string, callback eventbus.Callba
 // RegisterStorageEvents implements the eventbus.EventBus interface.
 func (d *distEventBus) RegisterStorageEvents(bucketName string, objectPrefix string, objectRegEx *regexp.Regexp, client *storage.Client) (string, error) {
 	ctx := context.TODO()
-	bucket := client.Bucket(bucketName)
-
 	notifyID := eventbus.GetNotificationID(bucketName, objectPrefix)
-	if !d.disableGCSSubscriptions {
+
+	// Without a storage client only the events published via
+	// PublishStorageEvent are received.
+	if client != nil && !d.disableGCSSubscriptions {
+		bucket := client.Bucket(bucketName)
 		notifications, err := bucket.Notifications(ctx)
 		if err != nil {
 			return "", err
@@ -166,7 +168,6 @@ func (d *distEventBus) RegisterStorageEvents(bucketName string, objectPrefix str
 		}
 
 		if !found {
-			bucket := client.Bucket(bucketName)
 			notificationInfo, err = bucket.AddNotification(ctx, &storage.Notification{
 				TopicProjectID:   d.projectID,
 				TopicID:          d.topic.ID(),
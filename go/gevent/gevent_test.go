@@ -18,6 +18,7 @@ const (
 	SUBSCRIBER_1           = "buildbot-1"
 	SUBSCRIBER_2           = "buildbot-2"
 	SUBSCRIBER_STORAGE_EVT = "buildbot-storage-evt"
+	SUBSCRIBER_NO_CLIENT   = "buildbot-no-client"
 
 	// TEST_BUCKET is not actually accessed, it's just used to test synthetic storate events.
 	TEST_BUCKET = "skia-not-existing-gm"
@@ -95,7 +96,20 @@ func TestSynStorageEvents(t *testing.T) {
 	// Disable actual subscription to the bucket. It's not possible to test right now, but
 	// if the subscription fails or doesn't work we will know immediately when deploying.
 	eventBus.(*distEventBus).disableGCSSubscriptions = true
+	testSynStorageEvents(t, eventBus)
+}
+
+func TestSynStorageEventsNoClient(t *testing.T) {
+	testutils.LargeTest(t)
+
+	// Without a storage client no subscription to the bucket is attempted,
+	// e.g. for the storage events of local files.
+	eventBus, err := New(PROJECT_ID, LOCAL_TOPIC, SUBSCRIBER_NO_CLIENT)
+	assert.NoError(t, err)
+	testSynStorageEvents(t, eventBus)
+}
 
+func testSynStorageEvents(t *testing.T, eventBus eventbus.EventBus) {
 	targetFileRegExp := regexp.MustCompile(`.*\.json`)
 	storageEvtChan, err := eventBus.RegisterStorageEvents(TEST_BUCKET, TEST_PREFIX, targetFileRegExp, nil)
 	assert.NoError(t, err)
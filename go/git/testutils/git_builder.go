@@ -177,6 +177,13 @@ func (g *GitBuilder) CreateBranchAtCommit(ctx context.Context, name, commit stri
 	g.push(ctx)
 }
 
+// CreateTag creates a lightweight tag with the given name pointing at the
+// given commit and pushes it.
+func (g *GitBuilder) CreateTag(ctx context.Context, name, commit string) {
+	g.run(ctx, "git", "tag", name, commit)
+	g.run(ctx, "git", "push", "origin", name)
+}
+
 // CreateOrphanBranch creates a new orphan branch.
 func (g *GitBuilder) CreateOrphanBranch(ctx context.Context, newBranch string) {
 	g.run(ctx, "git", "checkout", "--orphan", newBranch)
@@ -141,7 +141,11 @@ func getSource(id string, dataSource *sharedconfig.DataSource, client *http.Clie
 		return NewGoogleStorageSource(id, dataSource.Bucket, dataSource.Dir, client, eventBus)
 	}
 
-	return nil, sklog.FmtErrorf("Unable to create source. At least a bucket and directory must be supplied")
+	localDir, ok := fileutil.LocalPath(dataSource.Dir)
+	if !ok {
+		return nil, fmt.Errorf("Datasource for %s needs either a bucket or a directory starting with %s, got %q.", id, fileutil.LOCAL_PATH_PREFIX, dataSource.Dir)
+	}
+	return NewFileSystemSource(id, localDir, eventBus)
 }
 
 // validIngestionFile returns true if the given file name matches basic rules.
@@ -319,20 +323,33 @@ func (g *gsResultFileLocation) Content() []byte {
 	return g.content
 }
 
-// TODO(stephana): Remove FileSystemSource since it's mostly used for testing, but
-// ingestion has now moved to being event driven, a mode that is not supported by it.
+// FS_BUCKET_ID is the bucket id used in storage events for files on the local
+// file system. The object id of these events is the absolute path of the file
+// without the leading '/'.
+const FS_BUCKET_ID = "--fsResultFileLocation"
 
 // FileSystemSource implements the Source interface to read from the local
-// file system.
+// file system. Storage events are only generated by polling the directory,
+// which publishes them on the event bus.
 type FileSystemSource struct {
-	rootDir string
-	id      string
+	rootDir  string
+	id       string
+	eventBus eventbus.EventBus
 }
 
-func NewFileSystemSource(baseName, rootDir string) (Source, error) {
+// NewFileSystemSource returns a new instance of FileSystemSource that reads
+// files from the given directory. If eventBus is not nil, storage events
+// for files in the directory are sent to the channel set via SetEventChannel.
+func NewFileSystemSource(baseName, rootDir string, eventBus eventbus.EventBus) (Source, error) {
+	absRootDir, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, sklog.FmtErrorf("Unable to get absolute path of %s: %s", rootDir, err)
+	}
+
 	return &FileSystemSource{
-		rootDir: rootDir,
-		id:      fmt.Sprintf("%s:fs:%s", baseName, rootDir),
+		rootDir:  absRootDir,
+		id:       fmt.Sprintf("%s:fs:%s", baseName, rootDir),
+		eventBus: eventBus,
 	}, nil
 }
 
@@ -391,14 +408,30 @@ func (f *FileSystemSource) ID() string {
 
 // SetEventChannel implements the Source interface.
 func (f *FileSystemSource) SetEventChannel(resultCh chan<- ResultFileLocation) error {
-	// Note: Events are not supported for the file system right now. Since it's mostly used for testing.
-	return sklog.FmtErrorf("FileSystemSource does not implement SetEventChannel")
+	if f.eventBus != nil {
+		eventType, err := f.eventBus.RegisterStorageEvents(FS_BUCKET_ID, f.rootDir, targetFileRegExp, nil)
+		if err != nil {
+			return sklog.FmtErrorf("Unable to register storage event: %s", err)
+		}
+
+		f.eventBus.SubscribeAsync(eventType, func(evData interface{}) {
+			file := evData.(*eventbus.StorageEvent)
+			rf, err := FileSystemResult("/"+file.ObjectID, f.rootDir)
+			if err != nil {
+				sklog.Errorf("Unable to create file system result: %s", err)
+				return
+			}
+			resultCh <- rf
+		})
+	}
+	return nil
 }
 
 // fsResultFileLocation implements the ResultFileLocation interface for
 // the local filesystem.
 type fsResultFileLocation struct {
 	path        string
+	absPath     string
 	buf         []byte
 	md5         string
 	lastUpdated int64
@@ -437,6 +470,7 @@ func FileSystemResult(path, rootDir string) (ResultFileLocation, error) {
 
 	return &fsResultFileLocation{
 		path:        strings.TrimPrefix(absPath, absRootDir+"/"),
+		absPath:     absPath,
 		buf:         buf.Bytes(),
 		md5:         hex.EncodeToString(md5),
 		lastUpdated: fileInfo.ModTime().Unix(),
@@ -455,7 +489,7 @@ func (f *fsResultFileLocation) Name() string {
 
 // StorageIDs implements the ResultFileLocation interface.
 func (f *fsResultFileLocation) StorageIDs() (string, string) {
-	return "--fsResultFileLocation", f.path
+	return FS_BUCKET_ID, strings.TrimLeft(f.absPath, "/")
 }
 
 // see ResultFileLocation interface.
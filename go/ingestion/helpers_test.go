@@ -3,13 +3,18 @@ package ingestion
 import (
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"testing"
 	"time"
 
 	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/eventbus"
+	"go.skia.org/infra/go/fileutil"
 	"go.skia.org/infra/go/gcs"
+	"go.skia.org/infra/go/sharedconfig"
 	"go.skia.org/infra/go/testutils"
 )
 
@@ -51,11 +56,70 @@ func TestFileSystemResultFileLocations(t *testing.T) {
 	assert.NoError(t, err)
 	defer testutils.RemoveAll(t, TEST_DATA_DIR)
 
-	src, err := NewFileSystemSource("test-fs-source", TEST_DATA_DIR)
+	src, err := NewFileSystemSource("test-fs-source", TEST_DATA_DIR, nil)
 	assert.NoError(t, err)
 	testSource(t, src)
 }
 
+func TestFileSystemSourceEvents(t *testing.T) {
+	testutils.SmallTest(t)
+
+	rootDir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	eventBus := eventbus.New()
+	src, err := NewFileSystemSource("test-fs-source", rootDir, eventBus)
+	assert.NoError(t, err)
+	resultCh := make(chan ResultFileLocation, 5)
+	assert.NoError(t, src.SetEventChannel(resultCh))
+
+	// Write a file into the current hourly directory and poll for it.
+	hourDir := filepath.Join(rootDir, time.Now().UTC().Format("2006/01/02/15"))
+	assert.NoError(t, os.MkdirAll(hourDir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(hourDir, "results.json"), []byte("{}"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(hourDir, "results.txt"), []byte("{}"), 0644))
+	found := drainPollChannel(src.Poll(time.Now().Add(-time.Hour).Unix(), time.Now().Unix()))
+	assert.Equal(t, 1, len(found))
+
+	// The storage event generated from the poll result is delivered to the channel.
+	bucketID, objectID := found[0].StorageIDs()
+	assert.Equal(t, FS_BUCKET_ID, bucketID)
+	eventBus.PublishStorageEvent(eventbus.NewStorageEvent(bucketID, objectID, found[0].TimeStamp(), found[0].MD5()))
+	select {
+	case rf := <-resultCh:
+		assert.Equal(t, found[0].Name(), rf.Name())
+		assert.Equal(t, found[0].MD5(), rf.MD5())
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "Did not receive storage event.")
+	}
+
+	// Events for files outside of the directory are ignored.
+	eventBus.PublishStorageEvent(eventbus.NewStorageEvent(bucketID, "some/other/dir/results.json", 0, "abcd"))
+	select {
+	case rf := <-resultCh:
+		assert.FailNow(t, "Unexpected storage event for "+rf.Name())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestGetSource(t *testing.T) {
+	testutils.SmallTest(t)
+
+	rootDir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	src, err := getSource("test", &sharedconfig.DataSource{Dir: fileutil.LOCAL_PATH_PREFIX + rootDir}, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, rootDir, src.(*FileSystemSource).rootDir)
+
+	// A source without a bucket must be explicitly local.
+	_, err = getSource("test", &sharedconfig.DataSource{Dir: rootDir}, nil, nil)
+	assert.Error(t, err)
+
+	_, err = getSource("test", &sharedconfig.DataSource{Bucket: "some-bucket"}, nil, nil)
+	assert.Error(t, err)
+}
+
 func TestCompareSources(t *testing.T) {
 	testutils.LargeTest(t)
 
@@ -66,7 +130,7 @@ func TestCompareSources(t *testing.T) {
 	assert.NoError(t, err)
 	defer testutils.RemoveAll(t, TEST_DATA_DIR)
 
-	fsSource, err := NewFileSystemSource("test-fs-source", TEST_DATA_DIR)
+	fsSource, err := NewFileSystemSource("test-fs-source", TEST_DATA_DIR, nil)
 	assert.NoError(t, err)
 
 	gsResults := drainPollChannel(gsSource.Poll(START_TIME, END_TIME))
@@ -84,3 +84,24 @@ func TestDomainFromHost(t *testing.T) {
 	assert.Equal(t, "skia.org", domainFromHost("perf.skia.org:443"))
 	assert.Equal(t, "skia.org", domainFromHost("example.com:443"))
 }
+
+func TestInitLocal(t *testing.T) {
+	testutils.SmallTest(t)
+	once.Do(loginInit)
+
+	// Restore the regular login system for other tests.
+	domains, emails, admins := activeUserDomainWhiteList, activeUserEmailWhiteList, activeAdminEmailWhiteList
+	defer func() {
+		localUser = ""
+		activeUserDomainWhiteList, activeUserEmailWhiteList, activeAdminEmailWhiteList = domains, emails, admins
+	}()
+
+	InitLocal("jdoe@example.com")
+	r, err := http.NewRequest("GET", "http://localhost:8000/", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "jdoe@example.com", LoggedInAs(r))
+	email, id := UserIdentifiers(r)
+	assert.Equal(t, "jdoe@example.com", email)
+	assert.Equal(t, "jdoe@example.com", id)
+	assert.True(t, IsAdmin(r))
+}
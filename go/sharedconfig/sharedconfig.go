@@ -5,10 +5,11 @@ import (
 )
 
 // DataSource is a single ingestion source. Currently we use the convention
-// that if 'bucket' is empty, we assume a source on the local file system.
+// that if 'bucket' is empty, we assume a source on the local file system,
+// in which case 'dir' must start with "file://".
 type DataSource struct {
 	Bucket string // Bucket in Google storage. If empty local storage is assumed.
-	Dir    string // Root directory of the data to ingest.
+	Dir    string // Root directory of the data to ingest, e.g. "file:///path/to/dir" for local storage.
 }
 
 type IngesterConfig struct {
@@ -43,6 +43,9 @@ type masterTileBuilder struct {
 	// git is the Git repo the commits come from.
 	git *gitinfo.GitInfo
 
+	// pull indicates whether the Git repo is pulled before a tile is loaded.
+	pull bool
+
 	// evt is the eventbus where we announce the availability of new tiles.
 	evt eventbus.EventBus
 
@@ -53,13 +56,15 @@ type masterTileBuilder struct {
 // NewBuilder creates a new Builder given the gitinfo, and loads Tiles from the
 // traceserver running at the given address. The tiles contain the last
 // 'tileSize' commits and are built from Traces of the type that traceBuilder
-// returns.
-func NewMasterTileBuilder(ctx context.Context, db DB, git *gitinfo.GitInfo, tileSize int, evt eventbus.EventBus, cachePath string) (MasterTileBuilder, error) {
+// returns. If pull is false the Git repo is not pulled before a tile is loaded,
+// i.e. only the commits in the local checkout are used.
+func NewMasterTileBuilder(ctx context.Context, db DB, git *gitinfo.GitInfo, tileSize int, pull bool, evt eventbus.EventBus, cachePath string) (MasterTileBuilder, error) {
 	ret := &masterTileBuilder{
 		tileSize:  tileSize,
 		tile:      nil,
 		db:        db,
 		git:       git,
+		pull:      pull,
 		evt:       evt,
 		cachePath: cachePath,
 	}
@@ -110,7 +115,7 @@ func NewMasterTileBuilder(ctx context.Context, db DB, git *gitinfo.GitInfo, tile
 // periodically by the Builder to keep the tile fresh.
 func (t *masterTileBuilder) LoadTile(ctx context.Context) error {
 	// Build CommitIDs for the last INITIAL_TILE_SIZE commits to the repo.
-	if err := t.git.Update(ctx, true, false); err != nil {
+	if err := t.git.Update(ctx, t.pull, false); err != nil {
 		sklog.Errorf("Failed to update Git repo: %s", err)
 	}
 	hashes := t.git.LastN(ctx, t.tileSize)
@@ -2,12 +2,15 @@ package main
 
 import (
 	"github.com/spf13/cobra"
+	"go.skia.org/infra/gold-client/go/goldclient"
 )
 
-// TODO(stephana): Implement the auth command that's currently stubbed out.
-
 // authEnv provides the environment for the auth command.
-type authEnv struct{}
+type authEnv struct {
+	flagServiceAccount string
+	flagUseLuci        bool
+	flagWorkDir        string
+}
 
 // getAuthCmd returns the definition of the auth command.
 func getAuthCmd() *cobra.Command {
@@ -16,12 +19,29 @@ func getAuthCmd() *cobra.Command {
 		Use:   "auth",
 		Short: "Authenticate against GCP",
 		Long: `
-Authenticate against GCP - TODO: How to specify the service account file ? `,
+Authenticate against GCP and the Gold backend. Either a service account file
+or the LUCI context can be used. The choice is stored in the work directory
+and used by the commands that upload to Gold.`,
 		Run: env.runAuthCmd,
 	}
+	authCmd.Flags().StringVarP(&env.flagServiceAccount, "service-account", "", "", "Service account file to be used to authenticate.")
+	authCmd.Flags().BoolVarP(&env.flagUseLuci, "luci", "", false, "Use the LUCI context to authenticate.")
+	authCmd.Flags().StringVarP(&env.flagWorkDir, "work-dir", "", "", "Temporary work directory")
+	_ = authCmd.MarkFlagRequired("work-dir")
 
 	return authCmd
 }
 
-// runAuthCommand
-func (a *authEnv) runAuthCmd(cmd *cobra.Command, args []string) { notImplemented(cmd) }
+// runAuthCommand writes the authentication options to the work directory
+// after making sure they can be used to create an authenticated client.
+func (a *authEnv) runAuthCmd(cmd *cobra.Command, args []string) {
+	authOpt := &goldclient.AuthOpt{
+		Luci:           a.flagUseLuci,
+		ServiceAccount: a.flagServiceAccount,
+	}
+	ifErrLogExit(cmd, authOpt.Validate())
+	_, err := authOpt.GetHTTPClient()
+	ifErrLogExit(cmd, err)
+	ifErrLogExit(cmd, goldclient.SaveAuthOpt(a.flagWorkDir, authOpt))
+	logVerbose(cmd, "Authentication options written to work directory.\n")
+}
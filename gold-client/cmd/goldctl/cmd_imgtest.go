@@ -1,13 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"net/http"
 	"os"
 
 	"github.com/spf13/cobra"
+	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/gold-client/go/goldclient"
 	"go.skia.org/infra/golden/go/jsonio"
 	"go.skia.org/infra/golden/go/search"
+	"go.skia.org/infra/golden/go/types"
 )
 
 // imgTestEnv is the environment for the imgtest command ant its sub-commands.
@@ -19,6 +25,7 @@ type imgTestEnv struct {
 	flagPatchsetID   string
 	flagJobID        string
 	flagInstandID    string
+	flagURL          string
 	flagWorkDir      string
 	flagPassFailStep bool
 	flagFailureFile  string
@@ -63,9 +70,12 @@ Add images generated by the tests to the test results. This requires two argumen
 		Run:  env.runImgTestAddCmd,
 		Args: cobra.NoArgs,
 	}
-	env.addCommonFlags(imgTestAddCmd, false)
+	env.addCommonFlags(imgTestAddCmd, true)
 	imgTestAddCmd.Flags().StringVarP(&env.flagTestName, "test-name", "", "", "Unique name of the test, must not contain spaces.")
 	imgTestAddCmd.Flags().StringVarP(&env.flagPNGFile, "png-file", "", "", "Path to the PNG file that contains the test results.")
+	_ = imgTestAddCmd.MarkFlagRequired("work-dir")
+	_ = imgTestAddCmd.MarkFlagRequired("test-name")
+	_ = imgTestAddCmd.MarkFlagRequired("png-file")
 
 	imgTestFinalizeCmd := &cobra.Command{
 		Use:   "finalize",
@@ -75,6 +85,7 @@ All tests have been added. Upload images and generate and upload the JSON file t
 test results.`,
 		Run: env.runImgTestFinalizeCmd,
 	}
+	env.addWorkDirFlag(imgTestFinalizeCmd)
 
 	imgTestPassFailCmd := &cobra.Command{
 		Use:   "passfail",
@@ -83,6 +94,8 @@ test results.`,
 Check against Gold or local baseline whether the results match the expectations`,
 		Run: env.runImgTestPassFailCmd,
 	}
+	env.addWorkDirFlag(imgTestPassFailCmd)
+	imgTestPassFailCmd.Flags().StringVarP(&env.flagFailureFile, "failure-file", "", "", "Path to the file where to write failure information")
 
 	// assemble the imgtest command.
 	imgTestCmd.AddCommand(
@@ -94,8 +107,16 @@ Check against Gold or local baseline whether the results match the expectations`
 	return imgTestCmd
 }
 
+// addWorkDirFlag adds the required work directory flag to commands that
+// only operate on the state written by 'imgtest init'.
+func (i *imgTestEnv) addWorkDirFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&i.flagWorkDir, "work-dir", "", "", "Temporary work directory")
+	_ = cmd.MarkFlagRequired("work-dir")
+}
+
 func (i *imgTestEnv) addCommonFlags(cmd *cobra.Command, optional bool) {
 	cmd.Flags().StringVarP(&i.flagInstandID, "instance", "", "", "ID of the Gold instance.")
+	cmd.Flags().StringVarP(&i.flagURL, "url", "", "", "URL of the Gold instance. If empty it is derived from the instance ID.")
 	cmd.Flags().StringVarP(&i.flagWorkDir, "work-dir", "", "", "Temporary work directory")
 	cmd.Flags().BoolVarP(&i.flagPassFailStep, "passfail", "", false, "Whether the 'add' call returns a pass/fail for each test.")
 
@@ -115,13 +136,63 @@ func (i *imgTestEnv) addCommonFlags(cmd *cobra.Command, optional bool) {
 	}
 }
 
-// TODO(stephana): Implement these stubbed out sub-commands.
-func (i *imgTestEnv) runImgTestInitCmd(cmd *cobra.Command, args []string)     { notImplemented(cmd) }
-func (i *imgTestEnv) runImgTestFinalizeCmd(cmd *cobra.Command, args []string) { notImplemented(cmd) }
-func (i *imgTestEnv) runImgTestPassFailCmd(cmd *cobra.Command, args []string) { notImplemented(cmd) }
+// runImgTestInitCmd sets up the work directory for a testing session.
+func (i *imgTestEnv) runImgTestInitCmd(cmd *cobra.Command, args []string) {
+	_ = i.initGoldClient(cmd)
+}
 
-// runImgTestCommand processes and uploads test results to Gold.
+// runImgTestAddCmd adds a test result and uploads the image to Gold. If
+// 'imgtest init' was not called for the work directory it initializes it
+// from the flags first.
 func (i *imgTestEnv) runImgTestAddCmd(cmd *cobra.Command, args []string) {
+	var goldClient goldclient.GoldClient
+	if goldclient.StateExists(i.flagWorkDir) {
+		goldClient = i.loadGoldClient(cmd)
+	} else {
+		goldClient = i.initGoldClient(cmd)
+	}
+
+	pass, err := goldClient.Test(i.flagTestName, i.flagPNGFile)
+	ifErrLogExit(cmd, err)
+
+	if !pass {
+		ifErrLogExit(cmd, i.writeFailures(fmt.Sprintf("%s\n", i.flagTestName)))
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// runImgTestFinalizeCmd uploads the results that were added to Gold.
+func (i *imgTestEnv) runImgTestFinalizeCmd(cmd *cobra.Command, args []string) {
+	goldClient := i.loadGoldClient(cmd)
+	ifErrLogExit(cmd, goldClient.Finalize())
+}
+
+// runImgTestPassFailCmd checks the results that were added against the
+// current baseline and exits with a non-zero exit code if any of them is
+// not positive.
+func (i *imgTestEnv) runImgTestPassFailCmd(cmd *cobra.Command, args []string) {
+	goldClient := i.loadGoldClient(cmd)
+	failures, err := goldClient.Check()
+	ifErrLogExit(cmd, err)
+
+	if len(failures) == 0 {
+		logVerbose(cmd, "All results match the expectations.\n")
+		os.Exit(0)
+	}
+
+	var buf bytes.Buffer
+	for _, result := range failures {
+		_, _ = fmt.Fprintf(&buf, "%s %s\n", result.Key[types.PRIMARY_KEY_FIELD], result.Digest)
+	}
+	logErrf(cmd, "%d results do not match the expectations:\n%s", len(failures), buf.String())
+	ifErrLogExit(cmd, i.writeFailures(buf.String()))
+	os.Exit(1)
+}
+
+// initGoldClient creates a GoldClient from the flags and writes its state to
+// the work directory.
+func (i *imgTestEnv) initGoldClient(cmd *cobra.Command) goldclient.GoldClient {
 	keyMap, err := readKeysFile(i.flagKeysFile)
 	ifErrLogExit(cmd, err)
 
@@ -139,18 +210,49 @@ func (i *imgTestEnv) runImgTestAddCmd(cmd *cobra.Command, args []string) {
 		BuildBucketID: jobID,
 	}
 
-	up, err := goldclient.NewUploadResults(gr, i.flagInstandID, i.flagPassFailStep, i.flagWorkDir)
+	up, err := goldclient.NewUploadResults(gr, i.flagInstandID, i.flagURL, i.flagPassFailStep, i.flagWorkDir)
 	ifErrLogExit(cmd, err)
-	goldClient, err := goldclient.NewCloudClient(up)
+	storage, httpClient := getUploadDeps(cmd, i.flagWorkDir)
+	goldClient, err := goldclient.NewCloudClient(storage, httpClient, up)
 	ifErrLogExit(cmd, err)
+	return goldClient
+}
 
-	pass, err := goldClient.Test(i.flagTestName, i.flagPNGFile)
+// loadGoldClient creates a GoldClient from the state in the work directory.
+func (i *imgTestEnv) loadGoldClient(cmd *cobra.Command) goldclient.GoldClient {
+	storage, httpClient := getUploadDeps(cmd, i.flagWorkDir)
+	goldClient, err := goldclient.LoadCloudClient(storage, httpClient, i.flagWorkDir)
 	ifErrLogExit(cmd, err)
+	return goldClient
+}
 
-	if !pass {
-		os.Exit(1)
+// writeFailures appends the given text to the failure file, if one was
+// given.
+func (i *imgTestEnv) writeFailures(text string) error {
+	if i.flagFailureFile == "" {
+		return nil
 	}
-	os.Exit(0)
+	f, err := os.OpenFile(i.flagFailureFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(text); err != nil {
+		util.Close(f)
+		return err
+	}
+	return f.Close()
+}
+
+// getUploadDeps returns the storage and the authenticated HTTP client based
+// on the auth options in the work directory.
+func getUploadDeps(cmd *cobra.Command, workDir string) (goldclient.Storage, *http.Client) {
+	authOpt, err := goldclient.LoadAuthOpt(workDir)
+	ifErrLogExit(cmd, err)
+	httpClient, err := authOpt.GetHTTPClient()
+	ifErrLogExit(cmd, err)
+	storage, err := goldclient.GetGCSStorage(context.Background(), httpClient)
+	ifErrLogExit(cmd, err)
+	return storage, httpClient
 }
 
 // readKeysFile is a helper function to read a JSON file with key/value pairs.
@@ -0,0 +1,98 @@
+package goldclient
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"cloud.google.com/go/storage"
+	"go.skia.org/infra/go/auth"
+	"go.skia.org/infra/go/httputils"
+	"go.skia.org/infra/go/sklog"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+)
+
+const (
+	// authFileName is the name of the file in the work directory that
+	// contains the authentication options.
+	authFileName = "auth_opt.json"
+)
+
+// AuthOpt defines how goldctl authenticates against GCS and the Gold
+// backend. It is written to the work directory by the 'auth' command so
+// that subsequent commands can use it.
+type AuthOpt struct {
+	// Luci indicates that the LUCI context should be used to get tokens.
+	Luci bool `json:"luci"`
+
+	// ServiceAccount is the path to the JSON file of a service account.
+	ServiceAccount string `json:"serviceAccount"`
+}
+
+// Validate returns an error if the options don't define a way to
+// authenticate.
+func (a *AuthOpt) Validate() error {
+	if a.Luci == (a.ServiceAccount != "") {
+		return sklog.FmtErrorf("Exactly one of LUCI auth or a service account file must be specified.")
+	}
+	return nil
+}
+
+// SaveAuthOpt writes the authentication options to the work directory.
+func SaveAuthOpt(workDir string, authOpt *AuthOpt) error {
+	if err := authOpt.Validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return sklog.FmtErrorf("Error creating work directory %s: %s", workDir, err)
+	}
+	jsonBytes, err := json.Marshal(authOpt)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(workDir, authFileName), jsonBytes, 0600)
+}
+
+// LoadAuthOpt reads the authentication options that were written to the
+// work directory by SaveAuthOpt.
+func LoadAuthOpt(workDir string) (*AuthOpt, error) {
+	jsonBytes, err := ioutil.ReadFile(filepath.Join(workDir, authFileName))
+	if err != nil {
+		return nil, sklog.FmtErrorf("Error reading auth options. Did you run 'goldctl auth'?: %s", err)
+	}
+	ret := &AuthOpt{}
+	if err := json.Unmarshal(jsonBytes, ret); err != nil {
+		return nil, sklog.FmtErrorf("Error parsing auth options: %s", err)
+	}
+	return ret, ret.Validate()
+}
+
+// GetHTTPClient returns an authenticated HTTP client.
+func (a *AuthOpt) GetHTTPClient() (*http.Client, error) {
+	scopes := []string{auth.SCOPE_USERINFO_EMAIL, auth.SCOPE_FULL_CONTROL}
+	var ts oauth2.TokenSource
+	var err error
+	if a.Luci {
+		ts, err = auth.NewLUCIContextTokenSource(scopes...)
+	} else {
+		ts, err = auth.NewJWTServiceAccountTokenSource("", a.ServiceAccount, scopes...)
+	}
+	if err != nil {
+		return nil, sklog.FmtErrorf("Error retrieving token source: %s", err)
+	}
+	return httputils.DefaultClientConfig().WithTokenSource(ts).With2xxOnly().Client(), nil
+}
+
+// GetGCSStorage returns a Storage instance that uploads to GCS using the
+// given authenticated HTTP client.
+func GetGCSStorage(ctx context.Context, client *http.Client) (Storage, error) {
+	storageClient, err := storage.NewClient(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, sklog.FmtErrorf("Error creating storage client: %s", err)
+	}
+	return NewGCSStorage(storageClient), nil
+}
@@ -1,16 +1,19 @@
 package goldclient
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
 	"image/png"
 	"io/ioutil"
+	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"go.skia.org/infra/go/sklog"
@@ -24,12 +27,28 @@ const (
 	resultPrefix       = "dm-json-v1"
 	imagePrefix        = "dm-images-v1"
 	resultFileNameTmpl = "dm-%s.json"
-	tempFileName       = "dm.json"
+
+	// stateFileName is the name of the file in the work directory that
+	// contains the state of the client between invocations.
+	stateFileName = "result-state.json"
+
+	// baselineRoute and hashesRoute are the routes on the Gold server that
+	// serve the baseline and the known hashes.
+	baselineRoute = "/json/baseline"
+	hashesRoute   = "/_/hashes"
 )
 
 type GoldClient interface {
 	SetConfig(config interface{}) error
 	Test(name string, imgFileName string) (bool, error)
+
+	// Finalize uploads the results file that contains all the results added
+	// via Test.
+	Finalize() error
+
+	// Check fetches the current baseline and returns the results added via
+	// Test whose digests are not positive in it.
+	Check() ([]*jsonio.Result, error)
 }
 
 type UploadResults struct {
@@ -38,63 +57,74 @@ type UploadResults struct {
 
 	perTestPassFail bool
 	instanceID      string
+	goldURL         string
 	workDir         string
 }
 
-func NewUploadResults(results *jsonio.GoldResults, instanceID string, perTestPassFail bool, workDir string) (*UploadResults, error) {
+// NewUploadResults creates a new UploadResults instance. If goldURL is empty
+// it is derived from the instanceID.
+func NewUploadResults(results *jsonio.GoldResults, instanceID, goldURL string, perTestPassFail bool, workDir string) (*UploadResults, error) {
 	ret := &UploadResults{
 		results:         results,
 		perTestPassFail: perTestPassFail,
 		instanceID:      instanceID,
+		goldURL:         goldURL,
 		workDir:         workDir,
 	}
 
 	return ret, nil
 }
 
-func (u *UploadResults) merge(right *UploadResults) {
-	if u.results == nil {
-		u.results = right.results
-	}
-	if u.instanceID == "" {
-		u.instanceID = right.instanceID
-	}
-	if !u.perTestPassFail {
-		u.perTestPassFail = right.perTestPassFail
-	}
-}
-
-func (u *UploadResults) getResultFilePath() string {
+func getResultFilePath(results *jsonio.GoldResults) string {
 	now := time.Now().UTC()
 	year, month, day := now.Date()
 	hour := now.Hour()
 	fileName := fmt.Sprintf(resultFileNameTmpl, strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10))
 	path := fmt.Sprintf("%s/%04d/%02d/%02d/%02d/%s", resultPrefix, year, month, day, hour, fileName)
 
-	if u.results.Issue > 0 {
+	if results.Issue > 0 {
 		path = "trybot/" + path
 	}
 
 	return path
 }
 
-func (u *UploadResults) getImagePath(imgHash string) string {
+func getImagePath(imgHash string) string {
 	return fmt.Sprintf("%s/%s.png", imagePrefix, imgHash)
 }
 
+// resultState is the state of a cloudClient. It is written to the work
+// directory so that it survives between invocations of goldctl.
+type resultState struct {
+	Results         *jsonio.GoldResults `json:"results"`
+	PerTestPassFail bool                `json:"perTestPassFail"`
+	InstanceID      string              `json:"instanceID"`
+	GoldURL         string              `json:"goldURL"`
+	Bucket          string              `json:"bucket"`
+	ResultsPath     string              `json:"resultsPath"`
+	KnownHashes     util.StringSet      `json:"knownHashes"`
+	Expectations    types.TestExp       `json:"expectations"`
+}
+
 // Implement the GoldClient interface for a remote Gold server.
 type cloudClient struct {
-	uploadResults *UploadResults
-	ready         bool
-	goldURL       string
-	bucket        string
-	knownHashes   util.StringSet
-	expectations  types.TestExp
+	workDir     string
+	resultState *resultState
+	ready       bool
+	storage     Storage
+	httpClient  *http.Client
 }
 
-func NewCloudClient(results *UploadResults) (GoldClient, error) {
+// NewCloudClient returns a GoldClient that uploads to the given storage and
+// uses the given HTTP client to talk to the Gold instance identified by
+// 'results'. The state of the client is written to the work directory, so it
+// can be picked up by LoadCloudClient.
+func NewCloudClient(storage Storage, httpClient *http.Client, results *UploadResults) (GoldClient, error) {
 	ret := &cloudClient{
-		uploadResults: &UploadResults{},
+		workDir:     results.workDir,
+		resultState: &resultState{},
+		storage:     storage,
+		httpClient:  httpClient,
 	}
 	if err := ret.SetConfig(results); err != nil {
 		return nil, sklog.FmtErrorf("Error initializing result in Cloud GoldClient: %s", err)
@@ -103,6 +133,34 @@ func NewCloudClient(results *UploadResults) (GoldClient, error) {
 	return ret, nil
 }
 
+// LoadCloudClient returns a GoldClient from the state that a previous call to
+// NewCloudClient wrote to the work directory.
+func LoadCloudClient(storage Storage, httpClient *http.Client, workDir string) (GoldClient, error) {
+	jsonBytes, err := ioutil.ReadFile(filepath.Join(workDir, stateFileName))
+	if err != nil {
+		return nil, sklog.FmtErrorf("Error reading state from work dir. Did you run 'goldctl imgtest init'?: %s", err)
+	}
+	state := &resultState{}
+	if err := json.Unmarshal(jsonBytes, state); err != nil {
+		return nil, sklog.FmtErrorf("Error parsing state in work dir: %s", err)
+	}
+
+	return &cloudClient{
+		workDir:     workDir,
+		resultState: state,
+		ready:       true,
+		storage:     storage,
+		httpClient:  httpClient,
+	}, nil
+}
+
+// StateExists returns true if a client state was written to the work
+// directory.
+func StateExists(workDir string) bool {
+	_, err := os.Stat(filepath.Join(workDir, stateFileName))
+	return err == nil
+}
+
 func (c *cloudClient) SetConfig(config interface{}) error {
 	// If we are ready, there is nothing todo here.
 	if c.ready {
@@ -114,36 +172,50 @@ func (c *cloudClient) SetConfig(config interface{}) error {
 	if !ok {
 		return sklog.FmtErrorf("Provided config is not an instance of *UploadResults")
 	}
-	c.uploadResults.merge(resultConf)
+
+	// TODO:  Make sure the GoldResult instance is set up correctly.
+	if _, err := resultConf.results.Validate(true); err != nil {
+		return sklog.FmtErrorf("Invalid GoldResults set. Missing fields: %s", err)
+	}
+
+	c.resultState.Results = resultConf.results
+	c.resultState.PerTestPassFail = resultConf.perTestPassFail
+	c.resultState.ResultsPath = getResultFilePath(resultConf.results)
 
 	// From the instance ID load Derive the Gold URL and the bucket from the instance ID.
-	if err := c.processInstanceID(resultConf.instanceID); err != nil {
+	if err := c.processInstanceID(resultConf.instanceID, resultConf.goldURL); err != nil {
 		return err
 	}
 
-	// TODO:  Make sure the GoldResult instance is set up correctly.
-	if _, err := c.uploadResults.results.Validate(true); err != nil {
-		return sklog.FmtErrorf("Invalid GoldResults set. Missing fields: %s", err)
+	if err := c.saveState(); err != nil {
+		return err
 	}
-
 	c.ready = true
 	return nil
 }
 
-func (c *cloudClient) processInstanceID(instanceID string) error {
+func (c *cloudClient) processInstanceID(instanceID, goldURL string) error {
 	// TODO(stephana): Move the URLs and deriving the bucket to a central place in the backend
 	// or get rid of the bucket entirely and expose an upload URL (requires authentication)
 
 	// Derive and set the GoldURL and the upload bucket.
-	c.goldURL = fmt.Sprintf("https://%s-gold.skia.org", instanceID)
-	c.bucket = fmt.Sprintf("skia-gold-%s", instanceID)
-
-	// TODO(stephana): Fetch the known hashes (may be empty, but should not fail).
-	c.knownHashes = util.StringSet{}
+	if goldURL == "" {
+		goldURL = fmt.Sprintf("https://%s-gold.skia.org", instanceID)
+	}
+	c.resultState.InstanceID = instanceID
+	c.resultState.GoldURL = strings.TrimRight(goldURL, "/")
+	c.resultState.Bucket = fmt.Sprintf("skia-gold-%s", instanceID)
 
-	// TODO(stephana): Fetch the baseline (may be empty but should not fail).
-	c.expectations = types.TestExp{}
+	// Fetch the known hashes (may be empty, but should not fail).
+	var err error
+	if c.resultState.KnownHashes, err = c.fetchKnownHashes(); err != nil {
+		return err
+	}
 
+	// Fetch the baseline (may be empty but should not fail).
+	if c.resultState.Expectations, err = c.fetchBaseline(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -153,35 +225,77 @@ func (c *cloudClient) Test(name string, imgFileName string) (bool, error) {
 	}
 
 	// Load the PNG from disk and hash it.
-	_, imgHash, err := loadAndHashFile(imgFileName)
+	imgBytes, imgHash, err := loadAndHashFile(imgFileName)
 	if err != nil {
 		return false, err
 	}
 
 	// Check against known hashes and upload if needed.
-	if !c.knownHashes[imgHash] {
-		if err := c.gsUtilUploadImage(imgFileName, imgHash); err != nil {
+	if !c.resultState.KnownHashes[imgHash] {
+		if err := c.storage.Upload(context.Background(), c.resultState.Bucket, getImagePath(imgHash), imgBytes); err != nil {
 			return false, sklog.FmtErrorf("Error uploading image: %s", err)
 		}
+		c.resultState.KnownHashes[imgHash] = true
 	}
 
-	// Upload the result of this test.
+	// Add the result of this test. If we do per test pass/fail there might
+	// not be a finalize step, so we upload the results right away.
 	c.addResult(name, imgHash)
-	if err := c.gsUtilUploadResultsFile(); err != nil {
-		return false, sklog.FmtErrorf("Error uploading result file: %s", err)
+	if c.resultState.PerTestPassFail {
+		if err := c.uploadResultsFile(); err != nil {
+			return false, sklog.FmtErrorf("Error uploading result file: %s", err)
+		}
+	}
+	if err := c.saveState(); err != nil {
+		return false, err
 	}
 
 	// If we do per test pass/fail then compare to the baseline and return accordingly
-	if c.uploadResults.perTestPassFail {
+	if c.resultState.PerTestPassFail {
 		// Check if this is positive in the expectations.
 		// TODO(stephana): Better define semantics of expecations.
-		return c.expectations[name][imgHash] == types.POSITIVE, nil
+		return c.resultState.Expectations[name][imgHash] == types.POSITIVE, nil
 	}
 
 	// If we don't do per-test pass/fail then return true.
 	return true, nil
 }
 
+// Finalize implements the GoldClient interface.
+func (c *cloudClient) Finalize() error {
+	if !c.ready {
+		return sklog.FmtErrorf("Unable to finalize. Cloud Gold Client uninitialized.")
+	}
+	if err := c.uploadResultsFile(); err != nil {
+		return sklog.FmtErrorf("Error uploading result file: %s", err)
+	}
+	return nil
+}
+
+// Check implements the GoldClient interface.
+func (c *cloudClient) Check() ([]*jsonio.Result, error) {
+	if !c.ready {
+		return nil, sklog.FmtErrorf("Unable to check results. Cloud Gold Client uninitialized.")
+	}
+
+	exp, err := c.fetchBaseline()
+	if err != nil {
+		return nil, err
+	}
+	c.resultState.Expectations = exp
+	if err := c.saveState(); err != nil {
+		return nil, err
+	}
+
+	ret := []*jsonio.Result{}
+	for _, result := range c.resultState.Results.Results {
+		if exp[result.Key[types.PRIMARY_KEY_FIELD]][result.Digest] != types.POSITIVE {
+			ret = append(ret, result)
+		}
+	}
+	return ret, nil
+}
+
 func (c *cloudClient) addResult(name, imgHash string) {
 	// Add the result to the overall results.
 	newResult := &jsonio.Result{
@@ -193,54 +307,89 @@ func (c *cloudClient) addResult(name, imgHash string) {
 	}
 
 	// TODO(stephana): Make the corpus field an option.
-	if _, ok := c.uploadResults.results.Key[types.CORPUS_FIELD]; !ok {
-		newResult.Key[types.CORPUS_FIELD] = c.uploadResults.instanceID
+	if _, ok := c.resultState.Results.Key[types.CORPUS_FIELD]; !ok {
+		newResult.Key[types.CORPUS_FIELD] = c.resultState.InstanceID
 	}
-	c.uploadResults.results.Results = append(c.uploadResults.results.Results, newResult)
+	c.resultState.Results.Results = append(c.resultState.Results.Results, newResult)
 }
 
-func (c *cloudClient) gsUtilUploadResultsFile() error {
-	localFileName := filepath.Join(c.uploadResults.workDir, tempFileName)
-	jsonBytes, err := json.MarshalIndent(c.uploadResults.results, "", "  ")
+func (c *cloudClient) uploadResultsFile() error {
+	jsonBytes, err := json.MarshalIndent(c.resultState.Results, "", "  ")
 	if err != nil {
 		return err
 	}
+	return c.storage.Upload(context.Background(), c.resultState.Bucket, c.resultState.ResultsPath, jsonBytes)
+}
 
-	if err := ioutil.WriteFile(localFileName, jsonBytes, 0600); err != nil {
+// saveState writes the state of the client to the work directory.
+func (c *cloudClient) saveState() error {
+	if err := os.MkdirAll(c.workDir, 0755); err != nil {
+		return sklog.FmtErrorf("Error creating work directory %s: %s", c.workDir, err)
+	}
+	jsonBytes, err := json.Marshal(c.resultState)
+	if err != nil {
 		return err
 	}
-
-	// Get the path to upload to
-	objPath := c.uploadResults.getResultFilePath()
-	return c.gsUtilCopy(localFileName, objPath)
+	if err := ioutil.WriteFile(filepath.Join(c.workDir, stateFileName), jsonBytes, 0600); err != nil {
+		return sklog.FmtErrorf("Error writing state to work dir: %s", err)
+	}
+	return nil
 }
 
-func (c *cloudClient) gsUtilCopy(srcFile, targetPath string) error {
-	srcFile, err := filepath.Abs(srcFile)
+// fetchKnownHashes returns the hashes of the images the Gold instance
+// already has.
+func (c *cloudClient) fetchKnownHashes() (util.StringSet, error) {
+	body, err := c.get(c.resultState.GoldURL + hashesRoute)
 	if err != nil {
-		return err
+		return nil, sklog.FmtErrorf("Error fetching known hashes: %s", err)
 	}
 
-	// runCmd := &exec.Command{
-	// 	Name:           "gsutil",
-	// 	Args:           []string{"cp", srcFile, fmt.Sprintf("gs://%s/%s", c.bucket, targetPath)},
-	// 	Timeout:        5 * time.Second,
-	// 	CombinedOutput: &combinedBuf,
-	// 	Verbose:        exec.Silent,
-	// }
+	ret := util.StringSet{}
+	scanner := bufio.NewScanner(bytes.NewBuffer(body))
+	for scanner.Scan() {
+		if hash := strings.TrimSpace(scanner.Text()); hash != "" {
+			ret[hash] = true
+		}
+	}
+	return ret, scanner.Err()
+}
 
-	runCmd := exec.Command("gsutil", "cp", srcFile, fmt.Sprintf("gs://%s/%s", c.bucket, targetPath))
-	outBytes, err := runCmd.CombinedOutput()
+// fetchBaseline returns the baseline of the branch the results belong to,
+// i.e. of the Gerrit issue for tryjobs and of master otherwise.
+func (c *cloudClient) fetchBaseline() (types.TestExp, error) {
+	url := c.resultState.GoldURL + baselineRoute
+	if c.resultState.Results.Issue > 0 {
+		url = fmt.Sprintf("%s/%d", url, c.resultState.Results.Issue)
+	}
+	body, err := c.get(url)
 	if err != nil {
-		return sklog.FmtErrorf("Error running gsutil. Got output \n%s\n and error: %s", outBytes, err)
+		return nil, sklog.FmtErrorf("Error fetching baseline: %s", err)
 	}
 
-	return nil
+	// Only the expectations of the baseline.CommitableBaseLine are needed.
+	baseline := &struct {
+		Baseline types.TestExp `json:"master"`
+	}{}
+	if err := json.Unmarshal(body, baseline); err != nil {
+		return nil, sklog.FmtErrorf("Error parsing baseline: %s", err)
+	}
+	if baseline.Baseline == nil {
+		return types.TestExp{}, nil
+	}
+	return baseline.Baseline, nil
 }
 
-func (c *cloudClient) gsUtilUploadImage(imagePath string, imgHash string) error {
-	objPath := c.uploadResults.getImagePath(imgHash)
-	return c.gsUtilCopy(imagePath, objPath)
+// get returns the body of the response to a GET request to the given URL.
+func (c *cloudClient) get(url string) ([]byte, error) {
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, sklog.FmtErrorf("Request to %s failed with status: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
 }
 
 func loadAndHashFile(fileName string) ([]byte, string, error) {
@@ -0,0 +1,160 @@
+package goldclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/golden/go/jsonio"
+	"go.skia.org/infra/golden/go/types"
+)
+
+const (
+	testInstanceID = "testing"
+	testGitHash    = "abcd1234"
+)
+
+// writePNG writes a 1x1 PNG with the given color to the directory and returns
+// the path and the hash of the image.
+func writePNG(t *testing.T, dir, name string, c color.NRGBA) (string, string) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, c)
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	assert.NoError(t, err)
+	assert.NoError(t, png.Encode(f, img))
+	assert.NoError(t, f.Close())
+
+	_, hash, err := loadAndHashFile(path)
+	assert.NoError(t, err)
+	return path, hash
+}
+
+// newTestServer returns a server that serves the given known hashes and
+// baseline the same way the Gold backend does.
+func newTestServer(t *testing.T, knownHash string, exp types.TestExp) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(hashesRoute, func(w http.ResponseWriter, r *http.Request) {
+		_, err := fmt.Fprintf(w, "%s\n", knownHash)
+		assert.NoError(t, err)
+	})
+	mux.HandleFunc(baselineRoute, func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"master": exp}))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestCloudClient(t *testing.T) {
+	testutils.SmallTest(t)
+
+	wd, cleanup := testutils.TempDir(t)
+	defer cleanup()
+	workDir := filepath.Join(wd, "work")
+	storageDir := filepath.Join(wd, "storage")
+
+	passPath, passHash := writePNG(t, wd, "pass.png", color.NRGBA{R: 0xff, A: 0xff})
+	failPath, failHash := writePNG(t, wd, "fail.png", color.NRGBA{G: 0xff, A: 0xff})
+
+	exp := types.TestExp{"test_one": {passHash: types.POSITIVE}}
+	server := newTestServer(t, passHash, exp)
+	defer server.Close()
+
+	gr := &jsonio.GoldResults{
+		GitHash: testGitHash,
+		Key:     map[string]string{"os": "linux"},
+	}
+	up, err := NewUploadResults(gr, testInstanceID, server.URL, true, workDir)
+	assert.NoError(t, err)
+	storage := NewLocalStorage(storageDir)
+	client, err := NewCloudClient(storage, http.DefaultClient, up)
+	assert.NoError(t, err)
+	assert.True(t, StateExists(workDir))
+
+	pass, err := client.Test("test_one", passPath)
+	assert.NoError(t, err)
+	assert.True(t, pass)
+
+	// Load the client from the work dir, like a separate invocation would.
+	client, err = LoadCloudClient(storage, http.DefaultClient, workDir)
+	assert.NoError(t, err)
+	pass, err = client.Test("test_two", failPath)
+	assert.NoError(t, err)
+	assert.False(t, pass)
+	assert.NoError(t, client.Finalize())
+
+	// Only the image that isn't known to the server was uploaded.
+	bucketDir := filepath.Join(storageDir, "skia-gold-"+testInstanceID)
+	_, err = os.Stat(filepath.Join(bucketDir, getImagePath(passHash)))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(bucketDir, getImagePath(failHash)))
+	assert.NoError(t, err)
+
+	// Exactly one results file was uploaded and it contains both results.
+	var resultFiles []string
+	assert.NoError(t, filepath.Walk(filepath.Join(bucketDir, resultPrefix), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			resultFiles = append(resultFiles, path)
+		}
+		return err
+	}))
+	assert.Len(t, resultFiles, 1)
+	jsonBytes, err := ioutil.ReadFile(resultFiles[0])
+	assert.NoError(t, err)
+	uploaded := &jsonio.GoldResults{}
+	assert.NoError(t, json.Unmarshal(jsonBytes, uploaded))
+	assert.Equal(t, testGitHash, uploaded.GitHash)
+	assert.Len(t, uploaded.Results, 2)
+	assert.Equal(t, testInstanceID, uploaded.Results[0].Key[types.CORPUS_FIELD])
+
+	// Only the second result fails the check.
+	failures, err := client.Check()
+	assert.NoError(t, err)
+	assert.Len(t, failures, 1)
+	assert.Equal(t, "test_two", failures[0].Key[types.PRIMARY_KEY_FIELD])
+	assert.Equal(t, failHash, failures[0].Digest)
+
+	// Once the result is triaged the check passes.
+	exp["test_two"] = types.TestClassification{failHash: types.POSITIVE}
+	failures, err = client.Check()
+	assert.NoError(t, err)
+	assert.Empty(t, failures)
+}
+
+func TestLoadCloudClientNoState(t *testing.T) {
+	testutils.SmallTest(t)
+
+	wd, cleanup := testutils.TempDir(t)
+	defer cleanup()
+	assert.False(t, StateExists(wd))
+	_, err := LoadCloudClient(NewLocalStorage(wd), http.DefaultClient, wd)
+	assert.Error(t, err)
+}
+
+func TestAuthOpt(t *testing.T) {
+	testutils.SmallTest(t)
+
+	wd, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	assert.Error(t, SaveAuthOpt(wd, &AuthOpt{}))
+	assert.Error(t, SaveAuthOpt(wd, &AuthOpt{Luci: true, ServiceAccount: "sa.json"}))
+
+	_, err := LoadAuthOpt(wd)
+	assert.Error(t, err)
+
+	authOpt := &AuthOpt{ServiceAccount: "sa.json"}
+	assert.NoError(t, SaveAuthOpt(wd, authOpt))
+	found, err := LoadAuthOpt(wd)
+	assert.NoError(t, err)
+	assert.Equal(t, authOpt, found)
+}
@@ -0,0 +1,63 @@
+package goldclient
+
+import (
+	"context"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+
+	"cloud.google.com/go/storage"
+	"go.skia.org/infra/go/gcs"
+	"go.skia.org/infra/go/sklog"
+)
+
+// Storage is where the images and result files are uploaded to. It allows to
+// swap GCS for a local stand-in.
+type Storage interface {
+	// Upload writes the given data to 'targetPath' in 'bucket'. An existing
+	// file is overwritten.
+	Upload(ctx context.Context, bucket, targetPath string, data []byte) error
+}
+
+// gcsStorage implements the Storage interface for Google Cloud Storage.
+type gcsStorage struct {
+	client *storage.Client
+}
+
+// NewGCSStorage returns a Storage instance that uploads to GCS.
+func NewGCSStorage(client *storage.Client) Storage {
+	return &gcsStorage{client: client}
+}
+
+// Upload implements the Storage interface.
+func (g *gcsStorage) Upload(ctx context.Context, bucket, targetPath string, data []byte) error {
+	gcsClient := gcs.NewGCSClient(g.client, bucket)
+	opts := gcs.FileWriteOptions{ContentType: mime.TypeByExtension(path.Ext(targetPath))}
+	if err := gcsClient.SetFileContents(ctx, targetPath, opts, data); err != nil {
+		return sklog.FmtErrorf("Error uploading to gs://%s/%s: %s", bucket, targetPath, err)
+	}
+	return nil
+}
+
+// localStorage implements the Storage interface by writing to a local
+// directory. Each bucket is a sub directory of the root directory.
+type localStorage struct {
+	rootDir string
+}
+
+// NewLocalStorage returns a Storage instance that writes to the given local
+// directory instead of a remote bucket.
+func NewLocalStorage(rootDir string) Storage {
+	return &localStorage{rootDir: rootDir}
+}
+
+// Upload implements the Storage interface.
+func (l *localStorage) Upload(ctx context.Context, bucket, targetPath string, data []byte) error {
+	outPath := filepath.Join(l.rootDir, bucket, filepath.FromSlash(targetPath))
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return sklog.FmtErrorf("Error creating directory for %s: %s", outPath, err)
+	}
+	return ioutil.WriteFile(outPath, data, 0644)
+}
@@ -1,4 +1,5 @@
-// Simple command line app the applies our image diff library to two PNGs.
+// Simple command line app the applies our image diff library to two images.
+// PNG (including 16-bit), JPEG and WebP images are supported.
 package main
 
 import (
@@ -21,15 +22,16 @@ func main() {
 	if flag.NArg() != 2 {
 		log.Fatal("Usage: imagediff [--out filename] imagepath1.png imagepath2.png\n")
 	}
-	a, err := diff.OpenNRGBAFromFile(flag.Arg(0))
+	a, aInfo, err := diff.OpenImgFromFile(flag.Arg(0))
 	if err != nil {
 		log.Fatal(err)
 	}
-	b, err := diff.OpenNRGBAFromFile(flag.Arg(1))
+	b, bInfo, err := diff.OpenImgFromFile(flag.Arg(1))
 	if err != nil {
 		log.Fatal(err)
 	}
 	metrics, d := diff.PixelDiff(a, b)
+	fmt.Printf("Formats: %s/%d bit vs %s/%d bit\n", aInfo.Format, aInfo.BitDepth, bInfo.Format, bInfo.BitDepth)
 	fmt.Printf("Dimensions are different: %v\n", metrics.DimDiffer)
 	fmt.Printf("Number of pixels different: %v\n", metrics.NumDiffPixels)
 	fmt.Printf("Pixel diff percent: %v\n", metrics.PixelDiffPercent)
@@ -212,7 +212,7 @@ func load(ctx context.Context, dsNamespace string) (*tiling.Tile, types.Expectat
 		sklog.Fatalf("Failed to connect to tracedb: %s", err)
 	}
 
-	masterTileBuilder, err := tracedb.NewMasterTileBuilder(ctx, tdb, git, *nCommits, evt, "")
+	masterTileBuilder, err := tracedb.NewMasterTileBuilder(ctx, tdb, git, *nCommits, true, evt, "")
 	if err != nil {
 		sklog.Fatalf("Failed to build trace/db.DB: %s", err)
 	}
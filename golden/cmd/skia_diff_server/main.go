@@ -23,6 +23,8 @@ import (
 var (
 	cacheSize          = flag.Int("cache_size", 1, "Approximate cachesize used to cache images and diff metrics in GiB. This is just a way to limit caching. 0 means no caching at all. Use default for testing.")
 	convertLegacy      = flag.Bool("convert_legacy", false, "Converts the legacy cache to the new format.")
+	fuzzyChannelDelta  = flag.Int("fuzzy_max_channel_delta", diff.DEFAULT_FUZZY_MAX_CHANNEL_DELTA, "The largest difference in any RGBA channel for two pixels to be considered the same by the fuzzy diff metric.")
+	fuzzyDiffPixels    = flag.Int("fuzzy_max_diff_pixels", diff.DEFAULT_FUZZY_MAX_DIFF_PIXELS, "The number of pixels that may differ by more than fuzzy_max_channel_delta for two images to still be a fuzzy match.")
 	gsBucketNames      = flag.String("gs_buckets", "skia-infra-gm,chromium-skia-gm", "Comma-separated list of google storage bucket that hold uploaded images.")
 	gsBaseDir          = flag.String("gs_basedir", diffstore.DEFAULT_GCS_IMG_DIR_NAME, "String that represents the google storage directory/directories following the GS bucket")
 	imageDir           = flag.String("image_dir", "/tmp/imagedir", "What directory to store test and diff images in.")
@@ -68,7 +70,11 @@ func main() {
 	client := httputils.DefaultClientConfig().WithTokenSource(ts).With2xxOnly().Client()
 
 	// Get the DiffStore that does the work loading and diffing images.
-	mapper := diffstore.NewGoldDiffStoreMapper(&diff.DiffMetrics{})
+	diffCfg := &diff.Config{
+		FuzzyMaxChannelDelta: *fuzzyChannelDelta,
+		FuzzyMaxDiffPixels:   *fuzzyDiffPixels,
+	}
+	mapper := diffstore.NewGoldDiffStoreMapper(&diff.DiffMetrics{}, diffCfg)
 	memDiffStore, err := diffstore.NewMemDiffStore(client, *imageDir, strings.Split(*gsBucketNames, ","), *gsBaseDir, *cacheSize, mapper)
 	if err != nil {
 		sklog.Fatalf("Allocating DiffStore failed: %s", err)
@@ -21,7 +21,9 @@ import (
 	"go.skia.org/infra/go/common"
 	"go.skia.org/infra/go/database"
 	"go.skia.org/infra/go/ds"
+	"go.skia.org/infra/go/email"
 	"go.skia.org/infra/go/eventbus"
+	"go.skia.org/infra/go/fileutil"
 	"go.skia.org/infra/go/gerrit"
 	"go.skia.org/infra/go/gevent"
 	"go.skia.org/infra/go/git/gitinfo"
@@ -34,6 +36,7 @@ import (
 	"go.skia.org/infra/go/timer"
 	tracedb "go.skia.org/infra/go/trace/db"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/autotriage"
 	"go.skia.org/infra/golden/go/db"
 	"go.skia.org/infra/golden/go/diff"
 	"go.skia.org/infra/golden/go/diffstore"
@@ -48,6 +51,7 @@ import (
 	"go.skia.org/infra/golden/go/tryjobstore"
 	"go.skia.org/infra/golden/go/types"
 	"go.skia.org/infra/golden/go/web"
+	"golang.org/x/oauth2"
 	"google.golang.org/api/option"
 	gstorage "google.golang.org/api/storage/v1"
 	"google.golang.org/grpc"
@@ -77,18 +81,28 @@ var (
 	diffServerGRPCAddr  = flag.String("diff_server_grpc", "", "The grpc port of the diff server. 'diff_server_http also needs to be set.")
 	diffServerImageAddr = flag.String("diff_server_http", "", "The images serving address of the diff server. 'diff_server_grpc has to be set as well.")
 	dsNamespace         = flag.String("ds_namespace", "", "Cloud datastore namespace to be used by this instance.")
+	emailClientSecret   = flag.String("email_client_secret_file", "", "OAuth client secret JSON file for sending email. If empty no emails about ignore rules are sent.")
+	emailTokenCacheFile = flag.String("email_token_cache_file", "client_token.json", "OAuth token cache file for sending email.")
 	eventTopic          = flag.String("event_topic", "", "The pubsub topic to use for distributed events.")
+	expBranches         = flag.String("exp_branches", "", "Comma-separated list of expectation branches, e.g. for release branches. Branches inherit the master expectations and can override them.")
 	forceLogin          = flag.Bool("force_login", true, "Force the user to be authenticated for all requests.")
-	gsBucketNames       = flag.String("gs_buckets", "skia-infra-gm,chromium-skia-gm", "Comma-separated list of google storage bucket that hold uploaded images.")
+	fuzzyChannelDelta   = flag.Int("fuzzy_max_channel_delta", diff.DEFAULT_FUZZY_MAX_CHANNEL_DELTA, "The largest difference in any RGBA channel for two pixels to be considered the same by the fuzzy diff metric. Ignored if diff_server_grpc is set.")
+	fuzzyDiffPixels     = flag.Int("fuzzy_max_diff_pixels", diff.DEFAULT_FUZZY_MAX_DIFF_PIXELS, "The number of pixels that may differ by more than fuzzy_max_channel_delta for two images to still be a fuzzy match. Ignored if diff_server_grpc is set.")
+	gsBucketNames       = flag.String("gs_buckets", "skia-infra-gm,chromium-skia-gm", "Comma-separated list of google storage bucket that hold uploaded images. Entries starting with 'file://' refer to local directories.")
 	hashesGSPath        = flag.String("hashes_gs_path", "", "GS path, where the known hashes file should be stored. If empty no file will be written. Format: <bucket>/<path>.")
 	baselineGSPath      = flag.String("baseline_gs_path", "", "GS path, where the baseline file should be stored. If empty no file will be written. Format: <bucket>/<path>.")
+	ignoreExpiryWarning = flag.Duration("ignore_expiry_warning", 72*time.Hour, "The owner of an ignore rule is notified this long before the rule expires. 0 disables these notifications.")
+	ignoreStaleAfter    = flag.Duration("ignore_stale_after", 30*24*time.Hour, "Ignore rules that did not match any untriaged digests for this long are flagged as stale. 0 disables flagging.")
+	ignoreWebhookURL    = flag.String("ignore_webhook_url", "", "If set, notifications about ignore rules are also POSTed as JSON to this URL.")
 	imageDir            = flag.String("image_dir", "/tmp/imagedir", "What directory to store test and diff images in.")
 	indexInterval       = flag.Duration("idx_interval", 5*time.Minute, "Interval at which the indexer calculates the search index.")
 	internalPort        = flag.String("internal_port", "", "HTTP service address for internal clients, e.g. probers. No authentication on this port.")
 	local               = flag.Bool("local", false, "Running locally if true. As opposed to in production.")
+	localStoreDir       = flag.String("local_store_dir", "", "If set, expectations, ignore rules and tryjobs are stored in files in this directory instead of cloud datastore. Useful for triaging a local test run.")
 	memProfile          = flag.Duration("memprofile", 0, "Duration for which to profile memory. After this duration the program writes the memory profile and exits.")
 	nCommits            = flag.Int("n_commits", 50, "Number of recent commits to include in the analysis.")
 	noCloudLog          = flag.Bool("no_cloud_log", false, "Disables cloud logging. Primarily for running locally.")
+	offlineUser         = flag.String("offline_user", "user@localhost", "The user that all requests are attributed to when running offline, i.e. if local_store_dir is set and all images and output paths are on the local file system.")
 	port                = flag.String("port", ":9000", "HTTP service address (e.g., ':9000')")
 	projectID           = flag.String("project_id", common.PROJECT_ID, "GCP project ID.")
 	promPort            = flag.String("prom_port", ":20000", "Metrics service address (e.g., ':10110')")
@@ -181,24 +195,38 @@ func main() {
 		*resourcesDir += "/frontend"
 	}
 
+	// When running offline all data is read from and written to the local file
+	// system. No credentials, login or access to the remote Git repo are needed.
+	offline := isOffline()
+
 	// Set up login
-	useRedirectURL := *redirectURL
-	if *local {
-		useRedirectURL = fmt.Sprintf("http://localhost%s/oauth2callback/", *port)
-	}
-	authWhiteList := metadata.GetWithDefault(metadata.AUTH_WHITE_LIST, login.DEFAULT_DOMAIN_WHITELIST)
-	if err := login.Init(useRedirectURL, authWhiteList, *clientSecretFile); err != nil {
-		sklog.Fatalf("Failed to initialize the login system: %s", err)
+	if offline {
+		login.InitLocal(*offlineUser)
+		sklog.Infof("Running offline. All requests are made by %s.", *offlineUser)
+	} else {
+		useRedirectURL := *redirectURL
+		if *local {
+			useRedirectURL = fmt.Sprintf("http://localhost%s/oauth2callback/", *port)
+		}
+		authWhiteList := metadata.GetWithDefault(metadata.AUTH_WHITE_LIST, login.DEFAULT_DOMAIN_WHITELIST)
+		if err := login.Init(useRedirectURL, authWhiteList, *clientSecretFile); err != nil {
+			sklog.Fatalf("Failed to initialize the login system: %s", err)
+		}
 	}
 
 	// Get the token source for the service account with access to GCS, the Monorail issue tracker,
 	// cloud pubsub, and datastore.
-	tokenSource, err := auth.NewJWTServiceAccountTokenSource("", *serviceAccountFile, gstorage.CloudPlatformScope, "https://www.googleapis.com/auth/userinfo.email")
-	if err != nil {
-		sklog.Fatalf("Failed to authenticate service account: %s", err)
-	}
 	// TODO(dogben): Ok to add request/dial timeouts?
-	client := httputils.DefaultClientConfig().WithTokenSource(tokenSource).WithoutRetries().Client()
+	var tokenSource oauth2.TokenSource = nil
+	clientConfig := httputils.DefaultClientConfig().WithoutRetries()
+	if !offline {
+		tokenSource, err = auth.NewJWTServiceAccountTokenSource("", *serviceAccountFile, gstorage.CloudPlatformScope, "https://www.googleapis.com/auth/userinfo.email")
+		if err != nil {
+			sklog.Fatalf("Failed to authenticate service account: %s", err)
+		}
+		clientConfig = clientConfig.WithTokenSource(tokenSource)
+	}
+	client := clientConfig.Client()
 
 	// serviceName uniquely identifies this host and app and is used as ID for other services.
 	nodeName, err := gevent.GetNodeName(appName, *local)
@@ -227,7 +255,11 @@ func main() {
 		}
 		sklog.Infof("DiffStore: NetDiffStore initiated.")
 	} else {
-		mapper := diffstore.NewGoldDiffStoreMapper(&diff.DiffMetrics{})
+		diffCfg := &diff.Config{
+			FuzzyMaxChannelDelta: *fuzzyChannelDelta,
+			FuzzyMaxDiffPixels:   *fuzzyDiffPixels,
+		}
+		mapper := diffstore.NewGoldDiffStoreMapper(&diff.DiffMetrics{}, diffCfg)
 		diffStore, err = diffstore.NewMemDiffStore(client, *imageDir, strings.Split(*gsBucketNames, ","), diffstore.DEFAULT_GCS_IMG_DIR_NAME, *cacheSize, mapper)
 		if err != nil {
 			sklog.Fatalf("Allocating local DiffStore failed: %s", err)
@@ -239,7 +271,13 @@ func main() {
 		sklog.Fatal(err)
 	}
 
-	git, err := gitinfo.CloneOrUpdate(ctx, *gitRepoURL, *gitRepoDir, false)
+	// Offline the local checkout is used as is, i.e. it is never pulled.
+	var git *gitinfo.GitInfo
+	if offline {
+		git, err = gitinfo.NewGitInfo(ctx, *gitRepoDir, false, false)
+	} else {
+		git, err = gitinfo.CloneOrUpdate(ctx, *gitRepoURL, *gitRepoDir, false)
+	}
 	if err != nil {
 		sklog.Fatal(err)
 	}
@@ -248,6 +286,9 @@ func main() {
 	// depending whether an PubSub topic was defined.
 	var evt eventbus.EventBus = nil
 	if *eventTopic != "" {
+		if offline {
+			sklog.Fatalf("A distributed eventbus (event_topic) cannot be used while running offline.")
+		}
 		evt, err = gevent.New(*projectID, *eventTopic, nodeName, option.WithTokenSource(tokenSource))
 		if err != nil {
 			sklog.Fatalf("Unable to create global event client. Got error: %s", err)
@@ -280,7 +321,7 @@ func main() {
 		sklog.Fatalf("Failed to connect to tracedb: %s", err)
 	}
 
-	masterTileBuilder, err := tracedb.NewMasterTileBuilder(ctx, db, git, *nCommits, evt, filepath.Join(*storageDir, "cached-last-tile"))
+	masterTileBuilder, err := tracedb.NewMasterTileBuilder(ctx, db, git, *nCommits, !offline, evt, filepath.Join(*storageDir, "cached-last-tile"))
 	if err != nil {
 		sklog.Fatalf("Failed to build trace/db.DB: %s", err)
 	}
@@ -290,20 +331,65 @@ func main() {
 		BaselineGSPath: *baselineGSPath,
 	}
 
+	// In local mode the known hashes and the baselines are written to the
+	// local store directory unless explicitly configured otherwise.
+	if *localStoreDir != "" {
+		if gsClientOpt.HashesGSPath == "" {
+			gsClientOpt.HashesGSPath = fileutil.LOCAL_PATH_PREFIX + filepath.Join(*localStoreDir, "hashes.txt")
+		}
+		if gsClientOpt.BaselineGSPath == "" {
+			gsClientOpt.BaselineGSPath = fileutil.LOCAL_PATH_PREFIX + filepath.Join(*localStoreDir, "baselines")
+		}
+	}
+
 	gsClient, err := storage.NewGStorageClient(client, gsClientOpt)
 	if err != nil {
 		sklog.Fatalf("Unable to create GStorageClient: %s", err)
 	}
 
-	if err := ds.InitWithOpt(*projectID, *dsNamespace, option.WithTokenSource(tokenSource)); err != nil {
-		sklog.Fatalf("Unable to configure cloud datastore: %s", err)
+	// Set up the cloud expectations store, since at least the issue portion
+	// will be used even if we use MySQL. In local mode everything is stored
+	// in files in the local store directory.
+	var expStore expstorage.ExpectationsStore
+	var issueExpStoreFactory expstorage.IssueExpStoreFactory
+	var branchExpStoreFactory expstorage.BranchExpStoreFactory
+	if *localStoreDir != "" {
+		expDir := filepath.Join(*localStoreDir, "expectations")
+		expStore, issueExpStoreFactory, err = expstorage.NewLocalExpectationsStore(expDir, evt)
+		if err != nil {
+			sklog.Fatalf("Unable to configure local expectations store: %s", err)
+		}
+
+		branchExpStoreFactory, err = expstorage.NewLocalBranchExpStoreFactory(expDir, evt)
+		if err != nil {
+			sklog.Fatalf("Unable to configure local branch expectations store: %s", err)
+		}
+	} else {
+		if err := ds.InitWithOpt(*projectID, *dsNamespace, option.WithTokenSource(tokenSource)); err != nil {
+			sklog.Fatalf("Unable to configure cloud datastore: %s", err)
+		}
+
+		expStore, issueExpStoreFactory, err = expstorage.NewCloudExpectationsStore(ds.DS, evt)
+		if err != nil {
+			sklog.Fatalf("Unable to configure cloud expectations store: %s", err)
+		}
+
+		branchExpStoreFactory, err = expstorage.NewCloudBranchExpStoreFactory(ds.DS, evt)
+		if err != nil {
+			sklog.Fatalf("Unable to configure cloud branch expectations store: %s", err)
+		}
 	}
 
-	// Set up the cloud expectations store, since at least the issue portion
-	// will be used even if we use MySQL.
-	expStore, issueExpStoreFactory, err := expstorage.NewCloudExpectationsStore(ds.DS, evt)
-	if err != nil {
-		sklog.Fatalf("Unable to configure cloud expectations store: %s", err)
+	// Parse the names of the expectation branches.
+	branches := []string{}
+	for _, branch := range strings.Split(*expBranches, ",") {
+		if branch = strings.TrimSpace(branch); branch == "" {
+			continue
+		}
+		if !expstorage.ValidBranchName(branch) {
+			sklog.Fatalf("Invalid name for an expectations branch: %q", branch)
+		}
+		branches = append(branches, branch)
 	}
 
 	// Check if we should set up a MySQL backend for some of the stores.
@@ -330,25 +416,32 @@ func main() {
 		expStore = expstorage.NewSQLExpectationStore(vdb)
 	}
 
-	tryjobStore, err := tryjobstore.NewCloudTryjobStore(ds.DS, issueExpStoreFactory, evt)
+	var tryjobStore tryjobstore.TryjobStore
+	if *localStoreDir != "" {
+		tryjobStore, err = tryjobstore.NewLocalTryjobStore(filepath.Join(*localStoreDir, "tryjobs.gob"), issueExpStoreFactory, evt)
+	} else {
+		tryjobStore, err = tryjobstore.NewCloudTryjobStore(ds.DS, issueExpStoreFactory, evt)
+	}
 	if err != nil {
 		sklog.Fatalf("Unable to instantiate tryjob store: %s", err)
 	}
 
 	// Extract the site URL
 	storages := &storage.Storage{
-		DiffStore:            diffStore,
-		ExpectationsStore:    expstorage.NewCachingExpectationStore(expStore, evt),
-		IssueExpStoreFactory: issueExpStoreFactory,
-		MasterTileBuilder:    masterTileBuilder,
-		DigestStore:          digestStore,
-		NCommits:             *nCommits,
-		EventBus:             evt,
-		TryjobStore:          tryjobStore,
-		TryjobMonitor:        tryjobs.NewTryjobMonitor(tryjobStore, gerritAPI, *siteURL, evt, *authoritative),
-		GerritAPI:            gerritAPI,
-		GStorageClient:       gsClient,
-		Git:                  git,
+		DiffStore:             diffStore,
+		ExpectationsStore:     expstorage.NewCachingExpectationStore(expStore, evt),
+		IssueExpStoreFactory:  issueExpStoreFactory,
+		BranchExpStoreFactory: branchExpStoreFactory,
+		ExpBranches:           branches,
+		MasterTileBuilder:     masterTileBuilder,
+		DigestStore:           digestStore,
+		NCommits:              *nCommits,
+		EventBus:              evt,
+		TryjobStore:           tryjobStore,
+		TryjobMonitor:         tryjobs.NewTryjobMonitor(tryjobStore, gerritAPI, *siteURL, evt, *authoritative),
+		GerritAPI:             gerritAPI,
+		GStorageClient:        gsClient,
+		Git:                   git,
 	}
 
 	// Load the whitelist if there is one and disable querying for issues.
@@ -371,6 +464,10 @@ func main() {
 	// If MySQL is configured we use it to store the ignore rules.
 	if useMySQL {
 		storages.IgnoreStore = ignore.NewSQLIgnoreStore(vdb, storages.ExpectationsStore, storages.GetTileStreamNow(time.Minute))
+	} else if *localStoreDir != "" {
+		if storages.IgnoreStore, err = ignore.NewLocalIgnoreStore(filepath.Join(*localStoreDir, "ignores.json"), storages.ExpectationsStore, storages.GetTileStreamNow(time.Minute)); err != nil {
+			sklog.Fatalf("Unable to create local ignorestore: %s", err)
+		}
 	} else if storages.IgnoreStore, err = ignore.NewCloudIgnoreStore(ds.DS, storages.ExpectationsStore, storages.GetTileStreamNow(time.Minute)); err != nil {
 		sklog.Fatalf("Unable to create ignorestore: %s", err)
 	}
@@ -379,6 +476,48 @@ func main() {
 		sklog.Fatalf("Failed to start monitoring for expired ignore rules: %s", err)
 	}
 
+	// Only the authoritative instance notifies the owners of ignore rules to
+	// avoid duplicate notifications.
+	var ignoreNotifier ignore.Notifier = nil
+	if *authoritative {
+		notifiers := []ignore.Notifier{}
+		if *emailClientSecret != "" {
+			emailAuth, err := email.NewFromFiles(*emailTokenCacheFile, *emailClientSecret)
+			if err != nil {
+				sklog.Fatalf("Failed to create email auth: %s", err)
+			}
+			notifiers = append(notifiers, ignore.NewEmailNotifier(emailAuth))
+		}
+		if *ignoreWebhookURL != "" {
+			notifiers = append(notifiers, ignore.NewWebhookNotifier(httputils.NewTimeoutClient(), *ignoreWebhookURL))
+		}
+		ignoreNotifier = ignore.NewMultiNotifier(notifiers...)
+	}
+
+	ignoreMonitorOpts := &ignore.RuleMonitorOptions{
+		ExpiryWarning: *ignoreExpiryWarning,
+		StaleAfter:    *ignoreStaleAfter,
+		SiteURL:       *siteURL,
+		StatePath:     filepath.Join(*storageDir, "ignore-monitor-state"),
+	}
+	if storages.IgnoreMonitor, err = ignore.NewRuleMonitor(storages.IgnoreStore, ignoreNotifier, ignoreMonitorOpts); err != nil {
+		sklog.Fatalf("Failed to create the ignore rule monitor: %s", err)
+	}
+	storages.IgnoreMonitor.Start(time.Hour)
+
+	// The auto-triage rules are stored next to the ignore rules.
+	if useMySQL || (*localStoreDir != "") {
+		storages.AutoTriageStore = autotriage.NewMemRuleStore()
+	} else if storages.AutoTriageStore, err = autotriage.NewCloudRuleStore(ds.DS); err != nil {
+		sklog.Fatalf("Unable to create auto-triage rule store: %s", err)
+	}
+
+	// Only the authoritative instance applies the auto-triage rules, since
+	// they change the expectations.
+	if *authoritative {
+		storages.AutoTriager = autotriage.New(storages.AutoTriageStore, storages.ExpectationsStore, storages.DiffStore)
+	}
+
 	// Rebuild the index every two minutes.
 	ixr, err := indexer.New(storages, *indexInterval)
 	if err != nil {
@@ -427,12 +566,14 @@ func main() {
 
 	// json handlers only used by the new UI.
 	router.HandleFunc("/json/byblame", handlers.JsonByBlameHandler).Methods("GET")
+	router.HandleFunc("/json/blame", handlers.JsonDigestBlameHandler).Methods("GET")
 	router.HandleFunc("/json/list", handlers.JsonListTestsHandler).Methods("GET")
 	router.HandleFunc("/json/paramset", handlers.JsonParamsHandler).Methods("GET")
 	router.HandleFunc("/json/commits", handlers.JsonCommitsHandler).Methods("GET")
 	router.HandleFunc("/json/diff", handlers.JsonDiffHandler).Methods("GET")
 	router.HandleFunc("/json/details", handlers.JsonDetailsHandler).Methods("GET")
 	router.HandleFunc("/json/triage", handlers.JsonTriageHandler).Methods("POST")
+	router.HandleFunc("/json/triage/bulk", handlers.JsonBulkTriageHandler).Methods("POST")
 	router.HandleFunc("/json/clusterdiff", handlers.JsonClusterDiffHandler).Methods("GET")
 	router.HandleFunc("/json/cmp", handlers.JsonCompareTestHandler).Methods("POST")
 	router.HandleFunc("/json/triagelog", handlers.JsonTriageLogHandler).Methods("GET")
@@ -450,12 +591,21 @@ func main() {
 	router.HandleFunc(web.BASELINE_ISSUE_ROUTE, handlers.JsonBaselineHandler).Methods("GET")
 	router.HandleFunc("/json/refresh/{id}", handlers.JsonRefreshIssue).Methods("GET")
 
+	// Expectation branches.
+	router.HandleFunc("/json/branches", handlers.JsonBranchesHandler).Methods("GET")
+	router.HandleFunc("/json/branches/merge/{branch}", handlers.JsonBranchMergeHandler).Methods("POST")
+
 	// Only expose these endpoints if login is enforced across the app or this an open site.
 	if openSite {
 		router.HandleFunc("/json/ignores", handlers.JsonIgnoresHandler).Methods("GET")
 		router.HandleFunc("/json/ignores/add/", handlers.JsonIgnoresAddHandler).Methods("POST")
 		router.HandleFunc("/json/ignores/del/{id}", handlers.JsonIgnoresDeleteHandler).Methods("POST")
 		router.HandleFunc("/json/ignores/save/{id}", handlers.JsonIgnoresUpdateHandler).Methods("POST")
+		router.HandleFunc("/json/ignores/report", handlers.JsonIgnoresReportHandler).Methods("GET")
+		router.HandleFunc("/json/autotriage", handlers.JsonAutoTriageRulesHandler).Methods("GET")
+		router.HandleFunc("/json/autotriage/add/", handlers.JsonAutoTriageAddHandler).Methods("POST")
+		router.HandleFunc("/json/autotriage/del/{id}", handlers.JsonAutoTriageDeleteHandler).Methods("POST")
+		router.HandleFunc("/json/autotriage/save/{id}", handlers.JsonAutoTriageUpdateHandler).Methods("POST")
 	}
 
 	// For everything else serve the same markup.
@@ -530,3 +680,22 @@ func main() {
 	sklog.Infof("Serving on http://127.0.0.1" + *port)
 	sklog.Fatal(http.ListenAndServe(*port, externalHandler))
 }
+
+// isOffline returns true if the local store directory is used and all images,
+// known hashes and baselines are on the local file system.
+func isOffline() bool {
+	if (*localStoreDir == "") || (*diffServerGRPCAddr != "") || (*diffServerImageAddr != "") {
+		return false
+	}
+	for _, bucket := range strings.Split(*gsBucketNames, ",") {
+		if _, ok := fileutil.LocalPath(bucket); !ok {
+			return false
+		}
+	}
+	for _, gsPath := range []string{*hashesGSPath, *baselineGSPath} {
+		if _, ok := fileutil.LocalPath(gsPath); (gsPath != "") && !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -24,10 +24,16 @@ var gold = gold || {};
 	gold.METRIC_COMBINED = 'combined';
 	gold.METRIC_PERCENT  = 'percent';
 	gold.METRIC_PIXEL    = 'pixel';
+	gold.METRIC_SSIM     = 'ssim';
+	gold.METRIC_DELTA_E  = 'deltae';
+	gold.METRIC_FUZZY    = 'fuzzy';
   gold.allMetrics = [
     gold.METRIC_COMBINED,
     gold.METRIC_PERCENT,
     gold.METRIC_PIXEL,
+    gold.METRIC_SSIM,
+    gold.METRIC_DELTA_E,
+    gold.METRIC_FUZZY,
   ];
 
   // Operators to apply to images grouped by test.
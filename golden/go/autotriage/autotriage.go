@@ -0,0 +1,356 @@
+// Package autotriage automatically marks new digests as positive when they
+// are close enough to an existing positive digest, based on rules that are
+// configured per test or per query.
+package autotriage
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/digesttools"
+	"go.skia.org/infra/golden/go/expstorage"
+	"go.skia.org/infra/golden/go/ignore"
+	"go.skia.org/infra/golden/go/tally"
+	"go.skia.org/infra/golden/go/types"
+)
+
+const (
+	// BOT_USER is the user recorded in the triage log for the changes made by
+	// the AutoTriager, so they can be told apart from, and undone like, the
+	// changes made by people.
+	BOT_USER = "gold-autotriage@skia.org"
+
+	// LOG_PAGE_SIZE is the number of triage log entries read at a time.
+	LOG_PAGE_SIZE = 1000
+)
+
+// Threshold is the largest value of a diff metric for which a digest is
+// considered close enough to a positive digest.
+type Threshold struct {
+	Metric string  `json:"metric"` // One of diff.GetDiffMetricIDs().
+	Max    float32 `json:"max"`
+}
+
+// Rule describes which untriaged digests are automatically marked positive.
+//
+// A digest produced by a trace that matches Query is marked positive if its
+// closest positive digest, as measured by the metric of the first Threshold,
+// is within all of the Thresholds.
+type Rule struct {
+	ID         int64       `json:"id"`
+	UpdatedBy  string      `json:"updatedBy"`
+	Updated    time.Time   `json:"updated"`
+	Query      string      `json:"query"`
+	Thresholds []Threshold `json:"thresholds"`
+	Note       string      `json:"note"`
+}
+
+// NewRule creates a new Rule.
+func NewRule(user string, queryStr string, thresholds []Threshold, note string) *Rule {
+	return &Rule{
+		UpdatedBy:  user,
+		Updated:    time.Now(),
+		Query:      queryStr,
+		Thresholds: thresholds,
+		Note:       note,
+	}
+}
+
+// Validate returns an error if the Rule is not valid.
+func (r *Rule) Validate() error {
+	q, err := url.ParseQuery(r.Query)
+	if err != nil {
+		return fmt.Errorf("Invalid query %q: %s", r.Query, err)
+	}
+	if len(q) == 0 {
+		return fmt.Errorf("Query must not be empty.")
+	}
+	if len(r.Thresholds) == 0 {
+		return fmt.Errorf("At least one threshold is required.")
+	}
+	for _, t := range r.Thresholds {
+		if !util.In(t.Metric, diff.GetDiffMetricIDs()) {
+			return fmt.Errorf("Unknown diff metric: %q", t.Metric)
+		}
+		if t.Max < 0 {
+			return fmt.Errorf("Threshold for %q must be >= 0: %f", t.Metric, t.Max)
+		}
+	}
+	return nil
+}
+
+// RuleStore stores auto-triage rules.
+type RuleStore interface {
+	// Create adds a new rule to the store.
+	Create(*Rule) error
+
+	// List returns all the rules in the store.
+	List() ([]*Rule, error)
+
+	// Update replaces the rule with the given id.
+	Update(id int64, rule *Rule) error
+
+	// Delete removes a rule from the store. The return value is the number of
+	// records that were deleted (either 0 or 1).
+	Delete(id int64) (int, error)
+}
+
+// MemRuleStore is an in-memory implementation of RuleStore.
+type MemRuleStore struct {
+	rules  []*Rule
+	mutex  sync.Mutex
+	nextId int64
+}
+
+// NewMemRuleStore creates a new MemRuleStore.
+func NewMemRuleStore() RuleStore {
+	return &MemRuleStore{
+		rules:  []*Rule{},
+		nextId: 1,
+	}
+}
+
+// Create, see RuleStore interface.
+func (m *MemRuleStore) Create(rule *Rule) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	rule.ID = m.nextId
+	m.nextId++
+	m.rules = append(m.rules, rule)
+	return nil
+}
+
+// List, see RuleStore interface.
+func (m *MemRuleStore) List() ([]*Rule, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	result := make([]*Rule, len(m.rules))
+	copy(result, m.rules)
+	return result, nil
+}
+
+// Update, see RuleStore interface.
+func (m *MemRuleStore) Update(id int64, updated *Rule) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, rule := range m.rules {
+		if rule.ID == id {
+			updated.ID = id
+			m.rules[i] = updated
+			return nil
+		}
+	}
+	return fmt.Errorf("Did not find a Rule with id: %d", id)
+}
+
+// Delete, see RuleStore interface.
+func (m *MemRuleStore) Delete(id int64) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for idx, rule := range m.rules {
+		if rule.ID == id {
+			m.rules = append(m.rules[:idx], m.rules[idx+1:]...)
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// AutoTriager applies the rules in a RuleStore to the untriaged digests in a
+// tile.
+type AutoTriager struct {
+	store     RuleStore
+	expStore  expstorage.ExpectationsStore
+	diffStore diff.DiffStore
+
+	// triaged are the digests, by test name, that BOT_USER has changed. They
+	// are never auto-triaged again, so undoing an auto-triage sticks.
+	triaged map[string]util.StringSet
+
+	// logSeen is the number of triage log entries that have been read into
+	// triaged.
+	logSeen int
+
+	// mutex protects triaged and logSeen, and makes calls to Run sequential.
+	mutex sync.Mutex
+}
+
+// New creates a new AutoTriager.
+func New(store RuleStore, expStore expstorage.ExpectationsStore, diffStore diff.DiffStore) *AutoTriager {
+	return &AutoTriager{
+		store:     store,
+		expStore:  expStore,
+		diffStore: diffStore,
+		triaged:   map[string]util.StringSet{},
+	}
+}
+
+// matcher is a Rule with its query parsed.
+type matcher struct {
+	rule  *Rule
+	query ignore.QueryRule
+}
+
+// closestKey identifies a closest digest lookup.
+type closestKey struct {
+	test   string
+	digest string
+	metric string
+}
+
+// Run marks the untriaged digests in the tile that match a Rule as positive
+// and returns the changes that were made. 'talliesByTest' are the tallies of
+// the digests in the tile by test name.
+//
+// All the changes are made as a single change to the expectations by
+// BOT_USER. Digests that BOT_USER has changed before are skipped, even if
+// they are untriaged again because the change was undone.
+func (a *AutoTriager) Run(tile *tiling.Tile, talliesByTest map[string]tally.Tally) (types.TestExp, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	rules, err := a.store.List()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load auto-triage rules: %s", err)
+	}
+	matchers := make([]*matcher, 0, len(rules))
+	for _, rule := range rules {
+		q, err := url.ParseQuery(rule.Query)
+		if err != nil || len(rule.Thresholds) == 0 {
+			sklog.Errorf("Skipping invalid auto-triage rule %d: %q", rule.ID, rule.Query)
+			continue
+		}
+		matchers = append(matchers, &matcher{
+			rule:  rule,
+			query: ignore.NewQueryRule(q),
+		})
+	}
+	if len(matchers) == 0 {
+		return types.TestExp{}, nil
+	}
+
+	exp, err := a.expStore.Get()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load expectations: %s", err)
+	}
+	if err := a.updateTriaged(); err != nil {
+		return nil, err
+	}
+
+	changes := types.TestExp{}
+	closest := map[closestKey]*digesttools.Closest{}
+	for _, trace := range tile.Traces {
+		gTrace := trace.(*types.GoldenTrace)
+		params := gTrace.Params()
+		test := params[types.PRIMARY_KEY_FIELD]
+		tallies := talliesByTest[test]
+		if tallies == nil {
+			continue
+		}
+		for _, m := range matchers {
+			if !m.query.IsMatch(params) {
+				continue
+			}
+			for _, digest := range gTrace.Values {
+				if digest == types.MISSING_DIGEST || exp.Classification(test, digest) != types.UNTRIAGED || a.triaged[test][digest] {
+					continue
+				}
+				if _, ok := changes[test][digest]; ok {
+					continue
+				}
+				key := closestKey{test: test, digest: digest, metric: m.rule.Thresholds[0].Metric}
+				c, ok := closest[key]
+				if !ok {
+					c = digesttools.ClosestDigest(test, digest, exp, tallies, a.diffStore, types.POSITIVE, key.metric)
+					closest[key] = c
+				}
+				if !withinThresholds(c, m.rule.Thresholds) {
+					continue
+				}
+				sklog.Infof("Auto-triage rule %d marked %s %s positive, closest positive is %s", m.rule.ID, test, digest, c.Digest)
+				if _, ok := changes[test]; !ok {
+					changes[test] = types.TestClassification{}
+				}
+				changes[test][digest] = types.POSITIVE
+			}
+		}
+	}
+
+	if len(changes) > 0 {
+		if err := a.expStore.AddChange(changes, BOT_USER); err != nil {
+			return nil, fmt.Errorf("Failed to store auto-triaged expectations: %s", err)
+		}
+	}
+	return changes, nil
+}
+
+// updateTriaged reads the triage log entries that were added since the last
+// call and adds the digests changed by BOT_USER to a.triaged.
+func (a *AutoTriager) updateTriaged() error {
+	// The log is returned with the most recent change first, so the entries
+	// that haven't been seen yet are at the start.
+	entries, total, err := a.expStore.QueryLog(0, LOG_PAGE_SIZE, true)
+	if err != nil {
+		return fmt.Errorf("Failed to load triage log: %s", err)
+	}
+	if total < a.logSeen {
+		// The log was cleared.
+		a.triaged = map[string]util.StringSet{}
+		a.logSeen = 0
+	}
+	for offset := 0; offset < total-a.logSeen; offset += LOG_PAGE_SIZE {
+		if offset > 0 {
+			if entries, _, err = a.expStore.QueryLog(offset, LOG_PAGE_SIZE, true); err != nil {
+				return fmt.Errorf("Failed to load triage log: %s", err)
+			}
+		}
+		for i, entry := range entries {
+			if offset+i >= total-a.logSeen {
+				break
+			}
+			if entry.Name != BOT_USER {
+				continue
+			}
+			for _, d := range entry.Details {
+				if _, ok := a.triaged[d.TestName]; !ok {
+					a.triaged[d.TestName] = util.StringSet{}
+				}
+				a.triaged[d.TestName][d.Digest] = true
+			}
+		}
+	}
+	a.logSeen = total
+	return nil
+}
+
+// withinThresholds returns true if the closest digest was found and is within
+// all the thresholds. The first threshold is for the metric that was used to
+// find the closest digest.
+func withinThresholds(c *digesttools.Closest, thresholds []Threshold) bool {
+	if c.Digest == "" {
+		return false
+	}
+	for i, t := range thresholds {
+		value := c.Diff
+		if i > 0 {
+			var ok bool
+			if value, ok = c.Diffs[t.Metric]; !ok {
+				return false
+			}
+		}
+		if value > t.Max {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,204 @@
+package autotriage
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/ds"
+	ds_testutil "go.skia.org/infra/go/ds/testutil"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/expstorage"
+	"go.skia.org/infra/golden/go/tally"
+	"go.skia.org/infra/golden/go/types"
+)
+
+// mockDiffStore returns the diffs in 'diffs' for every right digest, keyed
+// by the left digest.
+type mockDiffStore struct {
+	diffs map[string]map[string]float32
+}
+
+func (m mockDiffStore) ImageHandler(urlPrefix string) (http.Handler, error)                   { return nil, nil }
+func (m mockDiffStore) WarmDigests(priority int64, digests []string, sync bool)               {}
+func (m mockDiffStore) WarmDiffs(priority int64, leftDigests []string, rightDigests []string) {}
+func (m mockDiffStore) UnavailableDigests() map[string]*diff.DigestFailure                    { return nil }
+func (m mockDiffStore) PurgeDigests(digests []string, purgeGCS bool) error                    { return nil }
+func (m mockDiffStore) ImageInfo(priority int64, digests []string) (map[string]*diff.ImageInfo, error) {
+	return nil, nil
+}
+
+func (m mockDiffStore) Get(priority int64, dMain string, dRest []string) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	for _, d := range dRest {
+		result[d] = &diff.DiffMetrics{
+			MaxRGBADiffs: []int{1, 1, 1, 0},
+			Diffs:        m.diffs[dMain],
+		}
+	}
+	return result, nil
+}
+
+func TestValidate(t *testing.T) {
+	testutils.SmallTest(t)
+
+	r := NewRule("jon@example.com", "config=gpu", []Threshold{{Metric: diff.METRIC_FUZZY, Max: 0}}, "")
+	assert.NoError(t, r.Validate())
+
+	r.Query = ""
+	assert.Error(t, r.Validate())
+
+	r.Query = "config=gpu"
+	r.Thresholds = nil
+	assert.Error(t, r.Validate())
+
+	r.Thresholds = []Threshold{{Metric: "unknown", Max: 1}}
+	assert.Error(t, r.Validate())
+
+	r.Thresholds = []Threshold{{Metric: diff.METRIC_SSIM, Max: -1}}
+	assert.Error(t, r.Validate())
+}
+
+func TestMemRuleStore(t *testing.T) {
+	testutils.SmallTest(t)
+	testRuleStore(t, NewMemRuleStore())
+}
+
+func TestCloudRuleStore(t *testing.T) {
+	testutils.LargeTest(t)
+
+	cleanup := ds_testutil.InitDatastore(t,
+		ds.AUTO_TRIAGE_RULE,
+		ds.HELPER_RECENT_KEYS)
+	defer cleanup()
+
+	store, err := NewCloudRuleStore(ds.DS)
+	assert.NoError(t, err)
+	testRuleStore(t, store)
+}
+
+func testRuleStore(t *testing.T, store RuleStore) {
+	thresholds := []Threshold{{Metric: diff.METRIC_COMBINED, Max: 0.1}}
+	r1 := NewRule("jon@example.com", "config=gpu", thresholds, "AA noise")
+	r2 := NewRule("jim@example.com", "name=foo", thresholds, "")
+	assert.NoError(t, store.Create(r1))
+	assert.NoError(t, store.Create(r2))
+
+	rules, err := store.List()
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+
+	updated := *r1
+	updated.Note = "an updated rule"
+	assert.NoError(t, store.Update(r1.ID, &updated))
+	rules, err = store.List()
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+	for _, r := range rules {
+		if r.ID == r1.ID {
+			assert.Equal(t, "an updated rule", r.Note)
+		}
+	}
+
+	n, err := store.Delete(r1.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	rules, err = store.List()
+	assert.NoError(t, err)
+	assert.Len(t, rules, 1)
+	assert.Equal(t, r2.ID, rules[0].ID)
+
+	n, err = store.Delete(1000000)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestRun(t *testing.T) {
+	testutils.SmallTest(t)
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+	expStore, _, err := expstorage.NewLocalExpectationsStore(dir, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, expStore.AddChange(types.TestExp{
+		"foo": {"aaa": types.POSITIVE},
+	}, "jon@example.com"))
+
+	diffStore := mockDiffStore{
+		diffs: map[string]map[string]float32{
+			"bbb": {diff.METRIC_COMBINED: 0.01, diff.METRIC_SSIM: 0.001},
+			"ccc": {diff.METRIC_COMBINED: 0.5, diff.METRIC_SSIM: 0.2},
+			"ddd": {diff.METRIC_COMBINED: 0.01, diff.METRIC_SSIM: 0.001},
+			"eee": {diff.METRIC_COMBINED: 0.01, diff.METRIC_SSIM: 0.1},
+		},
+	}
+
+	tile := &tiling.Tile{
+		Traces: map[string]tiling.Trace{
+			"gpu": &types.GoldenTrace{
+				Params_: map[string]string{types.PRIMARY_KEY_FIELD: "foo", "config": "gpu"},
+				Values:  []string{"aaa", "bbb", "ccc", types.MISSING_DIGEST, "eee"},
+			},
+			"8888": &types.GoldenTrace{
+				Params_: map[string]string{types.PRIMARY_KEY_FIELD: "foo", "config": "8888"},
+				Values:  []string{"aaa", "ddd"},
+			},
+		},
+	}
+	tallies := map[string]tally.Tally{
+		"foo": {"aaa": 2, "bbb": 1, "ccc": 1, "ddd": 1, "eee": 1},
+	}
+
+	store := NewMemRuleStore()
+	a := New(store, expStore, diffStore)
+
+	// No rules means no changes.
+	changes, err := a.Run(tile, tallies)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	assert.NoError(t, store.Create(NewRule("jon@example.com", "config=gpu", []Threshold{
+		{Metric: diff.METRIC_COMBINED, Max: 0.05},
+		{Metric: diff.METRIC_SSIM, Max: 0.01},
+	}, "")))
+
+	// Only 'bbb' is close enough on both metrics and produced by a trace that
+	// matches the rule.
+	changes, err = a.Run(tile, tallies)
+	assert.NoError(t, err)
+	assert.Equal(t, types.TestExp{"foo": {"bbb": types.POSITIVE}}, changes)
+	exp, err := expStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, types.POSITIVE, exp.Classification("foo", "bbb"))
+	assert.Equal(t, types.UNTRIAGED, exp.Classification("foo", "ccc"))
+	assert.Equal(t, types.UNTRIAGED, exp.Classification("foo", "ddd"))
+	assert.Equal(t, types.UNTRIAGED, exp.Classification("foo", "eee"))
+
+	// Running again finds nothing new to triage.
+	changes, err = a.Run(tile, tallies)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// Undoing the auto-triage sticks, since 'bbb' isn't triaged again.
+	log, _, err := expStore.QueryLog(0, 1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, BOT_USER, log[0].Name)
+	changeID, err := strconv.ParseInt(log[0].ID, 10, 64)
+	assert.NoError(t, err)
+	_, err = expStore.UndoChange(changeID, "jon@example.com")
+	assert.NoError(t, err)
+	changes, err = a.Run(tile, tallies)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+	exp, err = expStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, types.UNTRIAGED, exp.Classification("foo", "bbb"))
+
+	// The same holds for a new AutoTriager, which has to read the whole log.
+	changes, err = New(store, expStore, diffStore).Run(tile, tallies)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+}
@@ -0,0 +1,126 @@
+package autotriage
+
+import (
+	"context"
+	"sort"
+
+	"cloud.google.com/go/datastore"
+	"go.skia.org/infra/go/ds"
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/golden/go/dsutil"
+	"golang.org/x/sync/errgroup"
+)
+
+// cloudRuleStore implements the RuleStore interface.
+type cloudRuleStore struct {
+	client         *datastore.Client
+	recentKeysList *dsutil.RecentKeysList
+}
+
+// NewCloudRuleStore returns a RuleStore instance that is backed by Cloud
+// Datastore. The rules are stored next to the ignore rules.
+func NewCloudRuleStore(client *datastore.Client) (RuleStore, error) {
+	if client == nil {
+		return nil, sklog.FmtErrorf("Received nil for datastore client.")
+	}
+
+	containerKey := ds.NewKey(ds.HELPER_RECENT_KEYS)
+	containerKey.Name = "autotriage:recent-keys"
+
+	return &cloudRuleStore{
+		client:         client,
+		recentKeysList: dsutil.NewRecentKeysList(client, containerKey, dsutil.DefaultConsistencyDelta),
+	}, nil
+}
+
+// Create implements the RuleStore interface.
+func (c *cloudRuleStore) Create(rule *Rule) error {
+	createFn := func(tx *datastore.Transaction) error {
+		key := dsutil.TimeSortableKey(ds.AUTO_TRIAGE_RULE, 0)
+		rule.ID = key.ID
+
+		if _, err := tx.Put(key, rule); err != nil {
+			return err
+		}
+		return c.recentKeysList.Add(tx, key)
+	}
+
+	_, err := c.client.RunInTransaction(context.TODO(), createFn)
+	return err
+}
+
+// List implements the RuleStore interface.
+func (c *cloudRuleStore) List() ([]*Rule, error) {
+	ctx := context.TODO()
+	var egroup errgroup.Group
+	var queriedKeys []*datastore.Key
+	egroup.Go(func() error {
+		query := ds.NewQuery(ds.AUTO_TRIAGE_RULE).KeysOnly()
+		var err error
+		queriedKeys, err = c.client.GetAll(ctx, query, nil)
+		return err
+	})
+
+	var recently *dsutil.Recently
+	egroup.Go(func() error {
+		var err error
+		recently, err = c.recentKeysList.GetRecent()
+		return err
+	})
+
+	if err := egroup.Wait(); err != nil {
+		return nil, sklog.FmtErrorf("Error getting keys of auto-triage rules: %s", err)
+	}
+
+	allKeys := recently.Combine(queriedKeys)
+	if len(allKeys) == 0 {
+		return []*Rule{}, nil
+	}
+
+	ret := make([]*Rule, len(allKeys))
+	if err := c.client.GetMulti(ctx, allKeys, ret); err != nil {
+		return nil, err
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return ret, nil
+}
+
+// Update implements the RuleStore interface.
+func (c *cloudRuleStore) Update(id int64, rule *Rule) error {
+	key := ds.NewKey(ds.AUTO_TRIAGE_RULE)
+	key.ID = id
+	rule.ID = id
+	_, err := c.client.Mutate(context.TODO(), datastore.NewUpdate(key, rule))
+	return err
+}
+
+// Delete implements the RuleStore interface.
+func (c *cloudRuleStore) Delete(id int64) (int, error) {
+	if id <= 0 {
+		return 0, sklog.FmtErrorf("Given id does not exist: %d", id)
+	}
+
+	deleteFn := func(tx *datastore.Transaction) error {
+		key := ds.NewKey(ds.AUTO_TRIAGE_RULE)
+		key.ID = id
+
+		rule := &Rule{}
+		if err := tx.Get(key, rule); err != nil {
+			return err
+		}
+		if err := tx.Delete(key); err != nil {
+			return err
+		}
+		return c.recentKeysList.Delete(tx, key)
+	}
+
+	_, err := c.client.RunInTransaction(context.TODO(), deleteFn)
+	if err != nil {
+		// Don't report an error if the item did not exist.
+		if err == datastore.ErrNoSuchEntity {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return 1, nil
+}
@@ -27,6 +27,10 @@ type CommitableBaseLine struct {
 
 	// Issue indicates the Gerrit issue of this baseline. 0 indicates the master branch.
 	Issue int64
+
+	// Branch is the name of the expectations branch of this baseline. It is
+	// empty for the master branch and Gerrit issues.
+	Branch string `json:"branch,omitempty"`
 }
 
 // GetBaselineForMaster calculates the master baseline for the given configuration of
@@ -61,6 +65,37 @@ func GetBaselineForMaster(exps types.Expectations, tile *tiling.Tile) *Commitabl
 	return ret
 }
 
+// GetBaselineForBranch calculates the baseline of an expectations branch for
+// the given tile. exps are the expectations of the branch, i.e. the master
+// expectations with the branch overrides applied.
+func GetBaselineForBranch(branch string, exps types.Expectations, tile *tiling.Tile) *CommitableBaseLine {
+	ret := GetBaselineForMaster(exps, tile)
+	ret.Branch = branch
+	return ret
+}
+
+// FilterByCorpus returns a copy of the given baseline that only contains the
+// tests that have traces in the given corpus in the tile.
+func FilterByCorpus(baseLine *CommitableBaseLine, tile *tiling.Tile, corpus string) *CommitableBaseLine {
+	corpusTests := map[string]bool{}
+	for _, trace := range tile.Traces {
+		params := trace.Params()
+		if params[types.CORPUS_FIELD] == corpus {
+			corpusTests[params[types.PRIMARY_KEY_FIELD]] = true
+		}
+	}
+
+	ret := &CommitableBaseLine{}
+	*ret = *baseLine
+	ret.Baseline = types.TestExp{}
+	for testName, digests := range baseLine.Baseline {
+		if corpusTests[testName] {
+			ret.Baseline[testName] = digests
+		}
+	}
+	return ret
+}
+
 // GetBaselineForIssue returns the baseline for the given issue. This baseline
 // contains all triaged digests that are not in the master tile.
 func GetBaselineForIssue(issueID int64, tryjobs []*tryjobstore.Tryjob, tryjobResults [][]*tryjobstore.TryjobResult, exp types.Expectations, commits []*tiling.Commit, talliesByTest map[string]tally.Tally) *CommitableBaseLine {
@@ -0,0 +1,49 @@
+package baseline
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/golden/go/types"
+)
+
+func TestBranchAndCorpusBaseline(t *testing.T) {
+	testutils.SmallTest(t)
+
+	tile := tiling.NewTile()
+	tile.Commits = []*tiling.Commit{{Hash: "aaa", CommitTime: 1}, {Hash: "bbb", CommitTime: 2}}
+	addTrace := func(id, test, corpus string, values ...string) {
+		tile.Traces[id] = &types.GoldenTrace{
+			Values: values,
+			Params_: map[string]string{
+				types.PRIMARY_KEY_FIELD: test,
+				types.CORPUS_FIELD:      corpus,
+			},
+		}
+	}
+	addTrace("a", "foo", "gm", "d1", "d2")
+	addTrace("b", "bar", "image", "d3", "d3")
+
+	// The branch marks d2 as positive, which is untriaged on master.
+	exps := types.NewExpectations(types.TestExp{
+		"foo": {"d1": types.POSITIVE, "d2": types.POSITIVE},
+		"bar": {"d3": types.POSITIVE},
+	})
+	baseLine := GetBaselineForBranch("release-1", exps, tile)
+	assert.Equal(t, "release-1", baseLine.Branch)
+	assert.Equal(t, types.TestExp{
+		"foo": {"d2": types.POSITIVE},
+		"bar": {"d3": types.POSITIVE},
+	}, baseLine.Baseline)
+	assert.Equal(t, "bbb", baseLine.EndCommit.Hash)
+
+	filtered := FilterByCorpus(baseLine, tile, "gm")
+	assert.Equal(t, types.TestExp{"foo": {"d2": types.POSITIVE}}, filtered.Baseline)
+	assert.Equal(t, "release-1", filtered.Branch)
+	assert.Equal(t, 2, len(baseLine.Baseline))
+
+	filtered = FilterByCorpus(baseLine, tile, "unknown")
+	assert.Equal(t, types.TestExp{}, filtered.Baseline)
+}
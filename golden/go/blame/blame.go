@@ -7,6 +7,7 @@ import (
 	"go.skia.org/infra/go/tiling"
 	"go.skia.org/infra/go/timer"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/digeststore"
 	"go.skia.org/infra/golden/go/storage"
 	"go.skia.org/infra/golden/go/types"
 )
@@ -40,6 +41,26 @@ type BlameDistribution struct {
 	Old bool `json:"old"`
 }
 
+// DigestBlame contains the commits that likely introduced a digest. Unlike
+// BlameDistribution it is not limited to the current tile, since the blame of
+// a digest is persisted while the digest is part of the tile.
+type DigestBlame struct {
+	Test   string `json:"test"`
+	Digest string `json:"digest"`
+
+	// FirstSeen is the timestamp of the first occurrence of the digest and
+	// FirstCommit is the commit at which it was first seen, if known.
+	FirstSeen   int64          `json:"firstSeen"`
+	FirstCommit *tiling.Commit `json:"firstCommit"`
+
+	// Commits are the commits that likely introduced the digest. It is empty
+	// if we are not able to determine blame.
+	Commits []*tiling.Commit `json:"commits"`
+
+	// Old indicates whether the digest was first seen prior to the current tile.
+	Old bool `json:"old"`
+}
+
 // WeightedBlame combines an authors name with a probabily that she
 // is on a blamelist this is aggregated over the digests of a test.
 type WeightedBlame struct {
@@ -111,6 +132,54 @@ func (b *Blamer) GetBlame(testName string, digest string, commits []*tiling.Comm
 	}
 }
 
+// GetDigestBlame returns the commits that likely introduced the given
+// test name/digest pair. If the digest was first seen within the current tile
+// the blame is calculated from the tile, otherwise the blame that was
+// persisted while the digest was part of an earlier tile is returned. The
+// persisted blame is also returned if it starts earlier than the blame
+// calculated from the tile.
+func (b *Blamer) GetDigestBlame(testName string, digest string) (*DigestBlame, error) {
+	blameLists, commits := b.GetAllBlameLists()
+	ret := &DigestBlame{
+		Test:    testName,
+		Digest:  digest,
+		Commits: []*tiling.Commit{},
+	}
+
+	digestInfo, ok, err := b.storages.DigestStore.Get(testName, digest)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		ret.FirstSeen = digestInfo.First
+		ret.FirstCommit = digestInfo.FirstCommit
+		ret.Old = (len(commits) > 0) && (digestInfo.First < commits[0].CommitTime)
+		if len(digestInfo.Blame) > 0 {
+			ret.Commits = digestInfo.Blame
+		}
+	}
+
+	if blameDistribution := blameLists[testName][digest]; (blameDistribution != nil) && !blameDistribution.Old {
+		// The persisted blame wins if it starts earlier, i.e. if the start of
+		// the blamed range has already moved out of the tile.
+		if culprits := b.getCulprits(blameDistribution, commits); digeststore.BlameStartsNoLater(culprits, ret.Commits) {
+			ret.Commits = culprits
+		}
+	}
+	return ret, nil
+}
+
+// getCulprits returns the commits that most likely caused the digest of
+// the given blame distribution.
+func (b *Blamer) getCulprits(blameDistribution *BlameDistribution, commits []*tiling.Commit) []*tiling.Commit {
+	commitIndices, _ := b.getBlame(blameDistribution, commits, commits)
+	ret := make([]*tiling.Commit, 0, len(commitIndices))
+	for _, commitIdx := range commitIndices {
+		ret = append(ret, commits[commitIdx])
+	}
+	return ret
+}
+
 func (b *Blamer) getBlame(blameDistribution *BlameDistribution, blameCommits, commits []*tiling.Commit) ([]int, int) {
 	if (blameDistribution == nil) || (len(blameDistribution.Freq) == 0) {
 		return []int{}, 0
@@ -154,6 +223,10 @@ func (b *Blamer) Calculate(tile *tiling.Tile) error {
 
 	// blameRange stores the candidate ranges for a testName/digest pair.
 	blameRange := map[string]map[string][][]int{}
+
+	// digestInfos stores the info from the digest store for each
+	// testName/digest pair.
+	digestInfos := map[string]map[string]*digeststore.DigestInfo{}
 	firstCommit := tile.Commits[0]
 	tileLen := tile.LastCommitIndex() + 1
 	ret := map[string]map[string]*BlameDistribution{}
@@ -192,6 +265,11 @@ func (b *Blamer) Calculate(tile *tiling.Tile) error {
 					return err
 				}
 
+				if _, ok := digestInfos[testName]; !ok {
+					digestInfos[testName] = map[string]*digeststore.DigestInfo{}
+				}
+				digestInfos[testName][digest] = digestInfo
+
 				// Check if the digest was first seen outside the current tile.
 				isOld := digestInfo.First < firstCommit.CommitTime
 				commitRange := []int{startIdx, endIdx}
@@ -217,6 +295,7 @@ func (b *Blamer) Calculate(tile *tiling.Tile) error {
 	}
 
 	commits := tile.Commits[:tileLen]
+	persistBlame := []*digeststore.DigestInfo{}
 	for testName, digests := range blameRange {
 		for digest, commitRanges := range digests {
 			start := blameStart[testName][digest]
@@ -239,6 +318,22 @@ func (b *Blamer) Calculate(tile *tiling.Tile) error {
 			}
 
 			ret[testName][digest].Freq = freq
+
+			// Persist the blame of digests that were first seen in this tile, so
+			// it is still available after they were first seen before the tile.
+			// Only digests whose stored blame changed are written.
+			if !ret[testName][digest].Old {
+				digestInfo := digestInfos[testName][digest]
+				if (digestInfo.Exception == "") && digestInfo.UpdateBlame(commits[end], b.getCulprits(ret[testName][digest], commits)) {
+					persistBlame = append(persistBlame, digestInfo)
+				}
+			}
+		}
+	}
+
+	if len(persistBlame) > 0 {
+		if err := b.storages.DigestStore.Update(persistBlame); err != nil {
+			return err
 		}
 	}
 
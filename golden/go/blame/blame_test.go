@@ -14,6 +14,7 @@ import (
 	"go.skia.org/infra/go/testutils"
 	"go.skia.org/infra/go/tiling"
 	tracedb "go.skia.org/infra/go/trace/db"
+	"go.skia.org/infra/golden/go/digeststore"
 	"go.skia.org/infra/golden/go/expstorage"
 	"go.skia.org/infra/golden/go/mocks"
 	"go.skia.org/infra/golden/go/storage"
@@ -172,6 +173,89 @@ func TestBlamerWithSyntheticData(t *testing.T) {
 	assert.Equal(t, &BlameDistribution{Freq: []int{}}, blamer.GetBlame("bar", DI_9, commits[0:2]))
 }
 
+func TestPersistentBlame(t *testing.T) {
+	testutils.SmallTest(t)
+
+	eventBus := eventbus.New()
+	storages := &storage.Storage{
+		ExpectationsStore: expstorage.NewMemExpectationsStore(eventBus),
+		DigestStore:       digeststore.NewMemDigestStore(),
+		EventBus:          eventBus,
+	}
+	assert.NoError(t, storages.ExpectationsStore.AddChange(types.TestExp{"foo": {"aaa": types.POSITIVE}}, ""))
+
+	commits := make([]*tiling.Commit, 7)
+	for i := range commits {
+		commits[i] = &tiling.Commit{Hash: fmt.Sprintf("c%d", i), Author: "jon@example.com", CommitTime: int64(1000 + i)}
+	}
+	makeTile := func(tileCommits []*tiling.Commit, values ...string) *tiling.Tile {
+		tile := tiling.NewTile()
+		tile.Commits = tileCommits
+		tile.Traces["t1"] = &types.GoldenTrace{
+			Values:  values,
+			Params_: map[string]string{types.PRIMARY_KEY_FIELD: "foo"},
+		}
+		return tile
+	}
+
+	// The untriaged digest 'bbb' is introduced at the third commit.
+	blamer := New(storages)
+	assert.NoError(t, blamer.Calculate(makeTile(commits[0:4], "aaa", "aaa", "bbb", "bbb")))
+	digestBlame, err := blamer.GetDigestBlame("foo", "bbb")
+	assert.NoError(t, err)
+	assert.Equal(t, []*tiling.Commit{commits[2]}, digestBlame.Commits)
+	assert.Equal(t, commits[2], digestBlame.FirstCommit)
+	assert.False(t, digestBlame.Old)
+
+	// After the tile moved on, the digest was first seen before the tile, but
+	// the persisted blame is still available.
+	assert.NoError(t, blamer.Calculate(makeTile(commits[3:7], "bbb", "bbb", "bbb", "bbb")))
+	assert.True(t, blamer.GetBlame("foo", "bbb", commits[3:7]).Old)
+	digestBlame, err = blamer.GetDigestBlame("foo", "bbb")
+	assert.NoError(t, err)
+	assert.Equal(t, []*tiling.Commit{commits[2]}, digestBlame.Commits)
+	assert.Equal(t, commits[2], digestBlame.FirstCommit)
+	assert.Equal(t, commits[2].CommitTime, digestBlame.FirstSeen)
+	assert.True(t, digestBlame.Old)
+
+	// The untriaged digest 'ccc' is introduced after missing values, so its
+	// blame narrows once the last commit with 'aaa' is not in the tile anymore.
+	// The first blame is kept.
+	digestStore := &countingDigestStore{DigestStore: storages.DigestStore}
+	storages.DigestStore = digestStore
+	blamer = New(storages)
+	assert.NoError(t, blamer.Calculate(makeTile(commits[0:4], "aaa", types.MISSING_DIGEST, types.MISSING_DIGEST, "ccc")))
+	assert.Equal(t, 2, digestStore.updates)
+	digestBlame, err = blamer.GetDigestBlame("foo", "ccc")
+	assert.NoError(t, err)
+	assert.Equal(t, commits[1:4], digestBlame.Commits)
+	assert.NoError(t, blamer.Calculate(makeTile(commits[2:6], types.MISSING_DIGEST, "ccc", "ccc", "ccc")))
+	digestBlame, err = blamer.GetDigestBlame("foo", "ccc")
+	assert.NoError(t, err)
+	assert.Equal(t, commits[1:4], digestBlame.Commits)
+	assert.False(t, digestBlame.Old)
+
+	// The blame of 'ccc' didn't change, so it wasn't written again.
+	assert.Equal(t, 2, digestStore.updates)
+
+	// Unknown digests have no blame.
+	digestBlame, err = blamer.GetDigestBlame("foo", "ddd")
+	assert.NoError(t, err)
+	assert.Equal(t, []*tiling.Commit{}, digestBlame.Commits)
+	assert.Nil(t, digestBlame.FirstCommit)
+}
+
+// countingDigestStore counts the calls to Update.
+type countingDigestStore struct {
+	digeststore.DigestStore
+	updates int
+}
+
+func (c *countingDigestStore) Update(digestInfos []*digeststore.DigestInfo) error {
+	c.updates++
+	return c.DigestStore.Update(digestInfos)
+}
+
 func BenchmarkBlamer(b *testing.B) {
 	ctx := context.Background()
 	tileBuilder := mocks.GetTileBuilderFromEnv(b, ctx)
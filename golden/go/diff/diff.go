@@ -76,6 +76,17 @@ type DiffMetrics struct {
 	Diffs map[string]float32 `json:"diffs"`
 }
 
+// Metric returns the value of the given diff metric. It returns false if the
+// value is unknown, e.g. because the DiffMetrics were calculated before the
+// metric was added. Unknown values must not be treated as 0.
+func (d *DiffMetrics) Metric(id string) (float32, bool) {
+	if d == nil {
+		return 0, false
+	}
+	v, ok := d.Diffs[id]
+	return v, ok
+}
+
 // Diff error to indicate different error conditions during diffing.
 type DiffErr string
 
@@ -159,19 +170,23 @@ type DiffStore interface {
 	// purge the digests image from Google storage, forcing that the digest
 	// be re-uploaded by the build bots.
 	PurgeDigests(digests []string, purgeGCS bool) error
+
+	// ImageInfo returns the format and bit depth of the images of the given
+	// digests as map[digest]*ImageInfo.
+	ImageInfo(priority int64, digests []string) (map[string]*ImageInfo, error)
 }
 
-// OpenNRGBA reads an NRGBA image from the given reader.
-// If the underlying image is not NRGBA it will be converted.
+// OpenNRGBA reads an NRGBA image from the given reader. See DecodeImg for
+// the supported formats. If the underlying image is not NRGBA it will be converted.
 func OpenNRGBA(reader io.Reader) (*image.NRGBA, error) {
-	im, err := png.Decode(reader)
+	im, _, err := DecodeImg(reader)
 	if err != nil {
 		return nil, err
 	}
 	return GetNRGBA(im), nil
 }
 
-// OpenNRGBAFromFile opens the given file path to a PNG file and returns the image as image.NRGBA.
+// OpenNRGBAFromFile opens the given file path to an image file and returns the image as image.NRGBA.
 func OpenNRGBAFromFile(fileName string) (*image.NRGBA, error) {
 	f, err := os.Open(fileName)
 	if err != nil {
@@ -240,6 +255,45 @@ func diffColors(color1, color2 color.Color, maxRGBADiffs []int) color.Color {
 	return PixelMatchColor
 }
 
+// scaleDelta16 scales the difference between two 16-bit channel values to
+// the range of 8-bit values. Any non-zero difference results in a value > 0
+// so that differences below 8-bit precision are not lost.
+func scaleDelta16(delta int) int {
+	return (delta + 256) / 257
+}
+
+// diffPix16 compares the pixels of two NRGBA64 images with identical bounds
+// at their native precision. It writes the diff colors to resultPix, which are
+// the pixels of an NRGBA image, updates maxRGBADiffs and returns the number of
+// different pixels. The channel differences are scaled to 8-bit values.
+func diffPix16(p1, p2, resultPix []uint8, maxRGBADiffs []int) int {
+	numDiffPixels := 0
+	deltas := make([]int, 4)
+
+	// Each pixel consists of four big-endian uint16 values: R, G, B, A.
+	for i := 0; i < len(p1); i += 8 {
+		if *(*uint64)(unsafe.Pointer(&p1[i])) == *(*uint64)(unsafe.Pointer(&p2[i])) {
+			continue
+		}
+
+		numDiffPixels++
+		for c := 0; c < 4; c++ {
+			v1 := int(p1[i+2*c])<<8 | int(p1[i+2*c+1])
+			v2 := int(p2[i+2*c])<<8 | int(p2[i+2*c+1])
+			deltas[c] = scaleDelta16(util.AbsInt(v1 - v2))
+			maxRGBADiffs[c] = util.MaxInt(deltas[c], maxRGBADiffs[c])
+		}
+
+		dr, dg, db, da := deltas[0], deltas[1], deltas[2], deltas[3]
+		if dr+dg+db > 0 {
+			copy(resultPix[i/2:], PixelDiffColor[deltaOffset(dr+dg+db+da)])
+		} else {
+			copy(resultPix[i/2:], PixelAlphaDiffColor[deltaOffset(da)])
+		}
+	}
+	return numDiffPixels
+}
+
 // recode creates a new NRGBA image from the given image.
 func recode(img image.Image) *image.NRGBA {
 	ret := image.NewNRGBA(img.Bounds())
@@ -273,7 +327,8 @@ func GetNRGBA(img image.Image) *image.NRGBA {
 }
 
 // PixelDiff is a utility function that calculates the DiffMetrics and the image of the
-// difference for the provided images.
+// difference for the provided images. If either of the images has 16 bits per
+// channel the images are compared at that precision.
 func PixelDiff(img1, img2 image.Image) (*DiffMetrics, *image.NRGBA) {
 
 	img1Bounds := img1.Bounds()
@@ -296,6 +351,18 @@ func PixelDiff(img1, img2 image.Image) (*DiffMetrics, *image.NRGBA) {
 	numDiffPixels := totalPixels
 	maxRGBADiffs := make([]int, 4)
 
+	// High bit depth images with the same bounds are compared without
+	// quantizing them to 8 bits per channel.
+	highBitDepth := isHighBitDepth(img1) || isHighBitDepth(img2)
+	if img1Bounds.Eq(img2Bounds) && highBitDepth {
+		numDiffPixels = diffPix16(GetNRGBA64(img1).Pix, GetNRGBA64(img2).Pix, resultImg.Pix, maxRGBADiffs)
+		return &DiffMetrics{
+			NumDiffPixels:    numDiffPixels,
+			PixelDiffPercent: GetPixelDiffPercent(numDiffPixels, totalPixels),
+			MaxRGBADiffs:     maxRGBADiffs,
+			DimDiffer:        false}, resultImg
+	}
+
 	// Pix is a []uint8 rotating through R, G, B, A, R, G, B, A, ...
 	p1 := GetNRGBA(img1).Pix
 	p2 := GetNRGBA(img2).Pix
@@ -0,0 +1,101 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	"io"
+	"os"
+
+	"go.skia.org/infra/go/util"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// Names of the image formats that can be decoded. They match the names
+	// the decoders are registered with in the image package.
+	FORMAT_PNG  = "png"
+	FORMAT_JPEG = "jpeg"
+	FORMAT_WEBP = "webp"
+
+	// BIT_DEPTH_8 and BIT_DEPTH_16 are the supported number of bits per channel.
+	BIT_DEPTH_8  = 8
+	BIT_DEPTH_16 = 16
+
+	// MAX_IMAGE_PIXELS is the largest number of pixels of an image that will be
+	// decoded. A 16-bit image of that size takes up 256 MiB in memory.
+	MAX_IMAGE_PIXELS = 1 << 25
+)
+
+// ImageInfo describes how the image of a digest was encoded.
+type ImageInfo struct {
+	// Format is the format of the encoded image, e.g. FORMAT_PNG.
+	Format string `json:"format"`
+
+	// BitDepth is the number of bits per channel of the decoded image.
+	BitDepth int `json:"bitDepth"`
+}
+
+// DecodeImg decodes a PNG, JPEG or WebP image from the given reader. The image
+// is returned in the color model it was encoded with, i.e. 16-bit PNGs are not
+// quantized to 8 bits per channel. Images with more than MAX_IMAGE_PIXELS
+// pixels are rejected before their pixels are decoded.
+func DecodeImg(reader io.Reader) (image.Image, *ImageInfo, error) {
+	// Keep the bytes read while decoding the header, so they can be decoded
+	// again together with the pixels.
+	var header bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(reader, &header))
+	if err != nil {
+		return nil, nil, err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MAX_IMAGE_PIXELS {
+		return nil, nil, fmt.Errorf("Image of size %dx%d exceeds the maximum of %d pixels.", cfg.Width, cfg.Height, MAX_IMAGE_PIXELS)
+	}
+
+	img, format, err := image.Decode(io.MultiReader(&header, reader))
+	if err != nil {
+		return nil, nil, err
+	}
+	return img, &ImageInfo{Format: format, BitDepth: BitDepth(img)}, nil
+}
+
+// OpenImgFromFile decodes the image in the given file. See DecodeImg.
+func OpenImgFromFile(fileName string) (image.Image, *ImageInfo, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer util.Close(f)
+
+	return DecodeImg(f)
+}
+
+// BitDepth returns the number of bits per channel of the given image.
+func BitDepth(img image.Image) int {
+	if isHighBitDepth(img) {
+		return BIT_DEPTH_16
+	}
+	return BIT_DEPTH_8
+}
+
+// isHighBitDepth returns true if the given image has more than 8 bits per channel.
+func isHighBitDepth(img image.Image) bool {
+	switch img.(type) {
+	case *image.NRGBA64, *image.RGBA64, *image.Gray16:
+		return true
+	}
+	return false
+}
+
+// GetNRGBA64 converts the image to an *image.NRGBA64 without losing precision.
+// The Pix field of the returned image has no padding between the rows.
+func GetNRGBA64(img image.Image) *image.NRGBA64 {
+	if t, ok := img.(*image.NRGBA64); ok && (t.Stride == 8*t.Rect.Dx()) {
+		return t
+	}
+	ret := image.NewNRGBA64(img.Bounds())
+	draw.Draw(ret, img.Bounds(), img, img.Bounds().Min, draw.Src)
+	return ret
+}
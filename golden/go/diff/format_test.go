@@ -0,0 +1,116 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+)
+
+func TestDecodeImg(t *testing.T) {
+	testutils.SmallTest(t)
+
+	img8 := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	img16 := image.NewNRGBA64(image.Rect(0, 0, 4, 4))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			img8.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 50), G: uint8(y * 50), B: 10, A: 0xff})
+			img16.SetNRGBA64(x, y, color.NRGBA64{R: uint16(x * 5000), G: uint16(y * 5000), B: 1001, A: 0xffff})
+		}
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img16))
+	decoded, info, err := DecodeImg(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, &ImageInfo{Format: FORMAT_PNG, BitDepth: BIT_DEPTH_16}, info)
+	assert.Equal(t, img16, GetNRGBA64(decoded))
+
+	buf.Reset()
+	assert.NoError(t, png.Encode(&buf, img8))
+	_, info, err = DecodeImg(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, &ImageInfo{Format: FORMAT_PNG, BitDepth: BIT_DEPTH_8}, info)
+
+	buf.Reset()
+	assert.NoError(t, jpeg.Encode(&buf, img8, nil))
+	decoded, info, err = DecodeImg(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, &ImageInfo{Format: FORMAT_JPEG, BitDepth: BIT_DEPTH_8}, info)
+	assert.Equal(t, img8.Bounds(), decoded.Bounds())
+
+	_, _, err = DecodeImg(bytes.NewBufferString("not an image"))
+	assert.Error(t, err)
+}
+
+func TestPixelDiffHighBitDepth(t *testing.T) {
+	testutils.SmallTest(t)
+
+	one := image.NewNRGBA64(image.Rect(0, 0, 4, 4))
+	two := image.NewNRGBA64(image.Rect(0, 0, 4, 4))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			c := color.NRGBA64{R: 0x8000, G: 0x4000, B: 0x2000, A: 0xffff}
+			one.SetNRGBA64(x, y, c)
+			two.SetNRGBA64(x, y, c)
+		}
+	}
+
+	// Change one pixel below and one above 8-bit precision.
+	two.SetNRGBA64(1, 1, color.NRGBA64{R: 0x8001, G: 0x4000, B: 0x2000, A: 0xffff})
+	two.SetNRGBA64(2, 3, color.NRGBA64{R: 0x8000, G: 0x4000, B: 0x2000 + 10*257, A: 0xffff})
+
+	// Quantized to 8 bits per channel the first difference disappears.
+	dm8, _ := PixelDiff(GetNRGBA(one), GetNRGBA(two))
+	assert.Equal(t, 1, dm8.NumDiffPixels)
+
+	dm, diffImg := PixelDiff(one, two)
+	assert.Equal(t, &DiffMetrics{
+		NumDiffPixels:    2,
+		PixelDiffPercent: 12.5,
+		MaxRGBADiffs:     []int{1, 0, 10, 0},
+		DimDiffer:        false,
+	}, dm)
+	assert.Equal(t, color.NRGBA{}, diffImg.NRGBAAt(0, 0))
+	assert.NotEqual(t, color.NRGBA{}, diffImg.NRGBAAt(1, 1))
+	assert.NotEqual(t, color.NRGBA{}, diffImg.NRGBAAt(2, 3))
+}
+
+// pngHeader returns the signature and the IHDR chunk of an 8-bit RGBA PNG
+// with the given size, i.e. enough to decode the image config.
+func pngHeader(w, h uint32) []byte {
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], w)
+	binary.BigEndian.PutUint32(ihdr[8:], h)
+	ihdr[12] = 8 // Bit depth.
+	ihdr[13] = 6 // Color type RGBA.
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)-4))
+	buf.Write(ihdr)
+	_ = binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(ihdr))
+	return buf.Bytes()
+}
+
+func TestDecodeImgMaxPixels(t *testing.T) {
+	testutils.SmallTest(t)
+
+	// Images that are too large are rejected based on their header alone.
+	_, _, err := DecodeImg(bytes.NewReader(pngHeader(1<<16, 1<<16)))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeds the maximum")
+
+	// An image within the limit fails later because its pixels are missing.
+	_, _, err = DecodeImg(bytes.NewReader(pngHeader(16, 16)))
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "exceeds the maximum")
+}
@@ -1,6 +1,7 @@
 package diff
 
 import (
+	"fmt"
 	"image"
 	"math"
 )
@@ -9,16 +10,28 @@ const (
 	METRIC_COMBINED = "combined"
 	METRIC_PERCENT  = "percent"
 	METRIC_PIXEL    = "pixel"
+	METRIC_SSIM     = "ssim"
+	METRIC_DELTA_E  = "deltae"
+	METRIC_FUZZY    = "fuzzy"
 )
 
+// METRICS_VERSION must be incremented whenever the way the diff metrics are
+// calculated changes, so that stored diff metrics are recalculated. See
+// Config.Version. Version 3 compares 16-bit images at their native precision
+// instead of quantizing them to 8 bits per channel.
+const METRICS_VERSION = 3
+
 // MetricsFn is the signature a custom diff metric has to implmente.
-type MetricFn func(*DiffMetrics, *image.NRGBA, *image.NRGBA) float32
+type MetricFn func(*Config, *DiffMetrics, *image.NRGBA, *image.NRGBA) float32
 
 // metrics contains the custom diff metrics.
 var metrics = map[string]MetricFn{
 	METRIC_COMBINED: combinedDiffMetric,
 	METRIC_PERCENT:  percentDiffMetric,
 	METRIC_PIXEL:    pixelDiffMetric,
+	METRIC_SSIM:     ssimDiffMetric,
+	METRIC_DELTA_E:  deltaEDiffMetric,
+	METRIC_FUZZY:    fuzzyDiffMetric,
 }
 
 // diffMetricIds contains the ids of all diff metrics.
@@ -37,24 +50,57 @@ func GetDiffMetricIDs() []string {
 	return diffMetricIds
 }
 
-// DefaultDiffFn implements the DiffFn function type. Calculates the basic
-// image difference along with custom diff metrics.
-func DefaultDiffFn(leftImg *image.NRGBA, rightImg *image.NRGBA) (interface{}, *image.NRGBA) {
+// Config contains the parameters of the custom diff metrics.
+type Config struct {
+	// FuzzyMaxChannelDelta is the largest difference in any RGBA channel
+	// that two pixels can have and still be considered the same by the fuzzy
+	// metric.
+	FuzzyMaxChannelDelta int
+
+	// FuzzyMaxDiffPixels is the number of pixels that can differ by more than
+	// FuzzyMaxChannelDelta and still have the images be a fuzzy match.
+	FuzzyMaxDiffPixels int
+}
+
+// DefaultConfig returns the Config used by DefaultDiffFn.
+func DefaultConfig() *Config {
+	return &Config{
+		FuzzyMaxChannelDelta: DEFAULT_FUZZY_MAX_CHANNEL_DELTA,
+		FuzzyMaxDiffPixels:   DEFAULT_FUZZY_MAX_DIFF_PIXELS,
+	}
+}
+
+// Version returns a string that identifies the diff metrics calculated by
+// DiffFn. It changes if METRICS_VERSION or any of the parameters change.
+func (c *Config) Version() string {
+	return fmt.Sprintf("%d:fuzzy=%d,%d", METRICS_VERSION, c.FuzzyMaxChannelDelta, c.FuzzyMaxDiffPixels)
+}
+
+// DiffFn implements the DiffFn function type. Calculates the basic image
+// difference at the native precision of the images along with custom diff
+// metrics. The custom diff metrics are calculated on 8-bit images.
+func (c *Config) DiffFn(leftImg image.Image, rightImg image.Image) (interface{}, *image.NRGBA) {
 	ret, diffImg := PixelDiff(leftImg, rightImg)
 
 	// Calculate the metrics.
+	left, right := GetNRGBA(leftImg), GetNRGBA(rightImg)
 	diffs := make(map[string]float32, len(diffMetricIds))
 	for _, id := range diffMetricIds {
-		diffs[id] = metrics[id](ret, leftImg, rightImg)
+		diffs[id] = metrics[id](c, ret, left, right)
 	}
 	ret.Diffs = diffs
 
 	return ret, diffImg
 }
 
+// DefaultDiffFn implements the DiffFn function type using DefaultConfig.
+func DefaultDiffFn(leftImg image.Image, rightImg image.Image) (interface{}, *image.NRGBA) {
+	return DefaultConfig().DiffFn(leftImg, rightImg)
+}
+
 // combinedDiffMetric returns a value in [0, 1] that represents how large
 // the diff is between two images. Implements the MetricFn signature.
-func combinedDiffMetric(basic *DiffMetrics, one *image.NRGBA, two *image.NRGBA) float32 {
+func combinedDiffMetric(cfg *Config, basic *DiffMetrics, one *image.NRGBA, two *image.NRGBA) float32 {
 	//
 	// pixelDiffPercent float32, maxRGBA []int) float32 {
 	if len(basic.MaxRGBADiffs) == 0 {
@@ -74,11 +120,11 @@ func combinedDiffMetric(basic *DiffMetrics, one *image.NRGBA, two *image.NRGBA)
 }
 
 // percentDiffMetric returns pixel percent as the metric. Implements the MetricFn signature.
-func percentDiffMetric(basic *DiffMetrics, one *image.NRGBA, two *image.NRGBA) float32 {
+func percentDiffMetric(cfg *Config, basic *DiffMetrics, one *image.NRGBA, two *image.NRGBA) float32 {
 	return basic.PixelDiffPercent
 }
 
 // pixelDiffMetric returns the number of different pixels as the metric. Implements the MetricFn signature.
-func pixelDiffMetric(basic *DiffMetrics, one *image.NRGBA, two *image.NRGBA) float32 {
+func pixelDiffMetric(cfg *Config, basic *DiffMetrics, one *image.NRGBA, two *image.NRGBA) float32 {
 	return float32(basic.NumDiffPixels)
 }
@@ -0,0 +1,203 @@
+package diff
+
+import (
+	"image"
+	"math"
+
+	"go.skia.org/infra/go/util"
+)
+
+const (
+	// SSIM_WINDOW is the width and height of the windows that SSIM is
+	// calculated over.
+	SSIM_WINDOW = 8
+
+	// MAX_DELTA_E is reported by the delta-E metric for images with different
+	// dimensions. It is larger than the delta-E between any two sRGB colors.
+	MAX_DELTA_E = 1000
+
+	// SSIM stabilization constants, for a dynamic range of 255.
+	ssimC1 = (0.01 * 255) * (0.01 * 255)
+	ssimC2 = (0.03 * 255) * (0.03 * 255)
+)
+
+const (
+	// DEFAULT_FUZZY_MAX_CHANNEL_DELTA is the default for
+	// Config.FuzzyMaxChannelDelta.
+	DEFAULT_FUZZY_MAX_CHANNEL_DELTA = 8
+
+	// DEFAULT_FUZZY_MAX_DIFF_PIXELS is the default for
+	// Config.FuzzyMaxDiffPixels.
+	DEFAULT_FUZZY_MAX_DIFF_PIXELS = 0
+)
+
+// srgbToLinear maps an sRGB encoded channel value to linear light in [0, 1].
+var srgbToLinear [256]float64
+
+func init() {
+	for i := range srgbToLinear {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			srgbToLinear[i] = c / 12.92
+		} else {
+			srgbToLinear[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+}
+
+// pixels returns the Pix slices of the two images if they have the same
+// dimensions and are tightly packed, otherwise ok is false.
+func pixels(one *image.NRGBA, two *image.NRGBA) ([]uint8, []uint8, bool) {
+	b1, b2 := one.Bounds(), two.Bounds()
+	if b1.Dx() != b2.Dx() || b1.Dy() != b2.Dy() {
+		return nil, nil, false
+	}
+	n := b1.Dx() * b1.Dy() * 4
+	if one.Stride != b1.Dx()*4 || two.Stride != b2.Dx()*4 || len(one.Pix) < n || len(two.Pix) < n {
+		one, two = recode(one), recode(two)
+	}
+	return one.Pix[:n], two.Pix[:n], true
+}
+
+// ssimDiffMetric returns the structural dissimilarity of the two images,
+// (1 - SSIM) / 2, which is in [0, 1] where 0 means the images are identical.
+// SSIM is calculated on the luma of the images composited over black, and
+// averaged over non-overlapping SSIM_WINDOW x SSIM_WINDOW windows. Implements
+// the MetricFn signature.
+func ssimDiffMetric(cfg *Config, basic *DiffMetrics, one *image.NRGBA, two *image.NRGBA) float32 {
+	if basic.DimDiffer {
+		return 1
+	}
+	if basic.NumDiffPixels == 0 {
+		return 0
+	}
+	p1, p2, ok := pixels(one, two)
+	if !ok {
+		return 1
+	}
+	w, h := one.Bounds().Dx(), one.Bounds().Dy()
+	sum := 0.0
+	windows := 0
+	for wy := 0; wy < h; wy += SSIM_WINDOW {
+		for wx := 0; wx < w; wx += SSIM_WINDOW {
+			var sx, sy, sxx, syy, sxy float64
+			n := 0
+			for y := wy; y < util.MinInt(wy+SSIM_WINDOW, h); y++ {
+				for x := wx; x < util.MinInt(wx+SSIM_WINDOW, w); x++ {
+					i := (y*w + x) * 4
+					a := luma(p1[i:])
+					b := luma(p2[i:])
+					sx += a
+					sy += b
+					sxx += a * a
+					syy += b * b
+					sxy += a * b
+					n++
+				}
+			}
+			fn := float64(n)
+			mx, my := sx/fn, sy/fn
+			vx := sxx/fn - mx*mx
+			vy := syy/fn - my*my
+			cov := sxy/fn - mx*my
+			sum += ((2*mx*my + ssimC1) * (2*cov + ssimC2)) / ((mx*mx + my*my + ssimC1) * (vx + vy + ssimC2))
+			windows++
+		}
+	}
+	return float32((1 - sum/float64(windows)) / 2)
+}
+
+// luma returns the luma of the non-premultiplied RGBA pixel at the start of
+// 'p' composited over black.
+func luma(p []uint8) float64 {
+	return (0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])) * float64(p[3]) / 255
+}
+
+// deltaEDiffMetric returns the largest CIE76 delta-E between corresponding
+// pixels of the two images composited over black. A delta-E of about 2.3 is
+// the smallest difference a person can notice. Implements the MetricFn
+// signature.
+func deltaEDiffMetric(cfg *Config, basic *DiffMetrics, one *image.NRGBA, two *image.NRGBA) float32 {
+	if basic.DimDiffer {
+		return MAX_DELTA_E
+	}
+	if basic.NumDiffPixels == 0 {
+		return 0
+	}
+	p1, p2, ok := pixels(one, two)
+	if !ok {
+		return MAX_DELTA_E
+	}
+	ret := 0.0
+	for i := 0; i < len(p1); i += 4 {
+		if p1[i] == p2[i] && p1[i+1] == p2[i+1] && p1[i+2] == p2[i+2] && p1[i+3] == p2[i+3] {
+			continue
+		}
+		l1, a1, b1 := lab(p1[i:])
+		l2, a2, b2 := lab(p2[i:])
+		dl, da, db := l1-l2, a1-a2, b1-b2
+		ret = math.Max(ret, math.Sqrt(dl*dl+da*da+db*db))
+	}
+	return float32(ret)
+}
+
+// lab converts the non-premultiplied sRGB pixel at the start of 'p',
+// composited over black, into CIE L*a*b* with a D65 white point.
+func lab(p []uint8) (float64, float64, float64) {
+	alpha := float64(p[3]) / 255
+	r := srgbToLinear[p[0]] * alpha
+	g := srgbToLinear[p[1]] * alpha
+	b := srgbToLinear[p[2]] * alpha
+
+	fx := labF((0.4124*r + 0.3576*g + 0.1805*b) / 0.95047)
+	fy := labF(0.2126*r + 0.7152*g + 0.0722*b)
+	fz := labF((0.0193*r + 0.1192*g + 0.9505*b) / 1.08883)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// labF is the non-linear function used in the XYZ to L*a*b* conversion.
+func labF(t float64) float64 {
+	const delta = 6.0 / 29.0
+	if t > delta*delta*delta {
+		return math.Cbrt(t)
+	}
+	return t/(3*delta*delta) + 4.0/29.0
+}
+
+// fuzzyDiffMetric returns 0 if the images are a fuzzy match, i.e. no more
+// than cfg.FuzzyMaxDiffPixels pixels differ by more than
+// cfg.FuzzyMaxChannelDelta in any channel. Otherwise it returns the number of
+// pixels that differ by more than cfg.FuzzyMaxChannelDelta. Implements the
+// MetricFn signature.
+func fuzzyDiffMetric(cfg *Config, basic *DiffMetrics, one *image.NRGBA, two *image.NRGBA) float32 {
+	if basic.NumDiffPixels <= cfg.FuzzyMaxDiffPixels {
+		return 0
+	}
+	if basic.DimDiffer {
+		return float32(basic.NumDiffPixels)
+	}
+	maxDelta := 0
+	for _, d := range basic.MaxRGBADiffs {
+		maxDelta = util.MaxInt(maxDelta, d)
+	}
+	if maxDelta <= cfg.FuzzyMaxChannelDelta {
+		return 0
+	}
+	p1, p2, ok := pixels(one, two)
+	if !ok {
+		return float32(basic.NumDiffPixels)
+	}
+	n := 0
+	for i := 0; i < len(p1); i += 4 {
+		for c := i; c < i+4; c++ {
+			if util.AbsInt(int(p1[c])-int(p2[c])) > cfg.FuzzyMaxChannelDelta {
+				n++
+				break
+			}
+		}
+	}
+	if n <= cfg.FuzzyMaxDiffPixels {
+		return 0
+	}
+	return float32(n)
+}
@@ -0,0 +1,118 @@
+package diff
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.skia.org/infra/go/testutils"
+)
+
+// checkerboard returns a w x h image of black and white squares of the
+// given size.
+func checkerboard(w, h, size int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if ((x/size)+(y/size))%2 == 0 {
+				img.Set(x, y, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+			} else {
+				img.Set(x, y, color.NRGBA{A: 0xff})
+			}
+		}
+	}
+	return img
+}
+
+// allMetrics returns the metrics computed by DefaultDiffFn for the two images.
+func allMetrics(one, two *image.NRGBA) map[string]float32 {
+	dm, _ := DefaultDiffFn(one, two)
+	return dm.(*DiffMetrics).Diffs
+}
+
+func TestPerceptualMetricsIdentical(t *testing.T) {
+	testutils.SmallTest(t)
+
+	img := checkerboard(16, 16, 2)
+	diffs := allMetrics(img, img)
+	assert.Equal(t, float32(0), diffs[METRIC_SSIM])
+	assert.Equal(t, float32(0), diffs[METRIC_DELTA_E])
+	assert.Equal(t, float32(0), diffs[METRIC_FUZZY])
+}
+
+func TestPerceptualMetricsNoise(t *testing.T) {
+	testutils.SmallTest(t)
+
+	img := checkerboard(16, 16, 2)
+	noisy := checkerboard(16, 16, 2)
+	// Change a few pixels by a small amount, like anti-aliasing noise.
+	noisy.Set(1, 1, color.NRGBA{R: 0xfc, G: 0xfe, B: 0xff, A: 0xff})
+	noisy.Set(2, 0, color.NRGBA{R: 0x02, G: 0x01, B: 0x00, A: 0xff})
+	inverted := image.NewNRGBA(img.Bounds())
+	for i := 0; i < len(img.Pix); i += 4 {
+		inverted.Pix[i] = 0xff - img.Pix[i]
+		inverted.Pix[i+1] = 0xff - img.Pix[i+1]
+		inverted.Pix[i+2] = 0xff - img.Pix[i+2]
+		inverted.Pix[i+3] = 0xff
+	}
+
+	noise := allMetrics(img, noisy)
+	different := allMetrics(img, inverted)
+
+	assert.True(t, noise[METRIC_SSIM] > 0)
+	assert.True(t, noise[METRIC_SSIM] < 0.01)
+	assert.True(t, different[METRIC_SSIM] > 0.5)
+	assert.True(t, different[METRIC_SSIM] <= 1)
+
+	assert.True(t, noise[METRIC_DELTA_E] > 0)
+	assert.True(t, noise[METRIC_DELTA_E] < 2.3)
+	assert.InDelta(t, 100, different[METRIC_DELTA_E], 0.1)
+
+	// The noise is within the default channel tolerance.
+	assert.Equal(t, float32(0), noise[METRIC_FUZZY])
+	assert.Equal(t, float32(256), different[METRIC_FUZZY])
+}
+
+func TestFuzzyDiffMetricParams(t *testing.T) {
+	testutils.SmallTest(t)
+
+	img := checkerboard(4, 4, 1)
+	changed := checkerboard(4, 4, 1)
+	changed.Set(0, 0, color.NRGBA{R: 0xf0, G: 0xff, B: 0xff, A: 0xff})
+	changed.Set(1, 0, color.NRGBA{R: 0x10, A: 0xff})
+	changed.Set(2, 0, color.NRGBA{R: 0xff, G: 0xfb, B: 0xff, A: 0xff})
+	basic, _ := PixelDiff(img, changed)
+
+	cfg := &Config{FuzzyMaxChannelDelta: 8, FuzzyMaxDiffPixels: 0}
+	assert.Equal(t, float32(2), fuzzyDiffMetric(cfg, basic, img, changed))
+	dm, _ := cfg.DiffFn(img, changed)
+	assert.Equal(t, float32(2), dm.(*DiffMetrics).Diffs[METRIC_FUZZY])
+
+	cfg.FuzzyMaxDiffPixels = 2
+	assert.Equal(t, float32(0), fuzzyDiffMetric(cfg, basic, img, changed))
+
+	cfg = &Config{FuzzyMaxChannelDelta: 16, FuzzyMaxDiffPixels: 0}
+	assert.Equal(t, float32(0), fuzzyDiffMetric(cfg, basic, img, changed))
+}
+
+func TestConfigVersion(t *testing.T) {
+	testutils.SmallTest(t)
+
+	cfg := DefaultConfig()
+	assert.Equal(t, DefaultConfig().Version(), cfg.Version())
+	cfg.FuzzyMaxDiffPixels++
+	assert.NotEqual(t, DefaultConfig().Version(), cfg.Version())
+	cfg = DefaultConfig()
+	cfg.FuzzyMaxChannelDelta++
+	assert.NotEqual(t, DefaultConfig().Version(), cfg.Version())
+}
+
+func TestPerceptualMetricsDimDiffer(t *testing.T) {
+	testutils.SmallTest(t)
+
+	diffs := allMetrics(checkerboard(8, 8, 2), checkerboard(8, 9, 2))
+	assert.Equal(t, float32(1), diffs[METRIC_SSIM])
+	assert.Equal(t, float32(MAX_DELTA_E), diffs[METRIC_DELTA_E])
+	assert.Equal(t, float32(8), diffs[METRIC_FUZZY])
+}
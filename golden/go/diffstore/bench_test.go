@@ -48,7 +48,7 @@ func BenchmarkMemDiffStore(b *testing.B) {
 		}
 	}
 
-	mapper := NewGoldDiffStoreMapper(&diff.DiffMetrics{})
+	mapper := NewGoldDiffStoreMapper(&diff.DiffMetrics{}, nil)
 	diffStore, err := NewMemDiffStore(client, baseDir, []string{TEST_GCS_BUCKET_NAME}, TEST_GCS_IMAGE_DIR, 10, mapper)
 	allDigests := make([][]string, 0, PROCESS_N_TESTS)
 	processed := 0
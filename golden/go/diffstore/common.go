@@ -37,13 +37,8 @@ func saveFile(targetPath string, r io.Reader) error {
 }
 
 // loadImg loads an image from disk.
-func loadImg(sourcePath string) (*image.NRGBA, error) {
-	f, err := os.Open(sourcePath)
-	if err != nil {
-		return nil, err
-	}
-	defer util.Close(f)
-	return decodeImg(f)
+func loadImg(sourcePath string) (image.Image, *diff.ImageInfo, error) {
+	return diff.OpenImgFromFile(sourcePath)
 }
 
 // encodeImg encodes the given image as a PNG and writes the result to the
@@ -56,13 +51,10 @@ func encodeImg(w io.Writer, img *image.NRGBA) error {
 	return nil
 }
 
-// decodeImg decodes an image from the given reader and returns it as a NRGBA image.
-func decodeImg(reader io.Reader) (*image.NRGBA, error) {
-	im, err := png.Decode(reader)
-	if err != nil {
-		return nil, err
-	}
-	return diff.GetNRGBA(im), nil
+// decodeImg decodes an image from the given reader. The image is returned in
+// its native color model along with its format and bit depth.
+func decodeImg(reader io.Reader) (image.Image, *diff.ImageInfo, error) {
+	return diff.DecodeImg(reader)
 }
 
 // getDigestImageFileName returns the image name based on the digest.
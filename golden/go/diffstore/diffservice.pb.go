@@ -398,6 +398,139 @@ func (m *DigestFailureResponse) GetTS() int64 {
 	return 0
 }
 
+type ImageInfoRequest struct {
+	Priority             int64    `protobuf:"varint,1,opt,name=priority,proto3" json:"priority,omitempty"`
+	Digests              []string `protobuf:"bytes,2,rep,name=digests,proto3" json:"digests,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ImageInfoRequest) Reset()         { *m = ImageInfoRequest{} }
+func (m *ImageInfoRequest) String() string { return proto.CompactTextString(m) }
+func (*ImageInfoRequest) ProtoMessage()    {}
+func (*ImageInfoRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7be44b2c6ca656f4, []int{8}
+}
+
+func (m *ImageInfoRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImageInfoRequest.Unmarshal(m, b)
+}
+func (m *ImageInfoRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImageInfoRequest.Marshal(b, m, deterministic)
+}
+func (m *ImageInfoRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImageInfoRequest.Merge(m, src)
+}
+func (m *ImageInfoRequest) XXX_Size() int {
+	return xxx_messageInfo_ImageInfoRequest.Size(m)
+}
+func (m *ImageInfoRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImageInfoRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImageInfoRequest proto.InternalMessageInfo
+
+func (m *ImageInfoRequest) GetPriority() int64 {
+	if m != nil {
+		return m.Priority
+	}
+	return 0
+}
+
+func (m *ImageInfoRequest) GetDigests() []string {
+	if m != nil {
+		return m.Digests
+	}
+	return nil
+}
+
+type ImageInfoResponse struct {
+	ImageInfos           map[string]*DigestImageInfo `protobuf:"bytes,1,rep,name=imageInfos,proto3" json:"imageInfos,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}                    `json:"-"`
+	XXX_unrecognized     []byte                      `json:"-"`
+	XXX_sizecache        int32                       `json:"-"`
+}
+
+func (m *ImageInfoResponse) Reset()         { *m = ImageInfoResponse{} }
+func (m *ImageInfoResponse) String() string { return proto.CompactTextString(m) }
+func (*ImageInfoResponse) ProtoMessage()    {}
+func (*ImageInfoResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7be44b2c6ca656f4, []int{9}
+}
+
+func (m *ImageInfoResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImageInfoResponse.Unmarshal(m, b)
+}
+func (m *ImageInfoResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImageInfoResponse.Marshal(b, m, deterministic)
+}
+func (m *ImageInfoResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImageInfoResponse.Merge(m, src)
+}
+func (m *ImageInfoResponse) XXX_Size() int {
+	return xxx_messageInfo_ImageInfoResponse.Size(m)
+}
+func (m *ImageInfoResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImageInfoResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImageInfoResponse proto.InternalMessageInfo
+
+func (m *ImageInfoResponse) GetImageInfos() map[string]*DigestImageInfo {
+	if m != nil {
+		return m.ImageInfos
+	}
+	return nil
+}
+
+type DigestImageInfo struct {
+	Format               string   `protobuf:"bytes,1,opt,name=format,proto3" json:"format,omitempty"`
+	BitDepth             int32    `protobuf:"varint,2,opt,name=bitDepth,proto3" json:"bitDepth,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DigestImageInfo) Reset()         { *m = DigestImageInfo{} }
+func (m *DigestImageInfo) String() string { return proto.CompactTextString(m) }
+func (*DigestImageInfo) ProtoMessage()    {}
+func (*DigestImageInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_7be44b2c6ca656f4, []int{10}
+}
+
+func (m *DigestImageInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DigestImageInfo.Unmarshal(m, b)
+}
+func (m *DigestImageInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DigestImageInfo.Marshal(b, m, deterministic)
+}
+func (m *DigestImageInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DigestImageInfo.Merge(m, src)
+}
+func (m *DigestImageInfo) XXX_Size() int {
+	return xxx_messageInfo_DigestImageInfo.Size(m)
+}
+func (m *DigestImageInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_DigestImageInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DigestImageInfo proto.InternalMessageInfo
+
+func (m *DigestImageInfo) GetFormat() string {
+	if m != nil {
+		return m.Format
+	}
+	return ""
+}
+
+func (m *DigestImageInfo) GetBitDepth() int32 {
+	if m != nil {
+		return m.BitDepth
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Empty)(nil), "diffstore.Empty")
 	proto.RegisterType((*GetDiffsRequest)(nil), "diffstore.GetDiffsRequest")
@@ -408,43 +541,53 @@ func init() {
 	proto.RegisterType((*WarmDigestsRequest)(nil), "diffstore.WarmDigestsRequest")
 	proto.RegisterType((*WarmDiffsRequest)(nil), "diffstore.WarmDiffsRequest")
 	proto.RegisterType((*DigestFailureResponse)(nil), "diffstore.DigestFailureResponse")
+	proto.RegisterType((*ImageInfoRequest)(nil), "diffstore.ImageInfoRequest")
+	proto.RegisterType((*ImageInfoResponse)(nil), "diffstore.ImageInfoResponse")
+	proto.RegisterMapType((map[string]*DigestImageInfo)(nil), "diffstore.ImageInfoResponse.ImageInfosEntry")
+	proto.RegisterType((*DigestImageInfo)(nil), "diffstore.DigestImageInfo")
 }
 
 func init() { proto.RegisterFile("diffservice.proto", fileDescriptor_7be44b2c6ca656f4) }
 
 var fileDescriptor_7be44b2c6ca656f4 = []byte{
-	// 490 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0xc1, 0x6e, 0xd3, 0x40,
-	0x10, 0x8d, 0xed, 0xa6, 0x8d, 0xc7, 0x51, 0x09, 0x53, 0x40, 0x96, 0x2b, 0x2a, 0x6b, 0x25, 0xa4,
-	0x1c, 0x50, 0x0e, 0x41, 0x42, 0x80, 0x38, 0x20, 0x48, 0xe8, 0x81, 0x03, 0xd5, 0xa6, 0xa8, 0x37,
-	0xa4, 0x6d, 0xba, 0x09, 0x2b, 0x1c, 0x3b, 0x5d, 0x6f, 0x22, 0xe5, 0x4b, 0xf8, 0x41, 0x3e, 0x04,
-	0x79, 0xd7, 0x71, 0x37, 0xb1, 0xa9, 0x72, 0xf3, 0xcc, 0xce, 0xbc, 0x7d, 0xf3, 0xf6, 0x8d, 0xe1,
-	0xe9, 0x9d, 0x98, 0xcd, 0x72, 0x2e, 0xd7, 0x62, 0xca, 0x07, 0x4b, 0x99, 0xa9, 0x0c, 0x7d, 0x9d,
-	0x52, 0x99, 0xe4, 0xe4, 0x04, 0xda, 0xe3, 0xc5, 0x52, 0x6d, 0xc8, 0x3d, 0x3c, 0xb9, 0xe4, 0x6a,
-	0x54, 0x1c, 0x50, 0x7e, 0xbf, 0xe2, 0xb9, 0xc2, 0x08, 0x3a, 0x4b, 0x29, 0x32, 0x29, 0xd4, 0x26,
-	0x74, 0x62, 0xa7, 0xef, 0xd1, 0x2a, 0xc6, 0x0b, 0x80, 0x05, 0x13, 0xe9, 0x48, 0xcc, 0x79, 0xae,
-	0x42, 0x37, 0x76, 0xfa, 0x3e, 0xb5, 0x32, 0x48, 0xa0, 0x2b, 0xc5, 0xfc, 0x97, 0x32, 0x61, 0x1e,
-	0x7a, 0xb1, 0xd7, 0xf7, 0xe9, 0x4e, 0x8e, 0xf4, 0xa1, 0xf7, 0x70, 0x65, 0xbe, 0xcc, 0xd2, 0x9c,
-	0xe3, 0x33, 0x68, 0x6b, 0x72, 0xfa, 0xc2, 0x2e, 0x35, 0x01, 0xf9, 0x06, 0x67, 0x57, 0x2b, 0x39,
-	0xe7, 0x65, 0xe7, 0x96, 0x60, 0x08, 0x27, 0x77, 0x25, 0xbe, 0xa3, 0xf1, 0xb7, 0xa1, 0xa6, 0x5e,
-	0x34, 0x5c, 0x7e, 0x99, 0x68, 0x72, 0x1d, 0x5a, 0xc5, 0xe4, 0xaf, 0x03, 0xd1, 0x8f, 0x94, 0xad,
-	0x99, 0x48, 0xd8, 0x6d, 0xf2, 0x80, 0x59, 0x32, 0x60, 0x70, 0x6a, 0x50, 0xbe, 0x32, 0x91, 0xac,
-	0x24, 0x37, 0xd8, 0xc1, 0xf0, 0xfd, 0xa0, 0x52, 0x6d, 0xf0, 0xff, 0xf6, 0xc1, 0x68, 0xa7, 0x77,
-	0x9c, 0x2a, 0xb9, 0xa1, 0x7b, 0x80, 0xd1, 0x14, 0xce, 0x1a, 0xca, 0xb0, 0x07, 0xde, 0x6f, 0x6e,
-	0xa4, 0xf6, 0x69, 0xf1, 0x89, 0x6f, 0xa1, 0xbd, 0x66, 0xc9, 0x8a, 0xeb, 0x19, 0x82, 0x61, 0x6c,
-	0x51, 0xd8, 0x01, 0xd8, 0xde, 0x4e, 0x4d, 0xf9, 0x07, 0xf7, 0x9d, 0x43, 0x7e, 0x02, 0xde, 0x30,
-	0xb9, 0xd8, 0x93, 0xec, 0xb1, 0x37, 0xb5, 0xe4, 0x74, 0x77, 0xe5, 0x44, 0x38, 0xca, 0x37, 0xe9,
-	0x34, 0xf4, 0xb4, 0x94, 0xfa, 0x9b, 0x28, 0xe8, 0x19, 0xfc, 0x03, 0x1d, 0x13, 0x43, 0x90, 0xf0,
-	0x59, 0x65, 0x08, 0x73, 0x83, 0x9d, 0x3a, 0xc8, 0x33, 0x37, 0xf0, 0xbc, 0x71, 0x72, 0x7c, 0x01,
-	0xc7, 0xa5, 0x19, 0x8d, 0x7e, 0x65, 0x54, 0xe4, 0x29, 0x67, 0x79, 0x96, 0x96, 0x26, 0x2d, 0x23,
-	0x3c, 0x05, 0xf7, 0x7a, 0xa2, 0x07, 0xf2, 0xa8, 0x7b, 0x3d, 0x19, 0xfe, 0xf1, 0x20, 0x28, 0x66,
-	0x99, 0x98, 0x4d, 0xc1, 0x31, 0x74, 0xb6, 0xe6, 0xc4, 0xc8, 0xd2, 0x7d, 0x6f, 0x49, 0xa2, 0xf3,
-	0xc6, 0x33, 0x43, 0x8a, 0xb4, 0xf0, 0x13, 0x04, 0xd6, 0x2b, 0xe0, 0x4b, 0xab, 0xba, 0xfe, 0x3a,
-	0x51, 0xcf, 0x3a, 0x36, 0x6b, 0xd9, 0xc2, 0x8f, 0xe0, 0x57, 0x3a, 0xe3, 0x79, 0xad, 0xdf, 0xa2,
-	0xd2, 0xd4, 0xfd, 0x1d, 0xb0, 0x6e, 0x56, 0xac, 0x55, 0x46, 0xaf, 0x0e, 0x72, 0x37, 0x69, 0xe1,
-	0x67, 0xe8, 0xda, 0xab, 0x88, 0x17, 0x56, 0x63, 0xc3, 0x8e, 0x36, 0x92, 0x7a, 0x0d, 0x47, 0x57,
-	0x22, 0x9d, 0x37, 0xd0, 0x68, 0xa8, 0xbe, 0x3d, 0xd6, 0x3f, 0xad, 0x37, 0xff, 0x02, 0x00, 0x00,
-	0xff, 0xff, 0x5c, 0x68, 0x23, 0x23, 0xc9, 0x04, 0x00, 0x00,
+	// 590 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x55, 0x51, 0x8b, 0xda, 0x40,
+	0x10, 0x36, 0xe6, 0xbc, 0x33, 0xa3, 0x9c, 0xde, 0x5c, 0x5b, 0x24, 0xb6, 0x87, 0x2c, 0x14, 0x7c,
+	0x38, 0xa4, 0x58, 0x28, 0x6d, 0xe9, 0x43, 0x69, 0xb5, 0x77, 0x47, 0x0b, 0x3d, 0xd6, 0x2b, 0x47,
+	0x5f, 0x0a, 0xd1, 0xdb, 0x78, 0x4b, 0x35, 0xf1, 0x92, 0x55, 0xf0, 0xbf, 0xf5, 0xcf, 0x14, 0xfa,
+	0x43, 0x4a, 0x76, 0x93, 0xb8, 0x31, 0xa9, 0x08, 0x7d, 0x73, 0x66, 0xf6, 0x9b, 0xf9, 0xf6, 0xdb,
+	0x2f, 0x23, 0x9c, 0xdc, 0x71, 0xd7, 0x0d, 0x59, 0xb0, 0xe2, 0x13, 0xd6, 0x5b, 0x04, 0xbe, 0xf0,
+	0xd1, 0x92, 0x29, 0xe1, 0x07, 0x8c, 0x1c, 0x41, 0x65, 0x38, 0x5f, 0x88, 0x35, 0x79, 0x80, 0xc6,
+	0x05, 0x13, 0x83, 0xa8, 0x40, 0xd9, 0xc3, 0x92, 0x85, 0x02, 0x6d, 0xa8, 0x2e, 0x02, 0xee, 0x07,
+	0x5c, 0xac, 0x5b, 0x46, 0xc7, 0xe8, 0x9a, 0x34, 0x8d, 0xf1, 0x0c, 0x60, 0xee, 0x70, 0x6f, 0xc0,
+	0xa7, 0x2c, 0x14, 0xad, 0x72, 0xc7, 0xe8, 0x5a, 0x54, 0xcb, 0x20, 0x81, 0x7a, 0xc0, 0xa7, 0xf7,
+	0x42, 0x85, 0x61, 0xcb, 0xec, 0x98, 0x5d, 0x8b, 0x66, 0x72, 0xa4, 0x0b, 0xcd, 0xcd, 0xc8, 0x70,
+	0xe1, 0x7b, 0x21, 0xc3, 0x47, 0x50, 0x91, 0xe4, 0xe4, 0xc0, 0x3a, 0x55, 0x01, 0xf9, 0x0c, 0xa7,
+	0xd7, 0xcb, 0x60, 0xca, 0x62, 0x64, 0x42, 0xb0, 0x05, 0x47, 0x77, 0x71, 0x7f, 0x43, 0xf6, 0x4f,
+	0x42, 0x49, 0x3d, 0x02, 0x5c, 0x7c, 0x1c, 0x49, 0x72, 0x55, 0x9a, 0xc6, 0xe4, 0x8f, 0x01, 0xf6,
+	0x37, 0xcf, 0x59, 0x39, 0x7c, 0xe6, 0x8c, 0x67, 0x9b, 0x9e, 0x31, 0x03, 0x07, 0x8e, 0x55, 0x97,
+	0x4f, 0x0e, 0x9f, 0x2d, 0x03, 0xa6, 0x7a, 0xd7, 0xfa, 0x6f, 0x7a, 0xa9, 0x6a, 0xbd, 0x7f, 0xc3,
+	0x7b, 0x83, 0x0c, 0x76, 0xe8, 0x89, 0x60, 0x4d, 0xb7, 0x1a, 0xda, 0x13, 0x38, 0x2d, 0x38, 0x86,
+	0x4d, 0x30, 0x7f, 0x32, 0x25, 0xb5, 0x45, 0xa3, 0x9f, 0xf8, 0x0a, 0x2a, 0x2b, 0x67, 0xb6, 0x64,
+	0xf2, 0x0e, 0xb5, 0x7e, 0x47, 0xa3, 0x90, 0x69, 0x90, 0x4c, 0xa7, 0xea, 0xf8, 0xdb, 0xf2, 0x6b,
+	0x83, 0xfc, 0x00, 0xbc, 0x75, 0x82, 0xf9, 0x96, 0x64, 0xbb, 0xde, 0x54, 0x93, 0xb3, 0x9c, 0x95,
+	0x13, 0xe1, 0x20, 0x5c, 0x7b, 0x93, 0x96, 0x29, 0xa5, 0x94, 0xbf, 0x89, 0x80, 0xa6, 0xea, 0xbf,
+	0xa7, 0x63, 0x3a, 0x50, 0x9b, 0x31, 0x57, 0x0c, 0x32, 0x13, 0xf4, 0xd4, 0x5e, 0x9e, 0xb9, 0x85,
+	0xc7, 0x85, 0x37, 0xc7, 0x27, 0x70, 0x18, 0x9b, 0x51, 0xe9, 0x17, 0x47, 0x51, 0x9e, 0x32, 0x27,
+	0xf4, 0xbd, 0xd8, 0xa4, 0x71, 0x84, 0xc7, 0x50, 0xbe, 0x19, 0xc9, 0x0b, 0x99, 0xb4, 0x7c, 0x33,
+	0x22, 0x97, 0xd0, 0xbc, 0x9a, 0x3b, 0x53, 0x76, 0xe5, 0xb9, 0xfe, 0x7f, 0x89, 0x45, 0x7e, 0x19,
+	0x70, 0xa2, 0xb5, 0x8a, 0xf9, 0x7d, 0x01, 0xe0, 0x49, 0x32, 0xb1, 0xd4, 0xb9, 0xf6, 0x9e, 0x39,
+	0xc4, 0x26, 0x13, 0xbb, 0x48, 0xc3, 0xdb, 0xdf, 0xa1, 0xb1, 0x55, 0x2e, 0x70, 0xcf, 0x8b, 0xac,
+	0x7b, 0xec, 0x9c, 0x7b, 0x36, 0x33, 0x35, 0xdf, 0x0c, 0xa1, 0xb1, 0x55, 0x8d, 0x34, 0x74, 0xfd,
+	0x60, 0xee, 0xa4, 0xda, 0xaa, 0x28, 0xd2, 0x67, 0xcc, 0xc5, 0x80, 0x2d, 0xc4, 0xbd, 0x9c, 0x51,
+	0xa1, 0x69, 0xdc, 0xff, 0x6d, 0x42, 0x2d, 0xf2, 0xc6, 0x48, 0x6d, 0x1e, 0x1c, 0x42, 0x35, 0xf9,
+	0xd8, 0x51, 0x67, 0xb2, 0xb5, 0x74, 0xec, 0x76, 0x61, 0x4d, 0x49, 0x42, 0x4a, 0xf8, 0x1e, 0x6a,
+	0x9a, 0xab, 0xf1, 0x99, 0x76, 0x3a, 0xef, 0x76, 0xbb, 0xa9, 0x95, 0xd5, 0x9a, 0x2b, 0xe1, 0x3b,
+	0xb0, 0x52, 0xdf, 0x62, 0x3b, 0x87, 0x77, 0xdd, 0x9d, 0xe8, 0xaf, 0x80, 0xf9, 0x8f, 0x1f, 0x73,
+	0x27, 0xed, 0xe7, 0x7b, 0x6d, 0x0b, 0x52, 0xc2, 0x0f, 0x50, 0xd7, 0x57, 0x1b, 0x9e, 0x69, 0xc0,
+	0x82, 0x9d, 0x57, 0x48, 0xea, 0x12, 0xac, 0xcd, 0x63, 0xb5, 0x8b, 0x4d, 0xa5, 0xd0, 0x4f, 0x77,
+	0x39, 0x8e, 0x94, 0xf0, 0x1c, 0x0e, 0xae, 0xb9, 0x37, 0x2d, 0xb8, 0x50, 0xc1, 0xdc, 0xf1, 0xa1,
+	0xfc, 0x3b, 0x79, 0xf9, 0x77, 0x00, 0xa1, 0x74, 0x82, 0x0b, 0x63, 0x06, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -469,6 +612,8 @@ type DiffServiceClient interface {
 	UnavailableDigests(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*UnavailableDigestsResponse, error)
 	//Same functionality asSee PurgeDigestset in the diff.DiffStore interface.
 	PurgeDigests(ctx context.Context, in *PurgeDigestsRequest, opts ...grpc.CallOption) (*Empty, error)
+	// Same functionality as ImageInfo in the diff.DiffStore interface.
+	ImageInfo(ctx context.Context, in *ImageInfoRequest, opts ...grpc.CallOption) (*ImageInfoResponse, error)
 	// Ping is used to test connection.
 	Ping(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
 }
@@ -526,6 +671,15 @@ func (c *diffServiceClient) PurgeDigests(ctx context.Context, in *PurgeDigestsRe
 	return out, nil
 }
 
+func (c *diffServiceClient) ImageInfo(ctx context.Context, in *ImageInfoRequest, opts ...grpc.CallOption) (*ImageInfoResponse, error) {
+	out := new(ImageInfoResponse)
+	err := c.cc.Invoke(ctx, "/diffstore.DiffService/ImageInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *diffServiceClient) Ping(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error) {
 	out := new(Empty)
 	err := c.cc.Invoke(ctx, "/diffstore.DiffService/Ping", in, out, opts...)
@@ -547,6 +701,8 @@ type DiffServiceServer interface {
 	UnavailableDigests(context.Context, *Empty) (*UnavailableDigestsResponse, error)
 	//Same functionality asSee PurgeDigestset in the diff.DiffStore interface.
 	PurgeDigests(context.Context, *PurgeDigestsRequest) (*Empty, error)
+	// Same functionality as ImageInfo in the diff.DiffStore interface.
+	ImageInfo(context.Context, *ImageInfoRequest) (*ImageInfoResponse, error)
 	// Ping is used to test connection.
 	Ping(context.Context, *Empty) (*Empty, error)
 }
@@ -645,6 +801,24 @@ func _DiffService_PurgeDigests_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _DiffService_ImageInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImageInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DiffServiceServer).ImageInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/diffstore.DiffService/ImageInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DiffServiceServer).ImageInfo(ctx, req.(*ImageInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _DiffService_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Empty)
 	if err := dec(in); err != nil {
@@ -687,6 +861,10 @@ var _DiffService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "PurgeDigests",
 			Handler:    _DiffService_PurgeDigests_Handler,
 		},
+		{
+			MethodName: "ImageInfo",
+			Handler:    _DiffService_ImageInfo_Handler,
+		},
 		{
 			MethodName: "Ping",
 			Handler:    _DiffService_Ping_Handler,
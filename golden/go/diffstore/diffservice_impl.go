@@ -78,6 +78,23 @@ func (d *DiffServiceImpl) PurgeDigests(ctx context.Context, req *PurgeDigestsReq
 	return &Empty{}, d.diffStore.PurgeDigests(req.Digests, req.PurgeGCS)
 }
 
+// ImageInfo wraps around the ImageInfo method of the underlying DiffStore.
+func (d *DiffServiceImpl) ImageInfo(ctx context.Context, req *ImageInfoRequest) (*ImageInfoResponse, error) {
+	infos, err := d.diffStore.ImageInfo(req.Priority, req.Digests)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]*DigestImageInfo, len(infos))
+	for digest, info := range infos {
+		ret[digest] = &DigestImageInfo{
+			Format:   info.Format,
+			BitDepth: int32(info.BitDepth),
+		}
+	}
+	return &ImageInfoResponse{ImageInfos: ret}, nil
+}
+
 // Ping returns an empty message, used to test the connection.
 func (d *DiffServiceImpl) Ping(context.Context, *Empty) (*Empty, error) {
 	return &Empty{}, nil
@@ -37,7 +37,7 @@ func TestMemDiffStore(t *testing.T) {
 	baseDir := path.Join(w, TEST_DATA_BASE_DIR+"-diffstore")
 	client, tile := getSetupAndTile(t, baseDir)
 
-	mapper := NewGoldDiffStoreMapper(&diff.DiffMetrics{})
+	mapper := NewGoldDiffStoreMapper(&diff.DiffMetrics{}, nil)
 	diffStore, err := NewMemDiffStore(client, baseDir, []string{TEST_GCS_BUCKET_NAME}, TEST_GCS_IMAGE_DIR, 10, mapper)
 	assert.NoError(t, err)
 	memDiffStore := diffStore.(*MemDiffStore)
@@ -49,7 +49,7 @@ type DummyDiffStoreMapper struct {
 	GoldDiffStoreMapper
 }
 
-func (d DummyDiffStoreMapper) DiffFn(leftImg *image.NRGBA, rightImg *image.NRGBA) (interface{}, *image.NRGBA) {
+func (d DummyDiffStoreMapper) DiffFn(leftImg image.Image, rightImg image.Image) (interface{}, *image.NRGBA) {
 	return 42, nil
 }
 
@@ -62,7 +62,7 @@ func TestDiffFn(t *testing.T) {
 	client, _ := getSetupAndTile(t, baseDir)
 
 	// Instantiate a new MemDiffStore with the DummyDiffFn.
-	mapper := DummyDiffStoreMapper{GoldDiffStoreMapper: NewGoldDiffStoreMapper(&diff.DiffMetrics{}).(GoldDiffStoreMapper)}
+	mapper := DummyDiffStoreMapper{GoldDiffStoreMapper: NewGoldDiffStoreMapper(&diff.DiffMetrics{}, nil).(GoldDiffStoreMapper)}
 	diffStore, err := NewMemDiffStore(client, baseDir, []string{TEST_GCS_BUCKET_NAME}, TEST_GCS_IMAGE_DIR, 10, mapper)
 
 	assert.NoError(t, err)
@@ -84,7 +84,7 @@ func TestNetDiffStore(t *testing.T) {
 	baseDir := path.Join(w, TEST_DATA_BASE_DIR+"-netdiffstore")
 	client, tile := getSetupAndTile(t, baseDir)
 
-	mapper := NewGoldDiffStoreMapper(&diff.DiffMetrics{})
+	mapper := NewGoldDiffStoreMapper(&diff.DiffMetrics{}, nil)
 	memDiffStore, err := NewMemDiffStore(client, baseDir, []string{TEST_GCS_BUCKET_NAME}, TEST_GCS_IMAGE_DIR, 10, mapper)
 	assert.NoError(t, err)
 
@@ -150,6 +150,14 @@ func testDiffStore(t *testing.T, tile *tiling.Tile, baseDir string, diffStore di
 		assert.True(t, memDiffStore.imgLoader.IsOnDisk(d), fmt.Sprintf("Could not find '%s'", d))
 	}
 
+	// The image info is the same whether it's retrieved directly or remotely.
+	infos, err := diffStore.ImageInfo(diff.PRIORITY_NOW, digests)
+	assert.NoError(t, err)
+	assert.Equal(t, len(digests), len(infos))
+	for _, d := range digests {
+		assert.Equal(t, &diff.ImageInfo{Format: diff.FORMAT_PNG, BitDepth: diff.BIT_DEPTH_8}, infos[d])
+	}
+
 	// Warm the diffs and make sure they are in the cache.
 	diffStore.WarmDiffs(diff.PRIORITY_NOW, digests, digests)
 	memDiffStore.sync()
@@ -20,7 +20,7 @@ func TestFailureHandling(t *testing.T) {
 	baseDir := path.Join(w, TEST_DATA_BASE_DIR+"-diffstore-failure")
 	client, tile := getSetupAndTile(t, baseDir)
 
-	mapper := NewGoldDiffStoreMapper(&diff.DiffMetrics{})
+	mapper := NewGoldDiffStoreMapper(&diff.DiffMetrics{}, nil)
 	diffStore, err := NewMemDiffStore(client, baseDir, []string{TEST_GCS_BUCKET_NAME}, TEST_GCS_IMAGE_DIR, 10, mapper)
 	assert.NoError(t, err)
 
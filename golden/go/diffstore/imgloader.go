@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"image"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -112,14 +113,45 @@ type errResult struct {
 	id  string
 }
 
-// Get returns the images identified by digests and returns them as NRGBA images.
+// Get returns the images identified by digests in their native color model,
+// i.e. high bit depth images are not converted to 8 bits per channel.
 // Priority determines the order in which multiple concurrent calls are processed.
 // The returned instance of WaitGroup can be used to wait until all images are
 // not just loaded but also written to disk. Calling the Wait() function of the
 // WaitGroup is optional and the client should not call any of its other functions.
-func (il *ImageLoader) Get(priority int64, images []string) ([]*image.NRGBA, *sync.WaitGroup, error) {
+func (il *ImageLoader) Get(priority int64, images []string) ([]image.Image, *sync.WaitGroup, error) {
+	imgWrappers, pendingWritesWG, err := il.getImgRets(priority, images)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := make([]image.Image, len(imgWrappers))
+	for idx, ret := range imgWrappers {
+		result[idx] = ret.img
+	}
+	return result, pendingWritesWG, nil
+}
+
+// ImageInfo returns the format and bit depth of the given images. The returned
+// slice has the same order as the given images.
+func (il *ImageLoader) ImageInfo(priority int64, images []string) ([]*diff.ImageInfo, error) {
+	imgWrappers, _, err := il.getImgRets(priority, images)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*diff.ImageInfo, len(imgWrappers))
+	for idx, ret := range imgWrappers {
+		result[idx] = ret.info
+	}
+	return result, nil
+}
+
+// getImgRets loads the given images in parallel and returns the wrappers
+// that contain the images and their information. See Get for the semantics
+// of priority and the returned WaitGroup.
+func (il *ImageLoader) getImgRets(priority int64, images []string) (imgRetSlice, *sync.WaitGroup, error) {
 	// Parallel load the requested images.
-	result := make([]*image.NRGBA, len(images))
 	imgWrappers := make(imgRetSlice, len(images))
 	errCh := make(chan errResult, len(images))
 	var wg sync.WaitGroup
@@ -133,9 +165,7 @@ func (il *ImageLoader) Get(priority int64, images []string) ([]*image.NRGBA, *sy
 			} else {
 				// Extract the image and make sure after the first retrieval the channels
 				// are removed as well.
-				ret := tmp.(*imgRet)
-				result[idx] = ret.img
-				imgWrappers[idx] = ret
+				imgWrappers[idx] = tmp.(*imgRet)
 			}
 		}(idx, id)
 	}
@@ -159,7 +189,7 @@ func (il *ImageLoader) Get(priority int64, images []string) ([]*image.NRGBA, *sy
 		return nil, nil, errors.New(msg.String())
 	}
 
-	return result, pendingWritesWG, nil
+	return imgWrappers, pendingWritesWG, nil
 }
 
 // IsOnDisk returns true if the image that corresponds to the given imageID is in the disk cache.
@@ -193,13 +223,13 @@ func (il *ImageLoader) imageLoadWorker(priority int64, imageID string) (interfac
 	localRelPath, bucket, gsRelPath := il.mapper.ImagePaths(imageID)
 	localPath := filepath.Join(il.localImgDir, localRelPath)
 	if fileutil.FileExists(localPath) {
-		img, err := loadImg(localPath)
+		img, info, err := loadImg(localPath)
 		if err != nil {
 			util.LogErr(il.failureStore.addDigestFailure(diff.NewDigestFailure(imageID, diff.CORRUPTED)))
 			return nil, err
 		}
 		util.LogErr(il.failureStore.purgeDigestFailures([]string{imageID}))
-		return &imgRet{img: img}, nil
+		return &imgRet{img: img, info: info}, nil
 	}
 
 	// Download the image
@@ -210,7 +240,7 @@ func (il *ImageLoader) imageLoadWorker(priority int64, imageID string) (interfac
 	}
 
 	// Decode it and return it.
-	img, err := decodeImg(bytes.NewBuffer(imgBytes))
+	img, info, err := decodeImg(bytes.NewBuffer(imgBytes))
 	if err != nil {
 		util.LogErr(il.failureStore.addDigestFailure(diff.NewDigestFailure(imageID, diff.CORRUPTED)))
 		return nil, err
@@ -218,7 +248,7 @@ func (il *ImageLoader) imageLoadWorker(priority int64, imageID string) (interfac
 
 	// Save the file to disk.
 	writeDoneCh := il.saveImgInfoAsync(imageID, imgBytes)
-	return &imgRet{writtenCh: writeDoneCh, img: img}, nil
+	return &imgRet{writtenCh: writeDoneCh, img: img, info: info}, nil
 }
 
 func (il *ImageLoader) saveImgInfoAsync(imageID string, imgBytes []byte) <-chan bool {
@@ -260,7 +290,12 @@ func (il *ImageLoader) downloadImg(bucket, gsPath string) ([]byte, error) {
 
 // downloadImgFromBucket retrieves the given image from the given Google storage bucket.
 // It returns storage.ErrObjectNotExist if the given image does not exist in the bucket.
+// If the bucket name starts with fileutil.LOCAL_PATH_PREFIX the image is read from the local file system.
 func (il *ImageLoader) downloadImgFromBucket(objLocation, bucketName string) ([]byte, error) {
+	if localDir, ok := localBucketDir(bucketName); ok {
+		return ioutil.ReadFile(filepath.Join(localDir, objLocation))
+	}
+
 	ctx := context.Background()
 
 	// Retrieve the attributes.
@@ -310,7 +345,8 @@ func (il *ImageLoader) downloadImgFromBucket(objLocation, bucketName string) ([]
 	return buf.Bytes(), err
 }
 
-// removeImg removes the image that corresponds to the given relative path from GCS.
+// removeImg removes the image that corresponds to the given relative path from GCS
+// or from the local directories given via fileutil.LOCAL_PATH_PREFIX.
 func (il *ImageLoader) removeImg(bucket, gsRelPath string) {
 	// If the bucket is not empty then look there otherwise use the default buckets.
 	objLocation := filepath.Join(il.gsImageBaseDir, gsRelPath)
@@ -321,6 +357,14 @@ func (il *ImageLoader) removeImg(bucket, gsRelPath string) {
 
 	ctx := context.Background()
 	for _, bucketName := range bucketNames {
+		if localDir, ok := localBucketDir(bucketName); ok {
+			localPath := filepath.Join(localDir, objLocation)
+			if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+				sklog.Errorf("Unable to delete existing file at %s. Got error: %s", localPath, err)
+			}
+			continue
+		}
+
 		// Retrieve the attributes to test if the file exists.
 		_, err := il.storageClient.Bucket(bucketName).Object(objLocation).Attrs(ctx)
 		if err != nil {
@@ -338,12 +382,19 @@ func (il *ImageLoader) removeImg(bucket, gsRelPath string) {
 	}
 }
 
+// localBucketDir returns the directory on the local file system and true if
+// the given bucket name starts with fileutil.LOCAL_PATH_PREFIX.
+func localBucketDir(bucketName string) (string, bool) {
+	return fileutil.LocalPath(bucketName)
+}
+
 // imgRet is a container type used to return the loaded image and a channel
 // that is closed after the image had been written to disk or nil if the image
 // was already on disk and/or RAM.
 type imgRet struct {
 	writtenCh <-chan bool // will be closed after the image has been written to disk
-	img       *image.NRGBA
+	img       image.Image
+	info      *diff.ImageInfo
 	mutex     sync.Mutex
 }
 
@@ -2,6 +2,9 @@ package diffstore
 
 import (
 	"fmt"
+	"image"
+	"image/color"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
@@ -65,7 +68,7 @@ func TestImageLoader(t *testing.T) {
 	localPath := filepath.Join(imageLoader.localImgDir, relLocalPath)
 	localImg, err := diff.OpenNRGBAFromFile(localPath)
 	assert.NoError(t, err)
-	assert.Equal(t, foundImgs[0], localImg)
+	assert.Equal(t, diff.GetNRGBA(foundImgs[0]), localImg)
 }
 
 // Calls TwoLevelRadixPath to create the local image file path.
@@ -88,3 +91,40 @@ func getImageLoaderAndTile(t testutils.TestingT, mapper DiffStoreMapper) (string
 	assert.NoError(t, err)
 	return workingDir, tile, imgLoader, cleanup
 }
+
+func TestImageLoaderLocalBucket(t *testing.T) {
+	testutils.MediumTest(t)
+
+	w, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	// Write an image into the directory that serves as the bucket.
+	bucketDir := filepath.Join(w, "bucket")
+	imgDir := filepath.Join(bucketDir, "dm-images-v1")
+	assert.NoError(t, os.MkdirAll(imgDir, 0755))
+	digest := "aabbccddeeff00112233445566778899"
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(1, 1, color.NRGBA{R: 0xff, A: 0xff})
+	imgPath := filepath.Join(imgDir, digest+"."+IMG_EXTENSION)
+	f, err := os.Create(imgPath)
+	assert.NoError(t, err)
+	assert.NoError(t, encodeImg(f, img))
+	assert.NoError(t, f.Close())
+
+	workingDir := filepath.Join(w, "images")
+	bucketNames := []string{fileutil.LOCAL_PATH_PREFIX + bucketDir}
+	imgLoader, err := NewImgLoader(http.DefaultClient, w, workingDir, bucketNames, "dm-images-v1", 10, GoldDiffStoreMapper{})
+	assert.NoError(t, err)
+
+	foundImgs, pendingWrites, err := imgLoader.Get(1, []string{digest})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(foundImgs))
+	assert.Equal(t, img, diff.GetNRGBA(foundImgs[0]))
+	pendingWrites.Wait()
+	assert.True(t, imgLoader.IsOnDisk(digest))
+
+	// Purging removes the image from the disk cache and the local bucket.
+	assert.NoError(t, imgLoader.PurgeImages([]string{digest}, true))
+	assert.False(t, imgLoader.IsOnDisk(digest))
+	assert.False(t, fileutil.FileExists(imgPath))
+}
@@ -26,17 +26,33 @@ const (
 // as the Gold diff metric.
 type GoldDiffStoreMapper struct {
 	util.LRUCodec
+
+	// cfg contains the parameters of the diff metrics.
+	cfg *diff.Config
 }
 
 // NewGoldDiffStoreMapper returns a new instance of GoldDiffStoreMapper that uses
-// a JSON coded to serialize/deserialize instances of diff.DiffMetrics.
-func NewGoldDiffStoreMapper(diffInstance interface{}) DiffStoreMapper {
-	return GoldDiffStoreMapper{LRUCodec: util.JSONCodec(diffInstance)}
+// a JSON coded to serialize/deserialize instances of diff.DiffMetrics. The
+// diff metrics are calculated with the given diff.Config. If cfg is nil,
+// diff.DefaultConfig() is used.
+func NewGoldDiffStoreMapper(diffInstance interface{}, cfg *diff.Config) DiffStoreMapper {
+	if cfg == nil {
+		cfg = diff.DefaultConfig()
+	}
+	return GoldDiffStoreMapper{
+		LRUCodec: util.JSONCodec(diffInstance),
+		cfg:      cfg,
+	}
 }
 
 // DiffFn implements the DiffStoreMapper interface.
-func (g GoldDiffStoreMapper) DiffFn(leftImg *image.NRGBA, rightImg *image.NRGBA) (interface{}, *image.NRGBA) {
-	return diff.DefaultDiffFn(leftImg, rightImg)
+func (g GoldDiffStoreMapper) DiffFn(leftImg image.Image, rightImg image.Image) (interface{}, *image.NRGBA) {
+	return g.cfg.DiffFn(leftImg, rightImg)
+}
+
+// MetricsVersion implements the DiffStoreMapper interface.
+func (g GoldDiffStoreMapper) MetricsVersion() string {
+	return g.cfg.Version()
 }
 
 // DiffID implements the DiffStoreMapper interface.
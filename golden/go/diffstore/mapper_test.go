@@ -98,7 +98,7 @@ func TestCodec(t *testing.T) {
 	client, _ := getSetupAndTile(t, baseDir)
 
 	// Instantiate a new MemDiffStore with a codec for the test struct defined above.
-	mapper := NewGoldDiffStoreMapper(&DummyDiffMetrics{})
+	mapper := NewGoldDiffStoreMapper(&DummyDiffMetrics{}, nil)
 	diffStore, err := NewMemDiffStore(client, baseDir, []string{TEST_GCS_BUCKET_NAME}, TEST_GCS_IMAGE_DIR, 10, mapper)
 	assert.NoError(t, err)
 	memDiffStore := diffStore.(*MemDiffStore)
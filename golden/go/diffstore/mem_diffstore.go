@@ -51,8 +51,14 @@ type DiffStoreMapper interface {
 	// DiffFn calculates the different between two given images and returns a
 	// difference image. The type underlying interface{} is the input and output
 	// of the LRUCodec above. It is also what is returned by the Get(...) function
-	// of the DiffStore interface.
-	DiffFn(*image.NRGBA, *image.NRGBA) (interface{}, *image.NRGBA)
+	// of the DiffStore interface. The images are passed in their native color
+	// model, e.g. as *image.NRGBA64 for 16-bit images.
+	DiffFn(image.Image, image.Image) (interface{}, *image.NRGBA)
+
+	// MetricsVersion identifies the diff metrics calculated by DiffFn,
+	// including any parameters they depend on. Stored diff metrics with a
+	// different version are recalculated.
+	MetricsVersion() string
 
 	// Takes two image IDs and returns a unique diff ID.
 	// Note: DiffID(a,b) == DiffID(b, a) should hold.
@@ -218,6 +224,20 @@ func (m *MemDiffStore) UnavailableDigests() map[string]*diff.DigestFailure {
 	return m.imgLoader.failureStore.unavailableDigests()
 }
 
+// ImageInfo implements the DiffStore interface.
+func (m *MemDiffStore) ImageInfo(priority int64, digests []string) (map[string]*diff.ImageInfo, error) {
+	infos, err := m.imgLoader.ImageInfo(priority, digests)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]*diff.ImageInfo, len(digests))
+	for idx, digest := range digests {
+		ret[digest] = infos[idx]
+	}
+	return ret, nil
+}
+
 // PurgeDigests implements the DiffStore interface.
 func (m *MemDiffStore) PurgeDigests(digests []string, purgeGCS bool) error {
 	// We remove the given digests from the various places where they might
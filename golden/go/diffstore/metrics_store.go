@@ -39,6 +39,10 @@ type metricsStore struct {
 
 	// factory acts as the codec for metrics and is used to create instances of metricsRec.
 	factory *metricsRecFactory
+
+	// version is the DiffStoreMapper.MetricsVersion of the diff metrics
+	// calculated by this process.
+	version string
 }
 
 // metricsRec implements the boltutil.Record interface.
@@ -46,6 +50,10 @@ type metricsRec struct {
 	ID          string `json:"id"`
 	DiffMetrics []byte
 
+	// Version is the DiffStoreMapper.MetricsVersion the diff metrics were
+	// calculated with.
+	Version string `json:"version"`
+
 	// Split function that is configurable and injected by metricsRecFactory.
 	splitFn func(string) (string, string)
 }
@@ -70,10 +78,11 @@ type metricsRecFactory struct {
 }
 
 // newRec creates a new instance of metricsRec injecting the split function.
-func (m *metricsRecFactory) newRec(id string, diffMetrics []byte) *metricsRec {
+func (m *metricsRecFactory) newRec(id string, diffMetrics []byte, version string) *metricsRec {
 	return &metricsRec{
 		ID:          id,
 		DiffMetrics: diffMetrics,
+		Version:     version,
 		splitFn:     m.splitFn,
 	}
 }
@@ -118,10 +127,13 @@ func newMetricsStore(baseDir string, mapper DiffStoreMapper, codec util.LRUCodec
 		codec:   codec,
 		mapper:  mapper,
 		factory: factoryCodec,
+		version: mapper.MetricsVersion(),
 	}, nil
 }
 
-// loadDiffMetrics loads diff metrics from disk.
+// loadDiffMetrics loads diff metrics from disk. Diff metrics that were
+// calculated with a different version are not returned, so that they are
+// recalculated.
 func (m *metricsStore) loadDiffMetrics(id string) (interface{}, error) {
 	recs, err := m.store.Read([]string{id})
 	if err != nil {
@@ -132,9 +144,13 @@ func (m *metricsStore) loadDiffMetrics(id string) (interface{}, error) {
 		return nil, nil
 	}
 
+	rec := recs[0].(*metricsRec)
+	if rec.Version != m.version {
+		return nil, nil
+	}
+
 	// TODO(stephana): Remove the database guard below when we don't need it anymore.
 	// get the record and check if it's a legacy entry.
-	rec := recs[0].(*metricsRec)
 	if (len(rec.DiffMetrics) == 0) || strings.Contains(id, ":") {
 		if diffMetrics := m.fixLegacyRecord(id, rec.DiffMetrics); diffMetrics != nil {
 			return diffMetrics, nil
@@ -161,7 +177,7 @@ func (m *metricsStore) saveDiffMetrics(id string, diffMetrics interface{}) error
 		return fmt.Errorf("Got empty string for encoded diff metric.")
 	}
 
-	rec := m.factory.newRec(id, bytes)
+	rec := m.factory.newRec(id, bytes, m.version)
 	return m.store.Insert([]boltutil.Record{rec})
 }
 
@@ -0,0 +1,56 @@
+package diffstore
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/golden/go/diff"
+)
+
+func TestMetricsStoreVersion(t *testing.T) {
+	testutils.MediumTest(t)
+
+	baseDir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	newStore := func(cfg *diff.Config) *metricsStore {
+		mapper := NewGoldDiffStoreMapper(&diff.DiffMetrics{}, cfg)
+		mStore, err := newMetricsStore(baseDir, mapper, mapper)
+		assert.NoError(t, err)
+		return mStore
+	}
+
+	mStore := newStore(nil)
+	diffID := mStore.mapper.DiffID(TEST_GOLD_LEFT, TEST_GOLD_RIGHT)
+	diffMetrics := &diff.DiffMetrics{
+		NumDiffPixels: 10,
+		MaxRGBADiffs:  []int{1, 2, 3, 4},
+		Diffs:         map[string]float32{diff.METRIC_COMBINED: 0.5},
+	}
+	assert.NoError(t, mStore.saveDiffMetrics(diffID, diffMetrics))
+	found, err := mStore.loadDiffMetrics(diffID)
+	assert.NoError(t, err)
+	assert.Equal(t, diffMetrics, found)
+	assert.NoError(t, mStore.store.DB.Close())
+
+	// Diff metrics calculated with different parameters are not returned.
+	cfg := diff.DefaultConfig()
+	cfg.FuzzyMaxDiffPixels++
+	mStore = newStore(cfg)
+	found, err = mStore.loadDiffMetrics(diffID)
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+	assert.NoError(t, mStore.store.DB.Close())
+
+	// Neither are diff metrics which were stored without a version, i.e.
+	// before the custom diff metrics could change.
+	mStore = newStore(nil)
+	mStore.version = ""
+	assert.NoError(t, mStore.saveDiffMetrics(diffID, diffMetrics))
+	mStore.version = diff.DefaultConfig().Version()
+	found, err = mStore.loadDiffMetrics(diffID)
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+	assert.NoError(t, mStore.store.DB.Close())
+}
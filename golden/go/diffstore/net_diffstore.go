@@ -118,3 +118,21 @@ func (n *NetDiffStore) PurgeDigests(digests []string, purgeGCS bool) error {
 	}
 	return nil
 }
+
+// ImageInfo, see the diff.DiffStore interface.
+func (n *NetDiffStore) ImageInfo(priority int64, digests []string) (map[string]*diff.ImageInfo, error) {
+	req := &ImageInfoRequest{Priority: priority, Digests: digests}
+	resp, err := n.serviceClient.ImageInfo(context.Background(), req)
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving image info: %s", err)
+	}
+
+	ret := make(map[string]*diff.ImageInfo, len(resp.ImageInfos))
+	for digest, info := range resp.ImageInfos {
+		ret[digest] = &diff.ImageInfo{
+			Format:   info.Format,
+			BitDepth: int(info.BitDepth),
+		}
+	}
+	return ret, nil
+}
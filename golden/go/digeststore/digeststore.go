@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sync"
 
 	"github.com/boltdb/bolt"
+	"go.skia.org/infra/go/tiling"
 )
 
 const (
@@ -34,6 +36,14 @@ type DigestInfo struct {
 
 	// IssueIDs is a list of issue ids that are associated with this digest.
 	IssueIDs []int
+
+	// FirstCommit is the commit at which this digest was first seen.
+	FirstCommit *tiling.Commit
+
+	// Blame contains the commits that likely introduced this digest. It is
+	// calculated while the digest is part of the current tile and kept after
+	// the digest was first seen before the start of the tile.
+	Blame []*tiling.Commit
 }
 
 // UpdateTimestamps updates the time stamps of a DigestInfo based on the
@@ -51,6 +61,48 @@ func (d *DigestInfo) UpdateTimestamps(first int64, last int64) bool {
 	return changed
 }
 
+// UpdateBlame updates the first commit and the blamed commits of a DigestInfo
+// based on the arguments. The first commit is only replaced by an earlier
+// commit. The blame is only replaced by a blame that starts no later than the
+// current one, since the blame calculated from a tile narrows once the start of
+// its range has moved out of the tile. It returns true if the digest info was
+// modified.
+func (d *DigestInfo) UpdateBlame(firstCommit *tiling.Commit, blame []*tiling.Commit) bool {
+	changed := false
+	if (firstCommit != nil) && ((d.FirstCommit == nil) || (firstCommit.CommitTime < d.FirstCommit.CommitTime)) {
+		d.FirstCommit = firstCommit
+		changed = true
+	}
+	if BlameStartsNoLater(blame, d.Blame) && !sameCommits(d.Blame, blame) {
+		d.Blame = blame
+		changed = true
+	}
+	return changed
+}
+
+// BlameStartsNoLater returns true if 'blame' is not empty and its first commit
+// is not later than the first commit of 'current'.
+func BlameStartsNoLater(blame, current []*tiling.Commit) bool {
+	if len(blame) == 0 {
+		return false
+	}
+	return (len(current) == 0) || (blame[0].CommitTime <= current[0].CommitTime)
+}
+
+// sameCommits returns true if both slices contain the same commit hashes in
+// the same order.
+func sameCommits(a, b []*tiling.Commit) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Hash != b[i].Hash {
+			return false
+		}
+	}
+	return true
+}
+
 type DigestStore interface {
 	// Get returns the information about the given testName/digest pair.
 	Get(testName, digest string) (*DigestInfo, bool, error)
@@ -114,7 +166,7 @@ func (b BoltDigestStore) Update(digestInfos []*DigestInfo) error {
 			// record it.
 			if !found {
 				writeDigestInfos = append(writeDigestInfos, digestInfo)
-			} else if di.UpdateTimestamps(digestInfo.First, digestInfo.Last) {
+			} else if updateDigestInfo(di, digestInfo) {
 				writeDigestInfos = append(writeDigestInfos, di)
 			}
 		}
@@ -142,3 +194,60 @@ func (b BoltDigestStore) Update(digestInfos []*DigestInfo) error {
 		return nil
 	})
 }
+
+// updateDigestInfo merges the timestamps and the blame of 'update' into
+// 'current'. It returns true if 'current' was modified.
+func updateDigestInfo(current, update *DigestInfo) bool {
+	tsChanged := current.UpdateTimestamps(update.First, update.Last)
+	blameChanged := current.UpdateBlame(update.FirstCommit, update.Blame)
+	return tsChanged || blameChanged
+}
+
+// MemDigestStore is an in-memory implementation of DigestStore. It is
+// primarily used for testing.
+type MemDigestStore struct {
+	digestInfos map[string]map[string]*DigestInfo
+	mutex       sync.Mutex
+}
+
+// NewMemDigestStore returns a new instance of MemDigestStore.
+func NewMemDigestStore() DigestStore {
+	return &MemDigestStore{
+		digestInfos: map[string]map[string]*DigestInfo{},
+	}
+}
+
+// Get implements the DigestStore interface.
+func (m *MemDigestStore) Get(testName, digest string) (*DigestInfo, bool, error) {
+	if testName == "" {
+		return nil, false, fmt.Errorf("No testname provided for digest '%s'", digest)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	di, ok := m.digestInfos[testName][digest]
+	if !ok {
+		return nil, false, nil
+	}
+	ret := *di
+	return &ret, true, nil
+}
+
+// Update implements the DigestStore interface.
+func (m *MemDigestStore) Update(digestInfos []*DigestInfo) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, digestInfo := range digestInfos {
+		if _, ok := m.digestInfos[digestInfo.TestName]; !ok {
+			m.digestInfos[digestInfo.TestName] = map[string]*DigestInfo{}
+		}
+		current, ok := m.digestInfos[digestInfo.TestName][digestInfo.Digest]
+		if !ok {
+			cp := *digestInfo
+			m.digestInfos[digestInfo.TestName][digestInfo.Digest] = &cp
+			continue
+		}
+		updateDigestInfo(current, digestInfo)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 
 	assert "github.com/stretchr/testify/require"
 	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/tiling"
 )
 
 const TEST_DATA_DIR = "testdata"
@@ -21,6 +22,11 @@ func TestDigestStore(t *testing.T) {
 	testDigestStore(t, digestStore)
 }
 
+func TestMemDigestStore(t *testing.T) {
+	testutils.SmallTest(t)
+	testDigestStore(t, NewMemDigestStore())
+}
+
 func testDigestStore(t assert.TestingT, digestStore DigestStore) {
 	testName_1, digest_1 := "smapleTest_1", "sampleDigest_1"
 	timestamp_1 := time.Now().Unix() - 20
@@ -54,4 +60,29 @@ func testDigestStore(t assert.TestingT, digestStore DigestStore) {
 
 	assert.Equal(t, timestamp_1, di.First)
 	assert.Equal(t, timestamp_2, di.Last)
+
+	// The first commit is only replaced by an earlier commit and the blame
+	// only by a blame that starts no later.
+	c1 := &tiling.Commit{Hash: "aaa", CommitTime: timestamp_1}
+	c2 := &tiling.Commit{Hash: "bbb", CommitTime: timestamp_2}
+	digestInfos = []*DigestInfo{
+		{TestName: testName_1, Digest: digest_1, First: timestamp_2, Last: timestamp_2, FirstCommit: c2, Blame: []*tiling.Commit{c2}},
+	}
+	assert.NoError(t, digestStore.Update(digestInfos))
+	digestInfos = []*DigestInfo{
+		{TestName: testName_1, Digest: digest_1, First: timestamp_1, Last: timestamp_1, FirstCommit: c1, Blame: []*tiling.Commit{c1, c2}},
+	}
+	assert.NoError(t, digestStore.Update(digestInfos))
+	digestInfos = []*DigestInfo{
+		{TestName: testName_1, Digest: digest_1, First: timestamp_2, Last: timestamp_2, FirstCommit: c2, Blame: []*tiling.Commit{c2}},
+	}
+	assert.NoError(t, digestStore.Update(digestInfos))
+
+	di, ok, err = digestStore.Get(testName_1, digest_1)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, c1, di.FirstCommit)
+	assert.Equal(t, []*tiling.Commit{c1, c2}, di.Blame)
+	assert.Equal(t, timestamp_1, di.First)
+	assert.Equal(t, timestamp_2, di.Last)
 }
@@ -16,6 +16,9 @@ type Closest struct {
 	Diff       float32 `json:"diff"`       // A percent value.
 	DiffPixels float32 `json:"diffPixels"` // A percent value.
 	MaxRGBA    []int   `json:"maxRGBA"`
+
+	// Diffs contains the values of all the diff metrics, see diff.GetDiffMetricIDs().
+	Diffs map[string]float32 `json:"diffs"`
 }
 
 func newClosest() *Closest {
@@ -23,13 +26,17 @@ func newClosest() *Closest {
 		Diff:       math.MaxFloat32,
 		DiffPixels: math.MaxFloat32,
 		MaxRGBA:    []int{},
+		Diffs:      map[string]float32{},
 	}
 }
 
 // ClosestDigest returns the closest digest of type 'label' to 'digest', or "" if there aren't any positive digests.
 //
+// Closeness is measured by 'metric', which is one of diff.GetDiffMetricIDs(),
+// and Closest.Diff is the value of that metric.
+//
 // If no digest of type 'label' is found then Closest.Digest is the empty string.
-func ClosestDigest(test string, digest string, exp types.Expectations, tallies tally.Tally, diffStore diff.DiffStore, label types.Label) *Closest {
+func ClosestDigest(test string, digest string, exp types.Expectations, tallies tally.Tally, diffStore diff.DiffStore, label types.Label, metric string) *Closest {
 	ret := newClosest()
 	unavailableDigests := diffStore.UnavailableDigests()
 
@@ -54,11 +61,12 @@ func ClosestDigest(test string, digest string, exp types.Expectations, tallies t
 	} else {
 		for digest, diffs := range diffMetrics {
 			dm := diffs.(*diff.DiffMetrics)
-			if delta := combinedDiffMetric(dm.PixelDiffPercent, dm.MaxRGBADiffs); delta < ret.Diff {
+			if delta := metricValue(dm, metric); delta < ret.Diff {
 				ret.Digest = digest
 				ret.Diff = delta
 				ret.DiffPixels = dm.PixelDiffPercent
 				ret.MaxRGBA = dm.MaxRGBADiffs
+				ret.Diffs = dm.Diffs
 			}
 		}
 		return ret
@@ -72,7 +80,22 @@ func ClosestFromDiffMetrics(diff *diff.DiffMetrics) *Closest {
 		Diff:       combinedDiffMetric(diff.PixelDiffPercent, diff.MaxRGBADiffs),
 		DiffPixels: diff.PixelDiffPercent,
 		MaxRGBA:    diff.MaxRGBADiffs,
+		Diffs:      diff.Diffs,
+	}
+}
+
+// metricValue returns the value of the given metric from the DiffMetrics. The
+// combined metric is calculated if it is missing, e.g. for DiffMetrics that
+// were cached before the metrics were added to DiffMetrics.Diffs. Other
+// missing metrics are treated as infinitely far away.
+func metricValue(dm *diff.DiffMetrics, metric string) float32 {
+	if value, ok := dm.Diffs[metric]; ok {
+		return value
+	}
+	if metric == diff.METRIC_COMBINED {
+		return combinedDiffMetric(dm.PixelDiffPercent, dm.MaxRGBADiffs)
 	}
+	return math.MaxFloat32
 }
 
 // combinedDiffMetric returns a value in [0, 1] that represents how large
@@ -19,8 +19,12 @@ func (m MockDiffStore) WarmDigests(priority int64, digests []string, sync bool)
 func (m MockDiffStore) WarmDiffs(priority int64, leftDigests []string, rightDigests []string) {}
 func (m MockDiffStore) UnavailableDigests() map[string]*diff.DigestFailure                    { return nil }
 func (m MockDiffStore) PurgeDigests(digests []string, purgeGCS bool) error                    { return nil }
+func (m MockDiffStore) ImageInfo(priority int64, digests []string) (map[string]*diff.ImageInfo, error) {
+	return nil, nil
+}
 
-// Get always finds that digest "eee" is closest to dMain.
+// Get always finds that digest "eee" is closest to dMain, except by the SSIM
+// metric, where "aaa" is closest.
 func (m MockDiffStore) Get(priority int64, dMain string, dRest []string) (map[string]interface{}, error) {
 	result := map[string]interface{}{}
 	for i, d := range dRest {
@@ -28,9 +32,14 @@ func (m MockDiffStore) Get(priority int64, dMain string, dRest []string) (map[st
 		if d == "eee" {
 			diffPercent = 0.1
 		}
+		ssim := float32(0.5)
+		if d == "aaa" {
+			ssim = 0.01
+		}
 		result[d] = &diff.DiffMetrics{
 			PixelDiffPercent: diffPercent,
 			MaxRGBADiffs:     []int{5, 3, 4, 0},
+			Diffs:            map[string]float32{diff.METRIC_SSIM: ssim},
 		}
 	}
 	return result, nil
@@ -59,22 +68,32 @@ func TestClosestDigest(t *testing.T) {
 	exp := types.NewExpectations(testExp)
 
 	// First test against a test that has positive digests.
-	c := ClosestDigest("foo", "fff", exp, tallies, diffStore, types.POSITIVE)
+	c := ClosestDigest("foo", "fff", exp, tallies, diffStore, types.POSITIVE, diff.METRIC_COMBINED)
 	assert.InDelta(t, 0.0372, float64(c.Diff), 0.01)
 	assert.Equal(t, "eee", c.Digest)
 	assert.Equal(t, []int{5, 3, 4, 0}, c.MaxRGBA)
 
 	// Now test against a test with no positive digests.
-	c = ClosestDigest("bar", "fff", exp, tallies, diffStore, types.POSITIVE)
+	c = ClosestDigest("bar", "fff", exp, tallies, diffStore, types.POSITIVE, diff.METRIC_COMBINED)
 	assert.Equal(t, float32(math.MaxFloat32), c.Diff)
 	assert.Equal(t, "", c.Digest)
 	assert.Equal(t, []int{}, c.MaxRGBA)
 
 	// Now test against negative digests.
-	c = ClosestDigest("foo", "fff", exp, tallies, diffStore, types.NEGATIVE)
+	c = ClosestDigest("foo", "fff", exp, tallies, diffStore, types.NEGATIVE, diff.METRIC_COMBINED)
 	assert.InDelta(t, 0.166, float64(c.Diff), 0.01)
 	assert.Equal(t, "bbb", c.Digest)
 	assert.Equal(t, []int{5, 3, 4, 0}, c.MaxRGBA)
+
+	// Now test using a different metric.
+	c = ClosestDigest("foo", "fff", exp, tallies, diffStore, types.POSITIVE, diff.METRIC_SSIM)
+	assert.Equal(t, "aaa", c.Digest)
+	assert.Equal(t, float32(0.01), c.Diff)
+	assert.Equal(t, map[string]float32{diff.METRIC_SSIM: 0.01}, c.Diffs)
+
+	// A metric that isn't available finds nothing.
+	c = ClosestDigest("foo", "fff", exp, tallies, diffStore, types.POSITIVE, diff.METRIC_FUZZY)
+	assert.Equal(t, "", c.Digest)
 }
 
 func TestCombinedDiffMetric(t *testing.T) {
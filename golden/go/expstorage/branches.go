@@ -0,0 +1,152 @@
+package expstorage
+
+import (
+	"fmt"
+	"regexp"
+
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/golden/go/types"
+)
+
+const (
+	// EV_BRANCH_EXP_CHANGED is the event type that is fired when the expectations
+	// of a branch change. It sends an instance of *EventExpectationChange.
+	EV_BRANCH_EXP_CHANGED = "expstorage:branch-exp-change"
+
+	// MASTER_BRANCH is the name of the master branch. It cannot be used as the
+	// name of an expectations branch.
+	MASTER_BRANCH = "master"
+)
+
+// validBranchName matches the names of expectation branches, e.g. "release-m71".
+var validBranchName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{0,99}$`)
+
+// BranchExpStoreFactory creates an ExpectationsStore instance for the given
+// branch. The store only contains the expectations that override the master
+// expectations on that branch. See BranchExpectations.
+type BranchExpStoreFactory func(branch string) (ExpectationsStore, error)
+
+// ValidBranchName returns true if the given string can be used as the name of
+// an expectations branch.
+func ValidBranchName(branch string) bool {
+	return (branch != MASTER_BRANCH) && validBranchName.MatchString(branch)
+}
+
+// BranchExpectations returns the expectations of a branch, i.e. the master
+// expectations with the expectations of the branch applied on top of them.
+func BranchExpectations(masterStore, branchStore ExpectationsStore) (types.Expectations, error) {
+	masterExp, err := masterStore.Get()
+	if err != nil {
+		return nil, sklog.FmtErrorf("Unable to load expectations for master: %s", err)
+	}
+	branchExp, err := branchStore.Get()
+	if err != nil {
+		return nil, sklog.FmtErrorf("Unable to load expectations for branch: %s", err)
+	}
+
+	ret := types.NewExpectations(masterExp.TestExp().DeepCopy())
+	ret.AddTestExp(branchExp.TestExp())
+	return ret, nil
+}
+
+// branchMerger is implemented by the ExpectationsStores of the master branch
+// that support merging expectation branches.
+type branchMerger interface {
+	// mergeBranch atomically adds the expectations of the given branch store
+	// to this store as a single change and removes them from the branch. The
+	// change records the merged branch and is attributed to the given user.
+	// Returns the merged expectations.
+	mergeBranch(branchStore ExpectationsStore, userID string) (types.TestExp, error)
+
+	// mergedBranch returns the name of the branch that was merged by the
+	// given change or an empty string if the change did not merge a branch.
+	mergedBranch(changeID int64) (string, error)
+
+	// undoMerge atomically undoes the given change, which merged the given
+	// branch store, and restores the expectations of the branch that were
+	// merged, unless they have been changed on the branch since.
+	undoMerge(changeID int64, branchStore ExpectationsStore, userID string) (types.TestExp, error)
+}
+
+// MergeBranch promotes the triage decisions of the given branch to master. The
+// change to the master expectations is attributed to the given user and the
+// branch and removes the merged decisions from the branch in the same
+// transaction, so the branch inherits them from master again. The history of
+// the branch is kept. Undoing the change via UndoChange restores the branch.
+// It returns the changes that were added to master.
+func MergeBranch(branch string, masterStore, branchStore ExpectationsStore, userID string) (types.TestExp, error) {
+	merger, ok := masterStore.(branchMerger)
+	if !ok {
+		return nil, sklog.FmtErrorf("Expectations store does not support merging branches.")
+	}
+	changes, err := merger.mergeBranch(branchStore, userID)
+	if err != nil {
+		return nil, sklog.FmtErrorf("Unable to merge expectations of branch %s into master: %s", branch, err)
+	}
+	return changes, nil
+}
+
+// UndoChange undoes the given change of the master expectations. If the change
+// merged an expectations branch the merged triage decisions are also restored
+// on the branch, which is retrieved via branchStores.
+func UndoChange(masterStore ExpectationsStore, branchStores BranchExpStoreFactory, changeID int64, userID string) (types.TestExp, error) {
+	merger, ok := masterStore.(branchMerger)
+	if !ok {
+		return masterStore.UndoChange(changeID, userID)
+	}
+	branch, err := merger.mergedBranch(changeID)
+	if err != nil {
+		return nil, err
+	}
+	if branch == "" {
+		return masterStore.UndoChange(changeID, userID)
+	}
+
+	branchStore, err := branchStores(branch)
+	if err != nil {
+		return nil, sklog.FmtErrorf("Unable to undo merge of branch %s: %s", branch, err)
+	}
+	return merger.undoMerge(changeID, branchStore, userID)
+}
+
+// mergeUserID returns the user a merge of the given branch is attributed to.
+func mergeUserID(userID, branch string) string {
+	return fmt.Sprintf("%s:%s", userID, branch)
+}
+
+// removeMerged removes the given merged expectations from the given branch
+// expectations, unless the label on the branch has been changed since.
+func removeMerged(branchExp, merged types.TestExp) {
+	for testName, digests := range merged {
+		for digest, label := range digests {
+			if found, ok := branchExp[testName][digest]; ok && (found == label) {
+				delete(branchExp[testName], digest)
+				if len(branchExp[testName]) == 0 {
+					delete(branchExp, testName)
+				}
+			}
+		}
+	}
+}
+
+// restoreMerged adds the given merged expectations back to the given branch
+// expectations, unless the branch has a newer label for a digest.
+func restoreMerged(branchExp, merged types.TestExp) types.TestExp {
+	restored := types.TestExp{}
+	for testName, digests := range merged {
+		for digest, label := range digests {
+			if _, ok := branchExp[testName][digest]; ok {
+				continue
+			}
+			if _, ok := branchExp[testName]; !ok {
+				branchExp[testName] = types.TestClassification{}
+			}
+			branchExp[testName][digest] = label
+			if _, ok := restored[testName]; !ok {
+				restored[testName] = types.TestClassification{}
+			}
+			restored[testName][digest] = label
+		}
+	}
+	return restored
+}
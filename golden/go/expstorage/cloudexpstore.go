@@ -33,7 +33,8 @@ const (
 // To separate concerns, we store overall expectations (i.e. for the master branch)
 // in the ds.MASTER_EXP_CHANGE and ds.MASTER_TEST_DIGEST_EXP entities.
 // Expectations for Gerrit issues are stored in the ds.TRYJOB_EXP_CHANGE and
-// ds.TRYJOB_TEST_DIGEST_EXP entities.
+// ds.TRYJOB_TEST_DIGEST_EXP entities. Expectations for branches are stored in
+// the ds.BRANCH_EXP_CHANGE entities.
 //
 // We use instances of TDESlice to record both, expectations and expectation changes.
 // These are usually stored as child entities.
@@ -57,6 +58,10 @@ type CloudExpStore struct {
 	// i.e. the master branch
 	issueID int64
 
+	// branch is the name of the expectations branch or empty for the master
+	// branch and Gerrit issues.
+	branch string
+
 	client   *datastore.Client
 	eventBus eventbus.EventBus
 
@@ -88,7 +93,7 @@ type CloudExpStore struct {
 }
 
 // IssueExpStoreFactory creates an ExpectationsStore instance for the given issue id.
-type IssueExpStoreFactory func(issueID int64) ExpectationsStore
+type IssueExpStoreFactory func(issueID int64) (ExpectationsStore, error)
 
 // NewCloudExpectationsStore returns an ExpectationsStore implementation based on
 // Cloud Datastore for the master branch and a factory to create ExpectationsStore
@@ -122,7 +127,7 @@ func NewCloudExpectationsStore(client *datastore.Client, eventBus eventbus.Event
 
 	// The factory allows to create an isolated ExpectationStore instance for the
 	// given issue.
-	factory := func(issueID int64) ExpectationsStore {
+	factory := func(issueID int64) (ExpectationsStore, error) {
 		summaryKey := ds.NewKey(ds.HELPER_RECENT_KEYS)
 		summaryKey.Name = fmt.Sprintf("expstorage-issue-%d", issueID)
 		expectationsKey := ds.NewKey(ds.EXPECTATIONS_BLOB_ROOT)
@@ -138,7 +143,7 @@ func NewCloudExpectationsStore(client *datastore.Client, eventBus eventbus.Event
 			expectationsKey: expectationsKey,
 			recentKeysList:  dsutil.NewRecentKeysList(client, summaryKey, dsutil.DefaultConsistencyDelta),
 			blobStore:       blobStore,
-		}
+		}, nil
 	}
 
 	// Check the connection to the cloud datastore and if we could load the
@@ -150,6 +155,36 @@ func NewCloudExpectationsStore(client *datastore.Client, eventBus eventbus.Event
 	return store, factory, nil
 }
 
+// NewCloudBranchExpStoreFactory returns a factory to create ExpectationsStore
+// instances for expectation branches based on Cloud Datastore. It shares the
+// blob store with the stores created by NewCloudExpectationsStore.
+func NewCloudBranchExpStoreFactory(client *datastore.Client, eventBus eventbus.EventBus) (BranchExpStoreFactory, error) {
+	if client == nil {
+		return nil, sklog.FmtErrorf("Received nil for datastore client.")
+	}
+
+	blobStore := dsutil.NewBlobStore(client, ds.EXPECTATIONS_BLOB_ROOT, ds.EXPECTATIONS_BLOB)
+	return func(branch string) (ExpectationsStore, error) {
+		summaryKey := ds.NewKey(ds.HELPER_RECENT_KEYS)
+		summaryKey.Name = "expstorage-branch-" + branch
+		expectationsKey := ds.NewKey(ds.EXPECTATIONS_BLOB_ROOT)
+		expectationsKey.Name = "expstorage-expectations-branch-" + branch
+		return &CloudExpStore{
+			issueID:         masterIssueID,
+			branch:          branch,
+			changeKind:      ds.BRANCH_EXP_CHANGE,
+			eventExpChange:  EV_BRANCH_EXP_CHANGED,
+			globalEvent:     false,
+			client:          client,
+			eventBus:        eventBus,
+			summaryKey:      summaryKey,
+			expectationsKey: expectationsKey,
+			recentKeysList:  dsutil.NewRecentKeysList(client, summaryKey, dsutil.DefaultConsistencyDelta),
+			blobStore:       blobStore,
+		}, nil
+	}, nil
+}
+
 // Get implements the ExpectationsStore interface.
 func (c *CloudExpStore) Get() (types.Expectations, error) {
 	expectations, _, err := c.loadCurrentExpectations(nil)
@@ -260,9 +295,22 @@ func (c *CloudExpStore) QueryLog(offset, size int, details bool) ([]*TriageLogEn
 
 // UndoChange implements the ExpectationsStore interface.
 func (c *CloudExpStore) UndoChange(changeID int64, userID string) (types.TestExp, error) {
+	_, _, changes, err := c.prepareUndo(changeID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.makeChange(changes, userID, c.getUniqueTimeStampMs(), changeID, true)
+	return changes, err
+}
+
+// prepareUndo loads the change with the given id and calculates the changes
+// that undo it. It returns the change record, the changes of the change and
+// the changes that undo it.
+func (c *CloudExpStore) prepareUndo(changeID int64) (*ExpChange, types.TestExp, types.TestExp, error) {
 	// Make sure the entity is valid.
 	if changeID <= 0 {
-		return nil, sklog.FmtErrorf("Change with id %d does not exist.", changeID)
+		return nil, nil, nil, sklog.FmtErrorf("Change with id %d does not exist.", changeID)
 	}
 
 	// Fetch the change record of the change we want to undo.
@@ -271,33 +319,33 @@ func (c *CloudExpStore) UndoChange(changeID int64, userID string) (types.TestExp
 	expChangeKey.ID = changeID
 	if err := c.client.Get(context.TODO(), expChangeKey, expChange); err != nil {
 		if err == datastore.ErrNoSuchEntity {
-			return nil, sklog.FmtErrorf("Change with id %d does not exist.", changeID)
+			return nil, nil, nil, sklog.FmtErrorf("Change with id %d does not exist.", changeID)
 		}
-		return nil, sklog.FmtErrorf("Error retrieving change %d: %s", expChangeKey.ID, err)
+		return nil, nil, nil, sklog.FmtErrorf("Error retrieving change %d: %s", expChangeKey.ID, err)
 	}
 
 	// Fetch the actual changes.
 	undoChanges := types.TestExp{}
 	if err := c.blobStore.Load(expChange.ExpectationsBlob, &undoChanges); err != nil {
-		return nil, sklog.FmtErrorf("Error retrieving expectations blob: %s", err)
+		return nil, nil, nil, sklog.FmtErrorf("Error retrieving expectations blob: %s", err)
 	}
 
 	// If this has been undone already, then don't do it.
 	if expChange.UndoChangeID != 0 {
-		return nil, fmt.Errorf("Unable to undo change %d which was created as an undo of change %d.", changeID, expChange.UndoChangeID)
+		return nil, nil, nil, fmt.Errorf("Unable to undo change %d which was created as an undo of change %d.", changeID, expChange.UndoChangeID)
 	}
 
 	// Retrieve the keys of all changes prior to the one we want to undo to
 	// build the expectations at the time of the original change
 	prevChangeKeys, err := c.getExpChangeKeys(changeID)
 	if err != nil {
-		return nil, sklog.FmtErrorf("Error retrieving keys for expectation changes: %s", err)
+		return nil, nil, nil, sklog.FmtErrorf("Error retrieving keys for expectation changes: %s", err)
 	}
 
 	// Build the expectations at that point.
 	exps, err := c.CalcExpectations(prevChangeKeys)
 	if err != nil {
-		return nil, sklog.FmtErrorf("Unable to get expectations for undo: %s", err)
+		return nil, nil, nil, sklog.FmtErrorf("Unable to get expectations for undo: %s", err)
 	}
 	prevTestExp := exps.TestExp()
 
@@ -308,9 +356,107 @@ func (c *CloudExpStore) UndoChange(changeID int64, userID string) (types.TestExp
 			changes[testName][digest] = prevTestExp[testName][digest]
 		}
 	}
+	return expChange, undoChanges, changes, nil
+}
 
-	_, err = c.makeChange(changes, userID, c.getUniqueTimeStampMs(), changeID, true)
-	return changes, err
+// mergeBranch implements the branchMerger interface. The change record of
+// the merge stores the name of the merged branch.
+func (c *CloudExpStore) mergeBranch(branchStore ExpectationsStore, userID string) (types.TestExp, error) {
+	b, err := c.cloudBranchStore(branchStore)
+	if err != nil {
+		return nil, err
+	}
+
+	branchExp, _, err := b.loadCurrentExpectations(nil)
+	if err != nil {
+		return nil, sklog.FmtErrorf("Error loading branch expectations: %s", err)
+	}
+	changes := branchExp.TestExp()
+	if len(changes) == 0 {
+		return changes, nil
+	}
+
+	// Remove the merged expectations from the branch in the same transaction
+	// that adds them to master. Expectations that were changed on the branch
+	// in the meantime are kept.
+	removeFn := func(tx *datastore.Transaction, actions *dsutil.TxActions) error {
+		currentExp, _, err := b.loadCurrentExpectations(tx)
+		if err != nil {
+			return sklog.FmtErrorf("Error loading branch expectations: %s", err)
+		}
+		branchExp := currentExp.TestExp().DeepCopy()
+		removeMerged(branchExp, changes)
+		return b.updateCurrentExpectations(tx, branchExp, true, actions)
+	}
+	if _, err := c.makeChangeFn(changes, mergeUserID(userID, b.branch), b.branch, c.getUniqueTimeStampMs(), 0, true, removeFn); err != nil {
+		return nil, err
+	}
+
+	if b.eventBus != nil {
+		b.eventBus.Publish(b.eventExpChange, b.evExpChange(changes), b.globalEvent)
+	}
+	return changes, nil
+}
+
+// mergedBranch implements the branchMerger interface.
+func (c *CloudExpStore) mergedBranch(changeID int64) (string, error) {
+	expChange := &ExpChange{}
+	expChangeKey := ds.NewKey(c.changeKind)
+	expChangeKey.ID = changeID
+	if err := c.client.Get(context.TODO(), expChangeKey, expChange); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return "", nil
+		}
+		return "", sklog.FmtErrorf("Error retrieving change %d: %s", changeID, err)
+	}
+	return expChange.Branch, nil
+}
+
+// undoMerge implements the branchMerger interface.
+func (c *CloudExpStore) undoMerge(changeID int64, branchStore ExpectationsStore, userID string) (types.TestExp, error) {
+	b, err := c.cloudBranchStore(branchStore)
+	if err != nil {
+		return nil, err
+	}
+
+	expChange, merged, changes, err := c.prepareUndo(changeID)
+	if err != nil {
+		return nil, err
+	}
+	if expChange.Branch != b.branch {
+		return nil, sklog.FmtErrorf("Change %d did not merge branch %s.", changeID, b.branch)
+	}
+
+	// Restore the merged expectations on the branch in the same transaction
+	// that reverts them on master.
+	var restored types.TestExp
+	restoreFn := func(tx *datastore.Transaction, actions *dsutil.TxActions) error {
+		currentExp, _, err := b.loadCurrentExpectations(tx)
+		if err != nil {
+			return sklog.FmtErrorf("Error loading branch expectations: %s", err)
+		}
+		branchExp := currentExp.TestExp().DeepCopy()
+		restored = restoreMerged(branchExp, merged)
+		return b.updateCurrentExpectations(tx, branchExp, true, actions)
+	}
+	if _, err := c.makeChangeFn(changes, userID, "", c.getUniqueTimeStampMs(), changeID, true, restoreFn); err != nil {
+		return nil, err
+	}
+
+	if b.eventBus != nil {
+		b.eventBus.Publish(b.eventExpChange, b.evExpChange(restored), b.globalEvent)
+	}
+	return changes, nil
+}
+
+// cloudBranchStore returns the given store as the CloudExpStore of an
+// expectations branch.
+func (c *CloudExpStore) cloudBranchStore(branchStore ExpectationsStore) (*CloudExpStore, error) {
+	b, ok := branchStore.(*CloudExpStore)
+	if !ok || (b.branch == "") || (c.branch != "") || (c.issueID > 0) {
+		return nil, sklog.FmtErrorf("Branches can only be merged between cloud stores of master and an expectations branch.")
+	}
+	return b, nil
 }
 
 // Clear implements the ExpectationsStore interface.
@@ -430,7 +576,7 @@ func (c *CloudExpStore) removeChange(changes types.TestExp) (err error) {
 
 	if c.eventBus != nil {
 		// This is always a local event since it's only used for testing.
-		c.eventBus.Publish(c.eventExpChange, evExpChange(changes, c.issueID), false)
+		c.eventBus.Publish(c.eventExpChange, c.evExpChange(changes), false)
 	}
 	return nil
 }
@@ -441,7 +587,14 @@ func (c *CloudExpStore) removeChange(changes types.TestExp) (err error) {
 // since this is an undo of an earlier change.
 // If transactional is true it the change will be added in a transaction.
 // This should only be false when we import existing data.
-func (c *CloudExpStore) makeChange(changes types.TestExp, userId string, timeStampMs int64, undoChangeID int64, transactional bool) (changeKey *datastore.Key, err error) {
+func (c *CloudExpStore) makeChange(changes types.TestExp, userId string, timeStampMs int64, undoChangeID int64, transactional bool) (*datastore.Key, error) {
+	return c.makeChangeFn(changes, userId, c.branch, timeStampMs, undoChangeID, transactional, nil)
+}
+
+// makeChangeFn works like makeChange, but records the given branch in the
+// change record and, if it is not nil, calls txFn as part of the transaction
+// that adds the change. txFn is ignored if transactional is false.
+func (c *CloudExpStore) makeChangeFn(changes types.TestExp, userId string, branch string, timeStampMs int64, undoChangeID int64, transactional bool, txFn func(*datastore.Transaction, *dsutil.TxActions) error) (changeKey *datastore.Key, err error) {
 	ctx := context.TODO()
 
 	// Get the total count of changes so we can include it in the change record.
@@ -472,6 +625,7 @@ func (c *CloudExpStore) makeChange(changes types.TestExp, userId string, timeSta
 	changeKey = dsutil.TimeSortableKey(c.changeKind, timeStampMs)
 	expChange := &ExpChange{
 		IssueID:          c.issueID,
+		Branch:           branch,
 		UserID:           userId,
 		UndoChangeID:     undoChangeID,
 		TimeStamp:        timeStampMs,
@@ -500,6 +654,13 @@ func (c *CloudExpStore) makeChange(changes types.TestExp, userId string, timeSta
 			return err
 		}
 
+		// Make any other changes that are part of this change.
+		if txFn != nil {
+			if err := txFn(tx, &actions); err != nil {
+				return err
+			}
+		}
+
 		// Mark the expectation change as valid.
 		expChange.OK = true
 		_, err := tx.Put(changeKey, expChange)
@@ -519,7 +680,7 @@ func (c *CloudExpStore) makeChange(changes types.TestExp, userId string, timeSta
 	}
 
 	if c.eventBus != nil {
-		c.eventBus.Publish(c.eventExpChange, evExpChange(changes, c.issueID), c.globalEvent)
+		c.eventBus.Publish(c.eventExpChange, c.evExpChange(changes), c.globalEvent)
 	}
 	return changeKey, nil
 }
@@ -595,6 +756,14 @@ func (c *CloudExpStore) updateCurrentExpectations(tx *datastore.Transaction, cha
 	return nil
 }
 
+// evExpChange returns the payload of the event that is fired when the
+// expectations in this store change.
+func (c *CloudExpStore) evExpChange(changes types.TestExp) *EventExpectationChange {
+	ret := evExpChange(changes, c.issueID)
+	ret.Branch = c.branch
+	return ret
+}
+
 // getExpChangeKeys returns the keys of all expectation changes for the given issue
 // in reverse chronological order. If beforeID is larger than 0 it is assumed to be
 // an ID that was created via TimeSortableKey and we only want to retrieve keys that are
@@ -614,6 +783,10 @@ func (c *CloudExpStore) getExpChangeKeys(beforeID int64) ([]*datastore.Key, erro
 			q = q.Filter("IssueID =", c.issueID)
 		}
 
+		if c.branch != "" {
+			q = q.Filter("Branch =", c.branch)
+		}
+
 		var err error
 		queryKeys, err = c.client.GetAll(ctx, q, nil)
 		return err
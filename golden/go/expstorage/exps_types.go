@@ -14,9 +14,13 @@ const (
 // ExpChange is used to store an expectation change in the database. Each
 // expectation change is an atomic change to expectations for an issue.
 // The actual expectations are captured in instances of TestDigestExp.
+// Branch is the name of the expectations branch the change belongs to. For
+// changes of the master branch it is the name of the merged branch, if the
+// change merged an expectations branch.
 type ExpChange struct {
 	ChangeID         *datastore.Key `datastore:"__key__"`
 	IssueID          int64
+	Branch           string
 	UserID           string
 	TimeStamp        int64 `datastore:",noindex"`
 	Count            int64 `datastore:",noindex"`
@@ -27,9 +31,11 @@ type ExpChange struct {
 
 // EventExpectationChange is the structure that is sent in expectation change events.
 // When the change happened on the master branch 'IssueID' will contain a value <0
-// and should be ignored.
+// and should be ignored. 'Branch' is only set for changes of an expectations
+// branch.
 type EventExpectationChange struct {
 	IssueID     int64
+	Branch      string
 	TestChanges types.TestExp
 }
 
@@ -3,7 +3,9 @@ package expstorage
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"testing"
@@ -23,6 +25,7 @@ import (
 var testKinds = []ds.Kind{
 	ds.MASTER_EXP_CHANGE,
 	ds.TRYJOB_EXP_CHANGE,
+	ds.BRANCH_EXP_CHANGE,
 	ds.TRYJOB_TEST_DIGEST_EXP,
 	ds.HELPER_RECENT_KEYS,
 	ds.EXPECTATIONS_BLOB_ROOT,
@@ -109,11 +112,223 @@ func TestIssueCloudExpectationsStore(t *testing.T) {
 	_, issueStoreFactory, err := NewCloudExpectationsStore(ds.DS, masterEventBus)
 	assert.NoError(t, err)
 	issueID := int64(1234567)
-	issueStore := issueStoreFactory(issueID)
+	issueStore, err := issueStoreFactory(issueID)
+	assert.NoError(t, err)
 	testExpectationStore(t, issueStore, masterEventBus, issueID, EV_TRYJOB_EXP_CHANGED)
 	testCloudExpstoreClear(t, issueStore)
 }
 
+func TestLocalExpectationsStore(t *testing.T) {
+	testutils.SmallTest(t)
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	eventBus := eventbus.New()
+	localStore, issueStoreFactory, err := NewLocalExpectationsStore(dir, eventBus)
+	assert.NoError(t, err)
+	testExpectationStore(t, localStore, eventBus, 0, EV_EXPSTORAGE_CHANGED)
+
+	// Reloading the store from disk returns the same expectations and log.
+	exp, err := localStore.Get()
+	assert.NoError(t, err)
+	logEntries, total, err := localStore.QueryLog(0, 100, true)
+	assert.NoError(t, err)
+	reloadedStore, _, err := NewLocalExpectationsStore(dir, nil)
+	assert.NoError(t, err)
+	foundExp, err := reloadedStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, exp.TestExp(), foundExp.TestExp())
+	foundEntries, foundTotal, err := reloadedStore.QueryLog(0, 100, true)
+	assert.NoError(t, err)
+	assert.Equal(t, total, foundTotal)
+	assert.Equal(t, logEntries, foundEntries)
+
+	assert.NoError(t, localStore.Clear())
+	reloadedStore, _, err = NewLocalExpectationsStore(dir, nil)
+	assert.NoError(t, err)
+	foundExp, err = reloadedStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(foundExp.TestExp()))
+
+	issueID := int64(1234567)
+	issueStore, err := issueStoreFactory(issueID)
+	assert.NoError(t, err)
+	testExpectationStore(t, issueStore, eventBus, issueID, EV_TRYJOB_EXP_CHANGED)
+
+	// A corrupt file results in an error instead of an empty store.
+	corruptID := int64(7654321)
+	corruptPath := filepath.Join(dir, "issues", fmt.Sprintf("issue_%d.json", corruptID))
+	assert.NoError(t, ioutil.WriteFile(corruptPath, []byte("{not json"), 0644))
+	_, err = issueStoreFactory(corruptID)
+	assert.Error(t, err)
+}
+
+func TestBranchCloudExpectationsStore(t *testing.T) {
+	testutils.LargeTest(t)
+
+	cleanup := initDS(t)
+	defer cleanup()
+
+	eventBus := eventbus.New()
+	branchStoreFactory, err := NewCloudBranchExpStoreFactory(ds.DS, eventBus)
+	assert.NoError(t, err)
+	branchStore, err := branchStoreFactory("release-1")
+	assert.NoError(t, err)
+	testExpectationStore(t, branchStore, eventBus, 0, EV_BRANCH_EXP_CHANGED)
+	testCloudExpstoreClear(t, branchStore)
+
+	// Merging moves the branch expectations to master in a single change
+	// and undoing that change restores the branch.
+	masterStore, _, err := NewCloudExpectationsStore(ds.DS, eventBus)
+	assert.NoError(t, err)
+	branchExp := types.TestExp{"foo": {"d1": types.POSITIVE}}
+	assert.NoError(t, branchStore.AddChange(branchExp, "jim@example.com"))
+	changes, err := MergeBranch("release-1", masterStore, branchStore, "jim@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, branchExp, changes)
+	exp, err := masterStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, branchExp, exp.TestExp())
+	exp, err = branchStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(exp.TestExp()))
+	_, total, err := branchStore.QueryLog(0, 10, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	logEntries, total, err := masterStore.QueryLog(0, 10, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	mergeID, err := strconv.ParseInt(logEntries[0].ID, 10, 64)
+	assert.NoError(t, err)
+	_, err = UndoChange(masterStore, branchStoreFactory, mergeID, "jon@example.com")
+	assert.NoError(t, err)
+	exp, err = masterStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, types.UNTRIAGED, exp.Classification("foo", "d1"))
+	exp, err = branchStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, branchExp, exp.TestExp())
+}
+
+func TestBranchExpectations(t *testing.T) {
+	testutils.SmallTest(t)
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	eventBus := eventbus.New()
+	masterStore, _, err := NewLocalExpectationsStore(dir, eventBus)
+	assert.NoError(t, err)
+	branchStoreFactory, err := NewLocalBranchExpStoreFactory(dir, eventBus)
+	assert.NoError(t, err)
+
+	assert.True(t, ValidBranchName("release-m71"))
+	assert.False(t, ValidBranchName(MASTER_BRANCH))
+	assert.False(t, ValidBranchName("../release"))
+	assert.False(t, ValidBranchName(""))
+
+	// Changes to the branch fire a local event that identifies the branch.
+	branchEvents := make(chan *EventExpectationChange, 10)
+	eventBus.SubscribeAsync(EV_BRANCH_EXP_CHANGED, func(e interface{}) {
+		branchEvents <- e.(*EventExpectationChange)
+	})
+
+	assert.NoError(t, masterStore.AddChange(types.TestExp{
+		"foo": {"d1": types.POSITIVE, "d2": types.NEGATIVE},
+		"bar": {"d3": types.POSITIVE},
+	}, "jon@example.com"))
+	branchStore, err := branchStoreFactory("release-1")
+	assert.NoError(t, err)
+	sameStore, err := branchStoreFactory("release-1")
+	assert.NoError(t, err)
+	assert.Equal(t, branchStore, sameStore)
+	assert.NoError(t, branchStore.AddChange(types.TestExp{
+		"foo": {"d1": types.NEGATIVE, "d4": types.POSITIVE},
+	}, "jim@example.com"))
+	ev := <-branchEvents
+	assert.Equal(t, "release-1", ev.Branch)
+
+	// The branch inherits from master with the branch overrides.
+	exp, err := BranchExpectations(masterStore, branchStore)
+	assert.NoError(t, err)
+	assert.Equal(t, types.TestExp{
+		"foo": {"d1": types.NEGATIVE, "d2": types.NEGATIVE, "d4": types.POSITIVE},
+		"bar": {"d3": types.POSITIVE},
+	}, exp.TestExp())
+
+	// Another branch is not affected.
+	otherStore, err := branchStoreFactory("release-2")
+	assert.NoError(t, err)
+	exp, err = BranchExpectations(masterStore, otherStore)
+	assert.NoError(t, err)
+	masterExp, err := masterStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, masterExp.TestExp(), exp.TestExp())
+
+	// Merging promotes the branch decisions to master and clears the branch.
+	changes, err := MergeBranch("release-1", masterStore, branchStore, "jim@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, types.TestExp{"foo": {"d1": types.NEGATIVE, "d4": types.POSITIVE}}, changes)
+	masterExp, err = masterStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, types.NEGATIVE, masterExp.Classification("foo", "d1"))
+	assert.Equal(t, types.POSITIVE, masterExp.Classification("foo", "d4"))
+	branchExp, err := branchStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(branchExp.TestExp()))
+
+	logEntries, _, err := masterStore.QueryLog(0, 1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "jim@example.com:release-1", logEntries[0].Name)
+	mergeID, err := strconv.ParseInt(logEntries[0].ID, 10, 64)
+	assert.NoError(t, err)
+
+	// The history of the branch is kept.
+	_, total, err := branchStore.QueryLog(0, 100, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	// Merging an empty branch does not change master.
+	changes, err = MergeBranch("release-1", masterStore, branchStore, "jim@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(changes))
+	_, total, err = masterStore.QueryLog(0, 100, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+
+	// Undoing the merge restores master and the branch, except for digests
+	// that were triaged on the branch since.
+	assert.NoError(t, branchStore.AddChange(types.TestExp{
+		"foo": {"d4": types.NEGATIVE},
+	}, "jim@example.com"))
+	changes, err = UndoChange(masterStore, branchStoreFactory, mergeID, "jon@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, types.TestExp{"foo": {"d1": types.POSITIVE, "d4": types.UNTRIAGED}}, changes)
+	masterExp, err = masterStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, types.POSITIVE, masterExp.Classification("foo", "d1"))
+	assert.Equal(t, types.UNTRIAGED, masterExp.Classification("foo", "d4"))
+	branchExp, err = branchStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, types.TestExp{"foo": {"d1": types.NEGATIVE, "d4": types.NEGATIVE}}, branchExp.TestExp())
+	_, total, err = masterStore.QueryLog(0, 100, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+
+	// Other changes are undone as usual.
+	logEntries, _, err = masterStore.QueryLog(2, 1, false)
+	assert.NoError(t, err)
+	firstID, err := strconv.ParseInt(logEntries[0].ID, 10, 64)
+	assert.NoError(t, err)
+	_, err = UndoChange(masterStore, branchStoreFactory, firstID, "jon@example.com")
+	assert.NoError(t, err)
+	masterExp, err = masterStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, types.UNTRIAGED, masterExp.Classification("bar", "d3"))
+}
+
 // initDS initializes the datastore for testing.
 func initDS(t *testing.T, kinds ...ds.Kind) func() {
 	initKinds := []ds.Kind{}
@@ -0,0 +1,468 @@
+package expstorage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"go.skia.org/infra/go/eventbus"
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/types"
+)
+
+// localExpChange is a single change in a LocalExpStore. Branch is the name of
+// the expectations branch that was merged by the change, if any.
+type localExpChange struct {
+	ID           int64         `json:"id"`
+	UserID       string        `json:"userID"`
+	TimeStamp    int64         `json:"ts"`
+	UndoChangeID int64         `json:"undoChangeID"`
+	Branch       string        `json:"branch"`
+	Changes      types.TestExp `json:"changes"`
+}
+
+// localExpState is the content of the file that backs a LocalExpStore.
+type localExpState struct {
+	Expectations types.TestExp     `json:"expectations"`
+	Changes      []*localExpChange `json:"changes"`
+	NextID       int64             `json:"nextID"`
+}
+
+// LocalExpStore implements the ExpectationsStore interface on top of a JSON
+// file on the local file system. It is intended for running Gold offline on a
+// single machine, e.g. for a developer triaging their own test results.
+type LocalExpStore struct {
+	path           string
+	issueID        int64
+	branch         string
+	eventExpChange string
+	globalEvent    bool
+	eventBus       eventbus.EventBus
+	state          *localExpState
+	mutex          sync.Mutex
+}
+
+// NewLocalExpectationsStore returns an ExpectationsStore for the master branch
+// that is backed by files in the given directory, and a factory to create
+// ExpectationsStore instances for Gerrit issues in the same directory.
+func NewLocalExpectationsStore(dir string, eventBus eventbus.EventBus) (ExpectationsStore, IssueExpStoreFactory, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "issues"), 0755); err != nil {
+		return nil, nil, sklog.FmtErrorf("Error creating directory %s: %s", dir, err)
+	}
+
+	store, err := newLocalExpStore(filepath.Join(dir, "master.json"), masterIssueID, EV_EXPSTORAGE_CHANGED, true, eventBus)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The factory allows to create an isolated ExpectationStore instance for the
+	// given issue. Instances are cached since they keep the state of the issue
+	// in memory.
+	issueStores := map[int64]*LocalExpStore{}
+	var issueStoresMutex sync.Mutex
+	factory := func(issueID int64) (ExpectationsStore, error) {
+		issueStoresMutex.Lock()
+		defer issueStoresMutex.Unlock()
+		if ret, ok := issueStores[issueID]; ok {
+			return ret, nil
+		}
+
+		path := filepath.Join(dir, "issues", fmt.Sprintf("issue_%d.json", issueID))
+		ret, err := newLocalExpStore(path, issueID, EV_TRYJOB_EXP_CHANGED, false, eventBus)
+		if err != nil {
+			return nil, sklog.FmtErrorf("Unable to load expectations for issue %d: %s", issueID, err)
+		}
+		issueStores[issueID] = ret
+		return ret, nil
+	}
+	return store, factory, nil
+}
+
+// NewLocalBranchExpStoreFactory returns a factory to create ExpectationsStore
+// instances for expectation branches that are backed by files in the given
+// directory.
+func NewLocalBranchExpStoreFactory(dir string, eventBus eventbus.EventBus) (BranchExpStoreFactory, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "branches"), 0755); err != nil {
+		return nil, sklog.FmtErrorf("Error creating directory %s: %s", dir, err)
+	}
+
+	// Instances are cached since they keep the state of the branch in memory.
+	branchStores := map[string]*LocalExpStore{}
+	var branchStoresMutex sync.Mutex
+	return func(branch string) (ExpectationsStore, error) {
+		branchStoresMutex.Lock()
+		defer branchStoresMutex.Unlock()
+		if ret, ok := branchStores[branch]; ok {
+			return ret, nil
+		}
+
+		path := filepath.Join(dir, "branches", branch+".json")
+		ret, err := newLocalExpStore(path, masterIssueID, EV_BRANCH_EXP_CHANGED, false, eventBus)
+		if err != nil {
+			return nil, sklog.FmtErrorf("Unable to load expectations for branch %s: %s", branch, err)
+		}
+		ret.branch = branch
+		branchStores[branch] = ret
+		return ret, nil
+	}, nil
+}
+
+// newLocalExpStore loads the store from the given path. If the file does not
+// exist the store is empty.
+func newLocalExpStore(path string, issueID int64, eventExpChange string, globalEvent bool, eventBus eventbus.EventBus) (*LocalExpStore, error) {
+	ret := &LocalExpStore{
+		path:           path,
+		issueID:        issueID,
+		eventExpChange: eventExpChange,
+		globalEvent:    globalEvent,
+		eventBus:       eventBus,
+		state:          newLocalExpState(),
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ret, nil
+	}
+	if err != nil {
+		return nil, sklog.FmtErrorf("Error opening %s: %s", path, err)
+	}
+	defer util.Close(f)
+	if err := json.NewDecoder(f).Decode(ret.state); err != nil {
+		return nil, sklog.FmtErrorf("Error decoding expectations in %s: %s", path, err)
+	}
+	if ret.state.Expectations == nil {
+		ret.state.Expectations = types.TestExp{}
+	}
+	return ret, nil
+}
+
+func newLocalExpState() *localExpState {
+	return &localExpState{
+		Expectations: types.TestExp{},
+		Changes:      []*localExpChange{},
+		NextID:       1,
+	}
+}
+
+// copy returns a copy of the state that is not affected by later changes.
+func (s *localExpState) copy() *localExpState {
+	return &localExpState{
+		Expectations: s.Expectations.DeepCopy(),
+		Changes:      append([]*localExpChange(nil), s.Changes...),
+		NextID:       s.NextID,
+	}
+}
+
+// Get implements the ExpectationsStore interface.
+func (l *LocalExpStore) Get() (types.Expectations, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return types.NewExpectations(l.state.Expectations.DeepCopy()), nil
+}
+
+// AddChange implements the ExpectationsStore interface.
+func (l *LocalExpStore) AddChange(changes types.TestExp, userID string) error {
+	return l.makeChange(changes, userID, 0)
+}
+
+// QueryLog implements the ExpectationsStore interface.
+func (l *LocalExpStore) QueryLog(offset, size int, details bool) ([]*TriageLogEntry, int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	// The log is returned with the most recent change first.
+	total := len(l.state.Changes)
+	if size <= 0 {
+		size = total
+	}
+	start := util.MinInt(offset, total)
+	end := util.MinInt(start+size, total)
+	ret := make([]*TriageLogEntry, 0, end-start)
+	for i := start; i < end; i++ {
+		change := l.state.Changes[total-1-i]
+		entry := &TriageLogEntry{
+			ID:           strconv.FormatInt(change.ID, 10),
+			Name:         change.UserID,
+			TS:           change.TimeStamp,
+			ChangeCount:  countDigests(change.Changes),
+			UndoChangeID: change.UndoChangeID,
+		}
+		if details {
+			entry.Details = triageDetails(change.Changes)
+		}
+		ret = append(ret, entry)
+	}
+	return ret, total, nil
+}
+
+// UndoChange implements the ExpectationsStore interface.
+func (l *LocalExpStore) UndoChange(changeID int64, userID string) (types.TestExp, error) {
+	l.mutex.Lock()
+	undone, prevExp := l.findChange(changeID)
+	l.mutex.Unlock()
+
+	if undone == nil {
+		return nil, sklog.FmtErrorf("Change with id %d does not exist.", changeID)
+	}
+	if undone.UndoChangeID != 0 {
+		return nil, fmt.Errorf("Unable to undo change %d which was created as an undo of change %d.", changeID, undone.UndoChangeID)
+	}
+
+	changes := undoLocalChange(undone, prevExp)
+	return changes, l.makeChange(changes, userID, changeID)
+}
+
+// Clear implements the ExpectationsStore interface.
+func (l *LocalExpStore) Clear() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.state = newLocalExpState()
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// removeChange implements the ExpectationsStore interface.
+func (l *LocalExpStore) removeChange(changes types.TestExp) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for testName, digests := range changes {
+		for digest := range digests {
+			delete(l.state.Expectations[testName], digest)
+			if len(l.state.Expectations[testName]) == 0 {
+				delete(l.state.Expectations, testName)
+			}
+		}
+	}
+	if err := l.save(); err != nil {
+		return err
+	}
+
+	if l.eventBus != nil {
+		// This is always a local event since it's only used for testing.
+		l.eventBus.Publish(l.eventExpChange, l.evExpChange(changes), false)
+	}
+	return nil
+}
+
+// makeChange applies the changes, records them in the log and writes the
+// store to disk. If undoChangeID is larger than 0 the change is recorded as
+// an undo of that change.
+func (l *LocalExpStore) makeChange(changes types.TestExp, userID string, undoChangeID int64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.appendChange(changes, userID, undoChangeID, "")
+	if err := l.save(); err != nil {
+		return err
+	}
+
+	if l.eventBus != nil {
+		l.eventBus.Publish(l.eventExpChange, l.evExpChange(changes), l.globalEvent)
+	}
+	return nil
+}
+
+// appendChange applies the changes and records them in the log. Assumes the
+// caller holds the mutex.
+func (l *LocalExpStore) appendChange(changes types.TestExp, userID string, undoChangeID int64, branch string) {
+	exp := types.NewExpectations(l.state.Expectations)
+	exp.AddTestExp(changes)
+	l.state.Changes = append(l.state.Changes, &localExpChange{
+		ID:           l.state.NextID,
+		UserID:       userID,
+		TimeStamp:    util.TimeStampMs(),
+		UndoChangeID: undoChangeID,
+		Branch:       branch,
+		Changes:      changes.DeepCopy(),
+	})
+	l.state.NextID++
+}
+
+// findChange returns the change with the given ID and the expectations before
+// the change. The change is nil if it does not exist. Assumes the caller holds
+// the mutex.
+func (l *LocalExpStore) findChange(changeID int64) (*localExpChange, types.Expectations) {
+	prevExp := types.NewExpectations(nil)
+	for _, change := range l.state.Changes {
+		if change.ID == changeID {
+			return change, prevExp
+		}
+		prevExp.AddTestExp(change.Changes)
+	}
+	return nil, prevExp
+}
+
+// undoLocalChange returns the changes that restore the labels the digests of
+// the given change had before the change.
+func undoLocalChange(undone *localExpChange, prevExp types.Expectations) types.TestExp {
+	changes := types.TestExp{}
+	for testName, digests := range undone.Changes {
+		changes[testName] = make(types.TestClassification, len(digests))
+		for digest := range digests {
+			changes[testName][digest] = prevExp.Classification(testName, digest)
+		}
+	}
+	return changes
+}
+
+// mergeBranch implements the branchMerger interface.
+func (l *LocalExpStore) mergeBranch(branchStore ExpectationsStore, userID string) (types.TestExp, error) {
+	b, err := l.localBranchStore(branchStore)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	changes := b.state.Expectations.DeepCopy()
+	if len(changes) == 0 {
+		return changes, nil
+	}
+
+	prevState, prevBranchState := l.state.copy(), b.state.copy()
+	l.appendChange(changes, mergeUserID(userID, b.branch), 0, b.branch)
+	removeMerged(b.state.Expectations, changes)
+	if err := l.saveWithBranch(b, prevState, prevBranchState); err != nil {
+		return nil, err
+	}
+
+	if l.eventBus != nil {
+		l.eventBus.Publish(l.eventExpChange, l.evExpChange(changes), l.globalEvent)
+		l.eventBus.Publish(b.eventExpChange, b.evExpChange(changes), b.globalEvent)
+	}
+	return changes, nil
+}
+
+// mergedBranch implements the branchMerger interface.
+func (l *LocalExpStore) mergedBranch(changeID int64) (string, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if change, _ := l.findChange(changeID); change != nil {
+		return change.Branch, nil
+	}
+	return "", nil
+}
+
+// undoMerge implements the branchMerger interface.
+func (l *LocalExpStore) undoMerge(changeID int64, branchStore ExpectationsStore, userID string) (types.TestExp, error) {
+	b, err := l.localBranchStore(branchStore)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	undone, prevExp := l.findChange(changeID)
+	if (undone == nil) || (undone.Branch != b.branch) {
+		return nil, sklog.FmtErrorf("Change %d did not merge branch %s.", changeID, b.branch)
+	}
+
+	changes := undoLocalChange(undone, prevExp)
+	prevState, prevBranchState := l.state.copy(), b.state.copy()
+	l.appendChange(changes, userID, changeID, "")
+	restored := restoreMerged(b.state.Expectations, undone.Changes)
+	if err := l.saveWithBranch(b, prevState, prevBranchState); err != nil {
+		return nil, err
+	}
+
+	if l.eventBus != nil {
+		l.eventBus.Publish(l.eventExpChange, l.evExpChange(changes), l.globalEvent)
+		l.eventBus.Publish(b.eventExpChange, b.evExpChange(restored), b.globalEvent)
+	}
+	return changes, nil
+}
+
+// localBranchStore returns the given store as the LocalExpStore of an
+// expectations branch.
+func (l *LocalExpStore) localBranchStore(branchStore ExpectationsStore) (*LocalExpStore, error) {
+	b, ok := branchStore.(*LocalExpStore)
+	if !ok || (b.branch == "") || (l.branch != "") || (l.issueID > 0) {
+		return nil, sklog.FmtErrorf("Branches can only be merged between local stores of master and an expectations branch.")
+	}
+	return b, nil
+}
+
+// saveWithBranch writes this store and the given branch store to disk. If
+// either fails both stores are reset to the given previous states. Assumes
+// the caller holds the mutexes of both stores.
+func (l *LocalExpStore) saveWithBranch(b *LocalExpStore, prevState, prevBranchState *localExpState) error {
+	err := l.save()
+	if err == nil {
+		if err = b.save(); err != nil {
+			l.state = prevState
+			util.LogErr(l.save())
+		}
+	}
+	if err != nil {
+		l.state = prevState
+		b.state = prevBranchState
+		return sklog.FmtErrorf("Error writing expectations: %s", err)
+	}
+	return nil
+}
+
+// evExpChange returns the payload of the event that is fired when the
+// expectations in this store change.
+func (l *LocalExpStore) evExpChange(changes types.TestExp) *EventExpectationChange {
+	ret := evExpChange(changes, l.issueID)
+	ret.Branch = l.branch
+	return ret
+}
+
+// save writes the state of the store to disk. Assumes the caller holds the
+// mutex.
+func (l *LocalExpStore) save() error {
+	return util.WithWriteFile(l.path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(l.state)
+	})
+}
+
+// countDigests returns the number of test/digest pairs in the given changes.
+func countDigests(changes types.TestExp) int {
+	ret := 0
+	for _, digests := range changes {
+		ret += len(digests)
+	}
+	return ret
+}
+
+// triageDetails returns the changes as a list of TriageDetail sorted by test
+// name and digest.
+func triageDetails(changes types.TestExp) []*TriageDetail {
+	ret := make([]*TriageDetail, 0, countDigests(changes))
+	for testName, digests := range changes {
+		for digest, label := range digests {
+			ret = append(ret, &TriageDetail{
+				TestName: testName,
+				Digest:   digest,
+				Label:    label.String(),
+			})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].TestName == ret[j].TestName {
+			return ret[i].Digest < ret[j].Digest
+		}
+		return ret[i].TestName < ret[j].TestName
+	})
+	return ret
+}
@@ -527,6 +527,46 @@ func (c *CachingExpectationStore) UndoChange(changeID int64, userID string) (typ
 	return changedTests, nil
 }
 
+// mergeBranch implements the branchMerger interface.
+func (c *CachingExpectationStore) mergeBranch(branchStore ExpectationsStore, userID string) (types.TestExp, error) {
+	merger, ok := c.store.(branchMerger)
+	if !ok {
+		return nil, sklog.FmtErrorf("Expectations store does not support merging branches.")
+	}
+	changedTests, err := merger.mergeBranch(branchStore, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fire an event that will trigger the addition to the cache.
+	c.eventBus.Publish(EV_EXPSTORAGE_CHANGED, evExpChange(changedTests, masterIssueID), true)
+	return changedTests, nil
+}
+
+// mergedBranch implements the branchMerger interface.
+func (c *CachingExpectationStore) mergedBranch(changeID int64) (string, error) {
+	if merger, ok := c.store.(branchMerger); ok {
+		return merger.mergedBranch(changeID)
+	}
+	return "", nil
+}
+
+// undoMerge implements the branchMerger interface.
+func (c *CachingExpectationStore) undoMerge(changeID int64, branchStore ExpectationsStore, userID string) (types.TestExp, error) {
+	merger, ok := c.store.(branchMerger)
+	if !ok {
+		return nil, sklog.FmtErrorf("Expectations store does not support merging branches.")
+	}
+	changedTests, err := merger.undoMerge(changeID, branchStore, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fire an event that will trigger the addition to the cache.
+	c.eventBus.Publish(EV_EXPSTORAGE_CHANGED, evExpChange(changedTests, masterIssueID), true)
+	return changedTests, nil
+}
+
 // Clear implements the ExpectationsStore interface.
 func (c *CachingExpectationStore) Clear() error {
 	if err := c.store.Clear(); err != nil {
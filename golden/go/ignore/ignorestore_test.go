@@ -2,6 +2,7 @@ package ignore
 
 import (
 	"net/url"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -49,6 +50,29 @@ func TestCloudIgnoreStore(t *testing.T) {
 	testIgnoreStore(t, store)
 }
 
+func TestLocalIgnoreStore(t *testing.T) {
+	testutils.SmallTest(t)
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "ignores.json")
+	store, err := NewLocalIgnoreStore(path, nil, nil)
+	assert.NoError(t, err)
+	testIgnoreStore(t, store)
+
+	// Make sure the rules are persisted.
+	r1 := NewIgnoreRule("jon@example.com", time.Now().Add(time.Hour), "config=gpu", "reason")
+	assert.NoError(t, store.Create(r1))
+	store, err = NewLocalIgnoreStore(path, nil, nil)
+	assert.NoError(t, err)
+	allRules, err := store.List(false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(allRules))
+	assert.Equal(t, r1.ID, allRules[0].ID)
+	assert.Equal(t, r1.Query, allRules[0].Query)
+}
+
 func testIgnoreStore(t *testing.T, store IgnoreStore) {
 	// Add a few instances.
 	r1 := NewIgnoreRule("jon@example.com", time.Now().Add(time.Hour), "config=gpu", "reason")
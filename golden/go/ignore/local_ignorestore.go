@@ -0,0 +1,142 @@
+package ignore
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/expstorage"
+	"go.skia.org/infra/golden/go/types"
+)
+
+// localIgnoreState is the content of the file that backs a localIgnoreStore.
+type localIgnoreState struct {
+	Rules  []*IgnoreRule `json:"rules"`
+	NextID int64         `json:"nextID"`
+}
+
+// localIgnoreStore implements the IgnoreStore interface on top of a JSON file
+// on the local file system.
+type localIgnoreStore struct {
+	path         string
+	state        *localIgnoreState
+	revision     int64
+	lastTilePair *types.TilePair
+	expStore     expstorage.ExpectationsStore
+	tileStream   <-chan *types.TilePair
+	mutex        sync.Mutex
+}
+
+// NewLocalIgnoreStore returns an IgnoreStore instance that stores the ignore
+// rules in the given file. The file is created when the first rule is added.
+func NewLocalIgnoreStore(path string, expStore expstorage.ExpectationsStore, tileStream <-chan *types.TilePair) (IgnoreStore, error) {
+	ret := &localIgnoreStore{
+		path:       path,
+		state:      &localIgnoreState{Rules: []*IgnoreRule{}, NextID: 1},
+		expStore:   expStore,
+		tileStream: tileStream,
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ret, nil
+	}
+	if err != nil {
+		return nil, sklog.FmtErrorf("Error opening %s: %s", path, err)
+	}
+	defer util.Close(f)
+	if err := json.NewDecoder(f).Decode(ret.state); err != nil {
+		return nil, sklog.FmtErrorf("Error decoding ignore rules in %s: %s", path, err)
+	}
+	return ret, nil
+}
+
+// Create implements the IgnoreStore interface.
+func (l *localIgnoreStore) Create(rule *IgnoreRule) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	rule.ID = l.state.NextID
+	l.state.NextID++
+	l.state.Rules = append(l.state.Rules, rule)
+	return l.save()
+}
+
+// List implements the IgnoreStore interface.
+func (l *localIgnoreStore) List(addCounts bool) ([]*IgnoreRule, error) {
+	l.mutex.Lock()
+	ret := make([]*IgnoreRule, 0, len(l.state.Rules))
+	for _, rule := range l.state.Rules {
+		ruleCopy := *rule
+		ret = append(ret, &ruleCopy)
+	}
+	l.mutex.Unlock()
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Expires.Before(ret[j].Expires) })
+
+	if addCounts {
+		var err error
+		l.lastTilePair, err = addIgnoreCounts(ret, l, l.lastTilePair, l.expStore, l.tileStream)
+		if err != nil {
+			sklog.Errorf("Unable to add counts to ignore list result: %s", err)
+		}
+	}
+	return ret, nil
+}
+
+// Update implements the IgnoreStore interface.
+func (l *localIgnoreStore) Update(id int64, rule *IgnoreRule) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for idx, oldRule := range l.state.Rules {
+		if oldRule.ID == id {
+			rule.ID = id
+			l.state.Rules[idx] = rule
+			return l.save()
+		}
+	}
+	return sklog.FmtErrorf("Did not find an IgnoreRule with id: %d", id)
+}
+
+// Delete implements the IgnoreStore interface.
+func (l *localIgnoreStore) Delete(id int64) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for idx, rule := range l.state.Rules {
+		if rule.ID == id {
+			l.state.Rules = append(l.state.Rules[:idx], l.state.Rules[idx+1:]...)
+			return 1, l.save()
+		}
+	}
+	return 0, nil
+}
+
+// Revision implements the IgnoreStore interface.
+func (l *localIgnoreStore) Revision() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.revision
+}
+
+// BuildRuleMatcher implements the IgnoreStore interface.
+func (l *localIgnoreStore) BuildRuleMatcher() (RuleMatcher, error) {
+	return buildRuleMatcher(l)
+}
+
+// save writes the rules to disk and increments the revision. Assumes the
+// caller holds the mutex.
+func (l *localIgnoreStore) save() error {
+	err := util.WithWriteFile(l.path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(l.state)
+	})
+	if err != nil {
+		return sklog.FmtErrorf("Error writing ignore rules to %s: %s", l.path, err)
+	}
+	l.revision++
+	return nil
+}
@@ -2,10 +2,15 @@ package ignore
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"go.skia.org/infra/go/metrics2"
 	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/go/util"
 )
 
 func oneStep(store IgnoreStore, metric metrics2.Int64Metric) error {
@@ -47,3 +52,328 @@ func Init(store IgnoreStore) error {
 
 	return nil
 }
+
+const (
+	// MAX_REPORT_TRACES is the maximum number of trace ids listed for each
+	// rule in a RuleReport.
+	MAX_REPORT_TRACES = 100
+)
+
+// RuleMonitorOptions configures a RuleMonitor.
+type RuleMonitorOptions struct {
+	// ExpiryWarning is how long before a rule expires its owner is notified.
+	// A value <= 0 disables these notifications.
+	ExpiryWarning time.Duration
+
+	// StaleAfter is how long a rule has to match no untriaged digests before it
+	// is flagged as stale. A value <= 0 disables flagging stale rules.
+	StaleAfter time.Duration
+
+	// SiteURL is the URL of the Gold instance. It is used to link to the
+	// ignore rules in notifications.
+	SiteURL string
+
+	// StatePath is the file where the monitor persists when rules last matched
+	// untriaged digests and which notifications were sent. If empty the state
+	// is kept in memory only.
+	StatePath string
+}
+
+// monitorState is the persisted state of a RuleMonitor.
+type monitorState struct {
+	// LastMatched maps rule ids to the last time the rule matched untriaged
+	// digests. Rules that never matched anything map to the time they were
+	// first seen by the monitor.
+	LastMatched map[int64]time.Time
+
+	// ExpiryNotified maps rule ids to the expiration time the owner was
+	// notified about. If the rule is extended the owner is notified again.
+	ExpiryNotified map[int64]time.Time
+
+	// StaleNotified maps rule ids to the value of LastMatched at the time the
+	// owner was notified that the rule is stale.
+	StaleNotified map[int64]time.Time
+}
+
+// RuleMonitor periodically checks the ignore rules. It notifies the owners of
+// rules that are about to expire and flags rules that have not matched any
+// untriaged digests for a while.
+type RuleMonitor struct {
+	store    IgnoreStore
+	notifier Notifier
+	opts     RuleMonitorOptions
+	state    *monitorState
+	numStale metrics2.Int64Metric
+	mutex    sync.Mutex
+}
+
+// NewRuleMonitor creates a new RuleMonitor for the given store. If notifier is
+// nil no notifications are sent, but stale rules are still flagged.
+func NewRuleMonitor(store IgnoreStore, notifier Notifier, opts *RuleMonitorOptions) (*RuleMonitor, error) {
+	state := &monitorState{}
+	if opts.StatePath != "" {
+		if err := util.MaybeReadGobFile(opts.StatePath, state); err != nil {
+			return nil, fmt.Errorf("Unable to read ignore monitor state from %s: %s", opts.StatePath, err)
+		}
+	}
+	if state.LastMatched == nil {
+		state.LastMatched = map[int64]time.Time{}
+	}
+	if state.ExpiryNotified == nil {
+		state.ExpiryNotified = map[int64]time.Time{}
+	}
+	if state.StaleNotified == nil {
+		state.StaleNotified = map[int64]time.Time{}
+	}
+
+	return &RuleMonitor{
+		store:    store,
+		notifier: notifier,
+		opts:     *opts,
+		state:    state,
+		numStale: metrics2.GetInt64Metric("gold_num_stale_ignore_rules", nil),
+	}, nil
+}
+
+// Start checks the ignore rules at the given interval in the background.
+func (r *RuleMonitor) Start(interval time.Duration) {
+	liveness := metrics2.NewLiveness("gold_ignore_rule_monitoring")
+	go func() {
+		for range time.Tick(interval) {
+			if err := r.oneStep(time.Now()); err != nil {
+				sklog.Errorf("Failed one step of checking ignore rules: %s", err)
+				continue
+			}
+			liveness.Reset()
+		}
+	}()
+}
+
+// oneStep updates the state of the monitor based on the current ignore rules
+// and sends the notifications that are due.
+func (r *RuleMonitor) oneStep(now time.Time) error {
+	rules, err := r.store.List(true)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range r.update(rules, now) {
+		if r.notifier == nil {
+			continue
+		}
+		if err := r.notifier.Notify(n); err != nil {
+			sklog.Errorf("Unable to notify %s about ignore rule %d: %s", n.To, n.Rule.ID, err)
+			continue
+		}
+		r.markNotified(n)
+	}
+	return r.save()
+}
+
+// update records which rules matched untriaged digests, removes the state of
+// deleted rules and returns the notifications that are due.
+func (r *RuleMonitor) update(rules []*IgnoreRule, now time.Time) []*Notification {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ids := make(map[int64]bool, len(rules))
+	for _, rule := range rules {
+		ids[rule.ID] = true
+		if _, ok := r.state.LastMatched[rule.ID]; !ok || (rule.Count > 0) {
+			r.state.LastMatched[rule.ID] = now
+		}
+	}
+
+	for _, m := range []map[int64]time.Time{r.state.LastMatched, r.state.ExpiryNotified, r.state.StaleNotified} {
+		for id := range m {
+			if !ids[id] {
+				delete(m, id)
+			}
+		}
+	}
+
+	ret := []*Notification{}
+	numStale := 0
+	for _, rule := range rules {
+		if r.expiresSoon(rule, now) && !r.state.ExpiryNotified[rule.ID].Equal(rule.Expires) {
+			ret = append(ret, r.newNotification(NOTIFY_EXPIRING, rule,
+				fmt.Sprintf("Gold ignore rule expires on %s", rule.Expires.Format("2006-01-02")),
+				fmt.Sprintf("Your ignore rule expires in %s. Please extend it if the traces it matches should still be ignored.", rule.Expires.Sub(now).Round(time.Hour))))
+		}
+
+		if r.isStale(rule.ID, now) {
+			numStale++
+			if !r.state.StaleNotified[rule.ID].Equal(r.state.LastMatched[rule.ID]) {
+				ret = append(ret, r.newNotification(NOTIFY_STALE, rule,
+					"Gold ignore rule is stale",
+					fmt.Sprintf("Your ignore rule has not matched any untriaged digests since %s. Please consider deleting it.", r.state.LastMatched[rule.ID].Format("2006-01-02"))))
+			}
+		}
+	}
+	r.numStale.Update(int64(numStale))
+	return ret
+}
+
+// expiresSoon returns true if the given rule expires within the expiry warning
+// period.
+func (r *RuleMonitor) expiresSoon(rule *IgnoreRule, now time.Time) bool {
+	return (r.opts.ExpiryWarning > 0) && rule.Expires.After(now) && (rule.Expires.Sub(now) <= r.opts.ExpiryWarning)
+}
+
+// isStale returns true if the rule with the given id has not matched any
+// untriaged digests for the configured time. Assumes the caller holds the mutex.
+func (r *RuleMonitor) isStale(id int64, now time.Time) bool {
+	lastMatched, ok := r.state.LastMatched[id]
+	return (r.opts.StaleAfter > 0) && ok && (now.Sub(lastMatched) >= r.opts.StaleAfter)
+}
+
+// newNotification returns a notification for the owner of the given rule.
+func (r *RuleMonitor) newNotification(notificationType NotificationType, rule *IgnoreRule, subject, message string) *Notification {
+	return &Notification{
+		Type:    notificationType,
+		To:      rule.UpdatedBy,
+		Subject: subject,
+		Message: message,
+		URL:     strings.TrimRight(r.opts.SiteURL, "/") + "/ignores",
+		Rule:    rule,
+	}
+}
+
+// markNotified records that the given notification was sent successfully.
+func (r *RuleMonitor) markNotified(n *Notification) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	switch n.Type {
+	case NOTIFY_EXPIRING:
+		r.state.ExpiryNotified[n.Rule.ID] = n.Rule.Expires
+	case NOTIFY_STALE:
+		r.state.StaleNotified[n.Rule.ID] = r.state.LastMatched[n.Rule.ID]
+	}
+}
+
+// save writes the state of the monitor to disk if a path was configured.
+func (r *RuleMonitor) save() error {
+	if r.opts.StatePath == "" {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := util.WriteGobFile(r.opts.StatePath, r.state); err != nil {
+		return fmt.Errorf("Unable to write ignore monitor state to %s: %s", r.opts.StatePath, err)
+	}
+	return nil
+}
+
+// RuleReport describes how an ignore rule relates to the traces of a tile and
+// to the other ignore rules.
+type RuleReport struct {
+	Rule *IgnoreRule `json:"rule"`
+
+	// Stale is true if the rule has not matched any untriaged digests for the
+	// configured time.
+	Stale bool `json:"stale"`
+
+	// LastMatched is the last time the rule matched untriaged digests. It is
+	// the zero time if the monitor has not seen the rule yet.
+	LastMatched time.Time `json:"lastMatched"`
+
+	// NumTraces is the number of traces in the tile the rule shadows.
+	NumTraces int `json:"numTraces"`
+
+	// NumExclusiveTraces is the number of traces only this rule shadows.
+	NumExclusiveTraces int `json:"numExclusiveTraces"`
+
+	// Traces contains the sorted ids of the first MAX_REPORT_TRACES traces the
+	// rule shadows.
+	Traces []string `json:"traces"`
+
+	// Overlaps contains the ids of the other rules that shadow some of the
+	// same traces.
+	Overlaps []int64 `json:"overlaps"`
+}
+
+// Report returns a RuleReport for every rule that is stale, shadows no traces
+// in the given tile or overlaps with other rules. The tile should include the
+// ignored traces. The result is sorted by rule id.
+func (r *RuleMonitor) Report(tile *tiling.Tile) ([]*RuleReport, error) {
+	rules, err := r.store.List(true)
+	if err != nil {
+		return nil, err
+	}
+
+	reports, err := buildRuleReports(rules, tile)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	ret := make([]*RuleReport, 0, len(reports))
+	for _, report := range reports {
+		report.LastMatched = r.state.LastMatched[report.Rule.ID]
+		report.Stale = r.isStale(report.Rule.ID, now)
+		if report.Stale || (report.NumTraces == 0) || (len(report.Overlaps) > 0) {
+			ret = append(ret, report)
+		}
+	}
+	return ret, nil
+}
+
+// buildRuleReports matches the given rules against the traces in the tile and
+// returns a RuleReport for each rule, sorted by rule id. The Stale and
+// LastMatched fields are not set.
+func buildRuleReports(rules []*IgnoreRule, tile *tiling.Tile) ([]*RuleReport, error) {
+	queries, err := ToQuery(rules)
+	if err != nil {
+		return nil, err
+	}
+	queryRules := make([]QueryRule, 0, len(queries))
+	for _, q := range queries {
+		queryRules = append(queryRules, NewQueryRule(q))
+	}
+
+	reports := make([]*RuleReport, len(rules))
+	traceIDs := make([][]string, len(rules))
+	overlaps := make([]map[int64]bool, len(rules))
+	for idx, rule := range rules {
+		reports[idx] = &RuleReport{Rule: rule, Traces: []string{}, Overlaps: []int64{}}
+		overlaps[idx] = map[int64]bool{}
+	}
+
+	matched := make([]int, 0, len(rules))
+	for traceID, trace := range tile.Traces {
+		matched = matched[:0]
+		for idx, q := range queryRules {
+			if q.IsMatch(trace.Params()) {
+				matched = append(matched, idx)
+			}
+		}
+
+		for _, idx := range matched {
+			reports[idx].NumTraces++
+			traceIDs[idx] = append(traceIDs[idx], traceID)
+			if len(matched) == 1 {
+				reports[idx].NumExclusiveTraces++
+			}
+			for _, otherIdx := range matched {
+				if otherIdx != idx {
+					overlaps[idx][rules[otherIdx].ID] = true
+				}
+			}
+		}
+	}
+
+	for idx, report := range reports {
+		sort.Strings(traceIDs[idx])
+		report.Traces = append(report.Traces, traceIDs[idx][:util.MinInt(MAX_REPORT_TRACES, len(traceIDs[idx]))]...)
+		for id := range overlaps[idx] {
+			report.Overlaps = append(report.Overlaps, id)
+		}
+		sort.Slice(report.Overlaps, func(i, j int) bool { return report.Overlaps[i] < report.Overlaps[j] })
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Rule.ID < reports[j].Rule.ID })
+	return reports, nil
+}
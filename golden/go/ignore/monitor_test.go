@@ -0,0 +1,163 @@
+package ignore
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/golden/go/types"
+)
+
+// mockNotifier records the notifications it was asked to send.
+type mockNotifier struct {
+	sent []*Notification
+}
+
+func (m *mockNotifier) Notify(n *Notification) error {
+	m.sent = append(m.sent, n)
+	return nil
+}
+
+func TestRuleMonitor(t *testing.T) {
+	testutils.SmallTest(t)
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	now := time.Now()
+	store := NewMemIgnoreStore()
+	r1 := NewIgnoreRule("jon@example.com", now.Add(time.Hour), "config=gpu", "reason")
+	r2 := NewIgnoreRule("jim@example.com", now.Add(30*24*time.Hour), "config=8888", "No good reason.")
+	assert.NoError(t, store.Create(r1))
+	assert.NoError(t, store.Create(r2))
+
+	// The first rule keeps matching untriaged digests.
+	r1.Count = 1
+
+	notifier := &mockNotifier{}
+	opts := &RuleMonitorOptions{
+		ExpiryWarning: 24 * time.Hour,
+		StaleAfter:    7 * 24 * time.Hour,
+		SiteURL:       "https://gold.skia.org/",
+		StatePath:     filepath.Join(dir, "state.gob"),
+	}
+	monitor, err := NewRuleMonitor(store, notifier, opts)
+	assert.NoError(t, err)
+
+	// The first rule is about to expire.
+	assert.NoError(t, monitor.oneStep(now))
+	assert.Equal(t, 1, len(notifier.sent))
+	assert.Equal(t, NOTIFY_EXPIRING, notifier.sent[0].Type)
+	assert.Equal(t, "jon@example.com", notifier.sent[0].To)
+	assert.Equal(t, "https://gold.skia.org/ignores", notifier.sent[0].URL)
+
+	// The owner is only notified once about the same expiration.
+	assert.NoError(t, monitor.oneStep(now.Add(time.Minute)))
+	assert.Equal(t, 1, len(notifier.sent))
+
+	// The second rule matches untriaged digests for a while, then it becomes stale.
+	r2.Count = 5
+	assert.NoError(t, monitor.oneStep(now.Add(2*24*time.Hour)))
+	assert.Equal(t, 1, len(notifier.sent))
+	r2.Count = 0
+	assert.NoError(t, monitor.oneStep(now.Add(8*24*time.Hour)))
+	assert.Equal(t, 1, len(notifier.sent))
+	assert.NoError(t, monitor.oneStep(now.Add(9*24*time.Hour)))
+	assert.Equal(t, 2, len(notifier.sent))
+	assert.Equal(t, NOTIFY_STALE, notifier.sent[1].Type)
+	assert.Equal(t, r2.ID, notifier.sent[1].Rule.ID)
+
+	// The state is persisted, so a new monitor does not notify again.
+	notifier = &mockNotifier{}
+	monitor, err = NewRuleMonitor(store, notifier, opts)
+	assert.NoError(t, err)
+	assert.NoError(t, monitor.oneStep(now.Add(9*24*time.Hour)))
+	assert.Equal(t, 0, len(notifier.sent))
+	assert.True(t, monitor.isStale(r2.ID, now.Add(9*24*time.Hour)))
+	assert.False(t, monitor.isStale(r1.ID, now.Add(9*24*time.Hour)))
+
+	// The state of deleted rules is removed.
+	_, err = store.Delete(r2.ID)
+	assert.NoError(t, err)
+	assert.NoError(t, monitor.oneStep(now.Add(9*24*time.Hour)))
+	_, ok := monitor.state.LastMatched[r2.ID]
+	assert.False(t, ok)
+}
+
+func TestBuildRuleReports(t *testing.T) {
+	testutils.SmallTest(t)
+
+	tile := tiling.NewTile()
+	addTrace := func(id string, params map[string]string) {
+		trace := types.NewGoldenTrace()
+		trace.Params_ = params
+		tile.Traces[id] = trace
+	}
+	addTrace("a", map[string]string{"config": "gpu", "name": "foo"})
+	addTrace("b", map[string]string{"config": "gpu", "name": "bar"})
+	addTrace("c", map[string]string{"config": "8888", "name": "bar"})
+
+	rules := []*IgnoreRule{
+		{ID: 3, Query: "name=bar"},
+		{ID: 1, Query: "config=gpu"},
+		{ID: 2, Query: "config=565"},
+	}
+	reports, err := buildRuleReports(rules, tile)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(reports))
+
+	assert.Equal(t, int64(1), reports[0].Rule.ID)
+	assert.Equal(t, 2, reports[0].NumTraces)
+	assert.Equal(t, 1, reports[0].NumExclusiveTraces)
+	assert.Equal(t, []string{"a", "b"}, reports[0].Traces)
+	assert.Equal(t, []int64{3}, reports[0].Overlaps)
+
+	assert.Equal(t, int64(2), reports[1].Rule.ID)
+	assert.Equal(t, 0, reports[1].NumTraces)
+	assert.Equal(t, []string{}, reports[1].Traces)
+	assert.Equal(t, []int64{}, reports[1].Overlaps)
+
+	assert.Equal(t, int64(3), reports[2].Rule.ID)
+	assert.Equal(t, 2, reports[2].NumTraces)
+	assert.Equal(t, 1, reports[2].NumExclusiveTraces)
+	assert.Equal(t, []string{"b", "c"}, reports[2].Traces)
+	assert.Equal(t, []int64{1}, reports[2].Overlaps)
+
+	rules = append(rules, &IgnoreRule{ID: 4, Query: "bad=%"})
+	_, err = buildRuleReports(rules, tile)
+	assert.Error(t, err)
+}
+
+// mockEmail records the emails it was asked to send.
+type mockEmail struct {
+	to   []string
+	body string
+}
+
+func (m *mockEmail) Send(senderDisplayName string, to []string, subject string, body string) error {
+	m.to = to
+	m.body = body
+	return nil
+}
+
+func TestEmailNotifier(t *testing.T) {
+	testutils.SmallTest(t)
+
+	email := &mockEmail{}
+	rule := NewIgnoreRule("jon@example.com", time.Now().Add(time.Hour), "config=gpu&name=<b>", "reason")
+	notifier := NewEmailNotifier(email)
+	assert.NoError(t, notifier.Notify(&Notification{
+		Type:    NOTIFY_EXPIRING,
+		To:      rule.UpdatedBy,
+		Subject: "expiring",
+		Message: "Your rule expires.",
+		URL:     "https://gold.skia.org/ignores",
+		Rule:    rule,
+	}))
+	assert.Equal(t, []string{"jon@example.com"}, email.to)
+	assert.Contains(t, email.body, "Your rule expires.")
+	assert.Contains(t, email.body, "config=gpu&amp;name=&lt;b&gt;")
+}
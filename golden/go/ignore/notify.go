@@ -0,0 +1,143 @@
+package ignore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/util"
+)
+
+const (
+	// SENDER_DISPLAY_NAME is the sender of notification emails about ignore rules.
+	SENDER_DISPLAY_NAME = "Skia Gold"
+
+	// NOTIFY_EXPIRING indicates that an ignore rule is about to expire.
+	NOTIFY_EXPIRING NotificationType = "expiring"
+
+	// NOTIFY_STALE indicates that an ignore rule has not matched any untriaged
+	// digests for a while and is probably no longer needed.
+	NOTIFY_STALE NotificationType = "stale"
+
+	// EMAIL_BODY is the template for the body of notification emails.
+	EMAIL_BODY = `<p>{{.Message}}</p>
+<p style="padding: 1em;">
+	Query: <b>{{.Rule.Query}}</b><br>
+	Note: {{.Rule.Note}}<br>
+	Expires: {{.Rule.Expires.Format "2006-01-02 15:04 MST"}}
+</p>
+<p>
+	Manage the ignore rules at <a href="{{.URL}}">{{.URL}}</a>.
+</p>`
+)
+
+var emailBodyTemplate = template.Must(template.New("email").Parse(EMAIL_BODY))
+
+// NotificationType is the reason a Notification is sent.
+type NotificationType string
+
+// Notification is sent to the owner of an ignore rule. It is also the body of
+// the JSON that is posted to a webhook.
+type Notification struct {
+	Type    NotificationType `json:"type"`
+	To      string           `json:"to"` // The owner of the rule, i.e. IgnoreRule.UpdatedBy.
+	Subject string           `json:"subject"`
+	Message string           `json:"message"`
+	URL     string           `json:"url"` // Link to the ignore rules in Gold.
+	Rule    *IgnoreRule      `json:"rule"`
+}
+
+// Notifier delivers notifications about ignore rules.
+type Notifier interface {
+	// Notify sends the given notification.
+	Notify(n *Notification) error
+}
+
+// Email sending interface. Note that email.GMail implements this interface.
+type Email interface {
+	Send(senderDisplayName string, to []string, subject string, body string) error
+}
+
+// emailNotifier implements the Notifier interface by sending HTML email to the
+// owner of the rule.
+type emailNotifier struct {
+	email Email
+}
+
+// NewEmailNotifier returns a Notifier that emails the owner of the rule.
+func NewEmailNotifier(email Email) Notifier {
+	return &emailNotifier{email: email}
+}
+
+// Notify implements the Notifier interface.
+func (e *emailNotifier) Notify(n *Notification) error {
+	if n.To == "" {
+		sklog.Warningf("Not sending email for ignore rule %d since it has no owner.", n.Rule.ID)
+		return nil
+	}
+
+	var body bytes.Buffer
+	if err := emailBodyTemplate.Execute(&body, n); err != nil {
+		return fmt.Errorf("Failed to format email body: %s", err)
+	}
+	return e.email.Send(SENDER_DISPLAY_NAME, []string{n.To}, n.Subject, body.String())
+}
+
+// webhookNotifier implements the Notifier interface by POSTing the
+// notification as JSON to a URL.
+type webhookNotifier struct {
+	client *http.Client
+	url    string
+}
+
+// NewWebhookNotifier returns a Notifier that POSTs the notification serialized
+// as JSON to the given URL.
+func NewWebhookNotifier(client *http.Client, url string) Notifier {
+	return &webhookNotifier{
+		client: client,
+		url:    url,
+	}
+}
+
+// Notify implements the Notifier interface.
+func (w *webhookNotifier) Notify(n *Notification) error {
+	b, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("Failed to encode notification: %s", err)
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("Failed to send notification: %s", err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Wrong status code sending notification: %d %s", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
+
+// multiNotifier implements the Notifier interface by sending the notification
+// via multiple Notifiers.
+type multiNotifier []Notifier
+
+// NewMultiNotifier returns a Notifier that delivers notifications via all the
+// given Notifiers.
+func NewMultiNotifier(notifiers ...Notifier) Notifier {
+	return multiNotifier(notifiers)
+}
+
+// Notify implements the Notifier interface. It tries all Notifiers and returns
+// the last error that occurred.
+func (m multiNotifier) Notify(n *Notification) error {
+	var lastErr error
+	for _, notifier := range m {
+		if err := notifier.Notify(n); err != nil {
+			sklog.Errorf("Error sending notification for ignore rule %d: %s", n.Rule.ID, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
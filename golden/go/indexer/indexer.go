@@ -5,6 +5,7 @@ package indexer
 import (
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.skia.org/infra/go/metrics2"
@@ -21,6 +22,7 @@ import (
 	"go.skia.org/infra/golden/go/storage"
 	"go.skia.org/infra/golden/go/summary"
 	"go.skia.org/infra/golden/go/tally"
+	"go.skia.org/infra/golden/go/traceclass"
 	"go.skia.org/infra/golden/go/tryjobstore"
 	"go.skia.org/infra/golden/go/types"
 	"go.skia.org/infra/golden/go/warmer"
@@ -48,6 +50,7 @@ type SearchIndex struct {
 	paramsetSummary      *paramsets.ParamSummary
 	blamer               *blame.Blamer
 	warmer               *warmer.Warmer
+	traceClasses         *traceclass.TraceClasses
 
 	// This is set by the indexing pipeline when we just want to update
 	// individual tests that have changed.
@@ -68,6 +71,7 @@ func newSearchIndex(storages *storage.Storage, tilePair *types.TilePair) *Search
 		paramsetSummary:      paramsets.New(),
 		blamer:               blame.New(storages),
 		warmer:               warmer.New(storages),
+		traceClasses:         traceclass.New(nil),
 		storages:             storages,
 	}
 }
@@ -146,6 +150,17 @@ func (idx *SearchIndex) GetBlame(test, digest string, commits []*tiling.Commit)
 	return idx.blamer.GetBlame(test, digest, commits)
 }
 
+// Proxy to blame.Blamer.GetDigestBlame.
+func (idx *SearchIndex) GetDigestBlame(test, digest string) (*blame.DigestBlame, error) {
+	return idx.blamer.GetDigestBlame(test, digest)
+}
+
+// GetTraceClasses returns the classes of the traces in the tile keyed by trace id.
+// Traces that are not assigned to any class are omitted.
+func (idx *SearchIndex) GetTraceClasses() map[string]traceclass.Classes {
+	return idx.traceClasses.ByTrace()
+}
+
 // Indexer is the type that drive continously indexing as the underlying
 // data change. It uses a DAG that encodes the dependencies of the
 // different components of an index and creates a processing pipeline on top
@@ -176,6 +191,9 @@ func New(storages *storage.Storage, interval time.Duration) (*Indexer, error) {
 
 	blamerNode := indexTestsNode.Child(calcBlame)
 
+	// Trace classes depend on the expectations, e.g. to find new negatives.
+	traceClassNode := indexTestsNode.Child(calcTraceClasses)
+
 	// write baselines whenever a new tile is processed or when the expectations
 	// change.
 	pdag.NewNode(writeMasterBaseline, indexTestsNode)
@@ -184,13 +202,16 @@ func New(storages *storage.Storage, interval time.Duration) (*Indexer, error) {
 	tallyNode := root.Child(calcTallies)
 	tallyIgnoresNode := root.Child(calcTalliesWithIgnores)
 
+	// Auto-triage depends on the tallies of the tile without ignored traces.
+	pdag.NewNode(runAutoTriage, tallyNode)
+
 	// parameters depend on tallies.
 	paramsNode := pdag.NewNode(calcParamsets, tallyNode, tallyIgnoresNode)
 	pdag.NewNode(writeKnownHashesList, tallyIgnoresNode)
 
-	// summaries depend on tallies and blamer.
-	summaryNode := pdag.NewNode(calcSummaries, tallyNode, blamerNode)
-	summaryIgnoresNode := pdag.NewNode(calcSummariesWithIgnores, tallyIgnoresNode, blamerNode)
+	// summaries depend on tallies, blamer and trace classes.
+	summaryNode := pdag.NewNode(calcSummaries, tallyNode, blamerNode, traceClassNode)
+	summaryIgnoresNode := pdag.NewNode(calcSummariesWithIgnores, tallyIgnoresNode, blamerNode, traceClassNode)
 
 	// The warmer depends on summaries.
 	pdag.NewNode(runWarmer, summaryNode, summaryIgnoresNode)
@@ -308,6 +329,7 @@ func (ixr *Indexer) indexTests(testChanges []types.TestExp) {
 		paramsetSummary:      lastIdx.paramsetSummary,
 		blamer:               blame.New(ixr.storages),
 		warmer:               warmer.New(ixr.storages),
+		traceClasses:         traceclass.New(nil),
 		testNames:            testNames.Keys(),
 		storages:             lastIdx.storages,
 	}
@@ -367,14 +389,14 @@ func calcTalliesWithIgnores(state interface{}) error {
 // calcSummaries is the pipeline function to calculate the summaries.
 func calcSummaries(state interface{}) error {
 	idx := state.(*SearchIndex)
-	err := idx.summaries.Calculate(idx.tilePair.Tile, idx.testNames, idx.tallies, idx.blamer)
+	err := idx.summaries.Calculate(idx.tilePair.Tile, idx.testNames, idx.tallies, idx.blamer, idx.traceClasses)
 	return err
 }
 
 // calcSummariesWithIgnores is the pipeline function to calculate the summaries.
 func calcSummariesWithIgnores(state interface{}) error {
 	idx := state.(*SearchIndex)
-	err := idx.summariesWithIgnores.Calculate(idx.tilePair.TileWithIgnores, idx.testNames, idx.talliesWithIgnores, idx.blamer)
+	err := idx.summariesWithIgnores.Calculate(idx.tilePair.TileWithIgnores, idx.testNames, idx.talliesWithIgnores, idx.blamer, idx.traceClasses)
 	return err
 }
 
@@ -392,6 +414,19 @@ func calcBlame(state interface{}) error {
 	return err
 }
 
+// calcTraceClasses is the pipeline function to classify the traces. Since the
+// traces of the tile are a subset of the traces in the tile with ignores, the
+// latter is used for both.
+func calcTraceClasses(state interface{}) error {
+	idx := state.(*SearchIndex)
+	exp, err := idx.storages.ExpectationsStore.Get()
+	if err != nil {
+		return err
+	}
+	idx.traceClasses.Calculate(idx.tilePair.TileWithIgnores, exp)
+	return nil
+}
+
 func writeKnownHashesList(state interface{}) error {
 	idx := state.(*SearchIndex)
 
@@ -452,6 +487,35 @@ func writeMasterBaseline(state interface{}) error {
 	return nil
 }
 
+// autoTriageRunning is 1 while runAutoTriage is applying the auto-triage
+// rules and 0 otherwise.
+var autoTriageRunning int32
+
+// runAutoTriage is the pipeline function to apply the auto-triage rules. It
+// runs asynchronously since changed expectations trigger a new index anyway.
+func runAutoTriage(state interface{}) error {
+	idx := state.(*SearchIndex)
+
+	// Only instances that were configured to auto-triage write expectations.
+	if idx.storages.AutoTriager == nil {
+		return nil
+	}
+
+	// Skip this run if the previous one is still going, otherwise the runs
+	// would queue up behind each other since each run triggers a new index.
+	if !atomic.CompareAndSwapInt32(&autoTriageRunning, 0, 1) {
+		sklog.Infof("Skipping auto-triage, the previous run hasn't finished.")
+		return nil
+	}
+	go func() {
+		defer atomic.StoreInt32(&autoTriageRunning, 0)
+		if _, err := idx.storages.AutoTriager.Run(idx.tilePair.Tile, idx.tallies.ByTest()); err != nil {
+			sklog.Errorf("Error running auto-triage: %s", err)
+		}
+	}()
+	return nil
+}
+
 // runWamer is the pipeline function to run the wamer. It runs it
 // asynchronously since its results are not relevant for the searchIndex.
 func runWarmer(state interface{}) error {
@@ -180,7 +180,7 @@ func setupStorages(t testutils.TestingT, ctx context.Context) (*storage.Storage,
 	traceDB, err := tracedb.NewTraceServiceDBFromAddress(*traceService, types.GoldenTraceBuilder)
 	assert.NoError(t, err)
 
-	masterTileBuilder, err := tracedb.NewMasterTileBuilder(ctx, traceDB, git, N_COMMITS, evt, "")
+	masterTileBuilder, err := tracedb.NewMasterTileBuilder(ctx, traceDB, git, N_COMMITS, true, evt, "")
 	assert.NoError(t, err)
 
 	ret := &storage.Storage{
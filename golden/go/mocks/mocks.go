@@ -55,6 +55,9 @@ func (m MockDiffStore) PurgeDigests(digests []string, purgeGCS bool) error
 func (m MockDiffStore) ImageHandler(urlPrefix string) (http.Handler, error)                   { return nil, nil }
 func (m MockDiffStore) WarmDigests(priority int64, digests []string, sync bool)               {}
 func (m MockDiffStore) WarmDiffs(priority int64, leftDigests []string, rightDigests []string) {}
+func (m MockDiffStore) ImageInfo(priority int64, digests []string) (map[string]*diff.ImageInfo, error) {
+	return nil, nil
+}
 
 func NewMockDiffStore() diff.DiffStore {
 	return MockDiffStore{}
@@ -129,7 +132,7 @@ func GetTileBuilderFromEnv(t assert.TestingT, ctx context.Context) tracedb.Maste
 	db, err := tracedb.NewTraceServiceDBFromAddress(traceDBAddress, types.GoldenTraceBuilder)
 	assert.NoError(t, err)
 
-	tileBuilder, err := tracedb.NewMasterTileBuilder(ctx, db, git, 50, eventBus, "")
+	tileBuilder, err := tracedb.NewMasterTileBuilder(ctx, db, git, 50, true, eventBus, "")
 	assert.NoError(t, err)
 	return tileBuilder
 }
@@ -0,0 +1,127 @@
+package search
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"sort"
+
+	"go.opencensus.io/trace"
+	"go.skia.org/infra/golden/go/types"
+)
+
+const (
+	// MAX_BULK_TRIAGE_DIGESTS is the maximum number of digests that can be
+	// changed by a single bulk triage operation.
+	MAX_BULK_TRIAGE_DIGESTS = 10000
+
+	// NO_MAX_DIFF indicates that the candidates of a bulk triage operation are
+	// not restricted by their diff to the closest reference digest.
+	NO_MAX_DIFF = -1
+)
+
+// BulkTriageDigest is a digest whose label is changed by a bulk triage
+// operation.
+type BulkTriageDigest struct {
+	Test   string `json:"test"`
+	Digest string `json:"digest"`
+
+	// Status is the label of the digest before the bulk triage operation.
+	Status string `json:"status"`
+
+	// ClosestDigest is the closest digest that already has the target label
+	// and ClosestDiff is the diff to it according to the metric of the query.
+	// They are only set if a maximum diff was given.
+	ClosestDigest string  `json:"closestDigest"`
+	ClosestDiff   float32 `json:"closestDiff"`
+}
+
+// BulkTriageCandidates returns the digests matching the given query whose label
+// would change if they were assigned the given label. If maxDiff is not
+// negative only digests whose diff to the closest digest with the target label
+// is at most maxDiff are returned. In that case the label must be positive or
+// negative. The result is sorted by test and digest.
+func (s *SearchAPI) BulkTriageCandidates(ctx context.Context, q *Query, label types.Label, maxDiff float32) ([]*BulkTriageDigest, error) {
+	ctx, span := trace.StartSpan(ctx, "search/BulkTriageCandidates")
+	defer span.End()
+
+	if (maxDiff >= 0) && (label == types.UNTRIAGED) {
+		return nil, fmt.Errorf("A maximum diff can only be used to triage digests as positive or negative.")
+	}
+
+	// Search returns all matching digests regardless of the limit. Only the
+	// displayed digests are padded with traces, so we keep that set minimal.
+	bulkQuery := *q
+	bulkQuery.Offset = 0
+	bulkQuery.Limit = 1
+	bulkQuery.NoDiff = maxDiff < 0
+	searchResp, err := s.Search(ctx, &bulkQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := selectBulkTriageDigests(searchResp.Digests, label, maxDiff, q.Metric)
+	if len(ret) > MAX_BULK_TRIAGE_DIGESTS {
+		return nil, fmt.Errorf("Query matches %d digests. Bulk triage is limited to %d digests.", len(ret), MAX_BULK_TRIAGE_DIGESTS)
+	}
+	return ret, nil
+}
+
+// BulkTriageToken returns a token that identifies the given label and the
+// digests that a bulk triage operation would change, as returned by
+// BulkTriageCandidates. It allows to verify that the digests have not changed
+// between the preview of a bulk triage operation and applying it.
+func BulkTriageToken(label types.Label, digests []*BulkTriageDigest) string {
+	h := md5.New()
+	_, _ = fmt.Fprintf(h, "%s\n", label)
+	for _, d := range digests {
+		_, _ = fmt.Fprintf(h, "%s %s %s\n", d.Test, d.Digest, d.Status)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// selectBulkTriageDigests returns the digests that do not have the target label
+// yet and, if maxDiff is not negative, are within maxDiff of the closest
+// digest with the target label according to the given metric.
+func selectBulkTriageDigests(digests []*SRDigest, label types.Label, maxDiff float32, metric string) []*BulkTriageDigest {
+	refKey := REF_CLOSEST_POSTIVE
+	if label == types.NEGATIVE {
+		refKey = REF_CLOSEST_NEGATIVE
+	}
+
+	ret := make([]*BulkTriageDigest, 0, len(digests))
+	for _, d := range digests {
+		if d.Status == label.String() {
+			continue
+		}
+
+		entry := &BulkTriageDigest{
+			Test:        d.Test,
+			Digest:      d.Digest,
+			Status:      d.Status,
+			ClosestDiff: NO_MAX_DIFF,
+		}
+		if maxDiff >= 0 {
+			ref, ok := d.RefDiffs[refKey]
+			if !ok || (ref == nil) || (ref.DiffMetrics == nil) {
+				continue
+			}
+			// A missing metric means the digests can't be compared with it.
+			v, ok := ref.Diffs[metric]
+			if !ok || v > maxDiff {
+				continue
+			}
+			entry.ClosestDigest = ref.Digest
+			entry.ClosestDiff = v
+		}
+		ret = append(ret, entry)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Test == ret[j].Test {
+			return ret[i].Digest < ret[j].Digest
+		}
+		return ret[i].Test < ret[j].Test
+	})
+	return ret
+}
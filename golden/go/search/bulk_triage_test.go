@@ -0,0 +1,85 @@
+package search
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/types"
+)
+
+func TestSelectBulkTriageDigests(t *testing.T) {
+	testutils.SmallTest(t)
+
+	refDiff := func(digest string, value float32) *SRDiffDigest {
+		return &SRDiffDigest{
+			Digest:      digest,
+			DiffMetrics: &diff.DiffMetrics{Diffs: map[string]float32{diff.METRIC_COMBINED: value}},
+		}
+	}
+	digests := []*SRDigest{
+		{Test: "foo", Digest: "d3", Status: types.UNTRIAGED.String(), RefDiffs: map[string]*SRDiffDigest{
+			REF_CLOSEST_POSTIVE:  refDiff("p1", 0.5),
+			REF_CLOSEST_NEGATIVE: nil,
+		}},
+		{Test: "foo", Digest: "d1", Status: types.POSITIVE.String()},
+		{Test: "bar", Digest: "d2", Status: types.NEGATIVE.String(), RefDiffs: map[string]*SRDiffDigest{
+			REF_CLOSEST_POSTIVE:  refDiff("p2", 2),
+			REF_CLOSEST_NEGATIVE: refDiff("n1", 0.1),
+		}},
+		{Test: "foo", Digest: "d4", Status: types.UNTRIAGED.String()},
+	}
+
+	// Without a maximum diff all digests with a different label are selected.
+	ret := selectBulkTriageDigests(digests, types.POSITIVE, NO_MAX_DIFF, diff.METRIC_COMBINED)
+	assert.Equal(t, []*BulkTriageDigest{
+		{Test: "bar", Digest: "d2", Status: "negative", ClosestDiff: NO_MAX_DIFF},
+		{Test: "foo", Digest: "d3", Status: "untriaged", ClosestDiff: NO_MAX_DIFF},
+		{Test: "foo", Digest: "d4", Status: "untriaged", ClosestDiff: NO_MAX_DIFF},
+	}, ret)
+
+	// Digests without a reference diff or too far from the closest positive
+	// digest are skipped.
+	ret = selectBulkTriageDigests(digests, types.POSITIVE, 1, diff.METRIC_COMBINED)
+	assert.Equal(t, []*BulkTriageDigest{
+		{Test: "foo", Digest: "d3", Status: "untriaged", ClosestDigest: "p1", ClosestDiff: 0.5},
+	}, ret)
+
+	ret = selectBulkTriageDigests(digests, types.NEGATIVE, 1, diff.METRIC_COMBINED)
+	assert.Equal(t, 0, len(ret))
+
+	ret = selectBulkTriageDigests(digests, types.UNTRIAGED, NO_MAX_DIFF, diff.METRIC_COMBINED)
+	assert.Equal(t, 2, len(ret))
+
+	// A missing metric is not treated as a diff of 0.
+	ret = selectBulkTriageDigests(digests, types.POSITIVE, 1, diff.METRIC_SSIM)
+	assert.Equal(t, 0, len(ret))
+	digests = append(digests, &SRDigest{Test: "foo", Digest: "d5", Status: types.UNTRIAGED.String(), RefDiffs: map[string]*SRDiffDigest{
+		REF_CLOSEST_POSTIVE: {Digest: "p3"},
+	}})
+	ret = selectBulkTriageDigests(digests, types.POSITIVE, 1, diff.METRIC_COMBINED)
+	assert.Equal(t, 1, len(ret))
+}
+
+func TestBulkTriageToken(t *testing.T) {
+	testutils.SmallTest(t)
+
+	digests := []*BulkTriageDigest{
+		{Test: "bar", Digest: "d2", Status: "negative"},
+		{Test: "foo", Digest: "d3", Status: "untriaged"},
+	}
+	token := BulkTriageToken(types.POSITIVE, digests)
+	assert.Equal(t, token, BulkTriageToken(types.POSITIVE, []*BulkTriageDigest{
+		{Test: "bar", Digest: "d2", Status: "negative"},
+		{Test: "foo", Digest: "d3", Status: "untriaged"},
+	}))
+
+	// The token changes with the label and the digests.
+	assert.NotEqual(t, token, BulkTriageToken(types.NEGATIVE, digests))
+	assert.NotEqual(t, token, BulkTriageToken(types.POSITIVE, digests[:1]))
+	assert.NotEqual(t, token, BulkTriageToken(types.POSITIVE, []*BulkTriageDigest{
+		{Test: "bar", Digest: "d2", Status: "untriaged"},
+		{Test: "foo", Digest: "d3", Status: "untriaged"},
+	}))
+}
@@ -6,6 +6,7 @@ import (
 	"go.skia.org/infra/go/sklog"
 	"go.skia.org/infra/go/tiling"
 	"go.skia.org/infra/golden/go/indexer"
+	"go.skia.org/infra/golden/go/traceclass"
 	"go.skia.org/infra/golden/go/types"
 )
 
@@ -36,6 +37,10 @@ func iterTile(query *Query, addFn AddFn, acceptFn AcceptFn, exp ExpSlice, idx *i
 	}
 
 	traceTally := idx.TalliesByTrace(query.IncludeIgnores)
+	var traceClasses map[string]traceclass.Classes
+	if query.FTraceClass != "" {
+		traceClasses = idx.GetTraceClasses()
+	}
 	lastTraceIdx, traceView, err := getTraceViewFn(tile, query.FCommitBegin, query.FCommitEnd)
 	if err != nil {
 		return err
@@ -45,6 +50,11 @@ func iterTile(query *Query, addFn AddFn, acceptFn AcceptFn, exp ExpSlice, idx *i
 	for id, trace := range tile.Traces {
 		// Check if the query matches.
 		if tiling.Matches(trace, query.Query) {
+			// Only include traces of the requested class.
+			if (traceClasses != nil) && !traceClasses[id].Has(traceclass.Class(query.FTraceClass)) {
+				continue
+			}
+
 			fullTr := trace.(*types.GoldenTrace)
 			params := fullTr.Params_
 			reducedTr := traceView(fullTr)
@@ -50,6 +50,7 @@ type SRDigest struct {
 	ClosestRef string                   `json:"closestRef"`
 	RefDiffs   map[string]*SRDiffDigest `json:"refDiffs"`
 	Blame      *blame.BlameDistribution `json:"blame"`
+	ImageInfo  *diff.ImageInfo          `json:"imageInfo,omitempty"`
 }
 
 // SRDiffDigest captures the diff information between
@@ -57,11 +58,12 @@ type SRDigest struct {
 // digest is given by the context where this is used.
 type SRDiffDigest struct {
 	*diff.DiffMetrics
-	Test     string              `json:"test"`
-	Digest   string              `json:"digest"`
-	Status   string              `json:"status"`
-	ParamSet map[string][]string `json:"paramset"`
-	N        int                 `json:"n"`
+	Test      string              `json:"test"`
+	Digest    string              `json:"digest"`
+	Status    string              `json:"status"`
+	ParamSet  map[string][]string `json:"paramset"`
+	N         int                 `json:"n"`
+	ImageInfo *diff.ImageInfo     `json:"imageInfo,omitempty"`
 }
 
 // NewSearchResponse is the structure returned by the
@@ -263,21 +265,51 @@ func (s *SearchAPI) GetDigestDetails(test, digest string) (*SRDigestDetails, err
 		s.addParamsAndTraces(ctx, ret, inter, exp, idx)
 	}
 
+	s.addImageInfo(ret[0])
+
 	return &SRDigestDetails{
 		Digest:  ret[0],
 		Commits: tile.Commits,
 	}, nil
 }
 
+// addImageInfo adds the format and bit depth of the digest and its reference
+// digests to the given search result.
+func (s *SearchAPI) addImageInfo(srDigest *SRDigest) {
+	digests := []string{srDigest.Digest}
+	for _, ref := range srDigest.RefDiffs {
+		if ref != nil {
+			digests = append(digests, ref.Digest)
+		}
+	}
+
+	infos, err := s.storages.DiffStore.ImageInfo(diff.PRIORITY_NOW, digests)
+	if err != nil {
+		sklog.Errorf("Unable to retrieve image info for %v: %s", digests, err)
+		return
+	}
+
+	srDigest.ImageInfo = infos[srDigest.Digest]
+	for _, ref := range srDigest.RefDiffs {
+		if ref != nil {
+			ref.ImageInfo = infos[ref.Digest]
+		}
+	}
+}
+
 // getExpectationsFromQuery returns a slice of expectations that should be
 // used in the given query. It will add the issue expectations if this is
-// querying tryjob results. If query is nil the expectations of the master
+// querying tryjob results and the branch expectations if an expectations
+// branch was selected. If query is nil the expectations of the master
 // tile are returned.
 func (s *SearchAPI) getExpectationsFromQuery(q *Query) (ExpSlice, error) {
-	ret := make(ExpSlice, 0, 2)
+	ret := make(ExpSlice, 0, 3)
 
 	if (q != nil) && (q.Issue > 0) {
-		issueExpStore := s.storages.IssueExpStoreFactory(q.Issue)
+		issueExpStore, err := s.storages.IssueExpStoreFactory(q.Issue)
+		if err != nil {
+			return nil, err
+		}
 		tjExp, err := issueExpStore.Get()
 		if err != nil {
 			return nil, sklog.FmtErrorf("Unable to load expectations for issue %d from tryjobstore: %s", q.Issue, err)
@@ -285,6 +317,16 @@ func (s *SearchAPI) getExpectationsFromQuery(q *Query) (ExpSlice, error) {
 		ret = append(ret, tjExp)
 	}
 
+	if (q != nil) && (q.Branch != "") {
+		branchExp, err := s.storages.GetBranchExpectations(q.Branch)
+		if err != nil {
+			return nil, sklog.FmtErrorf("Unable to load expectations for branch %s: %s", q.Branch, err)
+		}
+
+		// The branch expectations already contain the master expectations.
+		return append(ret, branchExp), nil
+	}
+
 	exp, err := s.storages.ExpectationsStore.Get()
 	if err != nil {
 		return nil, sklog.FmtErrorf("Unable to load expectations for master: %s", err)
@@ -519,8 +561,14 @@ func (s *SearchAPI) afterDiffResultFilter(ctx context.Context, digestInfo []*SRD
 		}
 
 		// Filter all digests where the diff is below the given threshold.
-		if filterDiffMax && (!ok || (ref.Diffs[q.Metric] > q.FDiffMax)) {
-			continue
+		// Digests where the metric is unknown are excluded.
+		if filterDiffMax {
+			if !ok {
+				continue
+			}
+			if d, known := ref.Metric(q.Metric); !known || (d > q.FDiffMax) {
+				continue
+			}
 		}
 
 		// If selected only consider digests that have a reference to compare to.
@@ -653,24 +701,35 @@ type srDigestSlice struct {
 	lessFn srDigestSliceLessFn
 }
 
+// closestDiff returns the value of the metric for the closest reference of
+// the digest, or false if there is no closest reference or the value is
+// unknown.
+func closestDiff(d *SRDigest, metric string) (float32, bool) {
+	ref, ok := d.RefDiffs[d.ClosestRef]
+	if !ok || (ref == nil) {
+		return 0, false
+	}
+	return ref.Metric(metric)
+}
+
 // newSRDigestSlice creates a new instance of srDigestSlice that wraps around
 // a slice of result digests.
 func newSRDigestSlice(metric string, slice []*SRDigest) *srDigestSlice {
-	// Sort by increasing by diff metric. Not having a diff metric puts the item at the bottom
-	// of the list.
+	// Sort by increasing by diff metric. Not having a diff metric, or not
+	// knowing its value, puts the item at the bottom of the list.
 	lessFn := func(i, j *SRDigest) bool {
-		if (i.ClosestRef == "") && (j.ClosestRef == "") {
+		iDiff, iOk := closestDiff(i, metric)
+		jDiff, jOk := closestDiff(j, metric)
+		if !iOk && !jOk {
 			return i.Digest < j.Digest
 		}
 
-		if i.ClosestRef == "" {
+		if !iOk {
 			return false
 		}
-		if j.ClosestRef == "" {
+		if !jOk {
 			return true
 		}
-		iDiff := i.RefDiffs[i.ClosestRef].Diffs[metric]
-		jDiff := j.RefDiffs[j.ClosestRef].Diffs[metric]
 
 		// If they are the same then sort by digest to make the result stable.
 		if iDiff == jDiff {
@@ -11,6 +11,8 @@ import (
 
 	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/expstorage"
+	"go.skia.org/infra/golden/go/traceclass"
 	"go.skia.org/infra/golden/go/types"
 )
 
@@ -276,12 +278,20 @@ func ParseQuery(r *http.Request, query *Query) error {
 	query.Head = r.FormValue("head") == "true"
 	query.IncludeIgnores = r.FormValue("include") == "true"
 	query.IncludeMaster = r.FormValue("master") == "true"
+	query.Branch = r.FormValue("branch")
+	if (query.Branch != "") && !expstorage.ValidBranchName(query.Branch) {
+		return fmt.Errorf("Invalid expectations branch: %s", query.Branch)
+	}
 
 	// Extract the filter values.
 	query.FCommitBegin = r.FormValue("fbegin")
 	query.FCommitEnd = r.FormValue("fend")
 	query.FGroupTest = r.FormValue("fgrouptest")
 	query.FRef = r.FormValue("fref") == "true"
+	query.FTraceClass = r.FormValue("ftraceclass")
+	if (query.FTraceClass != "") && !traceclass.ValidClass(query.FTraceClass) {
+		return fmt.Errorf("Invalid trace class: %s", query.FTraceClass)
+	}
 
 	// Check if we want diffs.
 	query.NoDiff = r.FormValue("nodiff") == "true"
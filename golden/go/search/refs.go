@@ -76,9 +76,9 @@ func (r *RefDiffer) GetRefDiffs(metric string, match []string, test, digest stri
 			val.N = tally[val.Digest]
 
 			// Find the minimum.
-			if val.DiffMetrics.Diffs[metric] < minDiff {
+			if d, ok := val.DiffMetrics.Metric(metric); ok && d < minDiff {
 				minKey = key
-				minDiff = val.DiffMetrics.Diffs[metric]
+				minDiff = d
 			}
 		}
 	}
@@ -118,15 +118,19 @@ func (r *RefDiffer) getClosestDiff(metric, digest string, compDigests []string)
 		return nil
 	}
 
+	// Digests for which the metric is unknown can't be the closest.
 	minDiff := float32(math.Inf(1))
 	minDigest := ""
 	for resultDigest, diffInfo := range diffs {
-		diffMetrics := diffInfo.(*diff.DiffMetrics)
-		if diffMetrics.Diffs[metric] < minDiff {
-			minDiff = diffMetrics.Diffs[metric]
+		d, ok := diffInfo.(*diff.DiffMetrics).Metric(metric)
+		if ok && d < minDiff {
+			minDiff = d
 			minDigest = resultDigest
 		}
 	}
+	if minDigest == "" {
+		return nil
+	}
 
 	return &SRDiffDigest{
 		DiffMetrics: diffs[minDigest].(*diff.DiffMetrics),
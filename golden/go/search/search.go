@@ -137,14 +137,18 @@ type Query struct {
 	Patchsets     []int64 `json:"-"`
 	IncludeMaster bool    `json:"master"` // Include digests also contained in master when searching code review issues.
 
+	// Branch is the name of the expectations branch to use. Empty for master.
+	Branch string `json:"branch"`
+
 	// Filtering.
-	FCommitBegin string  `json:"fbegin"`     // Start commit
-	FCommitEnd   string  `json:"fend"`       // End commit
-	FRGBAMin     int32   `json:"frgbamin"`   // Min RGBA delta
-	FRGBAMax     int32   `json:"frgbamax"`   // Max RGBA delta
-	FDiffMax     float32 `json:"fdiffmax"`   // Max diff according to metric
-	FGroupTest   string  `json:"fgrouptest"` // Op within grouped by test.
-	FRef         bool    `json:"fref"`       // Only digests with reference.
+	FCommitBegin string  `json:"fbegin"`      // Start commit
+	FCommitEnd   string  `json:"fend"`        // End commit
+	FRGBAMin     int32   `json:"frgbamin"`    // Min RGBA delta
+	FRGBAMax     int32   `json:"frgbamax"`    // Max RGBA delta
+	FDiffMax     float32 `json:"fdiffmax"`    // Max diff according to metric
+	FGroupTest   string  `json:"fgrouptest"`  // Op within grouped by test.
+	FRef         bool    `json:"fref"`        // Only digests with reference.
+	FTraceClass  string  `json:"ftraceclass"` // Only traces of this class, see traceclass.Class.
 
 	// Pagination.
 	Offset int32 `json:"offset"`
@@ -5,9 +5,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 
 	gstorage "cloud.google.com/go/storage"
+	"go.skia.org/infra/go/fileutil"
 	"go.skia.org/infra/go/gcs"
 	"go.skia.org/infra/go/sklog"
 	"go.skia.org/infra/go/util"
@@ -17,6 +21,7 @@ import (
 )
 
 // GSClientOptions is used to define input parameters to the GStorageClient.
+// Paths that start with fileutil.LOCAL_PATH_PREFIX are written to the local file system.
 type GSClientOptions struct {
 	HashesGSPath   string // bucket and path for storing the list of known digests.
 	BaselineGSPath string // bucket and path for storing the base line information. This is considered a directory.
@@ -46,7 +51,7 @@ func NewGStorageClient(client *http.Client, options *GSClientOptions) (*GStorage
 // WriteKnownDigests writes the given list of digests to GS as newline
 // separated strings.
 func (g *GStorageClient) WriteKnownDigests(digests []string) error {
-	writeFn := func(w *gstorage.Writer) error {
+	writeFn := func(w io.Writer) error {
 		for _, digest := range digests {
 			if _, err := w.Write([]byte(digest + "\n")); err != nil {
 				return fmt.Errorf("Error writing digests: %s", err)
@@ -59,9 +64,10 @@ func (g *GStorageClient) WriteKnownDigests(digests []string) error {
 }
 
 // WriteBaseLine writes the given baseline to GCS. It returns the path of the
-// written file in GCS (prefixed with 'gs://').
+// written file in GCS (prefixed with 'gs://') or the local path (prefixed with
+// fileutil.LOCAL_PATH_PREFIX).
 func (g *GStorageClient) WriteBaseLine(baseLine *baseline.CommitableBaseLine) (string, error) {
-	writeFn := func(w *gstorage.Writer) error {
+	writeFn := func(w io.Writer) error {
 		if err := json.NewEncoder(w).Encode(baseLine); err != nil {
 			return fmt.Errorf("Error encoding baseline to JSON: %s", err)
 		}
@@ -69,28 +75,20 @@ func (g *GStorageClient) WriteBaseLine(baseLine *baseline.CommitableBaseLine) (s
 	}
 
 	outPath := g.getBaselinePath(baseLine.Issue)
+	if _, ok := fileutil.LocalPath(outPath); ok {
+		return outPath, g.writeToPath(outPath, "application/json", writeFn)
+	}
 	return "gs://" + outPath, g.writeToPath(outPath, "application/json", writeFn)
 }
 
 // ReadBaseline returns the baseline for the given issue from GCS.
 func (g *GStorageClient) ReadBaseline(issueID int64) (*baseline.CommitableBaseLine, error) {
-	baselinePath := g.getBaselinePath(issueID)
-	bucketName, storagePath := gcs.SplitGSPath(baselinePath)
-
-	ctx := context.Background()
-	target := g.storageClient.Bucket(bucketName).Object(storagePath)
-
-	_, err := target.Attrs(ctx)
+	reader, err := g.newReader(g.getBaselinePath(issueID))
 	if err != nil {
 		// If the item doesn't exist we return an empty baseline
 		if err == gstorage.ErrObjectNotExist {
 			return &baseline.CommitableBaseLine{Baseline: types.TestExp{}}, nil
 		}
-		return nil, sklog.FmtErrorf("Error fetching attributes of baseline file: %s", err)
-	}
-
-	reader, err := target.NewReader(ctx)
-	if err != nil {
 		return nil, sklog.FmtErrorf("Error getting reader for baseline file: %s", err)
 	}
 	defer util.Close(reader)
@@ -116,18 +114,8 @@ func (g *GStorageClient) getBaselinePath(issueID int64) string {
 // loadKnownDigests loads the digests that have previously been written
 // to GS via WriteKnownDigests. Used for testing.
 func (g *GStorageClient) loadKnownDigests() ([]string, error) {
-	bucketName, storagePath := gcs.SplitGSPath(g.options.HashesGSPath)
-
-	ctx := context.Background()
-	target := g.storageClient.Bucket(bucketName).Object(storagePath)
-
 	// If the item doesn't exist this will return gstorage.ErrObjectNotExist
-	_, err := target.Attrs(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	reader, err := target.NewReader(ctx)
+	reader, err := g.newReader(g.options.HashesGSPath)
 	if err != nil {
 		return nil, err
 	}
@@ -143,14 +131,50 @@ func (g *GStorageClient) loadKnownDigests() ([]string, error) {
 
 // removeGSPath removes the given file. Primarily used for testing.
 func (g *GStorageClient) removeGSPath(targetPath string) error {
+	if localPath, ok := fileutil.LocalPath(targetPath); ok {
+		return os.Remove(localPath)
+	}
+
 	bucketName, storagePath := gcs.SplitGSPath(targetPath)
 	target := g.storageClient.Bucket(bucketName).Object(storagePath)
 	return target.Delete(context.Background())
 }
 
+// newReader returns a reader for the given path in GCS or on the local file
+// system. If the file does not exist gstorage.ErrObjectNotExist is returned.
+func (g *GStorageClient) newReader(targetPath string) (io.ReadCloser, error) {
+	if localPath, ok := fileutil.LocalPath(targetPath); ok {
+		f, err := os.Open(localPath)
+		if os.IsNotExist(err) {
+			return nil, gstorage.ErrObjectNotExist
+		}
+		return f, err
+	}
+
+	bucketName, storagePath := gcs.SplitGSPath(targetPath)
+	ctx := context.Background()
+	target := g.storageClient.Bucket(bucketName).Object(storagePath)
+	if _, err := target.Attrs(ctx); err != nil {
+		return nil, err
+	}
+	return target.NewReader(ctx)
+}
+
 // writeToPath is a generic function that allows to write data to the given
-// target path in GCS. The actual writing is done in the passed write function.
-func (g *GStorageClient) writeToPath(targetPath, contentType string, wrtFn func(w *gstorage.Writer) error) error {
+// target path in GCS or on the local file system. The actual writing is done
+// in the passed write function.
+func (g *GStorageClient) writeToPath(targetPath, contentType string, wrtFn func(w io.Writer) error) error {
+	if localPath, ok := fileutil.LocalPath(targetPath); ok {
+		if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+			return err
+		}
+		if err := util.WithWriteFile(localPath, wrtFn); err != nil {
+			return err
+		}
+		sklog.Infof("File written to local path %s", localPath)
+		return nil
+	}
+
 	bucketName, storagePath := gcs.SplitGSPath(targetPath)
 
 	// Only write the known digests if a target path was given.
@@ -17,6 +17,7 @@ import (
 	"go.skia.org/infra/go/tiling"
 	tracedb "go.skia.org/infra/go/trace/db"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/autotriage"
 	"go.skia.org/infra/golden/go/baseline"
 	"go.skia.org/infra/golden/go/diff"
 	"go.skia.org/infra/golden/go/digeststore"
@@ -32,19 +33,27 @@ import (
 // Storage is a container struct for the various storage objects we are using.
 // It is intended to reduce parameter lists as we pass around storage objects.
 type Storage struct {
-	DiffStore            diff.DiffStore
-	ExpectationsStore    expstorage.ExpectationsStore
-	IssueExpStoreFactory expstorage.IssueExpStoreFactory
-	IgnoreStore          ignore.IgnoreStore
-	MasterTileBuilder    tracedb.MasterTileBuilder
-	DigestStore          digeststore.DigestStore
-	EventBus             eventbus.EventBus
-	TryjobStore          tryjobstore.TryjobStore
-	TryjobMonitor        *tryjobs.TryjobMonitor
-	GerritAPI            *gerrit.Gerrit
-	GStorageClient       *GStorageClient
-	Git                  *gitinfo.GitInfo
-	WhiteListQuery       paramtools.ParamSet
+	DiffStore             diff.DiffStore
+	ExpectationsStore     expstorage.ExpectationsStore
+	IssueExpStoreFactory  expstorage.IssueExpStoreFactory
+	BranchExpStoreFactory expstorage.BranchExpStoreFactory
+	IgnoreStore           ignore.IgnoreStore
+	IgnoreMonitor         *ignore.RuleMonitor
+	AutoTriageStore       autotriage.RuleStore
+	AutoTriager           *autotriage.AutoTriager
+	MasterTileBuilder     tracedb.MasterTileBuilder
+	DigestStore           digeststore.DigestStore
+	EventBus              eventbus.EventBus
+	TryjobStore           tryjobstore.TryjobStore
+	TryjobMonitor         *tryjobs.TryjobMonitor
+	GerritAPI             *gerrit.Gerrit
+	GStorageClient        *GStorageClient
+	Git                   *gitinfo.GitInfo
+	WhiteListQuery        paramtools.ParamSet
+
+	// ExpBranches are the names of the expectation branches that can be
+	// selected, e.g. in search and when triaging.
+	ExpBranches []string
 
 	// NCommits is the number of commits we should consider. If NCommits is
 	// 0 or smaller all commits in the last tile will be considered.
@@ -99,7 +108,10 @@ func (s *Storage) PushIssueBaseline(issueID int64, tile *tiling.Tile, tallies *t
 		return sklog.FmtErrorf("Trying to write baseline while GCS path is not configured.")
 	}
 
-	issueExpStore := s.IssueExpStoreFactory(issueID)
+	issueExpStore, err := s.IssueExpStoreFactory(issueID)
+	if err != nil {
+		return err
+	}
 	exp, err := issueExpStore.Get()
 	if err != nil {
 		return sklog.FmtErrorf("Unable to get issue expecations: %s", err)
@@ -153,6 +165,36 @@ func (s *Storage) FetchBaseline(issueID int64) (*baseline.CommitableBaseLine, er
 	return masterBaseline, nil
 }
 
+// GetBranchExpStore returns the ExpectationsStore that contains the overrides
+// of the given expectations branch. An error is returned if the branch is not
+// one of the configured ExpBranches.
+func (s *Storage) GetBranchExpStore(branch string) (expstorage.ExpectationsStore, error) {
+	if (s.BranchExpStoreFactory == nil) || !util.In(branch, s.ExpBranches) {
+		return nil, sklog.FmtErrorf("Unknown expectations branch: %s", branch)
+	}
+	return s.BranchExpStoreFactory(branch)
+}
+
+// GetBranchExpectations returns the expectations of the given branch, i.e. the
+// master expectations with the overrides of the branch applied.
+func (s *Storage) GetBranchExpectations(branch string) (types.Expectations, error) {
+	branchStore, err := s.GetBranchExpStore(branch)
+	if err != nil {
+		return nil, err
+	}
+	return expstorage.BranchExpectations(s.ExpectationsStore, branchStore)
+}
+
+// CalcBranchBaseline calculates the baseline of the given expectations branch
+// based on the given tile.
+func (s *Storage) CalcBranchBaseline(branch string, tile *tiling.Tile) (*baseline.CommitableBaseLine, error) {
+	exps, err := s.GetBranchExpectations(branch)
+	if err != nil {
+		return nil, err
+	}
+	return baseline.GetBaselineForBranch(branch, exps, tile), nil
+}
+
 // LoadWhiteList loads the given JSON5 file that defines that query to
 // whitelist traces. If the given path is empty or the file cannot be parsed
 // an error will be returned.
@@ -332,10 +374,11 @@ func (s *Storage) GetOrUpdateDigestInfo(testName, digest string, commit *tiling.
 		return digestInfo, nil
 	}
 	digestInfo = &digeststore.DigestInfo{
-		TestName: testName,
-		Digest:   digest,
-		First:    commit.CommitTime,
-		Last:     commit.CommitTime,
+		TestName:    testName,
+		Digest:      digest,
+		First:       commit.CommitTime,
+		Last:        commit.CommitTime,
+		FirstCommit: commit,
 	}
 	err = s.DigestStore.Update([]*digeststore.DigestInfo{digestInfo})
 	if err != nil {
@@ -391,7 +434,10 @@ func (s *Storage) checkCommitableIssues(tile *tiling.Tile) {
 						return sklog.FmtErrorf("Unable to extract gerrit issue from commit %s. Got error: %s", commit.Hash, err)
 					}
 
-					issueExpStore := s.IssueExpStoreFactory(issueID)
+					issueExpStore, err := s.IssueExpStoreFactory(issueID)
+					if err != nil {
+						return err
+					}
 					issueExps, err := issueExpStore.Get()
 					if err != nil {
 						return sklog.FmtErrorf("Unable to retrieve expecations for issue %d: %s", issueID, err)
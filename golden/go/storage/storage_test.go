@@ -2,11 +2,13 @@ package storage
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/fileutil"
 	"go.skia.org/infra/go/testutils"
 	"go.skia.org/infra/golden/go/baseline"
 	"go.skia.org/infra/golden/go/types"
@@ -45,7 +47,39 @@ var (
 func TestWritingHashes(t *testing.T) {
 	testutils.LargeTest(t)
 	gsClient, opt := initGSClient(t)
+	testWritingHashes(t, gsClient, opt)
+}
+
+func TestWritingBaselines(t *testing.T) {
+	testutils.LargeTest(t)
+	gsClient, _ := initGSClient(t)
+	testWritingBaselines(t, gsClient)
+}
+
+func TestBaselineRobustness(t *testing.T) {
+	testutils.LargeTest(t)
+	gsClient, _ := initGSClient(t)
+	testBaselineRobustness(t, gsClient)
+}
+
+func TestLocalGStorageClient(t *testing.T) {
+	testutils.SmallTest(t)
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	opt := &GSClientOptions{
+		HashesGSPath:   fileutil.LOCAL_PATH_PREFIX + filepath.Join(dir, "hashes.txt"),
+		BaselineGSPath: fileutil.LOCAL_PATH_PREFIX + filepath.Join(dir, "baselines"),
+	}
+	gsClient, err := NewGStorageClient(nil, opt)
+	assert.NoError(t, err)
+	testWritingHashes(t, gsClient, opt)
+	testBaselineRobustness(t, gsClient)
+	testWritingBaselines(t, gsClient)
+}
 
+func testWritingHashes(t *testing.T, gsClient *GStorageClient, opt *GSClientOptions) {
 	knownDigests := []string{
 		"c003788f8d306ff1226e2a460835dae4",
 		"885b31941c25efc313b0fd66d55b86d9",
@@ -71,10 +105,7 @@ func TestWritingHashes(t *testing.T) {
 	assert.Equal(t, knownDigests, found)
 }
 
-func TestWritingBaselines(t *testing.T) {
-	testutils.LargeTest(t)
-
-	gsClient, _ := initGSClient(t)
+func testWritingBaselines(t *testing.T, gsClient *GStorageClient) {
 	removePaths := []string{}
 	defer func() {
 		for _, path := range removePaths {
@@ -113,11 +144,7 @@ func TestWritingBaselines(t *testing.T) {
 	assert.Equal(t, combined, foundBaseline)
 }
 
-func TestBaselineRobustness(t *testing.T) {
-	testutils.LargeTest(t)
-
-	gsClient, _ := initGSClient(t)
-
+func testBaselineRobustness(t *testing.T, gsClient *GStorageClient) {
 	removePaths := []string{}
 	defer func() {
 		for _, path := range removePaths {
@@ -16,6 +16,7 @@ import (
 	"go.skia.org/infra/golden/go/diff"
 	"go.skia.org/infra/golden/go/storage"
 	"go.skia.org/infra/golden/go/tally"
+	"go.skia.org/infra/golden/go/traceclass"
 	"go.skia.org/infra/golden/go/types"
 )
 
@@ -32,6 +33,10 @@ type Summary struct {
 	Num       int                    `json:"num"`
 	Corpus    string                 `json:"corpus"`
 	Blame     []*blame.WeightedBlame `json:"blame"`
+
+	// TraceClasses contains the number of traces of this test in each class,
+	// e.g. the number of flaky traces.
+	TraceClasses traceclass.Counts `json:"traceClasses"`
 }
 
 // clone creates a copy of the summary.
@@ -44,6 +49,10 @@ func (s *Summary) clone() *Summary {
 		ret.Blame[idx] = &blame.WeightedBlame{}
 		*ret.Blame[idx] = *b
 	}
+	ret.TraceClasses = make(traceclass.Counts, len(s.TraceClasses))
+	for class, count := range s.TraceClasses {
+		ret.TraceClasses[class] = count
+	}
 	return ret
 }
 
@@ -51,10 +60,11 @@ func (s *Summary) clone() *Summary {
 //
 // It also updates itself when Tallies have been updated.
 type Summaries struct {
-	storages  *storage.Storage
-	tallies   *tally.Tallies
-	blamer    *blame.Blamer
-	summaries map[string]*Summary
+	storages     *storage.Storage
+	tallies      *tally.Tallies
+	blamer       *blame.Blamer
+	traceClasses *traceclass.TraceClasses
+	summaries    map[string]*Summary
 }
 
 // New creates a new instance of Summaries.
@@ -72,19 +82,22 @@ func (s *Summaries) Clone() *Summaries {
 	}
 
 	return &Summaries{
-		storages:  s.storages,
-		tallies:   s.tallies,
-		blamer:    s.blamer,
-		summaries: copied,
+		storages:     s.storages,
+		tallies:      s.tallies,
+		blamer:       s.blamer,
+		traceClasses: s.traceClasses,
+		summaries:    copied,
 	}
 }
 
 // Calculate sets the summaries based on the given tile. If testNames is empty
 // (or nil) the entire tile will be calculated. Otherwise only the given
-// test names will be updated.
-func (s *Summaries) Calculate(tile *tiling.Tile, testNames []string, tallies *tally.Tallies, blamer *blame.Blamer) error {
+// test names will be updated. traceClasses can be nil, in which case the
+// summaries contain no trace classes.
+func (s *Summaries) Calculate(tile *tiling.Tile, testNames []string, tallies *tally.Tallies, blamer *blame.Blamer, traceClasses *traceclass.TraceClasses) error {
 	s.tallies = tallies
 	s.blamer = blamer
+	s.traceClasses = traceClasses
 
 	summaries, err := s.CalcSummaries(tile, testNames, nil, true)
 	if err != nil {
@@ -160,9 +173,15 @@ func (s *Summaries) CalcSummaries(tile *tiling.Tile, testNames []string, query u
 	lastCommitIndex := tile.LastCommitIndex()
 	for name, traces := range filtered {
 		digests := util.NewStringSet()
+		classCounts := traceclass.Counts{}
 		corpus := ""
 		for _, trid := range traces {
 			corpus = trid.tr.Params()[types.CORPUS_FIELD]
+			if s.traceClasses != nil {
+				for _, class := range s.traceClasses.Get(trid.id) {
+					classCounts[class]++
+				}
+			}
 			if head {
 				// Find the last non-missing value in the trace.
 				for i := lastCommitIndex; i >= 0; i-- {
@@ -189,6 +208,7 @@ func (s *Summaries) CalcSummaries(tile *tiling.Tile, testNames []string, query u
 			}
 		}
 		ret[name] = s.makeSummary(name, e, s.storages.DiffStore, corpus, digests.Keys())
+		ret[name].TraceClasses = classCounts
 	}
 	t.Stop()
 
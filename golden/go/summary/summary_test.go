@@ -15,6 +15,7 @@ import (
 	"go.skia.org/infra/golden/go/mocks"
 	"go.skia.org/infra/golden/go/storage"
 	"go.skia.org/infra/golden/go/tally"
+	"go.skia.org/infra/golden/go/traceclass"
 	"go.skia.org/infra/golden/go/types"
 )
 
@@ -179,10 +180,20 @@ func TestCalcSummaries(t *testing.T) {
 	err = blamer.Calculate(tile)
 	assert.NoError(t, err)
 
+	exp, err := storages.ExpectationsStore.Get()
+	assert.NoError(t, err)
+	traceClasses := traceclass.New(nil)
+	traceClasses.Calculate(tile, exp)
+
 	summaries := New(storages)
-	assert.NoError(t, summaries.Calculate(tileWithoutIgnored, nil, ta, blamer))
+	assert.NoError(t, summaries.Calculate(tileWithoutIgnored, nil, ta, blamer, traceClasses))
+
+	// Trace 'a' changed from a positive to a negative digest at head.
+	sum := summaries.Get()
+	assert.Equal(t, traceclass.Counts{traceclass.NEW_NEGATIVE: 1}, sum["foo"].TraceClasses)
+	assert.Equal(t, traceclass.Counts{}, sum["bar"].TraceClasses)
 
-	sum, err := summaries.CalcSummaries(tileWithoutIgnored, nil, url.Values{types.CORPUS_FIELD: {"gm"}}, false)
+	sum, err = summaries.CalcSummaries(tileWithoutIgnored, nil, url.Values{types.CORPUS_FIELD: {"gm"}}, false)
 	if err != nil {
 		t.Fatalf("Failed to calc: %s", err)
 	}
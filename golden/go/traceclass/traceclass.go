@@ -0,0 +1,210 @@
+// traceclass classifies traces based on the sequence of digests they produced
+// over the commits of a tile, e.g. to find flaky traces or traces that started
+// to produce negative digests at head.
+package traceclass
+
+import (
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/go/timer"
+	"go.skia.org/infra/golden/go/types"
+)
+
+// Class is the classification of a trace.
+type Class string
+
+const (
+	// FLAKY traces alternate between digests, i.e. they repeatedly return to a
+	// digest they had already produced before.
+	FLAKY Class = "flaky"
+
+	// NEW_NEGATIVE traces produce a negative digest at head, but did not produce
+	// a negative digest at the commit before that.
+	NEW_NEGATIVE Class = "newneg"
+
+	// POSITIVE_FLIP traces flip back and forth between positive digests.
+	POSITIVE_FLIP Class = "posflip"
+
+	// Default values for Options.
+	DEFAULT_FLAKY_MIN_DIGESTS         = 2
+	DEFAULT_FLAKY_MIN_REVERTS         = 2
+	DEFAULT_POSITIVE_FLIP_MIN_REVERTS = 1
+)
+
+// AllClasses contains all classes a trace can be assigned to.
+var AllClasses = []Class{FLAKY, NEW_NEGATIVE, POSITIVE_FLIP}
+
+// ValidClass returns true if the given string is the name of a Class.
+func ValidClass(s string) bool {
+	for _, c := range AllClasses {
+		if string(c) == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Classes is the set of classes of a single trace.
+type Classes []Class
+
+// Has returns true if the given class is contained in the set.
+func (c Classes) Has(class Class) bool {
+	for _, cl := range c {
+		if cl == class {
+			return true
+		}
+	}
+	return false
+}
+
+// Counts maps a class to the number of traces of that class.
+type Counts map[Class]int
+
+// Options control when a trace is assigned to a class.
+type Options struct {
+	// FlakyMinDigests is the minimum number of distinct digests of a flaky trace.
+	FlakyMinDigests int
+
+	// FlakyMinReverts is the minimum number of times a flaky trace has to
+	// change back to a digest it produced earlier in the tile.
+	FlakyMinReverts int
+
+	// PositiveFlipMinReverts is the minimum number of times a trace has to
+	// change back to a positive digest it produced earlier in the tile, while
+	// producing a different positive digest in between.
+	PositiveFlipMinReverts int
+}
+
+// DefaultOptions returns the options used by Gold to classify traces.
+func DefaultOptions() *Options {
+	return &Options{
+		FlakyMinDigests:        DEFAULT_FLAKY_MIN_DIGESTS,
+		FlakyMinReverts:        DEFAULT_FLAKY_MIN_REVERTS,
+		PositiveFlipMinReverts: DEFAULT_POSITIVE_FLIP_MIN_REVERTS,
+	}
+}
+
+// TraceClasses contains the classes of all traces in a tile.
+// It is not thread safe. The client of this package needs to make sure there
+// are no conflicts.
+type TraceClasses struct {
+	options *Options
+	byTrace map[string]Classes
+	byTest  map[string]Counts
+}
+
+// New creates a new instance of TraceClasses. If options is nil the values of
+// DefaultOptions() are used.
+func New(options *Options) *TraceClasses {
+	if options == nil {
+		options = DefaultOptions()
+	}
+	return &TraceClasses{
+		options: options,
+		byTrace: map[string]Classes{},
+		byTest:  map[string]Counts{},
+	}
+}
+
+// Calculate classifies all traces of the given tile based on the given
+// expectations.
+func (t *TraceClasses) Calculate(tile *tiling.Tile, exp types.Expectations) {
+	defer timer.New("traceclass.Calculate").Stop()
+
+	byTrace := map[string]Classes{}
+	byTest := map[string]Counts{}
+	for id, tr := range tile.Traces {
+		gTrace := tr.(*types.GoldenTrace)
+		classes := t.classify(gTrace, exp)
+		if len(classes) == 0 {
+			continue
+		}
+
+		byTrace[id] = classes
+		test := gTrace.Params_[types.PRIMARY_KEY_FIELD]
+		counts, ok := byTest[test]
+		if !ok {
+			counts = Counts{}
+			byTest[test] = counts
+		}
+		for _, class := range classes {
+			counts[class]++
+		}
+	}
+	t.byTrace = byTrace
+	t.byTest = byTest
+}
+
+// ByTrace returns the classes of the traces keyed by trace id. Traces that are
+// not assigned to any class are omitted.
+func (t *TraceClasses) ByTrace() map[string]Classes {
+	return t.byTrace
+}
+
+// ByTest returns the number of traces of each class keyed by test name.
+func (t *TraceClasses) ByTest() map[string]Counts {
+	return t.byTest
+}
+
+// Get returns the classes of the given trace or nil if the trace is not
+// assigned to any class.
+func (t *TraceClasses) Get(traceID string) Classes {
+	return t.byTrace[traceID]
+}
+
+// classify returns the classes of a single trace.
+func (t *TraceClasses) classify(trace *types.GoldenTrace, exp types.Expectations) Classes {
+	test := trace.Params_[types.PRIMARY_KEY_FIELD]
+
+	// Collect the non-missing values of the trace.
+	values := make([]string, 0, len(trace.Values))
+	for _, digest := range trace.Values {
+		if digest != types.MISSING_DIGEST {
+			values = append(values, digest)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	ret := Classes{}
+	distinct, reverts := countReverts(values)
+	if (distinct >= t.options.FlakyMinDigests) && (reverts >= t.options.FlakyMinReverts) {
+		ret = append(ret, FLAKY)
+	}
+
+	// Check if the trace went from non-negative to negative at head.
+	last := len(values) - 1
+	if exp.Classification(test, values[last]) == types.NEGATIVE {
+		if (last == 0) || (exp.Classification(test, values[last-1]) != types.NEGATIVE) {
+			ret = append(ret, NEW_NEGATIVE)
+		}
+	}
+
+	// Check if the trace flips between positive digests.
+	positives := make([]string, 0, len(values))
+	for _, digest := range values {
+		if exp.Classification(test, digest) == types.POSITIVE {
+			positives = append(positives, digest)
+		}
+	}
+	if _, posReverts := countReverts(positives); posReverts >= t.options.PositiveFlipMinReverts {
+		ret = append(ret, POSITIVE_FLIP)
+	}
+
+	return ret
+}
+
+// countReverts returns the number of distinct digests in the given sequence and
+// the number of times the sequence changes back to a digest that appeared
+// earlier.
+func countReverts(digests []string) (int, int) {
+	seen := make(map[string]bool, len(digests))
+	reverts := 0
+	for i, digest := range digests {
+		if (i > 0) && (digest != digests[i-1]) && seen[digest] {
+			reverts++
+		}
+		seen[digest] = true
+	}
+	return len(seen), reverts
+}
@@ -0,0 +1,77 @@
+package traceclass
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/golden/go/types"
+)
+
+func TestCalculate(t *testing.T) {
+	testutils.SmallTest(t)
+
+	m := types.MISSING_DIGEST
+	tile := tiling.NewTile()
+	addTrace := func(id, test string, values ...string) {
+		tile.Traces[id] = &types.GoldenTrace{
+			Values:  values,
+			Params_: map[string]string{types.PRIMARY_KEY_FIELD: test},
+		}
+	}
+
+	// Stable traces and traces that changed once.
+	addTrace("stable", "foo", "aaa", "aaa", m, "aaa", "aaa")
+	addTrace("changed", "foo", "aaa", "aaa", "bbb", "bbb", "bbb")
+	addTrace("empty", "foo", m, m, m, m, m)
+
+	// Alternates between two untriaged digests.
+	addTrace("flaky", "foo", "ccc", "ddd", "ccc", m, "ddd", "ddd")
+
+	// The positive digest is replaced by a negative digest at head. Missing
+	// values are skipped.
+	addTrace("newneg", "bar", "aaa", "aaa", "aaa", "neg1", m)
+
+	// Negative since the beginning, so it is not a new regression.
+	addTrace("oldneg", "bar", "neg1", "neg2", "neg2")
+
+	// Flips between two positive digests with an untriaged digest in between.
+	addTrace("posflip", "bar", "aaa", "bbb", "ccc", "aaa")
+
+	exp := types.NewExpectations(types.TestExp{
+		"foo": {"aaa": types.POSITIVE, "bbb": types.POSITIVE},
+		"bar": {"aaa": types.POSITIVE, "bbb": types.POSITIVE, "neg1": types.NEGATIVE, "neg2": types.NEGATIVE},
+	})
+
+	tc := New(nil)
+	tc.Calculate(tile, exp)
+	assert.Equal(t, map[string]Classes{
+		"flaky":   {FLAKY},
+		"newneg":  {NEW_NEGATIVE},
+		"posflip": {POSITIVE_FLIP},
+	}, tc.ByTrace())
+	assert.Equal(t, map[string]Counts{
+		"foo": {FLAKY: 1},
+		"bar": {NEW_NEGATIVE: 1, POSITIVE_FLIP: 1},
+	}, tc.ByTest())
+	assert.True(t, tc.Get("flaky").Has(FLAKY))
+	assert.False(t, tc.Get("flaky").Has(NEW_NEGATIVE))
+	assert.Nil(t, tc.Get("stable"))
+
+	// With stricter options the positive flip is also flaky, but the trace that
+	// alternates between untriaged digests is not.
+	tc = New(&Options{
+		FlakyMinDigests:        3,
+		FlakyMinReverts:        1,
+		PositiveFlipMinReverts: 2,
+	})
+	tc.Calculate(tile, exp)
+	assert.Equal(t, map[string]Classes{
+		"newneg":  {NEW_NEGATIVE},
+		"posflip": {FLAKY},
+	}, tc.ByTrace())
+
+	assert.True(t, ValidClass("flaky"))
+	assert.False(t, ValidClass("unknown"))
+}
@@ -0,0 +1,344 @@
+package tryjobstore
+
+import (
+	"sort"
+	"sync"
+
+	"go.skia.org/infra/go/eventbus"
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/expstorage"
+)
+
+// localTryjobState is the content of the file that backs a localTryjobStore.
+type localTryjobState struct {
+	// Issues maps issue ids to issues. The Tryjobs of the patchsets are not set.
+	Issues map[int64]*Issue
+
+	// Tryjobs maps BuildBucket ids to tryjobs.
+	Tryjobs map[int64]*Tryjob
+
+	// Results maps BuildBucket ids to the results of the tryjob.
+	Results map[int64][]*TryjobResult
+}
+
+// localTryjobStore implements the TryjobStore interface by keeping all data in
+// memory and writing it to a file on the local file system after each change.
+type localTryjobStore struct {
+	path            string
+	state           *localTryjobState
+	eventBus        eventbus.EventBus
+	expStoreFactory expstorage.IssueExpStoreFactory
+	mutex           sync.Mutex
+}
+
+// NewLocalTryjobStore creates a new instance of TryjobStore that stores its
+// data in the given file.
+func NewLocalTryjobStore(path string, expStoreFactory expstorage.IssueExpStoreFactory, eventBus eventbus.EventBus) (TryjobStore, error) {
+	if eventBus == nil {
+		return nil, sklog.FmtErrorf("Received nil for eventbus.")
+	}
+
+	state := &localTryjobState{}
+	if err := util.MaybeReadGobFile(path, state); err != nil {
+		return nil, sklog.FmtErrorf("Error reading tryjob data from %s: %s", path, err)
+	}
+	if state.Issues == nil {
+		state.Issues = map[int64]*Issue{}
+	}
+	if state.Tryjobs == nil {
+		state.Tryjobs = map[int64]*Tryjob{}
+	}
+	if state.Results == nil {
+		state.Results = map[int64][]*TryjobResult{}
+	}
+
+	return &localTryjobStore{
+		path:            path,
+		state:           state,
+		eventBus:        eventBus,
+		expStoreFactory: expStoreFactory,
+	}, nil
+}
+
+// ListIssues implements the TryjobStore interface.
+func (l *localTryjobStore) ListIssues(offset, size int) ([]*Issue, int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	issues := make([]*Issue, 0, len(l.state.Issues))
+	for _, issue := range l.state.Issues {
+		issues = append(issues, issue)
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Updated.After(issues[j].Updated) })
+
+	total := len(issues)
+	start := util.MinInt(total, offset)
+	end := util.MinInt(start+size, total)
+	ret := make([]*Issue, 0, end-start)
+	for _, issue := range issues[start:end] {
+		ret = append(ret, copyIssue(issue))
+	}
+	return ret, total, nil
+}
+
+// GetIssue implements the TryjobStore interface.
+func (l *localTryjobStore) GetIssue(issueID int64, loadTryjobs bool) (*Issue, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	issue, ok := l.state.Issues[issueID]
+	if !ok {
+		return nil, nil
+	}
+
+	ret := copyIssue(issue)
+	if loadTryjobs {
+		for patchsetID, tryjobs := range l.getTryjobsForIssue(issueID, nil, true) {
+			ps := ret.FindPatchset(patchsetID)
+			if ps == nil {
+				return nil, sklog.FmtErrorf("Unable to find patchset %d in issue %d:", patchsetID, ret.ID)
+			}
+			ps.Tryjobs = tryjobs
+		}
+	}
+	return ret, nil
+}
+
+// UpdateIssue implements the TryjobStore interface.
+func (l *localTryjobStore) UpdateIssue(details *Issue, updateFn NewValueFn) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	curr, ok := l.state.Issues[details.ID]
+	if updateFn != nil {
+		if !ok {
+			return sklog.FmtErrorf("Unable to find issue %d for transactional update.", details.ID)
+		}
+		newVal := updateFn(copyIssue(curr))
+		if newVal == nil {
+			return nil
+		}
+		details = newVal.(*Issue)
+	} else if ok && !details.newer(curr) {
+		return nil
+	}
+
+	l.state.Issues[details.ID] = copyIssue(details)
+	return l.save()
+}
+
+// CommitIssueExp implements the TryjobStore interface.
+func (l *localTryjobStore) CommitIssueExp(issueID int64, commitFn func() error) error {
+	issue, err := l.GetIssue(issueID, false)
+	if err != nil {
+		return err
+	}
+
+	if issue == nil {
+		return sklog.FmtErrorf("Unable to find issue %d.", issueID)
+	}
+
+	// If this is already committed then we are done.
+	if issue.Committed {
+		return nil
+	}
+
+	// Execute the commit function to commit the actual expectations.
+	if err := commitFn(); err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if curr, ok := l.state.Issues[issueID]; ok {
+		curr.Committed = true
+	}
+	return l.save()
+}
+
+// DeleteIssue implements the TryjobStore interface.
+func (l *localTryjobStore) DeleteIssue(issueID int64) error {
+	// Remove the expectations for this issue.
+	expStore, err := l.expStoreFactory(issueID)
+	if err != nil {
+		return err
+	}
+	if err := expStore.Clear(); err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for buildBucketID, tryjob := range l.state.Tryjobs {
+		if tryjob.IssueID == issueID {
+			delete(l.state.Tryjobs, buildBucketID)
+			delete(l.state.Results, buildBucketID)
+		}
+	}
+	delete(l.state.Issues, issueID)
+	return l.save()
+}
+
+// GetTryjobs implements the TryjobStore interface.
+func (l *localTryjobStore) GetTryjobs(issueID int64, patchsetIDs []int64, filterDup bool, loadResults bool) ([]*Tryjob, [][]*TryjobResult, error) {
+	l.mutex.Lock()
+	tryjobs := []*Tryjob{}
+	for _, tjs := range l.getTryjobsForIssue(issueID, patchsetIDs, filterDup) {
+		tryjobs = append(tryjobs, tjs...)
+	}
+	l.mutex.Unlock()
+
+	sort.Slice(tryjobs, func(i, j int) bool {
+		return tryjobs[i].Builder < tryjobs[j].Builder
+	})
+
+	var results [][]*TryjobResult
+	if loadResults {
+		var err error
+		results, err = l.GetTryjobResults(tryjobs)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return tryjobs, results, nil
+}
+
+// RunningTryjobs implements the TryjobStore interface.
+func (l *localTryjobStore) RunningTryjobs() ([]*Tryjob, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	ret := []*Tryjob{}
+	for _, tryjob := range l.state.Tryjobs {
+		if tryjob.Status < TRYJOB_COMPLETE {
+			ret = append(ret, tryjob.clone())
+		}
+	}
+	return ret, nil
+}
+
+// GetTryjob implements the TryjobStore interface.
+func (l *localTryjobStore) GetTryjob(issueID, buildBucketID int64) (*Tryjob, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if tryjob, ok := l.state.Tryjobs[buildBucketID]; ok {
+		return tryjob.clone(), nil
+	}
+	return nil, nil
+}
+
+// GetTryjobResults implements the TryjobStore interface.
+func (l *localTryjobStore) GetTryjobResults(tryjobs []*Tryjob) ([][]*TryjobResult, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	ret := make([][]*TryjobResult, len(tryjobs))
+	for idx, tryjob := range tryjobs {
+		results := l.state.Results[tryjob.BuildBucketID]
+		ret[idx] = make([]*TryjobResult, len(results))
+		copy(ret[idx], results)
+	}
+	return ret, nil
+}
+
+// UpdateTryjob implements the TryjobStore interface.
+func (l *localTryjobStore) UpdateTryjob(buildBucketID int64, tryjob *Tryjob, newValFn NewValueFn) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if tryjob == nil {
+		// make sure we have the necessary information if there are no data to be written directly.
+		if (buildBucketID == 0) || (newValFn == nil) {
+			return sklog.FmtErrorf("Id and newValFn cannot be nil when no tryjob is provided. Update not possible")
+		}
+
+		curr, ok := l.state.Tryjobs[buildBucketID]
+		if !ok {
+			return sklog.FmtErrorf("Unable to find tryjob %d for transactional update.", buildBucketID)
+		}
+		newVal := newValFn(curr.clone())
+		if newVal == nil {
+			l.eventBus.Publish(EV_TRYJOB_UPDATED, curr.clone(), true)
+			return nil
+		}
+		tryjob = newVal.(*Tryjob)
+	} else if curr, ok := l.state.Tryjobs[tryjob.BuildBucketID]; ok && !tryjob.newer(curr) {
+		l.eventBus.Publish(EV_TRYJOB_UPDATED, tryjob.clone(), true)
+		return nil
+	}
+
+	l.state.Tryjobs[tryjob.BuildBucketID] = tryjob.clone()
+	if err := l.save(); err != nil {
+		return err
+	}
+	l.eventBus.Publish(EV_TRYJOB_UPDATED, tryjob.clone(), true)
+	return nil
+}
+
+// UpdateTryjobResult implements the TryjobStore interface.
+func (l *localTryjobStore) UpdateTryjobResult(tryjob *Tryjob, results []*TryjobResult) error {
+	if err := validateTryjobResults(results); err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.state.Results[tryjob.BuildBucketID] = append(l.state.Results[tryjob.BuildBucketID], results...)
+	return l.save()
+}
+
+// getTryjobsForIssue returns copies of the tryjobs for the given issue and
+// patchsets grouped by patchset as a map[patch_set_id][]*Tryjob. If
+// patchsetIDs is empty the tryjobs of all patchsets are returned. If filterDup
+// is true duplicate tryjobs will be filtered out for each patchset.
+// Assumes the caller holds the mutex.
+func (l *localTryjobStore) getTryjobsForIssue(issueID int64, patchsetIDs []int64, filterDup bool) map[int64][]*Tryjob {
+	var patchsets map[int64]bool
+	if len(patchsetIDs) > 0 {
+		patchsets = make(map[int64]bool, len(patchsetIDs))
+		for _, id := range patchsetIDs {
+			patchsets[id] = true
+		}
+	}
+
+	ret := map[int64][]*Tryjob{}
+	for _, tryjob := range l.state.Tryjobs {
+		if tryjob.IssueID != issueID || (patchsets != nil && !patchsets[tryjob.PatchsetID]) {
+			continue
+		}
+		ret[tryjob.PatchsetID] = append(ret[tryjob.PatchsetID], tryjob.clone())
+	}
+
+	if filterDup {
+		filterDuplicateTryjobs(ret)
+	}
+	return ret
+}
+
+// save writes the state of the store to disk. Assumes the caller holds the
+// mutex.
+func (l *localTryjobStore) save() error {
+	if err := util.WriteGobFile(l.path, l.state); err != nil {
+		return sklog.FmtErrorf("Error writing tryjob data to %s: %s", l.path, err)
+	}
+	return nil
+}
+
+// copyIssue returns a copy of the given issue that can be modified without
+// changing the original. The tryjobs of the patchsets are not copied.
+func copyIssue(issue *Issue) *Issue {
+	ret := &Issue{}
+	*ret = *issue
+	if issue.PatchsetDetails != nil {
+		ret.PatchsetDetails = make([]*PatchsetDetail, 0, len(issue.PatchsetDetails))
+		for _, psd := range issue.PatchsetDetails {
+			psdCopy := *psd
+			psdCopy.Tryjobs = nil
+			ret.PatchsetDetails = append(ret.PatchsetDetails, &psdCopy)
+		}
+	}
+	return ret
+}
@@ -245,7 +245,11 @@ func (c *cloudTryjobStore) DeleteIssue(issueID int64) error {
 
 	// Remove the expectations for this issue.
 	egroup.Go(func() error {
-		return c.expStoreFactory(issueID).Clear()
+		expStore, err := c.expStoreFactory(issueID)
+		if err != nil {
+			return err
+		}
+		return expStore.Clear()
 	})
 
 	// Make sure all dependents are deleted.
@@ -352,30 +356,42 @@ func (c *cloudTryjobStore) GetTryjobResults(tryjobs []*Tryjob) ([][]*TryjobResul
 
 // UpdateTryjobResults implements the TryjobStore interface.
 func (c *cloudTryjobStore) UpdateTryjobResult(tryjob *Tryjob, results []*TryjobResult) error {
+	if err := validateTryjobResults(results); err != nil {
+		return err
+	}
+
 	tryjobKey := c.getTryjobKey(tryjob.BuildBucketID)
 	keys := make([]*datastore.Key, 0, len(results))
+	for range results {
+		keys = append(keys, c.getTryjobResultKey(tryjobKey))
+	}
+
+	// var egroup errgroup.Group
+	for i := 0; i < len(keys); i += batchSize {
+		endIdx := util.MinInt(i+batchSize, len(keys))
+		if _, err := c.client.PutMulti(context.Background(), keys[i:endIdx], results[i:endIdx]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTryjobResults makes sure the given results can be added to a
+// tryjob, i.e. that each one is for exactly one test and that all
+// (test,digest) pairs are unique.
+func validateTryjobResults(results []*TryjobResult) error {
 	uniqueEntries := util.StringSet{}
 	for _, result := range results {
 		// Make sure that tests are not bunched together.
 		if len(result.Params[types.PRIMARY_KEY_FIELD]) != 1 {
 			return fmt.Errorf("Parameter value for primary key field '%s' must exactly contain one value. Found: %v", types.PRIMARY_KEY_FIELD, result.Params[types.PRIMARY_KEY_FIELD])
 		}
-
-		keys = append(keys, c.getTryjobResultKey(tryjobKey))
 		uniqueEntries[result.TestName+result.Digest] = true
 	}
 
-	if len(uniqueEntries) != len(keys) {
+	if len(uniqueEntries) != len(results) {
 		return fmt.Errorf("All (test,digest) pairs must be unique when adding tryjob results.")
 	}
-
-	// var egroup errgroup.Group
-	for i := 0; i < len(keys); i += batchSize {
-		endIdx := util.MinInt(i+batchSize, len(keys))
-		if _, err := c.client.PutMulti(context.Background(), keys[i:endIdx], results[i:endIdx]); err != nil {
-			return err
-		}
-	}
 	return nil
 }
 
@@ -531,30 +547,37 @@ func (c *cloudTryjobStore) getTryjobsForIssue(issueID int64, patchsetIDs []int64
 
 	// Go through the patchsets and dedupe tryjobs for each.
 	if filterDup {
-		for patchsetID, tryjobs := range tryjobsMap {
-			// sort when they were last updated
-			sort.Slice(tryjobs, func(i, j int) bool { return tryjobs[i].Updated.Before(tryjobs[j].Updated) })
-
-			// Iterate the builders in reverse order and filter out duplicate builders.
-			builders := util.StringSet{}
-			for i := len(tryjobs) - 1; i >= 0; i-- {
-				if builders[tryjobs[i].Builder] {
-					copy(tryjobs[i:], tryjobs[i+1:])
-					tryjobs[len(tryjobs)-1] = nil
-					tryjobs = tryjobs[:len(tryjobs)-1]
-				} else {
-					builders[tryjobs[i].Builder] = true
-				}
-			}
-
-			// NOTE: Store the new slice back into the tryjobs map since we might have removed some values.
-			tryjobsMap[patchsetID] = tryjobs
-		}
+		filterDuplicateTryjobs(tryjobsMap)
 	}
 
 	return retKeys, tryjobsMap, nil
 }
 
+// filterDuplicateTryjobs removes duplicate tryjobs for each patchset in the
+// given map[patch_set_id][]*Tryjob, only keeping the newest tryjob for each
+// builder.
+func filterDuplicateTryjobs(tryjobsMap map[int64][]*Tryjob) {
+	for patchsetID, tryjobs := range tryjobsMap {
+		// sort when they were last updated
+		sort.Slice(tryjobs, func(i, j int) bool { return tryjobs[i].Updated.Before(tryjobs[j].Updated) })
+
+		// Iterate the builders in reverse order and filter out duplicate builders.
+		builders := util.StringSet{}
+		for i := len(tryjobs) - 1; i >= 0; i-- {
+			if builders[tryjobs[i].Builder] {
+				copy(tryjobs[i:], tryjobs[i+1:])
+				tryjobs[len(tryjobs)-1] = nil
+				tryjobs = tryjobs[:len(tryjobs)-1]
+			} else {
+				builders[tryjobs[i].Builder] = true
+			}
+		}
+
+		// NOTE: Store the new slice back into the tryjobs map since we might have removed some values.
+		tryjobsMap[patchsetID] = tryjobs
+	}
+}
+
 // updateEntity writes the given entity to the datastore. If the
 // newValFn is not nil an error will be returned if the entity does not exist.
 // The non-nil return value of newValFn will be written to the data store.
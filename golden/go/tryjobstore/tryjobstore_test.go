@@ -3,6 +3,7 @@ package tryjobstore
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 	"sort"
 	"testing"
 	"time"
@@ -38,6 +39,43 @@ func TestCloudTryjobStore(t *testing.T) {
 	testTryjobStore(t, store, expStoreFactory)
 }
 
+func TestLocalTryjobStore(t *testing.T) {
+	testutils.MediumTest(t)
+
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	eventBus := eventbus.New()
+	_, expStoreFactory, err := expstorage.NewLocalExpectationsStore(dir, eventBus)
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "tryjobs.gob")
+	store, err := NewLocalTryjobStore(path, expStoreFactory, eventBus)
+	assert.NoError(t, err)
+
+	testTryjobStore(t, store, expStoreFactory)
+
+	// Make sure the data survive reloading the store.
+	issueID := int64(99)
+	reloaded, err := NewLocalTryjobStore(path, expStoreFactory, eventBus)
+	assert.NoError(t, err)
+	foundIssue, err := reloaded.GetIssue(issueID, true)
+	assert.NoError(t, err)
+	assert.True(t, foundIssue.Committed)
+	foundTJs, foundTJResults, err := reloaded.GetTryjobs(issueID, nil, false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(foundTJs))
+	assert.Equal(t, 3, len(foundTJResults))
+
+	// Deleting the issue removes the tryjobs.
+	assert.NoError(t, reloaded.DeleteIssue(issueID))
+	foundIssue, err = reloaded.GetIssue(issueID, false)
+	assert.NoError(t, err)
+	assert.Nil(t, foundIssue)
+	foundTJs, _, err = reloaded.GetTryjobs(issueID, nil, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(foundTJs))
+}
+
 func testTryjobStore(t *testing.T, store TryjobStore, expStoreFactory expstorage.IssueExpStoreFactory) {
 	// Add the issue and two tryjobs to the store.
 	issueID := int64(99)
@@ -185,7 +223,8 @@ func testTryjobStore(t *testing.T, store TryjobStore, expStoreFactory expstorage
 	allChanges := types.NewExpectations(nil)
 	expLogEntries := []*expstorage.TriageLogEntry{}
 	userName := "jdoe@example.com"
-	expStore := expStoreFactory(issueID)
+	expStore, err := expStoreFactory(issueID)
+	assert.NoError(t, err)
 	for i := 0; i < 5; i++ {
 		triageDetails := []*expstorage.TriageDetail{}
 		changes := types.TestExp{}
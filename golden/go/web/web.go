@@ -19,6 +19,8 @@ import (
 	"go.skia.org/infra/go/sklog"
 	"go.skia.org/infra/go/tiling"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/autotriage"
+	"go.skia.org/infra/golden/go/baseline"
 	"go.skia.org/infra/golden/go/blame"
 	"go.skia.org/infra/golden/go/diff"
 	"go.skia.org/infra/golden/go/expstorage"
@@ -28,6 +30,7 @@ import (
 	"go.skia.org/infra/golden/go/status"
 	"go.skia.org/infra/golden/go/storage"
 	"go.skia.org/infra/golden/go/summary"
+	"go.skia.org/infra/golden/go/traceclass"
 	"go.skia.org/infra/golden/go/tryjobstore"
 	"go.skia.org/infra/golden/go/types"
 	"go.skia.org/infra/golden/go/validation"
@@ -106,11 +109,29 @@ func (wh *WebHandlers) JsonByBlameHandler(w http.ResponseWriter, r *http.Request
 		for _, d := range s.UntHashes {
 			dist := idx.GetBlame(test, d, commits)
 			groupid := strings.Join(lookUpCommits(dist.Freq, commits), ":")
+
+			// If the digest was seen before the current tile, the blame within
+			// the tile is unreliable. Use the blame that was persisted while the
+			// digest was part of an earlier tile instead.
+			var blameCommits []*tiling.Commit = nil
+			if dist.Old {
+				if digestBlame, err := idx.GetDigestBlame(test, d); err != nil {
+					sklog.Warningf("Unable to retrieve blame for %s/%s: %s", test, d, err)
+				} else if len(digestBlame.Commits) > 0 {
+					blameCommits = digestBlame.Commits
+					groupid = strings.Join(commitHashes(blameCommits), ":")
+				}
+			}
+
 			// Only fill in commitinfo for each groupid only once.
 			if _, ok := commitinfo[groupid]; !ok {
 				ci := []*tiling.Commit{}
-				for _, index := range dist.Freq {
-					ci = append(ci, commits[index])
+				if blameCommits != nil {
+					ci = append(ci, blameCommits...)
+				} else {
+					for _, index := range dist.Freq {
+						ci = append(ci, commits[index])
+					}
 				}
 				sort.Sort(CommitSlice(ci))
 				commitinfo[groupid] = ci
@@ -193,6 +214,33 @@ func lookUpCommits(freq []int, commits []*tiling.Commit) []string {
 	return ret
 }
 
+// commitHashes returns the hashes of the given commits.
+func commitHashes(commits []*tiling.Commit) []string {
+	ret := make([]string, 0, len(commits))
+	for _, commit := range commits {
+		ret = append(ret, commit.Hash)
+	}
+	return ret
+}
+
+// JsonDigestBlameHandler returns the commits that likely introduced a digest,
+// even if the digest was first seen before the current tile.
+func (wh *WebHandlers) JsonDigestBlameHandler(w http.ResponseWriter, r *http.Request) {
+	test := r.FormValue("test")
+	digest := r.FormValue("digest")
+	if test == "" || !validation.IsValidDigest(digest) {
+		httputils.ReportError(w, r, fmt.Errorf("Some query parameters are wrong or missing: %q %q", test, digest), "Missing query parameters.")
+		return
+	}
+
+	ret, err := wh.Indexer.GetIndex().GetDigestBlame(test, digest)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Unable to retrieve blame for digest.")
+		return
+	}
+	sendJsonResponse(w, ret)
+}
+
 // ByBlameEntry is a helper structure that is serialized to
 // JSON and sent to the front-end.
 type ByBlameEntry struct {
@@ -420,6 +468,22 @@ func (wh *WebHandlers) JsonIgnoresHandler(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// JsonIgnoresReportHandler returns the ignore rules that are stale, shadow no
+// traces or overlap with other rules, along with the traces they shadow.
+func (wh *WebHandlers) JsonIgnoresReportHandler(w http.ResponseWriter, r *http.Request) {
+	if wh.Storages.IgnoreMonitor == nil {
+		httputils.ReportError(w, r, fmt.Errorf("No ignore monitor configured."), "Ignore rule reports are not available.")
+		return
+	}
+
+	report, err := wh.Storages.IgnoreMonitor.Report(wh.Indexer.GetIndex().GetTile(true))
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to build the ignore rule report.")
+		return
+	}
+	sendJsonResponse(w, report)
+}
+
 // JsonIgnoresUpdateHandler updates an existing ignores rule.
 func (wh *WebHandlers) JsonIgnoresUpdateHandler(w http.ResponseWriter, r *http.Request) {
 	user := login.LoggedInAs(r)
@@ -519,6 +583,112 @@ func (wh *WebHandlers) JsonIgnoresAddHandler(w http.ResponseWriter, r *http.Requ
 	wh.JsonIgnoresHandler(w, r)
 }
 
+// AutoTriageRequest encapsulates a single auto-triage rule that is submitted
+// for addition or update.
+type AutoTriageRequest struct {
+	Query      string                 `json:"query"`
+	Thresholds []autotriage.Threshold `json:"thresholds"`
+	Note       string                 `json:"note"`
+}
+
+// JsonAutoTriageRulesHandler returns the current auto-triage rules in JSON format.
+func (wh *WebHandlers) JsonAutoTriageRulesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	rules, err := wh.Storages.AutoTriageStore.List()
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to retrieve auto-triage rules.")
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(rules); err != nil {
+		sklog.Errorf("Failed to write or encode result: %s", err)
+	}
+}
+
+// parseAutoTriageRequest returns the valid auto-triage rule in the body of
+// the request. It reports an error and returns nil if the request is invalid.
+func parseAutoTriageRequest(w http.ResponseWriter, r *http.Request, user string) *autotriage.Rule {
+	req := &AutoTriageRequest{}
+	if err := parseJson(r, req); err != nil {
+		httputils.ReportError(w, r, err, "Failed to parse submitted data.")
+		return nil
+	}
+	rule := autotriage.NewRule(user, req.Query, req.Thresholds, req.Note)
+	if err := rule.Validate(); err != nil {
+		httputils.ReportError(w, r, err, "Invalid auto-triage rule.")
+		return nil
+	}
+	return rule
+}
+
+// JsonAutoTriageAddHandler is for adding a new auto-triage rule.
+func (wh *WebHandlers) JsonAutoTriageAddHandler(w http.ResponseWriter, r *http.Request) {
+	user := login.LoggedInAs(r)
+	if user == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to add an auto-triage rule.")
+		return
+	}
+	rule := parseAutoTriageRequest(w, r, user)
+	if rule == nil {
+		return
+	}
+
+	if err := wh.Storages.AutoTriageStore.Create(rule); err != nil {
+		httputils.ReportError(w, r, err, "Failed to create auto-triage rule.")
+		return
+	}
+
+	wh.JsonAutoTriageRulesHandler(w, r)
+}
+
+// JsonAutoTriageUpdateHandler updates an existing auto-triage rule.
+func (wh *WebHandlers) JsonAutoTriageUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	user := login.LoggedInAs(r)
+	if user == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to update an auto-triage rule.")
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 0)
+	if err != nil {
+		httputils.ReportError(w, r, err, "ID must be valid integer.")
+		return
+	}
+	rule := parseAutoTriageRequest(w, r, user)
+	if rule == nil {
+		return
+	}
+
+	if err := wh.Storages.AutoTriageStore.Update(id, rule); err != nil {
+		httputils.ReportError(w, r, err, "Unable to update auto-triage rule.")
+		return
+	}
+
+	wh.JsonAutoTriageRulesHandler(w, r)
+}
+
+// JsonAutoTriageDeleteHandler deletes an existing auto-triage rule.
+func (wh *WebHandlers) JsonAutoTriageDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	user := login.LoggedInAs(r)
+	if user == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to delete an auto-triage rule.")
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 0)
+	if err != nil {
+		httputils.ReportError(w, r, err, "ID must be valid integer.")
+		return
+	}
+
+	if _, err = wh.Storages.AutoTriageStore.Delete(id); err != nil {
+		httputils.ReportError(w, r, err, "Unable to delete auto-triage rule.")
+		return
+	}
+
+	wh.JsonAutoTriageRulesHandler(w, r)
+}
+
 // TriageRequest is the form of the JSON posted to jsonTriageHandler.
 type TriageRequest struct {
 	// TestDigestStatus maps status to test name and digests as: map[testName][digest]status
@@ -526,6 +696,10 @@ type TriageRequest struct {
 
 	// Issue is the id of the code review issue for which we want to change the expectations.
 	Issue int64 `json:"issue"`
+
+	// Branch is the name of the expectations branch for which we want to change
+	// the expectations. Empty for the master branch.
+	Branch string `json:"branch"`
 }
 
 // JsonTriageHandler handles a request to change the triage status of one or more
@@ -563,11 +737,10 @@ func (wh *WebHandlers) JsonTriageHandler(w http.ResponseWriter, r *http.Request)
 		tc[test] = labeledDigests
 	}
 
-	// Use the expectations store for the master branch, unless an issue was given
-	// in the request, then get the expectations store for the issue.
-	expStore := wh.Storages.ExpectationsStore
-	if req.Issue > 0 {
-		expStore = wh.Storages.IssueExpStoreFactory(req.Issue)
+	expStore, err := wh.getExpStore(req.Issue, req.Branch)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid expectations branch.")
+		return
 	}
 
 	// Add the change.
@@ -583,6 +756,125 @@ func (wh *WebHandlers) JsonTriageHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// getExpStore returns the expectations store for the master branch, unless an
+// issue or an expectations branch is given, then it returns the expectations
+// store for the issue or the branch.
+func (wh *WebHandlers) getExpStore(issue int64, branch string) (expstorage.ExpectationsStore, error) {
+	if issue > 0 {
+		return wh.Storages.IssueExpStoreFactory(issue)
+	}
+	if branch != "" {
+		return wh.Storages.GetBranchExpStore(branch)
+	}
+	return wh.Storages.ExpectationsStore, nil
+}
+
+// BulkTriageResponse is the response of JsonBulkTriageHandler.
+type BulkTriageResponse struct {
+	// DryRun is true if the expectations were not changed.
+	DryRun bool `json:"dryRun"`
+
+	// Label is the label assigned to the digests.
+	Label string `json:"label"`
+
+	// Digests are the digests whose label was (or would be) changed.
+	Digests []*search.BulkTriageDigest `json:"digests"`
+	Total   int                        `json:"total"`
+
+	// Token identifies the digests. It has to be passed in the request that
+	// applies the previewed bulk triage operation.
+	Token string `json:"token"`
+}
+
+// JsonBulkTriageHandler assigns a label to all digests matching a search query.
+// It accepts the same parameters as JsonSearchHandler and additionally:
+//
+//  label   - the label to assign to the digests, required.
+//  maxdiff - if given, only digests whose diff (according to the 'metric'
+//            parameter) to the closest digest that already has the target
+//            label is at most this value are triaged.
+//  dryrun  - if "true" the expectations are not changed and the response
+//            is a preview of the digests that would be triaged.
+//  token   - the token returned by the dry run, required unless dryrun is
+//            "true". The request fails if the digests matching the query
+//            have changed since the dry run.
+//
+// All changes are added as a single change to the expectations, so they can
+// be undone with one entry of the triage log.
+func (wh *WebHandlers) JsonBulkTriageHandler(w http.ResponseWriter, r *http.Request) {
+	user := login.LoggedInAs(r)
+	if user == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to triage.")
+		return
+	}
+
+	q, ok := parseSearchQuery(w, r)
+	if !ok {
+		return
+	}
+
+	labelStr := r.FormValue("label")
+	if !types.ValidLabel(labelStr) {
+		httputils.ReportError(w, r, fmt.Errorf("Invalid label: %q", labelStr), "Invalid label in bulk triage request.")
+		return
+	}
+	label := types.LabelFromString(labelStr)
+
+	maxDiff := float32(search.NO_MAX_DIFF)
+	if maxDiffStr := r.FormValue("maxdiff"); maxDiffStr != "" {
+		val, err := strconv.ParseFloat(maxDiffStr, 32)
+		if err != nil || val < 0 {
+			httputils.ReportError(w, r, fmt.Errorf("Invalid maxdiff: %q", maxDiffStr), "Invalid maximum diff in bulk triage request.")
+			return
+		}
+		maxDiff = float32(val)
+	}
+	dryRun := r.FormValue("dryrun") == "true"
+	token := r.FormValue("token")
+	if !dryRun && (token == "") {
+		httputils.ReportError(w, r, fmt.Errorf("Missing bulk triage token."), "Bulk triage requires the token of a dry run.")
+		return
+	}
+
+	expStore, err := wh.getExpStore(q.Issue, q.Branch)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid expectations branch.")
+		return
+	}
+
+	digests, err := wh.SearchAPI.BulkTriageCandidates(r.Context(), q, label, maxDiff)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Unable to find the digests to triage.")
+		return
+	}
+
+	newToken := search.BulkTriageToken(label, digests)
+	if !dryRun && (token != newToken) {
+		httputils.ReportError(w, r, fmt.Errorf("Bulk triage token %s does not match %s.", token, newToken), "The digests matching the query have changed since the dry run.")
+		return
+	}
+
+	if !dryRun && (len(digests) > 0) {
+		tc := types.TestExp{}
+		for _, d := range digests {
+			tc.AddDigest(d.Test, d.Digest, label)
+		}
+		if err := expStore.AddChange(tc, user); err != nil {
+			httputils.ReportError(w, r, err, "Failed to store the updated expectations.")
+			return
+		}
+		sklog.Infof("Bulk triage by %s: %d digests labeled as %s", user, len(digests), label)
+	}
+
+	sendJsonResponse(w, &BulkTriageResponse{
+		DryRun:  dryRun,
+		Label:   label.String(),
+		Digests: digests,
+		Total:   len(digests),
+		Token:   newToken,
+	})
+}
+
 // JsonStatusHandler returns the current status of with respect to HEAD.
 func (wh *WebHandlers) JsonStatusHandler(w http.ResponseWriter, r *http.Request) {
 	sendJsonResponse(w, wh.StatusWatcher.GetStatus())
@@ -716,6 +1008,8 @@ type ClusterDiffResult struct {
 //  unt     - If true include tests that have untriaged digests. (true, false)
 //  pos     - If true include tests that have positive digests. (true, false)
 //  neg     - If true include tests that have negative digests. (true, false)
+//  ftraceclass - If set only include tests that have traces of this class,
+//                e.g. 'flaky'. See the traceclass package.
 //
 // The return format looks like:
 //
@@ -724,7 +1018,8 @@ type ClusterDiffResult struct {
 //      "name": "01-original",
 //      "diameter": 123242,
 //      "untriaged": 2,
-//      "num": 2
+//      "num": 2,
+//      "traceClasses": {"flaky": 1}
 //    },
 //    ...
 //  ]
@@ -779,6 +1074,9 @@ func (wh *WebHandlers) JsonListTestsHandler(w http.ResponseWriter, r *http.Reque
 
 // includeSummary returns true if the given summary matches the query flags.
 func includeSummary(s *summary.Summary, q *search.Query) bool {
+	if (q.FTraceClass != "") && (s.TraceClasses[traceclass.Class(q.FTraceClass)] == 0) {
+		return false
+	}
 	return ((s.Pos > 0) && (q.Pos)) ||
 		((s.Neg > 0) && (q.Neg)) ||
 		((s.Untriaged > 0) && (q.Unt))
@@ -861,7 +1159,8 @@ func (wh *WebHandlers) purgeDigests(w http.ResponseWriter, r *http.Request) bool
 }
 
 // JsonTriageLogHandler returns the entries in the triagelog paginated
-// in reverse chronological order.
+// in reverse chronological order. The 'issue' and 'branch' parameters select
+// the triagelog of a Gerrit issue or an expectations branch instead of master.
 func (wh *WebHandlers) JsonTriageLogHandler(w http.ResponseWriter, r *http.Request) {
 	// Get the pagination params.
 	var logEntries []*expstorage.TriageLogEntry
@@ -878,12 +1177,10 @@ func (wh *WebHandlers) JsonTriageLogHandler(w http.ResponseWriter, r *http.Reque
 		}
 
 		details := q.Get("details") == "true"
-		expStore := wh.Storages.ExpectationsStore
-		if issue > 0 {
-			expStore = wh.Storages.IssueExpStoreFactory(issue)
+		var expStore expstorage.ExpectationsStore
+		if expStore, err = wh.getExpStore(issue, q.Get("branch")); err == nil {
+			logEntries, total, err = expStore.QueryLog(offset, size, details)
 		}
-
-		logEntries, total, err = expStore.QueryLog(offset, size, details)
 	}
 
 	if err != nil {
@@ -903,7 +1200,9 @@ func (wh *WebHandlers) JsonTriageLogHandler(w http.ResponseWriter, r *http.Reque
 // JsonTriageUndoHandler performs an "undo" for a given change id.
 // The change id's are returned in the result of jsonTriageLogHandler.
 // It accepts one query parameter 'id' which is the id if the change
-// that should be reversed.
+// that should be reversed. Like jsonTriageLogHandler it accepts the 'issue'
+// and 'branch' parameters to undo a change of a Gerrit issue or an
+// expectations branch. Undoing the merge of a branch restores the branch.
 // If successful it returns the same result as a call to jsonTriageLogHandler
 // to reflect the changed triagelog.
 func (wh *WebHandlers) JsonTriageUndoHandler(w http.ResponseWriter, r *http.Request) {
@@ -915,14 +1214,32 @@ func (wh *WebHandlers) JsonTriageUndoHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Extract the id to undo.
-	changeID, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	q := r.URL.Query()
+	changeID, err := strconv.ParseInt(q.Get("id"), 10, 64)
 	if err != nil {
 		httputils.ReportError(w, r, err, "Invalid change id.")
 		return
 	}
 
+	validate := search.Validation{}
+	issue := validate.Int64Value("issue", q.Get("issue"), 0)
+	if err := validate.Errors(); err != nil {
+		httputils.ReportError(w, r, err, "Invalid issue.")
+		return
+	}
+	branch := q.Get("branch")
+	expStore, err := wh.getExpStore(issue, branch)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid expectations branch.")
+		return
+	}
+
 	// Do the undo procedure.
-	_, err = wh.Storages.ExpectationsStore.UndoChange(changeID, user)
+	if (issue > 0) || (branch != "") {
+		_, err = expStore.UndoChange(changeID, user)
+	} else {
+		_, err = expstorage.UndoChange(expStore, wh.Storages.GetBranchExpStore, changeID, user)
+	}
 	if err != nil {
 		httputils.ReportError(w, r, err, "Unable to undo.")
 		return
@@ -1012,10 +1329,15 @@ func (wh *WebHandlers) JsonCompareTestHandler(w http.ResponseWriter, r *http.Req
 // baselines for a options issue. It can respond to requests like these:
 //    /json/baseline
 //    /json/baseline/64789
-// where the latter contains the issue id for which we would like to retrieve
+//    /json/baseline?branch=release-m71&corpus=gm
+// where the second contains the issue id for which we would like to retrieve
 // the baseline. In that case the returned options will be blend of the master
 // baseline and the baseline defined for the issue (usually based on tryjob
 // results).
+// The 'branch' parameter selects an expectations branch instead of master and
+// the 'corpus' parameter restricts the baseline to the tests of a corpus. Both
+// are calculated from the current tile and are therefore only available if
+// the server maintains an index.
 func (wh *WebHandlers) JsonBaselineHandler(w http.ResponseWriter, r *http.Request) {
 	issueID := int64(0)
 	var err error
@@ -1028,13 +1350,89 @@ func (wh *WebHandlers) JsonBaselineHandler(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	baseline, err := wh.Storages.FetchBaseline(issueID)
+	branch := r.FormValue("branch")
+	corpus := r.FormValue("corpus")
+	if ((branch != "") || (corpus != "")) && (wh.Indexer == nil) {
+		httputils.ReportError(w, r, fmt.Errorf("No index available."), "Branch and corpus baselines are not supported by this server.")
+		return
+	}
+	if (branch != "") && (issueID > 0) {
+		httputils.ReportError(w, r, fmt.Errorf("Received issue %d and branch %s.", issueID, branch), "Baselines for issues are always based on master.")
+		return
+	}
+
+	var baseLine *baseline.CommitableBaseLine
+	if branch != "" {
+		baseLine, err = wh.Storages.CalcBranchBaseline(branch, wh.Indexer.GetIndex().GetTile(false))
+	} else {
+		baseLine, err = wh.Storages.FetchBaseline(issueID)
+	}
 	if err != nil {
 		httputils.ReportError(w, r, err, "Fetching baselines failed.")
 		return
 	}
 
-	sendJsonResponse(w, baseline)
+	if corpus != "" {
+		baseLine = baseline.FilterByCorpus(baseLine, wh.Indexer.GetIndex().GetTile(true), corpus)
+	}
+	sendJsonResponse(w, baseLine)
+}
+
+// BranchInfo describes an expectations branch.
+type BranchInfo struct {
+	Name         string `json:"name"`
+	NumOverrides int    `json:"numOverrides"` // Number of test/digest pairs that differ from master.
+}
+
+// JsonBranchesHandler returns the list of expectations branches and the number
+// of expectations they override.
+func (wh *WebHandlers) JsonBranchesHandler(w http.ResponseWriter, r *http.Request) {
+	ret := make([]*BranchInfo, 0, len(wh.Storages.ExpBranches))
+	for _, branch := range wh.Storages.ExpBranches {
+		branchStore, err := wh.Storages.GetBranchExpStore(branch)
+		if err != nil {
+			httputils.ReportError(w, r, err, "Unable to get expectations branch.")
+			return
+		}
+		exp, err := branchStore.Get()
+		if err != nil {
+			httputils.ReportError(w, r, err, "Unable to load branch expectations.")
+			return
+		}
+
+		numOverrides := 0
+		for _, digests := range exp.TestExp() {
+			numOverrides += len(digests)
+		}
+		ret = append(ret, &BranchInfo{Name: branch, NumOverrides: numOverrides})
+	}
+	sendJsonResponse(w, ret)
+}
+
+// JsonBranchMergeHandler promotes the triage decisions of the expectations
+// branch identified by 'branch' to master and resets the branch. The merge is
+// a single change in the triagelog of master. It returns the changes that were
+// added to the master expectations.
+func (wh *WebHandlers) JsonBranchMergeHandler(w http.ResponseWriter, r *http.Request) {
+	user := login.LoggedInAs(r)
+	if user == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to merge an expectations branch.")
+		return
+	}
+
+	branch := mux.Vars(r)["branch"]
+	branchStore, err := wh.Storages.GetBranchExpStore(branch)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid expectations branch.")
+		return
+	}
+
+	changes, err := expstorage.MergeBranch(branch, wh.Storages.ExpectationsStore, branchStore, user)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to merge expectations branch.")
+		return
+	}
+	sendJsonResponse(w, changes)
 }
 
 // JsonRefreshIssue forces a refresh of a Gerrit issue, i.e. reload data that
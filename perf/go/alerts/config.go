@@ -5,6 +5,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"text/template"
 
 	"go.skia.org/infra/go/paramtools"
 	"go.skia.org/infra/perf/go/types"
@@ -24,8 +25,13 @@ type Config struct {
 	ID             int64             `json:"id"               datastore:",noindex"`
 	DisplayName    string            `json:"display_name"     datastore:",noindex"`
 	Query          string            `json:"query"            datastore:",noindex"` // The query to perform on the trace store to select the traces to alert on.
-	Alert          string            `json:"alert"            datastore:",noindex"` // Email address or id of a chat room to send alerts to.
+	Alert          string            `json:"alert"            datastore:",noindex"` // Email address or id of a chat room to send alerts to. The meaning depends on Transport.
+	Transport      Transport         `json:"transport"        datastore:",noindex"` // How notifications are delivered. The empty string means EMAIL_TRANSPORT.
+	Template       string            `json:"template"         datastore:",noindex"` // Template for the body of notifications. The empty string means use the default template for the Transport.
 	Interesting    float32           `json:"interesting"      datastore:",noindex"` // The regression interestingness threshold.
+	Significance   float32           `json:"significance"     datastore:",noindex"` // The p-value threshold used by the statistical test algorithms, e.g. types.MANNWHITNEYU_ALGO. 0 means use the server default.
+	Band           float32           `json:"band"             datastore:",noindex"` // How many multiples of the baseline spread a trace must move to be a regression, used by the baseline algorithms, e.g. types.MEDIANMAD_ALGO. 0 means use the server default.
+	BaselineWindow int               `json:"baseline_window"  datastore:",noindex"` // How many commits of history are used to build the baseline for the baseline algorithms. 0 means use the server default.
 	BugURITemplate string            `json:"bug_uri_template" datastore:",noindex"` // URI Template used for reporting bugs. Format TBD.
 	Algo           types.ClusterAlgo `json:"algo"             datastore:",noindex"` // Which clustering algorithm to use.
 	State          ConfigState       `json:"state"`                                 // The state of the config.
@@ -204,6 +210,30 @@ func (c *Config) Validate() error {
 			}
 		}
 	}
+	transport, err := ToTransport(string(c.Transport))
+	if err != nil {
+		return fmt.Errorf("Invalid Config: %s", err)
+	}
+	c.Transport = transport
+	if c.Transport == WEBHOOK_TRANSPORT {
+		if err := ValidateWebhookURL(c.Alert); err != nil {
+			return fmt.Errorf("Invalid Config: %s", err)
+		}
+	}
+	if c.Template != "" {
+		if _, err := template.New("").Parse(c.Template); err != nil {
+			return fmt.Errorf("Invalid Config: Invalid Template: %s", err)
+		}
+	}
+	if c.Significance < 0 || c.Significance >= 1 {
+		return fmt.Errorf("Invalid Config: Significance must be in [0, 1): %f", c.Significance)
+	}
+	if c.Band < 0 {
+		return fmt.Errorf("Invalid Config: Band must be >= 0: %f", c.Band)
+	}
+	if c.BaselineWindow < 0 {
+		return fmt.Errorf("Invalid Config: BaselineWindow must be >= 0: %d", c.BaselineWindow)
+	}
 	if c.StepUpOnly {
 		c.StepUpOnly = false
 		c.Direction = UP
@@ -214,9 +244,10 @@ func (c *Config) Validate() error {
 // NewConfig creates a new Config properly initialized.
 func NewConfig() *Config {
 	return &Config{
-		ID:     INVALID_ID,
-		Algo:   types.KMEANS_ALGO,
-		State:  ACTIVE,
-		Sparse: DefaultSparse,
+		ID:        INVALID_ID,
+		Algo:      types.KMEANS_ALGO,
+		State:     ACTIVE,
+		Sparse:    DefaultSparse,
+		Transport: EMAIL_TRANSPORT,
 	}
 }
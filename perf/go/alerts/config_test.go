@@ -45,6 +45,82 @@ func TestValidate(t *testing.T) {
 	a.GroupBy = "foo"
 	a.Query = "bar=baz&foo=quux"
 	assert.Error(t, a.Validate())
+
+	a = NewConfig()
+	a.Significance = 0.01
+	assert.NoError(t, a.Validate())
+	a.Significance = 1
+	assert.Error(t, a.Validate())
+	a.Significance = -0.5
+	assert.Error(t, a.Validate())
+
+	a = NewConfig()
+	a.Band = 2.5
+	a.BaselineWindow = 200
+	assert.NoError(t, a.Validate())
+	a.Band = -1
+	assert.Error(t, a.Validate())
+	a.Band = 0
+	a.BaselineWindow = -1
+	assert.Error(t, a.Validate())
+
+	a = NewConfig()
+	a.Transport = ""
+	assert.NoError(t, a.Validate())
+	assert.Equal(t, EMAIL_TRANSPORT, a.Transport)
+	a.Transport = "carrier-pigeon"
+	assert.Error(t, a.Validate())
+	a.Transport = CHAT_TRANSPORT
+	a.Template = "Regression at {{.Commit.Hash}}"
+	assert.NoError(t, a.Validate())
+	a.Template = "Regression at {{.Commit.Hash"
+	assert.Error(t, a.Validate())
+
+	// Webhooks can't point at local or private hosts.
+	a = NewConfig()
+	a.Transport = WEBHOOK_TRANSPORT
+	a.Alert = "https://example.com/hook"
+	assert.NoError(t, a.Validate())
+	a.Alert = "http://169.254.169.254/computeMetadata/v1/"
+	assert.Error(t, a.Validate())
+	a.Alert = "http://localhost:8000/"
+	assert.Error(t, a.Validate())
+	a.Alert = "file:///etc/passwd"
+	assert.Error(t, a.Validate())
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	testutils.SmallTest(t)
+
+	for _, u := range []string{
+		"https://example.com/hook",
+		"http://example.com:8080/hook?x=y",
+		"https://8.8.8.8/",
+		"https://[2001:4860:4860::8888]/",
+	} {
+		assert.NoError(t, ValidateWebhookURL(u), u)
+	}
+	for _, u := range []string{
+		"",
+		"example.com/hook",
+		"ftp://example.com/",
+		"https:///hook",
+		"http://localhost/",
+		"http://LOCALHOST./",
+		"http://metadata.localhost/",
+		"http://127.0.0.1:8000/",
+		"http://0.0.0.0/",
+		"http://10.1.2.3/",
+		"http://172.16.0.1/",
+		"http://192.168.1.1/",
+		"http://169.254.169.254/",
+		"http://[::1]/",
+		"http://[fe80::1]/",
+		"http://[fd00::1]/",
+		"http://[::ffff:127.0.0.1]/",
+	} {
+		assert.Error(t, ValidateWebhookURL(u), u)
+	}
 }
 
 func TestGroupedBy(t *testing.T) {
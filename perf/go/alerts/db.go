@@ -25,37 +25,119 @@ func NewStore() *Store {
 }
 
 // Save can write a new, or update an existing, Config. New
-// Config's will have an ID of -1.
-func (s *Store) Save(cfg *Config) error {
+// Config's will have an ID of -1, and will have their ID set once saved.
+//
+// Every Save is recorded as a Revision made by 'author', which is returned.
+func (s *Store) Save(cfg *Config, author string) (*Revision, error) {
+	action := UPDATE_ACTION
+	if cfg.ID == INVALID_ID {
+		action = CREATE_ACTION
+	}
+	return s.save(cfg, author, action, 0)
+}
+
+// save writes the Config and a Revision recording the change. 'restored' is
+// the id of the Revision being restored for RESTORE_ACTION.
+func (s *Store) save(cfg *Config, author string, action RevisionAction, restored int64) (*Revision, error) {
 	if err := cfg.Validate(); err != nil {
-		return fmt.Errorf("Failed to save invalid Config: %s", err)
+		return nil, fmt.Errorf("Failed to save invalid Config: %s", err)
 	}
-	key := ds.NewKey(ds.ALERT)
-	if cfg.ID != INVALID_ID {
-		key.ID = int64(cfg.ID)
+	// New Config's get their ID up front, so that they are written along with
+	// their Revision in a single transaction, as updates are.
+	isNew := cfg.ID == INVALID_ID
+	if isNew {
+		keys, err := ds.DS.AllocateIDs(context.TODO(), []*datastore.Key{ds.NewKey(ds.ALERT)})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to allocate an ID: %s", err)
+		}
+		cfg.ID = keys[0].ID
 	}
-	if _, err := ds.DS.Put(context.TODO(), key, cfg); err != nil {
-		return fmt.Errorf("Failed to write to database: %s", err)
+
+	key := ds.NewKey(ds.ALERT)
+	key.ID = cfg.ID
+	var rev *Revision
+	var pending *datastore.PendingKey
+	commit, err := ds.DS.RunInTransaction(context.TODO(), func(tx *datastore.Transaction) error {
+		var old *Config
+		prev := NewConfig()
+		if err := tx.Get(key, prev); err == nil {
+			prev.ID = cfg.ID
+			old = prev
+		} else if err != datastore.ErrNoSuchEntity {
+			return fmt.Errorf("Failed to retrieve from datastore: %s", err)
+		}
+		var err error
+		rev, err = newRevision(old, cfg, author, action)
+		if err != nil {
+			return err
+		}
+		rev.Restored = restored
+		if _, err := tx.Put(key, cfg); err != nil {
+			return fmt.Errorf("Failed to write to database: %s", err)
+		}
+		pending, err = tx.Put(ds.NewKey(ds.ALERT_REVISION), rev)
+		if err != nil {
+			return fmt.Errorf("Failed to write revision: %s", err)
+		}
+		return nil
+	})
+	if err != nil {
+		if isNew {
+			cfg.ID = INVALID_ID
+		}
+		return nil, err
 	}
-	return nil
+	rev.ID = commit.Key(pending).ID
+	return rev, nil
 }
 
-func (s *Store) Delete(id int) error {
+// Delete marks the Config as DELETED, recording the change as a Revision
+// made by 'author', which is returned.
+func (s *Store) Delete(id int, author string) (*Revision, error) {
 	key := ds.NewKey(ds.ALERT)
 	key.ID = int64(id)
 
-	_, err := ds.DS.RunInTransaction(context.TODO(), func(tx *datastore.Transaction) error {
+	var rev *Revision
+	var pending *datastore.PendingKey
+	commit, err := ds.DS.RunInTransaction(context.TODO(), func(tx *datastore.Transaction) error {
 		cfg := NewConfig()
 		if err := tx.Get(key, cfg); err != nil {
 			return fmt.Errorf("Failed to retrieve from datastore: %s", err)
 		}
+		cfg.ID = int64(id)
+		old := *cfg
 		cfg.State = DELETED
+		var err error
+		rev, err = newRevision(&old, cfg, author, DELETE_ACTION)
+		if err != nil {
+			return err
+		}
 		if _, err := tx.Put(key, cfg); err != nil {
 			return fmt.Errorf("Failed to write to database: %s", err)
 		}
+		pending, err = tx.Put(ds.NewKey(ds.ALERT_REVISION), rev)
+		if err != nil {
+			return fmt.Errorf("Failed to write revision: %s", err)
+		}
 		return nil
 	})
-	return err
+	if err != nil {
+		return nil, err
+	}
+	rev.ID = commit.Key(pending).ID
+	return rev, nil
+}
+
+// Get returns the Config with the given id.
+func (s *Store) Get(id int64) (*Config, error) {
+	key := ds.NewKey(ds.ALERT)
+	key.ID = id
+	cfg := NewConfig()
+	if err := ds.DS.Get(context.TODO(), key, cfg); err != nil {
+		return nil, fmt.Errorf("Failed to retrieve alert %d: %s", id, err)
+	}
+	cfg.ID = id
+	return cfg, nil
 }
 
 // ConfigSlice is a utility type for sorting Configs by DisplayName.
@@ -14,7 +14,7 @@ func TestDS(t *testing.T) {
 	testutils.LargeTest(t)
 	testutils.LocalOnlyTest(t)
 
-	cleanup := testutil.InitDatastore(t, ds.ALERT)
+	cleanup := testutil.InitDatastore(t, ds.ALERT, ds.ALERT_REVISION)
 	defer cleanup()
 
 	// Test saving one alert.
@@ -22,7 +22,7 @@ func TestDS(t *testing.T) {
 	cfg := NewConfig()
 	cfg.Query = "source_type=svg"
 	cfg.DisplayName = "bar"
-	err := a.Save(cfg)
+	_, err := a.Save(cfg, "user@example.com")
 	assert.NoError(t, err)
 
 	// Confirm it appears in the list.
@@ -31,7 +31,7 @@ func TestDS(t *testing.T) {
 	assert.Len(t, cfgs, 1)
 
 	// Delete it.
-	err = a.Delete(int(cfgs[0].ID))
+	_, err = a.Delete(int(cfgs[0].ID), "user@example.com")
 	assert.NoError(t, err)
 
 	// Confirm it is still there if we list deleted configs.
@@ -48,7 +48,7 @@ func TestDS(t *testing.T) {
 	cfg = NewConfig()
 	cfg.Query = "source_type=skp"
 	cfg.DisplayName = "foo"
-	err = a.Save(cfg)
+	_, err = a.Save(cfg, "user@example.com")
 	assert.NoError(t, err)
 
 	time.Sleep(1)
@@ -60,3 +60,49 @@ func TestDS(t *testing.T) {
 	assert.Equal(t, "bar", cfgs[0].DisplayName)
 	assert.Equal(t, "foo", cfgs[1].DisplayName)
 }
+
+func TestDSRevisions(t *testing.T) {
+	testutils.LargeTest(t)
+	testutils.LocalOnlyTest(t)
+
+	cleanup := testutil.InitDatastore(t, ds.ALERT, ds.ALERT_REVISION)
+	defer cleanup()
+
+	a := NewStore()
+	cfg := NewConfig()
+	cfg.Query = "source_type=svg"
+	cfg.Interesting = 50
+	created, err := a.Save(cfg, "alice@example.com")
+	assert.NoError(t, err)
+	assert.NotEqual(t, int64(INVALID_ID), cfg.ID)
+	assert.Equal(t, CREATE_ACTION, created.Action)
+	id := cfg.ID
+
+	time.Sleep(time.Second)
+	cfg.Query = "source_type=skp"
+	updated, err := a.Save(cfg, "bob@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, UPDATE_ACTION, updated.Action)
+	assert.Equal(t, []FieldChange{{Field: "query", Old: "source_type=svg", New: "source_type=skp"}}, updated.Changes)
+
+	time.Sleep(time.Second)
+	revs, err := a.Revisions(id, 10)
+	assert.NoError(t, err)
+	assert.Len(t, revs, 2)
+	assert.Equal(t, "bob@example.com", revs[0].Author)
+	assert.Equal(t, "alice@example.com", revs[1].Author)
+
+	// Restore the original query.
+	restored, err := a.Restore(id, revs[1].ID, "carol@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, RESTORE_ACTION, restored.Action)
+	assert.Equal(t, revs[1].ID, restored.Restored)
+	got, err := a.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "source_type=svg", got.Query)
+	assert.Equal(t, float32(50), got.Interesting)
+
+	// Restoring a revision of another alert fails.
+	_, err = a.Restore(id+1, revs[1].ID, "carol@example.com")
+	assert.Error(t, err)
+}
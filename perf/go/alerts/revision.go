@@ -0,0 +1,149 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.skia.org/infra/go/ds"
+	"google.golang.org/api/iterator"
+)
+
+// RevisionAction is the kind of change recorded in a Revision.
+type RevisionAction string
+
+// RevisionAction constants.
+const (
+	CREATE_ACTION  RevisionAction = "create"
+	UPDATE_ACTION  RevisionAction = "update"
+	DELETE_ACTION  RevisionAction = "delete"
+	RESTORE_ACTION RevisionAction = "restore"
+)
+
+// FieldChange records the change of a single field of a Config.
+type FieldChange struct {
+	Field string `json:"field"` // The JSON name of the field, e.g. "query".
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Revision records a single change to a Config.
+type Revision struct {
+	ID       int64          `json:"id"       datastore:"-"`
+	AlertID  int64          `json:"alert_id"`
+	TS       int64          `json:"ts"`                            // When the change was made, in seconds from the Unix epoch.
+	Author   string         `json:"author"   datastore:",noindex"` // Email address of who made the change.
+	Action   RevisionAction `json:"action"   datastore:",noindex"`
+	Changes  []FieldChange  `json:"changes"  datastore:",noindex"` // The fields that changed from the previous revision.
+	Config   string         `json:"-"        datastore:",noindex"` // The JSON serialized Config after the change.
+	Restored int64          `json:"restored" datastore:",noindex"` // The ID of the Revision that was restored for RESTORE_ACTION.
+}
+
+// newRevision returns a Revision that records the change from 'old' to 'cfg'.
+// 'old' is nil if the Config is new.
+func newRevision(old, cfg *Config, author string, action RevisionAction) (*Revision, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode Config: %s", err)
+	}
+	return &Revision{
+		AlertID: cfg.ID,
+		TS:      time.Now().Unix(),
+		Author:  author,
+		Action:  action,
+		Changes: Diff(old, cfg),
+		Config:  string(b),
+	}, nil
+}
+
+// GetConfig returns the Config as it was after this Revision was made.
+func (r *Revision) GetConfig() (*Config, error) {
+	cfg := NewConfig()
+	if err := json.Unmarshal([]byte(r.Config), cfg); err != nil {
+		return nil, fmt.Errorf("Failed to decode Config for revision %d: %s", r.ID, err)
+	}
+	cfg.ID = r.AlertID
+	return cfg, nil
+}
+
+// Summary returns a human readable description of the Revision, suitable for
+// the activity log.
+func (r *Revision) Summary() string {
+	parts := make([]string, 0, len(r.Changes))
+	for _, c := range r.Changes {
+		parts = append(parts, fmt.Sprintf("%s: %q -> %q", c.Field, c.Old, c.New))
+	}
+	return fmt.Sprintf("%s alert %d: %s", strings.Title(string(r.Action)), r.AlertID, strings.Join(parts, ", "))
+}
+
+// Diff returns the fields that differ between 'old' and 'cfg', in the order
+// they appear in Config. If 'old' is nil then all the non-empty fields of
+// 'cfg' are returned. The ID is never included.
+func Diff(old, cfg *Config) []FieldChange {
+	ret := []FieldChange{}
+	oldValue := reflect.ValueOf(Config{})
+	if old != nil {
+		oldValue = reflect.ValueOf(*old)
+	}
+	newValue := reflect.ValueOf(*cfg)
+	t := newValue.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "id" {
+			continue
+		}
+		o := fmt.Sprintf("%v", oldValue.Field(i).Interface())
+		n := fmt.Sprintf("%v", newValue.Field(i).Interface())
+		if o != n {
+			ret = append(ret, FieldChange{
+				Field: name,
+				Old:   o,
+				New:   n,
+			})
+		}
+	}
+	return ret
+}
+
+// Revisions returns the most recent 'n' Revisions for the given alert,
+// newest first.
+func (s *Store) Revisions(alertID int64, n int) ([]*Revision, error) {
+	ret := []*Revision{}
+	q := ds.NewQuery(ds.ALERT_REVISION).Filter("AlertID =", alertID).Order("-TS").Limit(n)
+	it := ds.DS.Run(context.TODO(), q)
+	for {
+		rev := &Revision{}
+		k, err := it.Next(rev)
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Failed to read alert revisions: %s", err)
+		}
+		rev.ID = k.ID
+		ret = append(ret, rev)
+	}
+	return ret, nil
+}
+
+// Restore makes the Config that was saved in the given Revision the current
+// Config for the alert, and records that as a new Revision, which is returned.
+func (s *Store) Restore(alertID, revisionID int64, author string) (*Revision, error) {
+	key := ds.NewKey(ds.ALERT_REVISION)
+	key.ID = revisionID
+	rev := &Revision{}
+	if err := ds.DS.Get(context.TODO(), key, rev); err != nil {
+		return nil, fmt.Errorf("Failed to load revision %d: %s", revisionID, err)
+	}
+	rev.ID = revisionID
+	if rev.AlertID != alertID {
+		return nil, fmt.Errorf("Revision %d is not a revision of alert %d.", revisionID, alertID)
+	}
+	cfg, err := rev.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return s.save(cfg, author, RESTORE_ACTION, revisionID)
+}
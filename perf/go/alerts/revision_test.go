@@ -0,0 +1,54 @@
+package alerts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.skia.org/infra/go/testutils"
+)
+
+func TestDiff(t *testing.T) {
+	testutils.SmallTest(t)
+
+	old := NewConfig()
+	old.ID = 12
+	old.Query = "config=8888"
+	old.Interesting = 50
+
+	cfg := *old
+	cfg.Query = "config=565"
+	cfg.Interesting = 25
+	cfg.Owner = "alice@example.com"
+	assert.Equal(t, []FieldChange{
+		{Field: "query", Old: "config=8888", New: "config=565"},
+		{Field: "interesting", Old: "50", New: "25"},
+		{Field: "owner", Old: "", New: "alice@example.com"},
+	}, Diff(old, &cfg))
+
+	assert.Empty(t, Diff(old, old))
+
+	// A new Config reports all the non-empty fields, but not the id.
+	changes := Diff(nil, old)
+	for _, c := range changes {
+		assert.NotEqual(t, "id", c.Field)
+	}
+	assert.Contains(t, changes, FieldChange{Field: "query", Old: "", New: "config=8888"})
+}
+
+func TestRevisionConfigRoundTrip(t *testing.T) {
+	testutils.SmallTest(t)
+
+	cfg := NewConfig()
+	cfg.ID = 12
+	cfg.Query = "config=8888"
+	rev, err := newRevision(nil, cfg, "alice@example.com", CREATE_ACTION)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), rev.AlertID)
+
+	got, err := rev.GetConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, cfg, got)
+
+	rev.Changes = []FieldChange{{Field: "query", Old: "", New: "config=8888"}}
+	assert.Equal(t, `Create alert 12: query: "" -> "config=8888"`, rev.Summary())
+}
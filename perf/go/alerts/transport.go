@@ -0,0 +1,100 @@
+package alerts
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Transport is the name of the mechanism used to deliver the notifications for
+// an alert. See perf/go/notify for the implementations.
+type Transport string
+
+// Transport constants.
+//
+// Update alert-config-sk if this enum is changed.
+const (
+	EMAIL_TRANSPORT   Transport = "email"   // Config.Alert is a comma separated list of email addresses.
+	CHAT_TRANSPORT    Transport = "chat"    // Config.Alert is the name of a chat room.
+	WEBHOOK_TRANSPORT Transport = "webhook" // Config.Alert is the URL that a JSON message will be POST'd to.
+	ISSUE_TRANSPORT   Transport = "issue"   // Config.Alert is a comma separated list of email addresses to CC on a filed bug.
+)
+
+var (
+	AllTransports = []Transport{EMAIL_TRANSPORT, CHAT_TRANSPORT, WEBHOOK_TRANSPORT, ISSUE_TRANSPORT}
+
+	// privateNetworks are the address ranges, in addition to loopback and
+	// link-local addresses, that webhooks must not be sent to.
+	privateNetworks = mustParseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	ret := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		ret = append(ret, n)
+	}
+	return ret
+}
+
+// ToTransport converts a string to a Transport, returning an error if the
+// string isn't a valid Transport. The empty string is treated as
+// EMAIL_TRANSPORT, which was the only transport before Transport existed.
+func ToTransport(s string) (Transport, error) {
+	if s == "" {
+		return EMAIL_TRANSPORT, nil
+	}
+	ret := Transport(s)
+	for _, t := range AllTransports {
+		if t == ret {
+			return ret, nil
+		}
+	}
+	return ret, fmt.Errorf("%q is not a valid Transport, must be a value in %v", s, AllTransports)
+}
+
+// ValidWebhookIP returns true if webhook notifications may be sent to the
+// given IP address, i.e. if it isn't a loopback, private, link-local,
+// multicast or unspecified address. This keeps webhooks from reaching
+// internal services, e.g. the metadata server.
+func ValidWebhookIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateWebhookURL returns an error if the given URL can't be used as the
+// destination of webhook notifications. It must be an http or https URL whose
+// host isn't localhost or an IP address rejected by ValidWebhookIP. Host names
+// aren't resolved here, the webhook Transport checks the addresses it
+// connects to.
+func ValidateWebhookURL(webhookURL string) error {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return fmt.Errorf("Invalid webhook URL: %s", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("Invalid webhook URL %q: must be an http or https URL.", webhookURL)
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return fmt.Errorf("Invalid webhook URL %q: missing host.", webhookURL)
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("Invalid webhook URL %q: local hosts are not allowed.", webhookURL)
+	}
+	if ip := net.ParseIP(host); ip != nil && !ValidWebhookIP(ip) {
+		return fmt.Errorf("Invalid webhook URL %q: private and local addresses are not allowed.", webhookURL)
+	}
+	return nil
+}
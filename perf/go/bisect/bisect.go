@@ -0,0 +1,94 @@
+// Package bisect publishes requests to fill in the data for the commits that
+// could have caused a regression.
+//
+// When an alerts.Config is Sparse, or the bots skip commits, a regression can
+// only be narrowed down to a range of commits. A Request describes that range
+// so that other tooling, such as the task scheduler, can run the missing
+// commits and narrow it down to a single commit.
+package bisect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"cloud.google.com/go/pubsub"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/perf/go/cid"
+)
+
+// Request is a request to fill in the data for the commits between Begin and
+// End. It is serialized as JSON when published.
+type Request struct {
+	AlertID     int64               `json:"alert_id"`
+	Query       string              `json:"query"`        // The query of the alert that found the regression.
+	ClusterType string              `json:"cluster_type"` // "low" or "high", see regression.LOW_CLUSTER.
+	Shortcut    string              `json:"shortcut"`     // The id of a shortcut to the traces in the regression.
+	Begin       *cid.CommitDetail   `json:"begin"`        // The last commit with data before the regression.
+	End         *cid.CommitDetail   `json:"end"`          // The commit the regression was detected at.
+	Missing     []*cid.CommitDetail `json:"missing"`      // The commits between Begin and End that have no data.
+}
+
+// Publisher publishes bisect Requests.
+type Publisher interface {
+	Publish(ctx context.Context, req *Request) error
+}
+
+// webhookPublisher implements Publisher by POSTing JSON to a URL.
+type webhookPublisher struct {
+	client *http.Client
+	url    string
+}
+
+// NewWebhookPublisher returns a Publisher that POSTs each Request serialized
+// as JSON to the given URL.
+func NewWebhookPublisher(client *http.Client, url string) Publisher {
+	return &webhookPublisher{
+		client: client,
+		url:    url,
+	}
+}
+
+// See Publisher.
+func (w *webhookPublisher) Publish(ctx context.Context, req *Request) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("Failed to encode bisect request: %s", err)
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("Failed to send bisect request: %s", err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Wrong status code sending bisect request: %d %s", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
+
+// pubsubPublisher implements Publisher by publishing to a PubSub topic.
+type pubsubPublisher struct {
+	topic *pubsub.Topic
+}
+
+// NewPubSubPublisher returns a Publisher that publishes each Request
+// serialized as JSON to the given topic.
+func NewPubSubPublisher(topic *pubsub.Topic) Publisher {
+	return &pubsubPublisher{
+		topic: topic,
+	}
+}
+
+// See Publisher.
+func (p *pubsubPublisher) Publish(ctx context.Context, req *Request) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("Failed to encode bisect request: %s", err)
+	}
+	if _, err := p.topic.Publish(ctx, &pubsub.Message{Data: b}).Get(ctx); err != nil {
+		return fmt.Errorf("Failed to publish bisect request: %s", err)
+	}
+	return nil
+}
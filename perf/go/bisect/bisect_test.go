@@ -0,0 +1,51 @@
+package bisect
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/perf/go/cid"
+)
+
+func TestWebhookPublisher(t *testing.T) {
+	testutils.SmallTest(t)
+
+	var got *Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = &Request{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(got))
+	}))
+	defer ts.Close()
+
+	req := &Request{
+		AlertID:     12,
+		Query:       "config=8888",
+		ClusterType: "low",
+		Begin:       &cid.CommitDetail{CommitID: cid.CommitID{Source: "master", Offset: 10}},
+		End:         &cid.CommitDetail{CommitID: cid.CommitID{Source: "master", Offset: 13}},
+		Missing: []*cid.CommitDetail{
+			{CommitID: cid.CommitID{Source: "master", Offset: 11}},
+			{CommitID: cid.CommitID{Source: "master", Offset: 12}},
+		},
+	}
+	p := NewWebhookPublisher(http.DefaultClient, ts.URL)
+	assert.NoError(t, p.Publish(context.Background(), req))
+	assert.Equal(t, req, got)
+}
+
+func TestWebhookPublisher_Error(t *testing.T) {
+	testutils.SmallTest(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	p := NewWebhookPublisher(http.DefaultClient, ts.URL)
+	assert.Error(t, p.Publish(context.Background(), &Request{}))
+}
@@ -61,6 +61,12 @@ type ClusterSummary struct {
 	// StepPoint is the ColumnHeader for the step point.
 	StepPoint *dataframe.ColumnHeader `json:"step_point"`
 
+	// PrevStepPoint is the ColumnHeader of the last commit before StepPoint
+	// where any of the traces in the cluster have data. If there are commits
+	// between PrevStepPoint and StepPoint then any one of them could have
+	// caused the step. Can be nil.
+	PrevStepPoint *dataframe.ColumnHeader `json:"prev_step_point,omitempty"`
+
 	// Num is the number of observations that are in this cluster.
 	Num int `json:"num"`
 }
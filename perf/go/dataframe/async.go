@@ -514,6 +514,9 @@ func (p *FrameRequestProcess) doCalc(formula string, begin, end time.Time) (*Dat
 	if err != nil {
 		return nil, fmt.Errorf("Calculation failed: %s", err)
 	}
+	if df == nil {
+		return nil, fmt.Errorf("Calculation failed: the formula must contain a filter() or shortcut().")
+	}
 
 	// Convert the Rows from float64 to float32 for DataFrame.
 	ts := types.TraceSet{}
@@ -58,7 +58,11 @@ type DataFrameBuilder interface {
 	// points ending at the given 'end' time for the given keys.
 	NewNFromKeys(ctx context.Context, end time.Time, keys []string, n int32, progress types.Progress) (*DataFrame, error)
 
-	// TODO Add func to count matches.
+	// NumMatches returns an estimate of the number of traces that match the
+	// given query over the time range [begin, end) without loading them. The
+	// estimate is the largest number of matching traces in any one tile.
+	NumMatches(begin, end time.Time, q *query.Query) (int64, error)
+
 	// TODO Add func to get merged paramset for a date range.
 }
 
@@ -16,6 +16,7 @@ import (
 	"go.skia.org/infra/perf/go/btts"
 	"go.skia.org/infra/perf/go/cid"
 	"go.skia.org/infra/perf/go/dataframe"
+	"go.skia.org/infra/perf/go/tracestore"
 	"go.skia.org/infra/perf/go/types"
 	"golang.org/x/sync/errgroup"
 )
@@ -24,13 +25,13 @@ const (
 	NEW_N_FROM_KEY_STEP = 4 * 24 * time.Hour
 )
 
-// builder implements DataFrameBuilder using btts.
+// builder implements DataFrameBuilder using a tracestore.TraceStore.
 type builder struct {
 	vcs   vcsinfo.VCS
-	store *btts.BigTableTraceStore
+	store tracestore.TraceStore
 }
 
-func NewDataFrameBuilderFromBTTS(vcs vcsinfo.VCS, store *btts.BigTableTraceStore) dataframe.DataFrameBuilder {
+func NewDataFrameBuilderFromTraceStore(vcs vcsinfo.VCS, store tracestore.TraceStore) dataframe.DataFrameBuilder {
 	return &builder{
 		vcs:   vcs,
 		store: store,
@@ -105,10 +106,10 @@ func fromTimeRange(vcs vcsinfo.VCS, begin, end time.Time, downsample bool) ([]*d
 // should appear in the resulting Trace.
 type tileMapOffsetToIndex map[btts.TileKey]map[int32]int32
 
-// buildTileMapOffsetToIndex returns a tileMapOffsetToIndex for the given indices and the given BigTableTraceStore.
+// buildTileMapOffsetToIndex returns a tileMapOffsetToIndex for the given indices and the given TraceStore.
 //
 // The returned map is used when loading traces out of tiles.
-func buildTileMapOffsetToIndex(indices []int32, store *btts.BigTableTraceStore) tileMapOffsetToIndex {
+func buildTileMapOffsetToIndex(indices []int32, store tracestore.TraceStore) tileMapOffsetToIndex {
 	ret := tileMapOffsetToIndex{}
 	for targetIndex, sourceIndex := range indices {
 		tileKey := store.TileKey(sourceIndex)
@@ -241,6 +242,45 @@ func (b *builder) NewFromQueryAndRange(begin, end time.Time, q *query.Query, dow
 	return b.new(colHeaders, indices, q, progress, skip)
 }
 
+// See DataFrameBuilder.
+func (b *builder) NumMatches(begin, end time.Time, q *query.Query) (int64, error) {
+	defer timer.New("dfbuilder_num_matches").Stop()
+	_, indices, _ := fromTimeRange(b.vcs, begin, end, false)
+	mapper := buildTileMapOffsetToIndex(indices, b.store)
+
+	var mutex sync.Mutex // mutex protects ret.
+	ret := int64(0)
+	var g errgroup.Group
+	for tileKey := range mapper {
+		tileKey := tileKey
+		g.Go(func() error {
+			ops, err := b.store.GetOrderedParamSet(tileKey)
+			if err != nil {
+				return err
+			}
+			r, err := q.Regexp(ops)
+			if err != nil || (!q.Empty() && r.String() == "") {
+				// Nothing matches in this tile, see new().
+				return nil
+			}
+			count, err := b.store.QueryCount(tileKey, r)
+			if err != nil {
+				return err
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			if count > ret {
+				ret = count
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return -1, fmt.Errorf("Failed while counting: %s", err)
+	}
+	return ret, nil
+}
+
 // See DataFrameBuilder.
 func (b *builder) NewFromKeysAndRange(keys []string, begin, end time.Time, downsample bool, progress types.Progress) (*dataframe.DataFrame, error) {
 	// TODO tickle progress as each Go routine completes.
@@ -18,6 +18,8 @@ import (
 	"go.skia.org/infra/perf/go/btts_testutils"
 	"go.skia.org/infra/perf/go/config"
 	"go.skia.org/infra/perf/go/dataframe"
+	"go.skia.org/infra/perf/go/localts"
+	"go.skia.org/infra/perf/go/tracestore"
 )
 
 var (
@@ -94,7 +96,7 @@ func TestBuildTraceMapper(t *testing.T) {
 }
 
 // The keys of values are structured keys, not encoded keys.
-func addValusAtIndex(store *btts.BigTableTraceStore, index int32, values map[string]float32, filename string, ts time.Time) error {
+func addValusAtIndex(store tracestore.TraceStore, index int32, values map[string]float32, filename string, ts time.Time) error {
 	tileKey := store.TileKey(index)
 	ps := paramtools.ParamSet{}
 	for structuredKey, _ := range values {
@@ -142,6 +144,25 @@ func TestBuildNew(t *testing.T) {
 	// Should not fail on an empty table.
 	store, err := btts.NewBigTableTraceStoreFromConfig(ctx, cfg, &btts_testutils.MockTS{}, false)
 	assert.NoError(t, err)
+	testBuildNew(t, ctx, store)
+}
+
+func TestBuildNewLocal(t *testing.T) {
+	testutils.MediumTest(t)
+	ctx := context.Background()
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	// Should not fail on an empty store.
+	store, err := localts.NewLocalTraceStore(dir, 6)
+	assert.NoError(t, err)
+	defer testutils.AssertCloses(t, store)
+	testBuildNew(t, ctx, store)
+}
+
+// testBuildNew exercises the builder against the given empty TraceStore,
+// which must have a tile size of 6.
+func testBuildNew(t *testing.T, ctx context.Context, store tracestore.TraceStore) {
 	now := time.Now()
 	v := &mockVCS{
 		ret: []*vcsinfo.IndexCommit{
@@ -155,7 +176,7 @@ func TestBuildNew(t *testing.T) {
 			&vcsinfo.IndexCommit{Index: 7, Hash: "823", Timestamp: now},
 		},
 	}
-	builder := NewDataFrameBuilderFromBTTS(v, store)
+	builder := NewDataFrameBuilderFromTraceStore(v, store)
 	df, err := builder.New(nil)
 	assert.NoError(t, err)
 	assert.Len(t, df.TraceSet, 0)
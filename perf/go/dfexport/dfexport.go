@@ -0,0 +1,248 @@
+// Package dfexport writes a dataframe.DataFrame in formats that are easy to
+// load into notebooks and other analysis tools.
+//
+// All the formats are 'long', i.e. there is one row per trace per commit
+// that has a value, and each row contains the trace id, the commit source,
+// offset and timestamp, one column per param, and the value.
+package dfexport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/xitongsys/parquet-go/writer"
+	"go.skia.org/infra/go/query"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/go/vec32"
+	"go.skia.org/infra/perf/go/dataframe"
+)
+
+// Format is the format to export a DataFrame in.
+type Format string
+
+// Format constants.
+const (
+	CSV     Format = "csv"
+	JSONL   Format = "jsonl"
+	PARQUET Format = "parquet"
+)
+
+// AllFormats is a list of all the valid Formats.
+var AllFormats = []Format{CSV, JSONL, PARQUET}
+
+// ToFormat converts a string to a Format, returning an error if the string
+// isn't a valid Format.
+func ToFormat(s string) (Format, error) {
+	for _, f := range AllFormats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("Unknown export format: %q", s)
+}
+
+// ContentType returns the MIME type of the Format.
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv"
+	case JSONL:
+		return "application/x-ndjson"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Names of the columns that appear in every row.
+const (
+	TRACE_ID_COLUMN  = "trace_id"
+	SOURCE_COLUMN    = "source"
+	OFFSET_COLUMN    = "offset"
+	TIMESTAMP_COLUMN = "timestamp"
+	VALUE_COLUMN     = "value"
+
+	// PARAM_PREFIX is prepended to the name of a param column if the param
+	// has the same name as one of the columns above.
+	PARAM_PREFIX = "param_"
+
+	// PARQUET_PARALLELISM is the number of goroutines used to encode Parquet.
+	PARQUET_PARALLELISM = 4
+)
+
+var fixedColumns = []string{TRACE_ID_COLUMN, SOURCE_COLUMN, OFFSET_COLUMN, TIMESTAMP_COLUMN, VALUE_COLUMN}
+
+// Row is a single exported value.
+type Row struct {
+	TraceID   string            `json:"trace_id"`
+	Source    string            `json:"source"`
+	Offset    int64             `json:"offset"`
+	Timestamp int64             `json:"timestamp"` // In seconds from the Unix epoch.
+	Params    map[string]string `json:"params"`
+	Value     float32           `json:"value"`
+}
+
+// trace is a trace from the DataFrame with its params decoded.
+type trace struct {
+	id     string
+	params map[string]string
+	values []float32
+}
+
+// traces returns the traces in the DataFrame sorted by id, along with the
+// sorted list of all the param keys.
+func traces(df *dataframe.DataFrame) ([]*trace, []string, error) {
+	ret := make([]*trace, 0, len(df.TraceSet))
+	keys := util.StringSet{}
+	for id, values := range df.TraceSet {
+		params, err := query.ParseKey(id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to parse trace id %q: %s", id, err)
+		}
+		for k := range params {
+			keys[k] = true
+		}
+		ret = append(ret, &trace{
+			id:     id,
+			params: params,
+			values: values,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].id < ret[j].id })
+	paramKeys := keys.Keys()
+	sort.Strings(paramKeys)
+	return ret, paramKeys, nil
+}
+
+// ColumnName returns the name of the column for the given param key.
+func ColumnName(key string) string {
+	if util.In(key, fixedColumns) {
+		return PARAM_PREFIX + key
+	}
+	return key
+}
+
+// Export writes the DataFrame to 'w' in the given Format.
+//
+// Points that are vec32.MISSING_DATA_SENTINEL are not exported.
+func Export(w io.Writer, df *dataframe.DataFrame, format Format) error {
+	traces, paramKeys, err := traces(df)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case CSV:
+		return exportCSV(w, df.Header, traces, paramKeys)
+	case JSONL:
+		return exportJSONL(w, df.Header, traces)
+	case PARQUET:
+		return exportParquet(w, df.Header, traces, paramKeys)
+	default:
+		return fmt.Errorf("Unknown export format: %q", format)
+	}
+}
+
+// exportCSV writes the traces as CSV with a header row.
+func exportCSV(w io.Writer, header []*dataframe.ColumnHeader, traces []*trace, paramKeys []string) error {
+	cw := csv.NewWriter(w)
+	columns := []string{TRACE_ID_COLUMN, SOURCE_COLUMN, OFFSET_COLUMN, TIMESTAMP_COLUMN}
+	for _, key := range paramKeys {
+		columns = append(columns, ColumnName(key))
+	}
+	columns = append(columns, VALUE_COLUMN)
+	if err := cw.Write(columns); err != nil {
+		return fmt.Errorf("Failed to write CSV header: %s", err)
+	}
+	record := make([]string, len(columns))
+	for _, tr := range traces {
+		record[0] = tr.id
+		for i, key := range paramKeys {
+			record[4+i] = tr.params[key]
+		}
+		for i, h := range header {
+			if tr.values[i] == vec32.MISSING_DATA_SENTINEL {
+				continue
+			}
+			record[1] = h.Source
+			record[2] = strconv.FormatInt(h.Offset, 10)
+			record[3] = strconv.FormatInt(h.Timestamp, 10)
+			record[len(record)-1] = strconv.FormatFloat(float64(tr.values[i]), 'g', -1, 32)
+			if err := cw.Write(record); err != nil {
+				return fmt.Errorf("Failed to write CSV row: %s", err)
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportJSONL writes the traces as one JSON serialized Row per line.
+func exportJSONL(w io.Writer, header []*dataframe.ColumnHeader, traces []*trace) error {
+	enc := json.NewEncoder(w)
+	for _, tr := range traces {
+		for i, h := range header {
+			if tr.values[i] == vec32.MISSING_DATA_SENTINEL {
+				continue
+			}
+			row := &Row{
+				TraceID:   tr.id,
+				Source:    h.Source,
+				Offset:    h.Offset,
+				Timestamp: h.Timestamp,
+				Params:    tr.params,
+				Value:     tr.values[i],
+			}
+			if err := enc.Encode(row); err != nil {
+				return fmt.Errorf("Failed to write JSON row: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// exportParquet writes the traces as a Parquet file. The param columns are
+// optional since not every trace has every param.
+func exportParquet(w io.Writer, header []*dataframe.ColumnHeader, traces []*trace, paramKeys []string) error {
+	md := []string{
+		fmt.Sprintf("name=%s, type=BYTE_ARRAY, convertedtype=UTF8", TRACE_ID_COLUMN),
+		fmt.Sprintf("name=%s, type=BYTE_ARRAY, convertedtype=UTF8", SOURCE_COLUMN),
+		fmt.Sprintf("name=%s, type=INT64", OFFSET_COLUMN),
+		fmt.Sprintf("name=%s, type=INT64", TIMESTAMP_COLUMN),
+	}
+	for _, key := range paramKeys {
+		md = append(md, fmt.Sprintf("name=%s, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL", ColumnName(key)))
+	}
+	md = append(md, fmt.Sprintf("name=%s, type=FLOAT", VALUE_COLUMN))
+
+	pw, err := writer.NewCSVWriterFromWriter(md, w, PARQUET_PARALLELISM)
+	if err != nil {
+		return fmt.Errorf("Failed to create Parquet writer: %s", err)
+	}
+	for _, tr := range traces {
+		for i, h := range header {
+			if tr.values[i] == vec32.MISSING_DATA_SENTINEL {
+				continue
+			}
+			record := make([]interface{}, 0, len(md))
+			record = append(record, tr.id, h.Source, h.Offset, h.Timestamp)
+			for _, key := range paramKeys {
+				if value, ok := tr.params[key]; ok {
+					record = append(record, value)
+				} else {
+					record = append(record, nil)
+				}
+			}
+			record = append(record, tr.values[i])
+			if err := pw.Write(record); err != nil {
+				return fmt.Errorf("Failed to write Parquet row: %s", err)
+			}
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		return fmt.Errorf("Failed to finish Parquet file: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package dfexport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/vec32"
+	"go.skia.org/infra/perf/go/dataframe"
+	"go.skia.org/infra/perf/go/types"
+)
+
+func newTestDataFrame() *dataframe.DataFrame {
+	e := vec32.MISSING_DATA_SENTINEL
+	return &dataframe.DataFrame{
+		Header: []*dataframe.ColumnHeader{
+			{Source: "master", Offset: 10, Timestamp: 1500000000},
+			{Source: "master", Offset: 11, Timestamp: 1500000100},
+		},
+		TraceSet: types.TraceSet{
+			",arch=x86,config=8888,": []float32{1.5, e},
+			",config=565,value=y,":   []float32{2, 3},
+		},
+	}
+}
+
+func TestToFormat(t *testing.T) {
+	testutils.SmallTest(t)
+
+	for _, f := range AllFormats {
+		got, err := ToFormat(string(f))
+		assert.NoError(t, err)
+		assert.Equal(t, f, got)
+	}
+	_, err := ToFormat("xml")
+	assert.Error(t, err)
+}
+
+func TestExportCSV(t *testing.T) {
+	testutils.SmallTest(t)
+
+	var b bytes.Buffer
+	assert.NoError(t, Export(&b, newTestDataFrame(), CSV))
+	expected := `trace_id,source,offset,timestamp,arch,config,param_value,value
+",arch=x86,config=8888,",master,10,1500000000,x86,8888,,1.5
+",config=565,value=y,",master,10,1500000000,,565,y,2
+",config=565,value=y,",master,11,1500000100,,565,y,3
+`
+	assert.Equal(t, expected, b.String())
+}
+
+func TestExportJSONL(t *testing.T) {
+	testutils.SmallTest(t)
+
+	var b bytes.Buffer
+	assert.NoError(t, Export(&b, newTestDataFrame(), JSONL))
+	rows := []*Row{}
+	scanner := bufio.NewScanner(&b)
+	for scanner.Scan() {
+		row := &Row{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), row))
+		rows = append(rows, row)
+	}
+	assert.Len(t, rows, 3)
+	assert.Equal(t, &Row{
+		TraceID:   ",arch=x86,config=8888,",
+		Source:    "master",
+		Offset:    10,
+		Timestamp: 1500000000,
+		Params:    map[string]string{"arch": "x86", "config": "8888"},
+		Value:     1.5,
+	}, rows[0])
+	assert.Equal(t, int64(11), rows[2].Offset)
+	assert.Equal(t, float32(3), rows[2].Value)
+}
+
+func TestExportParquet(t *testing.T) {
+	testutils.SmallTest(t)
+
+	var b bytes.Buffer
+	assert.NoError(t, Export(&b, newTestDataFrame(), PARQUET))
+	// Parquet files start and end with a magic number.
+	assert.True(t, bytes.HasPrefix(b.Bytes(), []byte("PAR1")))
+	assert.True(t, bytes.HasSuffix(b.Bytes(), []byte("PAR1")))
+}
+
+func TestExportBadKey(t *testing.T) {
+	testutils.SmallTest(t)
+
+	df := newTestDataFrame()
+	df.TraceSet["not-a-key"] = []float32{1, 2}
+	var b bytes.Buffer
+	assert.Error(t, Export(&b, df, CSV))
+}
@@ -0,0 +1,384 @@
+// Package localts implements tracestore.TraceStore on top of BoltDB, so that
+// Perf can be run on a single machine without BigTable.
+//
+// The layout of the data mirrors the layout used in BigTable, see
+// BIGTABLE.md, with each tile having its own OrderedParamSet and its own
+// bucket of traces.
+package localts
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"go.skia.org/infra/go/paramtools"
+	"go.skia.org/infra/go/query"
+	"go.skia.org/infra/go/vec32"
+	"go.skia.org/infra/perf/go/btts"
+	"go.skia.org/infra/perf/go/tracestore"
+)
+
+const (
+	// DB_FILENAME is the name of the BoltDB file in the directory passed to
+	// NewLocalTraceStore.
+	DB_FILENAME = "traces.db"
+
+	// Top level buckets.
+	OPS_BUCKET     = "ops"     // Maps TileKey.OpsRowName() to the encoded OrderedParamSet.
+	HASHES_BUCKET  = "hashes"  // Maps the md5 hash of a source file name to the source file name.
+	TILES_BUCKET   = "tiles"   // Contains one bucket per tile, named by TileKey.OpsRowName().
+	VALUES_BUCKET  = "values"  // Per-tile bucket that maps encoded trace ids to trace values.
+	SOURCES_BUCKET = "sources" // Per-tile bucket that maps encoded trace ids to the source hash of each value.
+)
+
+// LocalTraceStore implements tracestore.TraceStore using BoltDB.
+type LocalTraceStore struct {
+	tileSize int32 // How many commits we store per tile.
+	db       *bolt.DB
+
+	mutex    sync.Mutex                                   // Protects opsCache.
+	opsCache map[btts.TileKey]*paramtools.OrderedParamSet // map[tile] -> ops.
+}
+
+// NewLocalTraceStore returns a new LocalTraceStore that stores its data in
+// the given directory, which is created if it doesn't exist.
+//
+// Note that BoltDB only allows a single process to have the database open at
+// a time.
+func NewLocalTraceStore(dir string, tileSize int32) (*LocalTraceStore, error) {
+	if tileSize <= 0 {
+		return nil, fmt.Errorf("tileSize must be >0. %d", tileSize)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Failed to create directory %q: %s", dir, err)
+	}
+	filename := filepath.Join(dir, DB_FILENAME)
+	db, err := bolt.Open(filename, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open BoltDB at %s: %s", filename, err)
+	}
+	createBuckets := func(tx *bolt.Tx) error {
+		for _, name := range []string{OPS_BUCKET, HASHES_BUCKET, TILES_BUCKET} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return fmt.Errorf("Failed to create bucket %s: %s", name, err)
+			}
+		}
+		return nil
+	}
+	if err := db.Update(createBuckets); err != nil {
+		return nil, fmt.Errorf("Failed to create buckets: %s", err)
+	}
+	return &LocalTraceStore{
+		tileSize: tileSize,
+		db:       db,
+		opsCache: map[btts.TileKey]*paramtools.OrderedParamSet{},
+	}, nil
+}
+
+// Close the underlying database.
+func (l *LocalTraceStore) Close() error {
+	return l.db.Close()
+}
+
+// See tracestore.TraceStore.
+func (l *LocalTraceStore) TileKey(index int32) btts.TileKey {
+	return btts.TileKeyFromOffset(index / l.tileSize)
+}
+
+// See tracestore.TraceStore.
+func (l *LocalTraceStore) OffsetFromIndex(index int32) int32 {
+	return index % l.tileSize
+}
+
+// See tracestore.TraceStore.
+func (l *LocalTraceStore) GetLatestTile() (btts.TileKey, error) {
+	ret := btts.BadTileKey
+	err := l.db.View(func(tx *bolt.Tx) error {
+		// TileKeys are stored in reverse order, so the first key is the latest tile.
+		k, _ := tx.Bucket([]byte(OPS_BUCKET)).Cursor().First()
+		if k == nil {
+			return fmt.Errorf("No tiles found.")
+		}
+		var err error
+		ret, err = btts.TileKeyFromOpsRowName(string(k))
+		return err
+	})
+	if err != nil {
+		return btts.BadTileKey, fmt.Errorf("Failed to find latest tile: %s", err)
+	}
+	return ret, nil
+}
+
+// getOPS returns the OPS for the given tile, reading it from the given
+// transaction if it isn't cached.
+func (l *LocalTraceStore) getOPS(tx *bolt.Tx, tileKey btts.TileKey) (*paramtools.OrderedParamSet, error) {
+	l.mutex.Lock()
+	ops, ok := l.opsCache[tileKey]
+	l.mutex.Unlock()
+	if ok {
+		return ops, nil
+	}
+	b := tx.Bucket([]byte(OPS_BUCKET)).Get([]byte(tileKey.OpsRowName()))
+	if b == nil {
+		return paramtools.NewOrderedParamSet(), nil
+	}
+	ops, err := paramtools.NewOrderedParamSetFromBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode OPS: %s", err)
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.opsCache[tileKey] = ops
+	return ops, nil
+}
+
+// See tracestore.TraceStore.
+func (l *LocalTraceStore) GetOrderedParamSet(tileKey btts.TileKey) (*paramtools.OrderedParamSet, error) {
+	var ret *paramtools.OrderedParamSet
+	err := l.db.View(func(tx *bolt.Tx) error {
+		var err error
+		ret, err = l.getOPS(tx, tileKey)
+		return err
+	})
+	return ret, err
+}
+
+// See tracestore.TraceStore.
+func (l *LocalTraceStore) UpdateOrderedParamSet(tileKey btts.TileKey, p paramtools.ParamSet) (*paramtools.OrderedParamSet, error) {
+	var ret *paramtools.OrderedParamSet
+	// BoltDB only allows one writable transaction at a time, so there's no
+	// chance of a lost update.
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		ops, err := l.getOPS(tx, tileKey)
+		if err != nil {
+			return err
+		}
+		// If the OPS contains our paramset then we're done.
+		if delta := ops.Delta(p); len(delta) == 0 {
+			ret = ops
+			return nil
+		}
+		ops = ops.Copy()
+		ops.Update(p)
+		encodedOps, err := ops.Encode()
+		if err != nil {
+			return fmt.Errorf("Failed to encode new ops: %s", err)
+		}
+		if err := tx.Bucket([]byte(OPS_BUCKET)).Put([]byte(tileKey.OpsRowName()), encodedOps); err != nil {
+			return fmt.Errorf("Failed to write ops: %s", err)
+		}
+		ret = ops
+		return nil
+	})
+	if err != nil {
+		// Since the transaction may have been rolled back make sure we re-read
+		// the OPS next time.
+		l.mutex.Lock()
+		delete(l.opsCache, tileKey)
+		l.mutex.Unlock()
+		return nil, err
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.opsCache[tileKey] = ret
+	return ret, nil
+}
+
+// tileBuckets returns the values and sources buckets for the given tile. If
+// 'create' is true then the buckets are created if they don't exist,
+// otherwise nil buckets are returned for a tile that doesn't exist.
+func tileBuckets(tx *bolt.Tx, tileKey btts.TileKey, create bool) (*bolt.Bucket, *bolt.Bucket, error) {
+	tiles := tx.Bucket([]byte(TILES_BUCKET))
+	name := []byte(tileKey.OpsRowName())
+	if !create {
+		tile := tiles.Bucket(name)
+		if tile == nil {
+			return nil, nil, nil
+		}
+		return tile.Bucket([]byte(VALUES_BUCKET)), tile.Bucket([]byte(SOURCES_BUCKET)), nil
+	}
+	tile, err := tiles.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to create tile bucket: %s", err)
+	}
+	values, err := tile.CreateBucketIfNotExists([]byte(VALUES_BUCKET))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to create values bucket: %s", err)
+	}
+	sources, err := tile.CreateBucketIfNotExists([]byte(SOURCES_BUCKET))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to create sources bucket: %s", err)
+	}
+	return values, sources, nil
+}
+
+// decodeValues decodes the stored trace values. Missing or short values are
+// filled with vec32.MISSING_DATA_SENTINEL.
+func (l *LocalTraceStore) decodeValues(b []byte) []float32 {
+	ret := vec32.New(int(l.tileSize))
+	for i := 0; i < len(ret) && (i+1)*4 <= len(b); i++ {
+		ret[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return ret
+}
+
+// encodeValues is the inverse of decodeValues.
+func encodeValues(values []float32) []byte {
+	ret := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(ret[i*4:], math.Float32bits(v))
+	}
+	return ret
+}
+
+// See tracestore.TraceStore.
+func (l *LocalTraceStore) WriteTraces(index int32, values map[string]float32, source string, timestamp time.Time) error {
+	sourceHash := md5.Sum([]byte(source))
+	tileKey := l.TileKey(index)
+	offset := l.OffsetFromIndex(index)
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte(HASHES_BUCKET)).Put(sourceHash[:], []byte(source)); err != nil {
+			return fmt.Errorf("Failed to write source: %s", err)
+		}
+		valuesBucket, sourcesBucket, err := tileBuckets(tx, tileKey, true)
+		if err != nil {
+			return err
+		}
+		for k, v := range values {
+			key := []byte(k)
+			trace := l.decodeValues(valuesBucket.Get(key))
+			trace[offset] = v
+			if err := valuesBucket.Put(key, encodeValues(trace)); err != nil {
+				return fmt.Errorf("Failed to write trace values: %s", err)
+			}
+			// Copy since the slice returned from Get is only valid for the life of the transaction
+			// and can't be modified.
+			sources := make([]byte, md5.Size*l.tileSize)
+			copy(sources, sourcesBucket.Get(key))
+			copy(sources[md5.Size*offset:], sourceHash[:])
+			if err := sourcesBucket.Put(key, sources); err != nil {
+				return fmt.Errorf("Failed to write trace sources: %s", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed writing traces: %s", err)
+	}
+	return nil
+}
+
+// See tracestore.TraceStore.
+func (l *LocalTraceStore) ReadTraces(tileKey btts.TileKey, keys []string) (map[string][]float32, error) {
+	ops, err := l.GetOrderedParamSet(tileKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get OPS: %s", err)
+	}
+	ret := map[string][]float32{}
+	err = l.db.View(func(tx *bolt.Tx) error {
+		valuesBucket, _, err := tileBuckets(tx, tileKey, false)
+		if err != nil || valuesBucket == nil {
+			return err
+		}
+		for _, key := range keys {
+			params, err := query.ParseKey(key)
+			if err != nil {
+				return fmt.Errorf("Failed to parse key %q: %s", key, err)
+			}
+			// Not all keys may appear in all tiles, that's ok.
+			encodedKey, err := ops.EncodeParamsAsString(paramtools.Params(params))
+			if err != nil {
+				continue
+			}
+			if b := valuesBucket.Get([]byte(encodedKey)); b != nil {
+				ret[key] = l.decodeValues(b)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// forEachMatch calls 'f' for each trace in the given tile whose encoded key
+// matches 'q'. The value passed to 'f' is only valid during the call.
+func (l *LocalTraceStore) forEachMatch(tileKey btts.TileKey, q *regexp.Regexp, f func(encodedKey string, value []byte)) error {
+	return l.db.View(func(tx *bolt.Tx) error {
+		valuesBucket, _, err := tileBuckets(tx, tileKey, false)
+		if err != nil || valuesBucket == nil {
+			return err
+		}
+		return valuesBucket.ForEach(func(k, v []byte) error {
+			if q.Match(k) {
+				f(string(k), v)
+			}
+			return nil
+		})
+	})
+}
+
+// See tracestore.TraceStore.
+func (l *LocalTraceStore) QueryTraces(tileKey btts.TileKey, q *regexp.Regexp) (map[string][]float32, error) {
+	ret := map[string][]float32{}
+	err := l.forEachMatch(tileKey, q, func(encodedKey string, value []byte) {
+		ret[encodedKey] = l.decodeValues(value)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to query: %s", err)
+	}
+	return ret, nil
+}
+
+// See tracestore.TraceStore.
+func (l *LocalTraceStore) QueryCount(tileKey btts.TileKey, q *regexp.Regexp) (int64, error) {
+	ret := int64(0)
+	err := l.forEachMatch(tileKey, q, func(encodedKey string, value []byte) {
+		ret++
+	})
+	if err != nil {
+		return -1, fmt.Errorf("Failed to query: %s", err)
+	}
+	return ret, nil
+}
+
+// See tracestore.TraceStore.
+func (l *LocalTraceStore) GetSource(index int32, traceId string) (string, error) {
+	tileKey := l.TileKey(index)
+	offset := l.OffsetFromIndex(index)
+	ret := ""
+	err := l.db.View(func(tx *bolt.Tx) error {
+		_, sourcesBucket, err := tileBuckets(tx, tileKey, false)
+		if err != nil {
+			return err
+		}
+		if sourcesBucket == nil {
+			return fmt.Errorf("No source found.")
+		}
+		sources := sourcesBucket.Get([]byte(traceId))
+		if len(sources) < int(md5.Size*(offset+1)) {
+			return fmt.Errorf("No source found.")
+		}
+		name := tx.Bucket([]byte(HASHES_BUCKET)).Get(sources[md5.Size*offset : md5.Size*(offset+1)])
+		if name == nil {
+			return fmt.Errorf("No source found.")
+		}
+		ret = string(name)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to read source: %s", err)
+	}
+	return ret, nil
+}
+
+// Validate that LocalTraceStore faithfully implements the TraceStore interface.
+var _ tracestore.TraceStore = (*LocalTraceStore)(nil)
@@ -0,0 +1,151 @@
+package localts
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.skia.org/infra/go/paramtools"
+	"go.skia.org/infra/go/query"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/vec32"
+	"go.skia.org/infra/perf/go/btts"
+)
+
+const (
+	TILE_SIZE = 256
+)
+
+func TestOrderedParamSet(t *testing.T) {
+	testutils.MediumTest(t)
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	l, err := NewLocalTraceStore(dir, TILE_SIZE)
+	assert.NoError(t, err)
+
+	// No tiles yet.
+	_, err = l.GetLatestTile()
+	assert.Error(t, err)
+
+	// Create an OPS in a fresh tile.
+	tileKey := btts.TileKeyFromOffset(1)
+	op, err := l.UpdateOrderedParamSet(tileKey, paramtools.ParamSet{
+		"cpu":    []string{"x86", "arm"},
+		"config": []string{"8888", "565"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, op.KeyOrder, 2)
+
+	// Then update that OPS.
+	op, err = l.UpdateOrderedParamSet(tileKey, paramtools.ParamSet{
+		"os": []string{"linux", "win"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, op.KeyOrder, 3)
+
+	latest, err := l.GetLatestTile()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), latest.Offset())
+
+	// Add an OPS for a new tile.
+	_, err = l.UpdateOrderedParamSet(btts.TileKeyFromOffset(4), paramtools.ParamSet{
+		"os": []string{"win", "linux"},
+	})
+	assert.NoError(t, err)
+	latest, err = l.GetLatestTile()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), latest.Offset())
+
+	// Re-open the store so it has no cache, and confirm the OPS was persisted.
+	assert.NoError(t, l.Close())
+	l, err = NewLocalTraceStore(dir, TILE_SIZE)
+	assert.NoError(t, err)
+	defer testutils.AssertCloses(t, l)
+	op2, err := l.GetOrderedParamSet(tileKey)
+	assert.NoError(t, err)
+	assert.Equal(t, op.KeyOrder, op2.KeyOrder)
+	assert.Equal(t, op.ParamSet, op2.ParamSet)
+}
+
+func TestReadWriteTraces(t *testing.T) {
+	testutils.MediumTest(t)
+	dir, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	l, err := NewLocalTraceStore(dir, TILE_SIZE)
+	assert.NoError(t, err)
+	defer testutils.AssertCloses(t, l)
+
+	tileKey := l.TileKey(TILE_SIZE + 1)
+	assert.Equal(t, int32(1), tileKey.Offset())
+	assert.Equal(t, int32(1), l.OffsetFromIndex(TILE_SIZE+1))
+
+	ops, err := l.UpdateOrderedParamSet(tileKey, paramtools.ParamSet{
+		"config": []string{"8888", "565", "gpu"},
+		"cpu":    []string{"x86", "arm"},
+	})
+	assert.NoError(t, err)
+	encode := func(p paramtools.Params) string {
+		key, err := ops.EncodeParamsAsString(p)
+		assert.NoError(t, err)
+		return key
+	}
+	x86_8888 := encode(paramtools.Params{"config": "8888", "cpu": "x86"})
+	x86_565 := encode(paramtools.Params{"config": "565", "cpu": "x86"})
+	arm_gpu := encode(paramtools.Params{"config": "gpu", "cpu": "arm"})
+
+	now := time.Now()
+	err = l.WriteTraces(TILE_SIZE+1, map[string]float32{
+		x86_8888: 1.0,
+		x86_565:  2.0,
+	}, "gs://some/file.json", now)
+	assert.NoError(t, err)
+	err = l.WriteTraces(TILE_SIZE+2, map[string]float32{
+		x86_8888: 1.5,
+		arm_gpu:  3.0,
+	}, "gs://some/other-file.json", now)
+	assert.NoError(t, err)
+
+	e := vec32.MISSING_DATA_SENTINEL
+
+	// ReadTraces takes and returns structured keys.
+	traces, err := l.ReadTraces(tileKey, []string{",config=8888,cpu=x86,", ",config=gpu,cpu=arm,", ",config=565,cpu=arm,"})
+	assert.NoError(t, err)
+	assert.Len(t, traces, 2)
+	assert.Equal(t, []float32{e, 1.0, 1.5, e}, traces[",config=8888,cpu=x86,"][:4])
+	assert.Equal(t, []float32{e, e, 3.0, e}, traces[",config=gpu,cpu=arm,"][:4])
+	assert.Len(t, traces[",config=8888,cpu=x86,"], TILE_SIZE)
+
+	// QueryTraces returns encoded keys.
+	q, err := query.New(url.Values{"cpu": []string{"x86"}})
+	assert.NoError(t, err)
+	r, err := q.Regexp(ops)
+	assert.NoError(t, err)
+	traces, err = l.QueryTraces(tileKey, r)
+	assert.NoError(t, err)
+	assert.Len(t, traces, 2)
+	assert.Equal(t, []float32{e, 2.0, e}, traces[x86_565][:3])
+
+	count, err := l.QueryCount(tileKey, r)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// Querying a tile with no data isn't an error.
+	count, err = l.QueryCount(btts.TileKeyFromOffset(3), r)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	// Sources are recorded per point.
+	source, err := l.GetSource(TILE_SIZE+1, x86_8888)
+	assert.NoError(t, err)
+	assert.Equal(t, "gs://some/file.json", source)
+	source, err = l.GetSource(TILE_SIZE+2, x86_8888)
+	assert.NoError(t, err)
+	assert.Equal(t, "gs://some/other-file.json", source)
+	_, err = l.GetSource(TILE_SIZE+2, x86_565)
+	assert.Error(t, err)
+	_, err = l.GetSource(TILE_SIZE+1, ",9=9,")
+	assert.Error(t, err)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"html/template"
 	"regexp"
+	textTemplate "text/template"
 
 	"go.skia.org/infra/go/sklog"
 	"go.skia.org/infra/perf/go/alerts"
@@ -31,10 +32,23 @@ const (
 <p>
 	With {{.Cluster.Num}} matching traces.
 </p>`
+
+	// TEXT is the default template for the body of notifications sent by
+	// transports other than email.
+	TEXT = `A Perf Regression ({{.Alert.DisplayName}}) has been found at:
+
+  https://{{.SubDomain}}.skia.org/g/t/{{.Commit.Hash}}
+
+For:
+
+  {{.Commit.URL}}
+
+With {{.Cluster.Num}} matching traces.`
 )
 
 var (
 	emailTemplate = template.Must(template.New("email").Parse(EMAIL))
+	textTmpl      = textTemplate.Must(textTemplate.New("text").Parse(TEXT))
 
 	emailAddressSplitter = regexp.MustCompile("[, ]+")
 )
@@ -54,18 +68,27 @@ func (n NoEmail) Send(from string, to []string, subject string, body string) err
 
 // Notifier sends notifications.
 type Notifier struct {
-	email     Email
-	subdomain string
+	subdomain  string
+	transports map[alerts.Transport]Transport
 }
 
-// New returns a new Notifier.
+// New returns a new Notifier that can send email. Use AddTransport to support
+// the other kinds of alerts.Transport.
 func New(email Email, subdomain string) *Notifier {
 	return &Notifier{
-		email:     email,
 		subdomain: subdomain,
+		transports: map[alerts.Transport]Transport{
+			alerts.EMAIL_TRANSPORT: NewEmailTransport(email),
+		},
 	}
 }
 
+// AddTransport registers the Transport used to deliver notifications for
+// alerts.Config's with the given alerts.Transport.
+func (n *Notifier) AddTransport(name alerts.Transport, transport Transport) {
+	n.transports[name] = transport
+}
+
 type context struct {
 	SubDomain string
 	Commit    *cid.CommitDetail
@@ -81,13 +104,44 @@ func (n *Notifier) formatEmail(c *cid.CommitDetail, alert *alerts.Config, cl *cl
 		Cluster:   cl,
 	}
 
+	tmpl := emailTemplate
+	if alert.Template != "" {
+		var err error
+		tmpl, err = template.New("email").Parse(alert.Template)
+		if err != nil {
+			return "", fmt.Errorf("Failed to parse alert template: %s", err)
+		}
+	}
 	var b bytes.Buffer
-	if err := emailTemplate.Execute(&b, templateContext); err != nil {
+	if err := tmpl.Execute(&b, templateContext); err != nil {
 		return "", fmt.Errorf("Failed to format email body: %s", err)
 	}
 	return b.String(), nil
 }
 
+func (n *Notifier) formatText(c *cid.CommitDetail, alert *alerts.Config, cl *clustering2.ClusterSummary) (string, error) {
+	templateContext := &context{
+		SubDomain: n.subdomain,
+		Commit:    c,
+		Alert:     alert,
+		Cluster:   cl,
+	}
+
+	tmpl := textTmpl
+	if alert.Template != "" {
+		var err error
+		tmpl, err = textTemplate.New("text").Parse(alert.Template)
+		if err != nil {
+			return "", fmt.Errorf("Failed to parse alert template: %s", err)
+		}
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, templateContext); err != nil {
+		return "", fmt.Errorf("Failed to format message body: %s", err)
+	}
+	return b.String(), nil
+}
+
 func splitEmails(s string) []string {
 	ret := []string{}
 	for _, e := range emailAddressSplitter.Split(s, -1) {
@@ -98,25 +152,57 @@ func splitEmails(s string) []string {
 	return ret
 }
 
-// Send a notification for the given cluster found at the given commit. Where to send it is defined in the alerts.Config.
-func (n *Notifier) Send(c *cid.CommitDetail, alert *alerts.Config, cl *clustering2.ClusterSummary) error {
+// Format returns the Message that would be sent for the given cluster found
+// at the given commit, along with the Transport that would deliver it.
+func (n *Notifier) Format(c *cid.CommitDetail, alert *alerts.Config, cl *clustering2.ClusterSummary) (*Message, Transport, error) {
 	if alert.Alert == "" {
-		return fmt.Errorf("No notification sent. No email address set for alert #%d", alert.ID)
+		return nil, nil, fmt.Errorf("No notification sent. No destination set for alert #%d", alert.ID)
+	}
+	name, err := alerts.ToTransport(string(alert.Transport))
+	if err != nil {
+		return nil, nil, err
+	}
+	transport, ok := n.transports[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("No notification sent. Transport %q is not available.", name)
+	}
+	var body string
+	if name == alerts.EMAIL_TRANSPORT {
+		body, err = n.formatEmail(c, alert, cl)
+	} else {
+		body, err = n.formatText(c, alert, cl)
 	}
-	body, err := n.formatEmail(c, alert, cl)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg := &Message{
+		Subject:   fmt.Sprintf("%s - Regression found for %q", alert.DisplayName, c.Message),
+		Body:      body,
+		URL:       fmt.Sprintf("https://%s.skia.org/g/t/%s", n.subdomain, c.Hash),
+		Transport: name,
+		To:        alert.Alert,
+		AlertID:   alert.ID,
+		Commit:    c,
+		NumTraces: cl.Num,
+	}
+	return msg, transport, nil
+}
+
+// Send a notification for the given cluster found at the given commit. Where to send it is defined in the alerts.Config.
+func (n *Notifier) Send(c *cid.CommitDetail, alert *alerts.Config, cl *clustering2.ClusterSummary) error {
+	msg, transport, err := n.Format(c, alert, cl)
 	if err != nil {
 		return err
 	}
-	subject := fmt.Sprintf("%s - Regression found for %q", alert.DisplayName, c.Message)
-	if err := n.email.Send(FROM_ADDRESS, splitEmails(alert.Alert), subject, body); err != nil {
-		return fmt.Errorf("Failed to send email: %s", err)
+	if err := transport.Send(alert, msg); err != nil {
+		return fmt.Errorf("Failed to send %s notification: %s", msg.Transport, err)
 	}
 
 	return nil
 }
 
-// ExampleSend sends an example for dummy data for the given alerts.Config.
-func (n *Notifier) ExampleSend(alert *alerts.Config) error {
+// exampleData returns dummy data to use in example notifications.
+func exampleData() (*cid.CommitDetail, *clustering2.ClusterSummary) {
 	c := &cid.CommitDetail{
 		Message: "Re-enable opList dependency tracking",
 		URL:     "https://skia.googlesource.com/skia/+/d261e1075a93677442fdf7fe72aba7e583863664",
@@ -125,5 +211,19 @@ func (n *Notifier) ExampleSend(alert *alerts.Config) error {
 	cl := &clustering2.ClusterSummary{
 		Num: 10,
 	}
+	return c, cl
+}
+
+// ExampleSend sends an example for dummy data for the given alerts.Config.
+func (n *Notifier) ExampleSend(alert *alerts.Config) error {
+	c, cl := exampleData()
 	return n.Send(c, alert, cl)
 }
+
+// ExampleFormat returns the Message that ExampleSend would send for the given
+// alerts.Config, without sending it.
+func (n *Notifier) ExampleFormat(alert *alerts.Config) (*Message, error) {
+	c, cl := exampleData()
+	msg, _, err := n.Format(c, alert, cl)
+	return msg, err
+}
@@ -0,0 +1,190 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"go.skia.org/infra/go/httputils"
+	"go.skia.org/infra/go/issues"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/perf/go/alerts"
+	"go.skia.org/infra/perf/go/cid"
+)
+
+var (
+	// ISSUE_LABELS are the labels applied to bugs filed by the issue Transport.
+	ISSUE_LABELS = []string{"FromSkiaPerf", "Type-Defect", "Priority-Medium"}
+)
+
+// Message is a formatted notification, ready to be delivered by a Transport.
+//
+// Message is also the body of the JSON sent by the webhook Transport.
+type Message struct {
+	Subject   string            `json:"subject"`
+	Body      string            `json:"body"`
+	URL       string            `json:"url"` // Link to the regression in Perf.
+	Transport alerts.Transport  `json:"transport"`
+	To        string            `json:"to"` // Where the message will be delivered, i.e. alerts.Config.Alert.
+	AlertID   int64             `json:"alert_id"`
+	Commit    *cid.CommitDetail `json:"commit"`
+	NumTraces int               `json:"num_traces"`
+}
+
+// Transport delivers a Message for an alert.
+type Transport interface {
+	// Send the message to the destination given in alert.Alert.
+	Send(alert *alerts.Config, msg *Message) error
+}
+
+// emailTransport implements Transport by sending HTML email.
+type emailTransport struct {
+	email Email
+}
+
+// NewEmailTransport returns a Transport that sends the Message as HTML email.
+func NewEmailTransport(email Email) Transport {
+	return &emailTransport{
+		email: email,
+	}
+}
+
+// See Transport.
+func (e *emailTransport) Send(alert *alerts.Config, msg *Message) error {
+	return e.email.Send(FROM_ADDRESS, splitEmails(alert.Alert), msg.Subject, msg.Body)
+}
+
+// ChatSender is the signature of chatbot.Send.
+type ChatSender func(body, room, thread string) error
+
+// chatTransport implements Transport by sending to a chat room.
+type chatTransport struct {
+	send ChatSender
+}
+
+// NewChatTransport returns a Transport that sends the Message to the chat room
+// named in alert.Alert. Messages for the same alert are grouped into a single
+// thread. Note that chatbot.Init must be called before using chatbot.Send as
+// the ChatSender.
+func NewChatTransport(send ChatSender) Transport {
+	return &chatTransport{
+		send: send,
+	}
+}
+
+// See Transport.
+func (c *chatTransport) Send(alert *alerts.Config, msg *Message) error {
+	return c.send(msg.Body, alert.Alert, fmt.Sprintf("perf-alert-%d", alert.ID))
+}
+
+// webhookTransport implements Transport by POSTing JSON to a URL.
+type webhookTransport struct {
+	client *http.Client
+
+	// validIP returns true if the transport may connect to the given address.
+	validIP func(net.IP) bool
+}
+
+// NewWebhookTransport returns a Transport that POSTs the Message serialized as
+// JSON to the URL in alert.Alert.
+//
+// The transport refuses to connect to any address rejected by
+// alerts.ValidWebhookIP, including when following redirects, so webhooks can't
+// be used to reach internal services.
+func NewWebhookTransport() Transport {
+	w := &webhookTransport{
+		validIP: alerts.ValidWebhookIP,
+	}
+	w.client = &http.Client{
+		Transport: &http.Transport{
+			Dial: w.dial,
+		},
+		Timeout: httputils.REQUEST_TIMEOUT,
+	}
+	return w
+}
+
+// dial resolves the host in addr and connects to it, only if every address the
+// host resolves to passes w.validIP. Dialing the checked address, rather than
+// the host name, keeps the host from resolving to a different address between
+// the check and the connection.
+func (w *webhookTransport) dial(network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("No addresses found for %q", host)
+	}
+	for _, ip := range ips {
+		if !w.validIP(ip) {
+			return nil, fmt.Errorf("Webhooks may not be sent to %q: %s is not a public address.", host, ip)
+		}
+	}
+	return net.DialTimeout(network, net.JoinHostPort(ips[0].String(), port), httputils.DIAL_TIMEOUT)
+}
+
+// See Transport.
+func (w *webhookTransport) Send(alert *alerts.Config, msg *Message) error {
+	u, err := url.Parse(alert.Alert)
+	if err != nil {
+		return fmt.Errorf("Invalid webhook URL: %s", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("Invalid webhook URL %q: must be an http or https URL.", alert.Alert)
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("Failed to encode message: %s", err)
+	}
+	resp, err := w.client.Post(alert.Alert, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("Failed to send message: %s", err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Wrong status code sending message: %d %s", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
+
+// issueTransport implements Transport by filing a bug.
+type issueTransport struct {
+	tracker issues.IssueTracker
+}
+
+// NewIssueTransport returns a Transport that files a bug for the Message,
+// owned by alert.Owner and CC'ing the email addresses in alert.Alert.
+func NewIssueTransport(tracker issues.IssueTracker) Transport {
+	return &issueTransport{
+		tracker: tracker,
+	}
+}
+
+// See Transport.
+func (i *issueTransport) Send(alert *alerts.Config, msg *Message) error {
+	cc := []issues.MonorailPerson{}
+	for _, email := range splitEmails(alert.Alert) {
+		cc = append(cc, issues.MonorailPerson{
+			Name: email,
+		})
+	}
+	req := issues.IssueRequest{
+		Status: "Untriaged",
+		Owner: issues.MonorailPerson{
+			Name: alert.Owner,
+		},
+		CC:          cc,
+		Labels:      ISSUE_LABELS,
+		Summary:     msg.Subject,
+		Description: fmt.Sprintf("%s\n\n%s", msg.Body, msg.URL),
+	}
+	return i.tracker.AddIssue(req)
+}
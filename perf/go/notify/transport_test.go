@@ -0,0 +1,171 @@
+package notify
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.skia.org/infra/go/issues"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/perf/go/alerts"
+)
+
+type chatMock struct {
+	body   string
+	room   string
+	thread string
+}
+
+func (c *chatMock) Send(body, room, thread string) error {
+	c.body = body
+	c.room = room
+	c.thread = thread
+	return nil
+}
+
+type issueTrackerMock struct {
+	issues []issues.IssueRequest
+}
+
+func (i *issueTrackerMock) FromQuery(q string) ([]issues.Issue, error) {
+	return nil, nil
+}
+
+func (i *issueTrackerMock) AddComment(id string, comment issues.CommentRequest) error {
+	return nil
+}
+
+func (i *issueTrackerMock) AddIssue(issue issues.IssueRequest) error {
+	i.issues = append(i.issues, issue)
+	return nil
+}
+
+func TestChatTransport(t *testing.T) {
+	testutils.SmallTest(t)
+
+	c := &chatMock{}
+	n := New(&emailMock{}, "perf")
+	n.AddTransport(alerts.CHAT_TRANSPORT, NewChatTransport(c.Send))
+	alert := &alerts.Config{
+		ID:          12,
+		Alert:       "perf-room",
+		DisplayName: "MyAlert",
+		Transport:   alerts.CHAT_TRANSPORT,
+	}
+	err := n.ExampleSend(alert)
+	assert.NoError(t, err)
+	assert.Equal(t, "perf-room", c.room)
+	assert.Equal(t, "perf-alert-12", c.thread)
+	assert.Equal(t, "A Perf Regression (MyAlert) has been found at:\n\n  https://perf.skia.org/g/t/d261e1075a93677442fdf7fe72aba7e583863664\n\nFor:\n\n  https://skia.googlesource.com/skia/+/d261e1075a93677442fdf7fe72aba7e583863664\n\nWith 10 matching traces.", c.body)
+
+	// Custom templates are supported.
+	alert.Template = "{{.Alert.DisplayName}}: {{.Cluster.Num}} traces at {{.Commit.Hash}}"
+	err = n.ExampleSend(alert)
+	assert.NoError(t, err)
+	assert.Equal(t, "MyAlert: 10 traces at d261e1075a93677442fdf7fe72aba7e583863664", c.body)
+}
+
+func TestWebhookTransport(t *testing.T) {
+	testutils.SmallTest(t)
+
+	var got Message
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer ts.Close()
+
+	// The test servers listen on loopback, which webhooks are normally not
+	// allowed to reach.
+	n := New(&emailMock{}, "perf")
+	wt := NewWebhookTransport()
+	n.AddTransport(alerts.WEBHOOK_TRANSPORT, wt)
+	alert := &alerts.Config{
+		ID:          12,
+		Alert:       ts.URL,
+		DisplayName: "MyAlert",
+		Transport:   alerts.WEBHOOK_TRANSPORT,
+	}
+	assert.Error(t, n.ExampleSend(alert))
+	alert.Alert = strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+	assert.Error(t, n.ExampleSend(alert))
+	alert.Alert = "file:///etc/passwd"
+	assert.Error(t, n.ExampleSend(alert))
+
+	wt.(*webhookTransport).validIP = func(net.IP) bool { return true }
+	alert.Alert = ts.URL
+	err := n.ExampleSend(alert)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), got.AlertID)
+	assert.Equal(t, 10, got.NumTraces)
+	assert.Equal(t, "https://perf.skia.org/g/t/d261e1075a93677442fdf7fe72aba7e583863664", got.URL)
+	assert.Equal(t, "d261e1075a93677442fdf7fe72aba7e583863664", got.Commit.Hash)
+	assert.Equal(t, alerts.WEBHOOK_TRANSPORT, got.Transport)
+
+	// Non-2xx responses are errors.
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	alert.Alert = failing.URL
+	assert.Error(t, n.ExampleSend(alert))
+}
+
+func TestIssueTransport(t *testing.T) {
+	testutils.SmallTest(t)
+
+	tracker := &issueTrackerMock{}
+	n := New(&emailMock{}, "perf")
+	n.AddTransport(alerts.ISSUE_TRANSPORT, NewIssueTransport(tracker))
+	alert := &alerts.Config{
+		Alert:       "someone@example.org, someother@example.com",
+		Owner:       "owner@example.org",
+		DisplayName: "MyAlert",
+		Transport:   alerts.ISSUE_TRANSPORT,
+	}
+	err := n.ExampleSend(alert)
+	assert.NoError(t, err)
+	assert.Len(t, tracker.issues, 1)
+	issue := tracker.issues[0]
+	assert.Equal(t, "owner@example.org", issue.Owner.Name)
+	assert.Equal(t, []issues.MonorailPerson{{Name: "someone@example.org"}, {Name: "someother@example.com"}}, issue.CC)
+	assert.Equal(t, "MyAlert - Regression found for \"Re-enable opList dependency tracking\"", issue.Summary)
+	assert.Equal(t, ISSUE_LABELS, issue.Labels)
+}
+
+func TestUnavailableTransport(t *testing.T) {
+	testutils.SmallTest(t)
+
+	n := New(&emailMock{}, "perf")
+	alert := &alerts.Config{
+		Alert:     "perf-room",
+		Transport: alerts.CHAT_TRANSPORT,
+	}
+	assert.Error(t, n.ExampleSend(alert))
+
+	alert.Transport = "carrier-pigeon"
+	assert.Error(t, n.ExampleSend(alert))
+}
+
+func TestExampleFormat(t *testing.T) {
+	testutils.SmallTest(t)
+
+	e := &emailMock{}
+	n := New(e, "perf")
+	alert := &alerts.Config{
+		Alert:       "someone@example.org",
+		DisplayName: "MyAlert",
+	}
+	msg, err := n.ExampleFormat(alert)
+	assert.NoError(t, err)
+	assert.Equal(t, alerts.EMAIL_TRANSPORT, msg.Transport)
+	assert.Equal(t, "someone@example.org", msg.To)
+	assert.Equal(t, "MyAlert - Regression found for \"Re-enable opList dependency tracking\"", msg.Subject)
+
+	// Nothing was sent.
+	assert.Equal(t, "", e.subject)
+}
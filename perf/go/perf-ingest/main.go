@@ -1,5 +1,9 @@
 // perf-ingest listens to a PubSub Topic for new files that appear
 // in a storage bucket and then ingests those files into BigTable.
+//
+// When --trace_store_dir is set perf-ingest instead periodically scans a local
+// directory for new files and ingests them into a local trace store, which
+// skiaperf can then be pointed at.
 package main
 
 import (
@@ -13,6 +17,7 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -32,6 +37,8 @@ import (
 	"go.skia.org/infra/perf/go/btts"
 	"go.skia.org/infra/perf/go/config"
 	"go.skia.org/infra/perf/go/ingestcommon"
+	"go.skia.org/infra/perf/go/localts"
+	"go.skia.org/infra/perf/go/tracestore"
 	"google.golang.org/api/option"
 )
 
@@ -41,11 +48,17 @@ var (
 	local      = flag.Bool("local", false, "Running locally if true. As opposed to in production.")
 	port       = flag.String("port", ":8000", "HTTP service address (e.g., ':8000')")
 	promPort   = flag.String("prom_port", ":20000", "Metrics service address (e.g., ':10110')")
+
+	sourceDir     = flag.String("source_dir", "", "The directory to scan for files to ingest when using --trace_store_dir.")
+	traceStoreDir = flag.String("trace_store_dir", "", "If set then ingest into a local trace store in this directory, instead of BigTable.")
 )
 
 const (
 	// MAX_PARALLEL_RECEIVES is the number of Go routines we want to run. Determined experimentally.
 	MAX_PARALLEL_RECEIVES = 1
+
+	// LOCAL_POLL_PERIOD is how often --source_dir is scanned for new files.
+	LOCAL_POLL_PERIOD = time.Minute
 )
 
 var (
@@ -107,8 +120,8 @@ func getParamsAndValues(b *ingestcommon.BenchData) ([]paramtools.Params, []float
 	return params, values, ps
 }
 
-// processSingleFile parses the contents of a single JSON file and writes the values into BigTable.
-func processSingleFile(ctx context.Context, store *btts.BigTableTraceStore, vcs vcsinfo.VCS, name string, r io.Reader, timestamp time.Time) error {
+// processSingleFile parses the contents of a single JSON file and writes the values into the TraceStore.
+func processSingleFile(ctx context.Context, store tracestore.TraceStore, vcs vcsinfo.VCS, name string, r io.Reader, timestamp time.Time) error {
 	benchData, err := ingestcommon.ParseBenchDataFromReader(r)
 	if err != nil {
 		sklog.Errorf("Failed to read or parse data: %s", err)
@@ -153,6 +166,65 @@ type Event struct {
 	Name   string `json:"name"`
 }
 
+// ingestDir ingests every JSON file in 'dir' that has been added or modified
+// since it was last ingested, as recorded in 'ingested', which maps file names
+// to their modification time.
+func ingestDir(ctx context.Context, store tracestore.TraceStore, vcs vcsinfo.VCS, dir string, ingested map[string]time.Time) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		if modTime, ok := ingested[path]; ok && modTime.Equal(info.ModTime()) {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("Failed to open %q: %s", path, err)
+		}
+		defer util.Close(f)
+		sklog.Info(path)
+		err = processSingleFile(ctx, store, vcs, "file://"+path, f, info.ModTime())
+		if err == NonRecoverableError {
+			sklog.Warningf("Skipping %q.", path)
+		} else if err != nil {
+			return fmt.Errorf("Failed to write results: %s", err)
+		}
+		ingested[path] = info.ModTime()
+		return nil
+	})
+}
+
+// runLocal ingests the files in --source_dir into the local trace store in
+// --trace_store_dir, and then keeps scanning for new files.
+func runLocal(ctx context.Context, cfg *config.PerfBigTableConfig) {
+	if *sourceDir == "" {
+		sklog.Fatal("The --source_dir flag is required when using --trace_store_dir.")
+	}
+	vcs, err := gitinfo.CloneOrUpdate(ctx, cfg.GitUrl, "/tmp/skia_ingest_checkout", true)
+	if err != nil {
+		sklog.Fatal(err)
+	}
+	store, err := localts.NewLocalTraceStore(*traceStoreDir, cfg.TileSize)
+	if err != nil {
+		sklog.Fatal(err)
+	}
+	go func() {
+		ingested := map[string]time.Time{}
+		for {
+			if err := ingestDir(ctx, store, vcs, *sourceDir, ingested); err != nil {
+				sklog.Errorf("Failed to ingest %q: %s", *sourceDir, err)
+			}
+			time.Sleep(LOCAL_POLL_PERIOD)
+		}
+	}()
+
+	http.HandleFunc("/ready", httputils.ReadyHandleFunc)
+	log.Fatal(http.ListenAndServe(*port, nil))
+}
+
 func main() {
 	common.InitWithMust(
 		"perf-ingest",
@@ -168,6 +240,10 @@ func main() {
 	if !ok {
 		sklog.Fatalf("Invalid --config value: %q", *configName)
 	}
+	if *traceStoreDir != "" {
+		runLocal(ctx, cfg)
+		return
+	}
 	hostname, err := os.Hostname()
 	if err != nil {
 		sklog.Fatalf("Failed to get hostname: %s", err)
@@ -0,0 +1,72 @@
+package perfclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.skia.org/infra/go/util"
+)
+
+// ExportClient downloads data from the /_/export/ endpoint of a running Perf
+// server.
+type ExportClient struct {
+	client *http.Client
+	host   string
+}
+
+// NewExportClient creates a new ExportClient that talks to the Perf server at
+// the given host, e.g. "https://perf.skia.org".
+func NewExportClient(client *http.Client, host string) *ExportClient {
+	return &ExportClient{
+		client: client,
+		host:   strings.TrimSuffix(host, "/"),
+	}
+}
+
+// Export writes all the trace values that match the query 'q' between
+// 'begin' and 'end' to 'w', in the given format, which is one of "csv",
+// "jsonl" or "parquet".
+func (e *ExportClient) Export(ctx context.Context, w io.Writer, format string, q url.Values, begin, end time.Time) error {
+	return e.export(ctx, w, url.Values{
+		"format": []string{format},
+		"q":      []string{q.Encode()},
+		"begin":  []string{strconv.FormatInt(begin.Unix(), 10)},
+		"end":    []string{strconv.FormatInt(end.Unix(), 10)},
+	})
+}
+
+// ExportShortcut is the same as Export, but exports the traces in the
+// shortcut with the given id.
+func (e *ExportClient) ExportShortcut(ctx context.Context, w io.Writer, format string, shortcut string, begin, end time.Time) error {
+	return e.export(ctx, w, url.Values{
+		"format": []string{format},
+		"keys":   []string{shortcut},
+		"begin":  []string{strconv.FormatInt(begin.Unix(), 10)},
+		"end":    []string{strconv.FormatInt(end.Unix(), 10)},
+	})
+}
+
+func (e *ExportClient) export(ctx context.Context, w io.Writer, params url.Values) error {
+	req, err := http.NewRequest("GET", e.host+"/_/export/?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("Failed to create export request: %s", err)
+	}
+	resp, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("Failed to request export: %s", err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Export failed with status %d: %s", resp.StatusCode, resp.Status)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("Failed to read export: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package perfclient
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+)
+
+func TestExport(t *testing.T) {
+	testutils.SmallTest(t)
+
+	var got url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/_/export/", r.URL.Path)
+		got = r.URL.Query()
+		_, err := w.Write([]byte("trace_id,value\n"))
+		assert.NoError(t, err)
+	}))
+	defer ts.Close()
+
+	e := NewExportClient(ts.Client(), ts.URL+"/")
+	var b bytes.Buffer
+	q := url.Values{"config": []string{"8888"}}
+	err := e.Export(context.Background(), &b, "csv", q, time.Unix(100, 0), time.Unix(200, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "trace_id,value\n", b.String())
+	assert.Equal(t, "csv", got.Get("format"))
+	assert.Equal(t, "config=8888", got.Get("q"))
+	assert.Equal(t, "100", got.Get("begin"))
+	assert.Equal(t, "200", got.Get("end"))
+
+	b.Reset()
+	err = e.ExportShortcut(context.Background(), &b, "jsonl", "X1234", time.Unix(100, 0), time.Unix(200, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "X1234", got.Get("keys"))
+	assert.Equal(t, "jsonl", got.Get("format"))
+}
+
+func TestExportError(t *testing.T) {
+	testutils.SmallTest(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Invalid format.", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	e := NewExportClient(ts.Client(), ts.URL)
+	var b bytes.Buffer
+	err := e.Export(context.Background(), &b, "xml", url.Values{}, time.Unix(100, 0), time.Unix(200, 0))
+	assert.Error(t, err)
+}
@@ -27,15 +27,18 @@ func RegressionsForAlert(ctx context.Context, cfg *alerts.Config, ps paramtools.
 
 		// Create ClusterRequest and run.
 		req := &ClusterRequest{
-			Radius:      cfg.Radius,
-			Query:       q,
-			Algo:        cfg.Algo,
-			Interesting: cfg.Interesting,
-			K:           cfg.K,
-			Sparse:      cfg.Sparse,
-			Type:        CLUSTERING_REQUEST_TYPE_LAST_N,
-			N:           int32(numContinuous),
-			End:         end,
+			Radius:         cfg.Radius,
+			Query:          q,
+			Algo:           cfg.Algo,
+			Interesting:    cfg.Interesting,
+			Significance:   cfg.Significance,
+			Band:           cfg.Band,
+			BaselineWindow: cfg.BaselineWindow,
+			K:              cfg.K,
+			Sparse:         cfg.Sparse,
+			Type:           CLUSTERING_REQUEST_TYPE_LAST_N,
+			N:              int32(numContinuous),
+			End:            end,
 		}
 		_, err := Run(ctx, req, git, cidl, dfBuilder, clusterResponseProcessor)
 		if err != nil {
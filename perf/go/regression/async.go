@@ -17,6 +17,7 @@ import (
 	"go.skia.org/infra/perf/go/config"
 	"go.skia.org/infra/perf/go/dataframe"
 	"go.skia.org/infra/perf/go/shortcut2"
+	"go.skia.org/infra/perf/go/stepfit"
 	"go.skia.org/infra/perf/go/types"
 )
 
@@ -60,18 +61,21 @@ var (
 
 // ClusterRequest is all the info needed to start a clustering run.
 type ClusterRequest struct {
-	Source      string             `json:"source"`
-	Offset      int                `json:"offset"`
-	Radius      int                `json:"radius"`
-	Query       string             `json:"query"`
-	K           int                `json:"k"`
-	TZ          string             `json:"tz"`
-	Algo        types.ClusterAlgo  `json:"algo"`
-	Interesting float32            `json:"interesting"`
-	Sparse      bool               `json:"sparse"`
-	Type        ClusterRequestType `json:"type"`
-	N           int32              `json:"n"`
-	End         time.Time          `json:"end"`
+	Source         string             `json:"source"`
+	Offset         int                `json:"offset"`
+	Radius         int                `json:"radius"`
+	Query          string             `json:"query"`
+	K              int                `json:"k"`
+	TZ             string             `json:"tz"`
+	Algo           types.ClusterAlgo  `json:"algo"`
+	Interesting    float32            `json:"interesting"`
+	Significance   float32            `json:"significance"`
+	Band           float32            `json:"band"`
+	BaselineWindow int                `json:"baseline_window"`
+	Sparse         bool               `json:"sparse"`
+	Type           ClusterRequestType `json:"type"`
+	N              int32              `json:"n"`
+	End            time.Time          `json:"end"`
 }
 
 func (c *ClusterRequest) Id() string {
@@ -98,6 +102,10 @@ type ClusterRequestProcess struct {
 	lastUpdate time.Time          // The last time this process was updated.
 	state      ProcessState       // The current state of the process.
 	message    string             // Describes the current state of the process.
+
+	// steps are the stepKeys of the steps already reported for the
+	// MULTISTEP_ALGO. Only accessed by Run.
+	steps map[string]bool
 }
 
 func newProcess(ctx context.Context, req *ClusterRequest, git *gitinfo.GitInfo, cidl *cid.CommitIDLookup, dfBuilder dataframe.DataFrameBuilder, clusterResponseProcessor ClusterResponseProcessor) *ClusterRequestProcess {
@@ -109,6 +117,7 @@ func newProcess(ctx context.Context, req *ClusterRequest, git *gitinfo.GitInfo,
 		lastUpdate:               time.Now(),
 		state:                    PROCESS_RUNNING,
 		message:                  "Running",
+		steps:                    map[string]bool{},
 	}
 	if req.Type == CLUSTERING_REQUEST_TYPE_SINGLE {
 		// TODO(jcgregorio) This is awkward and should go away in a future CL.
@@ -316,6 +325,25 @@ func tooMuchMissingData(tr types.Trace) bool {
 	return missing(tr[:n]) || missing(tr[len(tr)-n:])
 }
 
+// significance returns the p-value threshold to use for the statistical test
+// algorithms, falling back to the default if the request doesn't supply one.
+func (p *ClusterRequestProcess) significance() float32 {
+	if p.request.Significance <= 0 {
+		return stepfit.DEFAULT_SIGNIFICANCE
+	}
+	return p.request.Significance
+}
+
+// band returns the width of the band around the baseline to use for the
+// baseline algorithms, falling back to the default if the request doesn't
+// supply one.
+func (p *ClusterRequestProcess) band() float32 {
+	if p.request.Band <= 0 {
+		return stepfit.DEFAULT_BAND
+	}
+	return p.request.Band
+}
+
 // ShortcutFromKeys stores a new shortcut for each cluster based on its Keys.
 func ShortcutFromKeys(summary *clustering2.ClusterSummaries) error {
 	var err error
@@ -327,6 +355,45 @@ func ShortcutFromKeys(summary *clustering2.ClusterSummaries) error {
 	return nil
 }
 
+// responses returns the ClusterResponses for the clusters found in df.
+//
+// The MULTISTEP_ALGO finds steps anywhere in df, so each step gets its own
+// ClusterResponse with a Frame centered on the commit of the step, as for the
+// other algorithms. The DataFrames of a CLUSTERING_REQUEST_TYPE_LAST_N request
+// overlap, so each step is only returned for the first DataFrame it is found
+// in.
+func (p *ClusterRequestProcess) responses(ctx context.Context, df *dataframe.DataFrame, summary *clustering2.ClusterSummaries) ([]*ClusterResponse, error) {
+	if p.request.Algo != types.MULTISTEP_ALGO || len(summary.Clusters) == 0 {
+		frame, err := dataframe.ResponseFromDataFrame(ctx, df, p.git, false, p.request.TZ)
+		if err != nil {
+			return nil, err
+		}
+		return []*ClusterResponse{{Summary: summary, Frame: frame}}, nil
+	}
+	ret := []*ClusterResponse{}
+	for _, cl := range summary.Clusters {
+		key := stepKey(cl)
+		if p.steps[key] {
+			continue
+		}
+		p.steps[key] = true
+		centeredDf, centered := centerOnStep(df, cl)
+		frame, err := dataframe.ResponseFromDataFrame(ctx, centeredDf, p.git, false, p.request.TZ)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &ClusterResponse{
+			Summary: &clustering2.ClusterSummaries{
+				Clusters:        []*clustering2.ClusterSummary{centered},
+				StdDevThreshold: summary.StdDevThreshold,
+				K:               summary.K,
+			},
+			Frame: frame,
+		})
+	}
+	return ret, nil
+}
+
 // Run does the work in a ClusterRequestProcess. It does not return until all the
 // work is done or the request failed. Should be run as a Go routine.
 func (p *ClusterRequestProcess) Run(ctx context.Context) {
@@ -341,9 +408,14 @@ func (p *ClusterRequestProcess) Run(ctx context.Context) {
 		}
 		sklog.Infof("Next dataframe: %d traces", len(df.TraceSet))
 		before := len(df.TraceSet)
+		turn := turningPoint(p.request.Algo, len(df.Header), p.request.Radius)
 		// Filter out Traces with insufficient data. I.e. we need 50% or more data
 		// on either side of the target commit.
-		df.FilterOut(tooMuchMissingData)
+		if isBaselineAlgo(p.request.Algo) {
+			df.FilterOut(tooMuchMissingBaselineData(turn))
+		} else {
+			df.FilterOut(tooMuchMissingData)
+		}
 		after := len(df.TraceSet)
 		sklog.Infof("Filtered Traces: %d %d %d", before, after, before-after)
 
@@ -369,7 +441,16 @@ func (p *ClusterRequestProcess) Run(ctx context.Context) {
 			summary, err = StepFit(df, k, config.MIN_STDDEV, p.clusterProgress, p.request.Interesting)
 		case types.TAIL_ALGO:
 			summary, err = Tail(df, k, config.MIN_STDDEV, p.clusterProgress, p.request.Interesting)
-
+		case types.MANNWHITNEYU_ALGO:
+			summary, err = MannWhitneyU(df, k, config.MIN_STDDEV, p.clusterProgress, p.significance())
+		case types.TTEST_ALGO:
+			summary, err = TTest(df, k, config.MIN_STDDEV, p.clusterProgress, p.significance())
+		case types.MULTISTEP_ALGO:
+			summary, err = MultiStepFit(df, k, config.MIN_STDDEV, p.clusterProgress, p.request.Interesting)
+		case types.MEDIANMAD_ALGO:
+			summary, err = BaselineFit(df, k, config.MIN_STDDEV, p.clusterProgress, stepfit.MedianMAD, p.band(), turn)
+		case types.EWMA_ALGO:
+			summary, err = BaselineFit(df, k, config.MIN_STDDEV, p.clusterProgress, stepfit.EWMA, p.band(), turn)
 		}
 		if err != nil {
 			p.reportError(err, "Invalid clustering.")
@@ -379,9 +460,10 @@ func (p *ClusterRequestProcess) Run(ctx context.Context) {
 			p.reportError(err, "Failed to write shortcut for keys.")
 			return
 		}
+		setPrevStepPoints(df, summary)
 
 		df.TraceSet = types.TraceSet{}
-		frame, err := dataframe.ResponseFromDataFrame(ctx, df, p.git, false, p.request.TZ)
+		resps, err := p.responses(ctx, df, summary)
 		if err != nil {
 			p.reportError(err, "Failed to convert DataFrame to FrameResponse.")
 			return
@@ -390,12 +472,8 @@ func (p *ClusterRequestProcess) Run(ctx context.Context) {
 		p.mutex.Lock()
 		p.state = PROCESS_SUCCESS
 		p.message = ""
-		cr := &ClusterResponse{
-			Summary: summary,
-			Frame:   frame,
-		}
-		p.clusterResponseProcessor([]*ClusterResponse{cr})
-		p.response = append(p.response, cr)
+		p.clusterResponseProcessor(resps)
+		p.response = append(p.response, resps...)
 		p.mutex.Unlock()
 	}
 }
@@ -0,0 +1,102 @@
+package regression
+
+import (
+	"time"
+
+	"go.skia.org/infra/go/vec32"
+	"go.skia.org/infra/perf/go/clustering2"
+	"go.skia.org/infra/perf/go/dataframe"
+	"go.skia.org/infra/perf/go/stepfit"
+	"go.skia.org/infra/perf/go/types"
+)
+
+const (
+	// DEFAULT_BASELINE_WINDOW is the number of commits of history used to
+	// build a baseline if the request doesn't specify one.
+	DEFAULT_BASELINE_WINDOW = 100
+
+	// MIN_SEASONAL_SAMPLES is the minimum number of commits in the history
+	// that land on the same day of the week as the commit being tested that
+	// are needed before only those commits are used to build the baseline.
+	MIN_SEASONAL_SAMPLES = 10
+)
+
+// isBaselineAlgo returns true if the algo compares each commit against a
+// baseline built from the history before it, as opposed to looking at a
+// window centered on the commit.
+func isBaselineAlgo(algo types.ClusterAlgo) bool {
+	return algo == types.MEDIANMAD_ALGO || algo == types.EWMA_ALGO
+}
+
+// baselineWindow returns the number of commits of history to use to build a
+// baseline for the given request.
+func baselineWindow(req *ClusterRequest) int {
+	if req.BaselineWindow <= 0 {
+		return DEFAULT_BASELINE_WINDOW
+	}
+	return req.BaselineWindow
+}
+
+// turningPoint returns the index of the commit being tested for a regression
+// in a DataFrame with 'headerLength' commits.
+//
+// For most algorithms that's the midpoint, but the baseline algorithms look
+// at history before the commit and only 'radius' commits after it.
+func turningPoint(algo types.ClusterAlgo, headerLength, radius int) int {
+	if isBaselineAlgo(algo) && radius < headerLength {
+		return headerLength - 1 - radius
+	}
+	return headerLength / 2
+}
+
+// tooMuchMissingBaselineData returns a dataframe.TraceFilter that removes
+// traces that have no value at the commit being tested, or >50% missing data
+// after it. Missing data in the history is handled by
+// stepfit.GetBaselineFit.
+func tooMuchMissingBaselineData(turn int) dataframe.TraceFilter {
+	return func(tr types.Trace) bool {
+		return tr[turn] == vec32.MISSING_DATA_SENTINEL || missing(tr[turn:])
+	}
+}
+
+// seasonalHistory returns the indices of the commits before 'turn' to build
+// the baseline from.
+//
+// The noise in timing benchmarks often depends on the load on the bots, which
+// varies with the day of the week, so if there is enough history then only
+// the commits that landed on the same day of the week as the commit at
+// 'turn' are used. Otherwise all the commits before 'turn' are used.
+func seasonalHistory(header []*dataframe.ColumnHeader, turn int) []int {
+	all := []int{}
+	sameDay := []int{}
+	weekday := time.Unix(header[turn].Timestamp, 0).UTC().Weekday()
+	for i := 0; i < turn; i++ {
+		all = append(all, i)
+		if time.Unix(header[i].Timestamp, 0).UTC().Weekday() == weekday {
+			sameDay = append(sameDay, i)
+		}
+	}
+	if len(sameDay) >= MIN_SEASONAL_SAMPLES {
+		return sameDay
+	}
+	return all
+}
+
+// BaselineFit finds regressions by comparing each trace individually against
+// a baseline built from its own history, using the given stepfit.Baseline.
+//
+// The commit being tested is at index 'turn' of the DataFrame, and a trace is
+// a regression if the values from 'turn' on move more than 'band' times the
+// spread of the baseline away from it. See stepfit.GetBaselineFit.
+func BaselineFit(df *dataframe.DataFrame, k int, stddevThreshold float32, progress clustering2.Progress, baseline stepfit.Baseline, band float32, turn int) (*clustering2.ClusterSummaries, error) {
+	indices := seasonalHistory(df.Header, turn)
+	history := make([]float32, len(indices))
+	return stepFitEach(df, k, stddevThreshold, func(trace []float32) *stepfit.StepFit {
+		for i, index := range indices {
+			history[i] = trace[index]
+		}
+		sf := stepfit.GetBaselineFit(history, trace[turn:], baseline, band)
+		sf.TurningPoint = turn
+		return sf
+	})
+}
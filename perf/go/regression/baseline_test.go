@@ -0,0 +1,84 @@
+package regression
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.skia.org/infra/go/paramtools"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/vec32"
+	"go.skia.org/infra/perf/go/dataframe"
+	"go.skia.org/infra/perf/go/stepfit"
+	"go.skia.org/infra/perf/go/types"
+)
+
+// headers returns n ColumnHeaders, one every 'step' starting at 'start'.
+func headers(start time.Time, step time.Duration, n int) []*dataframe.ColumnHeader {
+	ret := []*dataframe.ColumnHeader{}
+	for i := 0; i < n; i++ {
+		ret = append(ret, &dataframe.ColumnHeader{
+			Source:    "master",
+			Offset:    int64(i),
+			Timestamp: start.Add(time.Duration(i) * step).Unix(),
+		})
+	}
+	return ret
+}
+
+func TestTurningPoint(t *testing.T) {
+	testutils.SmallTest(t)
+
+	assert.Equal(t, 5, turningPoint(types.STEPFIT_ALGO, 11, 5))
+	assert.Equal(t, 5, turningPoint(types.MEDIANMAD_ALGO, 11, 5))
+	assert.Equal(t, 100, turningPoint(types.EWMA_ALGO, 103, 2))
+	assert.Equal(t, 1, turningPoint(types.EWMA_ALGO, 3, 5))
+}
+
+func TestSeasonalHistory(t *testing.T) {
+	testutils.SmallTest(t)
+
+	// A Monday.
+	start := time.Date(2018, time.May, 7, 12, 0, 0, 0, time.UTC)
+
+	// Not enough history on the same day of the week, so use everything.
+	h := headers(start, 24*time.Hour, 15)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, seasonalHistory(h, 13))
+
+	// Enough history, so only use the same day of the week.
+	h = headers(start, 24*time.Hour, 7*MIN_SEASONAL_SAMPLES+1)
+	got := seasonalHistory(h, 7*MIN_SEASONAL_SAMPLES)
+	assert.Len(t, got, MIN_SEASONAL_SAMPLES)
+	for _, i := range got {
+		assert.Equal(t, 0, i%7)
+	}
+}
+
+func TestBaselineFit(t *testing.T) {
+	testutils.SmallTest(t)
+
+	e := vec32.MISSING_DATA_SENTINEL
+	df := &dataframe.DataFrame{
+		TraceSet: types.TraceSet{
+			",arch=x86,config=8888,": []float32{10, 11, 9, 10, 12, 8, 10, 20, 21, 20},
+			",arch=x86,config=565,":  []float32{10, 11, 9, 10, 12, 8, 10, 10, 11, 9},
+			",arch=arm,config=8888,": []float32{10, 11, 9, e, 12, 8, 10, 1, 1, 2},
+			",arch=arm,config=565,":  []float32{10, 11, 9, 10, 12, 8, 10, 30, 10, 11},
+		},
+		Header:   headers(time.Now(), time.Minute, 10),
+		ParamSet: paramtools.ParamSet{},
+	}
+	summary, err := BaselineFit(df, 4, 0.001, nil, stepfit.MedianMAD, stepfit.DEFAULT_BAND, 7)
+	assert.NoError(t, err)
+	assert.Len(t, summary.Clusters, 2)
+	for _, cl := range summary.Clusters {
+		assert.Equal(t, 1, cl.Num)
+		assert.Equal(t, int64(7), cl.StepPoint.Offset)
+		if cl.StepFit.Status == stepfit.HIGH {
+			assert.Equal(t, []string{",arch=x86,config=8888,"}, cl.Keys)
+		} else {
+			assert.Equal(t, stepfit.LOW, cl.StepFit.Status)
+			assert.Equal(t, []string{",arch=arm,config=8888,"}, cl.Keys)
+		}
+	}
+}
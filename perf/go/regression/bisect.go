@@ -0,0 +1,107 @@
+package regression
+
+import (
+	"context"
+	"fmt"
+
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/vec32"
+	"go.skia.org/infra/perf/go/alerts"
+	"go.skia.org/infra/perf/go/bisect"
+	"go.skia.org/infra/perf/go/cid"
+	"go.skia.org/infra/perf/go/clustering2"
+	"go.skia.org/infra/perf/go/dataframe"
+)
+
+const (
+	// MAX_BISECT_COMMITS is the maximum number of missing commits listed in
+	// a bisect.Request.
+	MAX_BISECT_COMMITS = 200
+)
+
+// prevWithData returns the index of the last column before 'index' where any
+// of the traces in 'keys' have data, or -1 if there is no such column.
+func prevWithData(df *dataframe.DataFrame, keys []string, index int) int {
+	for i := index - 1; i >= 0; i-- {
+		for _, key := range keys {
+			if tr, ok := df.TraceSet[key]; ok && tr[i] != vec32.MISSING_DATA_SENTINEL {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// setPrevStepPoints fills in PrevStepPoint for each cluster in the summary.
+//
+// This needs to be done before the TraceSet of the DataFrame is cleared.
+func setPrevStepPoints(df *dataframe.DataFrame, summary *clustering2.ClusterSummaries) {
+	for _, cl := range summary.Clusters {
+		if cl.StepPoint == nil {
+			continue
+		}
+		for index, h := range df.Header {
+			if h.Source != cl.StepPoint.Source || h.Offset != cl.StepPoint.Offset {
+				continue
+			}
+			if prev := prevWithData(df, cl.Keys, index); prev != -1 {
+				cl.PrevStepPoint = df.Header[prev]
+			}
+			break
+		}
+	}
+}
+
+// Ambiguous returns true if the step in the cluster could have been caused by
+// more than one commit, i.e. there are commits with no data between the
+// PrevStepPoint and the StepPoint.
+func Ambiguous(cl *clustering2.ClusterSummary) bool {
+	return cl.StepPoint != nil && cl.PrevStepPoint != nil && cl.StepPoint.Offset-cl.PrevStepPoint.Offset > 1
+}
+
+// BisectRequest returns a bisect.Request for the commits that could have
+// caused the step in the cluster, or nil if the step is not Ambiguous.
+func BisectRequest(ctx context.Context, cidl *cid.CommitIDLookup, cfg *alerts.Config, clusterType string, cl *clustering2.ClusterSummary) (*bisect.Request, error) {
+	if !Ambiguous(cl) {
+		return nil, nil
+	}
+	ids := []*cid.CommitID{
+		{Source: cl.PrevStepPoint.Source, Offset: int(cl.PrevStepPoint.Offset)},
+		{Source: cl.StepPoint.Source, Offset: int(cl.StepPoint.Offset)},
+	}
+	for offset := cl.PrevStepPoint.Offset + 1; offset < cl.StepPoint.Offset && len(ids) < MAX_BISECT_COMMITS+2; offset++ {
+		ids = append(ids, &cid.CommitID{Source: cl.StepPoint.Source, Offset: int(offset)})
+	}
+	details, err := cidl.Lookup(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to look up commits to bisect: %s", err)
+	}
+	return &bisect.Request{
+		AlertID:     cfg.ID,
+		Query:       cfg.Query,
+		ClusterType: clusterType,
+		Shortcut:    cl.Shortcut,
+		Begin:       details[0],
+		End:         details[1],
+		Missing:     details[2:],
+	}, nil
+}
+
+// publishBisect publishes a bisect.Request for the cluster if the step in it
+// is Ambiguous.
+func (c *Continuous) publishBisect(ctx context.Context, cfg *alerts.Config, clusterType string, cl *clustering2.ClusterSummary) {
+	if c.bisector == nil {
+		return
+	}
+	req, err := BisectRequest(ctx, c.cidl, cfg, clusterType, cl)
+	if err != nil {
+		sklog.Errorf("Failed to build bisect request: %s", err)
+		return
+	}
+	if req == nil {
+		return
+	}
+	if err := c.bisector.Publish(ctx, req); err != nil {
+		sklog.Errorf("Failed to publish bisect request: %s", err)
+	}
+}
@@ -0,0 +1,57 @@
+package regression
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/vec32"
+	"go.skia.org/infra/perf/go/clustering2"
+	"go.skia.org/infra/perf/go/dataframe"
+	"go.skia.org/infra/perf/go/types"
+)
+
+func TestSetPrevStepPoints(t *testing.T) {
+	testutils.SmallTest(t)
+
+	e := vec32.MISSING_DATA_SENTINEL
+	df := &dataframe.DataFrame{
+		Header: []*dataframe.ColumnHeader{
+			{Source: "master", Offset: 10},
+			{Source: "master", Offset: 11},
+			{Source: "master", Offset: 12},
+			{Source: "master", Offset: 13},
+			{Source: "master", Offset: 14},
+		},
+		TraceSet: types.TraceSet{
+			",config=8888,": []float32{1, e, e, 2, 2},
+			",config=565,":  []float32{1, e, e, e, 2},
+			",config=gpu,":  []float32{1, 1, 1, 2, 2},
+		},
+	}
+	sparse := clustering2.NewClusterSummary()
+	sparse.Keys = []string{",config=8888,", ",config=565,"}
+	sparse.StepPoint = df.Header[3]
+
+	dense := clustering2.NewClusterSummary()
+	dense.Keys = []string{",config=gpu,"}
+	dense.StepPoint = df.Header[3]
+
+	first := clustering2.NewClusterSummary()
+	first.Keys = []string{",config=gpu,"}
+	first.StepPoint = df.Header[0]
+
+	summary := &clustering2.ClusterSummaries{
+		Clusters: []*clustering2.ClusterSummary{sparse, dense, first},
+	}
+	setPrevStepPoints(df, summary)
+
+	assert.Equal(t, df.Header[0], sparse.PrevStepPoint)
+	assert.True(t, Ambiguous(sparse))
+
+	assert.Equal(t, df.Header[2], dense.PrevStepPoint)
+	assert.False(t, Ambiguous(dense))
+
+	assert.Nil(t, first.PrevStepPoint)
+	assert.False(t, Ambiguous(first))
+}
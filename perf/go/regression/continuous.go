@@ -10,6 +10,7 @@ import (
 	"go.skia.org/infra/go/paramtools"
 	"go.skia.org/infra/go/sklog"
 	"go.skia.org/infra/perf/go/alerts"
+	"go.skia.org/infra/perf/go/bisect"
 	"go.skia.org/infra/perf/go/cid"
 	"go.skia.org/infra/perf/go/dataframe"
 	"go.skia.org/infra/perf/go/notify"
@@ -40,6 +41,11 @@ type Continuous struct {
 	notifier       *notify.Notifier
 	paramsProvider ParamsetProvider
 	dfBuilder      dataframe.DataFrameBuilder
+	bisector       bisect.Publisher // Can be nil.
+
+	// lastRecoveryCheck is the hash of the most recent commit when
+	// regressions were last checked for recovery.
+	lastRecoveryCheck string
 
 	mutex   sync.Mutex // Protects current.
 	current *Current
@@ -50,7 +56,8 @@ type Continuous struct {
 //   provider - Produces the slice of alerts.Config's that determine the clustering to perform.
 //   numCommits - The number of commits to run the clustering over.
 //   radius - The number of commits on each side of a commit to include when clustering.
-func NewContinuous(git *gitinfo.GitInfo, cidl *cid.CommitIDLookup, provider ConfigProvider, store *Store, numCommits int, radius int, notifier *notify.Notifier, paramsProvider ParamsetProvider, dfBuilder dataframe.DataFrameBuilder) *Continuous {
+//   bisector - Publishes bisect requests for regressions that span more than one commit. Can be nil.
+func NewContinuous(git *gitinfo.GitInfo, cidl *cid.CommitIDLookup, provider ConfigProvider, store *Store, numCommits int, radius int, notifier *notify.Notifier, paramsProvider ParamsetProvider, dfBuilder dataframe.DataFrameBuilder, bisector bisect.Publisher) *Continuous {
 	return &Continuous{
 		git:            git,
 		cidl:           cidl,
@@ -62,6 +69,7 @@ func NewContinuous(git *gitinfo.GitInfo, cidl *cid.CommitIDLookup, provider Conf
 		current:        &Current{},
 		paramsProvider: paramsProvider,
 		dfBuilder:      dfBuilder,
+		bisector:       bisector,
 	}
 }
 
@@ -91,48 +99,53 @@ func (c *Continuous) reportRegressions(ctx context.Context, resps []*ClusterResp
 	key := cfg.IdAsString()
 	for _, resp := range resps {
 		headerLength := len(resp.Frame.DataFrame.Header)
-		midPoint := headerLength / 2
+		midPoint := turningPoint(cfg.Algo, headerLength, cfg.Radius)
 
 		midOffset := resp.Frame.DataFrame.Header[midPoint].Offset
 
-		id := &cid.CommitID{
-			Source: "master",
-			Offset: int(midOffset),
-		}
-
-		details, err := c.cidl.Lookup(ctx, []*cid.CommitID{id})
-		if err != nil {
-			sklog.Errorf("Failed to look up commit %v: %s", *id, err)
-			continue
-		}
 		for _, cl := range resp.Summary.Clusters {
-			// Update database if regression at the midpoint is found.
-			if cl.StepPoint.Offset == midOffset {
-				if cl.StepFit.Status == stepfit.LOW && len(cl.Keys) >= cfg.MinimumNum && (cfg.Direction == alerts.DOWN || cfg.Direction == alerts.BOTH) {
-					sklog.Infof("Found Low regression at %s: %v", details[0].Message, *cl.StepFit)
-					isNew, err := c.store.SetLow(details[0], key, resp.Frame, cl)
-					if err != nil {
-						sklog.Errorf("Failed to save newly found cluster: %s", err)
-						continue
-					}
-					if isNew {
-						if err := c.notifier.Send(details[0], cfg, cl); err != nil {
-							sklog.Errorf("Failed to send notification: %s", err)
-						}
-					}
+			// Update database if regression at the midpoint is found. The
+			// Frames of the MULTISTEP_ALGO are centered on the step of their
+			// cluster, so each step is attributed to the commit it steps at.
+			if cl.StepPoint.Offset != midOffset {
+				continue
+			}
+			id := &cid.CommitID{
+				Source: "master",
+				Offset: int(cl.StepPoint.Offset),
+			}
+
+			details, err := c.cidl.Lookup(ctx, []*cid.CommitID{id})
+			if err != nil {
+				sklog.Errorf("Failed to look up commit %v: %s", *id, err)
+				continue
+			}
+			if cl.StepFit.Status == stepfit.LOW && len(cl.Keys) >= cfg.MinimumNum && (cfg.Direction == alerts.DOWN || cfg.Direction == alerts.BOTH) {
+				sklog.Infof("Found Low regression at %s: %v", details[0].Message, *cl.StepFit)
+				isNew, err := c.store.SetLow(details[0], key, resp.Frame, cl)
+				if err != nil {
+					sklog.Errorf("Failed to save newly found cluster: %s", err)
+					continue
 				}
-				if cl.StepFit.Status == stepfit.HIGH && len(cl.Keys) >= cfg.MinimumNum && (cfg.Direction == alerts.UP || cfg.Direction == alerts.BOTH) {
-					sklog.Infof("Found High regression at %s: %v", id.ID(), *cl.StepFit)
-					isNew, err := c.store.SetHigh(details[0], key, resp.Frame, cl)
-					if err != nil {
-						sklog.Errorf("Failed to save newly found cluster: %s", err)
-						continue
+				if isNew {
+					if err := c.notifier.Send(details[0], cfg, cl); err != nil {
+						sklog.Errorf("Failed to send notification: %s", err)
 					}
-					if isNew {
-						if err := c.notifier.Send(details[0], cfg, cl); err != nil {
-							sklog.Errorf("Failed to send notification: %s", err)
-						}
+					c.publishBisect(ctx, cfg, LOW_CLUSTER, cl)
+				}
+			}
+			if cl.StepFit.Status == stepfit.HIGH && len(cl.Keys) >= cfg.MinimumNum && (cfg.Direction == alerts.UP || cfg.Direction == alerts.BOTH) {
+				sklog.Infof("Found High regression at %s: %v", id.ID(), *cl.StepFit)
+				isNew, err := c.store.SetHigh(details[0], key, resp.Frame, cl)
+				if err != nil {
+					sklog.Errorf("Failed to save newly found cluster: %s", err)
+					continue
+				}
+				if isNew {
+					if err := c.notifier.Send(details[0], cfg, cl); err != nil {
+						sklog.Errorf("Failed to send notification: %s", err)
 					}
+					c.publishBisect(ctx, cfg, HIGH_CLUSTER, cl)
 				}
 			}
 		}
@@ -172,6 +185,7 @@ func (c *Continuous) Run(ctx context.Context) {
 			}
 			RegressionsForAlert(ctx, cfg, c.paramsProvider(), clusterResponseProcessor, c.numCommits, time.Now(), c.git, c.cidl, c.dfBuilder)
 		}
+		c.checkRecoveries(ctx)
 		clusteringLatency.Stop()
 		runsCounter.Inc(1)
 	}
@@ -8,6 +8,7 @@ import (
 
 	"cloud.google.com/go/datastore"
 	"go.skia.org/infra/go/ds"
+	"go.skia.org/infra/go/sklog"
 	"go.skia.org/infra/perf/go/cid"
 	"go.skia.org/infra/perf/go/clustering2"
 	"go.skia.org/infra/perf/go/dataframe"
@@ -160,6 +161,11 @@ func (s *Store) SetHigh(cid *cid.CommitDetail, alertID string, df *dataframe.Fra
 		isNew = r.SetHigh(alertID, df, high)
 		return s.store_ds(tx, cid, r)
 	})
+	if err == nil && isNew {
+		if err := s.AddHistory(newHistoryEntry(cid, alertID, HIGH_CLUSTER, FOUND_EVENT, TriageStatus{Status: UNTRIAGED})); err != nil {
+			sklog.Errorf("Failed to record regression history: %s", err)
+		}
+	}
 	return isNew, err
 }
 
@@ -176,6 +182,11 @@ func (s *Store) SetLow(cid *cid.CommitDetail, alertID string, df *dataframe.Fram
 		isNew = r.SetLow(alertID, df, low)
 		return s.store_ds(tx, cid, r)
 	})
+	if err == nil && isNew {
+		if err := s.AddHistory(newHistoryEntry(cid, alertID, LOW_CLUSTER, FOUND_EVENT, TriageStatus{Status: UNTRIAGED})); err != nil {
+			sklog.Errorf("Failed to record regression history: %s", err)
+		}
+	}
 	return isNew, err
 }
 
@@ -208,3 +219,40 @@ func (s *Store) TriageHigh(cid *cid.CommitDetail, alertID string, tr TriageStatu
 	})
 	return err
 }
+
+// RecoverLow marks the low cluster at the given commit and alertID as
+// RECOVERED at the commit 'recovery'.
+func (s *Store) RecoverLow(cid *cid.CommitDetail, alertID string, recovery *cid.CommitDetail) error {
+	return s.recover(cid, alertID, LOW_CLUSTER, recovery)
+}
+
+// RecoverHigh marks the high cluster at the given commit and alertID as
+// RECOVERED at the commit 'recovery'.
+func (s *Store) RecoverHigh(cid *cid.CommitDetail, alertID string, recovery *cid.CommitDetail) error {
+	return s.recover(cid, alertID, HIGH_CLUSTER, recovery)
+}
+
+// recover does the work of RecoverLow and RecoverHigh.
+func (s *Store) recover(cid *cid.CommitDetail, alertID, clusterType string, recovery *cid.CommitDetail) error {
+	_, err := ds.DS.RunInTransaction(context.TODO(), func(tx *datastore.Transaction) error {
+		r, err := s.load_ds(tx, cid)
+		if err != nil {
+			return fmt.Errorf("Failed to load Regressions: %s", err)
+		}
+		if clusterType == LOW_CLUSTER {
+			err = r.RecoverLow(alertID, recovery)
+		} else {
+			err = r.RecoverHigh(alertID, recovery)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to update Regressions: %s", err)
+		}
+		return s.store_ds(tx, cid, r)
+	})
+	if err != nil {
+		return err
+	}
+	entry := newHistoryEntry(cid, alertID, clusterType, RECOVERED_EVENT, TriageStatus{Status: RECOVERED})
+	entry.Recovery = recovery.Hash
+	return s.AddHistory(entry)
+}
@@ -59,13 +59,22 @@ func NewDataFrameIterator(ctx context.Context, progress types.Progress, req *Clu
 	if err != nil {
 		return nil, err
 	}
-	df, err := dfBuilder.NewNFromQuery(ctx, req.End, q, req.N, progress)
+	n := req.N
+	size := req.Radius*2 + 1
+	if isBaselineAlgo(req.Algo) {
+		// The baseline algorithms need history before each commit instead of a
+		// radius, so load enough extra commits to test the same commits as the
+		// other algorithms.
+		size = baselineWindow(req) + req.Radius + 1
+		n += int32(baselineWindow(req) - req.Radius)
+	}
+	df, err := dfBuilder.NewNFromQuery(ctx, req.End, q, n, progress)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to build dataframe iterator: %s", err)
 	}
 	return &dataframeSlicer{
 		df:     df,
-		size:   req.Radius*2 + 1,
+		size:   size,
 		offset: 0,
 	}, nil
 }
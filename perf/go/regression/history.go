@@ -0,0 +1,86 @@
+package regression
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.skia.org/infra/go/ds"
+	"go.skia.org/infra/perf/go/cid"
+	"google.golang.org/api/iterator"
+)
+
+// HistoryEvent is the kind of change recorded in a HistoryEntry.
+type HistoryEvent string
+
+// HistoryEvent constants.
+const (
+	FOUND_EVENT     HistoryEvent = "found"     // A new regression was found.
+	TRIAGED_EVENT   HistoryEvent = "triaged"   // A regression was triaged.
+	RECOVERED_EVENT HistoryEvent = "recovered" // A regression returned to its previous level.
+)
+
+// Cluster types, used in HistoryEntry.ClusterType.
+const (
+	LOW_CLUSTER  = "low"
+	HIGH_CLUSTER = "high"
+)
+
+// HistoryEntry records a single change to a Regression for an alert.
+type HistoryEntry struct {
+	AlertID     string       `json:"alert_id"`
+	TS          int64        `json:"ts"`                                // When the change happened.
+	CommitID    string       `json:"commit_id"    datastore:",noindex"` // The cid.ID() of the commit the regression is at.
+	Hash        string       `json:"hash"         datastore:",noindex"` // The git hash of the commit the regression is at.
+	ClusterType string       `json:"cluster_type" datastore:",noindex"` // LOW_CLUSTER or HIGH_CLUSTER.
+	Event       HistoryEvent `json:"event"        datastore:",noindex"` // What happened.
+	Status      Status       `json:"status"       datastore:",noindex"` // The triage status after the change.
+	Message     string       `json:"message"      datastore:",noindex"` // The triage message after the change.
+	Recovery    string       `json:"recovery"     datastore:",noindex"` // The git hash of the recovering commit for RECOVERED_EVENT.
+	User        string       `json:"user"         datastore:",noindex"` // Who made the change, empty if it was done automatically.
+}
+
+// newHistoryEntry returns a HistoryEntry for a change to the regression at
+// commit 'c'.
+func newHistoryEntry(c *cid.CommitDetail, alertID, clusterType string, event HistoryEvent, status TriageStatus) *HistoryEntry {
+	return &HistoryEntry{
+		AlertID:     alertID,
+		TS:          time.Now().Unix(),
+		CommitID:    c.ID(),
+		Hash:        c.Hash,
+		ClusterType: clusterType,
+		Event:       event,
+		Status:      status.Status,
+		Message:     status.Message,
+	}
+}
+
+// AddHistory records a HistoryEntry.
+func (s *Store) AddHistory(entry *HistoryEntry) error {
+	if entry.TS == 0 {
+		entry.TS = time.Now().Unix()
+	}
+	if _, err := ds.DS.Put(context.TODO(), ds.NewKey(ds.REGRESSION_HISTORY), entry); err != nil {
+		return fmt.Errorf("Failed to store regression history: %s", err)
+	}
+	return nil
+}
+
+// History returns the most recent 'n' HistoryEntry's for the given alert,
+// newest first.
+func (s *Store) History(alertID string, n int) ([]*HistoryEntry, error) {
+	ret := []*HistoryEntry{}
+	q := ds.NewQuery(ds.REGRESSION_HISTORY).Filter("AlertID =", alertID).Order("-TS").Limit(n)
+	it := ds.DS.Run(context.TODO(), q)
+	for {
+		entry := &HistoryEntry{}
+		_, err := it.Next(entry)
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Failed to read regression history: %s", err)
+		}
+		ret = append(ret, entry)
+	}
+	return ret, nil
+}
@@ -0,0 +1,274 @@
+package regression
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/go/vec32"
+	"go.skia.org/infra/perf/go/cid"
+	"go.skia.org/infra/perf/go/clustering2"
+	"go.skia.org/infra/perf/go/config"
+	"go.skia.org/infra/perf/go/dataframe"
+	"go.skia.org/infra/perf/go/shortcut2"
+	"go.skia.org/infra/perf/go/types"
+)
+
+const (
+	// RECOVERY_WINDOW is how far back to look for regressions that may have
+	// recovered.
+	RECOVERY_WINDOW = 28 * 24 * time.Hour
+
+	// RECOVERY_MIN_POINTS is the minimum number of commits with data that must
+	// be at the regressed level, and then back at the previous level, before a
+	// regression is considered recovered.
+	RECOVERY_MIN_POINTS = 5
+
+	// RECOVERY_FRACTION controls how close to the level before the step the
+	// traces need to get to be recovered, as a fraction of the step size.
+	RECOVERY_FRACTION = 0.25
+)
+
+// recoveryPoint looks at a trace that stepped at index 'turn' and returns the
+// index at which it returned to its level before the step, or -1 if it hasn't
+// recovered.
+//
+// The trace is considered recovered at index i if the mean of trace[i:] is
+// within RECOVERY_FRACTION of the step size of the mean of trace[:turn], and
+// there are at least 'minPoints' values in trace[turn:i] and trace[i:]. If
+// there are many such indices then the one that gives the best fit to the
+// three levels is returned.
+func recoveryPoint(trace []float32, turn int, minPoints int) int {
+	if turn <= 0 || turn >= len(trace) {
+		return -1
+	}
+	before := vec32.Mean(trace[:turn])
+	ret := -1
+	bestSSE := float32(math.MaxFloat32)
+	for i := turn + minPoints; i <= len(trace)-minPoints; i++ {
+		regressed := trace[turn:i]
+		recovered := trace[i:]
+		if count(regressed) < minPoints || count(recovered) < minPoints {
+			continue
+		}
+		regressedMean := vec32.Mean(regressed)
+		recoveredMean := vec32.Mean(recovered)
+		stepSize := float32(math.Abs(float64(regressedMean - before)))
+		if stepSize == 0 || float32(math.Abs(float64(recoveredMean-before))) > RECOVERY_FRACTION*stepSize {
+			continue
+		}
+		sse := vec32.SSE(regressed, regressedMean) + vec32.SSE(recovered, recoveredMean)
+		if sse < bestSSE {
+			bestSSE = sse
+			ret = i
+		}
+	}
+	return ret
+}
+
+// count returns the number of non-missing values in the trace.
+func count(trace []float32) int {
+	ret := 0
+	for _, x := range trace {
+		if x != vec32.MISSING_DATA_SENTINEL {
+			ret++
+		}
+	}
+	return ret
+}
+
+// meanTrace returns a trace where each point is the mean of all the
+// non-missing values in the TraceSet at that point. Each trace is normalized
+// first, as is done for clustering, so that traces with large values don't
+// dominate the mean.
+func meanTrace(traceSet types.TraceSet, traceLen int, minStdDev float32) types.Trace {
+	normalized := make([]types.Trace, 0, len(traceSet))
+	for _, tr := range traceSet {
+		norm := vec32.Dup(tr[:traceLen])
+		vec32.Norm(norm, minStdDev)
+		normalized = append(normalized, norm)
+	}
+	ret := types.NewTrace(traceLen)
+	column := make([]float32, 0, len(normalized))
+	for i := 0; i < traceLen; i++ {
+		column = column[:0]
+		for _, tr := range normalized {
+			if tr[i] != vec32.MISSING_DATA_SENTINEL {
+				column = append(column, tr[i])
+			}
+		}
+		if len(column) > 0 {
+			ret[i] = vec32.Mean(column)
+		}
+	}
+	return ret
+}
+
+// recoveryCheck is a regression cluster which may have recovered.
+type recoveryCheck struct {
+	id      string // The id of the commit the regression was found at.
+	alertID string
+	high    bool
+	cl      *clustering2.ClusterSummary
+	keys    []string
+	begin   int64 // Timestamp of the first commit of the frame the cluster was found in.
+}
+
+// newRecoveryCheck returns a recoveryCheck for the given cluster, which was
+// found in the given frame, or nil if the cluster can't be checked.
+func newRecoveryCheck(id, alertID string, high bool, frame *dataframe.FrameResponse, cl *clustering2.ClusterSummary) (*recoveryCheck, error) {
+	if cl.Shortcut == "" || cl.StepPoint == nil || frame == nil || frame.DataFrame == nil || len(frame.DataFrame.Header) == 0 {
+		return nil, nil
+	}
+	shortcut, err := shortcut2.Get(cl.Shortcut)
+	if err != nil {
+		return nil, err
+	}
+	return &recoveryCheck{
+		id:      id,
+		alertID: alertID,
+		high:    high,
+		cl:      cl,
+		keys:    shortcut.Keys,
+		begin:   frame.DataFrame.Header[0].Timestamp,
+	}, nil
+}
+
+// recoveryIndex returns the index into df.Header of the commit at which the
+// traces of the given recoveryCheck returned to their level before the step,
+// or -1 if they haven't recovered. The DataFrame may contain other traces and
+// start before the frame the cluster was found in.
+func recoveryIndex(df *dataframe.DataFrame, chk *recoveryCheck, minStdDev float32) int {
+	start := -1
+	turn := -1
+	for i, h := range df.Header {
+		if start == -1 && h.Timestamp >= chk.begin {
+			start = i
+		}
+		if start != -1 && h.Offset >= chk.cl.StepPoint.Offset {
+			turn = i
+			break
+		}
+	}
+	if turn == -1 {
+		return -1
+	}
+	traceSet := types.TraceSet{}
+	for _, key := range chk.keys {
+		if tr, ok := df.TraceSet[key]; ok {
+			traceSet[key] = tr[start:]
+		}
+	}
+	if len(traceSet) == 0 {
+		return -1
+	}
+	i := recoveryPoint(meanTrace(traceSet, len(df.Header)-start, minStdDev), turn-start, RECOVERY_MIN_POINTS)
+	if i == -1 {
+		return -1
+	}
+	return start + i
+}
+
+// checkRecoveries re-evaluates the untriaged and positively triaged
+// regressions found in the last RECOVERY_WINDOW against newer data, and marks
+// the ones that have returned to their previous level as RECOVERED.
+//
+// The regressions are only re-evaluated when new commits have arrived, and the
+// data for all of them is loaded in a single query.
+func (c *Continuous) checkRecoveries(ctx context.Context) {
+	latest := c.git.LastNIndex(1)
+	if len(latest) == 0 || latest[0].Hash == c.lastRecoveryCheck {
+		return
+	}
+	end := time.Now()
+	regMap, err := c.store.Range(end.Add(-RECOVERY_WINDOW).Unix(), end.Unix())
+	if err != nil {
+		sklog.Errorf("Failed to load regressions to check for recovery: %s", err)
+		return
+	}
+	checks := []*recoveryCheck{}
+	keys := util.StringSet{}
+	begin := end.Unix()
+	add := func(id, alertID string, high bool, frame *dataframe.FrameResponse, cl *clustering2.ClusterSummary) {
+		chk, err := newRecoveryCheck(id, alertID, high, frame, cl)
+		if err != nil {
+			sklog.Warningf("Failed to check for recovery of %q at %q: %s", alertID, id, err)
+			return
+		} else if chk == nil {
+			return
+		}
+		checks = append(checks, chk)
+		keys.AddLists(chk.keys)
+		if chk.begin < begin {
+			begin = chk.begin
+		}
+	}
+	for id, regs := range regMap {
+		for alertID, reg := range regs.ByAlertID {
+			if reg.Low != nil && Recoverable(reg.LowStatus.Status) {
+				add(id, alertID, false, reg.Frame, reg.Low)
+			}
+			if reg.High != nil && Recoverable(reg.HighStatus.Status) {
+				add(id, alertID, true, reg.Frame, reg.High)
+			}
+		}
+	}
+	if len(checks) > 0 {
+		df, err := c.dfBuilder.NewFromKeysAndRange(keys.Keys(), time.Unix(begin, 0), end, false, nil)
+		if err != nil {
+			sklog.Errorf("Failed to load data to check for recovery: %s", err)
+			return
+		}
+		c.recoverAll(ctx, df, checks)
+	}
+	c.lastRecoveryCheck = latest[0].Hash
+}
+
+// recoverAll marks the regressions of the given recoveryChecks which have
+// recovered in the given DataFrame as RECOVERED.
+func (c *Continuous) recoverAll(ctx context.Context, df *dataframe.DataFrame, checks []*recoveryCheck) {
+	details := map[string]*cid.CommitDetail{}
+	for _, chk := range checks {
+		i := recoveryIndex(df, chk, config.MIN_STDDEV)
+		if i == -1 {
+			continue
+		}
+		d, ok := details[chk.id]
+		if !ok {
+			commitID, err := cid.FromID(chk.id)
+			if err != nil {
+				sklog.Errorf("Found an invalid commit id %q: %s", chk.id, err)
+				continue
+			}
+			lookup, err := c.cidl.Lookup(ctx, []*cid.CommitID{commitID})
+			if err != nil {
+				sklog.Errorf("Failed to look up commit %q: %s", chk.id, err)
+				continue
+			}
+			d = lookup[0]
+			details[chk.id] = d
+		}
+		recovery, err := c.cidl.Lookup(ctx, []*cid.CommitID{
+			{
+				Source: df.Header[i].Source,
+				Offset: int(df.Header[i].Offset),
+			},
+		})
+		if err != nil {
+			sklog.Errorf("Failed to look up recovering commit: %s", err)
+			continue
+		}
+		if chk.high {
+			sklog.Infof("High regression for %q at %q recovered at %q", chk.alertID, chk.id, recovery[0].Hash)
+			err = c.store.RecoverHigh(d, chk.alertID, recovery[0])
+		} else {
+			sklog.Infof("Low regression for %q at %q recovered at %q", chk.alertID, chk.id, recovery[0].Hash)
+			err = c.store.RecoverLow(d, chk.alertID, recovery[0])
+		}
+		if err != nil {
+			sklog.Errorf("Failed to mark regression as recovered: %s", err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package regression
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/vec32"
+	"go.skia.org/infra/perf/go/clustering2"
+	"go.skia.org/infra/perf/go/dataframe"
+	"go.skia.org/infra/perf/go/types"
+)
+
+func TestRecoveryPoint(t *testing.T) {
+	testutils.SmallTest(t)
+
+	e := vec32.MISSING_DATA_SENTINEL
+	testCases := []struct {
+		value    []float32
+		turn     int
+		expected int
+		message  string
+	}{
+		{
+			value:    []float32{1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1},
+			turn:     5,
+			expected: 10,
+			message:  "Simple recovery.",
+		},
+		{
+			value:    []float32{1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2},
+			turn:     5,
+			expected: -1,
+			message:  "No recovery.",
+		},
+		{
+			value:    []float32{1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 3, 3, 3, 3, 3},
+			turn:     5,
+			expected: -1,
+			message:  "Moved further away.",
+		},
+		{
+			value:    []float32{1, 1, 1, 1, 1, 2, 2, 2, 2, 2, 2, 1, 1, 1},
+			turn:     5,
+			expected: -1,
+			message:  "Not enough points after recovery.",
+		},
+		{
+			value:    []float32{1, 1, 1, 1, 1, 2, 2, e, 2, 2, 2, 1.1, e, 1, 1, 1, 1},
+			turn:     5,
+			expected: 11,
+			message:  "Missing data and noise.",
+		},
+		{
+			value:    []float32{1, 1, 1},
+			turn:     0,
+			expected: -1,
+			message:  "Bad turning point.",
+		},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, recoveryPoint(tc.value, tc.turn, 5), tc.message)
+	}
+}
+
+func TestMeanTrace(t *testing.T) {
+	testutils.SmallTest(t)
+
+	e := vec32.MISSING_DATA_SENTINEL
+	traceSet := types.TraceSet{
+		",a=1,": types.Trace{1, 2, e},
+		",a=2,": types.Trace{100, 200, e},
+		",a=3,": types.Trace{3, e, e},
+		",a=4,": types.Trace{5, e, e},
+	}
+	// Traces are normalized before averaging, so ",a=2," doesn't dominate.
+	assert.Equal(t, types.Trace{-0.5, 1, e}, meanTrace(traceSet, 3, 0.001))
+	// The TraceSet is not modified.
+	assert.Equal(t, types.Trace{100, 200, e}, traceSet[",a=2,"])
+}
+
+func TestRecoveryIndex(t *testing.T) {
+	testutils.SmallTest(t)
+
+	header := []*dataframe.ColumnHeader{}
+	for i := 0; i < 16; i++ {
+		header = append(header, &dataframe.ColumnHeader{
+			Source:    "master",
+			Offset:    int64(i),
+			Timestamp: int64(100 + i),
+		})
+	}
+	df := &dataframe.DataFrame{
+		Header: header,
+		TraceSet: types.TraceSet{
+			",a=1,": types.Trace{9, 9, 1, 1, 1, 5, 5, 5, 5, 5, 1, 1, 1, 1, 1, 1},
+			",a=2,": types.Trace{9, 9, 2, 2, 2, 10, 10, 10, 10, 10, 2, 2, 2, 2, 2, 2},
+			",a=3,": types.Trace{9, 9, 1, 1, 1, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+		},
+	}
+	chk := &recoveryCheck{
+		cl: &clustering2.ClusterSummary{
+			StepPoint: &dataframe.ColumnHeader{Source: "master", Offset: 5},
+		},
+		keys:  []string{",a=1,", ",a=2,"},
+		begin: 102,
+	}
+	// The points before 'begin' are ignored.
+	assert.Equal(t, 10, recoveryIndex(df, chk, 0.001))
+
+	// A trace which hasn't recovered.
+	chk.keys = []string{",a=3,"}
+	assert.Equal(t, -1, recoveryIndex(df, chk, 0.001))
+
+	// No data for the cluster.
+	chk.keys = []string{",a=4,"}
+	assert.Equal(t, -1, recoveryIndex(df, chk, 0.001))
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"sync"
 
+	"go.skia.org/infra/perf/go/cid"
 	"go.skia.org/infra/perf/go/clustering2"
 	"go.skia.org/infra/perf/go/dataframe"
 )
@@ -21,6 +22,7 @@ const (
 	POSITIVE  Status = "positive"  // This change in performance is OK/expected.
 	NEGATIVE  Status = "negative"  // This regression is a bug.
 	UNTRIAGED Status = "untriaged" // The regression has not been triaged.
+	RECOVERED Status = "recovered" // The traces returned to their level before the regression.
 )
 
 // Regressions is a map[alertid]Regression and one Regressions is stored for each
@@ -35,6 +37,18 @@ type TriageStatus struct {
 	Message string `json:"message"`
 }
 
+// Recovery records when a regression recovered.
+type Recovery struct {
+	Commit         *cid.CommitDetail `json:"commit"`          // The commit at which the traces returned to their previous level.
+	PreviousStatus TriageStatus      `json:"previous_status"` // The TriageStatus before the regression was marked as RECOVERED.
+}
+
+// Recoverable returns true if a regression with the given Status should be
+// checked to see if it has recovered.
+func Recoverable(s Status) bool {
+	return s == UNTRIAGED || s == POSITIVE
+}
+
 // Regression tracks the status of the Low and High regression clusters, if they
 // exist for a given CommitID and alertid.
 //
@@ -49,6 +63,9 @@ type Regression struct {
 	Frame      *dataframe.FrameResponse    `json:"frame"` // Describes the Low and High ClusterSummary's.
 	LowStatus  TriageStatus                `json:"low_status"`
 	HighStatus TriageStatus                `json:"high_status"`
+
+	LowRecovery  *Recovery `json:"low_recovery,omitempty"`  // Non-nil if the Low regression has recovered.
+	HighRecovery *Recovery `json:"high_recovery,omitempty"` // Non-nil if the High regression has recovered.
 }
 
 func newRegression() *Regression {
@@ -183,6 +200,50 @@ func (r *Regressions) TriageHigh(alertid string, tr TriageStatus) error {
 	return nil
 }
 
+// RecoverLow marks the low cluster as RECOVERED at the given commit.
+func (r *Regressions) RecoverLow(alertid string, c *cid.CommitDetail) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	reg, ok := r.ByAlertID[alertid]
+	if !ok {
+		return ErrNoClusterFound
+	}
+	if reg.Low == nil {
+		return ErrNoClusterFound
+	}
+	reg.LowRecovery = &Recovery{
+		Commit:         c,
+		PreviousStatus: reg.LowStatus,
+	}
+	reg.LowStatus = TriageStatus{
+		Status:  RECOVERED,
+		Message: reg.LowStatus.Message,
+	}
+	return nil
+}
+
+// RecoverHigh marks the high cluster as RECOVERED at the given commit.
+func (r *Regressions) RecoverHigh(alertid string, c *cid.CommitDetail) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	reg, ok := r.ByAlertID[alertid]
+	if !ok {
+		return ErrNoClusterFound
+	}
+	if reg.High == nil {
+		return ErrNoClusterFound
+	}
+	reg.HighRecovery = &Recovery{
+		Commit:         c,
+		PreviousStatus: reg.HighStatus,
+	}
+	reg.HighStatus = TriageStatus{
+		Status:  RECOVERED,
+		Message: reg.HighStatus.Message,
+	}
+	return nil
+}
+
 // Triaged returns true if all clusters are triaged.
 func (r *Regressions) Triaged() bool {
 	ret := true
@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/perf/go/cid"
 	"go.skia.org/infra/perf/go/clustering2"
 	"go.skia.org/infra/perf/go/dataframe"
 	"go.skia.org/infra/perf/go/stepfit"
@@ -59,6 +60,53 @@ func TestRegressions(t *testing.T) {
 	assert.Equal(t, "{\"by_query\":{\"source_type=skp\":{\"low\":{\"centroid\":null,\"shortcut\":\"\",\"param_summaries\":null,\"step_fit\":null,\"step_point\":null,\"num\":0},\"high\":{\"centroid\":null,\"shortcut\":\"\",\"param_summaries\":null,\"step_fit\":null,\"step_point\":null,\"num\":0},\"frame\":{\"dataframe\":null,\"ticks\":null,\"skps\":null,\"msg\":\"\"},\"low_status\":{\"status\":\"positive\",\"message\":\"SKP Update\"},\"high_status\":{\"status\":\"negative\",\"message\":\"See bug #foo.\"}}}}", string(b))
 }
 
+func TestRecover(t *testing.T) {
+	testutils.SmallTest(t)
+	r := New()
+
+	df := &dataframe.FrameResponse{}
+	cl := &clustering2.ClusterSummary{}
+	c := &cid.CommitDetail{
+		CommitID: cid.CommitID{
+			Source: "master",
+			Offset: 10,
+		},
+		Hash: "abc",
+	}
+
+	// Can't recover a cluster that doesn't exist.
+	assert.Equal(t, ErrNoClusterFound, r.RecoverLow("source_type=skp", c))
+
+	r.SetLow("source_type=skp", df, cl)
+	assert.False(t, r.Triaged())
+	assert.Equal(t, ErrNoClusterFound, r.RecoverHigh("source_type=skp", c))
+
+	err := r.RecoverLow("source_type=skp", c)
+	assert.NoError(t, err)
+	assert.True(t, r.Triaged(), "Recovered regressions don't need triage.")
+	reg := r.ByAlertID["source_type=skp"]
+	assert.Equal(t, RECOVERED, reg.LowStatus.Status)
+	assert.Equal(t, c, reg.LowRecovery.Commit)
+	assert.Equal(t, UNTRIAGED, reg.LowRecovery.PreviousStatus.Status)
+	assert.Nil(t, reg.HighRecovery)
+
+	r.SetHigh("source_type=skp", df, cl)
+	err = r.TriageHigh("source_type=skp", TriageStatus{
+		Status:  POSITIVE,
+		Message: "SKP Update",
+	})
+	assert.NoError(t, err)
+	err = r.RecoverHigh("source_type=skp", c)
+	assert.NoError(t, err)
+	assert.Equal(t, TriageStatus{Status: RECOVERED, Message: "SKP Update"}, reg.HighStatus)
+	assert.Equal(t, POSITIVE, reg.HighRecovery.PreviousStatus.Status)
+
+	assert.True(t, Recoverable(UNTRIAGED))
+	assert.True(t, Recoverable(POSITIVE))
+	assert.False(t, Recoverable(NEGATIVE))
+	assert.False(t, Recoverable(RECOVERED))
+}
+
 func TestMerge(t *testing.T) {
 	testutils.SmallTest(t)
 
@@ -1,16 +1,52 @@
 package regression
 
 import (
+	"fmt"
+	"sort"
+
 	"go.skia.org/infra/go/sklog"
 	"go.skia.org/infra/go/vec32"
 	"go.skia.org/infra/perf/go/clustering2"
 	"go.skia.org/infra/perf/go/config"
 	"go.skia.org/infra/perf/go/dataframe"
 	"go.skia.org/infra/perf/go/stepfit"
+	"go.skia.org/infra/perf/go/types"
+)
+
+const (
+	// MIN_MULTISTEP_RADIUS is the smallest number of points on each side of a
+	// candidate turning point that MultiStepFit will fit a step over.
+	MIN_MULTISTEP_RADIUS = 2
 )
 
 // StepFit finds regressions by looking at each trace individually and seeing if that looks like a regression.
 func StepFit(df *dataframe.DataFrame, k int, stddevThreshold float32, progress clustering2.Progress, interesting float32) (*clustering2.ClusterSummaries, error) {
+	return stepFitEach(df, k, stddevThreshold, func(trace []float32) *stepfit.StepFit {
+		return stepfit.GetStepFitAtMid(trace, interesting)
+	})
+}
+
+// MannWhitneyU finds regressions by running a Mann-Whitney U test on each
+// trace individually, where 'significance' is the p-value threshold below
+// which a step is considered a regression.
+func MannWhitneyU(df *dataframe.DataFrame, k int, stddevThreshold float32, progress clustering2.Progress, significance float32) (*clustering2.ClusterSummaries, error) {
+	return stepFitEach(df, k, stddevThreshold, func(trace []float32) *stepfit.StepFit {
+		return stepfit.GetMannWhitneyUAtMid(trace, significance)
+	})
+}
+
+// TTest finds regressions by running Welch's t-test on each trace
+// individually, where 'significance' is the p-value threshold below which a
+// step is considered a regression.
+func TTest(df *dataframe.DataFrame, k int, stddevThreshold float32, progress clustering2.Progress, significance float32) (*clustering2.ClusterSummaries, error) {
+	return stepFitEach(df, k, stddevThreshold, func(trace []float32) *stepfit.StepFit {
+		return stepfit.GetWelchTTestAtMid(trace, significance)
+	})
+}
+
+// stepFitEach normalizes each trace in the DataFrame and passes it to 'fit',
+// gathering the traces that step up and down into the High and Low clusters.
+func stepFitEach(df *dataframe.DataFrame, k int, stddevThreshold float32, fit func(trace []float32) *stepfit.StepFit) (*clustering2.ClusterSummaries, error) {
 	low := clustering2.NewClusterSummary()
 	high := clustering2.NewClusterSummary()
 	// Normalize each trace and then run through stepfit. If interesting then
@@ -23,7 +59,7 @@ func StepFit(df *dataframe.DataFrame, k int, stddevThreshold float32, progress c
 		}
 		t := vec32.Dup(trace)
 		vec32.Norm(t, stddevThreshold)
-		sf := stepfit.GetStepFitAtMid(t, interesting)
+		sf := fit(t)
 
 		isLow := sf.Status == stepfit.LOW
 		isHigh := sf.Status == stepfit.HIGH
@@ -69,3 +105,97 @@ func StepFit(df *dataframe.DataFrame, k int, stddevThreshold float32, progress c
 	}
 	return ret, nil
 }
+
+// MultiStepFit finds regressions by looking for every step in each trace
+// individually, not just a step at the center of the DataFrame.
+//
+// Traces that step in the same direction at the same commit are gathered into
+// a single cluster, so the returned ClusterSummaries may contain many Low and
+// High clusters, each with its own StepPoint.
+func MultiStepFit(df *dataframe.DataFrame, k int, stddevThreshold float32, progress clustering2.Progress, interesting float32) (*clustering2.ClusterSummaries, error) {
+	// The number of points on each side of a candidate turning point to use
+	// when fitting a step.
+	radius := len(df.Header) / 4
+	if radius < MIN_MULTISTEP_RADIUS {
+		radius = MIN_MULTISTEP_RADIUS
+	}
+	lows := map[int]*clustering2.ClusterSummary{}
+	highs := map[int]*clustering2.ClusterSummary{}
+	count := 0
+	for key, trace := range df.TraceSet {
+		count++
+		if count%10000 == 0 {
+			sklog.Infof("multistep count: %d", count)
+		}
+		t := vec32.Dup(trace)
+		vec32.Norm(t, stddevThreshold)
+		for _, sf := range stepfit.FindSteps(t, interesting, radius) {
+			clusters := highs
+			if sf.Status == stepfit.LOW {
+				clusters = lows
+			}
+			cl, ok := clusters[sf.TurningPoint]
+			if !ok {
+				cl = clustering2.NewClusterSummary()
+				cl.StepFit = sf
+				cl.StepPoint = df.Header[sf.TurningPoint]
+				cl.Centroid = vec32.Dup(trace)
+				clusters[sf.TurningPoint] = cl
+			}
+			cl.Num++
+			if cl.Num < config.MAX_SAMPLE_TRACES_PER_CLUSTER {
+				cl.Keys = append(cl.Keys, key)
+			}
+		}
+	}
+	sklog.Infof("Found LOW: %d HIGH: %d steps", len(lows), len(highs))
+	ret := &clustering2.ClusterSummaries{
+		Clusters:        []*clustering2.ClusterSummary{},
+		K:               k,
+		StdDevThreshold: stddevThreshold,
+	}
+	for _, clusters := range []map[int]*clustering2.ClusterSummary{lows, highs} {
+		for _, cl := range clusters {
+			cl.ParamSummaries = clustering2.GetParamSummariesForKeys(cl.Keys)
+			ret.Clusters = append(ret.Clusters, cl)
+		}
+	}
+	sort.Slice(ret.Clusters, func(i, j int) bool {
+		return ret.Clusters[i].StepFit.TurningPoint < ret.Clusters[j].StepFit.TurningPoint
+	})
+	return ret, nil
+}
+
+// stepKey identifies the step of a cluster found by MultiStepFit by its commit
+// and direction.
+func stepKey(cl *clustering2.ClusterSummary) string {
+	return fmt.Sprintf("%s:%d:%s", cl.StepPoint.Source, cl.StepPoint.Offset, cl.StepFit.Status)
+}
+
+// centerOnStep returns a DataFrame with only the columns of df around the
+// StepPoint of the given cluster, so that the StepPoint is at its center, along
+// with a copy of the cluster whose Centroid and StepFit match the returned
+// DataFrame. The ParamSet of df is kept as is.
+func centerOnStep(df *dataframe.DataFrame, cl *clustering2.ClusterSummary) (*dataframe.DataFrame, *clustering2.ClusterSummary) {
+	turn := cl.StepFit.TurningPoint
+	half := turn
+	if n := len(df.Header) - 1 - turn; n < half {
+		half = n
+	}
+	begin, end := turn-half, turn+half+1
+	ret := &dataframe.DataFrame{
+		TraceSet: types.TraceSet{},
+		Header:   df.Header[begin:end],
+		ParamSet: df.ParamSet,
+		Skip:     df.Skip,
+	}
+	for key, tr := range df.TraceSet {
+		ret.TraceSet[key] = tr[begin:end]
+	}
+	centered := *cl
+	centered.Centroid = cl.Centroid[begin:end]
+	sf := *cl.StepFit
+	sf.TurningPoint = half
+	centered.StepFit = &sf
+	return ret, &centered
+}
@@ -10,7 +10,9 @@ import (
 	"go.skia.org/infra/go/ds/testutil"
 	"go.skia.org/infra/go/paramtools"
 	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/perf/go/clustering2"
 	"go.skia.org/infra/perf/go/dataframe"
+	"go.skia.org/infra/perf/go/stepfit"
 	"go.skia.org/infra/perf/go/types"
 )
 
@@ -70,3 +72,97 @@ func TestStepFit(t *testing.T) {
 	assert.Equal(t, df.Header[2], sum.Clusters[0].StepPoint)
 	assert.Equal(t, 2, len(sum.Clusters[0].Keys))
 }
+
+func TestMannWhitneyUAndTTest(t *testing.T) {
+	testutils.SmallTest(t)
+
+	df := &dataframe.DataFrame{
+		TraceSet: types.TraceSet{
+			",arch=x86,config=8888,": []float32{1, 2, 1, 2, 1, 2, 11, 12, 11, 12, 11, 12},
+			",arch=x86,config=565,":  []float32{1, 2, 1, 2, 1, 2, 11, 12, 11, 12, 11, 12},
+			",arch=arm,config=8888,": []float32{1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 2},
+			",arch=arm,config=565,":  []float32{11, 12, 11, 12, 11, 12, 1, 2, 1, 2, 1, 2},
+		},
+		Header:   []*dataframe.ColumnHeader{},
+		ParamSet: paramtools.ParamSet{},
+	}
+	for i := 0; i < 12; i++ {
+		df.Header = append(df.Header, &dataframe.ColumnHeader{
+			Source: "master",
+			Offset: int64(i),
+		})
+	}
+	for key := range df.TraceSet {
+		df.ParamSet.AddParamsFromKey(key)
+	}
+
+	for _, algo := range []func(*dataframe.DataFrame, int, float32, clustering2.Progress, float32) (*clustering2.ClusterSummaries, error){MannWhitneyU, TTest} {
+		sum, err := algo(df, 4, 0.01, nil, 0.05)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(sum.Clusters))
+		for _, cl := range sum.Clusters {
+			assert.Equal(t, df.Header[6], cl.StepPoint)
+			if cl.StepFit.Status == stepfit.HIGH {
+				assert.Equal(t, 2, len(cl.Keys))
+			} else {
+				assert.Equal(t, stepfit.LOW, cl.StepFit.Status)
+				assert.Equal(t, []string{",arch=arm,config=565,"}, cl.Keys)
+			}
+		}
+	}
+}
+
+func TestMultiStepFit(t *testing.T) {
+	testutils.SmallTest(t)
+
+	df := &dataframe.DataFrame{
+		TraceSet: types.TraceSet{
+			",arch=x86,config=8888,": []float32{0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1},
+			",arch=x86,config=565,":  []float32{0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1},
+			",arch=arm,config=8888,": []float32{1, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			",arch=arm,config=565,":  []float32{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+		},
+		Header:   []*dataframe.ColumnHeader{},
+		ParamSet: paramtools.ParamSet{},
+	}
+	for i := 0; i < 12; i++ {
+		df.Header = append(df.Header, &dataframe.ColumnHeader{
+			Source: "master",
+			Offset: int64(i),
+		})
+	}
+	for key := range df.TraceSet {
+		df.ParamSet.AddParamsFromKey(key)
+	}
+
+	sum, err := MultiStepFit(df, 4, 0.01, nil, 50)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(sum.Clusters))
+
+	// The step down is found even though it isn't at the center.
+	assert.Equal(t, df.Header[3], sum.Clusters[0].StepPoint)
+	assert.Equal(t, stepfit.LOW, sum.Clusters[0].StepFit.Status)
+	assert.Equal(t, []string{",arch=arm,config=8888,"}, sum.Clusters[0].Keys)
+
+	assert.Equal(t, df.Header[6], sum.Clusters[1].StepPoint)
+	assert.Equal(t, stepfit.HIGH, sum.Clusters[1].StepFit.Status)
+	assert.Equal(t, 2, len(sum.Clusters[1].Keys))
+	assert.NotEqual(t, stepKey(sum.Clusters[0]), stepKey(sum.Clusters[1]))
+
+	// The DataFrame is centered on each step.
+	centeredDf, centered := centerOnStep(df, sum.Clusters[0])
+	assert.Equal(t, df.Header[0:7], centeredDf.Header)
+	assert.Equal(t, []float32{1, 1, 1, 0, 0, 0, 0}, centeredDf.TraceSet[",arch=arm,config=8888,"])
+	assert.Equal(t, df.ParamSet, centeredDf.ParamSet)
+	assert.Equal(t, 3, centered.StepFit.TurningPoint)
+	assert.Equal(t, []float32{1, 1, 1, 0, 0, 0, 0}, centered.Centroid)
+	assert.Equal(t, sum.Clusters[0].StepPoint, centered.StepPoint)
+	assert.Equal(t, 3, sum.Clusters[0].StepFit.TurningPoint)
+
+	centeredDf, centered = centerOnStep(df, sum.Clusters[1])
+	assert.Equal(t, df.Header[1:12], centeredDf.Header)
+	assert.Equal(t, centeredDf.Header[len(centeredDf.Header)/2], centered.StepPoint)
+	assert.Equal(t, 5, centered.StepFit.TurningPoint)
+	assert.Equal(t, 11, len(centered.Centroid))
+	assert.Equal(t, 6, sum.Clusters[1].StepFit.TurningPoint)
+}
@@ -19,10 +19,13 @@ import (
 
 	"cloud.google.com/go/bigtable"
 	"cloud.google.com/go/datastore"
+	"cloud.google.com/go/pubsub"
 	storage "cloud.google.com/go/storage"
 	"github.com/gorilla/mux"
+	"go.skia.org/infra/go/allowed"
 	"go.skia.org/infra/go/auth"
 	"go.skia.org/infra/go/calc"
+	"go.skia.org/infra/go/chatbot"
 	"go.skia.org/infra/go/common"
 	"go.skia.org/infra/go/ds"
 	"go.skia.org/infra/go/email"
@@ -30,6 +33,7 @@ import (
 	"go.skia.org/infra/go/git/gitinfo"
 	"go.skia.org/infra/go/httputils"
 	"go.skia.org/infra/go/ingestion"
+	"go.skia.org/infra/go/issues"
 	"go.skia.org/infra/go/login"
 	"go.skia.org/infra/go/paramreducer"
 	"go.skia.org/infra/go/paramtools"
@@ -40,16 +44,21 @@ import (
 	"go.skia.org/infra/perf/go/activitylog"
 	"go.skia.org/infra/perf/go/alertfilter"
 	"go.skia.org/infra/perf/go/alerts"
+	"go.skia.org/infra/perf/go/bisect"
 	"go.skia.org/infra/perf/go/btts"
 	"go.skia.org/infra/perf/go/bug"
 	"go.skia.org/infra/perf/go/cid"
+	"go.skia.org/infra/perf/go/clustering2"
 	"go.skia.org/infra/perf/go/config"
 	"go.skia.org/infra/perf/go/dataframe"
 	"go.skia.org/infra/perf/go/dfbuilder"
+	"go.skia.org/infra/perf/go/dfexport"
 	"go.skia.org/infra/perf/go/dryrun"
+	"go.skia.org/infra/perf/go/localts"
 	"go.skia.org/infra/perf/go/notify"
 	"go.skia.org/infra/perf/go/regression"
 	"go.skia.org/infra/perf/go/shortcut2"
+	"go.skia.org/infra/perf/go/tracestore"
 	"go.skia.org/infra/perf/go/types"
 	"google.golang.org/api/option"
 )
@@ -63,6 +72,23 @@ const (
 
 	// DEFAULT_ALERT_CATEGORY is the category that will be used by the /_/alerts/ endpoint.
 	DEFAULT_ALERT_CATEGORY = "Prod"
+
+	// DEFAULT_EXPORT_RANGE is the time range exported by exportHandler if no
+	// begin time is given.
+	DEFAULT_EXPORT_RANGE = 7 * 24 * time.Hour
+
+	// MAX_EXPORT_RANGE is the longest time range exportHandler will export.
+	MAX_EXPORT_RANGE = 90 * 24 * time.Hour
+
+	// MAX_EXPORT_TRACES is the largest number of traces exportHandler will
+	// export.
+	MAX_EXPORT_TRACES = 10000
+
+	// ALERT_REVISION_LIMIT is the max number of entries returned by the /_/alert/revisions endpoint.
+	ALERT_REVISION_LIMIT = 100
+
+	// REGRESSION_HISTORY_LIMIT is the max number of entries returned by the /_/reg/history endpoint.
+	REGRESSION_HISTORY_LIMIT = 100
 )
 
 var (
@@ -83,6 +109,8 @@ var (
 // flags
 var (
 	algo                  = flag.String("algo", "kmeans", "The algorithm to use for detecting regressions (kmeans|stepfit).")
+	bisectTopic           = flag.String("bisect_topic", "", "If set then bisect requests for regressions that span more than one commit are published to this PubSub topic.")
+	bisectWebhook         = flag.String("bisect_webhook", "", "If set then bisect requests for regressions that span more than one commit are POST'd as JSON to this URL.")
 	configFilename        = flag.String("config_filename", "default.json5", "Configuration file in TOML format.")
 	commitRangeURL        = flag.String("commit_range_url", "", "A URI Template to be used for expanding details on a range of commits, from {begin} to {end} git hash. See cluster-summary2-sk.")
 	dataFrameSize         = flag.Int("dataframe_size", dataframe.DEFAULT_NUM_COMMITS, "The number of commits to include in the default dataframe.")
@@ -112,6 +140,7 @@ var (
 	subdomain             = flag.String("subdomain", "perf", "The public subdomain of the server, i.e. 'perf' for perf.skia.org.")
 	kubernetes            = flag.Bool("kubernetes", false, "If true then we are running on kubernetes.")
 	bigTableConfig        = flag.String("big_table_config", "nano", "The name of the config to use when using a BigTable trace store.")
+	traceStoreDir         = flag.String("trace_store_dir", "", "If set then traces are read from a local trace store in this directory, as written by perf-ingest, instead of from BigTable.")
 )
 
 var (
@@ -135,7 +164,11 @@ var (
 
 	notifier *notify.Notifier
 
-	traceStore *btts.BigTableTraceStore
+	bisector bisect.Publisher
+
+	traceStore tracestore.TraceStore
+
+	dfBuilder dataframe.DataFrameBuilder
 
 	emailAuth *email.GMail
 
@@ -227,6 +260,9 @@ func Init() {
 	}
 
 	scopes := []string{storage.ScopeReadOnly, datastore.ScopeDatastore, bigtable.Scope}
+	if *bisectTopic != "" {
+		scopes = append(scopes, pubsub.ScopePubSub)
+	}
 
 	ts, err := auth.NewDefaultTokenSource(*local, scopes...)
 	if err != nil {
@@ -260,12 +296,15 @@ func Init() {
 		sklog.Fatal(err)
 	}
 
-	var dfBuilder dataframe.DataFrameBuilder
-	traceStore, err = btts.NewBigTableTraceStoreFromConfig(ctx, btConfig, ts, false)
+	if *traceStoreDir != "" {
+		traceStore, err = localts.NewLocalTraceStore(*traceStoreDir, btConfig.TileSize)
+	} else {
+		traceStore, err = btts.NewBigTableTraceStoreFromConfig(ctx, btConfig, ts, false)
+	}
 	if err != nil {
 		sklog.Fatalf("Failed to open trace store: %s", err)
 	}
-	dfBuilder = dfbuilder.NewDataFrameBuilderFromBTTS(git, traceStore)
+	dfBuilder = dfbuilder.NewDataFrameBuilderFromTraceStore(git, traceStore)
 
 	freshDataFrame, err = dataframe.NewRefresher(ctx, git, dfBuilder, time.Minute, *dataFrameSize)
 	if err != nil {
@@ -287,6 +326,36 @@ func Init() {
 	} else {
 		notifier = notify.New(notify.NoEmail{}, *subdomain)
 	}
+	chatbot.Init(fmt.Sprintf("%s.skia.org", *subdomain))
+	notifier.AddTransport(alerts.CHAT_TRANSPORT, notify.NewChatTransport(chatbot.Send))
+	notifier.AddTransport(alerts.WEBHOOK_TRANSPORT, notify.NewWebhookTransport())
+	issueTS, err := auth.NewDefaultJWTServiceAccountTokenSource("https://www.googleapis.com/auth/userinfo.email")
+	if err != nil {
+		sklog.Warningf("Failed to create token source for filing bugs, the %q transport is unavailable: %s", alerts.ISSUE_TRANSPORT, err)
+	} else {
+		issueClient := httputils.DefaultClientConfig().WithTokenSource(issueTS).With2xxOnly().Client()
+		notifier.AddTransport(alerts.ISSUE_TRANSPORT, notify.NewIssueTransport(issues.NewMonorailIssueTracker(issueClient)))
+	}
+
+	if *bisectTopic != "" {
+		pubSubClient, err := pubsub.NewClient(ctx, *projectName, option.WithTokenSource(ts))
+		if err != nil {
+			sklog.Fatalf("Failed to create PubSub client: %s", err)
+		}
+		topic := pubSubClient.Topic(*bisectTopic)
+		exists, err := topic.Exists(ctx)
+		if err != nil {
+			sklog.Fatalf("Failed to check for the existence of the bisect topic: %s", err)
+		}
+		if !exists {
+			if topic, err = pubSubClient.CreateTopic(ctx, *bisectTopic); err != nil {
+				sklog.Fatalf("Failed to create the bisect topic: %s", err)
+			}
+		}
+		bisector = bisect.NewPubSubPublisher(topic)
+	} else if *bisectWebhook != "" {
+		bisector = bisect.NewWebhookPublisher(httputils.NewTimeoutClient(), *bisectWebhook)
+	}
 
 	frameRequests = dataframe.NewRunningFrameRequests(git, dfBuilder)
 	clusterRequests = regression.NewRunningClusterRequests(git, cidl, float32(*interesting), dfBuilder)
@@ -296,7 +365,7 @@ func Init() {
 	dryrunRequests = dryrun.New(cidl, dfBuilder, paramsProvider, git)
 
 	// Start running continuous clustering looking for regressions.
-	continuous = regression.NewContinuous(git, cidl, configProvider, regStore, *numContinuous, *radius, notifier, paramsProvider, dfBuilder)
+	continuous = regression.NewContinuous(git, cidl, configProvider, regStore, *numContinuous, *radius, notifier, paramsProvider, dfBuilder, bisector)
 	if *doClustering {
 		go continuous.Run(ctx)
 	}
@@ -618,6 +687,116 @@ func cidHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// exportHandler streams a DataFrame for a query, or a shortcut, over a time
+// range in a format suitable for analysis in a notebook.
+//
+// The query parameters are:
+//
+//    format - One of dfexport.AllFormats, defaults to "csv".
+//    q      - A query in URL query format, e.g. "config=8888&arch=x86".
+//    keys   - The id of a shortcut of trace ids, used instead of 'q'.
+//    begin  - Unix timestamp in seconds, defaults to DEFAULT_EXPORT_RANGE before 'end'.
+//    end    - Unix timestamp in seconds, defaults to now.
+//
+// Note that unlike the UI the data isn't downsampled. The range is limited to
+// MAX_EXPORT_RANGE and the number of traces to MAX_EXPORT_TRACES.
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	if login.LoggedInAs(r) == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to export data.")
+		return
+	}
+	formatName := r.FormValue("format")
+	if formatName == "" {
+		formatName = string(dfexport.CSV)
+	}
+	format, err := dfexport.ToFormat(formatName)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid format.")
+		return
+	}
+	end := time.Now()
+	if s := r.FormValue("end"); s != "" {
+		ts, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			httputils.ReportError(w, r, err, "Invalid end time.")
+			return
+		}
+		end = time.Unix(ts, 0)
+	}
+	begin := end.Add(-DEFAULT_EXPORT_RANGE)
+	if s := r.FormValue("begin"); s != "" {
+		ts, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			httputils.ReportError(w, r, err, "Invalid begin time.")
+			return
+		}
+		begin = time.Unix(ts, 0)
+	}
+	if !begin.Before(end) {
+		httputils.ReportError(w, r, fmt.Errorf("Begin %s not before end %s.", begin, end), "Invalid time range.")
+		return
+	}
+	if end.Sub(begin) > MAX_EXPORT_RANGE {
+		httputils.ReportError(w, r, fmt.Errorf("Range %s is longer than %s.", end.Sub(begin), MAX_EXPORT_RANGE), "Time range is too long.")
+		return
+	}
+
+	var df *dataframe.DataFrame
+	if keys := r.FormValue("keys"); keys != "" {
+		shortcut, err := shortcut2.Get(keys)
+		if err != nil {
+			httputils.ReportError(w, r, err, "Failed to load shortcut.")
+			return
+		}
+		if len(shortcut.Keys) > MAX_EXPORT_TRACES {
+			httputils.ReportError(w, r, fmt.Errorf("Shortcut has %d traces.", len(shortcut.Keys)), "Too many traces.")
+			return
+		}
+		df, err = dfBuilder.NewFromKeysAndRange(shortcut.Keys, begin, end, false, nil)
+		if err != nil {
+			httputils.ReportError(w, r, err, "Failed to load traces.")
+			return
+		}
+	} else {
+		values, err := url.ParseQuery(r.FormValue("q"))
+		if err != nil {
+			httputils.ReportError(w, r, err, "Invalid query.")
+			return
+		}
+		if len(values) == 0 {
+			httputils.ReportError(w, r, fmt.Errorf("Empty query."), "Empty queries are not allowed.")
+			return
+		}
+		q, err := query.New(values)
+		if err != nil {
+			httputils.ReportError(w, r, err, "Invalid query.")
+			return
+		}
+		// Count the matches before loading anything.
+		n, err := dfBuilder.NumMatches(begin, end, q)
+		if err != nil {
+			httputils.ReportError(w, r, err, "Failed to count traces.")
+			return
+		}
+		if n > MAX_EXPORT_TRACES {
+			httputils.ReportError(w, r, fmt.Errorf("Query matches %d traces.", n), "Too many traces.")
+			return
+		}
+		df, err = dfBuilder.NewFromQueryAndRange(begin, end, q, false, nil)
+		if err != nil {
+			httputils.ReportError(w, r, err, "Failed to load traces.")
+			return
+		}
+	}
+
+	// The response is streamed, so errors past this point can only be logged.
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=perf-%d-%d.%s", begin.Unix(), end.Unix(), format))
+	if err := dfexport.Export(w, df, format); err != nil {
+		sklog.Errorf("Failed to export traces: %s", err)
+	}
+}
+
 // ClusterStartResponse is serialized as JSON for the response in
 // clusterStartHandler.
 type ClusterStartResponse struct {
@@ -852,7 +1031,9 @@ func triageHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	key := tr.Alert.IdAsString()
+	clusterType := regression.HIGH_CLUSTER
 	if tr.ClusterType == "low" {
+		clusterType = regression.LOW_CLUSTER
 		err = regStore.TriageLow(detail[0], key, tr.Triage)
 	} else {
 		err = regStore.TriageHigh(detail[0], key, tr.Triage)
@@ -863,6 +1044,20 @@ func triageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	entry := &regression.HistoryEntry{
+		AlertID:     key,
+		CommitID:    detail[0].ID(),
+		Hash:        detail[0].Hash,
+		ClusterType: clusterType,
+		Event:       regression.TRIAGED_EVENT,
+		Status:      tr.Triage.Status,
+		Message:     tr.Triage.Message,
+		User:        login.LoggedInAs(r),
+	}
+	if err := regStore.AddHistory(entry); err != nil {
+		sklog.Errorf("Failed to record triage history: %s", err)
+	}
+
 	link := fmt.Sprintf("%s/t/?begin=%d&end=%d&subset=all", r.Header.Get("Origin"), detail[0].Timestamp, detail[0].Timestamp+1)
 	a := &activitylog.Activity{
 		UserID: login.LoggedInAs(r),
@@ -894,6 +1089,57 @@ func triageHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// RegressionBisectRequest is used in regressionBisectHandler.
+type RegressionBisectRequest struct {
+	Alert       alerts.Config               `json:"alert"`
+	ClusterType string                      `json:"cluster_type"`
+	Cluster     *clustering2.ClusterSummary `json:"cluster"`
+	Publish     bool                        `json:"publish"` // If true then also publish the bisect.Request.
+}
+
+// regressionBisectHandler takes a POST'd RegressionBisectRequest serialized as
+// JSON and returns the bisect.Request for the range of commits that could
+// have caused the regression, serialized as JSON. The response is null if the
+// regression can be attributed to a single commit.
+func regressionBisectHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	br := &RegressionBisectRequest{}
+	if err := json.NewDecoder(r.Body).Decode(br); err != nil {
+		httputils.ReportError(w, r, err, "Failed to decode JSON.")
+		return
+	}
+	if br.Cluster == nil {
+		httputils.ReportError(w, r, fmt.Errorf("Missing cluster."), "A cluster must be supplied.")
+		return
+	}
+	clusterType := regression.HIGH_CLUSTER
+	if br.ClusterType == "low" {
+		clusterType = regression.LOW_CLUSTER
+	}
+	req, err := regression.BisectRequest(r.Context(), cidl, &br.Alert, clusterType, br.Cluster)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to build bisect request.")
+		return
+	}
+	if br.Publish && req != nil {
+		if login.LoggedInAs(r) == "" {
+			httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to request a bisect.")
+			return
+		}
+		if bisector == nil {
+			httputils.ReportError(w, r, fmt.Errorf("No bisect publisher configured."), "Bisect requests are not enabled on this instance.")
+			return
+		}
+		if err := bisector.Publish(r.Context(), req); err != nil {
+			httputils.ReportError(w, r, err, "Failed to publish bisect request.")
+			return
+		}
+	}
+	if err := json.NewEncoder(w).Encode(req); err != nil {
+		sklog.Errorf("Failed to write or encode output: %s", err)
+	}
+}
+
 // regressionCount returns the number of commits that have regressions for alerts
 // in the given category. The time range of commits is REGRESSION_COUNT_DURATION.
 func regressionCount(category string) (int, error) {
@@ -941,6 +1187,27 @@ func regressionCountHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// regressionHistoryHandler returns the history of changes to the regressions
+// found by a single alert, newest first.
+//
+// Takes the alert id in the 'id' query parameter.
+func regressionHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := r.FormValue("id")
+	if id == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Missing alert id."), "An alert id must be supplied.")
+		return
+	}
+	history, err := regStore.History(id, REGRESSION_HISTORY_LIMIT)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to load regression history.")
+		return
+	}
+	if err := json.NewEncoder(w).Encode(history); err != nil {
+		sklog.Errorf("Failed to write or encode output: %s", err)
+	}
+}
+
 // RegressionRangeRequest is used in regressionRangeHandler and is used to query for a range of
 // of Regressions.
 //
@@ -1311,17 +1578,23 @@ func alertUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		httputils.ReportError(w, r, err, "Failed to decode JSON.")
 		return
 	}
-	if err := alertStore.Save(cfg); err != nil {
+	rev, err := alertStore.Save(cfg, login.LoggedInAs(r))
+	if err != nil {
 		httputils.ReportError(w, r, err, "Failed to save alerts.Config.")
+		return
 	}
-	link := ""
-	if cfg.ID != alerts.INVALID_ID {
-		link = fmt.Sprintf("/a/?%d", cfg.ID)
+	logAlertRevision(r, rev)
+	if err := json.NewEncoder(w).Encode(cfg); err != nil {
+		sklog.Errorf("Failed to write JSON response: %s", err)
 	}
+}
+
+// logAlertRevision records a change to an alerts.Config in the activity log.
+func logAlertRevision(r *http.Request, rev *alerts.Revision) {
 	a := &activitylog.Activity{
 		UserID: login.LoggedInAs(r),
-		Action: fmt.Sprintf("Create/Update Alert: %#v, %d", *cfg, cfg.ID),
-		URL:    link,
+		Action: rev.Summary(),
+		URL:    fmt.Sprintf("/a/?%d", rev.AlertID),
 	}
 	if err := activitylog.Write(a); err != nil {
 		sklog.Errorf("Failed to log activity: %s", err)
@@ -1339,18 +1612,66 @@ func alertDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(sid, 10, 64)
 	if err != nil {
 		httputils.ReportError(w, r, err, "Failed to parse alert id.")
+		return
 	}
-	if err := alertStore.Delete(int(id)); err != nil {
+	rev, err := alertStore.Delete(int(id), login.LoggedInAs(r))
+	if err != nil {
 		httputils.ReportError(w, r, err, "Failed to delete the alerts.Config.")
 		return
 	}
-	a := &activitylog.Activity{
-		UserID: login.LoggedInAs(r),
-		Action: fmt.Sprintf("Delete Alert: %d", id),
-		URL:    fmt.Sprintf("/a/?%d", id),
+	logAlertRevision(r, rev)
+}
+
+// alertRevisionsHandler returns the most recent revisions of an alert as
+// JSON, newest first.
+func alertRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to parse alert id.")
+		return
 	}
-	if err := activitylog.Write(a); err != nil {
-		sklog.Errorf("Failed to log activity: %s", err)
+	revs, err := alertStore.Revisions(id, ALERT_REVISION_LIMIT)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to retrieve alert revisions.")
+		return
+	}
+	if err := json.NewEncoder(w).Encode(revs); err != nil {
+		sklog.Errorf("Failed to write JSON response: %s", err)
+	}
+}
+
+// alertRestoreHandler makes an earlier revision of an alert the current
+// config, and returns the restored config as JSON.
+func alertRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if login.LoggedInAs(r) == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to restore alerts.")
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to parse alert id.")
+		return
+	}
+	revID, err := strconv.ParseInt(mux.Vars(r)["rev"], 10, 64)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to parse revision id.")
+		return
+	}
+	rev, err := alertStore.Restore(id, revID, login.LoggedInAs(r))
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to restore the alerts.Config.")
+		return
+	}
+	logAlertRevision(r, rev)
+	cfg, err := rev.GetConfig()
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to decode the restored alerts.Config.")
+		return
+	}
+	if err := json.NewEncoder(w).Encode(cfg); err != nil {
+		sklog.Errorf("Failed to write JSON response: %s", err)
 	}
 }
 
@@ -1395,8 +1716,28 @@ func alertNotifyTryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// In a dry run the formatted notification is returned instead of being sent.
+	if r.FormValue("dryrun") != "" {
+		msg, err := notifier.ExampleFormat(req)
+		if err != nil {
+			httputils.ReportError(w, r, err, fmt.Sprintf("Failed to format notification: %s", err))
+			return
+		}
+		if err := json.NewEncoder(w).Encode(msg); err != nil {
+			sklog.Errorf("Failed to encode response: %s", err)
+		}
+		return
+	}
+
+	// Don't let users make the server POST to arbitrary URLs. Webhooks can
+	// only be tried as a dry run.
+	if transport, err := alerts.ToTransport(string(req.Transport)); err == nil && transport == alerts.WEBHOOK_TRANSPORT {
+		httputils.ReportError(w, r, fmt.Errorf("Webhook notifications can't be sent from the try endpoint."), "Webhook notifications can only be tried as a dry run.")
+		return
+	}
+
 	if err := notifier.ExampleSend(req); err != nil {
-		httputils.ReportError(w, r, err, fmt.Sprintf("Failed to send email: %s", err))
+		httputils.ReportError(w, r, err, fmt.Sprintf("Failed to send notification: %s", err))
 	}
 }
 
@@ -1477,7 +1818,10 @@ func main() {
 	)
 
 	Init()
-	login.SimpleInitMust(*port, *local)
+	// Anyone that can log in is an editor, which matches the checks the
+	// handlers make with login.LoggedInAs.
+	editAllow := allowed.NewAllowedFromList(strings.Fields(login.DEFAULT_DOMAIN_WHITELIST))
+	login.InitWithAllow(*port, *local, nil, editAllow, nil)
 
 	// Resources are served directly.
 	router := mux.NewRouter()
@@ -1517,9 +1861,12 @@ func main() {
 	router.HandleFunc("/_/dryrun/start", dryrunRequests.StartHandler).Methods("POST")
 	router.HandleFunc("/_/dryrun/status/{id:[a-zA-Z0-9]+}", dryrunRequests.StatusHandler).Methods("GET")
 
+	router.HandleFunc("/_/export/", exportHandler).Methods("GET")
 	router.HandleFunc("/_/reg/", regressionRangeHandler).Methods("POST")
 	router.HandleFunc("/_/reg/count", regressionCountHandler).Methods("GET")
 	router.HandleFunc("/_/reg/current", regressionCurrentHandler).Methods("GET")
+	router.HandleFunc("/_/reg/history", regressionHistoryHandler).Methods("GET")
+	router.HandleFunc("/_/reg/bisect", regressionBisectHandler).Methods("POST")
 	router.HandleFunc("/_/triage/", triageHandler).Methods("POST")
 	router.HandleFunc("/_/alerts/", alertsHandler)
 	router.HandleFunc("/_/details/", detailsHandler).Methods("POST")
@@ -1528,8 +1875,10 @@ func main() {
 	router.HandleFunc("/_/alert/new", alertNewHandler).Methods("GET")
 	router.HandleFunc("/_/alert/update", alertUpdateHandler).Methods("POST")
 	router.HandleFunc("/_/alert/delete/{id:[0-9]+}", alertDeleteHandler).Methods("POST")
+	router.HandleFunc("/_/alert/revisions/{id:[0-9]+}", alertRevisionsHandler).Methods("GET")
+	router.HandleFunc("/_/alert/restore/{id:[0-9]+}/{rev:[0-9]+}", alertRestoreHandler).Methods("POST")
 	router.HandleFunc("/_/alert/bug/try", alertBugTryHandler).Methods("POST")
-	router.HandleFunc("/_/alert/notify/try", alertNotifyTryHandler).Methods("POST")
+	router.HandleFunc("/_/alert/notify/try", login.RestrictEditorFn(alertNotifyTryHandler)).Methods("POST")
 
 	var h http.Handler = router
 	if *internalOnly {
@@ -0,0 +1,123 @@
+package stepfit
+
+import (
+	"math"
+	"sort"
+
+	"go.skia.org/infra/go/vec32"
+)
+
+const (
+	// DEFAULT_BAND is the default width of the band around the baseline,
+	// measured in multiples of the baseline spread, outside of which a trace
+	// is considered to have regressed.
+	DEFAULT_BAND = 3.0
+
+	// MAD_SCALE converts a median absolute deviation into an estimate of the
+	// standard deviation for normally distributed data.
+	MAD_SCALE = 1.4826
+
+	// EWMA_ALPHA is the smoothing factor used by EWMA, i.e. the weight given to
+	// each new value.
+	EWMA_ALPHA = 0.1
+
+	// MIN_BASELINE_SAMPLES is the minimum number of non-missing values needed
+	// to build a baseline.
+	MIN_BASELINE_SAMPLES = 5
+)
+
+// Baseline returns the center and spread of the given values, which will not
+// contain any vec32.MISSING_DATA_SENTINEL values.
+type Baseline func(values []float32) (center float32, spread float32)
+
+// median returns the median of the values, which must not be empty. The
+// values are sorted as a side-effect.
+func median(values []float32) float32 {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// nonMissing returns a copy of the values with all the
+// vec32.MISSING_DATA_SENTINEL values removed.
+func nonMissing(values []float32) []float32 {
+	ret := make([]float32, 0, len(values))
+	for _, x := range values {
+		if x != vec32.MISSING_DATA_SENTINEL {
+			ret = append(ret, x)
+		}
+	}
+	return ret
+}
+
+// MedianMAD is a Baseline that uses the median as the center and the scaled
+// median absolute deviation as the spread, which makes it robust to the
+// outliers that are common in timing benchmarks.
+func MedianMAD(values []float32) (float32, float32) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	values = vec32.Dup(values)
+	center := median(values)
+	for i, x := range values {
+		values[i] = float32(math.Abs(float64(x - center)))
+	}
+	return center, MAD_SCALE * median(values)
+}
+
+// EWMA is a Baseline that uses the exponentially weighted moving average and
+// standard deviation, so that recent values count more than older ones.
+func EWMA(values []float32) (float32, float32) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	mean := float64(values[0])
+	variance := 0.0
+	for _, x := range values[1:] {
+		diff := float64(x) - mean
+		incr := EWMA_ALPHA * diff
+		mean += incr
+		variance = (1 - EWMA_ALPHA) * (variance + diff*incr)
+	}
+	return float32(mean), float32(math.Sqrt(variance))
+}
+
+// GetBaselineFit compares the values in 'current' against a baseline built
+// from the values in 'history' and returns a StepFit.
+//
+// The level of 'current' is its median, so a single outlier doesn't trigger
+// a regression. The StepFit is interesting if that level lies more than
+// 'band' times the spread of the baseline away from the center of the
+// baseline. StepSize is the distance between the center of the baseline and
+// the level, and Regression is that distance measured in multiples of the
+// spread. LeastSquares holds the spread. TurningPoint is len(history).
+//
+// Missing values in either 'history' or 'current' are ignored.
+func GetBaselineFit(history, current []float32, baseline Baseline, band float32) *StepFit {
+	ret := &StepFit{
+		TurningPoint: len(history),
+		Status:       UNINTERESTING,
+	}
+	h := nonMissing(history)
+	c := nonMissing(current)
+	if len(h) < MIN_BASELINE_SAMPLES || len(c) == 0 {
+		return ret
+	}
+	center, spread := baseline(h)
+	if spread < MIN_SSE {
+		spread = MIN_SSE
+	}
+	level := median(c)
+	ret.LeastSquares = spread
+	ret.StepSize = center - level
+	ret.Regression = ret.StepSize / spread
+	if ret.Regression > band {
+		ret.Status = LOW
+	} else if ret.Regression < -band {
+		ret.Status = HIGH
+	}
+	return ret
+}
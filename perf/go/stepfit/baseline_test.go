@@ -0,0 +1,88 @@
+package stepfit
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/vec32"
+)
+
+func TestMedianMAD(t *testing.T) {
+	testutils.SmallTest(t)
+
+	center, spread := MedianMAD([]float32{1, 2, 3, 4, 100})
+	assert.Equal(t, float32(3), center)
+	assert.InDelta(t, MAD_SCALE*1.0, spread, 0.0001)
+
+	center, spread = MedianMAD([]float32{2, 4})
+	assert.Equal(t, float32(3), center)
+	assert.InDelta(t, MAD_SCALE*1.0, spread, 0.0001)
+
+	// The input isn't modified.
+	values := []float32{3, 1, 2}
+	MedianMAD(values)
+	assert.Equal(t, []float32{3, 1, 2}, values)
+
+	center, spread = MedianMAD([]float32{})
+	assert.Equal(t, float32(0), center)
+	assert.Equal(t, float32(0), spread)
+}
+
+func TestEWMA(t *testing.T) {
+	testutils.SmallTest(t)
+
+	center, spread := EWMA([]float32{5, 5, 5, 5})
+	assert.Equal(t, float32(5), center)
+	assert.Equal(t, float32(0), spread)
+
+	// Recent values count more.
+	center, _ = EWMA([]float32{1, 1, 1, 1, 1, 1, 1, 1, 2, 2})
+	assert.True(t, center > 1.1 && center < 1.5, "Got %f", center)
+
+	center, spread = EWMA([]float32{1, 3, 1, 3, 1, 3, 1, 3, 1, 3})
+	assert.InDelta(t, 2, center, 0.5)
+	assert.True(t, spread > 0.5 && spread < 1.5, "Got %f", spread)
+}
+
+func TestGetBaselineFit(t *testing.T) {
+	testutils.SmallTest(t)
+
+	e := vec32.MISSING_DATA_SENTINEL
+	noisy := []float32{10, 11, 9, 10, 12, 8, 10, 11, 9, 10}
+
+	// Within the band.
+	sf := GetBaselineFit(noisy, []float32{11, 10, 12}, MedianMAD, DEFAULT_BAND)
+	assert.Equal(t, UNINTERESTING, sf.Status)
+	assert.Equal(t, len(noisy), sf.TurningPoint)
+
+	// A step up.
+	sf = GetBaselineFit(noisy, []float32{20, 21, 19}, MedianMAD, DEFAULT_BAND)
+	assert.Equal(t, HIGH, sf.Status)
+	assert.Equal(t, float32(-10), sf.StepSize)
+	assert.True(t, sf.Regression < -DEFAULT_BAND)
+
+	// A step down.
+	sf = GetBaselineFit(noisy, []float32{1, 0, 2}, EWMA, DEFAULT_BAND)
+	assert.Equal(t, LOW, sf.Status)
+
+	// A single outlier isn't a regression.
+	sf = GetBaselineFit(noisy, []float32{30, 10, 11}, MedianMAD, DEFAULT_BAND)
+	assert.Equal(t, UNINTERESTING, sf.Status)
+
+	// The same step is within a wider band.
+	sf = GetBaselineFit([]float32{10, 20, 0, 10, 25, 0, 10, 15, 5, 10}, []float32{20, 21, 19}, MedianMAD, DEFAULT_BAND)
+	assert.Equal(t, UNINTERESTING, sf.Status)
+
+	// Not enough history.
+	sf = GetBaselineFit([]float32{10, e, e, e, e, 10}, []float32{20}, MedianMAD, DEFAULT_BAND)
+	assert.Equal(t, UNINTERESTING, sf.Status)
+
+	// No current data.
+	sf = GetBaselineFit(noisy, []float32{e, e}, MedianMAD, DEFAULT_BAND)
+	assert.Equal(t, UNINTERESTING, sf.Status)
+
+	// A perfectly flat history doesn't divide by zero.
+	sf = GetBaselineFit([]float32{1, 1, 1, 1, 1}, []float32{2}, MedianMAD, DEFAULT_BAND)
+	assert.Equal(t, HIGH, sf.Status)
+}
@@ -0,0 +1,294 @@
+package stepfit
+
+import (
+	"math"
+	"sort"
+
+	"go.skia.org/infra/go/vec32"
+)
+
+const (
+	// DEFAULT_SIGNIFICANCE is the p-value threshold used by the statistical
+	// tests if none is supplied.
+	DEFAULT_SIGNIFICANCE = 0.05
+
+	// MIN_SAMPLES is the minimum number of non-missing values needed on each
+	// side of the turning point before a statistical test is attempted.
+	MIN_SAMPLES = 2
+)
+
+// split returns the non-missing values of the trace before and after index i.
+func split(trace []float32, i int) ([]float64, []float64) {
+	x := make([]float64, 0, i)
+	y := make([]float64, 0, len(trace)-i)
+	for j, v := range trace {
+		if v == vec32.MISSING_DATA_SENTINEL {
+			continue
+		}
+		if j < i {
+			x = append(x, float64(v))
+		} else {
+			y = append(y, float64(v))
+		}
+	}
+	return x, y
+}
+
+// newStatStepFit builds a StepFit from the results of a statistical test.
+//
+// The sign of StepSize and Regression follow the conventions of
+// GetStepFitAtMid, i.e. negative values indicate a step up.
+func newStatStepFit(x, y []float64, turn int, pvalue, effectSize float64, significance float32) *StepFit {
+	stepSize := float32(mean(x) - mean(y))
+	regression := float32(math.Abs(effectSize))
+	if stepSize < 0 {
+		regression = -regression
+	}
+	status := UNINTERESTING
+	if pvalue < float64(significance) {
+		if stepSize > 0 {
+			status = LOW
+		} else if stepSize < 0 {
+			status = HIGH
+		}
+	}
+	return &StepFit{
+		TurningPoint: turn,
+		StepSize:     stepSize,
+		Regression:   regression,
+		PValue:       float32(pvalue),
+		EffectSize:   float32(effectSize),
+		Status:       status,
+	}
+}
+
+// uninteresting returns a StepFit for a trace that can't be tested.
+func uninteresting(turn int) *StepFit {
+	return &StepFit{
+		TurningPoint: turn,
+		StepSize:     -1,
+		PValue:       1,
+		Status:       UNINTERESTING,
+	}
+}
+
+// GetMannWhitneyUAtMid splits the trace at its midpoint and runs a two-sided
+// Mann-Whitney U test on the two halves, which makes no assumption about the
+// distribution of the data, so it copes well with noisy or bimodal traces.
+//
+// The trace is considered a step if the p-value is below 'significance'. The
+// EffectSize is the rank-biserial correlation, which ranges over [-1, 1].
+func GetMannWhitneyUAtMid(trace []float32, significance float32) *StepFit {
+	turn := len(trace) / 2
+	x, y := split(trace, turn)
+	if len(x) < MIN_SAMPLES || len(y) < MIN_SAMPLES {
+		return uninteresting(turn)
+	}
+	u, pvalue := MannWhitneyU(x, y)
+	n1 := float64(len(x))
+	n2 := float64(len(y))
+	// Rank-biserial correlation, positive if x tends to be larger than y.
+	effectSize := 2*u/(n1*n2) - 1
+	return newStatStepFit(x, y, turn, pvalue, effectSize, significance)
+}
+
+// GetWelchTTestAtMid splits the trace at its midpoint and runs a two-sided
+// Welch's t-test on the two halves.
+//
+// The trace is considered a step if the p-value is below 'significance'. The
+// EffectSize is Cohen's d, i.e. the difference in means divided by the pooled
+// standard deviation.
+func GetWelchTTestAtMid(trace []float32, significance float32) *StepFit {
+	turn := len(trace) / 2
+	x, y := split(trace, turn)
+	if len(x) < MIN_SAMPLES || len(y) < MIN_SAMPLES {
+		return uninteresting(turn)
+	}
+	_, pvalue := WelchTTest(x, y)
+	n1 := float64(len(x))
+	n2 := float64(len(y))
+	pooled := math.Sqrt(((n1-1)*variance(x) + (n2-1)*variance(y)) / (n1 + n2 - 2))
+	effectSize := 0.0
+	if pooled > MIN_SSE {
+		effectSize = (mean(x) - mean(y)) / pooled
+	} else if mean(x) != mean(y) {
+		effectSize = (mean(x) - mean(y)) / MIN_SSE
+	}
+	return newStatStepFit(x, y, turn, pvalue, effectSize, significance)
+}
+
+// MannWhitneyU returns the U statistic for sample x and the two-sided p-value
+// of the Mann-Whitney U test, using the normal approximation with a
+// correction for ties.
+func MannWhitneyU(x, y []float64) (float64, float64) {
+	n1 := len(x)
+	n2 := len(y)
+	if n1 == 0 || n2 == 0 {
+		return 0, 1
+	}
+	type sample struct {
+		value float64
+		inX   bool
+	}
+	all := make([]sample, 0, n1+n2)
+	for _, v := range x {
+		all = append(all, sample{value: v, inX: true})
+	}
+	for _, v := range y {
+		all = append(all, sample{value: v, inX: false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].value < all[j].value })
+
+	// Assign ranks, averaging over ties, and accumulate the tie correction.
+	rankSumX := 0.0
+	tieCorrection := 0.0
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].value == all[i].value {
+			j++
+		}
+		rank := float64(i+j+1) / 2.0
+		for k := i; k < j; k++ {
+			if all[k].inX {
+				rankSumX += rank
+			}
+		}
+		t := float64(j - i)
+		tieCorrection += t*t*t - t
+		i = j
+	}
+	fn1 := float64(n1)
+	fn2 := float64(n2)
+	n := fn1 + fn2
+	u := rankSumX - fn1*(fn1+1)/2
+	mu := fn1 * fn2 / 2
+	sigma := math.Sqrt(fn1 * fn2 / 12 * ((n + 1) - tieCorrection/(n*(n-1))))
+	if sigma == 0 {
+		return u, 1
+	}
+	// Continuity correction.
+	z := (math.Abs(u-mu) - 0.5) / sigma
+	if z < 0 {
+		z = 0
+	}
+	return u, math.Erfc(z / math.Sqrt2)
+}
+
+// WelchTTest returns the t statistic and the two-sided p-value of Welch's
+// unequal variances t-test.
+func WelchTTest(x, y []float64) (float64, float64) {
+	n1 := float64(len(x))
+	n2 := float64(len(y))
+	if n1 < 2 || n2 < 2 {
+		return 0, 1
+	}
+	v1 := variance(x) / n1
+	v2 := variance(y) / n2
+	diff := mean(x) - mean(y)
+	if v1+v2 == 0 {
+		if diff == 0 {
+			return 0, 1
+		}
+		return math.Copysign(math.Inf(1), diff), 0
+	}
+	t := diff / math.Sqrt(v1+v2)
+	// Welch-Satterthwaite degrees of freedom.
+	df := (v1 + v2) * (v1 + v2) / (v1*v1/(n1-1) + v2*v2/(n2-1))
+	return t, regIncBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// mean returns the mean of x.
+func mean(x []float64) float64 {
+	if len(x) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, v := range x {
+		total += v
+	}
+	return total / float64(len(x))
+}
+
+// variance returns the sample variance of x.
+func variance(x []float64) float64 {
+	if len(x) < 2 {
+		return 0
+	}
+	m := mean(x)
+	total := 0.0
+	for _, v := range x {
+		total += (v - m) * (v - m)
+	}
+	return total / float64(len(x)-1)
+}
+
+// regIncBeta returns the regularized incomplete beta function I_x(a, b).
+func regIncBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	lgab, _ := math.Lgamma(a + b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+	// The continued fraction converges rapidly for x < (a+1)/(a+b+2), otherwise
+	// use the symmetry relation.
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(a, b, x) / a
+	}
+	return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+}
+
+// betaContinuedFraction evaluates the continued fraction for the incomplete
+// beta function using the modified Lentz's method.
+func betaContinuedFraction(a, b, x float64) float64 {
+	const (
+		maxIterations = 200
+		epsilon       = 1e-14
+		tiny          = 1e-300
+	)
+	qab := a + b
+	qap := a + 1
+	qam := a - 1
+	c := 1.0
+	d := 1 - qab*x/qap
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+		m2 := 2 * fm
+		aa := fm * (b - fm) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+		aa = -(a + fm) * (qab + fm) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
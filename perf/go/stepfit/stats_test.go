@@ -0,0 +1,95 @@
+package stepfit
+
+import (
+	"math"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/vec32"
+)
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) < tolerance
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	testutils.SmallTest(t)
+
+	u, p := MannWhitneyU([]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10})
+	assert.Equal(t, 0.0, u)
+	assert.True(t, near(0.0122, p, 0.001), "Got %f", p)
+
+	u, p = MannWhitneyU([]float64{1, 2, 3}, []float64{1, 2, 3})
+	assert.Equal(t, 4.5, u)
+	assert.Equal(t, 1.0, p)
+
+	_, p = MannWhitneyU([]float64{}, []float64{1, 2, 3})
+	assert.Equal(t, 1.0, p)
+}
+
+func TestWelchTTest(t *testing.T) {
+	testutils.SmallTest(t)
+
+	// The example from https://en.wikipedia.org/wiki/Welch%27s_t-test.
+	tstat, p := WelchTTest([]float64{19.8, 20.4, 19.6, 17.8, 18.5, 18.9, 18.3, 18.9, 19.5, 22.0}, []float64{28.2, 26.6, 20.1, 23.3, 25.2, 22.1, 17.7, 27.6, 20.6, 13.7, 23.2, 17.5, 20.6, 18.0, 23.9, 21.6, 24.3, 20.4, 23.9, 13.3})
+	assert.True(t, near(-2.22, tstat, 0.01), "Got %f", tstat)
+	assert.True(t, near(0.036, p, 0.001), "Got %f", p)
+
+	tstat, p = WelchTTest([]float64{1, 1, 1}, []float64{1, 1, 1})
+	assert.Equal(t, 0.0, tstat)
+	assert.Equal(t, 1.0, p)
+
+	_, p = WelchTTest([]float64{1, 1, 1}, []float64{2, 2, 2})
+	assert.Equal(t, 0.0, p)
+}
+
+func TestStatStepFits(t *testing.T) {
+	testutils.SmallTest(t)
+
+	e := vec32.MISSING_DATA_SENTINEL
+	testCases := []struct {
+		value   []float32
+		status  string
+		message string
+	}{
+		{
+			value:   []float32{1, 2, 1, 2, 1, 2, 11, 12, 11, 12, 11, 12},
+			status:  HIGH,
+			message: "Step Up",
+		},
+		{
+			value:   []float32{11, 12, 11, 12, 11, 12, 1, 2, 1, 2, 1, 2},
+			status:  LOW,
+			message: "Step Down",
+		},
+		{
+			value:   []float32{1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 2},
+			status:  UNINTERESTING,
+			message: "No step",
+		},
+		{
+			value:   []float32{1, e, e, e, e, e, 11, 12, 11, 12, 11, 12},
+			status:  UNINTERESTING,
+			message: "Too much missing data",
+		},
+		{
+			value:   []float32{},
+			status:  UNINTERESTING,
+			message: "Empty",
+		},
+	}
+
+	for _, tc := range testCases {
+		sf := GetMannWhitneyUAtMid(tc.value, DEFAULT_SIGNIFICANCE)
+		assert.Equal(t, tc.status, sf.Status, "MannWhitneyU: %s", tc.message)
+		sf = GetWelchTTestAtMid(tc.value, DEFAULT_SIGNIFICANCE)
+		assert.Equal(t, tc.status, sf.Status, "TTest: %s", tc.message)
+	}
+
+	sf := GetMannWhitneyUAtMid([]float32{1, 2, 1, 2, 1, 2, 11, 12, 11, 12, 11, 12}, DEFAULT_SIGNIFICANCE)
+	assert.Equal(t, 6, sf.TurningPoint)
+	assert.Equal(t, float32(-1), sf.EffectSize)
+	assert.True(t, sf.Regression < 0)
+	assert.True(t, sf.PValue < DEFAULT_SIGNIFICANCE)
+}
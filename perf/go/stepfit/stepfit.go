@@ -2,6 +2,7 @@ package stepfit
 
 import (
 	"math"
+	"sort"
 
 	"go.skia.org/infra/go/vec32"
 )
@@ -40,6 +41,14 @@ type StepFit struct {
 	// larger the number returned.
 	Regression float32 `json:"regression"`
 
+	// PValue is the p-value of the statistical test used to find the step.
+	// Only populated by the statistical tests, e.g. GetMannWhitneyUAtMid.
+	PValue float32 `json:"p_value"`
+
+	// EffectSize is the size of the step as measured by the statistical test.
+	// Only populated by the statistical tests, e.g. GetMannWhitneyUAtMid.
+	EffectSize float32 `json:"effect_size"`
+
 	// Status of the cluster.
 	//
 	// Values can be "High", "Low", and "Uninteresting"
@@ -50,11 +59,18 @@ type StepFit struct {
 //
 // See StepFit for a description of the values being calculated.
 func GetStepFitAtMid(trace []float32, interesting float32) *StepFit {
+	return GetStepFitAt(trace, len(trace)/2, interesting)
+}
+
+// GetStepFitAt takes one []float32 trace and calculates and returns a StepFit
+// for a step function that changes value at index i.
+//
+// See StepFit for a description of the values being calculated.
+func GetStepFitAt(trace []float32, i int, interesting float32) *StepFit {
 	lse := float32(math.MaxFloat32)
 	stepSize := float32(-1.0)
 	turn := 0
 
-	i := len(trace) / 2
 	y0 := vec32.Mean(trace[:i])
 	y1 := vec32.Mean(trace[i:])
 
@@ -87,3 +103,46 @@ func GetStepFitAtMid(trace []float32, interesting float32) *StepFit {
 		Status:       status,
 	}
 }
+
+// FindSteps looks for every step in the trace, not just one at the midpoint.
+//
+// Every index in the trace is considered as a candidate turning point and is
+// scored by GetStepFitAt over the 'radius' points on either side of it. Of
+// the interesting candidates only the best within 'radius' of each other are
+// kept, so a single step isn't reported multiple times.
+//
+// The returned StepFits are ordered by TurningPoint, which is an index into
+// the full trace. Returns an empty slice if no interesting steps are found.
+func FindSteps(trace []float32, interesting float32, radius int) []*StepFit {
+	if radius < 1 {
+		radius = 1
+	}
+	candidates := []*StepFit{}
+	for i := radius; i <= len(trace)-radius; i++ {
+		sf := GetStepFitAt(trace[i-radius:i+radius], radius, interesting)
+		if sf.Status == UNINTERESTING {
+			continue
+		}
+		sf.TurningPoint = i
+		candidates = append(candidates, sf)
+	}
+	// Sort by the absolute value of Regression, ties keep the earlier TurningPoint first.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return math.Abs(float64(candidates[i].Regression)) > math.Abs(float64(candidates[j].Regression))
+	})
+	ret := []*StepFit{}
+	for _, sf := range candidates {
+		keep := true
+		for _, kept := range ret {
+			if sf.TurningPoint-kept.TurningPoint < radius && kept.TurningPoint-sf.TurningPoint < radius {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			ret = append(ret, sf)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].TurningPoint < ret[j].TurningPoint })
+	return ret
+}
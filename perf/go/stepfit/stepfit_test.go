@@ -59,3 +59,59 @@ func TestStepFit(t *testing.T) {
 		}
 	}
 }
+
+func TestFindSteps(t *testing.T) {
+	testutils.SmallTest(t)
+	testCases := []struct {
+		value    []float32
+		expected []int
+		message  string
+	}{
+		{
+			value:    []float32{0, 0, 0, 0, 0, 0, 1, 1},
+			expected: []int{6},
+			message:  "Off-center step",
+		},
+		{
+			value:    []float32{0, 0, 0, 1, 1, 1, 0, 0, 0},
+			expected: []int{3, 6},
+			message:  "Step up and back down",
+		},
+		{
+			value:    []float32{0, 0, 1, 1, 2, 2, 3, 3},
+			expected: []int{2, 4, 6},
+			message:  "Staircase",
+		},
+		{
+			value:    []float32{1, 1, 1, 1, 1},
+			expected: []int{},
+			message:  "No step",
+		},
+		{
+			value:    []float32{},
+			expected: []int{},
+			message:  "Empty",
+		},
+	}
+
+	for _, tc := range testCases {
+		got := []int{}
+		for _, sf := range FindSteps(tc.value, 50, 2) {
+			got = append(got, sf.TurningPoint)
+		}
+		if len(got) != len(tc.expected) {
+			t.Errorf("Failed FindSteps Got %v Want %v: %s", got, tc.expected, tc.message)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.expected[i] {
+				t.Errorf("Failed FindSteps Got %v Want %v: %s", got, tc.expected, tc.message)
+			}
+		}
+	}
+
+	steps := FindSteps([]float32{0, 0, 0, 1, 1, 1, 0, 0, 0}, 50, 2)
+	if steps[0].Status != HIGH || steps[1].Status != LOW {
+		t.Errorf("Failed FindSteps wrong directions: %s %s", steps[0].Status, steps[1].Status)
+	}
+}
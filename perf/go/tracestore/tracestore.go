@@ -0,0 +1,59 @@
+// Package tracestore defines the interface for storing and retrieving the
+// traces that Perf displays and clusters over.
+package tracestore
+
+import (
+	"regexp"
+	"time"
+
+	"go.skia.org/infra/go/paramtools"
+	"go.skia.org/infra/perf/go/btts"
+)
+
+// TraceStore stores trace values in tiles of a fixed number of commits.
+//
+// Each tile has its own OrderedParamSet and the traces in a tile are keyed by
+// their OPS encoded keys, see paramtools.OrderedParamSet.EncodeParamsAsString.
+type TraceStore interface {
+	// TileKey returns the TileKey of the tile that would contain the given index.
+	TileKey(index int32) btts.TileKey
+
+	// OffsetFromIndex returns the offset within a tile for the given index.
+	OffsetFromIndex(index int32) int32
+
+	// GetLatestTile returns the latest, i.e. the newest tile.
+	GetLatestTile() (btts.TileKey, error)
+
+	// GetOrderedParamSet returns the OPS for the given tile.
+	GetOrderedParamSet(tileKey btts.TileKey) (*paramtools.OrderedParamSet, error)
+
+	// UpdateOrderedParamSet adds all params from 'p' to the OrderedParamSet
+	// for 'tileKey' and returns the updated OPS.
+	UpdateOrderedParamSet(tileKey btts.TileKey, p paramtools.ParamSet) (*paramtools.OrderedParamSet, error)
+
+	// WriteTraces writes the given values into the store.
+	//
+	// The keys of 'values' must be the OPS encoded Params of the trace.
+	WriteTraces(index int32, values map[string]float32, source string, timestamp time.Time) error
+
+	// ReadTraces loads the traces for the given structured keys. The returned
+	// map is keyed by the structured keys.
+	ReadTraces(tileKey btts.TileKey, keys []string) (map[string][]float32, error)
+
+	// QueryTraces returns a map of encoded keys to a slice of floats for all
+	// traces that match the given query.
+	QueryTraces(tileKey btts.TileKey, q *regexp.Regexp) (map[string][]float32, error)
+
+	// QueryCount does the same work as QueryTraces but only returns the number
+	// of traces that would be returned.
+	QueryCount(tileKey btts.TileKey, q *regexp.Regexp) (int64, error)
+
+	// GetSource returns the name of the file that contained the point at
+	// 'index' of trace 'traceId'.
+	//
+	// The traceId must be an OPS encoded key.
+	GetSource(index int32, traceId string) (string, error)
+}
+
+// Validate that BigTableTraceStore faithfully implements the TraceStore interface.
+var _ TraceStore = (*btts.BigTableTraceStore)(nil)
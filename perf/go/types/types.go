@@ -29,10 +29,16 @@ const (
 	KMEANS_ALGO  ClusterAlgo = "kmeans"  // Cluster traces using k-means clustering on their shapes.
 	STEPFIT_ALGO ClusterAlgo = "stepfit" // Look at each trace individually and determing if it steps up or down.
 	TAIL_ALGO    ClusterAlgo = "tail"    // Whether a trace has a jumping tail (a step in the end)
+
+	MANNWHITNEYU_ALGO ClusterAlgo = "mannwhitneyu" // Look at each trace individually and use the Mann-Whitney U test to determine if it steps up or down.
+	TTEST_ALGO        ClusterAlgo = "ttest"        // Look at each trace individually and use Welch's t-test to determine if it steps up or down.
+	MULTISTEP_ALGO    ClusterAlgo = "multistep"    // Look at each trace individually and find every step up or down anywhere in the trace.
+	MEDIANMAD_ALGO    ClusterAlgo = "medianmad"    // Look at each trace individually and compare it to a median/MAD baseline built from its history.
+	EWMA_ALGO         ClusterAlgo = "ewma"         // Look at each trace individually and compare it to an exponentially weighted moving average baseline built from its history.
 )
 
 var (
-	AllClusterAlgos = []ClusterAlgo{KMEANS_ALGO, STEPFIT_ALGO, TAIL_ALGO}
+	AllClusterAlgos = []ClusterAlgo{KMEANS_ALGO, STEPFIT_ALGO, TAIL_ALGO, MANNWHITNEYU_ALGO, TTEST_ALGO, MULTISTEP_ALGO, MEDIANMAD_ALGO, EWMA_ALGO}
 )
 
 func ToClusterAlgo(s string) (ClusterAlgo, error) {
@@ -7,6 +7,7 @@ import (
 	"os"
 	"regexp"
 	"sync"
+	"time"
 
 	"go.skia.org/infra/go/git/repograph"
 	"go.skia.org/infra/go/sklog"
@@ -168,6 +169,34 @@ func (b *Blacklist) removeRule(name string) error {
 	return nil
 }
 
+// RemoveExpiredRules removes all Rules from the Blacklist which expired
+// before the given time. Returns the names of the removed Rules.
+func (b *Blacklist) RemoveExpiredRules(now time.Time) ([]string, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	removed := map[string]*Rule{}
+	for name, r := range b.Rules {
+		if r.Expired(now) {
+			removed[name] = r
+			delete(b.Rules, name)
+		}
+	}
+	if len(removed) == 0 {
+		return []string{}, nil
+	}
+	if err := b.writeOut(); err != nil {
+		for name, r := range removed {
+			b.Rules[name] = r
+		}
+		return nil, err
+	}
+	rv := make([]string, 0, len(removed))
+	for name := range removed {
+		rv = append(rv, name)
+	}
+	return rv, nil
+}
+
 // RemoveRule removes the Rule from the Blacklist.
 func (b *Blacklist) RemoveRule(name string) error {
 	for _, r := range DEFAULT_RULES {
@@ -188,12 +217,15 @@ func (b *Blacklist) RemoveRule(name string) error {
 // empty, the Rule applies for all commits.
 //
 // A Rule should specify TaskSpecPatterns or Commits or both.
+//
+// If Expires is set, the Rule no longer applies after that time.
 type Rule struct {
-	AddedBy          string   `json:"added_by"`
-	TaskSpecPatterns []string `json:"task_spec_patterns"`
-	Commits          []string `json:"commits"`
-	Description      string   `json:"description"`
-	Name             string   `json:"name"`
+	AddedBy          string    `json:"added_by"`
+	TaskSpecPatterns []string  `json:"task_spec_patterns"`
+	Commits          []string  `json:"commits"`
+	Description      string    `json:"description"`
+	Expires          time.Time `json:"expires"`
+	Name             string    `json:"name"`
 }
 
 // ValidateRule returns an error if the given Rule is not valid.
@@ -254,9 +286,15 @@ func (r *Rule) matchCommit(commit string) bool {
 	return false
 }
 
+// Expired returns true iff the Rule has an expiration time which is before
+// the given time.
+func (r *Rule) Expired(now time.Time) bool {
+	return !util.TimeIsZero(r.Expires) && r.Expires.Before(now)
+}
+
 // Match returns true iff the Rule matches the given taskSpec and commit.
 func (r *Rule) Match(taskSpec, commit string) bool {
-	return r.matchTaskSpec(taskSpec) && r.matchCommit(commit)
+	return !r.Expired(time.Now()) && r.matchTaskSpec(taskSpec) && r.matchCommit(commit)
 }
 
 // FromFile returns a Blacklist instance based on the given file. If the file
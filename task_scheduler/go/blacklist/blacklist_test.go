@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"path"
 	"testing"
+	"time"
 
 	assert "github.com/stretchr/testify/require"
 	"go.skia.org/infra/go/deepequal"
@@ -44,6 +45,49 @@ func TestAddRemove(t *testing.T) {
 	deepequal.AssertDeepEqual(t, b1, b2)
 }
 
+func TestExpiredRules(t *testing.T) {
+	testutils.SmallTest(t)
+	tmp, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, tmp)
+	f := path.Join(tmp, "blacklist.json")
+	b1, err := FromFile(f)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	expired := &Rule{
+		AddedBy:          "test@google.com",
+		TaskSpecPatterns: []string{"^expired$"},
+		Expires:          now.Add(-time.Minute),
+		Name:             "Expired Rule",
+	}
+	active := &Rule{
+		AddedBy:          "test@google.com",
+		TaskSpecPatterns: []string{"^active$"},
+		Expires:          now.Add(time.Hour),
+		Name:             "Active Rule",
+	}
+	assert.NoError(t, b1.addRule(expired))
+	assert.NoError(t, b1.addRule(active))
+	assert.False(t, b1.Match("expired", "abc123"))
+	assert.True(t, b1.Match("active", "abc123"))
+
+	removed, err := b1.RemoveExpiredRules(now)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{expired.Name}, removed)
+	b2, err := FromFile(f)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(b2.Rules))
+	assert.NotNil(t, b2.Rules[active.Name])
+	assert.True(t, b2.Match("active", "abc123"))
+
+	// Once the remaining rule expires, it no longer matches.
+	removed, err = b1.RemoveExpiredRules(now.Add(2 * time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{active.Name}, removed)
+	assert.Equal(t, 0, len(b1.Rules))
+}
+
 func TestRules(t *testing.T) {
 	testutils.SmallTest(t)
 	type testCase struct {
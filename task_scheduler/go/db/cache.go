@@ -30,6 +30,11 @@ type TaskCache interface {
 	// given commit, or nil if no such task exists.
 	GetTaskForCommit(string, string, string) (*Task, error)
 
+	// GetTaskForInputsHash retrieves the most recently created successful
+	// task with the given name and InputsHash, or nil if no such task
+	// exists.
+	GetTaskForInputsHash(string, string) (*Task, error)
+
 	// GetTasksByKey returns the tasks with the given TaskKey, sorted
 	// by creation time.
 	GetTasksByKey(*TaskKey) ([]*Task, error)
@@ -83,6 +88,8 @@ type taskCache struct {
 	tasks          map[string]*Task
 	// map[repo_name][commit_hash][task_spec_name]*Task
 	tasksByCommit map[string]map[string]map[string]*Task
+	// map[task_spec_name][inputs_hash]*Task
+	tasksByInputsHash map[string]map[string]*Task
 	// map[TaskKey]map[task_id]*Task
 	tasksByKey map[TaskKey]map[string]*Task
 	// tasksByTime is sorted by Task.Created.
@@ -204,6 +211,17 @@ func (c *taskCache) GetTaskForCommit(repo, commit, name string) (*Task, error) {
 	return nil, nil
 }
 
+// See documentation for TaskCache interface.
+func (c *taskCache) GetTaskForInputsHash(name, hash string) (*Task, error) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	if t, ok := c.tasksByInputsHash[name][hash]; ok {
+		return t.Copy(), nil
+	}
+	return nil, nil
+}
+
 // See documentation for TaskCache interface.
 func (c *taskCache) UnfinishedTasks() ([]*Task, error) {
 	c.mtx.RLock()
@@ -257,6 +275,9 @@ func (c *taskCache) expireTasks() {
 		// Tasks by commit.
 		c.removeFromTasksByCommit(task)
 
+		// Tasks by inputs hash.
+		c.removeFromTasksByInputsHash(task)
+
 		// Tasks by key.
 		byKey, ok := c.tasksByKey[task.TaskKey]
 		if ok {
@@ -281,6 +302,20 @@ func (c *taskCache) expireTasks() {
 	}
 }
 
+// removeFromTasksByInputsHash removes task from tasksByInputsHash, if it is the
+// entry for its name and inputs hash. Assumes the caller holds a lock.
+func (c *taskCache) removeFromTasksByInputsHash(task *Task) {
+	if task.InputsHash == "" {
+		return
+	}
+	if other, ok := c.tasksByInputsHash[task.Name][task.InputsHash]; ok && other.Id == task.Id {
+		delete(c.tasksByInputsHash[task.Name], task.InputsHash)
+		if len(c.tasksByInputsHash[task.Name]) == 0 {
+			delete(c.tasksByInputsHash, task.Name)
+		}
+	}
+}
+
 // insertOrUpdateTask inserts task into the cache if it is a new task, or
 // updates the existing entries if not. Assumes the caller holds a lock. This is
 // a helper for expireAndUpdate.
@@ -348,6 +383,25 @@ func (c *taskCache) insertOrUpdateTask(task *Task) {
 		}
 	}
 
+	// Remove tasks which are no longer successful from tasksByInputsHash,
+	// so that their outputs are not reused.
+	if isUpdate {
+		c.removeFromTasksByInputsHash(old)
+	}
+
+	// Insert successful tasks into tasksByInputsHash, so that their
+	// outputs may be reused.
+	if task.InputsHash != "" && task.Success() && task.IsolatedOutput != "" {
+		byHash, ok := c.tasksByInputsHash[task.Name]
+		if !ok {
+			byHash = map[string]*Task{}
+			c.tasksByInputsHash[task.Name] = byHash
+		}
+		if other, ok := byHash[task.InputsHash]; !ok || !task.Created.Before(other.Created) {
+			byHash[task.InputsHash] = task
+		}
+	}
+
 	// Unfinished tasks.
 	if !task.Done() && !task.Fake() {
 		c.unfinished[task.Id] = task
@@ -397,6 +451,7 @@ func (c *taskCache) reset() error {
 	c.queryId = queryId
 	c.tasks = map[string]*Task{}
 	c.tasksByCommit = map[string]map[string]map[string]*Task{}
+	c.tasksByInputsHash = map[string]map[string]*Task{}
 	c.tasksByKey = map[TaskKey]map[string]*Task{}
 	c.unfinished = map[string]*Task{}
 	c.expireAndUpdate(tasks)
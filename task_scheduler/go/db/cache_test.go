@@ -101,6 +101,87 @@ func TestTaskCacheKnownTaskName(t *testing.T) {
 	assert.True(t, c.KnownTaskName(t3.Repo, t3.Name))
 }
 
+func TestTaskCacheGetTaskForInputsHash(t *testing.T) {
+	testutils.SmallTest(t)
+	db := NewInMemoryTaskDB()
+	w, err := window.New(time.Hour, 0, nil)
+	assert.NoError(t, err)
+	c, err := NewTaskCache(db, w)
+	assert.NoError(t, err)
+
+	test := func(hash string, expect *Task) {
+		found, err := c.GetTaskForInputsHash("Test-Task", hash)
+		assert.NoError(t, err)
+		if expect == nil {
+			assert.Nil(t, found)
+		} else {
+			deepequal.AssertDeepEqual(t, expect, found)
+		}
+	}
+
+	// Unfinished tasks are not found.
+	startTime := time.Now().Add(-30 * time.Minute) // Arbitrary starting point.
+	t1 := MakeTestTask(startTime, []string{"a", "b"})
+	t1.InputsHash = "hash1"
+	assert.NoError(t, db.PutTask(t1))
+	assert.NoError(t, c.Update())
+	test("hash1", nil)
+
+	// Neither are failed tasks.
+	t1.Status = TASK_STATUS_FAILURE
+	t1.IsolatedOutput = "out1"
+	assert.NoError(t, db.PutTask(t1))
+	assert.NoError(t, c.Update())
+	test("hash1", nil)
+
+	// Successful tasks are found.
+	t2 := MakeTestTask(startTime.Add(time.Minute), []string{"c"})
+	t2.InputsHash = "hash1"
+	t2.Status = TASK_STATUS_SUCCESS
+	t2.IsolatedOutput = "out2"
+	assert.NoError(t, db.PutTask(t2))
+	assert.NoError(t, c.Update())
+	test("hash1", t2)
+	test("hash2", nil)
+
+	// The most recent task wins.
+	t3 := MakeTestTask(startTime.Add(2*time.Minute), []string{"d"})
+	t3.InputsHash = "hash1"
+	t3.Status = TASK_STATUS_SUCCESS
+	t3.IsolatedOutput = "out2"
+	t3.ReusedFrom = t2.Id
+	assert.NoError(t, db.PutTask(t3))
+	assert.NoError(t, c.Update())
+	test("hash1", t3)
+
+	// Tasks with a different name are not found.
+	t4 := MakeTestTask(startTime.Add(3*time.Minute), []string{"e"})
+	t4.Name = "Another-Task"
+	t4.InputsHash = "hash2"
+	t4.Status = TASK_STATUS_SUCCESS
+	t4.IsolatedOutput = "out4"
+	assert.NoError(t, db.PutTask(t4))
+	assert.NoError(t, c.Update())
+	test("hash2", nil)
+	found, err := c.GetTaskForInputsHash(t4.Name, "hash2")
+	assert.NoError(t, err)
+	deepequal.AssertDeepEqual(t, t4, found)
+
+	// Tasks which stop being successful are no longer found.
+	t3.Status = TASK_STATUS_MISHAP
+	assert.NoError(t, db.PutTask(t3))
+	assert.NoError(t, c.Update())
+	test("hash1", nil)
+	t3.Status = TASK_STATUS_SUCCESS
+	assert.NoError(t, db.PutTask(t3))
+	assert.NoError(t, c.Update())
+	test("hash1", t3)
+	t3.IsolatedOutput = ""
+	assert.NoError(t, db.PutTask(t3))
+	assert.NoError(t, c.Update())
+	test("hash1", nil)
+}
+
 func TestTaskCacheGetTasksFromDateRange(t *testing.T) {
 	testutils.SmallTest(t)
 	db := NewInMemoryTaskDB()
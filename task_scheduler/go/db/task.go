@@ -125,6 +125,12 @@ type Task struct {
 	// URL-safe.
 	Id string `json:"id"`
 
+	// InputsHash is a hash of the inputs of the Task, ie. its isolated
+	// input, command, dimensions, CIPD packages, etc. It is only set for
+	// Tasks whose TaskSpecs are idempotent and is used to find previous
+	// Tasks whose outputs may be reused.
+	InputsHash string `json:"inputsHash"`
+
 	// IsolatedOutput is the isolated hash of any outputs produced by this Task.
 	// Filled in when the task is completed. This field will not be set if the
 	// Task does not correspond to a Swarming task.
@@ -147,6 +153,11 @@ type Task struct {
 	// RetryOf is the ID of the task which this task is a retry of, if any.
 	RetryOf string `json:"retryOf"`
 
+	// ReusedFrom is the ID of the task whose outputs were reused instead of
+	// running this task, if any. Such tasks have the same InputsHash,
+	// IsolatedOutput, SwarmingBotId, and SwarmingTaskId as the original.
+	ReusedFrom string `json:"reusedFrom"`
+
 	// Started is the time the task started running, or zero if the task is
 	// pending, or the same as Finished if the task never ran.
 	Started time.Time `json:"started"`
@@ -159,8 +170,9 @@ type Task struct {
 	// if the task is still pending.
 	SwarmingBotId string `json:"swarmingBotId"`
 
-	// SwarmingTaskId is the Swarming task ID. This field will not be set if the
-	// Task does not correspond to a Swarming task.
+	// SwarmingTaskId is the Swarming task ID, or the ID of the task in whichever
+	// TaskExecutor ran it. This field will not be set if the Task does not
+	// correspond to a Swarming task.
 	SwarmingTaskId string `json:"swarmingTaskId"`
 
 	// TaskKey is a struct which describes aspects of the Task related
@@ -349,8 +361,17 @@ func UpdateDBFromSwarmingTask(db TaskDB, s *swarming_api.SwarmingRpcsTaskResult)
 	if err != nil {
 		return err
 	}
-	_, err = UpdateTaskWithRetries(db, id, func(task *Task) error {
-		modified, err := task.UpdateFromSwarming(s)
+	return UpdateTaskIfModified(db, id, func(task *Task) (bool, error) {
+		return task.UpdateFromSwarming(s)
+	})
+}
+
+// UpdateTaskIfModified updates the task with the given ID in db using f, which
+// returns true iff it modified the task. The task is only written to db if it
+// was modified.
+func UpdateTaskIfModified(db TaskDB, id string, f func(*Task) (bool, error)) error {
+	_, err := UpdateTaskWithRetries(db, id, func(task *Task) error {
+		modified, err := f(task)
 		if err != nil {
 			return err
 		}
@@ -387,12 +408,14 @@ func (t *Task) Copy() *Task {
 		DbModified:     t.DbModified,
 		Finished:       t.Finished,
 		Id:             t.Id,
+		InputsHash:     t.InputsHash,
 		IsolatedOutput: t.IsolatedOutput,
 		Jobs:           util.CopyStringSlice(t.Jobs),
 		MaxAttempts:    t.MaxAttempts,
 		ParentTaskIds:  util.CopyStringSlice(t.ParentTaskIds),
 		Properties:     util.CopyStringMap(t.Properties),
 		RetryOf:        t.RetryOf,
+		ReusedFrom:     t.ReusedFrom,
 		Started:        t.Started,
 		Status:         t.Status,
 		SwarmingBotId:  t.SwarmingBotId,
@@ -658,6 +658,7 @@ func TestCopyTask(t *testing.T) {
 		DbModified:     now.Add(time.Millisecond),
 		Finished:       now.Add(time.Second),
 		Id:             "42",
+		InputsHash:     "same-old",
 		IsolatedOutput: "lonely-result",
 		Jobs:           []string{"123abc", "456def"},
 		MaxAttempts:    2,
@@ -667,6 +668,7 @@ func TestCopyTask(t *testing.T) {
 			"awesome": "true",
 		},
 		RetryOf:        "41",
+		ReusedFrom:     "40",
 		Started:        now.Add(time.Minute),
 		Status:         TASK_STATUS_MISHAP,
 		SwarmingBotId:  "ENIAC",
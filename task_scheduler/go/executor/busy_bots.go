@@ -1,4 +1,4 @@
-package scheduling
+package executor
 
 import (
 	"sort"
@@ -61,7 +61,7 @@ func newBusyBots() *busyBots {
 }
 
 // Return a space-separated string of sorted dimensions and values, filtered by dimensionWhitelist.
-// Similar to flatten in task_scheduler.go. When there are multiple values for a dimension, the
+// Similar to flatten in scheduling/task_scheduler.go. When there are multiple values for a dimension, the
 // longest is used. (The longest value is usually the most interesting.)
 func dimensionsString(dims []*swarming_api.SwarmingRpcsStringListPair) string {
 	vals := make(map[string]string, len(dimensionWhitelist))
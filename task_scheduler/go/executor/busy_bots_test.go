@@ -1,4 +1,4 @@
-package scheduling
+package executor
 
 import (
 	"fmt"
@@ -11,6 +11,25 @@ import (
 	"go.skia.org/infra/task_scheduler/go/db"
 )
 
+var (
+	androidTaskDims = map[string]string{
+		"pool":        "Skia",
+		"os":          "Android",
+		"device_type": "grouper",
+	}
+
+	androidBotDims = map[string][]string{
+		"pool":        {"Skia"},
+		"os":          {"Android"},
+		"device_type": {"grouper"},
+	}
+
+	linuxBotDims = map[string][]string{
+		"os":   {"Ubuntu"},
+		"pool": {"Skia"},
+	}
+)
+
 func TestBusyBots(t *testing.T) {
 	testutils.SmallTest(t)
 
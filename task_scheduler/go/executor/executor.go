@@ -0,0 +1,83 @@
+// Package executor contains the backends which run the tasks scheduled by the
+// Task Scheduler, eg. Swarming.
+package executor
+
+import (
+	"context"
+	"time"
+
+	"go.skia.org/infra/task_scheduler/go/db"
+	"go.skia.org/infra/task_scheduler/go/specs"
+)
+
+// Worker is a machine which is able to run tasks, eg. a Swarming bot.
+type Worker struct {
+	// Id uniquely identifies the Worker within the TaskExecutor.
+	Id string `json:"id"`
+
+	// Dimensions describe the Worker, in "key:value" format. A task may
+	// only run on a Worker if the Worker has all of the task's dimensions.
+	Dimensions []string `json:"dimensions"`
+}
+
+// HasDimensions returns true iff the Worker has all of the given dimensions.
+func (w *Worker) HasDimensions(dims []string) bool {
+	for _, d := range dims {
+		found := false
+		for _, wd := range w.Dimensions {
+			if d == wd {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// TaskRequest describes a task to be run by a TaskExecutor.
+type TaskRequest struct {
+	// TaskId is the ID of the db.Task which corresponds to this request.
+	TaskId string
+
+	// Attempt is the attempt number of the task, starting with zero.
+	Attempt int
+
+	// IsolatedInput is the isolated hash of the inputs of the task, if any.
+	IsolatedInput string
+
+	// ParentTaskIds are IDs of tasks which satisfied the task's dependencies.
+	ParentTaskIds []string
+
+	// RetryOf is the ID of the task which this task is a retry of, if any.
+	RetryOf string
+
+	// TaskSpec describes how to run the task. Any variables in the command,
+	// extra args and extra tags have already been replaced.
+	TaskSpec *specs.TaskSpec
+
+	db.TaskKey
+}
+
+// TaskExecutor is the interface between the Task Scheduler and the backend
+// which actually runs the tasks.
+type TaskExecutor interface {
+	// GetFreeWorkers returns the Workers which are currently able to run
+	// a task.
+	GetFreeWorkers(ctx context.Context) ([]*Worker, error)
+
+	// TriggerTask starts the given task. Returns the ID of the task within
+	// the TaskExecutor, which is stored as db.Task.SwarmingTaskId, and the
+	// creation time of the task.
+	TriggerTask(ctx context.Context, req *TaskRequest) (string, time.Time, error)
+
+	// UpdateTask updates the given db.Task with the current state of the
+	// corresponding task in the TaskExecutor. Returns true iff the db.Task
+	// was modified.
+	UpdateTask(ctx context.Context, task *db.Task) (bool, error)
+
+	// CancelTask cancels the task with the given TaskExecutor ID.
+	CancelTask(ctx context.Context, id string) error
+}
@@ -0,0 +1,360 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.skia.org/infra/go/exec"
+	"go.skia.org/infra/go/git"
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/task_scheduler/go/db"
+)
+
+const (
+	// LOCAL_TASK_LOG is the name of the file within the task directory
+	// which receives the combined output of a task run by the
+	// LocalTaskExecutor.
+	LOCAL_TASK_LOG = "task.log"
+
+	// ENV_PARENT_OUTPUT_DIRS is the environment variable which holds the
+	// output directories of the task's parent tasks, in the order of
+	// TaskRequest.ParentTaskIds, separated by os.PathListSeparator.
+	ENV_PARENT_OUTPUT_DIRS = "PARENT_OUTPUT_DIRS"
+
+	// LOCAL_TASK_RETENTION is how long the LocalTaskExecutor keeps track of
+	// a task after it finishes, which gives the Task Scheduler time to
+	// record its result.
+	LOCAL_TASK_RETENTION = time.Hour
+)
+
+// localTask tracks a task run by the LocalTaskExecutor.
+type localTask struct {
+	id       string
+	dir      string
+	worker   string
+	process  exec.Process
+	status   db.TaskStatus
+	started  time.Time
+	finished time.Time
+	canceled bool
+	timedOut bool
+}
+
+// LocalTaskExecutor is a TaskExecutor which runs the commands of tasks as
+// subprocesses on the local machine. Each of the configured Workers runs at
+// most one task at a time. Each task runs in its own directory, which
+// contains a checkout of the repo at the task's RepoState. Task state is only
+// kept in memory, so any tasks which are running when the process exits are
+// lost, and finished tasks are forgotten after LOCAL_TASK_RETENTION.
+// Successful tasks have their working directory as their IsolatedOutput,
+// which is passed to the tasks which depend on them via
+// ENV_PARENT_OUTPUT_DIRS. Isolated inputs are not supported.
+type LocalTaskExecutor struct {
+	mtx       sync.Mutex
+	busy      map[string]string
+	retention time.Duration
+	tasks     map[string]*localTask
+	workdir   string
+	workers   []*Worker
+}
+
+// NewLocalTaskExecutor returns a LocalTaskExecutor instance which runs tasks
+// on the given Workers, using subdirectories of workdir as the working
+// directories of the tasks.
+func NewLocalTaskExecutor(workdir string, workers []*Worker) (*LocalTaskExecutor, error) {
+	ids := make(map[string]bool, len(workers))
+	for _, w := range workers {
+		if w.Id == "" {
+			return nil, fmt.Errorf("Local workers must have an ID.")
+		}
+		if ids[w.Id] {
+			return nil, fmt.Errorf("Duplicate local worker ID %q", w.Id)
+		}
+		ids[w.Id] = true
+	}
+	if err := os.MkdirAll(workdir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &LocalTaskExecutor{
+		busy:      map[string]string{},
+		retention: LOCAL_TASK_RETENTION,
+		tasks:     map[string]*localTask{},
+		workdir:   workdir,
+		workers:   workers,
+	}, nil
+}
+
+// ReadLocalWorkers reads a list of Workers from the given JSON file.
+func ReadLocalWorkers(file string) ([]*Worker, error) {
+	var rv []*Worker
+	if err := util.WithReadFile(file, func(f io.Reader) error {
+		return json.NewDecoder(f).Decode(&rv)
+	}); err != nil {
+		return nil, fmt.Errorf("Failed to read local workers from %s: %s", file, err)
+	}
+	return rv, nil
+}
+
+// See documentation for TaskExecutor interface.
+func (e *LocalTaskExecutor) GetFreeWorkers(ctx context.Context) ([]*Worker, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	rv := make([]*Worker, 0, len(e.workers))
+	for _, w := range e.workers {
+		if _, ok := e.busy[w.Id]; !ok {
+			rv = append(rv, w)
+		}
+	}
+	return rv, nil
+}
+
+// See documentation for TaskExecutor interface.
+func (e *LocalTaskExecutor) TriggerTask(ctx context.Context, req *TaskRequest) (string, time.Time, error) {
+	if len(req.TaskSpec.Command) == 0 {
+		return "", time.Time{}, fmt.Errorf("Task %s has no command; cannot run it locally.", req.TaskId)
+	}
+	if req.IsolatedInput != "" {
+		return "", time.Time{}, fmt.Errorf("Task %s has isolated input %s; isolated inputs are not supported by the local task executor.", req.TaskId, req.IsolatedInput)
+	}
+
+	// Reserve a worker. Setting up the task directory may take a while, so
+	// don't hold the lock while doing so.
+	worker, err := e.reserveWorker(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	taskDir, env, err := e.setupTask(ctx, req)
+	if err != nil {
+		e.mtx.Lock()
+		delete(e.busy, worker.Id)
+		e.mtx.Unlock()
+		return "", time.Time{}, fmt.Errorf("Failed to set up task %s: %s", req.TaskId, err)
+	}
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	logFile, err := os.Create(path.Join(taskDir, LOCAL_TASK_LOG))
+	if err != nil {
+		delete(e.busy, worker.Id)
+		return "", time.Time{}, err
+	}
+	args := append(util.CopyStringSlice(req.TaskSpec.Command[1:]), req.TaskSpec.ExtraArgs...)
+	process, done, err := exec.RunIndefinitely(&exec.Command{
+		Name:           req.TaskSpec.Command[0],
+		Args:           args,
+		Env:            env,
+		InheritEnv:     true,
+		Dir:            taskDir,
+		CombinedOutput: logFile,
+	})
+	if err != nil {
+		util.Close(logFile)
+		delete(e.busy, worker.Id)
+		return "", time.Time{}, fmt.Errorf("Failed to start task %s: %s", req.TaskId, err)
+	}
+
+	now := time.Now().UTC()
+	t := &localTask{
+		id:      req.TaskId,
+		dir:     taskDir,
+		worker:  worker.Id,
+		process: process,
+		status:  db.TASK_STATUS_RUNNING,
+		started: now,
+	}
+	e.tasks[t.id] = t
+	go e.waitForTask(t, done, logFile, req.TaskSpec.ExecutionTimeout)
+	return t.id, now, nil
+}
+
+// reserveWorker marks a free Worker which matches the dimensions of the given
+// task as busy with the task and returns it.
+func (e *LocalTaskExecutor) reserveWorker(req *TaskRequest) (*Worker, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	if _, ok := e.tasks[req.TaskId]; ok {
+		return nil, fmt.Errorf("Task %s has already been triggered.", req.TaskId)
+	}
+	for _, id := range e.busy {
+		if id == req.TaskId {
+			return nil, fmt.Errorf("Task %s has already been triggered.", req.TaskId)
+		}
+	}
+	for _, w := range e.workers {
+		if _, ok := e.busy[w.Id]; !ok && w.HasDimensions(req.TaskSpec.Dimensions) {
+			e.busy[w.Id] = req.TaskId
+			return w, nil
+		}
+	}
+	return nil, fmt.Errorf("No free local worker matches dimensions %v for task %s", req.TaskSpec.Dimensions, req.TaskId)
+}
+
+// setupTask creates the working directory of the given task, checks out the
+// repo at the task's RepoState into it and returns the directory along with
+// the environment of the task.
+func (e *LocalTaskExecutor) setupTask(ctx context.Context, req *TaskRequest) (string, []string, error) {
+	parentDirs := make([]string, 0, len(req.ParentTaskIds))
+	for _, id := range req.ParentTaskIds {
+		dir := path.Join(e.workdir, id)
+		if _, err := os.Stat(dir); err != nil {
+			return "", nil, fmt.Errorf("Output of parent task %s is not available: %s", id, err)
+		}
+		parentDirs = append(parentDirs, dir)
+	}
+
+	taskDir := path.Join(e.workdir, req.TaskId)
+	if err := os.MkdirAll(taskDir, os.ModePerm); err != nil {
+		return "", nil, err
+	}
+	if req.RepoState.Valid() {
+		if err := checkoutRepoState(ctx, req.RepoState, taskDir); err != nil {
+			return "", nil, err
+		}
+	}
+
+	env := make([]string, 0, len(req.TaskSpec.Environment)+1)
+	for k, v := range req.TaskSpec.Environment {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	env = append(env, fmt.Sprintf("%s=%s", ENV_PARENT_OUTPUT_DIRS, strings.Join(parentDirs, string(os.PathListSeparator))))
+	sort.Strings(env)
+	return taskDir, env, nil
+}
+
+// checkoutRepoState checks out the given RepoState into a subdirectory of the
+// given directory, named after the repo, as when the repo is isolated for a
+// Swarming task. The patch of a try job is applied to the working copy without
+// committing it.
+func checkoutRepoState(ctx context.Context, rs db.RepoState, dir string) error {
+	co, err := git.NewCheckout(ctx, rs.Repo, dir)
+	if err != nil {
+		return err
+	}
+	if _, err := co.Git(ctx, "checkout", "--force", rs.Revision); err != nil {
+		return err
+	}
+	if rs.IsTryJob() {
+		patchRepo := rs.Repo
+		if rs.PatchRepo != "" {
+			patchRepo = rs.PatchRepo
+		}
+		if err := co.FetchRefFromRepo(ctx, patchRepo, rs.GetPatchRef()); err != nil {
+			return err
+		}
+		if _, err := co.Git(ctx, "cherry-pick", "--no-commit", "FETCH_HEAD"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForTask waits for the given task to finish, killing it if it exceeds
+// the given timeout, and records the result.
+func (e *LocalTaskExecutor) waitForTask(t *localTask, done <-chan error, logFile *os.File, timeout time.Duration) {
+	defer util.Close(logFile)
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+	var err error
+	select {
+	case err = <-done:
+	case <-timeoutCh:
+		e.mtx.Lock()
+		t.timedOut = true
+		e.mtx.Unlock()
+		if killErr := t.process.Kill(); killErr != nil {
+			sklog.Errorf("Failed to kill timed out task %s: %s", t.id, killErr)
+		}
+		err = <-done
+	}
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	t.finished = time.Now().UTC()
+	if t.canceled || t.timedOut {
+		t.status = db.TASK_STATUS_MISHAP
+	} else if err != nil {
+		t.status = db.TASK_STATUS_FAILURE
+	} else {
+		t.status = db.TASK_STATUS_SUCCESS
+	}
+	delete(e.busy, t.worker)
+	sklog.Infof("Local task %s finished on %s with status %s", t.id, t.worker, t.status)
+
+	// Forget about the task once the Task Scheduler has had time to record
+	// its result.
+	time.AfterFunc(e.retention, func() {
+		e.mtx.Lock()
+		defer e.mtx.Unlock()
+		delete(e.tasks, t.id)
+	})
+}
+
+// See documentation for TaskExecutor interface.
+func (e *LocalTaskExecutor) UpdateTask(ctx context.Context, task *db.Task) (bool, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	t, ok := e.tasks[task.SwarmingTaskId]
+	if !ok {
+		// The task was lost, eg. because the process restarted, or it
+		// finished long ago.
+		if task.Done() {
+			return false, nil
+		}
+		task.Status = db.TASK_STATUS_MISHAP
+		task.Finished = time.Now().UTC()
+		if util.TimeIsZero(task.Started) {
+			task.Started = task.Finished
+		}
+		return true, nil
+	}
+	modified := false
+	if task.Status != t.status {
+		task.Status = t.status
+		modified = true
+	}
+	if !task.Started.Equal(t.started) {
+		task.Started = t.started
+		modified = true
+	}
+	if !task.Finished.Equal(t.finished) {
+		task.Finished = t.finished
+		modified = true
+	}
+	if task.SwarmingBotId != t.worker {
+		task.SwarmingBotId = t.worker
+		modified = true
+	}
+	if t.status == db.TASK_STATUS_SUCCESS && task.IsolatedOutput != t.dir {
+		task.IsolatedOutput = t.dir
+		modified = true
+	}
+	return modified, nil
+}
+
+// See documentation for TaskExecutor interface.
+func (e *LocalTaskExecutor) CancelTask(ctx context.Context, id string) error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	t, ok := e.tasks[id]
+	if !ok {
+		return fmt.Errorf("No such local task %s", id)
+	}
+	if t.status != db.TASK_STATUS_RUNNING {
+		return nil
+	}
+	t.canceled = true
+	return t.process.Kill()
+}
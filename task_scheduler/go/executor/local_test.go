@@ -0,0 +1,247 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	git_testutils "go.skia.org/infra/go/git/testutils"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/task_scheduler/go/db"
+	"go.skia.org/infra/task_scheduler/go/specs"
+)
+
+func TestWorkerHasDimensions(t *testing.T) {
+	testutils.SmallTest(t)
+	w := &Worker{
+		Id:         "w1",
+		Dimensions: []string{"os:Linux", "pool:Skia"},
+	}
+	assert.True(t, w.HasDimensions(nil))
+	assert.True(t, w.HasDimensions([]string{"os:Linux"}))
+	assert.True(t, w.HasDimensions([]string{"pool:Skia", "os:Linux"}))
+	assert.False(t, w.HasDimensions([]string{"os:Mac"}))
+	assert.False(t, w.HasDimensions([]string{"os:Linux", "gpu:none"}))
+}
+
+// waitForLocalTask waits for the given task to finish and returns the
+// updated db.Task.
+func waitForLocalTask(t *testing.T, e *LocalTaskExecutor, id string) *db.Task {
+	task := &db.Task{SwarmingTaskId: id}
+	assert.NoError(t, testutils.EventuallyConsistent(10*time.Second, func() error {
+		_, err := e.UpdateTask(context.Background(), task)
+		assert.NoError(t, err)
+		if !task.Done() {
+			return testutils.TryAgainErr
+		}
+		return nil
+	}))
+	return task
+}
+
+func TestLocalTaskExecutor(t *testing.T) {
+	testutils.SmallTest(t)
+	ctx := context.Background()
+	wd, cleanup := testutils.TempDir(t)
+	defer cleanup()
+
+	e, err := NewLocalTaskExecutor(wd, []*Worker{
+		{Id: "linux", Dimensions: []string{"os:Linux"}},
+		{Id: "mac", Dimensions: []string{"os:Mac"}},
+	})
+	assert.NoError(t, err)
+
+	// No worker matches the dimensions.
+	req := &TaskRequest{
+		TaskId: "task1",
+		TaskSpec: &specs.TaskSpec{
+			Command:    []string{"sh", "-c"},
+			Dimensions: []string{"os:Windows"},
+		},
+	}
+	_, _, err = e.TriggerTask(ctx, req)
+	assert.Error(t, err)
+
+	// Successful task. Verify that the environment and extra args are
+	// passed to the command.
+	req.TaskSpec.Dimensions = []string{"os:Linux"}
+	req.TaskSpec.Environment = map[string]string{"MSG": "hello"}
+	req.TaskSpec.ExtraArgs = []string{"echo $MSG"}
+	id, _, err := e.TriggerTask(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "task1", id)
+	task := waitForLocalTask(t, e, id)
+	assert.Equal(t, db.TASK_STATUS_SUCCESS, task.Status)
+	assert.Equal(t, "linux", task.SwarmingBotId)
+	assert.Equal(t, path.Join(wd, id), task.IsolatedOutput)
+	assert.False(t, task.Finished.Before(task.Started))
+	output, err := ioutil.ReadFile(path.Join(wd, id, LOCAL_TASK_LOG))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(output))
+
+	// The worker is free again, so nothing changes on a second update.
+	workers, err := e.GetFreeWorkers(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(workers))
+	modified, err := e.UpdateTask(ctx, task)
+	assert.NoError(t, err)
+	assert.False(t, modified)
+
+	// Failed task.
+	id, _, err = e.TriggerTask(ctx, &TaskRequest{
+		TaskId: "task2",
+		TaskSpec: &specs.TaskSpec{
+			Command:    []string{"false"},
+			Dimensions: []string{"os:Mac"},
+		},
+	})
+	assert.NoError(t, err)
+	task = waitForLocalTask(t, e, id)
+	assert.Equal(t, db.TASK_STATUS_FAILURE, task.Status)
+	assert.Equal(t, "mac", task.SwarmingBotId)
+	assert.Equal(t, "", task.IsolatedOutput)
+
+	// Timed out task.
+	id, _, err = e.TriggerTask(ctx, &TaskRequest{
+		TaskId: "task3",
+		TaskSpec: &specs.TaskSpec{
+			Command:          []string{"sleep", "60"},
+			ExecutionTimeout: 10 * time.Millisecond,
+		},
+	})
+	assert.NoError(t, err)
+	task = waitForLocalTask(t, e, id)
+	assert.Equal(t, db.TASK_STATUS_MISHAP, task.Status)
+
+	// Canceled task. The worker is busy while the task is running.
+	id, _, err = e.TriggerTask(ctx, &TaskRequest{
+		TaskId: "task4",
+		TaskSpec: &specs.TaskSpec{
+			Command:    []string{"sleep", "60"},
+			Dimensions: []string{"os:Linux"},
+		},
+	})
+	assert.NoError(t, err)
+	workers, err = e.GetFreeWorkers(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(workers))
+	assert.Equal(t, "mac", workers[0].Id)
+	assert.NoError(t, e.CancelTask(ctx, id))
+	task = waitForLocalTask(t, e, id)
+	assert.Equal(t, db.TASK_STATUS_MISHAP, task.Status)
+
+	// Unknown tasks are marked as mishaps.
+	task = &db.Task{SwarmingTaskId: "bogus"}
+	modified, err = e.UpdateTask(ctx, task)
+	assert.NoError(t, err)
+	assert.True(t, modified)
+	assert.Equal(t, db.TASK_STATUS_MISHAP, task.Status)
+
+	// Tasks with isolated inputs can't be run locally.
+	_, _, err = e.TriggerTask(ctx, &TaskRequest{
+		TaskId:        "task5",
+		IsolatedInput: "abc123",
+		TaskSpec: &specs.TaskSpec{
+			Command: []string{"true"},
+		},
+	})
+	assert.Error(t, err)
+
+	// Finished tasks are eventually forgotten.
+	e.retention = 0
+	id, _, err = e.TriggerTask(ctx, &TaskRequest{
+		TaskId: "task6",
+		TaskSpec: &specs.TaskSpec{
+			Command: []string{"true"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, testutils.EventuallyConsistent(10*time.Second, func() error {
+		e.mtx.Lock()
+		defer e.mtx.Unlock()
+		if _, ok := e.tasks[id]; ok {
+			return testutils.TryAgainErr
+		}
+		return nil
+	}))
+}
+
+func TestLocalTaskExecutorRepoState(t *testing.T) {
+	testutils.LargeTest(t)
+	ctx := context.Background()
+	gb := git_testutils.GitInit(t, ctx)
+	defer gb.Cleanup()
+	gb.Add(ctx, "a.txt", "v1")
+	c1 := gb.CommitMsg(ctx, "c1")
+	gb.Add(ctx, "a.txt", "v2")
+	c2 := gb.CommitMsg(ctx, "c2")
+	gb.CreateFakeGerritCLGen(ctx, "12345", "1")
+	repoDir := strings.TrimSuffix(path.Base(gb.RepoUrl()), ".git")
+
+	wd, cleanup := testutils.TempDir(t)
+	defer cleanup()
+	e, err := NewLocalTaskExecutor(wd, []*Worker{{Id: "linux"}})
+	assert.NoError(t, err)
+
+	// The repo is checked out at the task's revision.
+	id, _, err := e.TriggerTask(ctx, &TaskRequest{
+		TaskId: "parent",
+		TaskKey: db.TaskKey{
+			RepoState: db.RepoState{
+				Repo:     gb.RepoUrl(),
+				Revision: c1,
+			},
+		},
+		TaskSpec: &specs.TaskSpec{
+			Command: []string{"sh", "-c", fmt.Sprintf("cp %s/a.txt out.txt", repoDir)},
+		},
+	})
+	assert.NoError(t, err)
+	parent := waitForLocalTask(t, e, id)
+	assert.Equal(t, db.TASK_STATUS_SUCCESS, parent.Status)
+
+	// The patch of a try job is applied, and the outputs of the parent
+	// tasks are available.
+	id, _, err = e.TriggerTask(ctx, &TaskRequest{
+		TaskId:        "child",
+		ParentTaskIds: []string{"parent"},
+		TaskKey: db.TaskKey{
+			RepoState: db.RepoState{
+				Patch: db.Patch{
+					Issue:    "12345",
+					Patchset: "1",
+					Server:   gb.RepoUrl(),
+				},
+				Repo:     gb.RepoUrl(),
+				Revision: c2,
+			},
+		},
+		TaskSpec: &specs.TaskSpec{
+			Command: []string{"sh", "-c", fmt.Sprintf("cat $%s/out.txt %s/a.txt && test -f %s/somefile", ENV_PARENT_OUTPUT_DIRS, repoDir, repoDir)},
+		},
+	})
+	assert.NoError(t, err)
+	task := waitForLocalTask(t, e, id)
+	assert.Equal(t, db.TASK_STATUS_SUCCESS, task.Status)
+	output, err := ioutil.ReadFile(path.Join(wd, id, LOCAL_TASK_LOG))
+	assert.NoError(t, err)
+	assert.Equal(t, "v1v2", string(output))
+
+	// Tasks whose parents' outputs are missing can't be run.
+	_, _, err = e.TriggerTask(ctx, &TaskRequest{
+		TaskId:        "orphan",
+		ParentTaskIds: []string{"bogus"},
+		TaskSpec: &specs.TaskSpec{
+			Command: []string{"true"},
+		},
+	})
+	assert.Error(t, err)
+	workers, err := e.GetFreeWorkers(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(workers))
+}
@@ -0,0 +1,299 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/task_scheduler/go/db"
+)
+
+const (
+	// SIMULATED_ISOLATED_OUTPUT is the isolated output of successful tasks
+	// run by the SimulatedTaskExecutor, which allows tasks which depend on
+	// them to be scheduled.
+	SIMULATED_ISOLATED_OUTPUT = "simulated"
+)
+
+// simulatedWorker tracks a Worker of the SimulatedTaskExecutor.
+type simulatedWorker struct {
+	*Worker
+	online  time.Time
+	offline time.Time
+	// busy is the total time spent running tasks which have finished.
+	busy time.Duration
+	// task is the ID of the running task, if any.
+	task string
+}
+
+// isOnline returns true iff the worker is available at the given time.
+func (w *simulatedWorker) isOnline(now time.Time) bool {
+	return !now.Before(w.online) && now.Before(w.offline)
+}
+
+// simulatedTask tracks a task "run" by the SimulatedTaskExecutor.
+type simulatedTask struct {
+	id         string
+	dimensions []string
+	duration   time.Duration
+	worker     string
+	status     db.TaskStatus
+	created    time.Time
+	started    time.Time
+	finished   time.Time
+}
+
+// SimulatedTaskExecutor is a TaskExecutor which does not actually run tasks.
+// Instead, it pretends to run them on a set of Workers according to a virtual
+// clock, which is advanced by calling SetTime. Tasks which are triggered while
+// no matching Worker is free stay pending until one becomes free. Tasks are
+// only started at the times passed to SetTime, so the granularity of the
+// simulation is determined by the caller. All tasks succeed after running for
+// the duration given for their TaskSpec.
+type SimulatedTaskExecutor struct {
+	defaultDuration time.Duration
+	durations       map[string]time.Duration
+	mtx             sync.Mutex
+	now             time.Time
+	pending         []*simulatedTask
+	start           time.Time
+	tasks           map[string]*simulatedTask
+	workers         []*simulatedWorker
+}
+
+// NewSimulatedTaskExecutor returns a SimulatedTaskExecutor instance whose
+// clock starts at the given time. Tasks take the duration given in durations
+// for their TaskSpec name, or defaultDuration if not present.
+func NewSimulatedTaskExecutor(start time.Time, durations map[string]time.Duration, defaultDuration time.Duration) *SimulatedTaskExecutor {
+	return &SimulatedTaskExecutor{
+		defaultDuration: defaultDuration,
+		durations:       durations,
+		now:             start,
+		start:           start,
+		tasks:           map[string]*simulatedTask{},
+	}
+}
+
+// AddWorker adds a Worker which is available from online until offline.
+func (e *SimulatedTaskExecutor) AddWorker(w *Worker, online, offline time.Time) error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	for _, existing := range e.workers {
+		if existing.Id == w.Id {
+			return fmt.Errorf("Duplicate simulated worker ID %q", w.Id)
+		}
+	}
+	e.workers = append(e.workers, &simulatedWorker{
+		Worker:  w,
+		online:  online,
+		offline: offline,
+	})
+	sort.Slice(e.workers, func(i, j int) bool {
+		return e.workers[i].Id < e.workers[j].Id
+	})
+	return nil
+}
+
+// Now returns the current time of the virtual clock.
+func (e *SimulatedTaskExecutor) Now() time.Time {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return e.now
+}
+
+// SetTime advances the virtual clock to the given time, finishing any tasks
+// whose duration has elapsed and starting pending tasks on the Workers which
+// are free at the given time.
+func (e *SimulatedTaskExecutor) SetTime(now time.Time) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	if now.Before(e.now) {
+		return
+	}
+	e.now = now
+	for _, w := range e.workers {
+		if w.task == "" {
+			continue
+		}
+		t := e.tasks[w.task]
+		if end := t.started.Add(t.duration); !end.After(now) {
+			t.finished = end
+			t.status = db.TASK_STATUS_SUCCESS
+			w.busy += t.duration
+			w.task = ""
+		}
+	}
+	pending := make([]*simulatedTask, 0, len(e.pending))
+	for _, t := range e.pending {
+		if !e.startTask(t) {
+			pending = append(pending, t)
+		}
+	}
+	e.pending = pending
+}
+
+// startTask starts the given task on the first free Worker which matches its
+// dimensions, if any. Returns true iff the task was started. Assumes that the
+// caller holds e.mtx.
+func (e *SimulatedTaskExecutor) startTask(t *simulatedTask) bool {
+	for _, w := range e.workers {
+		if w.task == "" && w.isOnline(e.now) && w.HasDimensions(t.dimensions) {
+			t.worker = w.Id
+			t.started = e.now
+			t.status = db.TASK_STATUS_RUNNING
+			w.task = t.id
+			return true
+		}
+	}
+	return false
+}
+
+// See documentation for TaskExecutor interface.
+func (e *SimulatedTaskExecutor) GetFreeWorkers(ctx context.Context) ([]*Worker, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	rv := make([]*Worker, 0, len(e.workers))
+	for _, w := range e.workers {
+		if w.task == "" && w.isOnline(e.now) {
+			rv = append(rv, w.Worker)
+		}
+	}
+	return rv, nil
+}
+
+// See documentation for TaskExecutor interface.
+func (e *SimulatedTaskExecutor) TriggerTask(ctx context.Context, req *TaskRequest) (string, time.Time, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	if _, ok := e.tasks[req.TaskId]; ok {
+		return "", time.Time{}, fmt.Errorf("Task %s has already been triggered.", req.TaskId)
+	}
+	duration, ok := e.durations[req.Name]
+	if !ok {
+		duration = e.defaultDuration
+	}
+	t := &simulatedTask{
+		id:         req.TaskId,
+		dimensions: util.CopyStringSlice(req.TaskSpec.Dimensions),
+		duration:   duration,
+		status:     db.TASK_STATUS_PENDING,
+		created:    e.now,
+	}
+	e.tasks[t.id] = t
+	if !e.startTask(t) {
+		e.pending = append(e.pending, t)
+	}
+	return t.id, t.created, nil
+}
+
+// See documentation for TaskExecutor interface.
+func (e *SimulatedTaskExecutor) UpdateTask(ctx context.Context, task *db.Task) (bool, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	t, ok := e.tasks[task.SwarmingTaskId]
+	if !ok {
+		if task.Done() {
+			return false, nil
+		}
+		task.Status = db.TASK_STATUS_MISHAP
+		task.Finished = e.now
+		if util.TimeIsZero(task.Started) {
+			task.Started = task.Finished
+		}
+		return true, nil
+	}
+	modified := false
+	if task.Status != t.status {
+		task.Status = t.status
+		modified = true
+	}
+	if !task.Started.Equal(t.started) {
+		task.Started = t.started
+		modified = true
+	}
+	if !task.Finished.Equal(t.finished) {
+		task.Finished = t.finished
+		modified = true
+	}
+	if task.SwarmingBotId != t.worker {
+		task.SwarmingBotId = t.worker
+		modified = true
+	}
+	if t.status == db.TASK_STATUS_SUCCESS && task.IsolatedOutput != SIMULATED_ISOLATED_OUTPUT {
+		task.IsolatedOutput = SIMULATED_ISOLATED_OUTPUT
+		modified = true
+	}
+	return modified, nil
+}
+
+// See documentation for TaskExecutor interface.
+func (e *SimulatedTaskExecutor) CancelTask(ctx context.Context, id string) error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	t, ok := e.tasks[id]
+	if !ok {
+		return fmt.Errorf("No such task: %s", id)
+	}
+	if t.status != db.TASK_STATUS_PENDING && t.status != db.TASK_STATUS_RUNNING {
+		return nil
+	}
+	for i, p := range e.pending {
+		if p == t {
+			e.pending = append(e.pending[:i], e.pending[i+1:]...)
+			break
+		}
+	}
+	for _, w := range e.workers {
+		if w.task == t.id {
+			w.busy += e.now.Sub(t.started)
+			w.task = ""
+		}
+	}
+	t.status = db.TASK_STATUS_MISHAP
+	t.finished = e.now
+	if util.TimeIsZero(t.started) {
+		t.started = t.finished
+	}
+	return nil
+}
+
+// WorkerUsage describes how much of its available time a simulated Worker
+// spent running tasks.
+type WorkerUsage struct {
+	// Online is the time during which the Worker was available.
+	Online time.Duration `json:"online"`
+	// Busy is the time during which the Worker was running tasks.
+	Busy time.Duration `json:"busy"`
+}
+
+// Usage returns the WorkerUsage of each Worker between the start of the
+// simulation and the current time of the virtual clock, keyed by Worker ID.
+func (e *SimulatedTaskExecutor) Usage() map[string]*WorkerUsage {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	rv := make(map[string]*WorkerUsage, len(e.workers))
+	for _, w := range e.workers {
+		u := &WorkerUsage{
+			Busy: w.busy,
+		}
+		if w.task != "" {
+			u.Busy += e.now.Sub(e.tasks[w.task].started)
+		}
+		online := w.online
+		if online.Before(e.start) {
+			online = e.start
+		}
+		offline := w.offline
+		if offline.After(e.now) {
+			offline = e.now
+		}
+		if offline.After(online) {
+			u.Online = offline.Sub(online)
+		}
+		rv[w.Id] = u
+	}
+	return rv
+}
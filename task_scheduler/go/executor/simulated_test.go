@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/task_scheduler/go/db"
+	"go.skia.org/infra/task_scheduler/go/specs"
+)
+
+func TestSimulatedTaskExecutor(t *testing.T) {
+	testutils.SmallTest(t)
+	ctx := context.Background()
+	start := time.Date(2018, time.June, 4, 0, 0, 0, 0, time.UTC)
+	e := NewSimulatedTaskExecutor(start, map[string]time.Duration{
+		"Build": 10 * time.Minute,
+	}, 30*time.Minute)
+	assert.NoError(t, e.AddWorker(&Worker{Id: "linux", Dimensions: []string{"os:Linux"}}, start, start.Add(2*time.Hour)))
+	assert.NoError(t, e.AddWorker(&Worker{Id: "mac", Dimensions: []string{"os:Mac"}}, start.Add(time.Hour), start.Add(2*time.Hour)))
+	assert.Error(t, e.AddWorker(&Worker{Id: "mac"}, start, start))
+
+	// Only the Linux worker is online.
+	workers, err := e.GetFreeWorkers(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(workers))
+	assert.Equal(t, "linux", workers[0].Id)
+
+	trigger := func(id, name string, dims ...string) *db.Task {
+		req := &TaskRequest{
+			TaskId:   id,
+			TaskSpec: &specs.TaskSpec{Dimensions: dims},
+		}
+		req.Name = name
+		taskId, created, err := e.TriggerTask(ctx, req)
+		assert.NoError(t, err)
+		assert.Equal(t, id, taskId)
+		assert.Equal(t, e.Now(), created)
+		return &db.Task{
+			Created:        created,
+			SwarmingTaskId: taskId,
+		}
+	}
+	update := func(task *db.Task, expectModified bool) {
+		modified, err := e.UpdateTask(ctx, task)
+		assert.NoError(t, err)
+		assert.Equal(t, expectModified, modified)
+	}
+
+	// The build starts right away; the test waits for the Mac worker.
+	build := trigger("build", "Build", "os:Linux")
+	test := trigger("test", "Test", "os:Mac")
+	update(build, true)
+	assert.Equal(t, db.TASK_STATUS_RUNNING, build.Status)
+	assert.Equal(t, start, build.Started)
+	assert.Equal(t, "linux", build.SwarmingBotId)
+	update(test, false)
+	assert.Equal(t, db.TASK_STATUS_PENDING, test.Status)
+	workers, err = e.GetFreeWorkers(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(workers))
+
+	// The build finishes after its duration.
+	e.SetTime(start.Add(5 * time.Minute))
+	update(build, false)
+	e.SetTime(start.Add(15 * time.Minute))
+	update(build, true)
+	assert.Equal(t, db.TASK_STATUS_SUCCESS, build.Status)
+	assert.Equal(t, start.Add(10*time.Minute), build.Finished)
+	assert.Equal(t, SIMULATED_ISOLATED_OUTPUT, build.IsolatedOutput)
+	update(build, false)
+
+	// The test starts once the Mac worker comes online and uses the
+	// default duration.
+	e.SetTime(start.Add(time.Hour))
+	update(test, true)
+	assert.Equal(t, db.TASK_STATUS_RUNNING, test.Status)
+	assert.Equal(t, start.Add(time.Hour), test.Started)
+	assert.Equal(t, "mac", test.SwarmingBotId)
+
+	// Canceled tasks are mishaps.
+	perf := trigger("perf", "Perf", "os:Linux")
+	assert.NoError(t, e.CancelTask(ctx, perf.SwarmingTaskId))
+	update(perf, true)
+	assert.Equal(t, db.TASK_STATUS_MISHAP, perf.Status)
+	assert.Error(t, e.CancelTask(ctx, "bogus"))
+
+	// Unknown tasks are mishaps.
+	bogus := &db.Task{SwarmingTaskId: "bogus"}
+	update(bogus, true)
+	assert.Equal(t, db.TASK_STATUS_MISHAP, bogus.Status)
+
+	// Time can't go backward.
+	e.SetTime(start)
+	assert.Equal(t, start.Add(time.Hour), e.Now())
+
+	// Usage only counts the time during which the workers were online.
+	e.SetTime(start.Add(3 * time.Hour))
+	usage := e.Usage()
+	assert.Equal(t, &WorkerUsage{Online: 2 * time.Hour, Busy: 10 * time.Minute}, usage["linux"])
+	assert.Equal(t, &WorkerUsage{Online: time.Hour, Busy: 30 * time.Minute}, usage["mac"])
+}
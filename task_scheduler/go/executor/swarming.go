@@ -0,0 +1,341 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	swarming_api "go.chromium.org/luci/common/api/swarming/swarming/v1"
+	"go.skia.org/infra/go/common"
+	"go.skia.org/infra/go/isolate"
+	"go.skia.org/infra/go/metrics2"
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/swarming"
+	"go.skia.org/infra/go/timeout"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/task_scheduler/go/db"
+	"go.skia.org/infra/task_scheduler/go/db/local_db"
+)
+
+// SwarmingTaskExecutor is a TaskExecutor which runs tasks on Swarming. It
+// also implements swarming.PubSubHandler to receive updates about tasks.
+type SwarmingTaskExecutor struct {
+	busyBots      *busyBots
+	db            db.TaskDB
+	isolateServer string
+	pools         []string
+	pubsubTopic   string
+	swarming      swarming.ApiClient
+}
+
+// NewSwarmingTaskExecutor returns a SwarmingTaskExecutor instance which runs
+// tasks in the given Swarming pools. The inputs of the tasks are expected on
+// the given Isolate server.
+func NewSwarmingTaskExecutor(d db.TaskDB, swarmingClient swarming.ApiClient, isolateServer string, pools []string, pubsubTopic string) *SwarmingTaskExecutor {
+	return &SwarmingTaskExecutor{
+		busyBots:      newBusyBots(),
+		db:            d,
+		isolateServer: isolateServer,
+		pools:         pools,
+		pubsubTopic:   pubsubTopic,
+		swarming:      swarmingClient,
+	}
+}
+
+// See documentation for TaskExecutor interface.
+func (e *SwarmingTaskExecutor) GetFreeWorkers(ctx context.Context) ([]*Worker, error) {
+	bots, err := getFreeSwarmingBots(e.swarming, e.busyBots, e.pools)
+	if err != nil {
+		return nil, err
+	}
+	rv := make([]*Worker, 0, len(bots))
+	for _, bot := range bots {
+		rv = append(rv, &Worker{
+			Id:         bot.BotId,
+			Dimensions: swarming.BotDimensionsToStringSlice(bot.Dimensions),
+		})
+	}
+	return rv, nil
+}
+
+// See documentation for TaskExecutor interface.
+func (e *SwarmingTaskExecutor) TriggerTask(ctx context.Context, req *TaskRequest) (string, time.Time, error) {
+	swarmingReq := makeSwarmingTaskRequest(req, e.isolateServer, e.pubsubTopic)
+	var resp *swarming_api.SwarmingRpcsTaskRequestMetadata
+	if err := timeout.Run(func() error {
+		var err error
+		resp, err = e.swarming.TriggerTask(swarmingReq)
+		return err
+	}, time.Minute); err != nil {
+		return "", time.Time{}, err
+	}
+	created, err := swarming.ParseTimestamp(resp.Request.CreatedTs)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return resp.TaskId, created, nil
+}
+
+// See documentation for TaskExecutor interface.
+func (e *SwarmingTaskExecutor) UpdateTask(ctx context.Context, task *db.Task) (bool, error) {
+	swarmTask, err := e.swarming.GetTask(task.SwarmingTaskId, false)
+	if err != nil {
+		return false, fmt.Errorf("Failed to get updated task from swarming: %s", err)
+	}
+	return task.UpdateFromSwarming(swarmTask)
+}
+
+// See documentation for TaskExecutor interface.
+func (e *SwarmingTaskExecutor) CancelTask(ctx context.Context, id string) error {
+	return e.swarming.CancelTask(id)
+}
+
+// HandleSwarmingPubSub loads the given Swarming task ID from Swarming and
+// updates the associated db.Task in the database. Returns a bool indicating
+// whether the pubsub message should be acknowledged.
+func (e *SwarmingTaskExecutor) HandleSwarmingPubSub(msg *swarming.PubSubTaskMessage) bool {
+	// First, make sure we have the task in our DB.
+	if msg.UserData != "" {
+		// We use ID of the task in our DB for the UserData field.
+		t, err := e.db.GetTaskById(msg.UserData)
+		if err != nil {
+			sklog.Errorf("Swarming Pub/Sub: Failed to retrieve task %q by ID: %s", msg.SwarmingTaskId, msg.UserData)
+			return true
+		} else if t == nil {
+			ts, _, err := local_db.ParseId(msg.UserData)
+			if err != nil {
+				sklog.Errorf("Failed to parse userdata as task ID: %s", err)
+				return true
+			} else if time.Now().Sub(ts) < 2*time.Minute {
+				sklog.Infof("Failed to update task %q from pub/sub: no such task ID: %q. Less than two minutes old; try again later.", msg.SwarmingTaskId, msg.UserData)
+				return false
+			} else {
+				sklog.Errorf("Failed to update task %q from pub/sub: no such task ID: %q", msg.SwarmingTaskId, msg.UserData)
+				return true
+			}
+		}
+	}
+
+	// Obtain the Swarming task data.
+	res, err := e.swarming.GetTask(msg.SwarmingTaskId, false)
+	if err != nil {
+		sklog.Errorf("pubsub: Failed to retrieve task from Swarming: %s", err)
+		return true
+	}
+	// Skip unfinished tasks.
+	if res.CompletedTs == "" {
+		return true
+	}
+	// Update the task in the DB.
+	if err := db.UpdateDBFromSwarmingTask(e.db, res); err != nil {
+		// TODO(borenet): Some of these cases should never be hit, after all tasks
+		// start supplying the ID in msg.UserData. We should be able to remove the logic.
+		if err == db.ErrNotFound {
+			id, err := swarming.GetTagValue(res, db.SWARMING_TAG_ID)
+			if err != nil {
+				id = "<MISSING ID TAG>"
+			}
+			created, err := swarming.ParseTimestamp(res.CreatedTs)
+			if err != nil {
+				sklog.Errorf("Failed to parse timestamp: %s; %s", res.CreatedTs, err)
+				return true
+			}
+			if time.Now().Sub(created) < 2*time.Minute {
+				sklog.Infof("Failed to update task %q: No such task ID: %q. Less than two minutes old; try again later.", msg.SwarmingTaskId, id)
+				return false
+			}
+			sklog.Errorf("Failed to update task %q: No such task ID: %q", msg.SwarmingTaskId, id)
+			return true
+		} else if err == db.ErrUnknownId {
+			expectedSwarmingTaskId := "<unknown>"
+			id, err := swarming.GetTagValue(res, db.SWARMING_TAG_ID)
+			if err != nil {
+				id = "<MISSING ID TAG>"
+			} else {
+				t, err := e.db.GetTaskById(id)
+				if err != nil {
+					sklog.Errorf("Failed to update task %q; mismatched ID and failed to retrieve task from DB: %s", msg.SwarmingTaskId, err)
+					return true
+				} else {
+					expectedSwarmingTaskId = t.SwarmingTaskId
+				}
+			}
+			sklog.Errorf("Failed to update task %q: Task %s has a different Swarming task ID associated with it: %s", msg.SwarmingTaskId, id, expectedSwarmingTaskId)
+			return true
+		} else {
+			sklog.Errorf("Failed to update task %q: %s", msg.SwarmingTaskId, err)
+			return true
+		}
+	}
+	return true
+}
+
+// getFreeSwarmingBots returns a slice of free swarming bots.
+func getFreeSwarmingBots(s swarming.ApiClient, busy *busyBots, pools []string) ([]*swarming_api.SwarmingRpcsBotInfo, error) {
+	defer metrics2.FuncTimer().Stop()
+
+	// Query for free Swarming bots and pending Swarming tasks in all pools.
+	var wg sync.WaitGroup
+	bots := []*swarming_api.SwarmingRpcsBotInfo{}
+	pending := []*swarming_api.SwarmingRpcsTaskResult{}
+	errs := []error{}
+	var mtx sync.Mutex
+	t := time.Time{}
+	for _, pool := range pools {
+		// Free bots.
+		wg.Add(1)
+		go func(pool string) {
+			defer wg.Done()
+			b, err := s.ListFreeBots(pool)
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				bots = append(bots, b...)
+			}
+		}(pool)
+
+		// Pending tasks.
+		wg.Add(1)
+		go func(pool string) {
+			defer wg.Done()
+			t, err := s.ListTaskResults(t, t, []string{fmt.Sprintf("pool:%s", pool)}, "PENDING", false)
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				pending = append(pending, t...)
+			}
+		}(pool)
+	}
+
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("Got errors loading bots and tasks from Swarming: %v", errs)
+	}
+
+	rv := make([]*swarming_api.SwarmingRpcsBotInfo, 0, len(bots))
+	for _, bot := range bots {
+		if bot.IsDead {
+			continue
+		}
+		if bot.Quarantined {
+			continue
+		}
+		if bot.TaskId != "" {
+			continue
+		}
+		rv = append(rv, bot)
+	}
+	busy.RefreshTasks(pending)
+	return busy.Filter(rv), nil
+}
+
+// makeSwarmingTaskRequest creates a SwarmingRpcsNewTaskRequest object from
+// the TaskRequest.
+func makeSwarmingTaskRequest(req *TaskRequest, isolateServer, pubSubTopic string) *swarming_api.SwarmingRpcsNewTaskRequest {
+	spec := req.TaskSpec
+	var caches []*swarming_api.SwarmingRpcsCacheEntry
+	if len(spec.Caches) > 0 {
+		caches = make([]*swarming_api.SwarmingRpcsCacheEntry, 0, len(spec.Caches))
+		for _, cache := range spec.Caches {
+			caches = append(caches, &swarming_api.SwarmingRpcsCacheEntry{
+				Name: cache.Name,
+				Path: cache.Path,
+			})
+		}
+	}
+	var cipdInput *swarming_api.SwarmingRpcsCipdInput
+	if len(spec.CipdPackages) > 0 {
+		cipdInput = &swarming_api.SwarmingRpcsCipdInput{
+			Packages: make([]*swarming_api.SwarmingRpcsCipdPackage, 0, len(spec.CipdPackages)),
+		}
+		for _, p := range spec.CipdPackages {
+			cipdInput.Packages = append(cipdInput.Packages, &swarming_api.SwarmingRpcsCipdPackage{
+				PackageName: p.Name,
+				Path:        p.Path,
+				Version:     p.Version,
+			})
+		}
+	}
+
+	dims := make([]*swarming_api.SwarmingRpcsStringPair, 0, len(spec.Dimensions))
+	dimsMap := make(map[string]string, len(spec.Dimensions))
+	for _, d := range spec.Dimensions {
+		split := strings.SplitN(d, ":", 2)
+		key := split[0]
+		val := split[1]
+		dims = append(dims, &swarming_api.SwarmingRpcsStringPair{
+			Key:   key,
+			Value: val,
+		})
+		dimsMap[key] = val
+	}
+
+	var env []*swarming_api.SwarmingRpcsStringPair
+	if len(spec.Environment) > 0 {
+		env = make([]*swarming_api.SwarmingRpcsStringPair, 0, len(spec.Environment))
+		for k, v := range spec.Environment {
+			env = append(env, &swarming_api.SwarmingRpcsStringPair{
+				Key:   k,
+				Value: v,
+			})
+		}
+	}
+
+	var envPrefixes []*swarming_api.SwarmingRpcsStringListPair
+	if len(spec.EnvPrefixes) > 0 {
+		envPrefixes = make([]*swarming_api.SwarmingRpcsStringListPair, 0, len(spec.EnvPrefixes))
+		for k, v := range spec.EnvPrefixes {
+			envPrefixes = append(envPrefixes, &swarming_api.SwarmingRpcsStringListPair{
+				Key:   k,
+				Value: util.CopyStringSlice(v),
+			})
+		}
+	}
+
+	expirationSecs := int64(spec.Expiration.Seconds())
+	if expirationSecs == int64(0) {
+		expirationSecs = int64(swarming.RECOMMENDED_EXPIRATION.Seconds())
+	}
+	executionTimeoutSecs := int64(spec.ExecutionTimeout.Seconds())
+	if executionTimeoutSecs == int64(0) {
+		executionTimeoutSecs = int64(swarming.RECOMMENDED_HARD_TIMEOUT.Seconds())
+	}
+	ioTimeoutSecs := int64(spec.IoTimeout.Seconds())
+	if ioTimeoutSecs == int64(0) {
+		ioTimeoutSecs = int64(swarming.RECOMMENDED_IO_TIMEOUT.Seconds())
+	}
+	return &swarming_api.SwarmingRpcsNewTaskRequest{
+		ExpirationSecs: expirationSecs,
+		Name:           req.Name,
+		Priority:       swarming.RECOMMENDED_PRIORITY,
+		Properties: &swarming_api.SwarmingRpcsTaskProperties{
+			Caches:               caches,
+			CipdInput:            cipdInput,
+			Command:              util.CopyStringSlice(spec.Command),
+			Dimensions:           dims,
+			Env:                  env,
+			EnvPrefixes:          envPrefixes,
+			ExecutionTimeoutSecs: executionTimeoutSecs,
+			ExtraArgs:            util.CopyStringSlice(spec.ExtraArgs),
+			Idempotent:           false,
+			InputsRef: &swarming_api.SwarmingRpcsFilesRef{
+				Isolated:       req.IsolatedInput,
+				Isolatedserver: isolateServer,
+				Namespace:      isolate.DEFAULT_NAMESPACE,
+			},
+			IoTimeoutSecs: ioTimeoutSecs,
+			Outputs:       util.CopyStringSlice(spec.Outputs),
+		},
+		PubsubTopic:    fmt.Sprintf(swarming.PUBSUB_FULLY_QUALIFIED_TOPIC_TMPL, common.PROJECT_ID, pubSubTopic),
+		PubsubUserdata: req.TaskId,
+		ServiceAccount: spec.ServiceAccount,
+		Tags:           db.TagsForTask(req.Name, req.TaskId, req.Attempt, req.RepoState, req.RetryOf, dimsMap, req.ForcedJobId, req.ParentTaskIds, spec.ExtraTags),
+		User:           "skiabot@google.com",
+	}
+}
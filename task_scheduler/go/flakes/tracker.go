@@ -0,0 +1,273 @@
+package flakes
+
+/*
+   Track the flakiness of TaskSpecs and quarantine the flakiest ones.
+*/
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/task_scheduler/go/blacklist"
+	"go.skia.org/infra/task_scheduler/go/db"
+)
+
+const (
+	// BLACKLIST_RULE_ADDED_BY is the AddedBy field of blacklist rules which
+	// are created for quarantined TaskSpecs.
+	BLACKLIST_RULE_ADDED_BY = "task-scheduler"
+)
+
+var (
+	// DEFAULT_POLICY is the Policy used by the Task Scheduler unless
+	// otherwise specified. It quarantines TaskSpecs but does not
+	// blacklist them.
+	DEFAULT_POLICY = Policy{
+		Window:              24 * time.Hour,
+		MinTasks:            10,
+		RetryThreshold:      0.05,
+		ExtraAttempts:       1,
+		QuarantineThreshold: 0.2,
+		ScoreMultiplier:     0.5,
+	}
+)
+
+// Policy determines how the Tracker reacts to flaky TaskSpecs.
+type Policy struct {
+	// Window is the time period over which flake rates are computed.
+	Window time.Duration
+
+	// MinTasks is the minimum number of finished tasks for a TaskSpec
+	// within the Window before its flake rate is taken into account.
+	MinTasks int
+
+	// TaskSpecs whose flake rate is at least RetryThreshold are allowed
+	// ExtraAttempts attempts on top of TaskSpec.MaxAttempts. Automatic
+	// retries are disabled if ExtraAttempts is zero.
+	RetryThreshold float64
+	ExtraAttempts  int
+
+	// TaskSpecs whose flake rate is at least QuarantineThreshold are
+	// quarantined. Quarantine is disabled if QuarantineThreshold is not
+	// positive.
+	QuarantineThreshold float64
+
+	// ScoreMultiplier is applied to the scores of task candidates for
+	// quarantined TaskSpecs in order to de-prioritize them.
+	ScoreMultiplier float64
+
+	// If BlacklistDuration is non-zero, a blacklist rule which expires
+	// after BlacklistDuration is added for newly-quarantined TaskSpecs.
+	BlacklistDuration time.Duration
+}
+
+// FlakeRate describes the flakiness of a TaskSpec.
+type FlakeRate struct {
+	// Name of the TaskSpec.
+	Name string `json:"name"`
+
+	// Flakes is the number of flakily-failed tasks, as determined by
+	// FindFlakes.
+	Flakes int `json:"flakes"`
+
+	// Total is the number of finished tasks.
+	Total int `json:"total"`
+
+	// Rate is Flakes / Total.
+	Rate float64 `json:"rate"`
+}
+
+// FlakeRates computes the flake rate of each TaskSpec in the given slice of
+// tasks, keyed by TaskSpec name.
+func FlakeRates(tasks []*db.Task) map[string]*FlakeRate {
+	rv := map[string]*FlakeRate{}
+	for _, task := range tasks {
+		if !task.Done() {
+			continue
+		}
+		r, ok := rv[task.Name]
+		if !ok {
+			r = &FlakeRate{Name: task.Name}
+			rv[task.Name] = r
+		}
+		r.Total++
+	}
+	for _, task := range FindFlakes(tasks) {
+		rv[task.Name].Flakes++
+	}
+	for _, r := range rv {
+		r.Rate = float64(r.Flakes) / float64(r.Total)
+	}
+	return rv
+}
+
+// Quarantine describes a quarantined TaskSpec.
+type Quarantine struct {
+	FlakeRate
+
+	// Since is the time at which the TaskSpec was quarantined.
+	Since time.Time `json:"since"`
+
+	// BlacklistRule is the name of the blacklist rule which was added for
+	// the TaskSpec, if any.
+	BlacklistRule string `json:"blacklist_rule"`
+}
+
+// Tracker periodically computes the flake rates of all TaskSpecs and applies
+// a Policy to them.
+type Tracker struct {
+	bl          *blacklist.Blacklist
+	db          db.TaskReader
+	mtx         sync.RWMutex
+	policy      Policy
+	quarantined map[string]*Quarantine
+	rates       map[string]*FlakeRate
+}
+
+// NewTracker returns a Tracker instance which reads tasks from the given DB
+// and adds rules to the given Blacklist according to the given Policy.
+func NewTracker(d db.TaskReader, bl *blacklist.Blacklist, policy Policy) *Tracker {
+	return &Tracker{
+		bl:          bl,
+		db:          d,
+		policy:      policy,
+		quarantined: map[string]*Quarantine{},
+		rates:       map[string]*FlakeRate{},
+	}
+}
+
+// blacklistRuleName returns the name of the blacklist rule for the given
+// quarantined TaskSpec. The name is shortened to fit within the limit for
+// rule names, using a hash of the TaskSpec name to keep it unique.
+func blacklistRuleName(taskSpec string) string {
+	name := "Flaky: " + taskSpec
+	if len(name) <= blacklist.MAX_NAME_CHARS {
+		return name
+	}
+	hash := fmt.Sprintf("%x", sha1.Sum([]byte(taskSpec)))[:8]
+	return name[:blacklist.MAX_NAME_CHARS-len(hash)-1] + "~" + hash
+}
+
+// Update recomputes the flake rates using the tasks created within the
+// Policy's Window before the given time and updates the set of quarantined
+// TaskSpecs.
+func (t *Tracker) Update(now time.Time) error {
+	tasks, err := t.db.GetTasksFromDateRange(now.Add(-t.policy.Window), now, "")
+	if err != nil {
+		return err
+	}
+	rates := FlakeRates(tasks)
+
+	expiredRules := map[string]bool{}
+	if t.bl != nil {
+		removed, err := t.bl.RemoveExpiredRules(now)
+		if err != nil {
+			return err
+		}
+		for _, name := range removed {
+			sklog.Infof("Removed expired blacklist rule %q", name)
+			expiredRules[name] = true
+		}
+	}
+
+	t.mtx.RLock()
+	prev := t.quarantined
+	t.mtx.RUnlock()
+	quarantined := map[string]*Quarantine{}
+	if t.policy.QuarantineThreshold > 0 {
+		for name, r := range rates {
+			if r.Total < t.policy.MinTasks || r.Rate < t.policy.QuarantineThreshold {
+				continue
+			}
+			q := &Quarantine{
+				FlakeRate: *r,
+				Since:     now,
+			}
+			if p, ok := prev[name]; ok {
+				// Don't blacklist the TaskSpec again once its rule
+				// has expired.
+				q.Since = p.Since
+				if !expiredRules[p.BlacklistRule] {
+					q.BlacklistRule = p.BlacklistRule
+				}
+			} else {
+				sklog.Warningf("Quarantining %s; flake rate is %f (%d of %d tasks)", name, r.Rate, r.Flakes, r.Total)
+				if t.bl != nil && t.policy.BlacklistDuration > 0 {
+					rule := &blacklist.Rule{
+						AddedBy:          BLACKLIST_RULE_ADDED_BY,
+						TaskSpecPatterns: []string{"^" + regexp.QuoteMeta(name) + "$"},
+						Description:      fmt.Sprintf("%s is quarantined; %d of %d tasks flaked within %s.", name, r.Flakes, r.Total, t.policy.Window),
+						Expires:          now.Add(t.policy.BlacklistDuration),
+						Name:             blacklistRuleName(name),
+					}
+					if err := t.bl.AddRule(rule, nil); err != nil {
+						sklog.Errorf("Failed to add blacklist rule for quarantined %s: %s", name, err)
+					} else {
+						q.BlacklistRule = rule.Name
+					}
+				}
+			}
+			quarantined[name] = q
+		}
+	}
+	for name := range prev {
+		if _, ok := quarantined[name]; !ok {
+			sklog.Infof("Releasing %s from quarantine.", name)
+		}
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.rates = rates
+	t.quarantined = quarantined
+	return nil
+}
+
+// Quarantined returns the currently-quarantined TaskSpecs, sorted by name.
+func (t *Tracker) Quarantined() []*Quarantine {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	rv := make([]*Quarantine, 0, len(t.quarantined))
+	for _, q := range t.quarantined {
+		cp := *q
+		rv = append(rv, &cp)
+	}
+	sort.Slice(rv, func(i, j int) bool {
+		return rv[i].Name < rv[j].Name
+	})
+	return rv
+}
+
+// IsQuarantined returns true iff the given TaskSpec is quarantined.
+func (t *Tracker) IsQuarantined(taskSpec string) bool {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	_, ok := t.quarantined[taskSpec]
+	return ok
+}
+
+// ExtraAttempts returns the number of attempts which the given TaskSpec is
+// allowed in addition to its MaxAttempts.
+func (t *Tracker) ExtraAttempts(taskSpec string) int {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	r, ok := t.rates[taskSpec]
+	if !ok || r.Total < t.policy.MinTasks || r.Rate < t.policy.RetryThreshold {
+		return 0
+	}
+	return t.policy.ExtraAttempts
+}
+
+// ScoreMultiplier returns the factor by which the scores of task candidates
+// for the given TaskSpec should be multiplied.
+func (t *Tracker) ScoreMultiplier(taskSpec string) float64 {
+	if t.IsQuarantined(taskSpec) {
+		return t.policy.ScoreMultiplier
+	}
+	return 1.0
+}
@@ -0,0 +1,127 @@
+package flakes
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/task_scheduler/go/blacklist"
+	"go.skia.org/infra/task_scheduler/go/db"
+)
+
+func TestBlacklistRuleName(t *testing.T) {
+	testutils.SmallTest(t)
+	assert.Equal(t, "Flaky: Test-Task", blacklistRuleName("Test-Task"))
+	long1 := blacklistRuleName("Test-Debian9-Clang-GCE-CPU-AVX2-x86_64-Debug-All-ASAN")
+	long2 := blacklistRuleName("Test-Debian9-Clang-GCE-CPU-AVX2-x86_64-Debug-All-MSAN")
+	assert.Equal(t, blacklist.MAX_NAME_CHARS, len(long1))
+	assert.Equal(t, blacklist.MAX_NAME_CHARS, len(long2))
+	assert.NotEqual(t, long1, long2)
+}
+
+func TestTracker(t *testing.T) {
+	testutils.SmallTest(t)
+	wd, cleanup := testutils.TempDir(t)
+	defer cleanup()
+	bl, err := blacklist.FromFile(path.Join(wd, "blacklist.json"))
+	assert.NoError(t, err)
+	d := db.NewInMemoryTaskDB()
+
+	now := time.Now().Round(time.Second)
+	tasks := []*db.Task{}
+	addTask := func(name, commit string, status db.TaskStatus) {
+		task := db.MakeTestTask(now.Add(-time.Duration(len(tasks)+1)*time.Minute), []string{commit})
+		task.Name = name
+		task.Status = status
+		tasks = append(tasks, task)
+	}
+	// Flaky: every commit fails once and then succeeds.
+	for i := 0; i < 5; i++ {
+		commit := fmt.Sprintf("abc%d", i)
+		addTask("Flaky", commit, db.TASK_STATUS_FAILURE)
+		addTask("Flaky", commit, db.TASK_STATUS_SUCCESS)
+	}
+	// Somewhat flaky: one mishap.
+	for i := 0; i < 5; i++ {
+		addTask("Somewhat-Flaky", fmt.Sprintf("abc%d", i), db.TASK_STATUS_SUCCESS)
+	}
+	addTask("Somewhat-Flaky", "abc5", db.TASK_STATUS_MISHAP)
+	// Not flaky.
+	for i := 0; i < 10; i++ {
+		addTask("Solid", fmt.Sprintf("abc%d", i), db.TASK_STATUS_SUCCESS)
+	}
+	// Too few tasks to draw conclusions.
+	addTask("Rare", "abc0", db.TASK_STATUS_MISHAP)
+	assert.NoError(t, d.PutTasks(tasks))
+
+	rates := FlakeRates(tasks)
+	assert.Equal(t, 4, len(rates))
+	assert.Equal(t, &FlakeRate{Name: "Flaky", Flakes: 5, Total: 10, Rate: 0.5}, rates["Flaky"])
+	assert.Equal(t, 1, rates["Somewhat-Flaky"].Flakes)
+	assert.Equal(t, 0, rates["Solid"].Flakes)
+
+	policy := Policy{
+		Window:              time.Hour,
+		MinTasks:            5,
+		RetryThreshold:      0.1,
+		ExtraAttempts:       2,
+		QuarantineThreshold: 0.3,
+		ScoreMultiplier:     0.25,
+		BlacklistDuration:   10 * time.Minute,
+	}
+	tr := NewTracker(d, bl, policy)
+
+	// Nothing is quarantined before the first update.
+	assert.Equal(t, 0, len(tr.Quarantined()))
+	assert.Equal(t, 0, tr.ExtraAttempts("Flaky"))
+
+	assert.NoError(t, tr.Update(now))
+	q := tr.Quarantined()
+	assert.Equal(t, 1, len(q))
+	assert.Equal(t, "Flaky", q[0].Name)
+	assert.Equal(t, now, q[0].Since)
+	assert.Equal(t, "Flaky: Flaky", q[0].BlacklistRule)
+	assert.True(t, tr.IsQuarantined("Flaky"))
+	assert.False(t, tr.IsQuarantined("Solid"))
+	assert.False(t, tr.IsQuarantined("Rare"))
+	assert.Equal(t, 2, tr.ExtraAttempts("Flaky"))
+	assert.Equal(t, 2, tr.ExtraAttempts("Somewhat-Flaky"))
+	assert.Equal(t, 0, tr.ExtraAttempts("Solid"))
+	assert.Equal(t, 0, tr.ExtraAttempts("Rare"))
+	assert.Equal(t, 0.25, tr.ScoreMultiplier("Flaky"))
+	assert.Equal(t, 1.0, tr.ScoreMultiplier("Solid"))
+
+	// The blacklist rule only applies to the quarantined TaskSpec.
+	rule := bl.Rules["Flaky: Flaky"]
+	assert.NotNil(t, rule)
+	assert.True(t, strings.HasPrefix(rule.Description, "Flaky is quarantined"))
+	assert.Equal(t, now.Add(10*time.Minute), rule.Expires)
+	assert.True(t, rule.Match("Flaky", "abc0"))
+	assert.False(t, rule.Match("Flaky-Extra", "abc0"))
+
+	// Once the rule expires it is removed, but the TaskSpec stays in
+	// quarantine without being blacklisted again.
+	later := now.Add(15 * time.Minute)
+	assert.NoError(t, tr.Update(later))
+	q = tr.Quarantined()
+	assert.Equal(t, 1, len(q))
+	assert.Equal(t, now, q[0].Since)
+	assert.Equal(t, "", q[0].BlacklistRule)
+	assert.Equal(t, 0, len(bl.Rules))
+
+	// When the tasks fall out of the window, the TaskSpec is released.
+	assert.NoError(t, tr.Update(now.Add(2*time.Hour)))
+	assert.Equal(t, 0, len(tr.Quarantined()))
+	assert.Equal(t, 0, tr.ExtraAttempts("Flaky"))
+	assert.Equal(t, 1.0, tr.ScoreMultiplier("Flaky"))
+
+	// Quarantine can be disabled.
+	tr = NewTracker(d, bl, Policy{Window: time.Hour, MinTasks: 5})
+	assert.NoError(t, tr.Update(now))
+	assert.Equal(t, 0, len(tr.Quarantined()))
+	assert.Equal(t, 0, len(bl.Rules))
+}
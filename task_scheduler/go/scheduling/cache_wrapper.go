@@ -46,6 +46,11 @@ func (c *cacheWrapper) GetTaskForCommit(repo, commit, name string) (*db.Task, er
 	return c.c.GetTaskForCommit(repo, commit, name)
 }
 
+// See documentation for TaskCache interface.
+func (c *cacheWrapper) GetTaskForInputsHash(name, hash string) (*db.Task, error) {
+	return c.c.GetTaskForInputsHash(name, hash)
+}
+
 // See documentation for TaskCache interface.
 func (c *cacheWrapper) GetTasksByKey(*db.TaskKey) ([]*db.Task, error) {
 	return nil, fmt.Errorf("cacheWrapper.GetTasksByKey not implemented.")
@@ -2,15 +2,72 @@ package scheduling
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
 
+	"go.skia.org/infra/go/cron"
 	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/task_scheduler/go/db"
 	"go.skia.org/infra/task_scheduler/go/specs"
 )
 
-// Trigger all jobs with the given trigger name.
-func triggerPeriodicJobsWithName(ctx context.Context, s *TaskScheduler, trigger string) error {
-	// Obtain the TasksCfg at tip of master in each repo.
+const (
+	// CRON_TRIGGERS_JSON_FILE is the name of a JSON file in the workdir
+	// which contains the last-triggered time for each cron-triggered job.
+	CRON_TRIGGERS_JSON_FILE = "cron-triggers.json"
+)
+
+// cronTriggers tracks the last time at which each cron-triggered job was
+// triggered.
+type cronTriggers struct {
+	jsonFile string
+	// LastTriggered is keyed by cronTriggerKey.
+	LastTriggered map[string]time.Time `json:"last_triggered"`
+}
+
+// newCronTriggers returns a cronTriggers instance, pre-filled with data from
+// a file in the given workdir.
+func newCronTriggers(workdir string) (*cronTriggers, error) {
+	rv := &cronTriggers{}
+	jsonFile := path.Join(workdir, CRON_TRIGGERS_JSON_FILE)
+	f, err := os.Open(jsonFile)
+	if err == nil {
+		defer util.Close(f)
+		if err := json.NewDecoder(f).Decode(rv); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if rv.LastTriggered == nil {
+		rv.LastTriggered = map[string]time.Time{}
+	}
+	rv.jsonFile = jsonFile
+	return rv, nil
+}
+
+// Write writes the last-triggered times to the JSON file.
+func (c *cronTriggers) Write() error {
+	return util.WithWriteFile(c.jsonFile, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(c)
+	})
+}
+
+// cronTriggerKey returns the key used for the given job in
+// cronTriggers.LastTriggered.
+func cronTriggerKey(repo, jobName string) string {
+	return repo + "#" + jobName
+}
+
+// readMasterTasksCfgs obtains the TasksCfg at tip of master in each repo.
+func readMasterTasksCfgs(ctx context.Context, s *TaskScheduler) (map[db.RepoState]*specs.TasksCfg, error) {
 	cfgs := make(map[db.RepoState]*specs.TasksCfg, len(s.repos))
 	for url, repo := range s.repos {
 		head := repo.Get("master")
@@ -20,10 +77,19 @@ func triggerPeriodicJobsWithName(ctx context.Context, s *TaskScheduler, trigger
 		}
 		cfg, err := s.taskCfgCache.ReadTasksCfg(ctx, rs)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		cfgs[rs] = cfg
 	}
+	return cfgs, nil
+}
+
+// Trigger all jobs with the given trigger name.
+func triggerPeriodicJobsWithName(ctx context.Context, s *TaskScheduler, trigger string) error {
+	cfgs, err := readMasterTasksCfgs(ctx, s)
+	if err != nil {
+		return err
+	}
 	// Trigger the periodic tasks.
 	sklog.Infof("Triggering %s tasks", trigger)
 	jobs := []*db.Job{}
@@ -56,3 +122,144 @@ func (s *TaskScheduler) registerPeriodicTriggers() {
 func (s *TaskScheduler) triggerPeriodicJobs(ctx context.Context) error {
 	return s.periodicTriggers.RunPeriodicTriggers(ctx)
 }
+
+// triggerCronJobs triggers jobs with cron triggers at HEAD of the master branch
+// in each repo if their schedule has fired since they were last triggered.
+// Jobs which have not been seen before are not triggered until their schedule
+// next fires after the given time. Only one job is triggered for each job
+// spec, even if its schedule fired multiple times since it was last
+// triggered.
+func (s *TaskScheduler) triggerCronJobs(ctx context.Context, now time.Time) error {
+	cfgs, err := readMasterTasksCfgs(ctx, s)
+	if err != nil {
+		return err
+	}
+	now = now.UTC()
+	jobs := []*db.Job{}
+	// Updates to cronTriggers.LastTriggered are only applied once the jobs
+	// have been inserted, so that failed jobs are retried on the next pass.
+	triggered := map[string]time.Time{}
+	for rs, cfg := range cfgs {
+		for name, spec := range cfg.Jobs {
+			expr, ok := specs.TriggerArg(spec.Trigger, specs.TRIGGER_CRON_PREFIX)
+			if !ok {
+				continue
+			}
+			sched, err := cron.Parse(expr)
+			if err != nil {
+				// This should have been caught in TasksCfg.Validate.
+				return fmt.Errorf("Invalid cron trigger for job %q: %s", name, err)
+			}
+			key := cronTriggerKey(rs.Repo, name)
+			last, ok := s.cronTriggers.LastTriggered[key]
+			if !ok {
+				triggered[key] = now
+				continue
+			}
+			next := sched.Next(last.UTC())
+			if next.IsZero() || next.After(now) {
+				continue
+			}
+			sklog.Infof("Triggering cron job %s in %s (%s)", name, rs.Repo, expr)
+			j, err := s.taskCfgCache.MakeJob(ctx, rs, name)
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, j)
+			triggered[key] = now
+		}
+	}
+	if err := s.db.PutJobs(jobs); err != nil {
+		return err
+	}
+	if len(triggered) == 0 {
+		return nil
+	}
+	for key, ts := range triggered {
+		s.cronTriggers.LastTriggered[key] = ts
+	}
+	return s.cronTriggers.Write()
+}
+
+// tagsByCommit returns the names of the tags in the given repo, keyed by the
+// hash of the commit to which they point.
+func tagsByCommit(ctx context.Context, s *TaskScheduler, repoUrl string) (map[string][]string, error) {
+	// Annotated tags point to a tag object; "*objectname" is the commit
+	// to which the tag object points.
+	output, err := s.repos[repoUrl].Repo().Git(ctx, "for-each-ref", "--format=%(refname) %(objectname) %(*objectname)", "refs/tags")
+	if err != nil {
+		return nil, err
+	}
+	rv := map[string][]string{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		name := strings.TrimPrefix(fields[0], "refs/tags/")
+		hash := fields[len(fields)-1]
+		rv[hash] = append(rv[hash], name)
+	}
+	return rv, nil
+}
+
+// triggerTagJobs triggers jobs with tag triggers at each tagged commit within
+// the scheduling window, unless they have already been triggered there.
+func (s *TaskScheduler) triggerTagJobs(ctx context.Context) error {
+	jobs := []*db.Job{}
+	for repoUrl, r := range s.repos {
+		tags, err := tagsByCommit(ctx, s, repoUrl)
+		if err != nil {
+			return err
+		}
+		for hash, names := range tags {
+			c := r.Get(hash)
+			if c == nil || !s.window.TestCommit(repoUrl, c) {
+				continue
+			}
+			rs := db.RepoState{
+				Repo:     repoUrl,
+				Revision: c.Hash,
+			}
+			cfg, err := s.taskCfgCache.ReadTasksCfg(ctx, rs)
+			if err != nil {
+				return err
+			}
+			for name, spec := range cfg.Jobs {
+				pattern, ok := specs.TriggerArg(spec.Trigger, specs.TRIGGER_TAG_PREFIX)
+				if !ok {
+					continue
+				}
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					// This should have been caught in TasksCfg.Validate.
+					return fmt.Errorf("Invalid tag trigger for job %q: %s", name, err)
+				}
+				matched := false
+				for _, tag := range names {
+					if re.MatchString(tag) {
+						matched = true
+						break
+					}
+				}
+				if !matched {
+					continue
+				}
+				existing, err := s.jCache.GetJobsByRepoState(name, rs)
+				if err != nil {
+					return err
+				}
+				if len(existing) > 0 {
+					continue
+				}
+				sklog.Infof("Triggering tag job %s at %s in %s", name, c.Hash, repoUrl)
+				j, err := s.taskCfgCache.MakeJob(ctx, rs, name)
+				if err != nil {
+					return err
+				}
+				jobs = append(jobs, j)
+			}
+		}
+	}
+	return s.db.PutJobs(jobs)
+}
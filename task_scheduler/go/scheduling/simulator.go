@@ -0,0 +1,434 @@
+package scheduling
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"go.skia.org/infra/go/git/repograph"
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/task_scheduler/go/db"
+	"go.skia.org/infra/task_scheduler/go/executor"
+	"go.skia.org/infra/task_scheduler/go/flakes"
+)
+
+/*
+	Simulate the Task Scheduler's decisions using recorded data.
+*/
+
+// SimulationParams are the parameters of a scheduling simulation.
+type SimulationParams struct {
+	// Start and End delimit the time period whose recorded Jobs are
+	// replayed.
+	Start time.Time
+	End   time.Time
+
+	// Step is the simulated time between scheduling loops.
+	Step time.Duration
+
+	// Lookback is the time period before Start from which recorded tasks
+	// are loaded as-is, so that the blamelists of the first simulated tasks
+	// are realistic.
+	Lookback time.Duration
+
+	// TimeDecayAmt24Hr is the time decay used to score task candidates.
+	TimeDecayAmt24Hr float64
+
+	// JobPriorities overrides the priorities of the replayed Jobs with the
+	// given names.
+	JobPriorities map[string]float64
+
+	// Workers, if not empty, are available throughout the simulation.
+	// Otherwise, the bots which ran the recorded tasks are used, with
+	// dimensions derived from the TaskSpecs they ran, and each is available
+	// from the start of its first recorded task until the end of its last.
+	Workers []*executor.Worker
+
+	// DefaultTaskDuration is the duration of tasks for TaskSpecs which have
+	// no recorded tasks. Otherwise the median duration of the recorded
+	// tasks is used.
+	DefaultTaskDuration time.Duration
+}
+
+// LatencyStats summarizes a set of latency samples.
+type LatencyStats struct {
+	Count  int           `json:"count"`
+	Mean   time.Duration `json:"mean"`
+	Median time.Duration `json:"median"`
+	P90    time.Duration `json:"p90"`
+	Max    time.Duration `json:"max"`
+}
+
+// newLatencyStats returns a LatencyStats instance for the given samples.
+func newLatencyStats(samples []time.Duration) *LatencyStats {
+	rv := &LatencyStats{
+		Count: len(samples),
+	}
+	if len(samples) == 0 {
+		return rv
+	}
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	var total time.Duration
+	for _, s := range sorted {
+		total += s
+	}
+	rv.Mean = total / time.Duration(len(sorted))
+	rv.Median = sorted[len(sorted)/2]
+	rv.P90 = sorted[len(sorted)*9/10]
+	rv.Max = sorted[len(sorted)-1]
+	return rv
+}
+
+// SimulationResult describes the outcome of a scheduling simulation.
+type SimulationResult struct {
+	// TimeDecayAmt24Hr is the time decay which was used.
+	TimeDecayAmt24Hr float64 `json:"time_decay_amt_24hr"`
+
+	// JobsReplayed is the number of recorded Jobs which were replayed.
+	JobsReplayed int `json:"jobs_replayed"`
+
+	// JobsSkipped is the number of recorded Jobs which were not replayed,
+	// ie. try jobs and Jobs for unknown repos.
+	JobsSkipped int `json:"jobs_skipped"`
+
+	// JobsFinished is the number of replayed Jobs which finished.
+	JobsFinished int `json:"jobs_finished"`
+
+	// TasksTriggered is the number of simulated tasks.
+	TasksTriggered int `json:"tasks_triggered"`
+
+	// QueueLatency measures the time from when a task candidate first
+	// appeared in the queue until it was triggered.
+	QueueLatency *LatencyStats `json:"queue_latency"`
+
+	// JobLatency measures the time from when a Job was created until all
+	// of its tasks finished, for Jobs created after the start of the
+	// simulation.
+	JobLatency *LatencyStats `json:"job_latency"`
+
+	// BackfillCoverage is the fraction of (commit, TaskSpec) pairs
+	// requested by the replayed non-forced Jobs which are covered by the
+	// blamelist of a finished task at the end of the simulation.
+	BackfillCoverage float64 `json:"backfill_coverage"`
+
+	// BotUtilization is the fraction of the time during which the bots
+	// were available that they spent running tasks.
+	BotUtilization float64 `json:"bot_utilization"`
+
+	// Workers describes the usage of each bot, keyed by ID.
+	Workers map[string]*executor.WorkerUsage `json:"workers"`
+}
+
+// medianTaskDurations returns the median duration of the finished tasks for
+// each TaskSpec, keyed by name.
+func medianTaskDurations(tasks []*db.Task) map[string]time.Duration {
+	byName := map[string][]time.Duration{}
+	for _, t := range tasks {
+		if !t.Done() || util.TimeIsZero(t.Started) || !t.Finished.After(t.Started) {
+			continue
+		}
+		byName[t.Name] = append(byName[t.Name], t.Finished.Sub(t.Started))
+	}
+	rv := make(map[string]time.Duration, len(byName))
+	for name, durations := range byName {
+		rv[name] = newLatencyStats(durations).Median
+	}
+	return rv
+}
+
+// addRecordedWorkers adds the bots which ran the given recorded tasks to the
+// given SimulatedTaskExecutor. Each bot's dimensions are the union of the
+// dimensions of the TaskSpecs it ran, and it is available from the start of
+// its first task until the end of its last task, clipped to [start, end).
+func addRecordedWorkers(ctx context.Context, s *TaskScheduler, e *executor.SimulatedTaskExecutor, tasks []*db.Task, start, end time.Time) error {
+	type recordedWorker struct {
+		dims    util.StringSet
+		online  time.Time
+		offline time.Time
+	}
+	workers := map[string]*recordedWorker{}
+	for _, t := range tasks {
+		if t.SwarmingBotId == "" || util.TimeIsZero(t.Started) {
+			continue
+		}
+		finished := t.Finished
+		if util.TimeIsZero(finished) {
+			finished = end
+		}
+		if !t.Started.Before(end) || finished.Before(start) {
+			continue
+		}
+		w, ok := workers[t.SwarmingBotId]
+		if !ok {
+			w = &recordedWorker{
+				dims:    util.StringSet{},
+				online:  t.Started,
+				offline: finished,
+			}
+			workers[t.SwarmingBotId] = w
+		}
+		if t.Started.Before(w.online) {
+			w.online = t.Started
+		}
+		if finished.After(w.offline) {
+			w.offline = finished
+		}
+		// We can't read the TaskSpecs of try jobs without applying
+		// their patches.
+		if t.IsTryJob() {
+			continue
+		}
+		spec, err := s.taskCfgCache.GetTaskSpec(ctx, t.RepoState, t.Name)
+		if err != nil {
+			sklog.Warningf("Failed to obtain dimensions of bot %s from task %s: %s", t.SwarmingBotId, t.Id, err)
+			continue
+		}
+		w.dims.AddLists(spec.Dimensions)
+	}
+	for id, w := range workers {
+		if len(w.dims) == 0 {
+			sklog.Warningf("Unable to determine dimensions of bot %s; ignoring.", id)
+			continue
+		}
+		dims := w.dims.Keys()
+		sort.Strings(dims)
+		online, offline := w.online, w.offline
+		if online.Before(start) {
+			online = start
+		}
+		if offline.After(end) {
+			offline = end
+		}
+		if err := e.AddWorker(&executor.Worker{Id: id, Dimensions: dims}, online, offline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Simulate replays the Jobs which were recorded in the given snapshot during
+// the simulated time period through a TaskScheduler which uses a
+// SimulatedTaskExecutor. Tasks recorded before the simulated time period are
+// loaded as-is. Try jobs are not replayed. The given repos must already be
+// updated, and the given workdir is used by the simulated TaskScheduler and
+// may be shared by consecutive simulations.
+//
+// Simulated tasks always succeed, so retries of failed tasks, and the bot time
+// they use, are not modelled and the simulated latencies may be lower than the
+// recorded ones.
+func Simulate(ctx context.Context, snapshot db.DB, repos repograph.Map, workdir string, p SimulationParams) (*SimulationResult, error) {
+	if !p.Start.Before(p.End) {
+		return nil, fmt.Errorf("Simulation must start before it ends.")
+	}
+	if p.Step <= 0 {
+		return nil, fmt.Errorf("Simulation step must be positive.")
+	}
+
+	// Read the recorded data.
+	from := p.Start.Add(-p.Lookback)
+	tasks, err := snapshot.GetTasksFromDateRange(from, p.End, "")
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := snapshot.GetJobsFromDateRange(from, p.End)
+	if err != nil {
+		return nil, err
+	}
+	rv := &SimulationResult{
+		TimeDecayAmt24Hr: p.TimeDecayAmt24Hr,
+	}
+	d := db.NewInMemoryDB()
+	seed := make([]*db.Task, 0, len(tasks))
+	for _, t := range tasks {
+		if t.Created.Before(p.Start) {
+			seed = append(seed, t.Copy())
+		}
+	}
+	if err := d.PutTasks(seed); err != nil {
+		return nil, err
+	}
+	replay := make([]*db.Job, 0, len(jobs))
+	for _, j := range jobs {
+		// Replay Jobs which were created or still running during the
+		// simulated time period.
+		if j.Created.Before(p.Start) && j.Done() && !j.Finished.After(p.Start) {
+			continue
+		}
+		if _, ok := repos[j.Repo]; !ok || j.IsTryJob() {
+			rv.JobsSkipped++
+			continue
+		}
+		cp := j.Copy()
+		cp.BuildbucketBuildId = 0
+		cp.BuildbucketLeaseKey = 0
+		cp.DbModified = time.Time{}
+		cp.Finished = time.Time{}
+		cp.Id = ""
+		cp.Status = db.JOB_STATUS_IN_PROGRESS
+		cp.Tasks = map[string][]*db.TaskSummary{}
+		if priority, ok := p.JobPriorities[cp.Name]; ok {
+			cp.Priority = priority
+		}
+		replay = append(replay, cp)
+	}
+	sort.Slice(replay, func(i, j int) bool {
+		return replay[i].Created.Before(replay[j].Created)
+	})
+	rv.JobsReplayed = len(replay)
+
+	// Create the simulated TaskScheduler. Its window covers the whole
+	// simulation, since it is based on the actual current time.
+	// It doesn't handle try jobs, so it needs no Gerrit.
+	e := executor.NewSimulatedTaskExecutor(p.Start, medianTaskDurations(tasks), p.DefaultTaskDuration)
+	if err := os.MkdirAll(workdir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	period := time.Now().Sub(from)
+	s, err := NewTaskScheduler(ctx, d, period, 0, workdir, "localhost", repos, nil, e, http.DefaultClient, p.TimeDecayAmt24Hr, flakes.Policy{}, "", "", nil, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Workers) > 0 {
+		for _, w := range p.Workers {
+			if err := e.AddWorker(w, p.Start, p.End); err != nil {
+				return nil, err
+			}
+		}
+	} else if err := addRecordedWorkers(ctx, s, e, tasks, p.Start, p.End); err != nil {
+		return nil, err
+	}
+
+	// Run the scheduling loop.
+	firstQueued := map[db.TaskKey]time.Time{}
+	queueLatency := []time.Duration{}
+	for now := p.Start; now.Before(p.End); now = now.Add(p.Step) {
+		e.SetTime(now)
+		if err := s.updateUnfinishedTasks(ctx); err != nil {
+			return nil, err
+		}
+		newJobs := []*db.Job{}
+		for len(replay) > 0 && !replay[0].Created.After(now) {
+			newJobs = append(newJobs, replay[0])
+			replay = replay[1:]
+		}
+		if err := d.PutJobs(newJobs); err != nil {
+			return nil, err
+		}
+		if err := s.jCache.Update(); err != nil {
+			return nil, err
+		}
+		if err := s.updateUnfinishedJobs(); err != nil {
+			return nil, err
+		}
+		queue, err := s.regenerateTaskQueue(ctx, now)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range queue {
+			if _, ok := firstQueued[c.TaskKey]; !ok {
+				firstQueued[c.TaskKey] = now
+			}
+		}
+		workers, err := e.GetFreeWorkers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.scheduleTasks(ctx, workers, queue); err != nil {
+			return nil, err
+		}
+		remaining := make(map[db.TaskKey]bool, s.QueueLen())
+		s.queueMtx.RLock()
+		for _, c := range s.queue {
+			remaining[c.TaskKey] = true
+		}
+		s.queueMtx.RUnlock()
+		for _, c := range queue {
+			if !remaining[c.TaskKey] {
+				queueLatency = append(queueLatency, now.Sub(firstQueued[c.TaskKey]))
+				delete(firstQueued, c.TaskKey)
+			}
+		}
+	}
+	e.SetTime(p.End)
+	if err := s.updateUnfinishedTasks(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.jCache.Update(); err != nil {
+		return nil, err
+	}
+	if err := s.updateUnfinishedJobs(); err != nil {
+		return nil, err
+	}
+
+	// Compute the results.
+	rv.QueueLatency = newLatencyStats(queueLatency)
+	simulated, err := d.GetTasksFromDateRange(p.Start, p.End, "")
+	if err != nil {
+		return nil, err
+	}
+	rv.TasksTriggered = len(simulated)
+	finishedJobs, err := d.GetJobsFromDateRange(from, p.End)
+	if err != nil {
+		return nil, err
+	}
+	jobLatency := []time.Duration{}
+	total, covered := 0, 0
+	for _, j := range finishedJobs {
+		if !j.IsForce {
+			for name := range j.Dependencies {
+				total++
+				t, err := s.tCache.GetTaskForCommit(j.Repo, j.Revision, name)
+				if err != nil {
+					return nil, err
+				}
+				if t != nil && t.Done() {
+					covered++
+				}
+			}
+		}
+		if !j.Done() {
+			continue
+		}
+		rv.JobsFinished++
+		if j.Created.Before(p.Start) {
+			continue
+		}
+		var finished time.Time
+		for _, summaries := range j.Tasks {
+			for _, summary := range summaries {
+				t, err := d.GetTaskById(summary.Id)
+				if err != nil {
+					return nil, err
+				}
+				if t != nil && t.Finished.After(finished) {
+					finished = t.Finished
+				}
+			}
+		}
+		if finished.After(j.Created) {
+			jobLatency = append(jobLatency, finished.Sub(j.Created))
+		}
+	}
+	rv.JobLatency = newLatencyStats(jobLatency)
+	if total > 0 {
+		rv.BackfillCoverage = float64(covered) / float64(total)
+	}
+	rv.Workers = e.Usage()
+	var online, busy time.Duration
+	for _, u := range rv.Workers {
+		online += u.Online
+		busy += u.Busy
+	}
+	if online > 0 {
+		rv.BotUtilization = float64(busy) / float64(online)
+	}
+	return rv, nil
+}
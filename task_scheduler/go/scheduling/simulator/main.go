@@ -0,0 +1,142 @@
+// Replay the Jobs recorded in a snapshot of the task scheduler DB through a
+// simulated TaskScheduler, to compare the effects of scheduling parameters
+// without touching production.
+//
+// Example:
+//   simulator --db /path/to/task_scheduler.bdb --repo https://skia.googlesource.com/skia.git \
+//     --workdir /tmp/simulator --start 2018-01-01T00:00:00Z --period 24h \
+//     --time_decay_amt_24hr 0.9,0.95,1.0
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.skia.org/infra/go/common"
+	"go.skia.org/infra/go/git/repograph"
+	"go.skia.org/infra/go/sklog"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/task_scheduler/go/db/local_db"
+	"go.skia.org/infra/task_scheduler/go/executor"
+	"go.skia.org/infra/task_scheduler/go/scheduling"
+)
+
+var (
+	dbfile              = flag.String("db", local_db.DB_FILENAME, "Snapshot of the task scheduler DB to read.")
+	defaultTaskDuration = flag.Duration("default_task_duration", 10*time.Minute, "Duration of tasks whose TaskSpecs have no recorded tasks.")
+	jobPriorities       = common.NewMultiStringFlag("job_priority", nil, "Override the priority of the replayed Jobs with the given name, in the form \"name=priority\".")
+	lookback            = flag.Duration("lookback", 24*time.Hour, "Time period before the start of the simulation from which recorded tasks are loaded as-is.")
+	output              = flag.String("output", "", "If set, write the results as JSON to this file.")
+	period              = flag.Duration("period", 24*time.Hour, "Duration of the simulated time period.")
+	repoUrls            = common.NewMultiStringFlag("repo", nil, "Repositories whose Jobs are replayed.")
+	startStr            = flag.String("start", "", "Beginning of the simulated time period; default (now - lookback - period). Format is "+time.RFC3339+".")
+	step                = flag.Duration("step", time.Minute, "Simulated time between scheduling loops.")
+	timeDecayAmts       = flag.String("time_decay_amt_24hr", "1.0", "Comma-separated list of time decay values; one simulation is run for each.")
+	workdir             = flag.String("workdir", "workdir", "Working directory to use.")
+	workersFile         = flag.String("workers", "", "JSON file containing a list of workers which are available throughout the simulation. If not set, the bots which ran the recorded tasks are used.")
+)
+
+func main() {
+
+	// Global init.
+	common.Init()
+
+	if len(*repoUrls) == 0 {
+		sklog.Fatal("At least one --repo is required.")
+	}
+	start := time.Now().UTC().Add(-*lookback - *period)
+	if *startStr != "" {
+		parsed, err := time.Parse(time.RFC3339, *startStr)
+		if err != nil {
+			sklog.Fatal(err)
+		}
+		start = parsed.UTC()
+	}
+	decays := []float64{}
+	for _, s := range strings.Split(*timeDecayAmts, ",") {
+		decay, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			sklog.Fatalf("Invalid --time_decay_amt_24hr %q: %s", s, err)
+		}
+		decays = append(decays, decay)
+	}
+	priorities := make(map[string]float64, len(*jobPriorities))
+	for _, s := range *jobPriorities {
+		split := strings.SplitN(s, "=", 2)
+		if len(split) != 2 {
+			sklog.Fatalf("Invalid --job_priority %q; expected \"name=priority\".", s)
+		}
+		priority, err := strconv.ParseFloat(split[1], 64)
+		if err != nil {
+			sklog.Fatalf("Invalid --job_priority %q: %s", s, err)
+		}
+		priorities[split[0]] = priority
+	}
+	var workers []*executor.Worker
+	if *workersFile != "" {
+		var err error
+		workers, err = executor.ReadLocalWorkers(*workersFile)
+		if err != nil {
+			sklog.Fatal(err)
+		}
+	}
+
+	d, err := local_db.NewDB(local_db.DB_NAME, *dbfile)
+	if err != nil {
+		sklog.Fatal(err)
+	}
+	defer util.Close(d)
+
+	ctx := context.Background()
+	if err := os.MkdirAll(*workdir, os.ModePerm); err != nil {
+		sklog.Fatal(err)
+	}
+	repos, err := repograph.NewMap(ctx, *repoUrls, *workdir)
+	if err != nil {
+		sklog.Fatal(err)
+	}
+	if err := repos.Update(ctx); err != nil {
+		sklog.Fatal(err)
+	}
+
+	results := make([]*scheduling.SimulationResult, 0, len(decays))
+	for _, decay := range decays {
+		sklog.Infof("Simulating %s to %s with time decay %f...", start, start.Add(*period), decay)
+		res, err := scheduling.Simulate(ctx, d, repos, *workdir, scheduling.SimulationParams{
+			Start:               start,
+			End:                 start.Add(*period),
+			Step:                *step,
+			Lookback:            *lookback,
+			TimeDecayAmt24Hr:    decay,
+			JobPriorities:       priorities,
+			Workers:             workers,
+			DefaultTaskDuration: *defaultTaskDuration,
+		})
+		if err != nil {
+			sklog.Fatal(err)
+		}
+		results = append(results, res)
+	}
+
+	if *output != "" {
+		if err := util.WithWriteFile(*output, func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(results)
+		}); err != nil {
+			sklog.Fatal(err)
+		}
+	}
+
+	fmt.Printf("%-10s %-8s %-8s %-8s %-12s %-12s %-12s %-12s %-10s %-10s\n", "decay", "jobs", "done", "tasks", "queue_p50", "queue_p90", "job_p50", "job_p90", "backfill", "util")
+	for _, res := range results {
+		fmt.Printf("%-10.3f %-8d %-8d %-8d %-12s %-12s %-12s %-12s %-10.3f %-10.3f\n", res.TimeDecayAmt24Hr, res.JobsReplayed, res.JobsFinished, res.TasksTriggered, res.QueueLatency.Median, res.QueueLatency.P90, res.JobLatency.Median, res.JobLatency.P90, res.BackfillCoverage, res.BotUtilization)
+	}
+}
@@ -0,0 +1,152 @@
+package scheduling
+
+import (
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/git/repograph"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/task_scheduler/go/db"
+	"go.skia.org/infra/task_scheduler/go/executor"
+	specs_testutils "go.skia.org/infra/task_scheduler/go/specs/testutils"
+)
+
+func TestNewLatencyStats(t *testing.T) {
+	testutils.SmallTest(t)
+	assert.Equal(t, &LatencyStats{}, newLatencyStats(nil))
+	samples := []time.Duration{}
+	for i := 10; i > 0; i-- {
+		samples = append(samples, time.Duration(i)*time.Minute)
+	}
+	assert.Equal(t, &LatencyStats{
+		Count:  10,
+		Mean:   330 * time.Second,
+		Median: 6 * time.Minute,
+		P90:    10 * time.Minute,
+		Max:    10 * time.Minute,
+	}, newLatencyStats(samples))
+	// The samples are not modified.
+	assert.Equal(t, 10*time.Minute, samples[0])
+}
+
+func TestSimulate(t *testing.T) {
+	testutils.LargeTest(t)
+	ctx, gb, _, c2 := specs_testutils.SetupTestRepo(t)
+	defer gb.Cleanup()
+	wd, cleanup := testutils.TempDir(t)
+	defer cleanup()
+	repos, err := repograph.NewMap(ctx, []string{gb.RepoUrl()}, wd)
+	assert.NoError(t, err)
+	assert.NoError(t, repos.Update(ctx))
+
+	// Record one Job for each JobSpec at c2, plus a try job which is not
+	// replayed.
+	start := repos[gb.RepoUrl()].Get(c2).Timestamp.Add(time.Minute)
+	snapshot := db.NewInMemoryDB()
+	rs := db.RepoState{
+		Repo:     gb.RepoUrl(),
+		Revision: c2,
+	}
+	jobs := []*db.Job{}
+	addJob := func(name string, deps map[string][]string) *db.Job {
+		j := &db.Job{
+			Created:      start.Add(time.Minute),
+			Dependencies: deps,
+			Name:         name,
+			Priority:     0.8,
+			RepoState:    rs,
+		}
+		jobs = append(jobs, j)
+		return j
+	}
+	addJob(specs_testutils.BuildTask, map[string][]string{
+		specs_testutils.BuildTask: {},
+	})
+	addJob(specs_testutils.TestTask, map[string][]string{
+		specs_testutils.BuildTask: {},
+		specs_testutils.TestTask:  {specs_testutils.BuildTask},
+	})
+	addJob(specs_testutils.PerfTask, map[string][]string{
+		specs_testutils.BuildTask: {},
+		specs_testutils.PerfTask:  {specs_testutils.BuildTask},
+	})
+	tryJob := addJob(specs_testutils.BuildTask, map[string][]string{
+		specs_testutils.BuildTask: {},
+	})
+	tryJob.Patch = db.Patch{
+		Issue:    "123",
+		Patchset: "1",
+		Server:   fakeGerritUrl,
+	}
+	assert.NoError(t, snapshot.PutJobs(jobs))
+
+	// One Linux bot and one Android bot, which has to run the Test and
+	// Perf tasks one after the other.
+	res, err := Simulate(ctx, snapshot, repos, wd, SimulationParams{
+		Start:            start,
+		End:              start.Add(2 * time.Hour),
+		Step:             time.Minute,
+		Lookback:         time.Hour,
+		TimeDecayAmt24Hr: 0.9,
+		Workers: []*executor.Worker{
+			{Id: "linux", Dimensions: []string{"pool:Skia", "os:Ubuntu"}},
+			{Id: "android", Dimensions: []string{"pool:Skia", "os:Android", "device_type:grouper"}},
+		},
+		DefaultTaskDuration: 10 * time.Minute,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.9, res.TimeDecayAmt24Hr)
+	assert.Equal(t, 3, res.JobsReplayed)
+	assert.Equal(t, 1, res.JobsSkipped)
+	assert.Equal(t, 3, res.JobsFinished)
+	assert.Equal(t, 3, res.TasksTriggered)
+	assert.Equal(t, &LatencyStats{
+		Count:  3,
+		Mean:   200 * time.Second,
+		Median: 0,
+		P90:    10 * time.Minute,
+		Max:    10 * time.Minute,
+	}, res.QueueLatency)
+	assert.Equal(t, &LatencyStats{
+		Count:  3,
+		Mean:   20 * time.Minute,
+		Median: 20 * time.Minute,
+		P90:    30 * time.Minute,
+		Max:    30 * time.Minute,
+	}, res.JobLatency)
+	assert.Equal(t, 1.0, res.BackfillCoverage)
+	assert.Equal(t, 0.125, res.BotUtilization)
+	assert.Equal(t, &executor.WorkerUsage{
+		Online: 2 * time.Hour,
+		Busy:   20 * time.Minute,
+	}, res.Workers["android"])
+
+	// Without a Linux bot, nothing can run.
+	res, err = Simulate(ctx, snapshot, repos, wd, SimulationParams{
+		Start:            start,
+		End:              start.Add(2 * time.Hour),
+		Step:             time.Minute,
+		Lookback:         time.Hour,
+		TimeDecayAmt24Hr: 0.9,
+		Workers: []*executor.Worker{
+			{Id: "android", Dimensions: []string{"pool:Skia", "os:Android", "device_type:grouper"}},
+		},
+		DefaultTaskDuration: 10 * time.Minute,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, res.JobsReplayed)
+	assert.Equal(t, 0, res.TasksTriggered)
+	assert.Equal(t, 0, res.JobsFinished)
+	assert.Equal(t, 0.0, res.BackfillCoverage)
+	assert.Equal(t, 0.0, res.BotUtilization)
+	assert.Equal(t, 0, res.QueueLatency.Count)
+
+	// Invalid parameters.
+	_, err = Simulate(ctx, snapshot, repos, wd, SimulationParams{
+		Start: start,
+		End:   start,
+		Step:  time.Minute,
+	})
+	assert.EqualError(t, err, "Simulation must start before it ends.")
+}
@@ -2,20 +2,20 @@ package scheduling
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"path"
 	"sort"
 	"strconv"
 	"strings"
 
-	swarming_api "go.chromium.org/luci/common/api/swarming/swarming/v1"
-	"go.skia.org/infra/go/common"
 	"go.skia.org/infra/go/isolate"
-	"go.skia.org/infra/go/swarming"
 	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/task_scheduler/go/db"
+	"go.skia.org/infra/task_scheduler/go/executor"
 	"go.skia.org/infra/task_scheduler/go/specs"
 )
 
@@ -179,123 +179,79 @@ func replaceVars(c *taskCandidate, s, taskId string) string {
 	return s
 }
 
-// MakeTaskRequest creates a SwarmingRpcsNewTaskRequest object from the taskCandidate.
-func (c *taskCandidate) MakeTaskRequest(id, isolateServer, pubSubTopic string) (*swarming_api.SwarmingRpcsNewTaskRequest, error) {
-	var caches []*swarming_api.SwarmingRpcsCacheEntry
-	if len(c.TaskSpec.Caches) > 0 {
-		caches = make([]*swarming_api.SwarmingRpcsCacheEntry, 0, len(c.TaskSpec.Caches))
-		for _, cache := range c.TaskSpec.Caches {
-			caches = append(caches, &swarming_api.SwarmingRpcsCacheEntry{
-				Name: cache.Name,
-				Path: cache.Path,
-			})
-		}
-	}
-	var cipdInput *swarming_api.SwarmingRpcsCipdInput
-	if len(c.TaskSpec.CipdPackages) > 0 {
-		cipdInput = &swarming_api.SwarmingRpcsCipdInput{
-			Packages: make([]*swarming_api.SwarmingRpcsCipdPackage, 0, len(c.TaskSpec.CipdPackages)),
-		}
-		for _, p := range c.TaskSpec.CipdPackages {
-			cipdInput.Packages = append(cipdInput.Packages, &swarming_api.SwarmingRpcsCipdPackage{
-				PackageName: p.Name,
-				Path:        p.Path,
-				Version:     p.Version,
-			})
-		}
-	}
-
-	dims := make([]*swarming_api.SwarmingRpcsStringPair, 0, len(c.TaskSpec.Dimensions))
-	dimsMap := make(map[string]string, len(c.TaskSpec.Dimensions))
-	for _, d := range c.TaskSpec.Dimensions {
-		split := strings.SplitN(d, ":", 2)
-		key := split[0]
-		val := split[1]
-		dims = append(dims, &swarming_api.SwarmingRpcsStringPair{
-			Key:   key,
-			Value: val,
-		})
-		dimsMap[key] = val
-	}
-
-	var env []*swarming_api.SwarmingRpcsStringPair
-	if len(c.TaskSpec.Environment) > 0 {
-		env = make([]*swarming_api.SwarmingRpcsStringPair, 0, len(c.TaskSpec.Environment))
-		for k, v := range c.TaskSpec.Environment {
-			env = append(env, &swarming_api.SwarmingRpcsStringPair{
-				Key:   k,
-				Value: v,
-			})
-		}
-	}
+// MakeTaskRequest creates an executor.TaskRequest from the taskCandidate,
+// replacing any variables in the TaskSpec.
+func (c *taskCandidate) MakeTaskRequest(id string) *executor.TaskRequest {
+	spec := c.TaskSpec.Copy()
 
-	var envPrefixes []*swarming_api.SwarmingRpcsStringListPair
-	if len(c.TaskSpec.EnvPrefixes) > 0 {
-		envPrefixes = make([]*swarming_api.SwarmingRpcsStringListPair, 0, len(c.TaskSpec.EnvPrefixes))
-		for k, v := range c.TaskSpec.EnvPrefixes {
-			envPrefixes = append(envPrefixes, &swarming_api.SwarmingRpcsStringListPair{
-				Key:   k,
-				Value: util.CopyStringSlice(v),
-			})
-		}
-	}
-
-	cmd := make([]string, 0, len(c.TaskSpec.Command))
+	spec.Command = make([]string, 0, len(c.TaskSpec.Command))
 	for _, arg := range c.TaskSpec.Command {
-		cmd = append(cmd, replaceVars(c, arg, id))
+		spec.Command = append(spec.Command, replaceVars(c, arg, id))
 	}
 
-	extraArgs := make([]string, 0, len(c.TaskSpec.ExtraArgs))
+	spec.ExtraArgs = make([]string, 0, len(c.TaskSpec.ExtraArgs))
 	for _, arg := range c.TaskSpec.ExtraArgs {
-		extraArgs = append(extraArgs, replaceVars(c, arg, id))
+		spec.ExtraArgs = append(spec.ExtraArgs, replaceVars(c, arg, id))
 	}
 
-	extraTags := make(map[string]string, len(c.TaskSpec.ExtraTags))
+	spec.ExtraTags = make(map[string]string, len(c.TaskSpec.ExtraTags))
 	for k, v := range c.TaskSpec.ExtraTags {
-		extraTags[k] = replaceVars(c, v, id)
+		spec.ExtraTags[k] = replaceVars(c, v, id)
 	}
 
-	expirationSecs := int64(c.TaskSpec.Expiration.Seconds())
-	if expirationSecs == int64(0) {
-		expirationSecs = int64(swarming.RECOMMENDED_EXPIRATION.Seconds())
+	return &executor.TaskRequest{
+		TaskId:        id,
+		Attempt:       c.Attempt,
+		IsolatedInput: c.IsolatedInput,
+		ParentTaskIds: util.CopyStringSlice(c.ParentTaskIds),
+		RetryOf:       c.RetryOf,
+		TaskSpec:      spec,
+		TaskKey:       c.TaskKey.Copy(),
 	}
-	executionTimeoutSecs := int64(c.TaskSpec.ExecutionTimeout.Seconds())
-	if executionTimeoutSecs == int64(0) {
-		executionTimeoutSecs = int64(swarming.RECOMMENDED_HARD_TIMEOUT.Seconds())
+}
+
+// MakeInputsHash returns a hash of the inputs of the task which would be
+// triggered for the taskCandidate, ie. its isolated input, command,
+// dimensions, CIPD packages, etc. Variables are replaced in the command as in
+// MakeTaskRequest, except for the task ID, which is unknown when looking for a
+// previous task with the same inputs. The taskCandidate must already have been
+// isolated.
+func (c *taskCandidate) MakeInputsHash() (string, error) {
+	if c.IsolatedInput == "" {
+		return "", fmt.Errorf("Cannot compute inputs hash for %s @ %s; task has not been isolated.", c.Name, c.Revision)
 	}
-	ioTimeoutSecs := int64(c.TaskSpec.IoTimeout.Seconds())
-	if ioTimeoutSecs == int64(0) {
-		ioTimeoutSecs = int64(swarming.RECOMMENDED_IO_TIMEOUT.Seconds())
+	req := c.MakeTaskRequest("")
+	dims := util.CopyStringSlice(req.TaskSpec.Dimensions)
+	sort.Strings(dims)
+	isolatedHashes := util.CopyStringSlice(c.IsolatedHashes)
+	sort.Strings(isolatedHashes)
+	b, err := json.Marshal(struct {
+		CipdPackages   []*specs.CipdPackage
+		Command        []string
+		Dimensions     []string
+		Environment    map[string]string
+		EnvPrefixes    map[string][]string
+		ExtraArgs      []string
+		IsolatedHashes []string
+		IsolatedInput  string
+		Outputs        []string
+		ServiceAccount string
+	}{
+		CipdPackages:   req.TaskSpec.CipdPackages,
+		Command:        req.TaskSpec.Command,
+		Dimensions:     dims,
+		Environment:    req.TaskSpec.Environment,
+		EnvPrefixes:    req.TaskSpec.EnvPrefixes,
+		ExtraArgs:      req.TaskSpec.ExtraArgs,
+		IsolatedHashes: isolatedHashes,
+		IsolatedInput:  req.IsolatedInput,
+		Outputs:        req.TaskSpec.Outputs,
+		ServiceAccount: req.TaskSpec.ServiceAccount,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to encode inputs of %s @ %s: %s", c.Name, c.Revision, err)
 	}
-	outputs := util.CopyStringSlice(c.TaskSpec.Outputs)
-	return &swarming_api.SwarmingRpcsNewTaskRequest{
-		ExpirationSecs: expirationSecs,
-		Name:           c.Name,
-		Priority:       swarming.RECOMMENDED_PRIORITY,
-		Properties: &swarming_api.SwarmingRpcsTaskProperties{
-			Caches:               caches,
-			CipdInput:            cipdInput,
-			Command:              cmd,
-			Dimensions:           dims,
-			Env:                  env,
-			EnvPrefixes:          envPrefixes,
-			ExecutionTimeoutSecs: executionTimeoutSecs,
-			ExtraArgs:            extraArgs,
-			Idempotent:           false,
-			InputsRef: &swarming_api.SwarmingRpcsFilesRef{
-				Isolated:       c.IsolatedInput,
-				Isolatedserver: isolateServer,
-				Namespace:      isolate.DEFAULT_NAMESPACE,
-			},
-			IoTimeoutSecs: ioTimeoutSecs,
-			Outputs:       outputs,
-		},
-		PubsubTopic:    fmt.Sprintf(swarming.PUBSUB_FULLY_QUALIFIED_TOPIC_TMPL, common.PROJECT_ID, pubSubTopic),
-		PubsubUserdata: id,
-		ServiceAccount: c.TaskSpec.ServiceAccount,
-		Tags:           db.TagsForTask(c.Name, id, c.Attempt, c.RepoState, c.RetryOf, dimsMap, c.ForcedJobId, c.ParentTaskIds, extraTags),
-		User:           "skiabot@google.com",
-	}, nil
+	return fmt.Sprintf("%x", sha256.Sum256(b)), nil
 }
 
 // allDepsMet determines whether all dependencies for the given task candidate
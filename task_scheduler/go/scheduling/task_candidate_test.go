@@ -99,3 +99,63 @@ func TestReplaceVar(t *testing.T) {
 	c.Server = "https://server"
 	assert.Equal(t, "refs/changes/45/12345/3", replaceVars(c, "<(PATCH_REF)", dummyId))
 }
+
+func TestMakeInputsHash(t *testing.T) {
+	testutils.SmallTest(t)
+	c := makeTaskCandidate("my-task", []string{"os:Linux", "pool:Skia"})
+	c.Repo = "my-repo"
+	c.Revision = "abc123"
+	c.TaskSpec.Command = []string{"run", "<(TASK_NAME)", "<(TASK_ID)"}
+
+	// The candidate must be isolated first.
+	_, err := c.MakeInputsHash()
+	assert.Error(t, err)
+
+	c.IsolatedInput = "isolated-input"
+	c.IsolatedHashes = []string{"dep1", "dep2"}
+	h1, err := c.MakeInputsHash()
+	assert.NoError(t, err)
+	assert.NotEqual(t, "", h1)
+
+	// The hash doesn't depend on the revision, the ordering of dimensions
+	// and dependencies, or on fields which don't affect the outputs.
+	c2 := c.Copy()
+	c2.Revision = "def456"
+	c2.Commits = []string{"def456"}
+	c2.TaskSpec.Dimensions = []string{"pool:Skia", "os:Linux"}
+	c2.IsolatedHashes = []string{"dep2", "dep1"}
+	c2.TaskSpec.Priority = 0.5
+	c2.TaskSpec.ExtraTags = map[string]string{"k": "v"}
+	h2, err := c2.MakeInputsHash()
+	assert.NoError(t, err)
+	assert.Equal(t, h1, h2)
+
+	// Changes to any of the inputs result in a different hash.
+	for _, modify := range []func(*taskCandidate){
+		func(c *taskCandidate) { c.IsolatedInput = "other" },
+		func(c *taskCandidate) { c.IsolatedHashes = []string{"dep1"} },
+		func(c *taskCandidate) { c.TaskSpec.Command = []string{"run"} },
+		func(c *taskCandidate) { c.TaskSpec.Dimensions = []string{"os:Mac", "pool:Skia"} },
+		func(c *taskCandidate) { c.TaskSpec.ExtraArgs = []string{"--verbose"} },
+		func(c *taskCandidate) {
+			c.TaskSpec.CipdPackages = []*specs.CipdPackage{{Name: "pkg", Path: "pkg", Version: "1"}}
+		},
+		func(c *taskCandidate) { c.TaskSpec.Environment = map[string]string{"k": "v"} },
+		func(c *taskCandidate) { c.TaskSpec.Outputs = []string{"out"} },
+	} {
+		cp := c.Copy()
+		modify(cp)
+		h, err := cp.MakeInputsHash()
+		assert.NoError(t, err)
+		assert.NotEqual(t, h1, h)
+	}
+
+	// Commands which use the revision depend on it.
+	c.TaskSpec.Command = []string{"run", "<(REVISION)"}
+	c2.TaskSpec.Command = []string{"run", "<(REVISION)"}
+	h1, err = c.MakeInputsHash()
+	assert.NoError(t, err)
+	h2, err = c2.MakeInputsHash()
+	assert.NoError(t, err)
+	assert.NotEqual(t, h1, h2)
+}
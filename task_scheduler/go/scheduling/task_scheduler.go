@@ -8,12 +8,12 @@ import (
 	"net/http"
 	"path"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
-	swarming_api "go.chromium.org/luci/common/api/swarming/swarming/v1"
 	"go.skia.org/infra/go/common"
 	"go.skia.org/infra/go/gerrit"
 	"go.skia.org/infra/go/git"
@@ -23,11 +23,11 @@ import (
 	"go.skia.org/infra/go/periodic_triggers"
 	"go.skia.org/infra/go/sklog"
 	"go.skia.org/infra/go/swarming"
-	"go.skia.org/infra/go/timeout"
 	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/task_scheduler/go/blacklist"
 	"go.skia.org/infra/task_scheduler/go/db"
-	"go.skia.org/infra/task_scheduler/go/db/local_db"
+	"go.skia.org/infra/task_scheduler/go/executor"
+	"go.skia.org/infra/task_scheduler/go/flakes"
 	"go.skia.org/infra/task_scheduler/go/specs"
 	"go.skia.org/infra/task_scheduler/go/tryjobs"
 	"go.skia.org/infra/task_scheduler/go/window"
@@ -93,11 +93,13 @@ type overdueJobSpecMetricKey struct {
 // TaskScheduler is a struct used for scheduling tasks on bots.
 type TaskScheduler struct {
 	bl                  *blacklist.Blacklist
-	busyBots            *busyBots
 	candidateMetrics    map[string]metrics2.Int64Metric
 	candidateMetricsMtx sync.Mutex
+	cronTriggers        *cronTriggers
 	db                  db.DB
 	depotToolsDir       string
+	executor            executor.TaskExecutor
+	flakes              *flakes.Tracker
 	isolate             *isolate.Client
 	jCache              db.JobCache
 	lastScheduled       time.Time // protected by queueMtx.
@@ -107,12 +109,9 @@ type TaskScheduler struct {
 	newTasksMtx sync.RWMutex
 
 	periodicTriggers *periodic_triggers.Triggerer
-	pools            []string
-	pubsubTopic      string
 	queue            []*taskCandidate // protected by queueMtx.
 	queueMtx         sync.RWMutex
 	repos            repograph.Map
-	swarming         swarming.ApiClient
 	taskCfgCache     *specs.TaskCfgCache
 	tCache           db.TaskCache
 	timeDecayAmt24Hr float64
@@ -123,7 +122,13 @@ type TaskScheduler struct {
 	workdir        string
 }
 
-func NewTaskScheduler(ctx context.Context, d db.DB, period time.Duration, numCommits int, workdir, host string, repos repograph.Map, isolateClient *isolate.Client, swarmingClient swarming.ApiClient, c *http.Client, timeDecayAmt24Hr float64, buildbucketApiUrl, trybotBucket string, projectRepoMapping map[string]string, pools []string, pubsubTopic, depotTools string, gerrit gerrit.GerritInterface) (*TaskScheduler, error) {
+// NewTaskScheduler returns a TaskScheduler instance which runs tasks using the
+// given TaskExecutor. If isolateClient is nil, the inputs of the tasks are not
+// isolated, which is only supported by executors which do not use Isolate.
+// Flaky TaskSpecs are retried and quarantined according to flakePolicy. If
+// gerrit is nil, try jobs are not handled and buildbucketApiUrl, trybotBucket
+// and projectRepoMapping are ignored.
+func NewTaskScheduler(ctx context.Context, d db.DB, period time.Duration, numCommits int, workdir, host string, repos repograph.Map, isolateClient *isolate.Client, taskExecutor executor.TaskExecutor, c *http.Client, timeDecayAmt24Hr float64, flakePolicy flakes.Policy, buildbucketApiUrl, trybotBucket string, projectRepoMapping map[string]string, depotTools string, gerrit gerrit.GerritInterface) (*TaskScheduler, error) {
 	bl, err := blacklist.FromFile(path.Join(workdir, "blacklist.json"))
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create blacklist from file: %s", err)
@@ -149,9 +154,12 @@ func NewTaskScheduler(ctx context.Context, d db.DB, period time.Duration, numCom
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create TaskCfgCache: %s", err)
 	}
-	tryjobs, err := tryjobs.NewTryJobIntegrator(buildbucketApiUrl, trybotBucket, host, c, d, w, projectRepoMapping, repos, taskCfgCache, gerrit)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to create TryJobIntegrator: %s", err)
+	var tryjobIntegrator *tryjobs.TryJobIntegrator
+	if gerrit != nil {
+		tryjobIntegrator, err = tryjobs.NewTryJobIntegrator(buildbucketApiUrl, trybotBucket, host, c, d, w, projectRepoMapping, repos, taskCfgCache, gerrit)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create TryJobIntegrator: %s", err)
+		}
 	}
 
 	pt, err := periodic_triggers.NewTriggerer(workdir)
@@ -159,27 +167,31 @@ func NewTaskScheduler(ctx context.Context, d db.DB, period time.Duration, numCom
 		return nil, fmt.Errorf("Failed to create periodic triggers: %s", err)
 	}
 
+	ct, err := newCronTriggers(workdir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create cron triggers: %s", err)
+	}
+
 	s := &TaskScheduler{
 		bl:               bl,
-		busyBots:         newBusyBots(),
 		candidateMetrics: map[string]metrics2.Int64Metric{},
+		cronTriggers:     ct,
 		db:               d,
 		depotToolsDir:    depotTools,
+		executor:         taskExecutor,
+		flakes:           flakes.NewTracker(d, bl, flakePolicy),
 		isolate:          isolateClient,
 		jCache:           jCache,
 		newTasks:         map[db.RepoState]util.StringSet{},
 		newTasksMtx:      sync.RWMutex{},
 		periodicTriggers: pt,
-		pools:            pools,
-		pubsubTopic:      pubsubTopic,
 		queue:            []*taskCandidate{},
 		queueMtx:         sync.RWMutex{},
 		repos:            repos,
-		swarming:         swarmingClient,
 		taskCfgCache:     taskCfgCache,
 		tCache:           tCache,
 		timeDecayAmt24Hr: timeDecayAmt24Hr,
-		tryjobs:          tryjobs,
+		tryjobs:          tryjobIntegrator,
 		overdueMetrics:   map[overdueJobSpecMetricKey]metrics2.Int64Metric{},
 		window:           w,
 		workdir:          workdir,
@@ -191,7 +203,9 @@ func NewTaskScheduler(ctx context.Context, d db.DB, period time.Duration, numCom
 // Start initiates the TaskScheduler's goroutines for scheduling tasks. beforeMainLoop
 // will be run before each scheduling iteration.
 func (s *TaskScheduler) Start(ctx context.Context, beforeMainLoop func()) {
-	s.tryjobs.Start(ctx)
+	if s.tryjobs != nil {
+		s.tryjobs.Start(ctx)
+	}
 	lvScheduling := metrics2.NewLiveness("last_successful_task_scheduling")
 	lvOverdueMetrics := metrics2.NewLiveness("last_successful_overdue_metrics_update")
 	go util.RepeatCtx(5*time.Second, ctx, func() {
@@ -213,12 +227,20 @@ func (s *TaskScheduler) Start(ctx context.Context, beforeMainLoop func()) {
 	})
 	lvUpdate := metrics2.NewLiveness("last_successful_tasks_update")
 	go util.RepeatCtx(5*time.Minute, ctx, func() {
-		if err := s.updateUnfinishedTasks(); err != nil {
+		if err := s.updateUnfinishedTasks(ctx); err != nil {
 			sklog.Errorf("Failed to run periodic tasks update: %s", err)
 		} else {
 			lvUpdate.Reset()
 		}
 	})
+	lvFlakes := metrics2.NewLiveness("last_successful_flakes_update")
+	go util.RepeatCtx(5*time.Minute, ctx, func() {
+		if err := s.flakes.Update(time.Now()); err != nil {
+			sklog.Errorf("Failed to update flaky task specs: %s", err)
+		} else {
+			lvFlakes.Reset()
+		}
+	})
 }
 
 // TaskSchedulerStatus is a struct which provides status information about the
@@ -361,6 +383,11 @@ func (s *TaskScheduler) CancelJob(id string) (*db.Job, error) {
 //    history, "stealing" commits from the previous task until we find a commit
 //    which was covered by a *different* previous task.
 //
+// Tasks which reuse the outputs of a previous task with the same inputs (see
+// TaskSpec.Idempotent) are treated like any other task: they run at their own
+// revision and their blamelists are computed as above, so the reused outputs
+// are attributed only to the commits not already covered by the original.
+//
 // Args:
 //   - cache:      TaskCache instance.
 //   - repo:       repograph.Graph instance corresponding to the repository of the task.
@@ -540,6 +567,8 @@ func (s *TaskScheduler) filterTaskCandidates(preFilterCandidates map[db.TaskKey]
 			if maxAttempts == 0 {
 				maxAttempts = specs.DEFAULT_TASK_SPEC_MAX_ATTEMPTS
 			}
+			// Flaky TaskSpecs get extra attempts.
+			maxAttempts += s.flakes.ExtraAttempts(c.Name)
 			// Special case for tasks created before arbitrary
 			// numbers of attempts were possible.
 			previousAttempt := previous.Attempt
@@ -682,6 +711,9 @@ func (s *TaskScheduler) processTaskCandidate(ctx context.Context, c *taskCandida
 	score *= decay
 	score *= priority
 
+	// De-prioritize quarantined TaskSpecs.
+	score *= s.flakes.ScoreMultiplier(c.Name)
+
 	c.Score = score
 	return nil
 }
@@ -885,22 +917,26 @@ func (s *TaskScheduler) regenerateTaskQueue(ctx context.Context, now time.Time)
 	return queue, nil
 }
 
-// getCandidatesToSchedule matches the list of free Swarming bots to task
-// candidates in the queue and returns the candidates which should be run.
-// Assumes that the tasks are sorted in decreasing order by score.
-func getCandidatesToSchedule(bots []*swarming_api.SwarmingRpcsBotInfo, tasks []*taskCandidate) []*taskCandidate {
+// getCandidatesToSchedule matches the list of free workers to task candidates
+// in the queue and returns the candidates which should be run. Assumes that the
+// tasks are sorted in decreasing order by score.
+func getCandidatesToSchedule(bots []*executor.Worker, tasks []*taskCandidate) []*taskCandidate {
+	rv, _ := matchBotsToCandidates(bots, tasks)
+	return rv
+}
+
+// matchBotsToCandidates is like getCandidatesToSchedule, but also returns the
+// ID of the bot chosen for each of the returned candidates, keyed by TaskKey.
+func matchBotsToCandidates(bots []*executor.Worker, tasks []*taskCandidate) ([]*taskCandidate, map[db.TaskKey]string) {
 	defer metrics2.FuncTimer().Stop()
-	// Create a bots-by-swarming-dimension mapping.
+	// Create a bots-by-dimension mapping.
 	botsByDim := map[string]util.StringSet{}
 	for _, b := range bots {
-		for _, dim := range b.Dimensions {
-			for _, val := range dim.Value {
-				d := fmt.Sprintf("%s:%s", dim.Key, val)
-				if _, ok := botsByDim[d]; !ok {
-					botsByDim[d] = util.StringSet{}
-				}
-				botsByDim[d][b.BotId] = true
+		for _, d := range b.Dimensions {
+			if _, ok := botsByDim[d]; !ok {
+				botsByDim[d] = util.StringSet{}
 			}
+			botsByDim[d][b.Id] = true
 		}
 	}
 
@@ -909,6 +945,7 @@ func getCandidatesToSchedule(bots []*swarming_api.SwarmingRpcsBotInfo, tasks []*
 	// match so that less-specialized tasks don't "steal" more-specialized
 	// bots which they don't actually need.
 	rv := make([]*taskCandidate, 0, len(bots))
+	botIds := make(map[db.TaskKey]string, len(bots))
 	for _, c := range tasks {
 		// TODO(borenet): Make this threshold configurable.
 		if c.Score <= 0.0 {
@@ -945,6 +982,7 @@ func getCandidatesToSchedule(bots []*swarming_api.SwarmingRpcsBotInfo, tasks []*
 
 			// Add the task to the scheduling list.
 			rv = append(rv, c)
+			botIds[c.TaskKey] = bot
 
 			// If we've exhausted the bot list, stop here.
 			if len(botsByDim) == 0 {
@@ -953,7 +991,7 @@ func getCandidatesToSchedule(bots []*swarming_api.SwarmingRpcsBotInfo, tasks []*
 		}
 	}
 	sort.Sort(taskCandidateSlice(rv))
-	return rv
+	return rv, botIds
 }
 
 // isolateTasks sets up the given RepoState and isolates the given
@@ -986,10 +1024,24 @@ func (s *TaskScheduler) isolateTasks(ctx context.Context, rs db.RepoState, candi
 // isolateCandidates uploads inputs for the taskCandidates to the Isolate
 // server. Returns a channel of the successfully-isolated candidates which is
 // closed after all candidates have been isolated or failed. Each failure is
-// sent to errCh.
+// sent to errCh. If the TaskScheduler has no Isolate client, the candidates
+// are passed through unchanged.
 func (s *TaskScheduler) isolateCandidates(ctx context.Context, candidates []*taskCandidate, errCh chan<- error) <-chan *taskCandidate {
 	defer metrics2.FuncTimer().Stop()
 
+	// Without an Isolate client, the TaskExecutor does not use isolated
+	// inputs, so all candidates can be triggered right away.
+	if s.isolate == nil {
+		isolated := make(chan *taskCandidate)
+		go func() {
+			for _, c := range candidates {
+				isolated <- c
+			}
+			close(isolated)
+		}()
+		return isolated
+	}
+
 	// First, group by RepoState since we have to isolate the code at
 	// that state for each task.
 	byRepoState := map[db.RepoState][]*taskCandidate{}
@@ -1024,10 +1076,28 @@ func (s *TaskScheduler) isolateCandidates(ctx context.Context, candidates []*tas
 	return isolated
 }
 
-// triggerTasks triggers the given slice of tasks to run on Swarming and returns
-// a channel of the successfully-triggered tasks which is closed after all tasks
-// have been triggered or failed. Each failure is sent to errCh.
-func (s *TaskScheduler) triggerTasks(isolated <-chan *taskCandidate, errCh chan<- error) <-chan *db.Task {
+// reuseTaskOutputs marks the given new task as having succeeded at the given
+// time with the outputs of the given previous task, which has the same
+// inputs, instead of running it.
+func reuseTaskOutputs(t, prev *db.Task, now time.Time) {
+	t.Created = now
+	t.Started = now
+	t.Finished = now
+	t.Status = db.TASK_STATUS_SUCCESS
+	t.IsolatedOutput = prev.IsolatedOutput
+	t.SwarmingBotId = prev.SwarmingBotId
+	t.SwarmingTaskId = prev.SwarmingTaskId
+	// Point to the task which actually ran.
+	t.ReusedFrom = prev.Id
+	if prev.ReusedFrom != "" {
+		t.ReusedFrom = prev.ReusedFrom
+	}
+}
+
+// triggerTasks triggers the given slice of tasks to run on the TaskExecutor and
+// returns a channel of the successfully-triggered tasks which is closed after
+// all tasks have been triggered or failed. Each failure is sent to errCh.
+func (s *TaskScheduler) triggerTasks(ctx context.Context, isolated <-chan *taskCandidate, errCh chan<- error) <-chan *db.Task {
 	defer metrics2.FuncTimer().Stop()
 	triggered := make(chan *db.Task)
 	var wg sync.WaitGroup
@@ -1040,27 +1110,40 @@ func (s *TaskScheduler) triggerTasks(isolated <-chan *taskCandidate, errCh chan<
 				errCh <- fmt.Errorf("Failed to trigger task: %s", err)
 				return
 			}
-			req, err := candidate.MakeTaskRequest(t.Id, s.isolate.ServerURL(), s.pubsubTopic)
-			if err != nil {
-				errCh <- fmt.Errorf("Failed to trigger task: %s", err)
-				return
-			}
-			var resp *swarming_api.SwarmingRpcsTaskRequestMetadata
-			if err := timeout.Run(func() error {
-				var err error
-				resp, err = s.swarming.TriggerTask(req)
-				return err
-			}, time.Minute); err != nil {
-				errCh <- fmt.Errorf("Failed to trigger task: %s", err)
-				return
+			// Reuse the outputs of a previous task with the same
+			// inputs, if possible. Without an isolated input, we
+			// can't tell whether the inputs are the same. Forced
+			// tasks always run, since the user explicitly asked for
+			// them, but their outputs may be reused later.
+			if candidate.TaskSpec.Idempotent && candidate.IsolatedInput != "" {
+				hash, err := candidate.MakeInputsHash()
+				if err != nil {
+					errCh <- fmt.Errorf("Failed to trigger task: %s", err)
+					return
+				}
+				t.InputsHash = hash
+				if !candidate.IsForceRun() {
+					prev, err := s.tCache.GetTaskForInputsHash(t.Name, t.InputsHash)
+					if err != nil {
+						errCh <- fmt.Errorf("Failed to trigger task: %s", err)
+						return
+					}
+					if prev != nil {
+						reuseTaskOutputs(t, prev, time.Now().UTC())
+						sklog.Infof("Reusing outputs of task %s for %s @ %s", t.ReusedFrom, t.Name, t.Revision)
+						triggered <- t
+						return
+					}
+				}
 			}
-			created, err := swarming.ParseTimestamp(resp.Request.CreatedTs)
+			req := candidate.MakeTaskRequest(t.Id)
+			executorTaskId, created, err := s.executor.TriggerTask(ctx, req)
 			if err != nil {
 				errCh <- fmt.Errorf("Failed to trigger task: %s", err)
 				return
 			}
 			t.Created = created
-			t.SwarmingTaskId = resp.TaskId
+			t.SwarmingTaskId = executorTaskId
 			triggered <- t
 		}(candidate)
 	}
@@ -1071,13 +1154,10 @@ func (s *TaskScheduler) triggerTasks(isolated <-chan *taskCandidate, errCh chan<
 	return triggered
 }
 
-// scheduleTasks queries for free Swarming bots and triggers tasks according
-// to relative priorities in the queue.
-func (s *TaskScheduler) scheduleTasks(ctx context.Context, bots []*swarming_api.SwarmingRpcsBotInfo, queue []*taskCandidate) error {
+// scheduleTasks matches the given free workers with tasks and triggers tasks
+// according to relative priorities in the queue.
+func (s *TaskScheduler) scheduleTasks(ctx context.Context, bots []*executor.Worker, queue []*taskCandidate) error {
 	defer metrics2.FuncTimer().Stop()
-	// Match free bots with tasks.
-	schedule := getCandidatesToSchedule(bots, queue)
-
 	// Setup the error channel.
 	errs := []error{}
 	errCh := make(chan error)
@@ -1090,23 +1170,58 @@ func (s *TaskScheduler) scheduleTasks(ctx context.Context, bots []*swarming_api.
 		}
 	}()
 
-	// Isolate the tasks by RepoState.
-	isolated := s.isolateCandidates(ctx, schedule, errCh)
-
-	// Trigger Swarming tasks.
-	triggered := s.triggerTasks(isolated, errCh)
-
-	// Collect the tasks we triggered.
+	// Whether a task can reuse the outputs of a previous task is only known
+	// after it has been isolated, ie. after it has been matched with a bot.
+	// Tasks which reuse outputs don't run on their bot, so we match the
+	// freed bots with the remaining candidates until no more outputs are
+	// reused.
 	numTriggered := 0
 	insert := map[string]map[string][]*db.Task{}
-	for t := range triggered {
-		byRepo, ok := insert[t.Repo]
-		if !ok {
-			byRepo = map[string][]*db.Task{}
-			insert[t.Repo] = byRepo
+	freeBots := bots
+	candidates := queue
+	for len(freeBots) > 0 && len(candidates) > 0 {
+		// Match free bots with tasks.
+		schedule, botIds := matchBotsToCandidates(freeBots, candidates)
+		if len(schedule) == 0 {
+			break
 		}
-		byRepo[t.Name] = append(byRepo[t.Name], t)
-		numTriggered++
+
+		// Isolate the tasks by RepoState.
+		isolated := s.isolateCandidates(ctx, schedule, errCh)
+
+		// Trigger the tasks.
+		triggered := s.triggerTasks(ctx, isolated, errCh)
+
+		// Collect the tasks we triggered.
+		freed := util.StringSet{}
+		for t := range triggered {
+			byRepo, ok := insert[t.Repo]
+			if !ok {
+				byRepo = map[string][]*db.Task{}
+				insert[t.Repo] = byRepo
+			}
+			byRepo[t.Name] = append(byRepo[t.Name], t)
+			numTriggered++
+			if t.ReusedFrom != "" {
+				freed[botIds[t.TaskKey]] = true
+			}
+		}
+
+		// Candidates which were matched with a bot are not considered
+		// again, even if they failed to trigger.
+		freeBots = make([]*executor.Worker, 0, len(freed))
+		for _, b := range bots {
+			if freed[b.Id] {
+				freeBots = append(freeBots, b)
+			}
+		}
+		remaining := make([]*taskCandidate, 0, len(candidates)-len(schedule))
+		for _, c := range candidates {
+			if _, ok := botIds[c.TaskKey]; !ok {
+				remaining = append(remaining, c)
+			}
+		}
+		candidates = remaining
 	}
 	close(errCh)
 	errWg.Wait()
@@ -1203,6 +1318,28 @@ func (s *TaskScheduler) RecurseAllBranches(ctx context.Context, fn func(string,
 	return nil
 }
 
+// onMatchingBranch returns true iff the given commit is reachable from any
+// branch whose name matches the given regular expression.
+func onMatchingBranch(ctx context.Context, r *repograph.Graph, c *repograph.Commit, pattern string) (bool, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		// This should have been caught in TasksCfg.Validate.
+		return false, fmt.Errorf("Invalid branch trigger %q: %s", pattern, err)
+	}
+	for _, b := range r.BranchHeads() {
+		if !re.MatchString(b.Name) {
+			continue
+		}
+		isAncestor, err := r.Repo().IsAncestor(ctx, c.Hash, b.Head)
+		if err != nil {
+			return false, err
+		} else if isAncestor {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // gatherNewJobs finds and inserts Jobs for all new commits.
 func (s *TaskScheduler) gatherNewJobs(ctx context.Context) error {
 	defer metrics2.FuncTimer().Stop()
@@ -1225,6 +1362,8 @@ func (s *TaskScheduler) gatherNewJobs(ctx context.Context) error {
 		if err != nil {
 			return false, err
 		}
+		// The files modified by this commit, loaded only when needed.
+		var changedFiles []string
 		for name, spec := range cfg.Jobs {
 			shouldRun := false
 			if !util.In(spec.Trigger, specs.PERIODIC_TRIGGERS) {
@@ -1237,8 +1376,26 @@ func (s *TaskScheduler) gatherNewJobs(ctx context.Context) error {
 					} else if isAncestor {
 						shouldRun = true
 					}
+				} else if pattern, ok := specs.TriggerArg(spec.Trigger, specs.TRIGGER_BRANCH_PREFIX); ok {
+					shouldRun, err = onMatchingBranch(ctx, r, c, pattern)
+					if err != nil {
+						return false, err
+					}
 				}
 			}
+			if shouldRun && len(spec.PathFilters) > 0 {
+				if changedFiles == nil {
+					// "-m" lists the files of merge commits which differ
+					// from any of their parents, which diff-tree omits
+					// otherwise.
+					output, err := r.Repo().Git(ctx, "diff-tree", "--no-commit-id", "--name-only", "-r", "--root", "-m", c.Hash)
+					if err != nil {
+						return false, err
+					}
+					changedFiles = strings.Fields(output)
+				}
+				shouldRun = spec.MatchesPaths(changedFiles)
+			}
 			if shouldRun {
 				j, err := s.taskCfgCache.MakeJob(ctx, rs, name)
 				if err != nil {
@@ -1266,6 +1423,12 @@ func (s *TaskScheduler) gatherNewJobs(ctx context.Context) error {
 	if err := s.triggerPeriodicJobs(ctx); err != nil {
 		return err
 	}
+	if err := s.triggerCronJobs(ctx, time.Now()); err != nil {
+		return err
+	}
+	if err := s.triggerTagJobs(ctx); err != nil {
+		return err
+	}
 
 	return s.jCache.Update()
 }
@@ -1302,13 +1465,13 @@ func (s *TaskScheduler) MainLoop(ctx context.Context) error {
 	var e1, e2 error
 	var wg1, wg2 sync.WaitGroup
 
-	var bots []*swarming_api.SwarmingRpcsBotInfo
+	var bots []*executor.Worker
 	wg1.Add(1)
 	go func() {
 		defer wg1.Done()
 
 		var err error
-		bots, err = getFreeSwarmingBots(s.swarming, s.busyBots, s.pools)
+		bots, err = s.executor.GetFreeWorkers(ctx)
 		if err != nil {
 			e1 = err
 			return
@@ -1423,6 +1586,11 @@ func (ts *TaskScheduler) GetBlacklist() *blacklist.Blacklist {
 	return ts.bl
 }
 
+// GetFlakeTracker returns the flakes.Tracker used by the TaskScheduler.
+func (ts *TaskScheduler) GetFlakeTracker() *flakes.Tracker {
+	return ts.flakes
+}
+
 // testedness computes the total "testedness" of a set of commits covered by a
 // task whose blamelist included N commits. The "testedness" of a task spec at a
 // given commit is defined as follows:
@@ -1479,72 +1647,9 @@ func testednessIncrease(blamelistLength, stoleFromBlamelistLength int) float64 {
 	}
 }
 
-// getFreeSwarmingBots returns a slice of free swarming bots.
-func getFreeSwarmingBots(s swarming.ApiClient, busy *busyBots, pools []string) ([]*swarming_api.SwarmingRpcsBotInfo, error) {
-	defer metrics2.FuncTimer().Stop()
-
-	// Query for free Swarming bots and pending Swarming tasks in all pools.
-	var wg sync.WaitGroup
-	bots := []*swarming_api.SwarmingRpcsBotInfo{}
-	pending := []*swarming_api.SwarmingRpcsTaskResult{}
-	errs := []error{}
-	var mtx sync.Mutex
-	t := time.Time{}
-	for _, pool := range pools {
-		// Free bots.
-		wg.Add(1)
-		go func(pool string) {
-			defer wg.Done()
-			b, err := s.ListFreeBots(pool)
-			mtx.Lock()
-			defer mtx.Unlock()
-			if err != nil {
-				errs = append(errs, err)
-			} else {
-				bots = append(bots, b...)
-			}
-		}(pool)
-
-		// Pending tasks.
-		wg.Add(1)
-		go func(pool string) {
-			defer wg.Done()
-			t, err := s.ListTaskResults(t, t, []string{fmt.Sprintf("pool:%s", pool)}, "PENDING", false)
-			mtx.Lock()
-			defer mtx.Unlock()
-			if err != nil {
-				errs = append(errs, err)
-			} else {
-				pending = append(pending, t...)
-			}
-		}(pool)
-	}
-
-	wg.Wait()
-	if len(errs) > 0 {
-		return nil, fmt.Errorf("Got errors loading bots and tasks from Swarming: %v", errs)
-	}
-
-	rv := make([]*swarming_api.SwarmingRpcsBotInfo, 0, len(bots))
-	for _, bot := range bots {
-		if bot.IsDead {
-			continue
-		}
-		if bot.Quarantined {
-			continue
-		}
-		if bot.TaskId != "" {
-			continue
-		}
-		rv = append(rv, bot)
-	}
-	busy.RefreshTasks(pending)
-	return busy.Filter(rv), nil
-}
-
-// updateUnfinishedTasks queries Swarming for all unfinished tasks and updates
-// their status in the DB.
-func (s *TaskScheduler) updateUnfinishedTasks() error {
+// updateUnfinishedTasks queries the TaskExecutor for all unfinished tasks and
+// updates their status in the DB.
+func (s *TaskScheduler) updateUnfinishedTasks(ctx context.Context) error {
 	defer metrics2.FuncTimer().Stop()
 	// Update the TaskCache.
 	if err := s.tCache.Update(); err != nil {
@@ -1557,22 +1662,19 @@ func (s *TaskScheduler) updateUnfinishedTasks() error {
 	}
 	sort.Sort(db.TaskSlice(tasks))
 
-	// Query Swarming for all unfinished tasks.
+	// Query the TaskExecutor for all unfinished tasks.
 	// TODO(borenet): This would be faster if Swarming had a
 	// get-multiple-tasks-by-ID endpoint.
-	sklog.Infof("Querying Swarming for %d unfinished tasks.", len(tasks))
+	sklog.Infof("Querying the task executor for %d unfinished tasks.", len(tasks))
 	var wg sync.WaitGroup
 	errs := make([]error, len(tasks))
 	for i, t := range tasks {
 		wg.Add(1)
 		go func(idx int, t *db.Task) {
 			defer wg.Done()
-			swarmTask, err := s.swarming.GetTask(t.SwarmingTaskId, false)
-			if err != nil {
-				errs[idx] = fmt.Errorf("Failed to update unfinished task; failed to get updated task from swarming: %s", err)
-				return
-			}
-			if err := db.UpdateDBFromSwarmingTask(s.db, swarmTask); err != nil {
+			if err := db.UpdateTaskIfModified(s.db, t.Id, func(task *db.Task) (bool, error) {
+				return s.executor.UpdateTask(ctx, task)
+			}); err != nil {
 				errs[idx] = fmt.Errorf("Failed to update unfinished task: %s", err)
 				return
 			}
@@ -1917,86 +2019,6 @@ func validateAndUpdateTask(d db.TaskDB, task *db.Task) error {
 	return d.PutTask(task)
 }
 
-// HandleSwarmingPubSub loads the given Swarming task ID from Swarming and
-// updates the associated db.Task in the database. Returns a bool indicating
-// whether the pubsub message should be acknowledged.
-func (s *TaskScheduler) HandleSwarmingPubSub(msg *swarming.PubSubTaskMessage) bool {
-	// First, make sure we have the task in our DB.
-	if msg.UserData != "" {
-		// We use ID of the task in our DB for the UserData field.
-		t, err := s.db.GetTaskById(msg.UserData)
-		if err != nil {
-			sklog.Errorf("Swarming Pub/Sub: Failed to retrieve task %q by ID: %s", msg.SwarmingTaskId, msg.UserData)
-			return true
-		} else if t == nil {
-			ts, _, err := local_db.ParseId(msg.UserData)
-			if err != nil {
-				sklog.Errorf("Failed to parse userdata as task ID: %s", err)
-				return true
-			} else if time.Now().Sub(ts) < 2*time.Minute {
-				sklog.Infof("Failed to update task %q from pub/sub: no such task ID: %q. Less than two minutes old; try again later.", msg.SwarmingTaskId, msg.UserData)
-				return false
-			} else {
-				sklog.Errorf("Failed to update task %q from pub/sub: no such task ID: %q", msg.SwarmingTaskId, msg.UserData)
-				return true
-			}
-		}
-	}
-
-	// Obtain the Swarming task data.
-	res, err := s.swarming.GetTask(msg.SwarmingTaskId, false)
-	if err != nil {
-		sklog.Errorf("pubsub: Failed to retrieve task from Swarming: %s", err)
-		return true
-	}
-	// Skip unfinished tasks.
-	if res.CompletedTs == "" {
-		return true
-	}
-	// Update the task in the DB.
-	if err := db.UpdateDBFromSwarmingTask(s.db, res); err != nil {
-		// TODO(borenet): Some of these cases should never be hit, after all tasks
-		// start supplying the ID in msg.UserData. We should be able to remove the logic.
-		if err == db.ErrNotFound {
-			id, err := swarming.GetTagValue(res, db.SWARMING_TAG_ID)
-			if err != nil {
-				id = "<MISSING ID TAG>"
-			}
-			created, err := swarming.ParseTimestamp(res.CreatedTs)
-			if err != nil {
-				sklog.Errorf("Failed to parse timestamp: %s; %s", res.CreatedTs, err)
-				return true
-			}
-			if time.Now().Sub(created) < 2*time.Minute {
-				sklog.Infof("Failed to update task %q: No such task ID: %q. Less than two minutes old; try again later.", msg.SwarmingTaskId, id)
-				return false
-			}
-			sklog.Errorf("Failed to update task %q: No such task ID: %q", msg.SwarmingTaskId, id)
-			return true
-		} else if err == db.ErrUnknownId {
-			expectedSwarmingTaskId := "<unknown>"
-			id, err := swarming.GetTagValue(res, db.SWARMING_TAG_ID)
-			if err != nil {
-				id = "<MISSING ID TAG>"
-			} else {
-				t, err := s.db.GetTaskById(id)
-				if err != nil {
-					sklog.Errorf("Failed to update task %q; mismatched ID and failed to retrieve task from DB: %s", msg.SwarmingTaskId, err)
-					return true
-				} else {
-					expectedSwarmingTaskId = t.SwarmingTaskId
-				}
-			}
-			sklog.Errorf("Failed to update task %q: Task %s has a different Swarming task ID associated with it: %s", msg.SwarmingTaskId, id, expectedSwarmingTaskId)
-			return true
-		} else {
-			sklog.Errorf("Failed to update task %q: %s", msg.SwarmingTaskId, err)
-			return true
-		}
-	}
-	return true
-}
-
 // updateOverdueJobSpecMetrics updates metrics for MEASUREMENT_OVERDUE_JOB_SPECS.
 func (s *TaskScheduler) updateOverdueJobSpecMetrics(ctx context.Context, now time.Time) error {
 	defer metrics2.FuncTimer().Stop()
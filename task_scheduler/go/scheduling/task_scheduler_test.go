@@ -2,6 +2,7 @@ package scheduling
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"math"
@@ -31,6 +32,8 @@ import (
 	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/task_scheduler/go/blacklist"
 	"go.skia.org/infra/task_scheduler/go/db"
+	"go.skia.org/infra/task_scheduler/go/executor"
+	"go.skia.org/infra/task_scheduler/go/flakes"
 	"go.skia.org/infra/task_scheduler/go/specs"
 	specs_testutils "go.skia.org/infra/task_scheduler/go/specs/testutils"
 	swarming_testutils "go.skia.org/infra/task_scheduler/go/testutils"
@@ -55,17 +58,6 @@ var (
 		"os":   "Ubuntu",
 		"pool": "Skia",
 	}
-
-	androidBotDims = map[string][]string{
-		"pool":        {"Skia"},
-		"os":          {"Android"},
-		"device_type": {"grouper"},
-	}
-
-	linuxBotDims = map[string][]string{
-		"os":   {"Ubuntu"},
-		"pool": {"Skia"},
-	}
 )
 
 func getCommit(t *testing.T, ctx context.Context, gb *git_testutils.GitBuilder, commit string) string {
@@ -207,7 +199,7 @@ func setup(t *testing.T) (context.Context, *git_testutils.GitBuilder, db.DB, *sw
 	assert.NoError(t, ioutil.WriteFile(gitcookies, []byte(".googlesource.com\tTRUE\t/\tTRUE\t123\to\tgit-user.google.com=abc123"), os.ModePerm))
 	g, err := gerrit.NewGerrit(fakeGerritUrl, gitcookies, urlMock.Client())
 	assert.NoError(t, err)
-	s, err := NewTaskScheduler(ctx, d, time.Duration(math.MaxInt64), 0, tmp, "fake.server", repos, isolateClient, swarmingClient, urlMock.Client(), 1.0, tryjobs.API_URL_TESTING, tryjobs.BUCKET_TESTING, projectRepoMapping, swarming.POOLS_PUBLIC, "", depotTools, g)
+	s, err := NewTaskScheduler(ctx, d, time.Duration(math.MaxInt64), 0, tmp, "fake.server", repos, isolateClient, executor.NewSwarmingTaskExecutor(d, swarmingClient, isolateClient.ServerURL(), swarming.POOLS_PUBLIC, ""), urlMock.Client(), 1.0, flakes.DEFAULT_POLICY, tryjobs.API_URL_TESTING, tryjobs.BUCKET_TESTING, projectRepoMapping, depotTools, g)
 	assert.NoError(t, err)
 	return ctx, gb, d, swarmingClient, s, urlMock, func() {
 		testutils.RemoveAll(t, tmp)
@@ -1500,69 +1492,61 @@ func makeTaskCandidate(name string, dims []string) *taskCandidate {
 	}
 }
 
-func makeSwarmingBot(id string, dims []string) *swarming_api.SwarmingRpcsBotInfo {
-	d := make([]*swarming_api.SwarmingRpcsStringListPair, 0, len(dims))
-	for _, s := range dims {
-		split := strings.SplitN(s, ":", 2)
-		d = append(d, &swarming_api.SwarmingRpcsStringListPair{
-			Key:   split[0],
-			Value: []string{split[1]},
-		})
-	}
-	return &swarming_api.SwarmingRpcsBotInfo{
-		BotId:      id,
-		Dimensions: d,
+func makeWorker(id string, dims []string) *executor.Worker {
+	return &executor.Worker{
+		Id:         id,
+		Dimensions: dims,
 	}
 }
 
 func TestGetCandidatesToSchedule(t *testing.T) {
 	testutils.MediumTest(t)
 	// Empty lists.
-	rv := getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{}, []*taskCandidate{})
+	rv := getCandidatesToSchedule([]*executor.Worker{}, []*taskCandidate{})
 	assert.Equal(t, 0, len(rv))
 
 	t1 := makeTaskCandidate("task1", []string{"k:v"})
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{}, []*taskCandidate{t1})
+	rv = getCandidatesToSchedule([]*executor.Worker{}, []*taskCandidate{t1})
 	assert.Equal(t, 0, len(rv))
 
-	b1 := makeSwarmingBot("bot1", []string{"k:v"})
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{})
+	b1 := makeWorker("bot1", []string{"k:v"})
+	rv = getCandidatesToSchedule([]*executor.Worker{b1}, []*taskCandidate{})
 	assert.Equal(t, 0, len(rv))
 
 	// Single match.
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t1})
+	rv = getCandidatesToSchedule([]*executor.Worker{b1}, []*taskCandidate{t1})
 	deepequal.AssertDeepEqual(t, []*taskCandidate{t1}, rv)
 
 	// No match.
 	t1.TaskSpec.Dimensions[0] = "k:v2"
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t1})
+	rv = getCandidatesToSchedule([]*executor.Worker{b1}, []*taskCandidate{t1})
 	assert.Equal(t, 0, len(rv))
 
 	// Add a task candidate to match b1.
 	t1 = makeTaskCandidate("task1", []string{"k:v2"})
 	t2 := makeTaskCandidate("task2", []string{"k:v"})
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t1, t2})
+	rv = getCandidatesToSchedule([]*executor.Worker{b1}, []*taskCandidate{t1, t2})
 	deepequal.AssertDeepEqual(t, []*taskCandidate{t2}, rv)
 
 	// Switch the task order.
 	t1 = makeTaskCandidate("task1", []string{"k:v2"})
 	t2 = makeTaskCandidate("task2", []string{"k:v"})
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t2, t1})
+	rv = getCandidatesToSchedule([]*executor.Worker{b1}, []*taskCandidate{t2, t1})
 	deepequal.AssertDeepEqual(t, []*taskCandidate{t2}, rv)
 
 	// Make both tasks match the bot, ensure that we pick the first one.
 	t1 = makeTaskCandidate("task1", []string{"k:v"})
 	t2 = makeTaskCandidate("task2", []string{"k:v"})
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t1, t2})
+	rv = getCandidatesToSchedule([]*executor.Worker{b1}, []*taskCandidate{t1, t2})
 	deepequal.AssertDeepEqual(t, []*taskCandidate{t1}, rv)
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t2, t1})
+	rv = getCandidatesToSchedule([]*executor.Worker{b1}, []*taskCandidate{t2, t1})
 	deepequal.AssertDeepEqual(t, []*taskCandidate{t2}, rv)
 
 	// Multiple dimensions. Ensure that different permutations of the bots
 	// and tasks lists give us the expected results.
 	dims := []string{"k:v", "k2:v2", "k3:v3"}
-	b1 = makeSwarmingBot("bot1", dims)
-	b2 := makeSwarmingBot("bot2", t1.TaskSpec.Dimensions)
+	b1 = makeWorker("bot1", dims)
+	b2 := makeWorker("bot2", t1.TaskSpec.Dimensions)
 	t1 = makeTaskCandidate("task1", []string{"k:v"})
 	t2 = makeTaskCandidate("task2", dims)
 	// In the first two cases, the task with fewer dimensions has the
@@ -1570,37 +1554,37 @@ func TestGetCandidatesToSchedule(t *testing.T) {
 	// is first in sorted order. The second task does not get scheduled
 	// because there is no bot available which can run it.
 	// TODO(borenet): Use a more optimal solution to avoid this case.
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2}, []*taskCandidate{t1, t2})
+	rv = getCandidatesToSchedule([]*executor.Worker{b1, b2}, []*taskCandidate{t1, t2})
 	deepequal.AssertDeepEqual(t, []*taskCandidate{t1}, rv)
 	t1 = makeTaskCandidate("task1", []string{"k:v"})
 	t2 = makeTaskCandidate("task2", dims)
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b2, b1}, []*taskCandidate{t1, t2})
+	rv = getCandidatesToSchedule([]*executor.Worker{b2, b1}, []*taskCandidate{t1, t2})
 	deepequal.AssertDeepEqual(t, []*taskCandidate{t1}, rv)
 	// In these two cases, the task with more dimensions has the higher
 	// priority. Both tasks get scheduled.
 	t1 = makeTaskCandidate("task1", []string{"k:v"})
 	t2 = makeTaskCandidate("task2", dims)
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2}, []*taskCandidate{t2, t1})
+	rv = getCandidatesToSchedule([]*executor.Worker{b1, b2}, []*taskCandidate{t2, t1})
 	deepequal.AssertDeepEqual(t, []*taskCandidate{t2, t1}, rv)
 	t1 = makeTaskCandidate("task1", []string{"k:v"})
 	t2 = makeTaskCandidate("task2", dims)
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b2, b1}, []*taskCandidate{t2, t1})
+	rv = getCandidatesToSchedule([]*executor.Worker{b2, b1}, []*taskCandidate{t2, t1})
 	deepequal.AssertDeepEqual(t, []*taskCandidate{t2, t1}, rv)
 
 	// Matching dimensions. More bots than tasks.
-	b2 = makeSwarmingBot("bot2", dims)
-	b3 := makeSwarmingBot("bot3", dims)
+	b2 = makeWorker("bot2", dims)
+	b3 := makeWorker("bot3", dims)
 	t1 = makeTaskCandidate("task1", dims)
 	t2 = makeTaskCandidate("task2", dims)
 	t3 := makeTaskCandidate("task3", dims)
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2, b3}, []*taskCandidate{t1, t2})
+	rv = getCandidatesToSchedule([]*executor.Worker{b1, b2, b3}, []*taskCandidate{t1, t2})
 	deepequal.AssertDeepEqual(t, []*taskCandidate{t1, t2}, rv)
 
 	// More tasks than bots.
 	t1 = makeTaskCandidate("task1", dims)
 	t2 = makeTaskCandidate("task2", dims)
 	t3 = makeTaskCandidate("task3", dims)
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2}, []*taskCandidate{t1, t2, t3})
+	rv = getCandidatesToSchedule([]*executor.Worker{b1, b2}, []*taskCandidate{t1, t2, t3})
 	deepequal.AssertDeepEqual(t, []*taskCandidate{t1, t2}, rv)
 }
 
@@ -1756,7 +1740,7 @@ func TestSchedulingE2E(t *testing.T) {
 		makeSwarmingRpcsTaskRequestMetadata(t, t4, linuxTaskDims),
 	}
 	swarmingClient.MockTasks(mockTasks)
-	assert.NoError(t, s.updateUnfinishedTasks())
+	assert.NoError(t, s.updateUnfinishedTasks(ctx))
 	assert.NoError(t, s.MainLoop(ctx))
 	assert.NoError(t, s.tCache.Update())
 	expectLen = 1 // Test task from c1
@@ -1800,7 +1784,7 @@ func TestSchedulingE2E(t *testing.T) {
 	}
 	swarmingClient.MockTasks(mockTasks)
 	swarmingClient.MockBots([]*swarming_api.SwarmingRpcsBotInfo{bot1, bot2, bot3, bot4})
-	assert.NoError(t, s.updateUnfinishedTasks())
+	assert.NoError(t, s.updateUnfinishedTasks(ctx))
 	assert.NoError(t, s.MainLoop(ctx))
 	assert.Equal(t, 0, len(s.queue))
 }
@@ -2022,7 +2006,7 @@ func testMultipleCandidatesBackfillingEachOtherSetup(t *testing.T) (context.Cont
 	g, err := gerrit.NewGerrit(fakeGerritUrl, gitcookies, urlMock.Client())
 	assert.NoError(t, err)
 
-	s, err := NewTaskScheduler(ctx, d, time.Duration(math.MaxInt64), 0, workdir, "fake.server", repos, isolateClient, swarmingClient, mockhttpclient.NewURLMock().Client(), 1.0, tryjobs.API_URL_TESTING, tryjobs.BUCKET_TESTING, projectRepoMapping, swarming.POOLS_PUBLIC, "", depotTools, g)
+	s, err := NewTaskScheduler(ctx, d, time.Duration(math.MaxInt64), 0, workdir, "fake.server", repos, isolateClient, executor.NewSwarmingTaskExecutor(d, swarmingClient, isolateClient.ServerURL(), swarming.POOLS_PUBLIC, ""), mockhttpclient.NewURLMock().Client(), 1.0, flakes.DEFAULT_POLICY, tryjobs.API_URL_TESTING, tryjobs.BUCKET_TESTING, projectRepoMapping, depotTools, g)
 	assert.NoError(t, err)
 
 	mockTasks := []*swarming_api.SwarmingRpcsTaskRequestMetadata{}
@@ -2243,6 +2227,79 @@ func TestSchedulingRetry(t *testing.T) {
 	assert.Equal(t, 5, i)
 }
 
+func TestSchedulingFlakyRetry(t *testing.T) {
+	ctx, gb, d, swarmingClient, s, _, cleanup := setup(t)
+	defer cleanup()
+
+	c1 := getRS1(t, ctx, gb).Revision
+
+	// Run the available compile task at c2.
+	bot1 := makeBot("bot1", linuxTaskDims)
+	swarmingClient.MockBots([]*swarming_api.SwarmingRpcsBotInfo{bot1})
+	assert.NoError(t, s.MainLoop(ctx))
+	assert.NoError(t, s.tCache.Update())
+	tasks, err := s.tCache.UnfinishedTasks()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tasks))
+	t1 := tasks[0]
+	c2 := t1.Revision
+
+	// Add a flaky history at c1: one failure followed by a success.
+	t2 := t1.Copy()
+	t2.Id = "t2Id"
+	t2.Revision = c1
+	t2.Commits = []string{c1}
+	t2.Created = t1.Created.Add(-2 * time.Second)
+	t2.Status = db.TASK_STATUS_FAILURE
+	t2.Finished = time.Now()
+	t3 := t2.Copy()
+	t3.Id = "t3Id"
+	t3.Created = t1.Created.Add(-time.Second)
+	t3.Status = db.TASK_STATUS_SUCCESS
+	t3.IsolatedOutput = "abc123"
+	t1.Commits = []string{c2}
+	t1.Status = db.TASK_STATUS_FAILURE
+	t1.Finished = time.Now()
+	assert.NoError(t, d.PutTasks([]*db.Task{t1, t2, t3}))
+	assert.NoError(t, s.tCache.Update())
+
+	// The TaskSpec is flaky, so it gets two attempts beyond its
+	// MaxAttempts.
+	s.flakes = flakes.NewTracker(d, s.bl, flakes.Policy{
+		Window:         24 * time.Hour,
+		MinTasks:       1,
+		RetryThreshold: 0.1,
+		ExtraAttempts:  2,
+	})
+	assert.NoError(t, s.flakes.Update(time.Now()))
+	assert.Equal(t, 2, s.flakes.ExtraAttempts(t1.Name))
+
+	prev := t1
+	i := 1
+	for {
+		assert.NoError(t, s.MainLoop(ctx))
+		assert.NoError(t, s.tCache.Update())
+		tasks, err = s.tCache.UnfinishedTasks()
+		assert.NoError(t, err)
+		if len(tasks) == 0 {
+			break
+		}
+		assert.Equal(t, 1, len(tasks))
+		retry := tasks[0]
+		assert.Equal(t, prev.Id, retry.RetryOf)
+		assert.Equal(t, i, retry.Attempt)
+		assert.Equal(t, c2, retry.Revision)
+		retry.Status = db.TASK_STATUS_FAILURE
+		retry.Finished = time.Now()
+		assert.NoError(t, d.PutTask(retry))
+		assert.NoError(t, s.tCache.Update())
+
+		prev = retry
+		i++
+	}
+	assert.Equal(t, 7, i)
+}
+
 func TestParentTaskId(t *testing.T) {
 	ctx, _, d, swarmingClient, s, _, cleanup := setup(t)
 	defer cleanup()
@@ -2657,6 +2714,210 @@ func TestTaskTimeouts(t *testing.T) {
 	assert.Equal(t, int64(2*60*60), swarmingTask.Request.ExpirationSecs)
 }
 
+func TestIdempotentTasks(t *testing.T) {
+	ctx, gb, d, swarmingClient, s, _, cleanup := setup(t)
+	defer cleanup()
+
+	// Rewrite tasks.json with an idempotent task.
+	name := "Idempotent-Task"
+	cfg := &specs.TasksCfg{
+		Jobs: map[string]*specs.JobSpec{
+			"Idempotent-Job": {
+				Priority:  1.0,
+				TaskSpecs: []string{name},
+			},
+		},
+		Tasks: map[string]*specs.TaskSpec{
+			name: {
+				CipdPackages: []*specs.CipdPackage{},
+				Dependencies: []string{},
+				Dimensions: []string{
+					"pool:Skia",
+					"os:Mac",
+					"gpu:my-gpu",
+				},
+				Idempotent: true,
+				Isolate:    "compile_skia.isolate",
+				Priority:   1.0,
+			},
+		},
+	}
+	gb.Add(ctx, specs.TASKS_CFG_FILE, testutils.MarshalJSON(t, &cfg))
+	c1 := gb.Commit(ctx)
+
+	// Cycle, ensure that the task is triggered and has an inputs hash.
+	bot := makeBot("bot", map[string]string{"pool": "Skia", "os": "Mac", "gpu": "my-gpu"})
+	swarmingClient.MockBots([]*swarming_api.SwarmingRpcsBotInfo{bot})
+	assert.NoError(t, s.MainLoop(ctx))
+	assert.NoError(t, s.tCache.Update())
+	t1, err := s.tCache.GetTaskForCommit(gb.RepoUrl(), c1, name)
+	assert.NoError(t, err)
+	assert.NotNil(t, t1)
+	assert.NotEqual(t, "", t1.InputsHash)
+	assert.Equal(t, "", t1.ReusedFrom)
+
+	// The task succeeds.
+	t1.Status = db.TASK_STATUS_SUCCESS
+	t1.Started = time.Now()
+	t1.Finished = time.Now()
+	t1.IsolatedOutput = "abc123"
+	t1.SwarmingBotId = "bot"
+	assert.NoError(t, d.PutTask(t1))
+	swarmingClient.MockTasks([]*swarming_api.SwarmingRpcsTaskRequestMetadata{
+		makeSwarmingRpcsTaskRequestMetadata(t, t1, map[string]string{"pool": "Skia", "os": "Mac", "gpu": "my-gpu"}),
+	})
+	assert.NoError(t, s.tCache.Update())
+
+	// Add a commit which doesn't change the inputs of the task. Ensure
+	// that the outputs of the previous task are reused.
+	gb.Add(ctx, "README", "unrelated change")
+	c2 := gb.Commit(ctx)
+	assert.NoError(t, s.MainLoop(ctx))
+	assert.NoError(t, s.tCache.Update())
+	t2, err := s.tCache.GetTaskForCommit(gb.RepoUrl(), c2, name)
+	assert.NoError(t, err)
+	assert.NotNil(t, t2)
+	assert.NotEqual(t, t1.Id, t2.Id)
+	assert.Equal(t, c2, t2.Revision)
+	assert.Equal(t, []string{c2}, t2.Commits)
+	assert.Equal(t, db.TASK_STATUS_SUCCESS, t2.Status)
+	assert.Equal(t, t1.Id, t2.ReusedFrom)
+	assert.Equal(t, t1.InputsHash, t2.InputsHash)
+	assert.Equal(t, t1.IsolatedOutput, t2.IsolatedOutput)
+	assert.Equal(t, t1.SwarmingTaskId, t2.SwarmingTaskId)
+	unfinished, err := s.tCache.UnfinishedTasks()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(unfinished))
+
+	// Forced tasks always run, even if their inputs haven't changed.
+	forcedJobId, err := s.TriggerJob(ctx, gb.RepoUrl(), c2, "Idempotent-Job")
+	assert.NoError(t, err)
+	assert.NoError(t, s.MainLoop(ctx))
+	assert.NoError(t, s.tCache.Update())
+	forced, err := s.tCache.GetTasksByKey(&db.TaskKey{
+		RepoState: db.RepoState{
+			Repo:     gb.RepoUrl(),
+			Revision: c2,
+		},
+		Name:        name,
+		ForcedJobId: forcedJobId,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(forced))
+	assert.Equal(t, "", forced[0].ReusedFrom)
+	assert.Equal(t, t1.InputsHash, forced[0].InputsHash)
+	assert.False(t, forced[0].Done())
+	forced[0].Status = db.TASK_STATUS_SUCCESS
+	forced[0].Started = time.Now()
+	forced[0].Finished = time.Now()
+	forced[0].IsolatedOutput = "def456"
+	assert.NoError(t, d.PutTask(forced[0]))
+	swarmingClient.MockTasks([]*swarming_api.SwarmingRpcsTaskRequestMetadata{
+		makeSwarmingRpcsTaskRequestMetadata(t, t1, map[string]string{"pool": "Skia", "os": "Mac", "gpu": "my-gpu"}),
+		makeSwarmingRpcsTaskRequestMetadata(t, forced[0], map[string]string{"pool": "Skia", "os": "Mac", "gpu": "my-gpu"}),
+	})
+	assert.NoError(t, s.tCache.Update())
+
+	// A commit which changes the inputs causes the task to run again.
+	gb.Add(ctx, "somefile.txt", "new contents")
+	c3 := gb.Commit(ctx)
+	assert.NoError(t, s.MainLoop(ctx))
+	assert.NoError(t, s.tCache.Update())
+	t3, err := s.tCache.GetTaskForCommit(gb.RepoUrl(), c3, name)
+	assert.NoError(t, err)
+	assert.NotNil(t, t3)
+	assert.Equal(t, "", t3.ReusedFrom)
+	assert.NotEqual(t, t1.InputsHash, t3.InputsHash)
+	assert.False(t, t3.Done())
+}
+
+func TestIdempotentTasksFreeBots(t *testing.T) {
+	ctx, gb, d, swarmingClient, s, _, cleanup := setup(t)
+	defer cleanup()
+
+	// Rewrite tasks.json with an idempotent task and a lower-priority
+	// task which needs the same bot.
+	name := "Idempotent-Task"
+	otherName := "Other-Task"
+	dims := []string{
+		"pool:Skia",
+		"os:Mac",
+		"gpu:my-gpu",
+	}
+	cfg := &specs.TasksCfg{
+		Jobs: map[string]*specs.JobSpec{
+			"Idempotent-Job": {
+				Priority:  1.0,
+				TaskSpecs: []string{name},
+			},
+			"Other-Job": {
+				Priority:  0.1,
+				TaskSpecs: []string{otherName},
+			},
+		},
+		Tasks: map[string]*specs.TaskSpec{
+			name: {
+				CipdPackages: []*specs.CipdPackage{},
+				Dependencies: []string{},
+				Dimensions:   dims,
+				Idempotent:   true,
+				Isolate:      "compile_skia.isolate",
+				Priority:     1.0,
+			},
+			otherName: {
+				CipdPackages: []*specs.CipdPackage{},
+				Dependencies: []string{},
+				Dimensions:   dims,
+				Isolate:      "compile_skia.isolate",
+				Priority:     1.0,
+			},
+		},
+	}
+	gb.Add(ctx, specs.TASKS_CFG_FILE, testutils.MarshalJSON(t, &cfg))
+	c1 := gb.Commit(ctx)
+
+	// Cycle with a single bot. Only the idempotent task runs.
+	bot := makeBot("bot", map[string]string{"pool": "Skia", "os": "Mac", "gpu": "my-gpu"})
+	swarmingClient.MockBots([]*swarming_api.SwarmingRpcsBotInfo{bot})
+	assert.NoError(t, s.MainLoop(ctx))
+	assert.NoError(t, s.tCache.Update())
+	t1, err := s.tCache.GetTaskForCommit(gb.RepoUrl(), c1, name)
+	assert.NoError(t, err)
+	assert.NotNil(t, t1)
+	other, err := s.tCache.GetTaskForCommit(gb.RepoUrl(), c1, otherName)
+	assert.NoError(t, err)
+	assert.Nil(t, other)
+
+	// The task succeeds.
+	t1.Status = db.TASK_STATUS_SUCCESS
+	t1.Started = time.Now()
+	t1.Finished = time.Now()
+	t1.IsolatedOutput = "abc123"
+	t1.SwarmingBotId = "bot"
+	assert.NoError(t, d.PutTask(t1))
+	swarmingClient.MockTasks([]*swarming_api.SwarmingRpcsTaskRequestMetadata{
+		makeSwarmingRpcsTaskRequestMetadata(t, t1, map[string]string{"pool": "Skia", "os": "Mac", "gpu": "my-gpu"}),
+	})
+	assert.NoError(t, s.tCache.Update())
+
+	// Add a commit which doesn't change the inputs of the idempotent task.
+	// Its outputs are reused, so the bot it was matched with runs the
+	// other task in the same cycle.
+	gb.Add(ctx, "README", "unrelated change")
+	c2 := gb.Commit(ctx)
+	assert.NoError(t, s.MainLoop(ctx))
+	assert.NoError(t, s.tCache.Update())
+	t2, err := s.tCache.GetTaskForCommit(gb.RepoUrl(), c2, name)
+	assert.NoError(t, err)
+	assert.NotNil(t, t2)
+	assert.Equal(t, t1.Id, t2.ReusedFrom)
+	other, err = s.tCache.GetTaskForCommit(gb.RepoUrl(), c2, otherName)
+	assert.NoError(t, err)
+	assert.NotNil(t, other)
+	assert.Equal(t, "", other.ReusedFrom)
+	assert.False(t, other.Done())
+}
+
 func TestPeriodicJobs(t *testing.T) {
 	ctx, gb, _, _, s, _, cleanup := setup(t)
 	defer cleanup()
@@ -2711,8 +2972,172 @@ func TestPeriodicJobs(t *testing.T) {
 	assert.Equal(t, 6, len(unfinished))
 }
 
+func TestEventTriggeredJobs(t *testing.T) {
+	ctx, gb, _, _, s, _, cleanup := setup(t)
+	defer cleanup()
+
+	// Rewrite tasks.json with jobs which use each kind of trigger.
+	name := "Triggered-Task"
+	jobSpec := func(trigger string, pathFilters ...string) *specs.JobSpec {
+		return &specs.JobSpec{
+			PathFilters: pathFilters,
+			Priority:    1.0,
+			TaskSpecs:   []string{name},
+			Trigger:     trigger,
+		}
+	}
+	cfg := &specs.TasksCfg{
+		Jobs: map[string]*specs.JobSpec{
+			"Path-Job":   jobSpec(specs.TRIGGER_ANY_BRANCH, "src"),
+			"Branch-Job": jobSpec("branch:^release-"),
+			"Tag-Job":    jobSpec("tag:^v[0-9]+$"),
+			"Cron-Job":   jobSpec("cron:0 0 * * *"),
+		},
+		Tasks: map[string]*specs.TaskSpec{
+			name: {
+				Dimensions: []string{
+					"pool:Skia",
+					"os:Linux",
+				},
+				Isolate:  "compile_skia.isolate",
+				Priority: 1.0,
+			},
+		},
+	}
+	gb.Add(ctx, specs.TASKS_CFG_FILE, testutils.MarshalJSON(t, &cfg))
+	gb.Commit(ctx)
+
+	jobsByName := func() map[string]int {
+		assert.NoError(t, s.updateRepos(ctx))
+		assert.NoError(t, s.gatherNewJobs(ctx))
+		jobs, err := s.jCache.UnfinishedJobs()
+		assert.NoError(t, err)
+		rv := map[string]int{}
+		for _, j := range jobs {
+			rv[j.Name]++
+		}
+		return rv
+	}
+
+	// Only the existing per-commit jobs are added.
+	counts := jobsByName()
+	assert.Equal(t, 0, counts["Path-Job"]+counts["Branch-Job"]+counts["Tag-Job"]+counts["Cron-Job"])
+
+	// The path-filtered job only runs for commits which touch src.
+	gb.Add(ctx, "README.md", "hello")
+	gb.Commit(ctx)
+	assert.Equal(t, 0, jobsByName()["Path-Job"])
+	gb.Add(ctx, "src/core/file.cpp", "int main() {}")
+	gb.Commit(ctx)
+	assert.Equal(t, 1, jobsByName()["Path-Job"])
+
+	// Merge commits are matched against the files they change relative to
+	// their parents, so both the commit on the branch and the merge run the
+	// path-filtered job.
+	gb.CreateBranchTrackBranch(ctx, "feature", "master")
+	gb.Add(ctx, "src/core/feature.cpp", "int feature() {}")
+	gb.Commit(ctx)
+	gb.CheckoutBranch(ctx, "master")
+	gb.Add(ctx, "README.md", "hello again")
+	gb.Commit(ctx)
+	merge := gb.MergeBranch(ctx, "feature")
+	assert.Equal(t, 3, jobsByName()["Path-Job"])
+	jobs, err := s.jCache.GetJobsByRepoState("Path-Job", db.RepoState{
+		Repo:     gb.RepoUrl(),
+		Revision: merge,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(jobs))
+
+	// The branch job only runs for commits on matching branches.
+	gb.CreateBranchTrackBranch(ctx, "other", "master")
+	gb.Add(ctx, "other.txt", "other")
+	gb.Commit(ctx)
+	assert.Equal(t, 0, jobsByName()["Branch-Job"])
+	gb.CheckoutBranch(ctx, "master")
+	gb.CreateBranchTrackBranch(ctx, "release-1", "master")
+	gb.Add(ctx, "release.txt", "release")
+	gb.Commit(ctx)
+	counts = jobsByName()
+	assert.Equal(t, 1, counts["Branch-Job"])
+	assert.Equal(t, 3, counts["Path-Job"])
+
+	// The tag job runs once for each commit with a matching tag.
+	gb.CheckoutBranch(ctx, "master")
+	gb.Add(ctx, "tagged.txt", "tagged")
+	tagged := gb.Commit(ctx)
+	gb.CreateTag(ctx, "not-a-release", tagged)
+	assert.Equal(t, 0, jobsByName()["Tag-Job"])
+	gb.CreateTag(ctx, "v1", tagged)
+	assert.Equal(t, 1, jobsByName()["Tag-Job"])
+	assert.Equal(t, 1, jobsByName()["Tag-Job"])
+	jobs, err = s.jCache.GetJobsByRepoState("Tag-Job", db.RepoState{
+		Repo:     gb.RepoUrl(),
+		Revision: tagged,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(jobs))
+
+	// The cron job is not triggered until its schedule fires after it was
+	// first seen, and then only once per firing.
+	key := cronTriggerKey(gb.RepoUrl(), "Cron-Job")
+	first, ok := s.cronTriggers.LastTriggered[key]
+	assert.True(t, ok)
+	countCronJobs := func(now time.Time) int {
+		assert.NoError(t, s.triggerCronJobs(ctx, now))
+		assert.NoError(t, s.jCache.Update())
+		jobs, err := s.jCache.UnfinishedJobs()
+		assert.NoError(t, err)
+		count := 0
+		for _, j := range jobs {
+			if j.Name == "Cron-Job" {
+				count++
+			}
+		}
+		return count
+	}
+	midnight := first.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	assert.Equal(t, 0, countCronJobs(midnight.Add(-time.Minute)))
+	assert.Equal(t, 1, countCronJobs(midnight.Add(time.Minute)))
+	assert.Equal(t, 1, countCronJobs(midnight.Add(time.Hour)))
+	assert.Equal(t, 2, countCronJobs(midnight.Add(24*time.Hour)))
+
+	// The last-triggered times are persisted.
+	ct, err := newCronTriggers(s.workdir)
+	assert.NoError(t, err)
+	assert.True(t, midnight.Add(24*time.Hour).Equal(ct.LastTriggered[key]))
+
+	// The file is only written when a job was triggered.
+	jsonFile := path.Join(s.workdir, CRON_TRIGGERS_JSON_FILE)
+	assert.NoError(t, os.Remove(jsonFile))
+	assert.Equal(t, 2, countCronJobs(midnight.Add(25*time.Hour)))
+	_, err = os.Stat(jsonFile)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, 3, countCronJobs(midnight.Add(48*time.Hour)))
+	_, err = os.Stat(jsonFile)
+	assert.NoError(t, err)
+
+	// If the jobs can't be inserted, the last-triggered time is unchanged
+	// and the job is triggered on the next pass.
+	d := s.db
+	s.db = &putJobsErrDB{DB: d}
+	assert.Error(t, s.triggerCronJobs(ctx, midnight.Add(72*time.Hour)))
+	assert.True(t, midnight.Add(48*time.Hour).Equal(s.cronTriggers.LastTriggered[key]))
+	s.db = d
+	assert.Equal(t, 4, countCronJobs(midnight.Add(72*time.Hour)))
+}
+
+// putJobsErrDB fails to insert any jobs.
+type putJobsErrDB struct {
+	db.DB
+}
+
+func (d *putJobsErrDB) PutJobs(jobs []*db.Job) error {
+	return errors.New("Failed to insert jobs")
+}
+
 func TestUpdateUnfinishedTasks(t *testing.T) {
-	_, _, _, swarmingClient, s, _, cleanup := setup(t)
+	ctx, _, _, swarmingClient, s, _, cleanup := setup(t)
 	defer cleanup()
 
 	// Create a few tasks.
@@ -2763,7 +3188,7 @@ func TestUpdateUnfinishedTasks(t *testing.T) {
 	deepequal.AssertDeepEqual(t, []*swarming_api.SwarmingRpcsTaskRequestMetadata{m1, m2}, got)
 
 	// Ensure that we update the tasks as expected.
-	assert.NoError(t, s.updateUnfinishedTasks())
+	assert.NoError(t, s.updateUnfinishedTasks(ctx))
 	for _, task := range tasks {
 		got, err := s.db.GetTaskById(task.Id)
 		assert.NoError(t, err)
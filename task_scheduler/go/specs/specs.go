@@ -12,10 +12,12 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 
+	"go.skia.org/infra/go/cron"
 	"go.skia.org/infra/go/exec"
 	"go.skia.org/infra/go/git"
 	"go.skia.org/infra/go/git/repograph"
@@ -50,6 +52,21 @@ const (
 	// Trigger this job weekly.
 	TRIGGER_WEEKLY = "weekly"
 
+	// Triggers which take an argument consist of one of these prefixes
+	// followed by the argument.
+
+	// Trigger this job at HEAD of the master branch according to a cron
+	// expression, eg. "cron:0 3 * * 1-5". Cron expressions are evaluated
+	// in UTC. See go/cron for the supported syntax.
+	TRIGGER_CRON_PREFIX = "cron:"
+	// Trigger this job for commits on branches whose names match a
+	// regular expression, eg. "branch:^chrome/m\\d+$".
+	TRIGGER_BRANCH_PREFIX = "branch:"
+	// Trigger this job for commits which have a tag whose name matches a
+	// regular expression, eg. "tag:^v\\d+\\.\\d+$". Only tagged commits
+	// within the scheduling window are considered.
+	TRIGGER_TAG_PREFIX = "tag:"
+
 	VARIABLE_SYNTAX = "<(%s)"
 
 	VARIABLE_BUILDBUCKET_BUILD_ID = "BUILDBUCKET_BUILD_ID"
@@ -158,6 +175,12 @@ func (c *TasksCfg) Validate() error {
 		return err
 	}
 
+	for name, j := range c.Jobs {
+		if err := j.Validate(); err != nil {
+			return fmt.Errorf("Invalid job %q: %s", name, err)
+		}
+	}
+
 	return nil
 }
 
@@ -204,6 +227,12 @@ type TaskSpec struct {
 	// ExtraTags are extra tags to add to the Swarming task.
 	ExtraTags map[string]string `json:"extra_tags,omitempty"`
 
+	// Idempotent indicates that the outputs of the task depend only on its
+	// inputs, ie. its isolated input, command, dimensions, CIPD packages,
+	// etc. If true, the outputs of a previous successful task with the same
+	// inputs are reused instead of running the task again.
+	Idempotent bool `json:"idempotent,omitempty"`
+
 	// IoTimeout is the maximum amount of time which the task may take to
 	// communicate with the server.
 	IoTimeout time.Duration `json:"io_timeout_ns,omitempty"`
@@ -299,6 +328,7 @@ func (t *TaskSpec) Copy() *TaskSpec {
 		Expiration:       t.Expiration,
 		ExtraArgs:        extraArgs,
 		ExtraTags:        extraTags,
+		Idempotent:       t.Idempotent,
 		IoTimeout:        t.IoTimeout,
 		Isolate:          t.Isolate,
 		MaxAttempts:      t.MaxAttempts,
@@ -340,8 +370,79 @@ type JobSpec struct {
 	Priority float64 `json:"priority,omitempty"`
 	// The names of TaskSpecs that are direct dependencies of this JobSpec.
 	TaskSpecs []string `json:"tasks"`
-	// One of the TRIGGER_* constants; see documentation above.
+	// One of the TRIGGER_* constants or a TRIGGER_*_PREFIX followed by its
+	// argument; see documentation above.
 	Trigger string `json:"trigger,omitempty"`
+	// PathFilters are glob patterns, as used by path.Match, which restrict
+	// commit-triggered jobs to commits which modify a matching file. A
+	// pattern which matches a directory matches all files within it. If
+	// empty, the job is triggered regardless of the modified files.
+	PathFilters []string `json:"path_filters,omitempty"`
+}
+
+// TriggerArg returns the argument of the given trigger and true if the
+// trigger starts with the given TRIGGER_*_PREFIX, or false otherwise.
+func TriggerArg(trigger, prefix string) (string, bool) {
+	if !strings.HasPrefix(trigger, prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(trigger, prefix)), true
+}
+
+// Validate returns an error if the JobSpec is not valid.
+func (j *JobSpec) Validate() error {
+	commitTriggered := false
+	switch j.Trigger {
+	case TRIGGER_ANY_BRANCH, TRIGGER_MASTER_ONLY:
+		commitTriggered = true
+	case TRIGGER_NIGHTLY, TRIGGER_ON_DEMAND, TRIGGER_WEEKLY:
+	default:
+		if expr, ok := TriggerArg(j.Trigger, TRIGGER_CRON_PREFIX); ok {
+			if _, err := cron.Parse(expr); err != nil {
+				return err
+			}
+		} else if pattern, ok := TriggerArg(j.Trigger, TRIGGER_BRANCH_PREFIX); ok {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("Invalid branch pattern %q: %s", pattern, err)
+			}
+			commitTriggered = true
+		} else if pattern, ok := TriggerArg(j.Trigger, TRIGGER_TAG_PREFIX); ok {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("Invalid tag pattern %q: %s", pattern, err)
+			}
+		} else {
+			return fmt.Errorf("Unknown trigger %q", j.Trigger)
+		}
+	}
+	for _, p := range j.PathFilters {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("Invalid path filter %q: %s", p, err)
+		}
+	}
+	if len(j.PathFilters) > 0 && !commitTriggered {
+		return fmt.Errorf("Path filters are only supported for jobs which are triggered by commits, not %q", j.Trigger)
+	}
+	return nil
+}
+
+// MatchesPaths returns true iff the JobSpec has no PathFilters or any of the
+// given file paths matches one of them.
+func (j *JobSpec) MatchesPaths(files []string) bool {
+	if len(j.PathFilters) == 0 {
+		return true
+	}
+	for _, f := range files {
+		// Check the file itself and all of its parent directories.
+		for p := f; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+			for _, pattern := range j.PathFilters {
+				// Errors are checked in Validate.
+				if match, _ := path.Match(pattern, p); match {
+					return true
+				}
+			}
+		}
+	}
+	return false
 }
 
 // Copy returns a copy of the JobSpec.
@@ -352,9 +453,10 @@ func (j *JobSpec) Copy() *JobSpec {
 		copy(taskSpecs, j.TaskSpecs)
 	}
 	return &JobSpec{
-		Priority:  j.Priority,
-		TaskSpecs: taskSpecs,
-		Trigger:   j.Trigger,
+		PathFilters: util.CopyStringSlice(j.PathFilters),
+		Priority:    j.Priority,
+		TaskSpecs:   taskSpecs,
+		Trigger:     j.Trigger,
 	}
 }
 
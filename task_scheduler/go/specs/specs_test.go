@@ -62,6 +62,7 @@ func TestCopyTaskSpec(t *testing.T) {
 		ExtraTags: map[string]string{
 			"dummy_tag": "dummy_val",
 		},
+		Idempotent:     true,
 		IoTimeout:      10 * time.Minute,
 		Isolate:        "abc123",
 		MaxAttempts:    5,
@@ -75,13 +76,78 @@ func TestCopyTaskSpec(t *testing.T) {
 func TestCopyJobSpec(t *testing.T) {
 	testutils.SmallTest(t)
 	v := &JobSpec{
-		TaskSpecs: []string{"Build", "Test"},
-		Trigger:   "trigger-name",
-		Priority:  753,
+		PathFilters: []string{"src/*", "*.gn"},
+		TaskSpecs:   []string{"Build", "Test"},
+		Trigger:     "trigger-name",
+		Priority:    753,
 	}
 	deepequal.AssertCopy(t, v, v.Copy())
 }
 
+func TestJobSpecValidate(t *testing.T) {
+	testutils.SmallTest(t)
+	test := func(j *JobSpec, expectErr string) {
+		err := j.Validate()
+		if expectErr == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), expectErr)
+		}
+	}
+	for _, trigger := range []string{TRIGGER_ANY_BRANCH, TRIGGER_MASTER_ONLY, TRIGGER_NIGHTLY, TRIGGER_ON_DEMAND, TRIGGER_WEEKLY} {
+		test(&JobSpec{Trigger: trigger}, "")
+	}
+	test(&JobSpec{Trigger: "bogus"}, "Unknown trigger")
+	test(&JobSpec{Trigger: "cron:0 3 * * 1-5"}, "")
+	test(&JobSpec{Trigger: "cron: @daily"}, "")
+	test(&JobSpec{Trigger: "cron:0 3 * *"}, "must have 5 fields")
+	test(&JobSpec{Trigger: "branch:^chrome/m\\d+$"}, "")
+	test(&JobSpec{Trigger: "branch:chrome/(m"}, "Invalid branch pattern")
+	test(&JobSpec{Trigger: "tag:^v[0-9]+$"}, "")
+	test(&JobSpec{Trigger: "tag:v[0-9"}, "Invalid tag pattern")
+
+	// Path filters.
+	test(&JobSpec{PathFilters: []string{"src/*", "*.gn"}}, "")
+	test(&JobSpec{PathFilters: []string{"src/*"}, Trigger: TRIGGER_MASTER_ONLY}, "")
+	test(&JobSpec{PathFilters: []string{"src/*"}, Trigger: "branch:.*"}, "")
+	test(&JobSpec{PathFilters: []string{"src/["}}, "Invalid path filter")
+	test(&JobSpec{PathFilters: []string{"src/*"}, Trigger: TRIGGER_NIGHTLY}, "only supported for jobs which are triggered by commits")
+	test(&JobSpec{PathFilters: []string{"src/*"}, Trigger: "cron:@daily"}, "only supported for jobs which are triggered by commits")
+
+	// TasksCfg.Validate checks the JobSpecs.
+	cfg := &TasksCfg{
+		Tasks: map[string]*TaskSpec{
+			"a": {Isolate: "abc123"},
+		},
+		Jobs: map[string]*JobSpec{
+			"j": {TaskSpecs: []string{"a"}, Trigger: "cron:61 * * * *"},
+		},
+	}
+	err := cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid job \"j\"")
+}
+
+func TestJobSpecMatchesPaths(t *testing.T) {
+	testutils.SmallTest(t)
+	j := &JobSpec{}
+	assert.True(t, j.MatchesPaths(nil))
+	assert.True(t, j.MatchesPaths([]string{"README.md"}))
+
+	j.PathFilters = []string{"src/gpu", "*.gn", "infra/bots/*.json"}
+	assert.False(t, j.MatchesPaths(nil))
+	assert.False(t, j.MatchesPaths([]string{"README.md", "src/core/SkCanvas.cpp"}))
+	assert.True(t, j.MatchesPaths([]string{"README.md", "BUILD.gn"}))
+	assert.True(t, j.MatchesPaths([]string{"src/gpu/GrContext.cpp"}))
+	assert.True(t, j.MatchesPaths([]string{"src/gpu/gl/GrGLGpu.cpp"}))
+	assert.False(t, j.MatchesPaths([]string{"src/gpuX/file.cpp"}))
+	assert.True(t, j.MatchesPaths([]string{"infra/bots/tasks.json"}))
+	assert.False(t, j.MatchesPaths([]string{"infra/bots/recipes/test.py"}))
+	// Patterns only match the path from the root of the repo.
+	assert.False(t, j.MatchesPaths([]string{"third_party/BUILD.gn"}))
+}
+
 func TestTaskSpecs(t *testing.T) {
 	testutils.LargeTest(t)
 
@@ -37,6 +37,8 @@ import (
 	"go.skia.org/infra/task_scheduler/go/db/local_db"
 	"go.skia.org/infra/task_scheduler/go/db/recovery"
 	"go.skia.org/infra/task_scheduler/go/db/remote_db"
+	"go.skia.org/infra/task_scheduler/go/executor"
+	"go.skia.org/infra/task_scheduler/go/flakes"
 	"go.skia.org/infra/task_scheduler/go/scheduling"
 	"go.skia.org/infra/task_scheduler/go/testutils"
 	"go.skia.org/infra/task_scheduler/go/tryjobs"
@@ -46,6 +48,10 @@ const (
 	// APP_NAME is the name of this app.
 	APP_NAME = "task_scheduler"
 
+	// Supported values of --task_executor.
+	TASK_EXECUTOR_LOCAL    = "local"
+	TASK_EXECUTOR_SWARMING = "swarming"
+
 	PUBSUB_SUBSCRIBER_TASK_SCHEDULER          = "task-scheduler"
 	PUBSUB_SUBSCRIBER_TASK_SCHEDULER_INTERNAL = "task-scheduler-internal"
 )
@@ -60,6 +66,10 @@ var (
 	// Task Scheduler instance.
 	ts *scheduling.TaskScheduler
 
+	// Swarming task executor, if used. Receives Pub/Sub messages about
+	// Swarming tasks.
+	swarmingExecutor *executor.SwarmingTaskExecutor
+
 	// Task Scheduler database.
 	tsDb db.BackupDBCloser
 
@@ -80,12 +90,14 @@ var (
 	dbPort         = flag.String("db_port", ":8008", "HTTP service port for the database RPC server (e.g., ':8008')")
 	isolateServer  = flag.String("isolate_server", isolate.ISOLATE_SERVER_URL, "Which Isolate server to use.")
 	local          = flag.Bool("local", false, "Whether we're running on a dev machine vs in production.")
+	localWorkers   = flag.String("local_workers", "", "JSON file containing the list of workers used by the local task executor.")
 	repoUrls       = common.NewMultiStringFlag("repo", nil, "Repositories for which to schedule tasks.")
 	recipesCfgFile = flag.String("recipes_cfg", "", "Path to the recipes.cfg file.")
 	resourcesDir   = flag.String("resources_dir", "", "The directory to find templates, JS, and CSS files. If blank, assumes you're running inside a checkout and will attempt to find the resources relative to this source file.")
 	scoreDecay24Hr = flag.Float64("scoreDecay24Hr", 0.9, "Task candidate scores are penalized using linear time decay. This is the desired value after 24 hours. Setting it to 1.0 causes commits not to be prioritized according to commit time.")
 	swarmingPools  = common.NewMultiStringFlag("pool", swarming.POOLS_PUBLIC, "Which Swarming pools to use.")
 	swarmingServer = flag.String("swarming_server", swarming.SWARMING_SERVER, "Which Swarming server to use.")
+	taskExecutor   = flag.String("task_executor", TASK_EXECUTOR_SWARMING, fmt.Sprintf("Which backend runs the tasks; either %q or %q.", TASK_EXECUTOR_SWARMING, TASK_EXECUTOR_LOCAL))
 	timePeriod     = flag.String("timeWindow", "4d", "Time period to use.")
 	tryJobBucket   = flag.String("tryjob_bucket", tryjobs.BUCKET_PRIMARY, "Which Buildbucket bucket to use for try jobs.")
 	commitWindow   = flag.Int("commitWindow", 10, "Minimum number of recent commits to keep in the timeWindow.")
@@ -93,6 +105,12 @@ var (
 	workdir        = flag.String("workdir", "workdir", "Working directory to use.")
 	promPort       = flag.String("prom_port", ":20000", "Metrics service address (e.g., ':10110')")
 
+	flakeWindow              = flag.Duration("flake_window", flakes.DEFAULT_POLICY.Window, "Time period over which the flake rates of task specs are computed.")
+	flakeRetryThreshold      = flag.Float64("flake_retry_threshold", flakes.DEFAULT_POLICY.RetryThreshold, "Task specs whose flake rate is at least this value get extra attempts.")
+	flakeExtraAttempts       = flag.Int("flake_extra_attempts", flakes.DEFAULT_POLICY.ExtraAttempts, "Number of extra attempts for flaky task specs.")
+	flakeQuarantineThreshold = flag.Float64("flake_quarantine_threshold", flakes.DEFAULT_POLICY.QuarantineThreshold, "Task specs whose flake rate is at least this value are quarantined. Zero disables quarantine.")
+	flakeBlacklistDuration   = flag.Duration("flake_blacklist_duration", 0, "If set, newly-quarantined task specs are blacklisted for this long.")
+
 	pubsubTopicName      = flag.String("pubsub_topic", swarming.PUBSUB_TOPIC_SWARMING_TASKS, "Pub/Sub topic to use for Swarming tasks.")
 	pubsubSubscriberName = flag.String("pubsub_subscriber", PUBSUB_SUBSCRIBER_TASK_SCHEDULER, "Pub/Sub subscriber name.")
 )
@@ -163,13 +181,15 @@ func blacklistHandler(w http.ResponseWriter, r *http.Request) {
 		rules = append(rules, r)
 	}
 	enc, err := json.Marshal(&struct {
-		Commits   []string
-		Rules     []*blacklist.Rule
-		TaskSpecs []string
+		Commits     []string
+		Quarantined []*flakes.Quarantine
+		Rules       []*blacklist.Rule
+		TaskSpecs   []string
 	}{
-		Commits:   c,
-		Rules:     rules,
-		TaskSpecs: t,
+		Commits:     c,
+		Quarantined: ts.GetFlakeTracker().Quarantined(),
+		Rules:       rules,
+		TaskSpecs:   t,
 	})
 	if err != nil {
 		httputils.ReportError(w, r, err, "Failed to encode JSON.")
@@ -508,6 +528,16 @@ func jsonTaskSearchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// jsonQuarantineHandler returns the TaskSpecs which are quarantined because
+// they are too flaky.
+func jsonQuarantineHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ts.GetFlakeTracker().Quarantined()); err != nil {
+		httputils.ReportError(w, r, err, fmt.Sprintf("Failed to encode response: %s", err))
+		return
+	}
+}
+
 // jsonTaskCandidateSearchHandler allows for searching task candidates based on
 // their TaskKey.
 func jsonTaskCandidateSearchHandler(w http.ResponseWriter, r *http.Request) {
@@ -544,6 +574,7 @@ func runServer(serverURL string, taskDb db.RemoteDB) {
 	r.HandleFunc("/json/job/{id}", jsonJobHandler)
 	r.HandleFunc("/json/job/{id}/cancel", login.RestrictEditorFn(jsonCancelJobHandler)).Methods(http.MethodPost)
 	r.HandleFunc("/json/jobs/search", jsonJobSearchHandler)
+	r.HandleFunc("/json/quarantine", jsonQuarantineHandler)
 	r.HandleFunc("/json/task", jsonTaskHandler).Methods(http.MethodPost, http.MethodPut)
 	r.HandleFunc("/json/task/{id}", jsonGetTaskHandler)
 	r.HandleFunc("/json/taskCandidates/search", jsonTaskCandidateSearchHandler)
@@ -558,7 +589,9 @@ func runServer(serverURL string, taskDb db.RemoteDB) {
 	r.HandleFunc("/oauth2callback/", login.OAuth2CallbackHandler)
 
 	sklog.AddLogsRedirect(r)
-	swarming.RegisterPubSubServer(ts, r)
+	if swarmingExecutor != nil {
+		swarming.RegisterPubSubServer(swarmingExecutor, r)
+	}
 	dbRouter := r.PathPrefix("/db").Subrouter()
 	dbRouter.Use(login.Restrict(allowed.NewAllowedFromList(VALID_DB_EMAILS)))
 	if err := remote_db.RegisterServer(taskDb, dbRouter); err != nil {
@@ -620,14 +653,22 @@ func main() {
 	}
 	httpClient := httputils.DefaultClientConfig().WithTokenSource(tokenSource).With2xxOnly().Client()
 
-	// Initialize Isolate client.
+	if *taskExecutor != TASK_EXECUTOR_SWARMING && *taskExecutor != TASK_EXECUTOR_LOCAL {
+		sklog.Fatalf("Unknown --task_executor %q", *taskExecutor)
+	}
+
+	// Initialize Isolate client. The local task executor does not use
+	// isolated inputs.
 	isolateServerUrl := *isolateServer
 	if *local {
 		isolateServerUrl = isolate.ISOLATE_SERVER_URL_FAKE
 	}
-	isolateClient, err := isolate.NewClient(wdAbs, isolateServerUrl)
-	if err != nil {
-		sklog.Fatal(err)
+	var isolateClient *isolate.Client
+	if *taskExecutor == TASK_EXECUTOR_SWARMING {
+		isolateClient, err = isolate.NewClient(wdAbs, isolateServerUrl)
+		if err != nil {
+			sklog.Fatal(err)
+		}
 	}
 
 	// Gerrit API client.
@@ -664,23 +705,40 @@ func main() {
 		sklog.Fatal(err)
 	}
 
-	// Initialize Swarming client.
-	var swarm swarming.ApiClient
-	if *local {
-		swarmTestClient := testutils.NewTestClient()
-		swarmTestClient.MockBots(testutils.MockSwarmingBotsForAllTasksForTesting(ctx, repos))
-		go testutils.PeriodicallyUpdateMockTasksForTesting(swarmTestClient)
-		swarm = swarmTestClient
-	} else {
-		ts, err := auth.NewLegacyTokenSource(*local, oauthCacheFile, "", swarming.AUTH_SCOPE)
+	// Create the task executor.
+	var taskExec executor.TaskExecutor
+	if *taskExecutor == TASK_EXECUTOR_LOCAL {
+		if *localWorkers == "" {
+			sklog.Fatalf("--local_workers is required for the local task executor.")
+		}
+		workers, err := executor.ReadLocalWorkers(*localWorkers)
 		if err != nil {
 			sklog.Fatal(err)
 		}
-		swarmClient := httputils.DefaultClientConfig().WithTokenSource(ts).WithDialTimeout(3 * time.Minute).With2xxOnly().Client()
-		swarm, err = swarming.NewApiClient(swarmClient, *swarmingServer)
+		taskExec, err = executor.NewLocalTaskExecutor(path.Join(wdAbs, "local_tasks"), workers)
 		if err != nil {
 			sklog.Fatal(err)
 		}
+	} else {
+		var swarm swarming.ApiClient
+		if *local {
+			swarmTestClient := testutils.NewTestClient()
+			swarmTestClient.MockBots(testutils.MockSwarmingBotsForAllTasksForTesting(ctx, repos))
+			go testutils.PeriodicallyUpdateMockTasksForTesting(swarmTestClient)
+			swarm = swarmTestClient
+		} else {
+			ts, err := auth.NewLegacyTokenSource(*local, oauthCacheFile, "", swarming.AUTH_SCOPE)
+			if err != nil {
+				sklog.Fatal(err)
+			}
+			swarmClient := httputils.DefaultClientConfig().WithTokenSource(ts).WithDialTimeout(3 * time.Minute).With2xxOnly().Client()
+			swarm, err = swarming.NewApiClient(swarmClient, *swarmingServer)
+			if err != nil {
+				sklog.Fatal(err)
+			}
+		}
+		swarmingExecutor = executor.NewSwarmingTaskExecutor(tsDb, swarm, isolateServerUrl, *swarmingPools, *pubsubTopicName)
+		taskExec = swarmingExecutor
 	}
 
 	// Start DB backup.
@@ -710,10 +768,18 @@ func main() {
 	if *local {
 		serverURL = "http://" + *host + *port
 	}
-	if err := swarming.InitPubSub(serverURL, *pubsubTopicName, *pubsubSubscriberName); err != nil {
-		sklog.Fatal(err)
+	if swarmingExecutor != nil {
+		if err := swarming.InitPubSub(serverURL, *pubsubTopicName, *pubsubSubscriberName); err != nil {
+			sklog.Fatal(err)
+		}
 	}
-	ts, err = scheduling.NewTaskScheduler(ctx, tsDb, period, *commitWindow, wdAbs, serverURL, repos, isolateClient, swarm, httpClient, *scoreDecay24Hr, tryjobs.API_URL_PROD, *tryJobBucket, common.PROJECT_REPO_MAPPING, *swarmingPools, *pubsubTopicName, depotTools, gerrit)
+	flakePolicy := flakes.DEFAULT_POLICY
+	flakePolicy.Window = *flakeWindow
+	flakePolicy.RetryThreshold = *flakeRetryThreshold
+	flakePolicy.ExtraAttempts = *flakeExtraAttempts
+	flakePolicy.QuarantineThreshold = *flakeQuarantineThreshold
+	flakePolicy.BlacklistDuration = *flakeBlacklistDuration
+	ts, err = scheduling.NewTaskScheduler(ctx, tsDb, period, *commitWindow, wdAbs, serverURL, repos, isolateClient, taskExec, httpClient, *scoreDecay24Hr, flakePolicy, tryjobs.API_URL_PROD, *tryJobBucket, common.PROJECT_REPO_MAPPING, depotTools, gerrit)
 	if err != nil {
 		sklog.Fatal(err)
 	}
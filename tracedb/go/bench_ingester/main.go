@@ -28,7 +28,7 @@ var (
 func main() {
 	common.Init()
 
-	inputSource, err := ingestion.NewFileSystemSource("local-dir", *inputDir)
+	inputSource, err := ingestion.NewFileSystemSource("local-dir", *inputDir, nil)
 	if err != nil {
 		sklog.Fatalf("Failed to open input dir %s. Got error: %s", *inputDir, err)
 	}